@@ -186,3 +186,144 @@ load _helpers
   local actual=$(echo $object | yq -r '.verbs | index("watch")' | tee /dev/stderr)
   [ "${actual}" != null ]
 }
+
+#--------------------------------------------------------------------
+# global.enableEndpointSlices
+
+@test "connectInject/ClusterRole: no endpointslices access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "endpointslices")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: allows endpointslices access with global.enableEndpointSlices=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.enableEndpointSlices=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "endpointslices")) | length' | tee /dev/stderr)
+  [ "${actual}" = "1" ]
+}
+
+#--------------------------------------------------------------------
+# connectInject.proxyConfigs
+
+@test "connectInject/ClusterRole: no proxyconfigs access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "proxyconfigs")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: allows proxyconfigs access with connectInject.proxyConfigs.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.proxyConfigs.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "proxyconfigs")) | .[0]' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.apiGroups[0]' | tee /dev/stderr)
+  [ "${actual}" = "consul.hashicorp.com" ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("list")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("watch")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
+#--------------------------------------------------------------------
+# connectInject.registrationStatus
+
+@test "connectInject/ClusterRole: no pods/status or events access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "pods/status" or .resources[0] == "events")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: allows pods/status and events access with connectInject.registrationStatus.enabled=true" {
+  cd `chart_dir`
+  local rules=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.registrationStatus.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules' | tee /dev/stderr)
+
+  local actual=$(echo $rules | yq -r 'map(select(.resources[0] == "pods/status")) | .[0].verbs | index("patch")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $rules | yq -r 'map(select(.resources[0] == "events")) | .[0].verbs | index("create")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
+#--------------------------------------------------------------------
+# connectInject.transparentProxy.networkPolicies
+
+@test "connectInject/ClusterRole: no networkpolicies access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "networkpolicies" or .resources[0] == "serviceintentions")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: allows networkpolicies and serviceintentions access with connectInject.transparentProxy.networkPolicies.enabled=true" {
+  cd `chart_dir`
+  local rules=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.networkPolicies.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules' | tee /dev/stderr)
+
+  local actual=$(echo $rules | yq -r 'map(select(.resources[0] == "networkpolicies")) | .[0].apiGroups[0]' | tee /dev/stderr)
+  [ "${actual}" = "networking.k8s.io" ]
+
+  local actual=$(echo $rules | yq -r 'map(select(.resources[0] == "networkpolicies")) | .[0].verbs | index("create")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $rules | yq -r 'map(select(.resources[0] == "serviceintentions")) | .[0].verbs | index("watch")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
+#--------------------------------------------------------------------
+# connectInject.agentless
+
+@test "connectInject/ClusterRole: no nodes access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "nodes")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/ClusterRole: allows watching nodes with connectInject.agentless.enabled=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-clusterrole.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.agentless.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules | map(select(.resources[0] == "nodes")) | .[0].verbs' | tee /dev/stderr)
+  [ "${actual}" = '["get","list","watch"]' ]
+}
@@ -168,6 +168,30 @@ EOF
   rm -f "$temp_file"
 }
 
+#--------------------------------------------------------------------
+# global.enableEndpointSlices
+
+@test "connectInject/Deployment: EndpointSlices are disabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-endpoint-slices"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: can enable EndpointSlices with global.enableEndpointSlices=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'global.enableEndpointSlices=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-endpoint-slices=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
 #--------------------------------------------------------------------
 # metrics
 
@@ -1831,6 +1855,371 @@ EOF
   [[ "$output" =~ "setting global.peering.enabled to true requires connectInject.enabled to be true" ]]
 }
 
+#--------------------------------------------------------------------
+# proxyConfigs
+
+@test "connectInject/Deployment: -enable-proxy-configs is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-proxy-configs=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -enable-proxy-configs=true is set when connectInject.proxyConfigs.enabled is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.proxyConfigs.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-proxy-configs=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# injectionProfiles
+
+@test "connectInject/Deployment: -injection-profiles-configmap is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-injection-profiles-configmap"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -injection-profiles-configmap is set when connectInject.injectionProfiles is set" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.injectionProfiles.small.concurrency=1' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-injection-profiles-configmap=release-name-consul-connect-inject-injection-profiles"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# transparentProxy.networkPolicies
+
+@test "connectInject/Deployment: -enable-network-policies is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-network-policies=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -enable-network-policies=true is set when connectInject.transparentProxy.networkPolicies.enabled is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'controller.enabled=true' \
+      --set 'connectInject.transparentProxy.networkPolicies.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-network-policies=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: fails if connectInject.transparentProxy.networkPolicies.enabled is true without controller.enabled" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.transparentProxy.networkPolicies.enabled=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "controller.enabled must be true if connectInject.transparentProxy.networkPolicies.enabled is true" ]]
+}
+
+#--------------------------------------------------------------------
+# warnOnInvalidAnnotations
+
+@test "connectInject/Deployment: -warn-on-invalid-annotations is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-warn-on-invalid-annotations=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -warn-on-invalid-annotations=true is set when connectInject.warnOnInvalidAnnotations is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.warnOnInvalidAnnotations=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-warn-on-invalid-annotations=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# registrationStatus
+
+@test "connectInject/Deployment: -enable-registration-status is not set by default" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-registration-status=true"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-registration-readiness-gate=true"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -enable-registration-status=true is set when connectInject.registrationStatus.enabled is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.registrationStatus.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-registration-status=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: -enable-registration-readiness-gate=true is set when connectInject.registrationStatus.readinessGate is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.registrationStatus.enabled=true' \
+      --set 'connectInject.registrationStatus.readinessGate=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-registration-readiness-gate=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: fails if connectInject.registrationStatus.readinessGate is true without connectInject.registrationStatus.enabled" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.registrationStatus.readinessGate=true' .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "connectInject.registrationStatus.enabled must be true if connectInject.registrationStatus.readinessGate is true" ]]
+}
+
+#--------------------------------------------------------------------
+# agentless
+
+@test "connectInject/Deployment: agentless registration is not enabled by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-agentless-registration=true"))' | tee /dev/stderr)
+
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: fails if connectInject.agentless.enabled is true with a Consul version before 1.14" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.agentless.enabled=true' \
+      --set 'global.image=hashicorp/consul:1.13.2' \
+      .
+  [ "$status" -eq 1 ]
+  [[ "$output" =~ "connectInject.agentless.enabled requires Consul 1.14.0 or later" ]]
+}
+
+@test "connectInject/Deployment: connectInject.agentless.enabled is allowed with an image without a version" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.agentless.enabled=true' \
+      --set 'global.image=hashicorp/consul:latest' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-agentless-registration=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: -enable-agentless-registration=true and -consul-server-host are set when connectInject.agentless.enabled is true" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.agentless.enabled=true' \
+      --set 'global.image=hashicorp/consul:1.14.0' \
+      --namespace default \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-agentless-registration=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-consul-server-host=\"release-name-consul-server.default.svc\""))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: -consul-server-grpc-port is 8503 by default when connectInject.agentless.enabled is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.agentless.enabled=true' \
+      --set 'global.image=hashicorp/consul:1.14.0' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-consul-server-grpc-port=8503"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: -consul-server-grpc-port can be set with connectInject.agentless.grpcPort" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.agentless.enabled=true' \
+      --set 'global.image=hashicorp/consul:1.14.0' \
+      --set 'connectInject.agentless.grpcPort=8502' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-consul-server-grpc-port=8502"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: CONSUL_HTTP_ADDR is the server service when connectInject.agentless.enabled is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.agentless.enabled=true' \
+      --set 'global.image=hashicorp/consul:1.14.0' \
+      --namespace default \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].env[] | select(.name == "CONSUL_HTTP_ADDR") | .value' | tee /dev/stderr)
+
+  [ "${actual}" = "http://release-name-consul-server.default.svc:8500" ]
+}
+
+@test "connectInject/Deployment: CONSUL_HTTP_ADDR is the https server service when connectInject.agentless.enabled and global.tls.enabled are true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.agentless.enabled=true' \
+      --set 'global.image=hashicorp/consul:1.14.0' \
+      --set 'global.tls.enabled=true' \
+      --namespace default \
+      . | tee /dev/stderr |
+      yq -r '.spec.template.spec.containers[0].env[] | select(.name == "CONSUL_HTTP_ADDR") | .value' | tee /dev/stderr)
+
+  [ "${actual}" = "https://release-name-consul-server.default.svc:8501" ]
+}
+
+#--------------------------------------------------------------------
+# sidecar lifecycle
+
+@test "connectInject/Deployment: sidecar lifecycle flags are not set by default" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-default-sidecar-proxy-shutdown-grace-period-seconds"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-default-sidecar-proxy-hold-application-until-ready"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-native-sidecars"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: sidecar lifecycle flags are set when configured" {
+  cd `chart_dir`
+  local cmd=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.sidecarProxy.lifecycle.shutdownGracePeriodSeconds=20' \
+      --set 'connectInject.sidecarProxy.lifecycle.holdApplicationUntilReady=true' \
+      --set 'connectInject.nativeSidecars=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command' | tee /dev/stderr)
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-default-sidecar-proxy-shutdown-grace-period-seconds=20"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-default-sidecar-proxy-hold-application-until-ready=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+
+  local actual=$(echo "$cmd" |
+    yq 'any(contains("-enable-native-sidecars=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+#--------------------------------------------------------------------
+# multiPort
+
+@test "connectInject/Deployment: -enable-multiport-shared-service-account is not set by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-multiport-shared-service-account"))' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "connectInject/Deployment: -enable-multiport-shared-service-account is set when connectInject.multiPort.sharedServiceAccount is true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.multiPort.sharedServiceAccount=true' \
+      . | tee /dev/stderr |
+      yq '.spec.template.spec.containers[0].command | any(contains("-enable-multiport-shared-service-account=true"))' | tee /dev/stderr)
+  [ "${actual}" = "true" ]
+}
+
+@test "connectInject/Deployment: clients are not required when connectInject.agentless.enabled is true" {
+  cd `chart_dir`
+  run helm template \
+      -s templates/connect-inject-deployment.yaml  \
+      --set 'client.enabled=false' \
+      --set 'client.grpc=false' \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.agentless.enabled=true' \
+      --set 'global.image=hashicorp/consul:1.14.0' .
+  [ "$status" -eq 0 ]
+}
 
 #--------------------------------------------------------------------
 # openshift
@@ -0,0 +1,41 @@
+#!/usr/bin/env bats
+
+load _helpers
+
+@test "connectInject/InjectionProfilesConfigMap: disabled by default" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/connect-inject-injection-profiles-configmap.yaml  \
+      --set 'connectInject.enabled=true' \
+      .
+}
+
+@test "connectInject/InjectionProfilesConfigMap: disabled with connectInject.enabled=false" {
+  cd `chart_dir`
+  assert_empty helm template \
+      -s templates/connect-inject-injection-profiles-configmap.yaml  \
+      --set 'connectInject.enabled=false' \
+      --set 'connectInject.injectionProfiles.small.concurrency=1' \
+      .
+}
+
+@test "connectInject/InjectionProfilesConfigMap: renders a key per profile" {
+  cd `chart_dir`
+  local data=$(helm template \
+      -s templates/connect-inject-injection-profiles-configmap.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.injectionProfiles.small.concurrency=1' \
+      --set 'connectInject.injectionProfiles.small.resources.limits.cpu=100m' \
+      --set 'connectInject.injectionProfiles.large.concurrency=4' \
+      . | tee /dev/stderr |
+      yq -r '.data' | tee /dev/stderr)
+
+  local actual=$(echo "$data" | yq -r '.small' | yq -r '.concurrency' | tee /dev/stderr)
+  [ "${actual}" = "1" ]
+
+  local actual=$(echo "$data" | yq -r '.small' | yq -r '.resources.limits.cpu' | tee /dev/stderr)
+  [ "${actual}" = "100m" ]
+
+  local actual=$(echo "$data" | yq -r '.large' | yq -r '.concurrency' | tee /dev/stderr)
+  [ "${actual}" = "4" ]
+}
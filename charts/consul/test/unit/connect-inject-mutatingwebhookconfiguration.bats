@@ -65,3 +65,24 @@ load _helpers
       yq '.webhooks[2].name | contains("peeringdialers.consul.hashicorp.com")' | tee /dev/stderr)
   [ "${actual}" = "true" ]
 }
+
+@test "connectInject/MutatingWebhookConfiguration: no webhook for proxyconfigs by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      . | tee /dev/stderr |
+      yq '.webhooks | map(select(.name | contains("proxyconfigs.consul.hashicorp.com"))) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "connectInject/MutatingWebhookConfiguration: proxyConfigs is enabled, so webhook for proxyconfigs exists" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/connect-inject-mutatingwebhookconfiguration.yaml  \
+      --set 'connectInject.enabled=true' \
+      --set 'connectInject.proxyConfigs.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.webhooks[1].clientConfig.service.path' | tee /dev/stderr)
+  [ "${actual}" = "/mutate-v1alpha1-proxyconfigs" ]
+}
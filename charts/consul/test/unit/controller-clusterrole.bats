@@ -62,6 +62,18 @@ load _helpers
   local actual=$(echo $object | yq -r '.resources | index("terminatinggateways")' | tee /dev/stderr)
   [ "${actual}" != null ]
 
+  local actual=$(echo $object | yq -r '.resources | index("apigateways")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.resources | index("httproutes")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.resources | index("tcproutes")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.resources | index("inlinecertificates")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
   local actual=$(echo $object | yq -r '.verbs | index("create")' | tee /dev/stderr)
   [ "${actual}" != null ]
 
@@ -125,6 +137,18 @@ load _helpers
   local actual=$(echo $object | yq -r '.resources | index("terminatinggateways/status")' | tee /dev/stderr)
   [ "${actual}" != null ]
 
+  local actual=$(echo $object | yq -r '.resources | index("apigateways/status")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.resources | index("httproutes/status")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.resources | index("tcproutes/status")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.resources | index("inlinecertificates/status")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
   local actual=$(echo $object | yq -r '.verbs | index("get")' | tee /dev/stderr)
   [ "${actual}" != null ]
 
@@ -162,6 +186,59 @@ load _helpers
   [ "${actual}" != null ]
 }
 
+@test "controller/ClusterRole: no access to secrets by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "secrets")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "controller/ClusterRole: sets get, list and watch access to secrets with controller.inlineCertificateSecrets.enabled=true" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      --set 'controller.inlineCertificateSecrets.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "secrets")) | .[0]' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.apiGroups[0]' | tee /dev/stderr)
+  [ "${actual}" = "" ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("get")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("list")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("watch")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
+@test "controller/ClusterRole: sets get, list and watch access to peerings for exportedservices validation" {
+  cd `chart_dir`
+  local object=$(helm template \
+      -s templates/controller-clusterrole.yaml  \
+      --set 'controller.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "peeringacceptors")) | .[0]' | tee /dev/stderr)
+
+  local actual=$(echo $object | yq -r '.resources | index("peeringdialers")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("get")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("list")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+
+  local actual=$(echo $object | yq -r '.verbs | index("watch")' | tee /dev/stderr)
+  [ "${actual}" != null ]
+}
+
 #--------------------------------------------------------------------
 # global.enablePodSecurityPolicies
 
@@ -84,3 +84,127 @@ load _helpers
       yq -c '.rules[0].verbs' | tee /dev/stderr)
   [ "${actual}" = '["get","list","watch","update","patch","delete","create"]' ]
 }
+
+#--------------------------------------------------------------------
+# syncCatalog.consulNamespaces.mirroringConsul
+
+@test "syncCatalog/ClusterRole: no namespaces access without Consul namespace mirroring" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      --set 'global.enableConsulNamespaces=true' \
+      . | tee /dev/stderr |
+      yq -r '[.rules[].resources[]] | any(. == "namespaces")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "syncCatalog/ClusterRole: can get namespaces with Consul namespace mirroring" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      --set 'global.enableConsulNamespaces=true' \
+      --set 'syncCatalog.consulNamespaces.mirroringConsul=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules[2].verbs' | tee /dev/stderr)
+  [ "${actual}" = '["get"]' ]
+}
+
+@test "syncCatalog/ClusterRole: can create namespaces with Consul namespace mirroring and the Create policy" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      --set 'global.enableConsulNamespaces=true' \
+      --set 'syncCatalog.consulNamespaces.mirroringConsul=true' \
+      --set 'syncCatalog.consulNamespaces.mirroringConsulMissingNamespacePolicy=Create' \
+      . | tee /dev/stderr |
+      yq -c '.rules[2].verbs' | tee /dev/stderr)
+  [ "${actual}" = '["get","create"]' ]
+}
+
+#--------------------------------------------------------------------
+# syncCatalog.ingress
+
+@test "syncCatalog/ClusterRole: no ingresses access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '[.rules[].resources[]] | any(. == "ingresses")' | tee /dev/stderr)
+  [ "${actual}" = "false" ]
+}
+
+@test "syncCatalog/ClusterRole: can watch ingresses with syncCatalog.ingress.enabled" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      --set 'syncCatalog.ingress.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules[2]' | tee /dev/stderr)
+  [ "${actual}" = '{"apiGroups":["networking.k8s.io"],"resources":["ingresses"],"verbs":["get","list","watch"]}' ]
+}
+
+#--------------------------------------------------------------------
+# pods
+
+@test "syncCatalog/ClusterRole: can get, list and watch pods for readiness checks" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      . | tee /dev/stderr |
+      yq -c '.rules[1]' | tee /dev/stderr)
+  [ "${actual}" = '{"apiGroups":[""],"resources":["nodes","pods"],"verbs":["get","list","watch"]}' ]
+}
+
+#--------------------------------------------------------------------
+# global.enableEndpointSlices
+
+@test "syncCatalog/ClusterRole: no endpointslices access by default" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "endpointslices")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "syncCatalog/ClusterRole: allows endpointslices access with global.enableEndpointSlices=true" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      --set 'global.enableEndpointSlices=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "endpointslices")) | length' | tee /dev/stderr)
+  [ "${actual}" = "1" ]
+}
+
+#--------------------------------------------------------------------
+# syncCatalog.replicas
+
+@test "syncCatalog/ClusterRole: no leases access with a single replica" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      . | tee /dev/stderr |
+      yq -r '.rules | map(select(.resources[0] == "leases")) | length' | tee /dev/stderr)
+  [ "${actual}" = "0" ]
+}
+
+@test "syncCatalog/ClusterRole: allows leases access with multiple replicas" {
+  cd `chart_dir`
+  local actual=$(helm template \
+      -s templates/sync-catalog-clusterrole.yaml  \
+      --set 'syncCatalog.enabled=true' \
+      --set 'syncCatalog.replicas=2' \
+      . | tee /dev/stderr |
+      yq -c '.rules | map(select(.resources[0] == "leases")) | .[0].verbs' | tee /dev/stderr)
+  [ "${actual}" = '["get","create","update"]' ]
+}
@@ -11,6 +11,10 @@ const (
 	ExportedServices   string = "exportedservices"
 	IngressGateway     string = "ingressgateway"
 	TerminatingGateway string = "terminatinggateway"
+	APIGateway         string = "apigateway"
+	HTTPRoute          string = "httproute"
+	TCPRoute           string = "tcproute"
+	InlineCertificate  string = "inlinecertificate"
 
 	Global                 string = "global"
 	Mesh                   string = "mesh"
@@ -23,4 +27,13 @@ const (
 	MigrateEntryKey  string = "consul.hashicorp.com/migrate-entry"
 	MigrateEntryTrue string = "true"
 	SourceValue      string = "kubernetes"
+
+	// DriftPolicyKey is the annotation that selects what the controller does
+	// when the config entry in Consul is changed or deleted outside of
+	// Kubernetes. With DriftPolicyCorrect, the default, the config entry is
+	// restored from the resource. With DriftPolicyReport, the resource gets a
+	// Drifted condition instead.
+	DriftPolicyKey     string = "consul.hashicorp.com/drift-policy"
+	DriftPolicyCorrect string = "correct"
+	DriftPolicyReport  string = "report"
 )
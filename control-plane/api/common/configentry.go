@@ -46,6 +46,21 @@ type ConfigEntryResource interface {
 	SyncedCondition() (status corev1.ConditionStatus, reason, message string)
 	// SyncedConditionStatus returns the status of the synced condition.
 	SyncedConditionStatus() corev1.ConditionStatus
+	// SetDriftedCondition updates the drifted condition.
+	SetDriftedCondition(status corev1.ConditionStatus, reason, message string)
+	// SetLastSyncedGeneration updates the generation of the resource that
+	// was last synced with Consul.
+	SetLastSyncedGeneration(generation int64)
+	// LastSyncedGeneration returns the generation of the resource that was
+	// last synced with Consul.
+	LastSyncedGeneration() int64
+	// SetConsulStatus updates the datacenter that owns the config entry in
+	// Consul and its modify index.
+	SetConsulStatus(datacenter string, modifyIndex uint64)
+	// SetDiscoveryChainStatus updates the discovery chain compiled by Consul
+	// for the service the config entry applies to, or the error Consul
+	// returned when compiling it.
+	SetDiscoveryChainStatus(chain *api.CompiledDiscoveryChain, compileErr string)
 	// ToConsul converts the resource to the corresponding Consul API definition.
 	// Its return type is the generic ConfigEntry but a specific config entry
 	// type should be constructed e.g. ServiceConfigEntry.
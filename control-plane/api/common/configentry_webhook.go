@@ -9,6 +9,7 @@ import (
 	"github.com/go-logr/logr"
 	"gomodules.xyz/jsonpatch/v2"
 	admissionv1 "k8s.io/api/admission/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
@@ -19,6 +20,18 @@ type ConfigEntryLister interface {
 	List(ctx context.Context) ([]ConfigEntryResource, error)
 }
 
+// ConfigEntryCrossValidator is implemented by CRD-specific webhooks whose
+// resources must be consistent with other resources in the cluster, e.g. a
+// ServiceRouter requires the protocol set by ServiceDefaults to support routing.
+// Consul rejects such config entries when they're written, but validating them
+// in the webhook rejects them before they're applied to Kubernetes.
+type ConfigEntryCrossValidator interface {
+	// CrossValidate returns an Invalid error if cfgEntry conflicts with other
+	// resources in the Kubernetes cluster. Any other error is returned if the
+	// resources can't be read.
+	CrossValidate(ctx context.Context, cfgEntry ConfigEntryResource) error
+}
+
 // ValidateConfigEntry validates cfgEntry. It is a generic method that
 // can be used by all CRD-specific validators.
 // Callers should pass themselves as validator and kind should be the custom
@@ -58,9 +71,20 @@ func ValidateConfigEntry(
 			}
 		}
 	}
+	if policy, ok := cfgEntry.GetAnnotations()[DriftPolicyKey]; ok && policy != DriftPolicyCorrect && policy != DriftPolicyReport {
+		return admission.Errored(http.StatusBadRequest,
+			fmt.Errorf("annotation %q must be %q or %q", DriftPolicyKey, DriftPolicyCorrect, DriftPolicyReport))
+	}
 	if err := cfgEntry.Validate(consulMeta); err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
+	if crossValidator, ok := configEntryLister.(ConfigEntryCrossValidator); ok {
+		if err := crossValidator.CrossValidate(ctx, cfgEntry); k8serr.IsInvalid(err) {
+			return admission.Errored(http.StatusBadRequest, err)
+		} else if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+	}
 	return admission.Patched(fmt.Sprintf("valid %s request", cfgEntry.KubeKind()), defaultingPatches...)
 }
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"net/http"
 	"testing"
 
 	logrtest "github.com/go-logr/logr/testing"
@@ -12,10 +13,12 @@ import (
 	"gomodules.xyz/jsonpatch/v2"
 	admissionv1 "k8s.io/api/admission/v1"
 	corev1 "k8s.io/api/core/v1"
+	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
@@ -92,6 +95,25 @@ func TestValidateConfigEntry(t *testing.T) {
 			nsMirroring:      true,
 			expAllow:         true,
 		},
+		"drift policy report": {
+			newResource: &mockConfigEntry{
+				MockName:        "foo",
+				MockNamespace:   otherNS,
+				MockAnnotations: map[string]string{DriftPolicyKey: DriftPolicyReport},
+				Valid:           true,
+			},
+			expAllow: true,
+		},
+		"invalid drift policy": {
+			newResource: &mockConfigEntry{
+				MockName:        "foo",
+				MockNamespace:   otherNS,
+				MockAnnotations: map[string]string{DriftPolicyKey: "ignore"},
+				Valid:           true,
+			},
+			expAllow:      false,
+			expErrMessage: `annotation "consul.hashicorp.com/drift-policy" must be "correct" or "report"`,
+		},
 	}
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -129,6 +151,62 @@ func TestValidateConfigEntry(t *testing.T) {
 	}
 }
 
+func TestValidateConfigEntry_CrossValidate(t *testing.T) {
+	cases := map[string]struct {
+		crossValidateErr error
+		expAllow         bool
+		expCode          int32
+		expErrMessage    string
+	}{
+		"valid": {
+			expAllow: true,
+		},
+		"invalid": {
+			crossValidateErr: k8serr.NewInvalid(schema.GroupKind{Group: "consul.hashicorp.com", Kind: "mockkind"}, "foo",
+				field.ErrorList{field.Invalid(field.NewPath("metadata").Child("name"), "foo", "conflicts")}),
+			expAllow:      false,
+			expCode:       http.StatusBadRequest,
+			expErrMessage: `mockkind.consul.hashicorp.com "foo" is invalid: metadata.name: Invalid value: "foo": conflicts`,
+		},
+		"list error": {
+			crossValidateErr: errors.New("list failed"),
+			expAllow:         false,
+			expCode:          http.StatusInternalServerError,
+			expErrMessage:    "list failed",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			cfgEntry := &mockConfigEntry{
+				MockName: "foo",
+				Valid:    true,
+			}
+			marshalledRequestObject, err := json.Marshal(cfgEntry)
+			require.NoError(t, err)
+
+			response := ValidateConfigEntry(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Name:      cfgEntry.KubernetesName(),
+					Operation: admissionv1.Create,
+					Object: runtime.RawExtension{
+						Raw: marshalledRequestObject,
+					},
+				},
+			},
+				logrtest.TestLogger{T: t},
+				&mockConfigEntryCrossValidator{Err: c.crossValidateErr},
+				cfgEntry,
+				ConsulMeta{})
+			require.Equal(t, c.expAllow, response.Allowed)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expCode, response.AdmissionResponse.Result.Code)
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
+
 func TestDefaultingPatches(t *testing.T) {
 	cfgEntry := &mockConfigEntry{
 		MockName: "test",
@@ -156,10 +234,20 @@ func (in *mockConfigEntryLister) List(_ context.Context) ([]ConfigEntryResource,
 	return in.Resources, nil
 }
 
+type mockConfigEntryCrossValidator struct {
+	mockConfigEntryLister
+	Err error
+}
+
+func (in *mockConfigEntryCrossValidator) CrossValidate(_ context.Context, _ ConfigEntryResource) error {
+	return in.Err
+}
+
 type mockConfigEntry struct {
-	MockName      string
-	MockNamespace string
-	Valid         bool
+	MockName        string
+	MockNamespace   string
+	MockAnnotations map[string]string
+	Valid           bool
 }
 
 func (in *mockConfigEntry) GetNamespace() string {
@@ -233,7 +321,7 @@ func (in *mockConfigEntry) GetLabels() map[string]string {
 func (in *mockConfigEntry) SetLabels(_ map[string]string) {}
 
 func (in *mockConfigEntry) GetAnnotations() map[string]string {
-	return nil
+	return in.MockAnnotations
 }
 
 func (in *mockConfigEntry) SetAnnotations(_ map[string]string) {}
@@ -310,6 +398,18 @@ func (in *mockConfigEntry) SetSyncedCondition(_ corev1.ConditionStatus, _ string
 
 func (in *mockConfigEntry) SetLastSyncedTime(_ *metav1.Time) {}
 
+func (in *mockConfigEntry) SetDriftedCondition(_ corev1.ConditionStatus, _ string, _ string) {}
+
+func (in *mockConfigEntry) SetLastSyncedGeneration(_ int64) {}
+
+func (in *mockConfigEntry) SetConsulStatus(_ string, _ uint64) {}
+
+func (in *mockConfigEntry) SetDiscoveryChainStatus(_ *capi.CompiledDiscoveryChain, _ string) {}
+
+func (in *mockConfigEntry) LastSyncedGeneration() int64 {
+	return 0
+}
+
 func (in *mockConfigEntry) SyncedCondition() (status corev1.ConditionStatus, reason string, message string) {
 	return corev1.ConditionTrue, "", ""
 }
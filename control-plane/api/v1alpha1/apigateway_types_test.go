@@ -0,0 +1,532 @@
+package v1alpha1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestAPIGateway_MatchesConsul(t *testing.T) {
+	cases := map[string]struct {
+		Ours    APIGateway
+		Theirs  capi.ConfigEntry
+		Matches bool
+	}{
+		"empty fields matches": {
+			Ours: APIGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.APIGatewayConfigEntry{
+				Kind:        capi.APIGateway,
+				Name:        "name",
+				Namespace:   "namespace",
+				CreateIndex: 1,
+				ModifyIndex: 2,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Matches: true,
+		},
+		"all fields set matches": {
+			Ours: APIGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: APIGatewaySpec{
+					Listeners: []APIGatewayListener{
+						{
+							Name:     "https",
+							Hostname: "*.example.com",
+							Port:     8443,
+							Protocol: "http",
+							TLS: APIGatewayTLSConfiguration{
+								Certificates: []ResourceReference{{Name: "cert"}},
+								MinVersion:   "TLSv1_2",
+								MaxVersion:   "TLSv1_3",
+								CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+							},
+						},
+					},
+				},
+			},
+			Theirs: &capi.APIGatewayConfigEntry{
+				Kind: capi.APIGateway,
+				Name: "name",
+				Listeners: []capi.APIGatewayListener{
+					{
+						Name:     "https",
+						Hostname: "*.example.com",
+						Port:     8443,
+						Protocol: "http",
+						TLS: capi.APIGatewayTLSConfiguration{
+							Certificates: []capi.ResourceReference{{Kind: capi.InlineCertificate, Name: "cert"}},
+							MinVersion:   "TLSv1_2",
+							MaxVersion:   "TLSv1_3",
+							CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+						},
+					},
+				},
+				Status: capi.ConfigEntryStatus{
+					Conditions: []capi.Condition{{Type: "Accepted", Status: "True"}},
+				},
+			},
+			Matches: true,
+		},
+		"mismatched types does not match": {
+			Ours: APIGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.TerminatingGatewayConfigEntry{
+				Kind: capi.TerminatingGateway,
+				Name: "name",
+			},
+			Matches: false,
+		},
+		"different listeners does not match": {
+			Ours: APIGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: APIGatewaySpec{
+					Listeners: []APIGatewayListener{{Name: "http", Port: 80, Protocol: "http"}},
+				},
+			},
+			Theirs: &capi.APIGatewayConfigEntry{
+				Kind:      capi.APIGateway,
+				Name:      "name",
+				Listeners: []capi.APIGatewayListener{{Name: "http", Port: 8080, Protocol: "http"}},
+			},
+			Matches: false,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.Matches, c.Ours.MatchesConsul(c.Theirs))
+		})
+	}
+}
+
+func TestAPIGateway_ToConsul(t *testing.T) {
+	cases := map[string]struct {
+		Ours APIGateway
+		Exp  *capi.APIGatewayConfigEntry
+	}{
+		"empty fields": {
+			Ours: APIGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Exp: &capi.APIGatewayConfigEntry{
+				Kind: capi.APIGateway,
+				Name: "name",
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
+		"every field set": {
+			Ours: APIGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: APIGatewaySpec{
+					Listeners: []APIGatewayListener{
+						{
+							Name:     "https",
+							Hostname: "*.example.com",
+							Port:     8443,
+							Protocol: "http",
+							TLS: APIGatewayTLSConfiguration{
+								Certificates: []ResourceReference{
+									{
+										Name:      "cert",
+										Partition: "partition",
+										Namespace: "namespace",
+									},
+								},
+								MinVersion:   "TLSv1_2",
+								MaxVersion:   "TLSv1_3",
+								CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+							},
+						},
+						{
+							Name:     "tcp",
+							Port:     5432,
+							Protocol: "tcp",
+						},
+					},
+				},
+			},
+			Exp: &capi.APIGatewayConfigEntry{
+				Kind: capi.APIGateway,
+				Name: "name",
+				Listeners: []capi.APIGatewayListener{
+					{
+						Name:     "https",
+						Hostname: "*.example.com",
+						Port:     8443,
+						Protocol: "http",
+						TLS: capi.APIGatewayTLSConfiguration{
+							Certificates: []capi.ResourceReference{
+								{
+									Kind:      capi.InlineCertificate,
+									Name:      "cert",
+									Partition: "partition",
+									Namespace: "namespace",
+								},
+							},
+							MinVersion:   "TLSv1_2",
+							MaxVersion:   "TLSv1_3",
+							CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+						},
+					},
+					{
+						Name:     "tcp",
+						Port:     5432,
+						Protocol: "tcp",
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			act := c.Ours.ToConsul("datacenter")
+			resource, ok := act.(*capi.APIGatewayConfigEntry)
+			require.True(t, ok, "could not cast")
+			require.Equal(t, c.Exp, resource)
+		})
+	}
+}
+
+func TestAPIGatewayFromConsul(t *testing.T) {
+	cases := map[string]struct {
+		Consul *capi.APIGatewayConfigEntry
+		Exp    APIGateway
+	}{
+		"empty fields": {
+			Consul: &capi.APIGatewayConfigEntry{
+				Kind: capi.APIGateway,
+				Name: "name",
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: APIGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+		},
+		"every field set": {
+			Consul: &capi.APIGatewayConfigEntry{
+				Kind: capi.APIGateway,
+				Name: "name",
+				Listeners: []capi.APIGatewayListener{
+					{
+						Name:     "https",
+						Hostname: "*.example.com",
+						Port:     8443,
+						Protocol: "http",
+						TLS: capi.APIGatewayTLSConfiguration{
+							Certificates: []capi.ResourceReference{
+								{
+									Kind:      capi.InlineCertificate,
+									Name:      "cert",
+									Partition: "partition",
+									Namespace: "namespace",
+								},
+							},
+							MinVersion:   "TLSv1_2",
+							MaxVersion:   "TLSv1_3",
+							CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+						},
+					},
+					{
+						Name:     "tcp",
+						Port:     5432,
+						Protocol: "tcp",
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: APIGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: APIGatewaySpec{
+					Listeners: []APIGatewayListener{
+						{
+							Name:     "https",
+							Hostname: "*.example.com",
+							Port:     8443,
+							Protocol: "http",
+							TLS: APIGatewayTLSConfiguration{
+								Certificates: []ResourceReference{
+									{
+										Kind:      capi.InlineCertificate,
+										Name:      "cert",
+										Partition: "partition",
+										Namespace: "namespace",
+									},
+								},
+								MinVersion:   "TLSv1_2",
+								MaxVersion:   "TLSv1_3",
+								CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+							},
+						},
+						{
+							Name:     "tcp",
+							Port:     5432,
+							Protocol: "tcp",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, &c.Exp, APIGatewayFromConsul(c.Consul))
+		})
+	}
+}
+
+func TestAPIGateway_Validate(t *testing.T) {
+	cases := map[string]struct {
+		input           *APIGateway
+		consulMeta      common.ConsulMeta
+		expectedErrMsgs []string
+	}{
+		"valid": {
+			input: &APIGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: APIGatewaySpec{
+					Listeners: []APIGatewayListener{
+						{
+							Name:     "https",
+							Port:     8443,
+							Protocol: "http",
+							TLS: APIGatewayTLSConfiguration{
+								Certificates: []ResourceReference{{Kind: capi.InlineCertificate, Name: "cert"}},
+								MinVersion:   "TLSv1_2",
+							},
+						},
+						{
+							Name:     "tcp",
+							Port:     5432,
+							Protocol: "tcp",
+						},
+					},
+				},
+			},
+		},
+		"invalid listeners": {
+			input: &APIGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: APIGatewaySpec{
+					Listeners: []APIGatewayListener{
+						{
+							Name:     "http",
+							Port:     80,
+							Protocol: "http",
+						},
+						{
+							Name:     "http",
+							Port:     70000,
+							Protocol: "grpc",
+						},
+						{
+							Port:     8080,
+							Protocol: "http",
+						},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.listeners[1].name: Duplicate value: "http"`,
+				`spec.listeners[1].port: Invalid value: 70000: must be between 1 and 65535`,
+				`spec.listeners[1].protocol: Invalid value: "grpc": must be one of "http", "tcp"`,
+				`spec.listeners[2].name: Required value: name is required`,
+			},
+		},
+		"invalid tls": {
+			input: &APIGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: APIGatewaySpec{
+					Listeners: []APIGatewayListener{
+						{
+							Name:     "https",
+							Port:     8443,
+							Protocol: "http",
+							TLS: APIGatewayTLSConfiguration{
+								Certificates: []ResourceReference{
+									{Kind: capi.APIGateway, Name: "cert"},
+									{Namespace: "ns", Partition: "part"},
+								},
+								MinVersion:   "TLSv1_3",
+								MaxVersion:   "TLSv2",
+								CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+							},
+						},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.listeners[0].tls.maxVersion: Invalid value: "TLSv2": must be one of "TLS_AUTO", "TLSv1_0", "TLSv1_1", "TLSv1_2", "TLSv1_3", ""`,
+				`spec.listeners[0].tls.cipherSuites: Invalid value: "[TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256]": cipher suites can only be set if minVersion is less than TLSv1_3`,
+				`spec.listeners[0].tls.certificates[0].kind: Invalid value: "api-gateway": must be "inline-certificate"`,
+				`spec.listeners[0].tls.certificates[1].name: Required value: name is required`,
+				`spec.listeners[0].tls.certificates[1].partition: Invalid value: "part": Consul Enterprise admin-partitions must be enabled to set partition`,
+				`spec.listeners[0].tls.certificates[1].namespace: Invalid value: "ns": Consul Enterprise namespaces must be enabled to set namespace`,
+			},
+		},
+		"certificate namespace and partition set when enabled": {
+			input: &APIGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: APIGatewaySpec{
+					Listeners: []APIGatewayListener{
+						{
+							Name:     "https",
+							Port:     8443,
+							Protocol: "http",
+							TLS: APIGatewayTLSConfiguration{
+								Certificates: []ResourceReference{{Name: "cert", Namespace: "ns", Partition: "part"}},
+							},
+						},
+					},
+				},
+			},
+			consulMeta: common.ConsulMeta{NamespacesEnabled: true, PartitionsEnabled: true},
+		},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := testCase.input.Validate(testCase.consulMeta)
+			if len(testCase.expectedErrMsgs) != 0 {
+				require.Error(t, err)
+				for _, s := range testCase.expectedErrMsgs {
+					require.Contains(t, err.Error(), s)
+				}
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestAPIGateway_DefaultNamespaceFields(t *testing.T) {
+	cases := map[string]struct {
+		consulMeta          common.ConsulMeta
+		expectedDestination string
+	}{
+		"disabled": {
+			consulMeta:          common.ConsulMeta{},
+			expectedDestination: "",
+		},
+		"destinationNS": {
+			consulMeta: common.ConsulMeta{
+				NamespacesEnabled:    true,
+				DestinationNamespace: "foo",
+			},
+			expectedDestination: "foo",
+		},
+		"mirroringWithPrefix": {
+			consulMeta: common.ConsulMeta{
+				NamespacesEnabled: true,
+				Mirroring:         true,
+				Prefix:            "ns-",
+			},
+			expectedDestination: "ns-bar",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			input := &APIGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "bar",
+				},
+				Spec: APIGatewaySpec{
+					Listeners: []APIGatewayListener{
+						{
+							TLS: APIGatewayTLSConfiguration{
+								Certificates: []ResourceReference{
+									{Name: "cert"},
+									{Name: "other", Namespace: "other"},
+								},
+							},
+						},
+					},
+				},
+			}
+			input.DefaultNamespaceFields(c.consulMeta)
+			certs := input.Spec.Listeners[0].TLS.Certificates
+			require.Equal(t, c.expectedDestination, certs[0].Namespace)
+			require.Equal(t, "other", certs[1].Namespace)
+		})
+	}
+}
+
+func TestAPIGateway_SetSyncedCondition(t *testing.T) {
+	gateway := &APIGateway{}
+	gateway.SetSyncedCondition(corev1.ConditionTrue, "reason", "message")
+
+	require.Equal(t, corev1.ConditionTrue, gateway.Status.Conditions[0].Status)
+	require.Equal(t, "reason", gateway.Status.Conditions[0].Reason)
+	require.Equal(t, "message", gateway.Status.Conditions[0].Message)
+	now := metav1.Now()
+	require.True(t, gateway.Status.Conditions[0].LastTransitionTime.Before(&now))
+}
+
+func TestAPIGateway_SetLastSyncedTime(t *testing.T) {
+	gateway := &APIGateway{}
+	syncedTime := metav1.NewTime(time.Now())
+	gateway.SetLastSyncedTime(&syncedTime)
+
+	require.Equal(t, &syncedTime, gateway.Status.LastSyncedTime)
+}
+
+func TestAPIGateway_Kinds(t *testing.T) {
+	gateway := &APIGateway{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "bar"}}
+	require.Equal(t, capi.APIGateway, gateway.ConsulKind())
+	require.Equal(t, common.APIGateway, gateway.KubeKind())
+	require.Equal(t, "foo", gateway.ConsulName())
+	require.Equal(t, "foo", gateway.KubernetesName())
+	require.Equal(t, "bar", gateway.ConsulMirroringNS())
+	require.False(t, gateway.ConsulGlobalResource())
+}
@@ -0,0 +1,89 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// httpProtocols are the protocols that support L7 features, i.e. routing,
+// splitting and intentions with permissions.
+var httpProtocols = []string{"http", "http2", "grpc"}
+
+// serviceProtocol returns the protocol of the service in the Consul namespace
+// as set by the ServiceDefaults and ProxyDefaults resources in the cluster.
+// It returns an empty string if neither sets the protocol since it may have
+// been set in Consul directly.
+func serviceProtocol(ctx context.Context, c client.Client, consulMeta common.ConsulMeta, name, consulNS string) (string, error) {
+	var svcDefaultsList ServiceDefaultsList
+	if err := c.List(ctx, &svcDefaultsList); err != nil {
+		return "", err
+	}
+	for _, item := range svcDefaultsList.Items {
+		if item.ConsulName() == name && consulNamespace(item.ConsulMirroringNS(), consulMeta) == consulNS && item.Spec.Protocol != "" {
+			return strings.ToLower(item.Spec.Protocol), nil
+		}
+	}
+
+	var proxyDefaultsList ProxyDefaultsList
+	if err := c.List(ctx, &proxyDefaultsList); err != nil {
+		return "", err
+	}
+	for _, item := range proxyDefaultsList.Items {
+		if item.ConsulName() != common.Global || len(item.Spec.Config) == 0 {
+			continue
+		}
+		var config struct {
+			Protocol string `json:"protocol"`
+		}
+		// The config is validated by the ProxyDefaults webhook so an
+		// unparseable config is ignored here.
+		if err := json.Unmarshal(item.Spec.Config, &config); err == nil {
+			return strings.ToLower(config.Protocol), nil
+		}
+	}
+	return "", nil
+}
+
+// consulNamespace returns the Consul namespace the config entries in the
+// Kubernetes namespace are written to.
+func consulNamespace(kubeNS string, consulMeta common.ConsulMeta) string {
+	return namespaces.ConsulNamespace(kubeNS, consulMeta.NamespacesEnabled, consulMeta.DestinationNamespace, consulMeta.Mirroring, consulMeta.Prefix)
+}
+
+// validateRoutingProtocol returns field errors if the protocol of the service
+// configured by the service-router or service-splitter doesn't support
+// routing or splitting.
+func validateRoutingProtocol(ctx context.Context, c client.Client, consulMeta common.ConsulMeta, cfgEntry common.ConfigEntryResource) (field.ErrorList, error) {
+	protocol, err := serviceProtocol(ctx, c, consulMeta, cfgEntry.ConsulName(), consulNamespace(cfgEntry.ConsulMirroringNS(), consulMeta))
+	if err != nil {
+		return nil, err
+	}
+	if protocol == "" || sliceContains(httpProtocols, protocol) {
+		return nil, nil
+	}
+	return field.ErrorList{
+		field.Invalid(field.NewPath("metadata").Child("name"), cfgEntry.KubernetesName(),
+			fmt.Sprintf("protocol %q of the service set by ServiceDefaults or ProxyDefaults does not support %s: protocol %s",
+				protocol, cfgEntry.ConsulKind(), notInSliceMessage(httpProtocols))),
+	}, nil
+}
+
+// crossValidationError returns an Invalid error for the resource if there
+// are any errors.
+func crossValidationError(cfgEntry common.ConfigEntryResource, errs field.ErrorList) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: ConsulHashicorpGroup, Kind: cfgEntry.KubeKind()},
+		cfgEntry.KubernetesName(), errs)
+}
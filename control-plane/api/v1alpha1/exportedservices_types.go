@@ -134,6 +134,24 @@ func (in *ExportedServices) SetLastSyncedTime(time *metav1.Time) {
 	in.Status.LastSyncedTime = time
 }
 
+func (in *ExportedServices) SetDriftedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.setCondition(Condition{
+		Type:               ConditionDrifted,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func (in *ExportedServices) SetLastSyncedGeneration(generation int64) {
+	in.Status.LastSyncedGeneration = generation
+}
+
+func (in *ExportedServices) LastSyncedGeneration() int64 {
+	return in.Status.LastSyncedGeneration
+}
+
 func (in *ExportedServices) SyncedCondition() (status corev1.ConditionStatus, reason, message string) {
 	cond := in.Status.GetCondition(ConditionSynced)
 	if cond == nil {
@@ -167,7 +185,7 @@ func (in *ExportedService) toConsul() capi.ExportedService {
 	for _, consumer := range in.Consumers {
 		consumers = append(consumers, capi.ServiceConsumer{
 			Partition: consumer.Partition,
-			PeerName:  consumer.Peer,
+			Peer:      consumer.Peer,
 		})
 	}
 	return capi.ExportedService{
@@ -177,6 +195,38 @@ func (in *ExportedService) toConsul() capi.ExportedService {
 	}
 }
 
+// ExportedServicesFromConsul converts the Consul config entry into an
+// ExportedServices resource. It's the reverse of ToConsul.
+func ExportedServicesFromConsul(entry *capi.ExportedServicesConfigEntry) *ExportedServices {
+	var services []ExportedService
+	for _, service := range entry.Services {
+		services = append(services, exportedServiceFromConsul(service))
+	}
+	return &ExportedServices{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: entry.Name,
+		},
+		Spec: ExportedServicesSpec{
+			Services: services,
+		},
+	}
+}
+
+func exportedServiceFromConsul(in capi.ExportedService) ExportedService {
+	var consumers []ServiceConsumer
+	for _, consumer := range in.Consumers {
+		consumers = append(consumers, ServiceConsumer{
+			Partition: consumer.Partition,
+			Peer:      consumer.Peer,
+		})
+	}
+	return ExportedService{
+		Name:      in.Name,
+		Namespace: in.Namespace,
+		Consumers: consumers,
+	}
+}
+
 func (in *ExportedServices) MatchesConsul(candidate api.ConfigEntry) bool {
 	configEntry, ok := candidate.(*capi.ExportedServicesConfigEntry)
 	if !ok {
@@ -90,7 +90,7 @@ func TestExportedServices_MatchesConsul(t *testing.T) {
 								Partition: "third",
 							},
 							{
-								PeerName: "second-peer",
+								Peer: "second-peer",
 							},
 						},
 					},
@@ -105,7 +105,7 @@ func TestExportedServices_MatchesConsul(t *testing.T) {
 								Partition: "fifth",
 							},
 							{
-								PeerName: "third-peer",
+								Peer: "third-peer",
 							},
 						},
 					},
@@ -214,7 +214,7 @@ func TestExportedServices_ToConsul(t *testing.T) {
 								Partition: "third",
 							},
 							{
-								PeerName: "second-peer",
+								Peer: "second-peer",
 							},
 						},
 					},
@@ -229,7 +229,7 @@ func TestExportedServices_ToConsul(t *testing.T) {
 								Partition: "fifth",
 							},
 							{
-								PeerName: "third-peer",
+								Peer: "third-peer",
 							},
 						},
 					},
@@ -251,6 +251,115 @@ func TestExportedServices_ToConsul(t *testing.T) {
 	}
 }
 
+func TestExportedServicesFromConsul(t *testing.T) {
+	cases := map[string]struct {
+		Consul *capi.ExportedServicesConfigEntry
+		Exp    ExportedServices
+	}{
+		"empty fields": {
+			Consul: &capi.ExportedServicesConfigEntry{
+				Name: common.DefaultConsulPartition,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: ExportedServices{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.DefaultConsulPartition,
+				},
+				Spec: ExportedServicesSpec{},
+			},
+		},
+		"every field set": {
+			Consul: &capi.ExportedServicesConfigEntry{
+				Name: common.DefaultConsulPartition,
+				Services: []capi.ExportedService{
+					{
+						Name:      "service-frontend",
+						Namespace: "frontend",
+						Consumers: []capi.ServiceConsumer{
+							{
+								Partition: "second",
+							},
+							{
+								Partition: "third",
+							},
+							{
+								Peer: "second-peer",
+							},
+						},
+					},
+					{
+						Name:      "service-backend",
+						Namespace: "backend",
+						Consumers: []capi.ServiceConsumer{
+							{
+								Partition: "fourth",
+							},
+							{
+								Partition: "fifth",
+							},
+							{
+								Peer: "third-peer",
+							},
+						},
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: ExportedServices{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: common.DefaultConsulPartition,
+				},
+				Spec: ExportedServicesSpec{
+					Services: []ExportedService{
+						{
+							Name:      "service-frontend",
+							Namespace: "frontend",
+							Consumers: []ServiceConsumer{
+								{
+									Partition: "second",
+								},
+								{
+									Partition: "third",
+								},
+								{
+									Peer: "second-peer",
+								},
+							},
+						},
+						{
+							Name:      "service-backend",
+							Namespace: "backend",
+							Consumers: []ServiceConsumer{
+								{
+									Partition: "fourth",
+								},
+								{
+									Partition: "fifth",
+								},
+								{
+									Peer: "third-peer",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, &c.Exp, ExportedServicesFromConsul(c.Consul))
+		})
+	}
+}
+
 func TestExportedServices_Validate(t *testing.T) {
 	cases := map[string]struct {
 		input             *ExportedServices
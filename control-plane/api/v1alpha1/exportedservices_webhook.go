@@ -9,6 +9,8 @@ import (
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	capi "github.com/hashicorp/consul/api"
 	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
@@ -57,10 +59,65 @@ func (v *ExportedServicesWebhook) Handle(ctx context.Context, req admission.Requ
 	if err := exports.Validate(v.ConsulMeta); err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
+	if err := v.CrossValidate(ctx, &exports); apierrors.IsInvalid(err) {
+		return admission.Errored(http.StatusBadRequest, err)
+	} else if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
 
 	return admission.Allowed(fmt.Sprintf("valid %s request", exports.KubeKind()))
 }
 
+// CrossValidate returns an error if services are exported to peers that no
+// PeeringAcceptor or PeeringDialer exists for.
+func (v *ExportedServicesWebhook) CrossValidate(ctx context.Context, cfgEntry common.ConfigEntryResource) error {
+	exports, ok := cfgEntry.(*ExportedServices)
+	if !ok {
+		return nil
+	}
+	var errs field.ErrorList
+	var peers map[string]bool
+	for i, service := range exports.Spec.Services {
+		for j, consumer := range service.Consumers {
+			if consumer.Peer == "" {
+				continue
+			}
+			if peers == nil {
+				var err error
+				if peers, err = v.peerNames(ctx); err != nil {
+					return err
+				}
+			}
+			if !peers[consumer.Peer] {
+				errs = append(errs, field.Invalid(field.NewPath("spec").Child("services").Index(i).Child("consumers").Index(j).Child("peer"), consumer.Peer,
+					"no PeeringAcceptor or PeeringDialer exists for the peer"))
+			}
+		}
+	}
+	return crossValidationError(exports, errs)
+}
+
+// peerNames returns the names of the peers that a PeeringAcceptor or a
+// PeeringDialer exists for.
+func (v *ExportedServicesWebhook) peerNames(ctx context.Context) (map[string]bool, error) {
+	peers := make(map[string]bool)
+	var acceptors PeeringAcceptorList
+	if err := v.Client.List(ctx, &acceptors); err != nil {
+		return nil, err
+	}
+	for _, acceptor := range acceptors.Items {
+		peers[acceptor.Name] = true
+	}
+	var dialers PeeringDialerList
+	if err := v.Client.List(ctx, &dialers); err != nil {
+		return nil, err
+	}
+	for _, dialer := range dialers.Items {
+		peers[dialer.Name] = true
+	}
+	return peers, nil
+}
+
 func (v *ExportedServicesWebhook) InjectDecoder(d *admission.Decoder) error {
 	v.decoder = d
 	return nil
@@ -163,6 +163,76 @@ func TestValidateExportedServices(t *testing.T) {
 			expAllow:      false,
 			expErrMessage: "exportedservices.consul.hashicorp.com \"other\" is invalid: spec.services[0]: Invalid value: []v1alpha1.ServiceConsumer(nil): service must have at least 1 consumer.",
 		},
+		"peer with peering acceptor": {
+			existingResources: []runtime.Object{&PeeringAcceptor{ObjectMeta: metav1.ObjectMeta{Name: "peer1", Namespace: "default"}}},
+			newResource: &ExportedServices{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: otherPartition,
+				},
+				Spec: ExportedServicesSpec{
+					Services: []ExportedService{
+						{
+							Name:      "service",
+							Namespace: "service-ns",
+							Consumers: []ServiceConsumer{{Peer: "peer1"}},
+						},
+					},
+				},
+			},
+			consulMeta: common.ConsulMeta{
+				PartitionsEnabled: true,
+				NamespacesEnabled: true,
+				Partition:         otherPartition,
+			},
+			expAllow: true,
+		},
+		"peer with peering dialer": {
+			existingResources: []runtime.Object{&PeeringDialer{ObjectMeta: metav1.ObjectMeta{Name: "peer1", Namespace: otherNS}}},
+			newResource: &ExportedServices{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: otherPartition,
+				},
+				Spec: ExportedServicesSpec{
+					Services: []ExportedService{
+						{
+							Name:      "service",
+							Namespace: "service-ns",
+							Consumers: []ServiceConsumer{{Peer: "peer1"}},
+						},
+					},
+				},
+			},
+			consulMeta: common.ConsulMeta{
+				PartitionsEnabled: true,
+				NamespacesEnabled: true,
+				Partition:         otherPartition,
+			},
+			expAllow: true,
+		},
+		"peer without peering acceptor or dialer": {
+			existingResources: []runtime.Object{&PeeringAcceptor{ObjectMeta: metav1.ObjectMeta{Name: "peer2", Namespace: "default"}}},
+			newResource: &ExportedServices{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: otherPartition,
+				},
+				Spec: ExportedServicesSpec{
+					Services: []ExportedService{
+						{
+							Name:      "service",
+							Namespace: "service-ns",
+							Consumers: []ServiceConsumer{{Peer: "peer1"}},
+						},
+					},
+				},
+			},
+			consulMeta: common.ConsulMeta{
+				PartitionsEnabled: true,
+				NamespacesEnabled: true,
+				Partition:         otherPartition,
+			},
+			expAllow:      false,
+			expErrMessage: "exportedservices.consul.hashicorp.com \"other\" is invalid: spec.services[0].consumers[0].peer: Invalid value: \"peer1\": no PeeringAcceptor or PeeringDialer exists for the peer",
+		},
 	}
 	for name, c := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -170,7 +240,7 @@ func TestValidateExportedServices(t *testing.T) {
 			marshalledRequestObject, err := json.Marshal(c.newResource)
 			require.NoError(t, err)
 			s := runtime.NewScheme()
-			s.AddKnownTypes(GroupVersion, &ExportedServices{}, &ExportedServicesList{})
+			s.AddKnownTypes(GroupVersion, &ExportedServices{}, &ExportedServicesList{}, &PeeringAcceptor{}, &PeeringAcceptorList{}, &PeeringDialer{}, &PeeringDialerList{})
 			client := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(c.existingResources...).Build()
 			decoder, err := admission.NewDecoder(s)
 			require.NoError(t, err)
@@ -0,0 +1,589 @@
+package v1alpha1
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	capi "github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	httpRouteKubeKind = "httproute"
+)
+
+func init() {
+	SchemeBuilder.Register(&HTTPRoute{}, &HTTPRouteList{})
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// HTTPRoute is the Schema for the httproutes API. It requires Consul 1.15 or newer.
+// +kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].status",description="The sync status of the resource with Consul"
+// +kubebuilder:printcolumn:name="Last Synced",type="date",JSONPath=".status.lastSyncedTime",description="The last successful synced time of the resource with Consul"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The age of the resource"
+// +kubebuilder:resource:shortName="http-route"
+type HTTPRoute struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HTTPRouteSpec `json:"spec,omitempty"`
+	Status `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HTTPRouteList contains a list of HTTPRoute.
+type HTTPRouteList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HTTPRoute `json:"items"`
+}
+
+// HTTPRouteSpec defines the desired state of HTTPRoute.
+type HTTPRouteSpec struct {
+	// Parents are the API gateways the route is bound to. The sectionName of
+	// a parent is the name of the listener to bind to. If it's empty, the
+	// route is bound to all http listeners of the gateway.
+	Parents []ResourceReference `json:"parents,omitempty"`
+	// Rules are the rules that route requests to services. The first rule
+	// that matches a request is used.
+	Rules []HTTPRouteRule `json:"rules,omitempty"`
+	// Hostnames are the host names the route matches requests for. If empty,
+	// the route matches the host names of the listeners it's bound to.
+	Hostnames []string `json:"hostnames,omitempty"`
+}
+
+// HTTPRouteRule routes the requests matching any of its matches to its services.
+type HTTPRouteRule struct {
+	// Filters modify the requests matching the rule before they are routed.
+	Filters HTTPFilters `json:"filters,omitempty"`
+	// Matches are the conditions of the rule. A request matches the rule if
+	// it matches any of them. If empty, the rule matches requests with any path.
+	Matches []HTTPMatch `json:"matches,omitempty"`
+	// Services are the services the requests are routed to. Requests are
+	// split between the services by their weight.
+	Services []HTTPService `json:"services,omitempty"`
+}
+
+// HTTPMatch matches requests by their method, path, headers and query
+// parameters. A request matches if it matches all of them.
+type HTTPMatch struct {
+	// Headers are the headers a request must have to match.
+	Headers []HTTPHeaderMatch `json:"headers,omitempty"`
+	// Method is the HTTP method a request must have to match. If empty,
+	// requests with any method match.
+	// One of `CONNECT`, `DELETE`, `GET`, `HEAD`, `OPTIONS`, `PATCH`, `POST`, `PUT` or `TRACE`.
+	Method string `json:"method,omitempty"`
+	// Path is the path a request must have to match. If match is empty, it
+	// defaults to matching all paths with the prefix "/".
+	Path HTTPPathMatch `json:"path,omitempty"`
+	// Query are the query parameters a request must have to match.
+	Query []HTTPQueryMatch `json:"query,omitempty"`
+}
+
+// HTTPHeaderMatch matches a request header.
+type HTTPHeaderMatch struct {
+	// Match is how the header value is matched.
+	// One of `exact`, `prefix`, `present`, `regex` or `suffix`.
+	Match string `json:"match,omitempty"`
+	// Name is the name of the header.
+	Name string `json:"name,omitempty"`
+	// Value is the value to match the header value with. It is ignored if
+	// match is `present`.
+	Value string `json:"value,omitempty"`
+}
+
+// HTTPPathMatch matches the path of a request.
+type HTTPPathMatch struct {
+	// Match is how the path is matched. One of `exact`, `prefix` or `regex`.
+	Match string `json:"match,omitempty"`
+	// Value is the value to match the path with.
+	Value string `json:"value,omitempty"`
+}
+
+// HTTPQueryMatch matches a query parameter of a request.
+type HTTPQueryMatch struct {
+	// Match is how the query parameter value is matched.
+	// One of `exact`, `present` or `regex`.
+	Match string `json:"match,omitempty"`
+	// Name is the name of the query parameter.
+	Name string `json:"name,omitempty"`
+	// Value is the value to match the query parameter value with. It is
+	// ignored if match is `present`.
+	Value string `json:"value,omitempty"`
+}
+
+// HTTPFilters modify requests before they are routed to a service.
+type HTTPFilters struct {
+	// Headers modify the request headers.
+	Headers []HTTPHeaderFilter `json:"headers,omitempty"`
+	// URLRewrite rewrites the URL of the request.
+	URLRewrite *URLRewrite `json:"urlRewrite,omitempty"`
+}
+
+// HTTPHeaderFilter adds, sets and removes request headers.
+type HTTPHeaderFilter struct {
+	// Add is a map of headers to add to the request. Existing values of the
+	// headers are kept.
+	Add map[string]string `json:"add,omitempty"`
+	// Remove is the list of headers to remove from the request.
+	Remove []string `json:"remove,omitempty"`
+	// Set is a map of headers to set on the request. Existing values of the
+	// headers are replaced.
+	Set map[string]string `json:"set,omitempty"`
+}
+
+// URLRewrite rewrites the URL of a request.
+type URLRewrite struct {
+	// Path replaces the prefix of the request path that was matched.
+	Path string `json:"path,omitempty"`
+}
+
+// HTTPService is a service requests are routed to.
+type HTTPService struct {
+	// Name is the name of the service.
+	Name string `json:"name,omitempty"`
+	// Weight is the proportion of the requests routed to the service
+	// relative to the other services of the rule. Defaults to 1.
+	Weight int `json:"weight,omitempty"`
+	// Filters modify the requests routed to the service, in addition to the
+	// filters of the rule.
+	Filters HTTPFilters `json:"filters,omitempty"`
+	// Partition is the admin partition of the service.
+	Partition string `json:"partition,omitempty"`
+	// Namespace is the namespace of the service.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+func (in *HTTPRoute) GetObjectMeta() metav1.ObjectMeta {
+	return in.ObjectMeta
+}
+
+func (in *HTTPRoute) AddFinalizer(name string) {
+	in.ObjectMeta.Finalizers = append(in.Finalizers(), name)
+}
+
+func (in *HTTPRoute) RemoveFinalizer(name string) {
+	var newFinalizers []string
+	for _, oldF := range in.Finalizers() {
+		if oldF != name {
+			newFinalizers = append(newFinalizers, oldF)
+		}
+	}
+	in.ObjectMeta.Finalizers = newFinalizers
+}
+
+func (in *HTTPRoute) Finalizers() []string {
+	return in.ObjectMeta.Finalizers
+}
+
+func (in *HTTPRoute) ConsulKind() string {
+	return capi.HTTPRoute
+}
+
+func (in *HTTPRoute) ConsulGlobalResource() bool {
+	return false
+}
+
+func (in *HTTPRoute) ConsulMirroringNS() string {
+	return in.Namespace
+}
+
+func (in *HTTPRoute) KubeKind() string {
+	return httpRouteKubeKind
+}
+
+func (in *HTTPRoute) ConsulName() string {
+	return in.ObjectMeta.Name
+}
+
+func (in *HTTPRoute) KubernetesName() string {
+	return in.ObjectMeta.Name
+}
+
+func (in *HTTPRoute) SetSyncedCondition(status corev1.ConditionStatus, reason, message string) {
+	in.Status.Conditions = Conditions{
+		{
+			Type:               ConditionSynced,
+			Status:             status,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		},
+	}
+}
+
+func (in *HTTPRoute) SetLastSyncedTime(time *metav1.Time) {
+	in.Status.LastSyncedTime = time
+}
+
+func (in *HTTPRoute) SetDriftedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.setCondition(Condition{
+		Type:               ConditionDrifted,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func (in *HTTPRoute) SetLastSyncedGeneration(generation int64) {
+	in.Status.LastSyncedGeneration = generation
+}
+
+func (in *HTTPRoute) LastSyncedGeneration() int64 {
+	return in.Status.LastSyncedGeneration
+}
+
+func (in *HTTPRoute) SyncedCondition() (status corev1.ConditionStatus, reason, message string) {
+	cond := in.Status.GetCondition(ConditionSynced)
+	if cond == nil {
+		return corev1.ConditionUnknown, "", ""
+	}
+	return cond.Status, cond.Reason, cond.Message
+}
+
+func (in *HTTPRoute) SyncedConditionStatus() corev1.ConditionStatus {
+	condition := in.Status.GetCondition(ConditionSynced)
+	if condition == nil {
+		return corev1.ConditionUnknown
+	}
+	return condition.Status
+}
+
+// ToConsul converts the resource into an http-route config entry. The
+// defaults that Consul sets when the config entry is written are set here
+// as well so that the config entry read back from Consul matches.
+func (in *HTTPRoute) ToConsul(datacenter string) capi.ConfigEntry {
+	var parents []capi.ResourceReference
+	for _, p := range in.Spec.Parents {
+		parents = append(parents, p.toConsul(capi.APIGateway))
+	}
+	var rules []capi.HTTPRouteRule
+	for _, r := range in.Spec.Rules {
+		rules = append(rules, r.toConsul())
+	}
+	return &capi.HTTPRouteConfigEntry{
+		Kind:      in.ConsulKind(),
+		Name:      in.ConsulName(),
+		Parents:   parents,
+		Rules:     rules,
+		Hostnames: in.Spec.Hostnames,
+		Meta:      meta(datacenter),
+	}
+}
+
+// HTTPRouteFromConsul converts the Consul config entry into an HTTPRoute
+// resource. It's the reverse of ToConsul.
+func HTTPRouteFromConsul(entry *capi.HTTPRouteConfigEntry) *HTTPRoute {
+	var parents []ResourceReference
+	for _, p := range entry.Parents {
+		parents = append(parents, resourceReferenceFromConsul(p))
+	}
+	var rules []HTTPRouteRule
+	for _, r := range entry.Rules {
+		rules = append(rules, httpRouteRuleFromConsul(r))
+	}
+	return &HTTPRoute{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: entry.Name,
+		},
+		Spec: HTTPRouteSpec{
+			Parents:   parents,
+			Rules:     rules,
+			Hostnames: entry.Hostnames,
+		},
+	}
+}
+
+func (in *HTTPRoute) MatchesConsul(candidate capi.ConfigEntry) bool {
+	configEntry, ok := candidate.(*capi.HTTPRouteConfigEntry)
+	if !ok {
+		return false
+	}
+	// No datacenter is passed to ToConsul as we ignore the Meta field when checking for equality.
+	// The Status field is set by Consul.
+	return cmp.Equal(in.ToConsul(""), configEntry, cmpopts.IgnoreFields(capi.HTTPRouteConfigEntry{}, "Partition", "Namespace", "Meta", "Status", "ModifyIndex", "CreateIndex"), cmpopts.IgnoreUnexported(), cmpopts.EquateEmpty())
+}
+
+func (in *HTTPRoute) Validate(consulMeta common.ConsulMeta) error {
+	var errs field.ErrorList
+	path := field.NewPath("spec")
+
+	for i, p := range in.Spec.Parents {
+		errs = append(errs, p.validate(path.Child("parents").Index(i), capi.APIGateway, consulMeta)...)
+	}
+	for i, r := range in.Spec.Rules {
+		errs = append(errs, r.validate(path.Child("rules").Index(i), consulMeta)...)
+	}
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: httpRouteKubeKind},
+			in.KubernetesName(), errs)
+	}
+	return nil
+}
+
+// DefaultNamespaceFields sets the namespace field on the parents and services
+// to their default values if namespaces are enabled.
+func (in *HTTPRoute) DefaultNamespaceFields(consulMeta common.ConsulMeta) {
+	// If namespaces are enabled we want to set the namespace fields to their
+	// defaults. If namespaces are not enabled (i.e. OSS) we don't set the
+	// namespace fields because this would cause errors
+	// making API calls (because namespace fields can't be set in OSS).
+	if consulMeta.NamespacesEnabled {
+		// Default to the current namespace (i.e. the namespace of the config entry).
+		namespace := namespaces.ConsulNamespace(in.Namespace, consulMeta.NamespacesEnabled, consulMeta.DestinationNamespace, consulMeta.Mirroring, consulMeta.Prefix)
+		for i, parent := range in.Spec.Parents {
+			if parent.Namespace == "" {
+				in.Spec.Parents[i].Namespace = namespace
+			}
+		}
+		for i, rule := range in.Spec.Rules {
+			for j, service := range rule.Services {
+				if service.Namespace == "" {
+					in.Spec.Rules[i].Services[j].Namespace = namespace
+				}
+			}
+		}
+	}
+}
+
+func (in HTTPRouteRule) toConsul() capi.HTTPRouteRule {
+	var matches []capi.HTTPMatch
+	for _, m := range in.Matches {
+		matches = append(matches, m.toConsul())
+	}
+	var services []capi.HTTPService
+	for _, s := range in.Services {
+		services = append(services, s.toConsul())
+	}
+	return capi.HTTPRouteRule{
+		Filters:  in.Filters.toConsul(),
+		Matches:  matches,
+		Services: services,
+	}
+}
+
+func httpRouteRuleFromConsul(in capi.HTTPRouteRule) HTTPRouteRule {
+	var matches []HTTPMatch
+	for _, m := range in.Matches {
+		matches = append(matches, httpMatchFromConsul(m))
+	}
+	var services []HTTPService
+	for _, s := range in.Services {
+		services = append(services, httpServiceFromConsul(s))
+	}
+	return HTTPRouteRule{
+		Filters:  httpFiltersFromConsul(in.Filters),
+		Matches:  matches,
+		Services: services,
+	}
+}
+
+func (in HTTPRouteRule) validate(path *field.Path, consulMeta common.ConsulMeta) field.ErrorList {
+	var errs field.ErrorList
+	errs = append(errs, in.Filters.validate(path.Child("filters"))...)
+	for i, m := range in.Matches {
+		errs = append(errs, m.validate(path.Child("matches").Index(i))...)
+	}
+	for i, s := range in.Services {
+		errs = append(errs, s.validate(path.Child("services").Index(i), consulMeta)...)
+	}
+	return errs
+}
+
+func (in HTTPMatch) toConsul() capi.HTTPMatch {
+	var headers []capi.HTTPHeaderMatch
+	for _, h := range in.Headers {
+		headers = append(headers, capi.HTTPHeaderMatch{
+			Match: capi.HTTPHeaderMatchType(h.Match),
+			Name:  h.Name,
+			Value: h.Value,
+		})
+	}
+	var query []capi.HTTPQueryMatch
+	for _, q := range in.Query {
+		query = append(query, capi.HTTPQueryMatch{
+			Match: capi.HTTPQueryMatchType(q.Match),
+			Name:  q.Name,
+			Value: q.Value,
+		})
+	}
+	pathMatch := capi.HTTPPathMatch{
+		Match: capi.HTTPPathMatchType(in.Path.Match),
+		Value: in.Path.Value,
+	}
+	if pathMatch.Match == "" {
+		pathMatch = capi.HTTPPathMatch{Match: capi.HTTPPathMatchPrefix, Value: "/"}
+	}
+	return capi.HTTPMatch{
+		Headers: headers,
+		Method:  capi.HTTPMatchMethod(in.Method),
+		Path:    pathMatch,
+		Query:   query,
+	}
+}
+
+func httpMatchFromConsul(in capi.HTTPMatch) HTTPMatch {
+	var headers []HTTPHeaderMatch
+	for _, h := range in.Headers {
+		headers = append(headers, HTTPHeaderMatch{
+			Match: string(h.Match),
+			Name:  h.Name,
+			Value: h.Value,
+		})
+	}
+	var query []HTTPQueryMatch
+	for _, q := range in.Query {
+		query = append(query, HTTPQueryMatch{
+			Match: string(q.Match),
+			Name:  q.Name,
+			Value: q.Value,
+		})
+	}
+	return HTTPMatch{
+		Headers: headers,
+		Method:  string(in.Method),
+		Path: HTTPPathMatch{
+			Match: string(in.Path.Match),
+			Value: in.Path.Value,
+		},
+		Query: query,
+	}
+}
+
+func (in HTTPMatch) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	methods := []string{"", "CONNECT", "DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT", "TRACE"}
+	if !sliceContains(methods, in.Method) {
+		errs = append(errs, field.Invalid(path.Child("method"), in.Method, notInSliceMessage(methods)))
+	}
+
+	pathMatches := []string{"", "exact", "prefix", "regex"}
+	if !sliceContains(pathMatches, in.Path.Match) {
+		errs = append(errs, field.Invalid(path.Child("path").Child("match"), in.Path.Match, notInSliceMessage(pathMatches)))
+	}
+	if (in.Path.Match == "exact" || in.Path.Match == "prefix") && (in.Path.Value == "" || invalidPathPrefix(in.Path.Value)) {
+		errs = append(errs, field.Invalid(path.Child("path").Child("value"), in.Path.Value, "must begin with a '/'"))
+	}
+
+	headerMatches := []string{"exact", "prefix", "present", "regex", "suffix"}
+	for i, h := range in.Headers {
+		if !sliceContains(headerMatches, h.Match) {
+			errs = append(errs, field.Invalid(path.Child("headers").Index(i).Child("match"), h.Match, notInSliceMessage(headerMatches)))
+		}
+		if h.Name == "" {
+			errs = append(errs, field.Required(path.Child("headers").Index(i).Child("name"), "name is required"))
+		}
+	}
+
+	queryMatches := []string{"exact", "present", "regex"}
+	for i, q := range in.Query {
+		if !sliceContains(queryMatches, q.Match) {
+			errs = append(errs, field.Invalid(path.Child("query").Index(i).Child("match"), q.Match, notInSliceMessage(queryMatches)))
+		}
+		if q.Name == "" {
+			errs = append(errs, field.Required(path.Child("query").Index(i).Child("name"), "name is required"))
+		}
+	}
+	return errs
+}
+
+func (in HTTPFilters) toConsul() capi.HTTPFilters {
+	var headers []capi.HTTPHeaderFilter
+	for _, h := range in.Headers {
+		headers = append(headers, capi.HTTPHeaderFilter{
+			Add:    h.Add,
+			Remove: h.Remove,
+			Set:    h.Set,
+		})
+	}
+	var urlRewrite *capi.URLRewrite
+	if in.URLRewrite != nil {
+		urlRewrite = &capi.URLRewrite{Path: in.URLRewrite.Path}
+	}
+	return capi.HTTPFilters{
+		Headers:    headers,
+		URLRewrite: urlRewrite,
+	}
+}
+
+func httpFiltersFromConsul(in capi.HTTPFilters) HTTPFilters {
+	var headers []HTTPHeaderFilter
+	for _, h := range in.Headers {
+		headers = append(headers, HTTPHeaderFilter{
+			Add:    h.Add,
+			Remove: h.Remove,
+			Set:    h.Set,
+		})
+	}
+	var urlRewrite *URLRewrite
+	if in.URLRewrite != nil {
+		urlRewrite = &URLRewrite{Path: in.URLRewrite.Path}
+	}
+	return HTTPFilters{
+		Headers:    headers,
+		URLRewrite: urlRewrite,
+	}
+}
+
+func (in HTTPFilters) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if in.URLRewrite != nil && invalidPathPrefix(in.URLRewrite.Path) {
+		errs = append(errs, field.Invalid(path.Child("urlRewrite").Child("path"), in.URLRewrite.Path, "must begin with a '/'"))
+	}
+	return errs
+}
+
+func (in HTTPService) toConsul() capi.HTTPService {
+	weight := in.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+	return capi.HTTPService{
+		Name:      in.Name,
+		Weight:    weight,
+		Filters:   in.Filters.toConsul(),
+		Partition: in.Partition,
+		Namespace: in.Namespace,
+	}
+}
+
+func httpServiceFromConsul(in capi.HTTPService) HTTPService {
+	return HTTPService{
+		Name:      in.Name,
+		Weight:    in.Weight,
+		Filters:   httpFiltersFromConsul(in.Filters),
+		Partition: in.Partition,
+		Namespace: in.Namespace,
+	}
+}
+
+func (in HTTPService) validate(path *field.Path, consulMeta common.ConsulMeta) field.ErrorList {
+	var errs field.ErrorList
+	if in.Name == "" {
+		errs = append(errs, field.Required(path.Child("name"), "name is required"))
+	}
+	if in.Weight < 0 {
+		errs = append(errs, field.Invalid(path.Child("weight"), in.Weight, "must not be negative"))
+	}
+	errs = append(errs, in.Filters.validate(path.Child("filters"))...)
+	if in.Partition != "" && !consulMeta.PartitionsEnabled {
+		errs = append(errs, field.Invalid(path.Child("partition"), in.Partition,
+			"Consul Enterprise admin-partitions must be enabled to set partition"))
+	}
+	if in.Namespace != "" && !consulMeta.NamespacesEnabled {
+		errs = append(errs, field.Invalid(path.Child("namespace"), in.Namespace,
+			"Consul Enterprise namespaces must be enabled to set namespace"))
+	}
+	return errs
+}
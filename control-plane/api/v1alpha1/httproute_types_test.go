@@ -0,0 +1,560 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestHTTPRoute_MatchesConsul(t *testing.T) {
+	cases := map[string]struct {
+		Ours    HTTPRoute
+		Theirs  capi.ConfigEntry
+		Matches bool
+	}{
+		"empty fields matches": {
+			Ours: HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.HTTPRouteConfigEntry{
+				Kind:        capi.HTTPRoute,
+				Name:        "name",
+				Namespace:   "namespace",
+				CreateIndex: 1,
+				ModifyIndex: 2,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Matches: true,
+		},
+		"defaults set by Consul matches": {
+			Ours: HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: HTTPRouteSpec{
+					Parents: []ResourceReference{{Name: "gateway"}},
+					Rules: []HTTPRouteRule{
+						{
+							Matches:  []HTTPMatch{{Method: "GET"}},
+							Services: []HTTPService{{Name: "web"}},
+						},
+					},
+				},
+			},
+			Theirs: &capi.HTTPRouteConfigEntry{
+				Kind:    capi.HTTPRoute,
+				Name:    "name",
+				Parents: []capi.ResourceReference{{Kind: capi.APIGateway, Name: "gateway"}},
+				Rules: []capi.HTTPRouteRule{
+					{
+						Matches: []capi.HTTPMatch{
+							{
+								Method: capi.HTTPMatchMethodGet,
+								Path:   capi.HTTPPathMatch{Match: capi.HTTPPathMatchPrefix, Value: "/"},
+							},
+						},
+						Services: []capi.HTTPService{{Name: "web", Weight: 1}},
+					},
+				},
+				Status: capi.ConfigEntryStatus{
+					Conditions: []capi.Condition{{Type: "Bound", Status: "True"}},
+				},
+			},
+			Matches: true,
+		},
+		"mismatched types does not match": {
+			Ours: HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.TCPRouteConfigEntry{
+				Kind: capi.TCPRoute,
+				Name: "name",
+			},
+			Matches: false,
+		},
+		"different hostnames does not match": {
+			Ours: HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: HTTPRouteSpec{
+					Hostnames: []string{"foo.example.com"},
+				},
+			},
+			Theirs: &capi.HTTPRouteConfigEntry{
+				Kind:      capi.HTTPRoute,
+				Name:      "name",
+				Hostnames: []string{"bar.example.com"},
+			},
+			Matches: false,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.Matches, c.Ours.MatchesConsul(c.Theirs))
+		})
+	}
+}
+
+func TestHTTPRoute_ToConsul(t *testing.T) {
+	cases := map[string]struct {
+		Ours HTTPRoute
+		Exp  *capi.HTTPRouteConfigEntry
+	}{
+		"empty fields": {
+			Ours: HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Exp: &capi.HTTPRouteConfigEntry{
+				Kind: capi.HTTPRoute,
+				Name: "name",
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
+		"every field set": {
+			Ours: HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: HTTPRouteSpec{
+					Parents: []ResourceReference{
+						{
+							Kind:        capi.APIGateway,
+							Name:        "gateway",
+							SectionName: "http",
+							Partition:   "partition",
+							Namespace:   "namespace",
+						},
+					},
+					Rules: []HTTPRouteRule{
+						{
+							Filters: HTTPFilters{
+								Headers: []HTTPHeaderFilter{
+									{
+										Add:    map[string]string{"x-add": "add"},
+										Remove: []string{"x-remove"},
+										Set:    map[string]string{"x-set": "set"},
+									},
+								},
+								URLRewrite: &URLRewrite{Path: "/v2"},
+							},
+							Matches: []HTTPMatch{
+								{
+									Headers: []HTTPHeaderMatch{{Match: "exact", Name: "x-version", Value: "2"}},
+									Method:  "POST",
+									Path:    HTTPPathMatch{Match: "prefix", Value: "/api"},
+									Query:   []HTTPQueryMatch{{Match: "present", Name: "debug"}},
+								},
+							},
+							Services: []HTTPService{
+								{
+									Name:   "web",
+									Weight: 90,
+									Filters: HTTPFilters{
+										Headers: []HTTPHeaderFilter{{Set: map[string]string{"x-service": "web"}}},
+									},
+									Partition: "partition",
+									Namespace: "namespace",
+								},
+								{
+									Name:   "web-canary",
+									Weight: 10,
+								},
+							},
+						},
+					},
+					Hostnames: []string{"example.com"},
+				},
+			},
+			Exp: &capi.HTTPRouteConfigEntry{
+				Kind: capi.HTTPRoute,
+				Name: "name",
+				Parents: []capi.ResourceReference{
+					{
+						Kind:        capi.APIGateway,
+						Name:        "gateway",
+						SectionName: "http",
+						Partition:   "partition",
+						Namespace:   "namespace",
+					},
+				},
+				Rules: []capi.HTTPRouteRule{
+					{
+						Filters: capi.HTTPFilters{
+							Headers: []capi.HTTPHeaderFilter{
+								{
+									Add:    map[string]string{"x-add": "add"},
+									Remove: []string{"x-remove"},
+									Set:    map[string]string{"x-set": "set"},
+								},
+							},
+							URLRewrite: &capi.URLRewrite{Path: "/v2"},
+						},
+						Matches: []capi.HTTPMatch{
+							{
+								Headers: []capi.HTTPHeaderMatch{{Match: capi.HTTPHeaderMatchExact, Name: "x-version", Value: "2"}},
+								Method:  capi.HTTPMatchMethodPost,
+								Path:    capi.HTTPPathMatch{Match: capi.HTTPPathMatchPrefix, Value: "/api"},
+								Query:   []capi.HTTPQueryMatch{{Match: capi.HTTPQueryMatchPresent, Name: "debug"}},
+							},
+						},
+						Services: []capi.HTTPService{
+							{
+								Name:   "web",
+								Weight: 90,
+								Filters: capi.HTTPFilters{
+									Headers: []capi.HTTPHeaderFilter{{Set: map[string]string{"x-service": "web"}}},
+								},
+								Partition: "partition",
+								Namespace: "namespace",
+							},
+							{
+								Name:   "web-canary",
+								Weight: 10,
+							},
+						},
+					},
+				},
+				Hostnames: []string{"example.com"},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			act := c.Ours.ToConsul("datacenter")
+			resource, ok := act.(*capi.HTTPRouteConfigEntry)
+			require.True(t, ok, "could not cast")
+			require.Equal(t, c.Exp, resource)
+		})
+	}
+}
+
+func TestHTTPRouteFromConsul(t *testing.T) {
+	cases := map[string]struct {
+		Consul *capi.HTTPRouteConfigEntry
+		Exp    HTTPRoute
+	}{
+		"empty fields": {
+			Consul: &capi.HTTPRouteConfigEntry{
+				Kind: capi.HTTPRoute,
+				Name: "name",
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+		},
+		"every field set": {
+			Consul: &capi.HTTPRouteConfigEntry{
+				Kind: capi.HTTPRoute,
+				Name: "name",
+				Parents: []capi.ResourceReference{
+					{
+						Kind:        capi.APIGateway,
+						Name:        "gateway",
+						SectionName: "http",
+						Partition:   "partition",
+						Namespace:   "namespace",
+					},
+				},
+				Rules: []capi.HTTPRouteRule{
+					{
+						Filters: capi.HTTPFilters{
+							Headers: []capi.HTTPHeaderFilter{
+								{
+									Add:    map[string]string{"x-add": "add"},
+									Remove: []string{"x-remove"},
+									Set:    map[string]string{"x-set": "set"},
+								},
+							},
+							URLRewrite: &capi.URLRewrite{Path: "/v2"},
+						},
+						Matches: []capi.HTTPMatch{
+							{
+								Headers: []capi.HTTPHeaderMatch{{Match: capi.HTTPHeaderMatchExact, Name: "x-version", Value: "2"}},
+								Method:  capi.HTTPMatchMethodPost,
+								Path:    capi.HTTPPathMatch{Match: capi.HTTPPathMatchPrefix, Value: "/api"},
+								Query:   []capi.HTTPQueryMatch{{Match: capi.HTTPQueryMatchPresent, Name: "debug"}},
+							},
+						},
+						Services: []capi.HTTPService{
+							{
+								Name:   "web",
+								Weight: 90,
+								Filters: capi.HTTPFilters{
+									Headers: []capi.HTTPHeaderFilter{{Set: map[string]string{"x-service": "web"}}},
+								},
+								Partition: "partition",
+								Namespace: "namespace",
+							},
+							{
+								Name:   "web-canary",
+								Weight: 10,
+							},
+						},
+					},
+				},
+				Hostnames: []string{"example.com"},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: HTTPRouteSpec{
+					Parents: []ResourceReference{
+						{
+							Kind:        capi.APIGateway,
+							Name:        "gateway",
+							SectionName: "http",
+							Partition:   "partition",
+							Namespace:   "namespace",
+						},
+					},
+					Rules: []HTTPRouteRule{
+						{
+							Filters: HTTPFilters{
+								Headers: []HTTPHeaderFilter{
+									{
+										Add:    map[string]string{"x-add": "add"},
+										Remove: []string{"x-remove"},
+										Set:    map[string]string{"x-set": "set"},
+									},
+								},
+								URLRewrite: &URLRewrite{Path: "/v2"},
+							},
+							Matches: []HTTPMatch{
+								{
+									Headers: []HTTPHeaderMatch{{Match: "exact", Name: "x-version", Value: "2"}},
+									Method:  "POST",
+									Path:    HTTPPathMatch{Match: "prefix", Value: "/api"},
+									Query:   []HTTPQueryMatch{{Match: "present", Name: "debug"}},
+								},
+							},
+							Services: []HTTPService{
+								{
+									Name:   "web",
+									Weight: 90,
+									Filters: HTTPFilters{
+										Headers: []HTTPHeaderFilter{{Set: map[string]string{"x-service": "web"}}},
+									},
+									Partition: "partition",
+									Namespace: "namespace",
+								},
+								{
+									Name:   "web-canary",
+									Weight: 10,
+								},
+							},
+						},
+					},
+					Hostnames: []string{"example.com"},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, &c.Exp, HTTPRouteFromConsul(c.Consul))
+		})
+	}
+}
+
+func TestHTTPRoute_Validate(t *testing.T) {
+	cases := map[string]struct {
+		input           *HTTPRoute
+		consulMeta      common.ConsulMeta
+		expectedErrMsgs []string
+	}{
+		"valid": {
+			input: &HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: HTTPRouteSpec{
+					Parents: []ResourceReference{{Name: "gateway"}},
+					Rules: []HTTPRouteRule{
+						{
+							Filters: HTTPFilters{URLRewrite: &URLRewrite{Path: "/"}},
+							Matches: []HTTPMatch{
+								{
+									Headers: []HTTPHeaderMatch{{Match: "present", Name: "x-debug"}},
+									Method:  "GET",
+									Path:    HTTPPathMatch{Match: "regex", Value: ".*"},
+									Query:   []HTTPQueryMatch{{Match: "exact", Name: "version", Value: "2"}},
+								},
+							},
+							Services: []HTTPService{{Name: "web", Weight: 2}},
+						},
+					},
+				},
+			},
+		},
+		"invalid matches": {
+			input: &HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: HTTPRouteSpec{
+					Rules: []HTTPRouteRule{
+						{
+							Matches: []HTTPMatch{
+								{
+									Headers: []HTTPHeaderMatch{{Match: "contains"}},
+									Method:  "get",
+									Path:    HTTPPathMatch{Match: "prefix", Value: "api"},
+									Query:   []HTTPQueryMatch{{Match: "prefix"}},
+								},
+								{
+									Path: HTTPPathMatch{Match: "suffix"},
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.rules[0].matches[0].method: Invalid value: "get": must be one of "", "CONNECT", "DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT", "TRACE"`,
+				`spec.rules[0].matches[0].path.value: Invalid value: "api": must begin with a '/'`,
+				`spec.rules[0].matches[0].headers[0].match: Invalid value: "contains": must be one of "exact", "prefix", "present", "regex", "suffix"`,
+				`spec.rules[0].matches[0].headers[0].name: Required value: name is required`,
+				`spec.rules[0].matches[0].query[0].match: Invalid value: "prefix": must be one of "exact", "present", "regex"`,
+				`spec.rules[0].matches[0].query[0].name: Required value: name is required`,
+				`spec.rules[0].matches[1].path.match: Invalid value: "suffix": must be one of "", "exact", "prefix", "regex"`,
+			},
+		},
+		"invalid parents, filters and services": {
+			input: &HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: HTTPRouteSpec{
+					Parents: []ResourceReference{{Kind: capi.IngressGateway, Name: "gateway"}},
+					Rules: []HTTPRouteRule{
+						{
+							Filters: HTTPFilters{URLRewrite: &URLRewrite{Path: "v2"}},
+							Services: []HTTPService{
+								{
+									Weight:    -1,
+									Filters:   HTTPFilters{URLRewrite: &URLRewrite{Path: "v3"}},
+									Partition: "part",
+									Namespace: "ns",
+								},
+							},
+						},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.parents[0].kind: Invalid value: "ingress-gateway": must be "api-gateway"`,
+				`spec.rules[0].filters.urlRewrite.path: Invalid value: "v2": must begin with a '/'`,
+				`spec.rules[0].services[0].name: Required value: name is required`,
+				`spec.rules[0].services[0].weight: Invalid value: -1: must not be negative`,
+				`spec.rules[0].services[0].filters.urlRewrite.path: Invalid value: "v3": must begin with a '/'`,
+				`spec.rules[0].services[0].partition: Invalid value: "part": Consul Enterprise admin-partitions must be enabled to set partition`,
+				`spec.rules[0].services[0].namespace: Invalid value: "ns": Consul Enterprise namespaces must be enabled to set namespace`,
+			},
+		},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := testCase.input.Validate(testCase.consulMeta)
+			if len(testCase.expectedErrMsgs) != 0 {
+				require.Error(t, err)
+				for _, s := range testCase.expectedErrMsgs {
+					require.Contains(t, err.Error(), s)
+				}
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestHTTPRoute_DefaultNamespaceFields(t *testing.T) {
+	cases := map[string]struct {
+		consulMeta          common.ConsulMeta
+		expectedDestination string
+	}{
+		"disabled": {
+			consulMeta:          common.ConsulMeta{},
+			expectedDestination: "",
+		},
+		"destinationNS": {
+			consulMeta: common.ConsulMeta{
+				NamespacesEnabled:    true,
+				DestinationNamespace: "foo",
+			},
+			expectedDestination: "foo",
+		},
+		"mirroringEnabledWithoutPrefix": {
+			consulMeta: common.ConsulMeta{
+				NamespacesEnabled: true,
+				Mirroring:         true,
+			},
+			expectedDestination: "bar",
+		},
+		"mirroringWithPrefix": {
+			consulMeta: common.ConsulMeta{
+				NamespacesEnabled: true,
+				Mirroring:         true,
+				Prefix:            "ns-",
+			},
+			expectedDestination: "ns-bar",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			input := &HTTPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "bar",
+				},
+				Spec: HTTPRouteSpec{
+					Parents: []ResourceReference{{Name: "gateway"}},
+					Rules: []HTTPRouteRule{
+						{
+							Services: []HTTPService{{Name: "web"}, {Name: "api", Namespace: "other"}},
+						},
+					},
+				},
+			}
+			input.DefaultNamespaceFields(c.consulMeta)
+			require.Equal(t, c.expectedDestination, input.Spec.Parents[0].Namespace)
+			require.Equal(t, c.expectedDestination, input.Spec.Rules[0].Services[0].Namespace)
+			require.Equal(t, "other", input.Spec.Rules[0].Services[1].Namespace)
+		})
+	}
+}
@@ -208,6 +208,24 @@ func (in *IngressGateway) SetLastSyncedTime(time *metav1.Time) {
 	in.Status.LastSyncedTime = time
 }
 
+func (in *IngressGateway) SetDriftedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.setCondition(Condition{
+		Type:               ConditionDrifted,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func (in *IngressGateway) SetLastSyncedGeneration(generation int64) {
+	in.Status.LastSyncedGeneration = generation
+}
+
+func (in *IngressGateway) LastSyncedGeneration() int64 {
+	return in.Status.LastSyncedGeneration
+}
+
 func (in *IngressGateway) SyncedCondition() (status corev1.ConditionStatus, reason, message string) {
 	cond := in.Status.GetCondition(ConditionSynced)
 	if cond == nil {
@@ -238,6 +256,24 @@ func (in *IngressGateway) ToConsul(datacenter string) capi.ConfigEntry {
 	}
 }
 
+// IngressGatewayFromConsul converts the Consul config entry into an
+// IngressGateway resource. It's the reverse of ToConsul.
+func IngressGatewayFromConsul(entry *capi.IngressGatewayConfigEntry) *IngressGateway {
+	var listeners []IngressListener
+	for _, l := range entry.Listeners {
+		listeners = append(listeners, ingressListenerFromConsul(l))
+	}
+	return &IngressGateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: entry.Name,
+		},
+		Spec: IngressGatewaySpec{
+			TLS:       *gatewayTLSConfigFromConsul(&entry.TLS),
+			Listeners: listeners,
+		},
+	}
+}
+
 func (in *IngressGateway) MatchesConsul(candidate capi.ConfigEntry) bool {
 	configEntry, ok := candidate.(*capi.IngressGatewayConfigEntry)
 	if !ok {
@@ -297,6 +333,19 @@ func (in *GatewayTLSConfig) toConsul() *capi.GatewayTLSConfig {
 	}
 }
 
+func gatewayTLSConfigFromConsul(in *capi.GatewayTLSConfig) *GatewayTLSConfig {
+	if in == nil {
+		return nil
+	}
+	return &GatewayTLSConfig{
+		Enabled:       in.Enabled,
+		SDS:           gatewayTLSSDSConfigFromConsul(in.SDS),
+		TLSMaxVersion: in.TLSMaxVersion,
+		TLSMinVersion: in.TLSMinVersion,
+		CipherSuites:  in.CipherSuites,
+	}
+}
+
 func (in *GatewayTLSConfig) validate(path *field.Path) field.ErrorList {
 	if in == nil {
 		return nil
@@ -327,6 +376,20 @@ func (in IngressListener) toConsul() capi.IngressListener {
 	}
 }
 
+func ingressListenerFromConsul(in capi.IngressListener) IngressListener {
+	var services []IngressService
+	for _, s := range in.Services {
+		services = append(services, ingressServiceFromConsul(s))
+	}
+
+	return IngressListener{
+		Port:     in.Port,
+		Protocol: in.Protocol,
+		TLS:      gatewayTLSConfigFromConsul(in.TLS),
+		Services: services,
+	}
+}
+
 func (in IngressService) toConsul() capi.IngressService {
 	return capi.IngressService{
 		Name:            in.Name,
@@ -339,6 +402,18 @@ func (in IngressService) toConsul() capi.IngressService {
 	}
 }
 
+func ingressServiceFromConsul(in capi.IngressService) IngressService {
+	return IngressService{
+		Name:            in.Name,
+		Hosts:           in.Hosts,
+		Namespace:       in.Namespace,
+		Partition:       in.Partition,
+		TLS:             gatewayServiceTLSConfigFromConsul(in.TLS),
+		RequestHeaders:  httpHeaderModifiersFromConsul(in.RequestHeaders),
+		ResponseHeaders: httpHeaderModifiersFromConsul(in.ResponseHeaders),
+	}
+}
+
 func (in *GatewayTLSSDSConfig) toConsul() *capi.GatewayTLSSDSConfig {
 	if in == nil {
 		return nil
@@ -349,6 +424,16 @@ func (in *GatewayTLSSDSConfig) toConsul() *capi.GatewayTLSSDSConfig {
 	}
 }
 
+func gatewayTLSSDSConfigFromConsul(in *capi.GatewayTLSSDSConfig) *GatewayTLSSDSConfig {
+	if in == nil {
+		return nil
+	}
+	return &GatewayTLSSDSConfig{
+		ClusterName:  in.ClusterName,
+		CertResource: in.CertResource,
+	}
+}
+
 func (in *GatewayServiceTLSConfig) toConsul() *capi.GatewayServiceTLSConfig {
 	if in == nil {
 		return nil
@@ -358,6 +443,15 @@ func (in *GatewayServiceTLSConfig) toConsul() *capi.GatewayServiceTLSConfig {
 	}
 }
 
+func gatewayServiceTLSConfigFromConsul(in *capi.GatewayServiceTLSConfig) *GatewayServiceTLSConfig {
+	if in == nil {
+		return nil
+	}
+	return &GatewayServiceTLSConfig{
+		SDS: gatewayTLSSDSConfigFromConsul(in.SDS),
+	}
+}
+
 func (in IngressListener) validate(path *field.Path, consulMeta common.ConsulMeta) field.ErrorList {
 	var errs field.ErrorList
 	validProtocols := []string{"tcp", "http", "http2", "grpc"}
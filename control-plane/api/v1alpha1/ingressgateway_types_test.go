@@ -470,6 +470,221 @@ func TestIngressGateway_ToConsul(t *testing.T) {
 	}
 }
 
+func TestIngressGatewayFromConsul(t *testing.T) {
+	cases := map[string]struct {
+		Consul *capi.IngressGatewayConfigEntry
+		Exp    IngressGateway
+	}{
+		"empty fields": {
+			Consul: &capi.IngressGatewayConfigEntry{
+				Kind: capi.IngressGateway,
+				Name: "name",
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: IngressGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: IngressGatewaySpec{},
+			},
+		},
+		"every field set": {
+			Consul: &capi.IngressGatewayConfigEntry{
+				Kind: capi.IngressGateway,
+				Name: "name",
+				TLS: capi.GatewayTLSConfig{
+					Enabled: true,
+					SDS: &capi.GatewayTLSSDSConfig{
+						ClusterName:  "cluster1",
+						CertResource: "cert1",
+					},
+					TLSMinVersion: "TLSv1_0",
+					TLSMaxVersion: "TLSv1_1",
+					CipherSuites:  []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "AES128-SHA"},
+				},
+				Listeners: []capi.IngressListener{
+					{
+						Port:     8888,
+						Protocol: "tcp",
+						TLS: &capi.GatewayTLSConfig{
+							Enabled: true,
+							SDS: &capi.GatewayTLSSDSConfig{
+								ClusterName:  "cluster1",
+								CertResource: "cert1",
+							},
+							TLSMinVersion: "TLSv1_0",
+							TLSMaxVersion: "TLSv1_1",
+							CipherSuites:  []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "AES128-SHA"},
+						},
+						Services: []capi.IngressService{
+							{
+								Name:      "name1",
+								Hosts:     []string{"host1_1", "host1_2"},
+								Namespace: "ns1",
+								Partition: "default",
+								TLS: &capi.GatewayServiceTLSConfig{
+									SDS: &capi.GatewayTLSSDSConfig{
+										ClusterName:  "cluster1",
+										CertResource: "cert1",
+									},
+								},
+								RequestHeaders: &capi.HTTPHeaderModifiers{
+									Add: map[string]string{
+										"foo":    "bar",
+										"source": "dest",
+									},
+									Set: map[string]string{
+										"bar": "baz",
+										"key": "car",
+									},
+									Remove: []string{
+										"foo",
+										"bar",
+										"baz",
+									},
+								},
+								ResponseHeaders: &capi.HTTPHeaderModifiers{
+									Add: map[string]string{
+										"doo":    "var",
+										"aource": "sest",
+									},
+									Set: map[string]string{
+										"var": "vaz",
+										"jey": "xar",
+									},
+									Remove: []string{
+										"doo",
+										"var",
+										"vaz",
+									},
+								},
+							},
+							{
+								Name:      "name2",
+								Hosts:     []string{"host2_1", "host2_2"},
+								Namespace: "ns2",
+							},
+						},
+					},
+					{
+						Port:     9999,
+						Protocol: "http",
+						Services: []capi.IngressService{
+							{
+								Name: "*",
+							},
+						},
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: IngressGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: IngressGatewaySpec{
+					TLS: GatewayTLSConfig{
+						Enabled: true,
+						SDS: &GatewayTLSSDSConfig{
+							ClusterName:  "cluster1",
+							CertResource: "cert1",
+						},
+						TLSMinVersion: "TLSv1_0",
+						TLSMaxVersion: "TLSv1_1",
+						CipherSuites:  []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "AES128-SHA"},
+					},
+					Listeners: []IngressListener{
+						{
+							Port:     8888,
+							Protocol: "tcp",
+							TLS: &GatewayTLSConfig{
+								Enabled: true,
+								SDS: &GatewayTLSSDSConfig{
+									ClusterName:  "cluster1",
+									CertResource: "cert1",
+								},
+								TLSMinVersion: "TLSv1_0",
+								TLSMaxVersion: "TLSv1_1",
+								CipherSuites:  []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "AES128-SHA"},
+							},
+							Services: []IngressService{
+								{
+									Name:      "name1",
+									Hosts:     []string{"host1_1", "host1_2"},
+									Namespace: "ns1",
+									Partition: "default",
+									TLS: &GatewayServiceTLSConfig{
+										SDS: &GatewayTLSSDSConfig{
+											ClusterName:  "cluster1",
+											CertResource: "cert1",
+										},
+									},
+									RequestHeaders: &HTTPHeaderModifiers{
+										Add: map[string]string{
+											"foo":    "bar",
+											"source": "dest",
+										},
+										Set: map[string]string{
+											"bar": "baz",
+											"key": "car",
+										},
+										Remove: []string{
+											"foo",
+											"bar",
+											"baz",
+										},
+									},
+									ResponseHeaders: &HTTPHeaderModifiers{
+										Add: map[string]string{
+											"doo":    "var",
+											"aource": "sest",
+										},
+										Set: map[string]string{
+											"var": "vaz",
+											"jey": "xar",
+										},
+										Remove: []string{
+											"doo",
+											"var",
+											"vaz",
+										},
+									},
+								},
+								{
+									Name:      "name2",
+									Hosts:     []string{"host2_1", "host2_2"},
+									Namespace: "ns2",
+								},
+							},
+						},
+						{
+							Port:     9999,
+							Protocol: "http",
+							Services: []IngressService{
+								{
+									Name: "*",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, &c.Exp, IngressGatewayFromConsul(c.Consul))
+		})
+	}
+}
+
 func TestIngressGateway_Validate(t *testing.T) {
 	cases := map[string]struct {
 		input             *IngressGateway
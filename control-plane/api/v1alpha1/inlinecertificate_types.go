@@ -0,0 +1,249 @@
+package v1alpha1
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const (
+	inlineCertificateKubeKind = "inlinecertificate"
+)
+
+func init() {
+	SchemeBuilder.Register(&InlineCertificate{}, &InlineCertificateList{})
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+
+// InlineCertificate is the Schema for the inlinecertificates API. It requires Consul 1.15 or newer.
+// +kubebuilder:printcolumn:name="Synced",type="string",JSONPath=".status.conditions[?(@.type==\"Synced\")].status",description="The sync status of the resource with Consul"
+// +kubebuilder:printcolumn:name="Last Synced",type="date",JSONPath=".status.lastSyncedTime",description="The last successful synced time of the resource with Consul"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The age of the resource"
+// +kubebuilder:resource:shortName="inline-certificate"
+type InlineCertificate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   InlineCertificateSpec `json:"spec,omitempty"`
+	Status `json:"status,omitempty"`
+
+	// certificate and privateKey are read from the Secret referenced by
+	// spec.secretRef by the controller. They are never stored in Kubernetes.
+	certificate string `json:"-"`
+	privateKey  string `json:"-"`
+}
+
+// +kubebuilder:object:root=true
+
+// InlineCertificateList contains a list of InlineCertificate.
+type InlineCertificateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []InlineCertificate `json:"items"`
+}
+
+// InlineCertificateSpec defines the desired state of InlineCertificate.
+// Either certificate and privateKey or secretRef must be set.
+type InlineCertificateSpec struct {
+	// Certificate is the public certificate, including any intermediate
+	// certificates, in PEM format.
+	Certificate string `json:"certificate,omitempty"`
+	// PrivateKey is the private key of the certificate in PEM format.
+	PrivateKey string `json:"privateKey,omitempty"`
+	// SecretRef references a Secret of type kubernetes.io/tls in the namespace
+	// of the resource. The certificate and private key are read from its
+	// tls.crt and tls.key keys instead of being stored in the resource, and
+	// the config entry is updated whenever the Secret changes. It requires
+	// the controller to be allowed to read Secrets.
+	SecretRef *InlineCertificateSecretRef `json:"secretRef,omitempty"`
+}
+
+// InlineCertificateSecretRef references the Secret of an inline certificate.
+type InlineCertificateSecretRef struct {
+	// Name is the name of the Secret.
+	Name string `json:"name,omitempty"`
+}
+
+func (in *InlineCertificate) GetObjectMeta() metav1.ObjectMeta {
+	return in.ObjectMeta
+}
+
+func (in *InlineCertificate) AddFinalizer(name string) {
+	in.ObjectMeta.Finalizers = append(in.Finalizers(), name)
+}
+
+func (in *InlineCertificate) RemoveFinalizer(name string) {
+	var newFinalizers []string
+	for _, oldF := range in.Finalizers() {
+		if oldF != name {
+			newFinalizers = append(newFinalizers, oldF)
+		}
+	}
+	in.ObjectMeta.Finalizers = newFinalizers
+}
+
+func (in *InlineCertificate) Finalizers() []string {
+	return in.ObjectMeta.Finalizers
+}
+
+func (in *InlineCertificate) ConsulKind() string {
+	return capi.InlineCertificate
+}
+
+func (in *InlineCertificate) ConsulGlobalResource() bool {
+	return false
+}
+
+func (in *InlineCertificate) ConsulMirroringNS() string {
+	return in.Namespace
+}
+
+func (in *InlineCertificate) KubeKind() string {
+	return inlineCertificateKubeKind
+}
+
+func (in *InlineCertificate) ConsulName() string {
+	return in.ObjectMeta.Name
+}
+
+func (in *InlineCertificate) KubernetesName() string {
+	return in.ObjectMeta.Name
+}
+
+func (in *InlineCertificate) SetSyncedCondition(status corev1.ConditionStatus, reason, message string) {
+	in.Status.Conditions = Conditions{
+		{
+			Type:               ConditionSynced,
+			Status:             status,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reason,
+			Message:            message,
+		},
+	}
+}
+
+func (in *InlineCertificate) SetLastSyncedTime(time *metav1.Time) {
+	in.Status.LastSyncedTime = time
+}
+
+func (in *InlineCertificate) SetDriftedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.setCondition(Condition{
+		Type:               ConditionDrifted,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func (in *InlineCertificate) SetLastSyncedGeneration(generation int64) {
+	in.Status.LastSyncedGeneration = generation
+}
+
+func (in *InlineCertificate) LastSyncedGeneration() int64 {
+	return in.Status.LastSyncedGeneration
+}
+
+func (in *InlineCertificate) SyncedCondition() (status corev1.ConditionStatus, reason, message string) {
+	cond := in.Status.GetCondition(ConditionSynced)
+	if cond == nil {
+		return corev1.ConditionUnknown, "", ""
+	}
+	return cond.Status, cond.Reason, cond.Message
+}
+
+func (in *InlineCertificate) SyncedConditionStatus() corev1.ConditionStatus {
+	condition := in.Status.GetCondition(ConditionSynced)
+	if condition == nil {
+		return corev1.ConditionUnknown
+	}
+	return condition.Status
+}
+
+// SetSecret sets the certificate and private key of the config entry from
+// the Secret referenced by spec.secretRef. It returns an error if the Secret
+// doesn't contain a valid certificate and private key.
+func (in *InlineCertificate) SetSecret(secret *corev1.Secret) error {
+	if secret.Type != corev1.SecretTypeTLS {
+		return fmt.Errorf("secret %q must be of type %s", secret.Name, corev1.SecretTypeTLS)
+	}
+	certificate := string(secret.Data[corev1.TLSCertKey])
+	privateKey := string(secret.Data[corev1.TLSPrivateKeyKey])
+	if _, err := tls.X509KeyPair([]byte(certificate), []byte(privateKey)); err != nil {
+		return fmt.Errorf("secret %q does not contain a valid certificate and private key: %s", secret.Name, err)
+	}
+	in.certificate = certificate
+	in.privateKey = privateKey
+	return nil
+}
+
+func (in *InlineCertificate) ToConsul(datacenter string) capi.ConfigEntry {
+	certificate, privateKey := in.Spec.Certificate, in.Spec.PrivateKey
+	if in.Spec.SecretRef != nil {
+		certificate, privateKey = in.certificate, in.privateKey
+	}
+	return &capi.InlineCertificateConfigEntry{
+		Kind:        in.ConsulKind(),
+		Name:        in.ConsulName(),
+		Certificate: certificate,
+		PrivateKey:  privateKey,
+		Meta:        meta(datacenter),
+	}
+}
+
+func (in *InlineCertificate) MatchesConsul(candidate capi.ConfigEntry) bool {
+	configEntry, ok := candidate.(*capi.InlineCertificateConfigEntry)
+	if !ok {
+		return false
+	}
+	// No datacenter is passed to ToConsul as we ignore the Meta field when checking for equality.
+	return cmp.Equal(in.ToConsul(""), configEntry, cmpopts.IgnoreFields(capi.InlineCertificateConfigEntry{}, "Partition", "Namespace", "Meta", "ModifyIndex", "CreateIndex"), cmpopts.IgnoreUnexported(), cmpopts.EquateEmpty())
+}
+
+func (in *InlineCertificate) Validate(_ common.ConsulMeta) error {
+	var errs field.ErrorList
+	path := field.NewPath("spec")
+
+	inline := in.Spec.Certificate != "" || in.Spec.PrivateKey != ""
+	switch {
+	case inline && in.Spec.SecretRef != nil:
+		errs = append(errs, field.Invalid(path.Child("secretRef"), in.Spec.SecretRef.Name,
+			"secretRef cannot be set if certificate or privateKey is set"))
+	case in.Spec.SecretRef != nil:
+		if in.Spec.SecretRef.Name == "" {
+			errs = append(errs, field.Required(path.Child("secretRef").Child("name"), "name is required"))
+		}
+	case in.Spec.Certificate == "":
+		errs = append(errs, field.Required(path.Child("certificate"), "certificate or secretRef must be set"))
+	case in.Spec.PrivateKey == "":
+		errs = append(errs, field.Required(path.Child("privateKey"), "privateKey must be set if certificate is set"))
+	default:
+		if _, err := tls.X509KeyPair([]byte(in.Spec.Certificate), []byte(in.Spec.PrivateKey)); err != nil {
+			// The private key is not included in the error.
+			errs = append(errs, field.Invalid(path.Child("certificate"), "<certificate>",
+				fmt.Sprintf("certificate and privateKey are not a valid key pair: %s", err)))
+		}
+	}
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: inlineCertificateKubeKind},
+			in.KubernetesName(), errs)
+	}
+	return nil
+}
+
+// DefaultNamespaceFields has no behaviour here as inline-certificates have no namespace specific fields.
+func (in *InlineCertificate) DefaultNamespaceFields(_ common.ConsulMeta) {
+}
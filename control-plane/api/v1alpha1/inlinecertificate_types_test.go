@@ -0,0 +1,291 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/cert"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestInlineCertificate_MatchesConsul(t *testing.T) {
+	cases := map[string]struct {
+		Ours    InlineCertificate
+		Theirs  capi.ConfigEntry
+		Matches bool
+	}{
+		"empty fields matches": {
+			Ours: InlineCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.InlineCertificateConfigEntry{
+				Kind:        capi.InlineCertificate,
+				Name:        "name",
+				Namespace:   "namespace",
+				CreateIndex: 1,
+				ModifyIndex: 2,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Matches: true,
+		},
+		"all fields set matches": {
+			Ours: InlineCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: InlineCertificateSpec{
+					Certificate: "certificate",
+					PrivateKey:  "private-key",
+				},
+			},
+			Theirs: &capi.InlineCertificateConfigEntry{
+				Kind:        capi.InlineCertificate,
+				Name:        "name",
+				Certificate: "certificate",
+				PrivateKey:  "private-key",
+			},
+			Matches: true,
+		},
+		"mismatched types does not match": {
+			Ours: InlineCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.APIGatewayConfigEntry{
+				Kind: capi.APIGateway,
+				Name: "name",
+			},
+			Matches: false,
+		},
+		"different certificate does not match": {
+			Ours: InlineCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: InlineCertificateSpec{
+					Certificate: "certificate",
+					PrivateKey:  "private-key",
+				},
+			},
+			Theirs: &capi.InlineCertificateConfigEntry{
+				Kind:        capi.InlineCertificate,
+				Name:        "name",
+				Certificate: "other-certificate",
+				PrivateKey:  "private-key",
+			},
+			Matches: false,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.Matches, c.Ours.MatchesConsul(c.Theirs))
+		})
+	}
+}
+
+func TestInlineCertificate_ToConsul(t *testing.T) {
+	certificate := &InlineCertificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "name",
+		},
+		Spec: InlineCertificateSpec{
+			Certificate: "certificate",
+			PrivateKey:  "private-key",
+		},
+	}
+	require.Equal(t, &capi.InlineCertificateConfigEntry{
+		Kind:        capi.InlineCertificate,
+		Name:        "name",
+		Certificate: "certificate",
+		PrivateKey:  "private-key",
+		Meta: map[string]string{
+			common.SourceKey:     common.SourceValue,
+			common.DatacenterKey: "datacenter",
+		},
+	}, certificate.ToConsul("datacenter"))
+}
+
+func TestInlineCertificate_SetSecret(t *testing.T) {
+	_, keyPem, certPem, _, err := cert.GenerateCA("test")
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		secret      *corev1.Secret
+		expectedErr string
+	}{
+		"valid": {
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "tls"},
+				Type:       corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					corev1.TLSCertKey:       []byte(certPem),
+					corev1.TLSPrivateKeyKey: []byte(keyPem),
+				},
+			},
+		},
+		"wrong type": {
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "tls"},
+				Type:       corev1.SecretTypeOpaque,
+			},
+			expectedErr: `secret "tls" must be of type kubernetes.io/tls`,
+		},
+		"invalid key pair": {
+			secret: &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "tls"},
+				Type:       corev1.SecretTypeTLS,
+				Data: map[string][]byte{
+					corev1.TLSCertKey:       []byte(certPem),
+					corev1.TLSPrivateKeyKey: []byte("invalid"),
+				},
+			},
+			expectedErr: `secret "tls" does not contain a valid certificate and private key`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			certificate := &InlineCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: InlineCertificateSpec{
+					SecretRef: &InlineCertificateSecretRef{Name: "tls"},
+				},
+			}
+			err := certificate.SetSecret(c.secret)
+			entry := certificate.ToConsul("datacenter").(*capi.InlineCertificateConfigEntry)
+			if c.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expectedErr)
+				require.Empty(t, entry.Certificate)
+				require.Empty(t, entry.PrivateKey)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, certPem, entry.Certificate)
+			require.Equal(t, keyPem, entry.PrivateKey)
+			require.True(t, certificate.MatchesConsul(entry))
+		})
+	}
+}
+
+func TestInlineCertificate_Validate(t *testing.T) {
+	_, keyPem, certPem, _, err := cert.GenerateCA("test")
+	require.NoError(t, err)
+
+	cases := map[string]struct {
+		input           *InlineCertificate
+		expectedErrMsgs []string
+	}{
+		"valid inline": {
+			input: &InlineCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: InlineCertificateSpec{
+					Certificate: certPem,
+					PrivateKey:  keyPem,
+				},
+			},
+		},
+		"valid secretRef": {
+			input: &InlineCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: InlineCertificateSpec{
+					SecretRef: &InlineCertificateSecretRef{Name: "tls"},
+				},
+			},
+		},
+		"nothing set": {
+			input: &InlineCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.certificate: Required value: certificate or secretRef must be set`,
+			},
+		},
+		"privateKey missing": {
+			input: &InlineCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: InlineCertificateSpec{
+					Certificate: certPem,
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.privateKey: Required value: privateKey must be set if certificate is set`,
+			},
+		},
+		"invalid key pair": {
+			input: &InlineCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: InlineCertificateSpec{
+					Certificate: certPem,
+					PrivateKey:  "invalid",
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.certificate: Invalid value: "<certificate>": certificate and privateKey are not a valid key pair`,
+			},
+		},
+		"secretRef and inline set": {
+			input: &InlineCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: InlineCertificateSpec{
+					Certificate: certPem,
+					SecretRef:   &InlineCertificateSecretRef{Name: "tls"},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.secretRef: Invalid value: "tls": secretRef cannot be set if certificate or privateKey is set`,
+			},
+		},
+		"secretRef without name": {
+			input: &InlineCertificate{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: InlineCertificateSpec{
+					SecretRef: &InlineCertificateSecretRef{},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.secretRef.name: Required value: name is required`,
+			},
+		},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := testCase.input.Validate(common.ConsulMeta{})
+			if len(testCase.expectedErrMsgs) != 0 {
+				require.Error(t, err)
+				for _, s := range testCase.expectedErrMsgs {
+					require.Contains(t, err.Error(), s)
+				}
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
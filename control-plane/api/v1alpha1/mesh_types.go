@@ -97,6 +97,10 @@ func (in *TransparentProxyMeshConfig) toConsul() capi.TransparentProxyMeshConfig
 	return capi.TransparentProxyMeshConfig{MeshDestinationsOnly: in.MeshDestinationsOnly}
 }
 
+func transparentProxyMeshConfigFromConsul(in capi.TransparentProxyMeshConfig) TransparentProxyMeshConfig {
+	return TransparentProxyMeshConfig{MeshDestinationsOnly: in.MeshDestinationsOnly}
+}
+
 func (in *Mesh) GetObjectMeta() metav1.ObjectMeta {
 	return in.ObjectMeta
 }
@@ -176,6 +180,24 @@ func (in *Mesh) SetLastSyncedTime(time *metav1.Time) {
 	in.Status.LastSyncedTime = time
 }
 
+func (in *Mesh) SetDriftedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.setCondition(Condition{
+		Type:               ConditionDrifted,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func (in *Mesh) SetLastSyncedGeneration(generation int64) {
+	in.Status.LastSyncedGeneration = generation
+}
+
+func (in *Mesh) LastSyncedGeneration() int64 {
+	return in.Status.LastSyncedGeneration
+}
+
 func (in *Mesh) ToConsul(datacenter string) capi.ConfigEntry {
 	return &capi.MeshConfigEntry{
 		TransparentProxy: in.Spec.TransparentProxy.toConsul(),
@@ -185,6 +207,21 @@ func (in *Mesh) ToConsul(datacenter string) capi.ConfigEntry {
 	}
 }
 
+// MeshFromConsul converts the Consul config entry into a Mesh resource. It's
+// the reverse of ToConsul.
+func MeshFromConsul(entry *capi.MeshConfigEntry) *Mesh {
+	return &Mesh{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: entry.GetName(),
+		},
+		Spec: MeshSpec{
+			TransparentProxy: transparentProxyMeshConfigFromConsul(entry.TransparentProxy),
+			TLS:              meshTLSConfigFromConsul(entry.TLS),
+			HTTP:             meshHTTPConfigFromConsul(entry.HTTP),
+		},
+	}
+}
+
 func (in *Mesh) MatchesConsul(candidate capi.ConfigEntry) bool {
 	configEntry, ok := candidate.(*capi.MeshConfigEntry)
 	if !ok {
@@ -218,6 +255,16 @@ func (in *MeshTLSConfig) toConsul() *capi.MeshTLSConfig {
 	}
 }
 
+func meshTLSConfigFromConsul(in *capi.MeshTLSConfig) *MeshTLSConfig {
+	if in == nil {
+		return nil
+	}
+	return &MeshTLSConfig{
+		Incoming: meshDirectionalTLSConfigFromConsul(in.Incoming),
+		Outgoing: meshDirectionalTLSConfigFromConsul(in.Outgoing),
+	}
+}
+
 func (in *MeshHTTPConfig) toConsul() *capi.MeshHTTPConfig {
 	if in == nil {
 		return nil
@@ -227,6 +274,15 @@ func (in *MeshHTTPConfig) toConsul() *capi.MeshHTTPConfig {
 	}
 }
 
+func meshHTTPConfigFromConsul(in *capi.MeshHTTPConfig) *MeshHTTPConfig {
+	if in == nil {
+		return nil
+	}
+	return &MeshHTTPConfig{
+		SanitizeXForwardedClientCert: in.SanitizeXForwardedClientCert,
+	}
+}
+
 func (in *MeshTLSConfig) validate(path *field.Path) field.ErrorList {
 	if in == nil {
 		return nil
@@ -266,6 +322,17 @@ func (in *MeshDirectionalTLSConfig) toConsul() *capi.MeshDirectionalTLSConfig {
 	}
 }
 
+func meshDirectionalTLSConfigFromConsul(in *capi.MeshDirectionalTLSConfig) *MeshDirectionalTLSConfig {
+	if in == nil {
+		return nil
+	}
+	return &MeshDirectionalTLSConfig{
+		TLSMinVersion: in.TLSMinVersion,
+		TLSMaxVersion: in.TLSMaxVersion,
+		CipherSuites:  in.CipherSuites,
+	}
+}
+
 // DefaultNamespaceFields has no behaviour here as meshes have no namespace specific fields.
 func (in *Mesh) DefaultNamespaceFields(_ common.ConsulMeta) {
 }
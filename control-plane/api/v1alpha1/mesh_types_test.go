@@ -193,6 +193,86 @@ func TestMesh_ToConsul(t *testing.T) {
 	}
 }
 
+func TestMeshFromConsul(t *testing.T) {
+	cases := map[string]struct {
+		Consul *capi.MeshConfigEntry
+		Exp    Mesh
+	}{
+		"empty fields": {
+			Consul: &capi.MeshConfigEntry{
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: Mesh{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "mesh",
+				},
+				Spec: MeshSpec{},
+			},
+		},
+		"every field set": {
+			Consul: &capi.MeshConfigEntry{
+				TransparentProxy: capi.TransparentProxyMeshConfig{
+					MeshDestinationsOnly: true,
+				},
+				TLS: &capi.MeshTLSConfig{
+					Incoming: &capi.MeshDirectionalTLSConfig{
+						TLSMinVersion: "TLSv1_0",
+						TLSMaxVersion: "TLSv1_1",
+						CipherSuites:  []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "AES128-SHA"},
+					},
+					Outgoing: &capi.MeshDirectionalTLSConfig{
+						TLSMinVersion: "TLSv1_0",
+						TLSMaxVersion: "TLSv1_1",
+						CipherSuites:  []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "AES128-SHA"},
+					},
+				},
+				HTTP: &capi.MeshHTTPConfig{
+					SanitizeXForwardedClientCert: true,
+				},
+				Namespace: "",
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: Mesh{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "mesh",
+				},
+				Spec: MeshSpec{
+					TransparentProxy: TransparentProxyMeshConfig{
+						MeshDestinationsOnly: true,
+					},
+					TLS: &MeshTLSConfig{
+						Incoming: &MeshDirectionalTLSConfig{
+							TLSMinVersion: "TLSv1_0",
+							TLSMaxVersion: "TLSv1_1",
+							CipherSuites:  []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "AES128-SHA"},
+						},
+						Outgoing: &MeshDirectionalTLSConfig{
+							TLSMinVersion: "TLSv1_0",
+							TLSMaxVersion: "TLSv1_1",
+							CipherSuites:  []string{"ECDHE-ECDSA-AES128-GCM-SHA256", "AES128-SHA"},
+						},
+					},
+					HTTP: &MeshHTTPConfig{
+						SanitizeXForwardedClientCert: true,
+					},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, &c.Exp, MeshFromConsul(c.Consul))
+		})
+	}
+}
+
 func TestMesh_Validate(t *testing.T) {
 	cases := map[string]struct {
 		input           *Mesh
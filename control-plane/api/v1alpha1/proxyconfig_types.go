@@ -0,0 +1,253 @@
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+const ProxyConfigKubeKind = "proxyconfigs"
+
+func init() {
+	SchemeBuilder.Register(&ProxyConfig{}, &ProxyConfigList{})
+}
+
+//+kubebuilder:object:root=true
+
+// ProxyConfig is the Schema for the proxyconfigs API. It configures the
+// sidecar proxies of the connect-injected pods it selects in its namespace.
+// Pod annotations take precedence over the settings of a ProxyConfig.
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="The age of the resource"
+// +kubebuilder:resource:shortName="proxy-config"
+type ProxyConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ProxyConfigSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ProxyConfigList contains a list of ProxyConfig.
+type ProxyConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ProxyConfig `json:"items"`
+}
+
+// ProxyConfigSpec defines the desired state of ProxyConfig.
+type ProxyConfigSpec struct {
+	// Selector selects the pods the config applies to by their labels.
+	// If both Selector and ServiceAccountName are empty, the config applies
+	// to all pods in its namespace.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	// ServiceAccountName selects the pods running as the service account
+	// the config applies to.
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// Upstreams are the upstreams of the proxy. They are only used if the
+	// pod doesn't have the connect-service-upstreams annotation.
+	Upstreams []ProxyConfigUpstream `json:"upstreams,omitempty"`
+	// Resources are the CPU and memory requests and limits of the Envoy
+	// sidecar container.
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Concurrency is the number of Envoy worker threads.
+	Concurrency *int `json:"concurrency,omitempty"`
+	// EnvoyExtraArgs are additional command line arguments passed to Envoy.
+	EnvoyExtraArgs []string `json:"envoyExtraArgs,omitempty"`
+	// Metrics configures the Prometheus metrics of the proxy.
+	Metrics *ProxyConfigMetrics `json:"metrics,omitempty"`
+	// TransparentProxy configures the traffic excluded from redirection to
+	// the proxy in transparent proxy mode.
+	TransparentProxy *ProxyConfigTransparentProxy `json:"transparentProxy,omitempty"`
+}
+
+// ProxyConfigUpstream is an upstream of a proxy.
+type ProxyConfigUpstream struct {
+	// Name is the name of the upstream service.
+	Name string `json:"name,omitempty"`
+	// Namespace is the Consul namespace of the upstream service.
+	Namespace string `json:"namespace,omitempty"`
+	// Partition is the Consul admin partition of the upstream service.
+	Partition string `json:"partition,omitempty"`
+	// Peer is the name of the cluster peer of the upstream service.
+	Peer string `json:"peer,omitempty"`
+	// Datacenter is the datacenter of the upstream service.
+	Datacenter string `json:"datacenter,omitempty"`
+	// PreparedQuery is the name of a prepared query to use as the upstream
+	// instead of a service.
+	PreparedQuery string `json:"preparedQuery,omitempty"`
+	// LocalBindPort is the port the upstream is exposed on localhost.
+	LocalBindPort int `json:"localBindPort"`
+}
+
+// ProxyConfigMetrics configures the Prometheus metrics of a proxy.
+type ProxyConfigMetrics struct {
+	// Enabled exposes the Envoy metrics for Prometheus.
+	Enabled *bool `json:"enabled,omitempty"`
+	// EnableMerging merges the metrics of the application with the Envoy
+	// metrics.
+	EnableMerging *bool `json:"enableMerging,omitempty"`
+	// MergedMetricsPort is the port the merged metrics are served on.
+	MergedMetricsPort int `json:"mergedMetricsPort,omitempty"`
+	// PrometheusScrapePort is the port Prometheus scrapes the metrics from.
+	PrometheusScrapePort int `json:"prometheusScrapePort,omitempty"`
+	// PrometheusScrapePath is the path Prometheus scrapes the metrics from.
+	PrometheusScrapePath string `json:"prometheusScrapePath,omitempty"`
+	// ServiceMetricsPort is the port the application serves its metrics on.
+	ServiceMetricsPort int `json:"serviceMetricsPort,omitempty"`
+	// ServiceMetricsPath is the path the application serves its metrics on.
+	ServiceMetricsPath string `json:"serviceMetricsPath,omitempty"`
+}
+
+// ProxyConfigTransparentProxy configures the traffic excluded from
+// redirection to a proxy in transparent proxy mode.
+type ProxyConfigTransparentProxy struct {
+	// ExcludeInboundPorts are the inbound ports that aren't redirected.
+	ExcludeInboundPorts []int `json:"excludeInboundPorts,omitempty"`
+	// ExcludeOutboundPorts are the outbound ports that aren't redirected.
+	ExcludeOutboundPorts []int `json:"excludeOutboundPorts,omitempty"`
+	// ExcludeOutboundCIDRs are the outbound IPs or CIDRs that aren't
+	// redirected.
+	ExcludeOutboundCIDRs []string `json:"excludeOutboundCIDRs,omitempty"`
+	// ExcludeUIDs are the user IDs whose outbound traffic isn't redirected.
+	ExcludeUIDs []int `json:"excludeUIDs,omitempty"`
+}
+
+func (in *ProxyConfig) KubeKind() string {
+	return ProxyConfigKubeKind
+}
+
+func (in *ProxyConfig) KubernetesName() string {
+	return in.ObjectMeta.Name
+}
+
+// Matches returns true if the config applies to the given pod. The pod
+// must be in the namespace of the config.
+func (in *ProxyConfig) Matches(pod corev1.Pod) bool {
+	if in.Spec.ServiceAccountName != "" && in.Spec.ServiceAccountName != pod.Spec.ServiceAccountName {
+		return false
+	}
+	if in.Spec.Selector == nil {
+		return true
+	}
+	selector, err := metav1.LabelSelectorAsSelector(in.Spec.Selector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(pod.Labels))
+}
+
+func (in *ProxyConfig) Validate() error {
+	var errs field.ErrorList
+	path := field.NewPath("spec")
+
+	if in.Spec.Selector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(in.Spec.Selector); err != nil {
+			errs = append(errs, field.Invalid(path.Child("selector"), in.Spec.Selector, err.Error()))
+		}
+	}
+
+	ports := make(map[int]int)
+	for i, u := range in.Spec.Upstreams {
+		errs = append(errs, u.validate(path.Child("upstreams").Index(i))...)
+		if j, ok := ports[u.LocalBindPort]; ok {
+			errs = append(errs, field.Invalid(path.Child("upstreams").Index(i).Child("localBindPort"), u.LocalBindPort,
+				fmt.Sprintf("localBindPort is already used by upstream %d", j)))
+		}
+		ports[u.LocalBindPort] = i
+	}
+
+	if in.Spec.Resources != nil {
+		for name, list := range map[string]corev1.ResourceList{"requests": in.Spec.Resources.Requests, "limits": in.Spec.Resources.Limits} {
+			for resource := range list {
+				if resource != corev1.ResourceCPU && resource != corev1.ResourceMemory {
+					errs = append(errs, field.NotSupported(path.Child("resources").Child(name).Key(string(resource)), string(resource),
+						[]string{string(corev1.ResourceCPU), string(corev1.ResourceMemory)}))
+				}
+			}
+		}
+	}
+
+	if in.Spec.Concurrency != nil && *in.Spec.Concurrency < 0 {
+		errs = append(errs, field.Invalid(path.Child("concurrency"), *in.Spec.Concurrency, "concurrency must be 0 or greater"))
+	}
+
+	if m := in.Spec.Metrics; m != nil {
+		metricsPath := path.Child("metrics")
+		errs = append(errs, validateOptionalPort(metricsPath.Child("mergedMetricsPort"), m.MergedMetricsPort)...)
+		errs = append(errs, validateOptionalPort(metricsPath.Child("prometheusScrapePort"), m.PrometheusScrapePort)...)
+		errs = append(errs, validateOptionalPort(metricsPath.Child("serviceMetricsPort"), m.ServiceMetricsPort)...)
+	}
+
+	if t := in.Spec.TransparentProxy; t != nil {
+		tproxyPath := path.Child("transparentProxy")
+		for i, port := range t.ExcludeInboundPorts {
+			errs = append(errs, validatePort(tproxyPath.Child("excludeInboundPorts").Index(i), port)...)
+		}
+		for i, port := range t.ExcludeOutboundPorts {
+			errs = append(errs, validatePort(tproxyPath.Child("excludeOutboundPorts").Index(i), port)...)
+		}
+		for i, cidr := range t.ExcludeOutboundCIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+				errs = append(errs, field.Invalid(tproxyPath.Child("excludeOutboundCIDRs").Index(i), cidr, "must be an IP address or CIDR"))
+			}
+		}
+		for i, uid := range t.ExcludeUIDs {
+			if uid < 0 {
+				errs = append(errs, field.Invalid(tproxyPath.Child("excludeUIDs").Index(i), uid, "must be 0 or greater"))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return apierrors.NewInvalid(
+			schema.GroupKind{Group: ConsulHashicorpGroup, Kind: ProxyConfigKubeKind},
+			in.KubernetesName(), errs)
+	}
+	return nil
+}
+
+func (in ProxyConfigUpstream) validate(path *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if in.PreparedQuery != "" {
+		if in.Name != "" || in.Namespace != "" || in.Partition != "" || in.Peer != "" || in.Datacenter != "" {
+			errs = append(errs, field.Invalid(path.Child("preparedQuery"), in.PreparedQuery,
+				"preparedQuery cannot be set with name, namespace, partition, peer or datacenter"))
+		}
+	} else if in.Name == "" {
+		errs = append(errs, field.Required(path.Child("name"), "name or preparedQuery must be set"))
+	}
+
+	var targets int
+	for _, v := range []string{in.Partition, in.Peer, in.Datacenter} {
+		if v != "" {
+			targets++
+		}
+	}
+	if targets > 1 {
+		errs = append(errs, field.Invalid(path, in, "only one of partition, peer or datacenter can be set"))
+	}
+
+	errs = append(errs, validatePort(path.Child("localBindPort"), in.LocalBindPort)...)
+	return errs
+}
+
+func validatePort(path *field.Path, port int) field.ErrorList {
+	if port < 1 || port > 65535 {
+		return field.ErrorList{field.Invalid(path, port, "must be between 1 and 65535")}
+	}
+	return nil
+}
+
+func validateOptionalPort(path *field.Path, port int) field.ErrorList {
+	if port == 0 {
+		return nil
+	}
+	return validatePort(path, port)
+}
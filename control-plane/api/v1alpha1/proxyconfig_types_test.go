@@ -0,0 +1,167 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestProxyConfig_Validate(t *testing.T) {
+	cases := map[string]struct {
+		proxyConfig     *ProxyConfig
+		expectedErrMsgs []string
+	}{
+		"empty": {
+			proxyConfig: &ProxyConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "web",
+				},
+			},
+		},
+		"valid": {
+			proxyConfig: &ProxyConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "web",
+				},
+				Spec: ProxyConfigSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+					Upstreams: []ProxyConfigUpstream{
+						{Name: "db", Namespace: "ns", LocalBindPort: 1234},
+						{Name: "api", Peer: "peer1", LocalBindPort: 1235},
+						{PreparedQuery: "query", LocalBindPort: 1236},
+					},
+					Resources: &corev1.ResourceRequirements{
+						Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+					},
+					Concurrency: intPointer(2),
+					Metrics:     &ProxyConfigMetrics{MergedMetricsPort: 20100},
+					TransparentProxy: &ProxyConfigTransparentProxy{
+						ExcludeInboundPorts:  []int{8080},
+						ExcludeOutboundPorts: []int{5432},
+						ExcludeOutboundCIDRs: []string{"10.0.0.0/8", "1.1.1.1"},
+						ExcludeUIDs:          []int{5996},
+					},
+				},
+			},
+		},
+		"invalid upstreams": {
+			proxyConfig: &ProxyConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "web",
+				},
+				Spec: ProxyConfigSpec{
+					Upstreams: []ProxyConfigUpstream{
+						{LocalBindPort: 1234},
+						{Name: "db", Peer: "peer1", Datacenter: "dc2", LocalBindPort: 1234},
+						{Name: "db", PreparedQuery: "query", LocalBindPort: 70000},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.upstreams[0].name: Required value: name or preparedQuery must be set`,
+				`spec.upstreams[1]: Invalid value`,
+				`only one of partition, peer or datacenter can be set`,
+				`spec.upstreams[1].localBindPort: Invalid value: 1234: localBindPort is already used by upstream 0`,
+				`spec.upstreams[2].preparedQuery: Invalid value: "query": preparedQuery cannot be set with name, namespace, partition, peer or datacenter`,
+				`spec.upstreams[2].localBindPort: Invalid value: 70000: must be between 1 and 65535`,
+			},
+		},
+		"invalid proxy settings": {
+			proxyConfig: &ProxyConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "web",
+				},
+				Spec: ProxyConfigSpec{
+					Selector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "app", Operator: "Bogus"}}},
+					Resources: &corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+					},
+					Concurrency: intPointer(-1),
+					Metrics:     &ProxyConfigMetrics{PrometheusScrapePort: -1},
+					TransparentProxy: &ProxyConfigTransparentProxy{
+						ExcludeInboundPorts:  []int{0},
+						ExcludeOutboundCIDRs: []string{"not-a-cidr"},
+						ExcludeUIDs:          []int{-1},
+					},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.selector: Invalid value`,
+				`spec.resources.requests[storage]: Unsupported value: "storage"`,
+				`spec.concurrency: Invalid value: -1: concurrency must be 0 or greater`,
+				`spec.metrics.prometheusScrapePort: Invalid value: -1: must be between 1 and 65535`,
+				`spec.transparentProxy.excludeInboundPorts[0]: Invalid value: 0: must be between 1 and 65535`,
+				`spec.transparentProxy.excludeOutboundCIDRs[0]: Invalid value: "not-a-cidr": must be an IP address or CIDR`,
+				`spec.transparentProxy.excludeUIDs[0]: Invalid value: -1: must be 0 or greater`,
+			},
+		},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := testCase.proxyConfig.Validate()
+			if len(testCase.expectedErrMsgs) != 0 {
+				require.Error(t, err)
+				for _, s := range testCase.expectedErrMsgs {
+					require.Contains(t, err.Error(), s)
+				}
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestProxyConfig_Matches(t *testing.T) {
+	pod := corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: map[string]string{"app": "web"},
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName: "web",
+		},
+	}
+
+	cases := map[string]struct {
+		spec ProxyConfigSpec
+		exp  bool
+	}{
+		"empty spec matches all pods": {
+			spec: ProxyConfigSpec{},
+			exp:  true,
+		},
+		"matching selector": {
+			spec: ProxyConfigSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}},
+			exp:  true,
+		},
+		"non-matching selector": {
+			spec: ProxyConfigSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}}},
+			exp:  false,
+		},
+		"matching service account": {
+			spec: ProxyConfigSpec{ServiceAccountName: "web"},
+			exp:  true,
+		},
+		"non-matching service account": {
+			spec: ProxyConfigSpec{ServiceAccountName: "api"},
+			exp:  false,
+		},
+		"matching service account with non-matching selector": {
+			spec: ProxyConfigSpec{
+				ServiceAccountName: "web",
+				Selector:           &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+			},
+			exp: false,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			proxyConfig := &ProxyConfig{Spec: c.spec}
+			require.Equal(t, c.exp, proxyConfig.Matches(pod))
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:object:generate=false
+
+type ProxyConfigWebhook struct {
+	client.Client
+	Logger  logr.Logger
+	decoder *admission.Decoder
+}
+
+// NOTE: The path value in the below line is the path to the webhook.
+// If it is updated, run code-gen, update subcommand/inject-connect/command.go
+// and the consul-helm value for the path to the webhook.
+//
+// NOTE: The below line cannot be combined with any other comment. If it is
+// it will break the code generation.
+//
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-v1alpha1-proxyconfigs,mutating=true,failurePolicy=fail,groups=consul.hashicorp.com,resources=proxyconfigs,versions=v1alpha1,name=mutate-proxyconfigs.consul.hashicorp.com,sideEffects=None,admissionReviewVersions=v1beta1;v1
+
+func (v *ProxyConfigWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var proxyConfig ProxyConfig
+	var proxyConfigList ProxyConfigList
+	err := v.decoder.Decode(req, &proxyConfig)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if err := proxyConfig.Validate(); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	v.Logger.Info("validate", "operation", req.Operation, "name", proxyConfig.KubernetesName())
+
+	if err := v.Client.List(ctx, &proxyConfigList, client.InNamespace(req.Namespace)); err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	for _, item := range proxyConfigList.Items {
+		if item.Name == proxyConfig.Name {
+			continue
+		}
+		// If another config selects exactly the same pods it's ambiguous
+		// which one applies, so reject it.
+		if item.Spec.ServiceAccountName == proxyConfig.Spec.ServiceAccountName &&
+			equality.Semantic.DeepEqual(item.Spec.Selector, proxyConfig.Spec.Selector) {
+			return admission.Errored(http.StatusBadRequest,
+				fmt.Errorf("an existing ProxyConfig resource has the same selector and service account `name: %s, namespace: %s`", item.Name, req.Namespace))
+		}
+	}
+
+	return admission.Allowed(fmt.Sprintf("valid %s request", proxyConfig.KubeKind()))
+}
+
+func (v *ProxyConfigWebhook) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
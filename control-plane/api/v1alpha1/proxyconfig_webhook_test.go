@@ -0,0 +1,168 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidateProxyConfig(t *testing.T) {
+	webSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+
+	cases := map[string]struct {
+		existingResources []runtime.Object
+		newResource       *ProxyConfig
+		operation         admissionv1.Operation
+		expAllow          bool
+		expErrMessage     string
+	}{
+		"valid, different selector": {
+			existingResources: []runtime.Object{&ProxyConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "api",
+					Namespace: "default",
+				},
+				Spec: ProxyConfigSpec{
+					Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+				},
+			}},
+			newResource: &ProxyConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+				Spec: ProxyConfigSpec{
+					Selector: webSelector,
+				},
+			},
+			operation: admissionv1.Create,
+			expAllow:  true,
+		},
+		"valid, same selector, different namespace": {
+			existingResources: []runtime.Object{&ProxyConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "other",
+				},
+				Spec: ProxyConfigSpec{
+					Selector: webSelector,
+				},
+			}},
+			newResource: &ProxyConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web2",
+					Namespace: "default",
+				},
+				Spec: ProxyConfigSpec{
+					Selector: webSelector,
+				},
+			},
+			operation: admissionv1.Create,
+			expAllow:  true,
+		},
+		"valid, update of the same resource": {
+			existingResources: []runtime.Object{&ProxyConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+				Spec: ProxyConfigSpec{
+					Selector: webSelector,
+				},
+			}},
+			newResource: &ProxyConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+				Spec: ProxyConfigSpec{
+					Selector:    webSelector,
+					Concurrency: intPointer(2),
+				},
+			},
+			operation: admissionv1.Update,
+			expAllow:  true,
+		},
+		"invalid, same selector and service account": {
+			existingResources: []runtime.Object{&ProxyConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+				Spec: ProxyConfigSpec{
+					Selector:           webSelector,
+					ServiceAccountName: "web",
+				},
+			}},
+			newResource: &ProxyConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web2",
+					Namespace: "default",
+				},
+				Spec: ProxyConfigSpec{
+					Selector:           webSelector,
+					ServiceAccountName: "web",
+				},
+			},
+			operation:     admissionv1.Create,
+			expAllow:      false,
+			expErrMessage: "an existing ProxyConfig resource has the same selector and service account `name: web, namespace: default`",
+		},
+		"invalid spec": {
+			newResource: &ProxyConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+				Spec: ProxyConfigSpec{
+					Upstreams: []ProxyConfigUpstream{{Name: "db"}},
+				},
+			},
+			operation:     admissionv1.Create,
+			expAllow:      false,
+			expErrMessage: "proxyconfigs.consul.hashicorp.com \"web\" is invalid: spec.upstreams[0].localBindPort: Invalid value: 0: must be between 1 and 65535",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			marshalledRequestObject, err := json.Marshal(c.newResource)
+			require.NoError(t, err)
+			s := runtime.NewScheme()
+			s.AddKnownTypes(GroupVersion, &ProxyConfig{}, &ProxyConfigList{})
+			client := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(c.existingResources...).Build()
+			decoder, err := admission.NewDecoder(s)
+			require.NoError(t, err)
+
+			validator := &ProxyConfigWebhook{
+				Client:  client,
+				Logger:  logrtest.TestLogger{T: t},
+				decoder: decoder,
+			}
+			response := validator.Handle(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Name:      c.newResource.KubernetesName(),
+					Namespace: "default",
+					Operation: c.operation,
+					Object: runtime.RawExtension{
+						Raw: marshalledRequestObject,
+					},
+				},
+			})
+
+			require.Equal(t, c.expAllow, response.Allowed)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
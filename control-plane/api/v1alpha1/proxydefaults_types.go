@@ -156,6 +156,24 @@ func (in *ProxyDefaults) SetLastSyncedTime(time *metav1.Time) {
 	in.Status.LastSyncedTime = time
 }
 
+func (in *ProxyDefaults) SetDriftedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.setCondition(Condition{
+		Type:               ConditionDrifted,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func (in *ProxyDefaults) SetLastSyncedGeneration(generation int64) {
+	in.Status.LastSyncedGeneration = generation
+}
+
+func (in *ProxyDefaults) LastSyncedGeneration() int64 {
+	return in.Status.LastSyncedGeneration
+}
+
 func (in *ProxyDefaults) ToConsul(datacenter string) capi.ConfigEntry {
 	consulConfig := in.convertConfig()
 	return &capi.ProxyConfigEntry{
@@ -169,6 +187,29 @@ func (in *ProxyDefaults) ToConsul(datacenter string) capi.ConfigEntry {
 	}
 }
 
+// ProxyDefaultsFromConsul converts the Consul config entry into a
+// ProxyDefaults resource. It's the reverse of ToConsul.
+func ProxyDefaultsFromConsul(entry *capi.ProxyConfigEntry) *ProxyDefaults {
+	var config json.RawMessage
+	if entry.Config != nil {
+		// We explicitly ignore the error returned by Marshal because the
+		// config has been unmarshalled from JSON by the Consul API.
+		config, _ = json.Marshal(entry.Config)
+	}
+	return &ProxyDefaults{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: entry.Name,
+		},
+		Spec: ProxyDefaultsSpec{
+			Mode:             proxyModeFromConsul(entry.Mode),
+			TransparentProxy: transparentProxyFromConsul(entry.TransparentProxy),
+			Config:           config,
+			MeshGateway:      meshGatewayFromConsul(entry.MeshGateway),
+			Expose:           exposeFromConsul(entry.Expose),
+		},
+	}
+}
+
 func (in *ProxyDefaults) MatchesConsul(candidate api.ConfigEntry) bool {
 	configEntry, ok := candidate.(*capi.ProxyConfigEntry)
 	if !ok {
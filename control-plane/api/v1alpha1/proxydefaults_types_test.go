@@ -286,6 +286,106 @@ func TestProxyDefaults_ToConsul(t *testing.T) {
 	}
 }
 
+func TestProxyDefaultsFromConsul(t *testing.T) {
+	cases := map[string]struct {
+		Consul *capi.ProxyConfigEntry
+		Exp    ProxyDefaults
+	}{
+		"empty fields": {
+			Consul: &capi.ProxyConfigEntry{
+				Name: "name",
+				Kind: capi.ProxyDefaults,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ProxyDefaultsSpec{},
+			},
+		},
+		"every field set": {
+			Consul: &capi.ProxyConfigEntry{
+				Kind:      capi.ProxyDefaults,
+				Name:      "name",
+				Namespace: "",
+				Config: map[string]interface{}{
+					"envoy_tracing_json": "{\"http\":{\"name\":\"envoy.zipkin\",\"config\":{\"collector_cluster\":\"zipkin\",\"collector_endpoint\":\"/api/v1/spans\",\"shared_span_context\":false}}}",
+				},
+				MeshGateway: capi.MeshGatewayConfig{
+					Mode: capi.MeshGatewayModeRemote,
+				},
+				Expose: capi.ExposeConfig{
+					Checks: true,
+					Paths: []capi.ExposePath{
+						{
+							ListenerPort:  80,
+							Path:          "/default",
+							LocalPathPort: 9091,
+							Protocol:      "tcp",
+						},
+						{
+							ListenerPort:  8080,
+							Path:          "/v2",
+							LocalPathPort: 3001,
+							Protocol:      "https",
+						},
+					},
+				},
+				TransparentProxy: &capi.TransparentProxyConfig{
+					OutboundListenerPort: 1000,
+					DialedDirectly:       true,
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ProxyDefaultsSpec{
+					Config: json.RawMessage(`{"envoy_tracing_json":"{\"http\":{\"name\":\"envoy.zipkin\",\"config\":{\"collector_cluster\":\"zipkin\",\"collector_endpoint\":\"/api/v1/spans\",\"shared_span_context\":false}}}"}`),
+					MeshGateway: MeshGateway{
+						Mode: "remote",
+					},
+					Expose: Expose{
+						Checks: true,
+						Paths: []ExposePath{
+							{
+								ListenerPort:  80,
+								Path:          "/default",
+								LocalPathPort: 9091,
+								Protocol:      "tcp",
+							},
+							{
+								ListenerPort:  8080,
+								Path:          "/v2",
+								LocalPathPort: 3001,
+								Protocol:      "https",
+							},
+						},
+					},
+					TransparentProxy: &TransparentProxy{
+						OutboundListenerPort: 1000,
+						DialedDirectly:       true,
+					},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, &c.Exp, ProxyDefaultsFromConsul(c.Consul))
+		})
+	}
+}
+
 // Test validation for fields other than Config. Config is tested
 // in separate tests below.
 func TestProxyDefaults_Validate(t *testing.T) {
@@ -208,6 +208,24 @@ func (in *ServiceDefaults) SetLastSyncedTime(time *metav1.Time) {
 	in.Status.LastSyncedTime = time
 }
 
+func (in *ServiceDefaults) SetDriftedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.setCondition(Condition{
+		Type:               ConditionDrifted,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func (in *ServiceDefaults) SetLastSyncedGeneration(generation int64) {
+	in.Status.LastSyncedGeneration = generation
+}
+
+func (in *ServiceDefaults) LastSyncedGeneration() int64 {
+	return in.Status.LastSyncedGeneration
+}
+
 func (in *ServiceDefaults) SyncedCondition() (status corev1.ConditionStatus, reason string, message string) {
 	cond := in.Status.GetCondition(ConditionSynced)
 	if cond == nil {
@@ -239,6 +257,25 @@ func (in *ServiceDefaults) ToConsul(datacenter string) capi.ConfigEntry {
 	}
 }
 
+// ServiceDefaultsFromConsul converts the Consul config entry into a
+// ServiceDefaults resource. It's the reverse of ToConsul.
+func ServiceDefaultsFromConsul(entry *capi.ServiceConfigEntry) *ServiceDefaults {
+	return &ServiceDefaults{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: entry.Name,
+		},
+		Spec: ServiceDefaultsSpec{
+			Protocol:         entry.Protocol,
+			Mode:             proxyModeFromConsul(entry.Mode),
+			TransparentProxy: transparentProxyFromConsul(entry.TransparentProxy),
+			MeshGateway:      meshGatewayFromConsul(entry.MeshGateway),
+			Expose:           exposeFromConsul(entry.Expose),
+			ExternalSNI:      entry.ExternalSNI,
+			UpstreamConfig:   upstreamsFromConsul(entry.UpstreamConfig),
+		},
+	}
+}
+
 // Validate validates the fields provided in the spec of the ServiceDefaults and
 // returns an error which lists all invalid fields in the resource spec.
 func (in *ServiceDefaults) Validate(consulMeta common.ConsulMeta) error {
@@ -298,6 +335,18 @@ func (in *Upstreams) toConsul() *capi.UpstreamConfiguration {
 	return upstreams
 }
 
+func upstreamsFromConsul(in *capi.UpstreamConfiguration) *Upstreams {
+	if in == nil {
+		return nil
+	}
+	upstreams := &Upstreams{}
+	upstreams.Defaults = upstreamFromConsul(in.Defaults)
+	for _, override := range in.Overrides {
+		upstreams.Overrides = append(upstreams.Overrides, upstreamFromConsul(override))
+	}
+	return upstreams
+}
+
 func (in *Upstream) validate(path *field.Path, kind string, partitionsEnabled bool) field.ErrorList {
 	if in == nil {
 		return nil
@@ -340,6 +389,24 @@ func (in *Upstream) toConsul() *capi.UpstreamConfig {
 	}
 }
 
+func upstreamFromConsul(in *capi.UpstreamConfig) *Upstream {
+	if in == nil {
+		return nil
+	}
+	return &Upstream{
+		Name:               in.Name,
+		Namespace:          in.Namespace,
+		Partition:          in.Partition,
+		EnvoyListenerJSON:  in.EnvoyListenerJSON,
+		EnvoyClusterJSON:   in.EnvoyClusterJSON,
+		Protocol:           in.Protocol,
+		ConnectTimeoutMs:   in.ConnectTimeoutMs,
+		Limits:             upstreamLimitsFromConsul(in.Limits),
+		PassiveHealthCheck: passiveHealthCheckFromConsul(in.PassiveHealthCheck),
+		MeshGateway:        meshGatewayFromConsul(in.MeshGateway),
+	}
+}
+
 func (in *UpstreamLimits) toConsul() *capi.UpstreamLimits {
 	if in == nil {
 		return nil
@@ -351,6 +418,17 @@ func (in *UpstreamLimits) toConsul() *capi.UpstreamLimits {
 	}
 }
 
+func upstreamLimitsFromConsul(in *capi.UpstreamLimits) *UpstreamLimits {
+	if in == nil {
+		return nil
+	}
+	return &UpstreamLimits{
+		MaxConnections:        in.MaxConnections,
+		MaxPendingRequests:    in.MaxPendingRequests,
+		MaxConcurrentRequests: in.MaxConcurrentRequests,
+	}
+}
+
 func (in *PassiveHealthCheck) toConsul() *capi.PassiveHealthCheck {
 	if in == nil {
 		return nil
@@ -361,6 +439,16 @@ func (in *PassiveHealthCheck) toConsul() *capi.PassiveHealthCheck {
 	}
 }
 
+func passiveHealthCheckFromConsul(in *capi.PassiveHealthCheck) *PassiveHealthCheck {
+	if in == nil {
+		return nil
+	}
+	return &PassiveHealthCheck{
+		Interval:    metav1.Duration{Duration: in.Interval},
+		MaxFailures: in.MaxFailures,
+	}
+}
+
 // DefaultNamespaceFields has no behaviour here as service-defaults have no namespace specific fields.
 func (in *ServiceDefaults) DefaultNamespaceFields(_ common.ConsulMeta) {
 }
@@ -251,6 +251,245 @@ func TestServiceDefaults_ToConsul(t *testing.T) {
 	}
 }
 
+func TestServiceDefaultsFromConsul(t *testing.T) {
+	cases := map[string]struct {
+		input    *capi.ServiceConfigEntry
+		expected *ServiceDefaults
+	}{
+		"empty fields": {
+			&capi.ServiceConfigEntry{
+				Name: "foo",
+				Kind: capi.ServiceDefaults,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			&ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: ServiceDefaultsSpec{},
+			},
+		},
+		"every field set": {
+			&capi.ServiceConfigEntry{
+				Kind:     capi.ServiceDefaults,
+				Name:     "foo",
+				Protocol: "https",
+				MeshGateway: capi.MeshGatewayConfig{
+					Mode: capi.MeshGatewayModeLocal,
+				},
+				Expose: capi.ExposeConfig{
+					Checks: true,
+					Paths: []capi.ExposePath{
+						{
+							ListenerPort:  80,
+							Path:          "/path",
+							LocalPathPort: 9000,
+							Protocol:      "tcp",
+						},
+						{
+							ListenerPort:  8080,
+							Path:          "/another-path",
+							LocalPathPort: 9091,
+							Protocol:      "http2",
+						},
+					},
+				},
+				ExternalSNI: "external-sni",
+				TransparentProxy: &capi.TransparentProxyConfig{
+					OutboundListenerPort: 1000,
+					DialedDirectly:       true,
+				},
+				UpstreamConfig: &capi.UpstreamConfiguration{
+					Defaults: &capi.UpstreamConfig{
+						Name:              "upstream-default",
+						Namespace:         "ns",
+						Partition:         "part",
+						EnvoyListenerJSON: `{"key": "value"}`,
+						EnvoyClusterJSON:  `{"key": "value"}`,
+						Protocol:          "http2",
+						ConnectTimeoutMs:  10,
+						Limits: &capi.UpstreamLimits{
+							MaxConnections:        intPointer(10),
+							MaxPendingRequests:    intPointer(10),
+							MaxConcurrentRequests: intPointer(10),
+						},
+						PassiveHealthCheck: &capi.PassiveHealthCheck{
+							Interval:    2 * time.Second,
+							MaxFailures: uint32(20),
+						},
+						MeshGateway: capi.MeshGatewayConfig{
+							Mode: "local",
+						},
+					},
+					Overrides: []*capi.UpstreamConfig{
+						{
+							Name:              "upstream-override-1",
+							Namespace:         "ns",
+							Partition:         "part",
+							EnvoyListenerJSON: `{"key": "value"}`,
+							EnvoyClusterJSON:  `{"key": "value"}`,
+							Protocol:          "http2",
+							ConnectTimeoutMs:  15,
+							Limits: &capi.UpstreamLimits{
+								MaxConnections:        intPointer(5),
+								MaxPendingRequests:    intPointer(5),
+								MaxConcurrentRequests: intPointer(5),
+							},
+							PassiveHealthCheck: &capi.PassiveHealthCheck{
+								Interval:    2 * time.Second,
+								MaxFailures: uint32(10),
+							},
+							MeshGateway: capi.MeshGatewayConfig{
+								Mode: "remote",
+							},
+						},
+						{
+							Name:              "upstream-default",
+							Namespace:         "ns",
+							Partition:         "part",
+							EnvoyListenerJSON: `{"key": "value"}`,
+							EnvoyClusterJSON:  `{"key": "value"}`,
+							Protocol:          "http2",
+							ConnectTimeoutMs:  10,
+							Limits: &capi.UpstreamLimits{
+								MaxConnections:        intPointer(2),
+								MaxPendingRequests:    intPointer(2),
+								MaxConcurrentRequests: intPointer(2),
+							},
+							PassiveHealthCheck: &capi.PassiveHealthCheck{
+								Interval:    2 * time.Second,
+								MaxFailures: uint32(10),
+							},
+							MeshGateway: capi.MeshGatewayConfig{
+								Mode: "remote",
+							},
+						},
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			&ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: ServiceDefaultsSpec{
+					Protocol: "https",
+					MeshGateway: MeshGateway{
+						Mode: "local",
+					},
+					Expose: Expose{
+						Checks: true,
+						Paths: []ExposePath{
+							{
+								ListenerPort:  80,
+								Path:          "/path",
+								LocalPathPort: 9000,
+								Protocol:      "tcp",
+							},
+							{
+								ListenerPort:  8080,
+								Path:          "/another-path",
+								LocalPathPort: 9091,
+								Protocol:      "http2",
+							},
+						},
+					},
+					ExternalSNI: "external-sni",
+					TransparentProxy: &TransparentProxy{
+						OutboundListenerPort: 1000,
+						DialedDirectly:       true,
+					},
+					UpstreamConfig: &Upstreams{
+						Defaults: &Upstream{
+							Name:              "upstream-default",
+							Namespace:         "ns",
+							Partition:         "part",
+							EnvoyListenerJSON: `{"key": "value"}`,
+							EnvoyClusterJSON:  `{"key": "value"}`,
+							Protocol:          "http2",
+							ConnectTimeoutMs:  10,
+							Limits: &UpstreamLimits{
+								MaxConnections:        intPointer(10),
+								MaxPendingRequests:    intPointer(10),
+								MaxConcurrentRequests: intPointer(10),
+							},
+							PassiveHealthCheck: &PassiveHealthCheck{
+								Interval: metav1.Duration{
+									Duration: 2 * time.Second,
+								},
+								MaxFailures: uint32(20),
+							},
+							MeshGateway: MeshGateway{
+								Mode: "local",
+							},
+						},
+						Overrides: []*Upstream{
+							{
+								Name:              "upstream-override-1",
+								Namespace:         "ns",
+								Partition:         "part",
+								EnvoyListenerJSON: `{"key": "value"}`,
+								EnvoyClusterJSON:  `{"key": "value"}`,
+								Protocol:          "http2",
+								ConnectTimeoutMs:  15,
+								Limits: &UpstreamLimits{
+									MaxConnections:        intPointer(5),
+									MaxPendingRequests:    intPointer(5),
+									MaxConcurrentRequests: intPointer(5),
+								},
+								PassiveHealthCheck: &PassiveHealthCheck{
+									Interval: metav1.Duration{
+										Duration: 2 * time.Second,
+									},
+									MaxFailures: uint32(10),
+								},
+								MeshGateway: MeshGateway{
+									Mode: "remote",
+								},
+							},
+							{
+								Name:              "upstream-default",
+								Namespace:         "ns",
+								Partition:         "part",
+								EnvoyListenerJSON: `{"key": "value"}`,
+								EnvoyClusterJSON:  `{"key": "value"}`,
+								Protocol:          "http2",
+								ConnectTimeoutMs:  10,
+								Limits: &UpstreamLimits{
+									MaxConnections:        intPointer(2),
+									MaxPendingRequests:    intPointer(2),
+									MaxConcurrentRequests: intPointer(2),
+								},
+								PassiveHealthCheck: &PassiveHealthCheck{
+									Interval: metav1.Duration{
+										Duration: 2 * time.Second,
+									},
+									MaxFailures: uint32(10),
+								},
+								MeshGateway: MeshGateway{
+									Mode: "remote",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, testCase.expected, ServiceDefaultsFromConsul(testCase.input))
+		})
+	}
+}
+
 func TestServiceDefaults_MatchesConsul(t *testing.T) {
 	cases := map[string]struct {
 		internal *ServiceDefaults
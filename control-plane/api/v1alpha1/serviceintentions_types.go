@@ -198,6 +198,24 @@ func (in *ServiceIntentions) SetLastSyncedTime(time *metav1.Time) {
 	in.Status.LastSyncedTime = time
 }
 
+func (in *ServiceIntentions) SetDriftedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.setCondition(Condition{
+		Type:               ConditionDrifted,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func (in *ServiceIntentions) SetLastSyncedGeneration(generation int64) {
+	in.Status.LastSyncedGeneration = generation
+}
+
+func (in *ServiceIntentions) LastSyncedGeneration() int64 {
+	return in.Status.LastSyncedGeneration
+}
+
 func (in *ServiceIntentions) SyncedCondition() (status corev1.ConditionStatus, reason, message string) {
 	cond := in.Status.GetCondition(ConditionSynced)
 	if cond == nil {
@@ -224,6 +242,28 @@ func (in *ServiceIntentions) ToConsul(datacenter string) api.ConfigEntry {
 	}
 }
 
+// ServiceIntentionsFromConsul converts the Consul config entry into a
+// ServiceIntentions resource. It's the reverse of ToConsul. The name of the
+// resource is the name of the destination service.
+func ServiceIntentionsFromConsul(entry *capi.ServiceIntentionsConfigEntry) *ServiceIntentions {
+	var sources SourceIntentions
+	for _, source := range entry.Sources {
+		sources = append(sources, sourceIntentionFromConsul(source))
+	}
+	return &ServiceIntentions{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: entry.Name,
+		},
+		Spec: ServiceIntentionsSpec{
+			Destination: Destination{
+				Name:      entry.Name,
+				Namespace: entry.Namespace,
+			},
+			Sources: sources,
+		},
+	}
+}
+
 func (in *ServiceIntentions) ConsulGlobalResource() bool {
 	return false
 }
@@ -320,6 +360,28 @@ func (in *SourceIntention) toConsul() *capi.SourceIntention {
 	}
 }
 
+func sourceIntentionFromConsul(in *capi.SourceIntention) *SourceIntention {
+	if in == nil {
+		return nil
+	}
+	var permissions IntentionPermissions
+	for _, permission := range in.Permissions {
+		permissions = append(permissions, &IntentionPermission{
+			Action: IntentionAction(permission.Action),
+			HTTP:   intentionHTTPPermissionFromConsul(permission.HTTP),
+		})
+	}
+	return &SourceIntention{
+		Name:        in.Name,
+		Namespace:   in.Namespace,
+		Partition:   in.Partition,
+		Peer:        in.Peer,
+		Action:      IntentionAction(in.Action),
+		Permissions: permissions,
+		Description: in.Description,
+	}
+}
+
 func (in IntentionAction) toConsul() capi.IntentionAction {
 	return capi.IntentionAction(in)
 }
@@ -348,6 +410,31 @@ func (in *IntentionHTTPPermission) toConsul() *capi.IntentionHTTPPermission {
 	}
 }
 
+func intentionHTTPPermissionFromConsul(in *capi.IntentionHTTPPermission) *IntentionHTTPPermission {
+	if in == nil {
+		return nil
+	}
+	var headerPermissions IntentionHTTPHeaderPermissions
+	for _, permission := range in.Header {
+		headerPermissions = append(headerPermissions, IntentionHTTPHeaderPermission{
+			Name:    permission.Name,
+			Present: permission.Present,
+			Exact:   permission.Exact,
+			Prefix:  permission.Prefix,
+			Suffix:  permission.Suffix,
+			Regex:   permission.Regex,
+			Invert:  permission.Invert,
+		})
+	}
+	return &IntentionHTTPPermission{
+		PathExact:  in.PathExact,
+		PathPrefix: in.PathPrefix,
+		PathRegex:  in.PathRegex,
+		Header:     headerPermissions,
+		Methods:    in.Methods,
+	}
+}
+
 func (in IntentionHTTPHeaderPermissions) toConsul() []capi.IntentionHTTPHeaderPermission {
 	var headerPermissions []capi.IntentionHTTPHeaderPermission
 	for _, permission := range in {
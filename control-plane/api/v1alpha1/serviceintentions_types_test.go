@@ -368,6 +368,152 @@ func TestServiceIntentions_ToConsul(t *testing.T) {
 	}
 }
 
+func TestServiceIntentionsFromConsul(t *testing.T) {
+	cases := map[string]struct {
+		Consul *capi.ServiceIntentionsConfigEntry
+		Exp    ServiceIntentions
+	}{
+		"empty fields": {
+			Consul: &capi.ServiceIntentionsConfigEntry{
+				Name: "",
+				Kind: capi.ServiceIntentions,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: ServiceIntentions{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "",
+				},
+				Spec: ServiceIntentionsSpec{},
+			},
+		},
+		"every field set": {
+			Consul: &capi.ServiceIntentionsConfigEntry{
+				Kind:      capi.ServiceIntentions,
+				Name:      "svc-name",
+				Namespace: "dest-ns",
+				Sources: []*capi.SourceIntention{
+					{
+						Name:        "svc1",
+						Namespace:   "test",
+						Partition:   "test",
+						Action:      "allow",
+						Description: "allow access from svc1",
+					},
+					{
+						Name:        "*",
+						Namespace:   "not-test",
+						Partition:   "not-test",
+						Action:      "deny",
+						Description: "disallow access from namespace not-test",
+					},
+					{
+						Name:      "svc-2",
+						Namespace: "bar",
+						Partition: "bar",
+						Permissions: []*capi.IntentionPermission{
+							{
+								Action: "allow",
+								HTTP: &capi.IntentionHTTPPermission{
+									PathExact:  "/foo",
+									PathPrefix: "/bar",
+									PathRegex:  "/baz",
+									Header: []capi.IntentionHTTPHeaderPermission{
+										{
+											Name:    "header",
+											Present: true,
+											Exact:   "exact",
+											Prefix:  "prefix",
+											Suffix:  "suffix",
+											Regex:   "regex",
+											Invert:  true,
+										},
+									},
+									Methods: []string{
+										"GET",
+										"PUT",
+									},
+								},
+							},
+						},
+						Description: "an L7 config",
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: ServiceIntentions{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "svc-name",
+				},
+				Spec: ServiceIntentionsSpec{
+					Destination: Destination{
+						Name:      "svc-name",
+						Namespace: "dest-ns",
+					},
+					Sources: []*SourceIntention{
+						{
+							Name:        "svc1",
+							Namespace:   "test",
+							Partition:   "test",
+							Action:      "allow",
+							Description: "allow access from svc1",
+						},
+						{
+							Name:        "*",
+							Namespace:   "not-test",
+							Partition:   "not-test",
+							Action:      "deny",
+							Description: "disallow access from namespace not-test",
+						},
+						{
+							Name:      "svc-2",
+							Namespace: "bar",
+							Partition: "bar",
+							Permissions: IntentionPermissions{
+								{
+									Action: "allow",
+									HTTP: &IntentionHTTPPermission{
+										PathExact:  "/foo",
+										PathPrefix: "/bar",
+										PathRegex:  "/baz",
+										Header: IntentionHTTPHeaderPermissions{
+											{
+												Name:    "header",
+												Present: true,
+												Exact:   "exact",
+												Prefix:  "prefix",
+												Suffix:  "suffix",
+												Regex:   "regex",
+												Invert:  true,
+											},
+										},
+										Methods: []string{
+											"GET",
+											"PUT",
+										},
+									},
+								},
+							},
+							Description: "an L7 config",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, &c.Exp, ServiceIntentionsFromConsul(c.Consul))
+		})
+	}
+}
+
 func TestServiceIntentions_AddFinalizer(t *testing.T) {
 	serviceIntentions := &ServiceIntentions{}
 	serviceIntentions.AddFinalizer("finalizer")
@@ -10,6 +10,8 @@ import (
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	capi "github.com/hashicorp/consul/api"
 	admissionv1 "k8s.io/api/admission/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
@@ -89,6 +91,11 @@ func (v *ServiceIntentionsWebhook) Handle(ctx context.Context, req admission.Req
 	if err := svcIntentions.Validate(v.ConsulMeta); err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
+	if err := v.CrossValidate(ctx, &svcIntentions); apierrors.IsInvalid(err) {
+		return admission.Errored(http.StatusBadRequest, err)
+	} else if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
 
 	// We always return an admission.Patched() response, even if there are no patches, since
 	// admission.Patched() with no patches is equal to admission.Allowed() under
@@ -96,6 +103,45 @@ func (v *ServiceIntentionsWebhook) Handle(ctx context.Context, req admission.Req
 	return admission.Patched(fmt.Sprintf("valid %s request", svcIntentions.KubeKind()), defaultingPatches...)
 }
 
+// CrossValidate returns an error if sources have permissions but the protocol
+// of the destination service set by the ServiceDefaults or ProxyDefaults
+// resources doesn't support L7 intentions.
+func (v *ServiceIntentionsWebhook) CrossValidate(ctx context.Context, cfgEntry common.ConfigEntryResource) error {
+	svcIntentions, ok := cfgEntry.(*ServiceIntentions)
+	if !ok {
+		return nil
+	}
+	destination := svcIntentions.Spec.Destination
+	if destination.Name == common.WildcardNamespace || destination.Namespace == common.WildcardNamespace {
+		return nil
+	}
+	var errs field.ErrorList
+	protocol := ""
+	for i, source := range svcIntentions.Spec.Sources {
+		if len(source.Permissions) == 0 {
+			continue
+		}
+		if protocol == "" {
+			consulNS := consulNamespace(svcIntentions.ConsulMirroringNS(), v.ConsulMeta)
+			if destination.Namespace != "" && v.ConsulMeta.NamespacesEnabled {
+				consulNS = destination.Namespace
+			}
+			var err error
+			protocol, err = serviceProtocol(ctx, v.Client, v.ConsulMeta, destination.Name, consulNS)
+			if err != nil {
+				return err
+			}
+			if protocol == "" || sliceContains(httpProtocols, protocol) {
+				return nil
+			}
+		}
+		errs = append(errs, field.Forbidden(field.NewPath("spec").Child("sources").Index(i).Child("permissions"),
+			fmt.Sprintf("protocol %q of the destination service set by ServiceDefaults or ProxyDefaults does not support permissions: protocol %s",
+				protocol, notInSliceMessage(httpProtocols))))
+	}
+	return crossValidationError(svcIntentions, errs)
+}
+
 func (v *ServiceIntentionsWebhook) InjectDecoder(d *admission.Decoder) error {
 	v.decoder = d
 	return nil
@@ -636,3 +636,139 @@ func TestHandle_ServiceIntentions_Patches(t *testing.T) {
 		}
 	}
 }
+
+func TestHandle_ServiceIntentions_CrossValidate(t *testing.T) {
+	intentionsWithPermissions := &ServiceIntentions{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "foo-intention",
+		},
+		Spec: ServiceIntentionsSpec{
+			Destination: Destination{
+				Name: "foo",
+			},
+			Sources: SourceIntentions{
+				{
+					Name: "bar",
+					Permissions: IntentionPermissions{
+						{
+							Action: "allow",
+							HTTP: &IntentionHTTPPermission{
+								PathPrefix: "/",
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		existingResources []runtime.Object
+		newResource       *ServiceIntentions
+		expAllow          bool
+		expErrMessage     string
+	}{
+		"permissions, no protocol set": {
+			newResource: intentionsWithPermissions,
+			expAllow:    true,
+		},
+		"permissions, http service defaults": {
+			existingResources: []runtime.Object{&ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       ServiceDefaultsSpec{Protocol: "http"},
+			}},
+			newResource: intentionsWithPermissions,
+			expAllow:    true,
+		},
+		"permissions, tcp service defaults": {
+			existingResources: []runtime.Object{&ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       ServiceDefaultsSpec{Protocol: "tcp"},
+			}},
+			newResource:   intentionsWithPermissions,
+			expAllow:      false,
+			expErrMessage: "serviceintentions.consul.hashicorp.com \"foo-intention\" is invalid: spec.sources[0].permissions: Forbidden: protocol \"tcp\" of the destination service set by ServiceDefaults or ProxyDefaults does not support permissions: protocol must be one of \"http\", \"http2\", \"grpc\"",
+		},
+		"permissions, tcp proxy defaults": {
+			existingResources: []runtime.Object{&ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: common.Global, Namespace: "default"},
+				Spec:       ProxyDefaultsSpec{Config: json.RawMessage(`{"protocol": "tcp"}`)},
+			}},
+			newResource:   intentionsWithPermissions,
+			expAllow:      false,
+			expErrMessage: "serviceintentions.consul.hashicorp.com \"foo-intention\" is invalid: spec.sources[0].permissions: Forbidden: protocol \"tcp\" of the destination service set by ServiceDefaults or ProxyDefaults does not support permissions: protocol must be one of \"http\", \"http2\", \"grpc\"",
+		},
+		"permissions, http service defaults overrides tcp proxy defaults": {
+			existingResources: []runtime.Object{
+				&ProxyDefaults{
+					ObjectMeta: metav1.ObjectMeta{Name: common.Global, Namespace: "default"},
+					Spec:       ProxyDefaultsSpec{Config: json.RawMessage(`{"protocol": "tcp"}`)},
+				},
+				&ServiceDefaults{
+					ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+					Spec:       ServiceDefaultsSpec{Protocol: "http"},
+				},
+			},
+			newResource: intentionsWithPermissions,
+			expAllow:    true,
+		},
+		"no permissions, tcp service defaults": {
+			existingResources: []runtime.Object{&ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       ServiceDefaultsSpec{Protocol: "tcp"},
+			}},
+			newResource: &ServiceIntentions{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo-intention",
+				},
+				Spec: ServiceIntentionsSpec{
+					Destination: Destination{
+						Name: "foo",
+					},
+					Sources: SourceIntentions{
+						{
+							Name:   "bar",
+							Action: "allow",
+						},
+					},
+				},
+			},
+			expAllow: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			marshalledRequestObject, err := json.Marshal(c.newResource)
+			require.NoError(t, err)
+			s := runtime.NewScheme()
+			s.AddKnownTypes(GroupVersion, &ServiceIntentions{}, &ServiceIntentionsList{}, &ServiceDefaults{}, &ServiceDefaultsList{}, &ProxyDefaults{}, &ProxyDefaultsList{})
+			client := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(c.existingResources...).Build()
+			decoder, err := admission.NewDecoder(s)
+			require.NoError(t, err)
+
+			validator := &ServiceIntentionsWebhook{
+				Client:       client,
+				ConsulClient: nil,
+				Logger:       logrtest.TestLogger{T: t},
+				decoder:      decoder,
+				ConsulMeta:   common.ConsulMeta{},
+			}
+			response := validator.Handle(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Name:      c.newResource.KubernetesName(),
+					Namespace: "default",
+					Operation: admissionv1.Create,
+					Object: runtime.RawExtension{
+						Raw: marshalledRequestObject,
+					},
+				},
+			})
+
+			require.Equal(t, c.expAllow, response.Allowed)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
@@ -249,6 +249,24 @@ func (in *ServiceResolver) SetLastSyncedTime(time *metav1.Time) {
 	in.Status.LastSyncedTime = time
 }
 
+func (in *ServiceResolver) SetDriftedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.setCondition(Condition{
+		Type:               ConditionDrifted,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func (in *ServiceResolver) SetLastSyncedGeneration(generation int64) {
+	in.Status.LastSyncedGeneration = generation
+}
+
+func (in *ServiceResolver) LastSyncedGeneration() int64 {
+	return in.Status.LastSyncedGeneration
+}
+
 func (in *ServiceResolver) SyncedCondition() (status corev1.ConditionStatus, reason string, message string) {
 	cond := in.Status.GetCondition(ConditionSynced)
 	if cond == nil {
@@ -280,6 +298,24 @@ func (in *ServiceResolver) ToConsul(datacenter string) capi.ConfigEntry {
 	}
 }
 
+// ServiceResolverFromConsul converts the Consul config entry into a
+// ServiceResolver resource. It's the reverse of ToConsul.
+func ServiceResolverFromConsul(entry *capi.ServiceResolverConfigEntry) *ServiceResolver {
+	return &ServiceResolver{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: entry.Name,
+		},
+		Spec: ServiceResolverSpec{
+			DefaultSubset:  entry.DefaultSubset,
+			Subsets:        serviceResolverSubsetMapFromConsul(entry.Subsets),
+			Redirect:       serviceResolverRedirectFromConsul(entry.Redirect),
+			Failover:       serviceResolverFailoverMapFromConsul(entry.Failover),
+			ConnectTimeout: metav1.Duration{Duration: entry.ConnectTimeout},
+			LoadBalancer:   loadBalancerFromConsul(entry.LoadBalancer),
+		},
+	}
+}
+
 func (in *ServiceResolver) MatchesConsul(candidate capi.ConfigEntry) bool {
 	configEntry, ok := candidate.(*capi.ServiceResolverConfigEntry)
 	if !ok {
@@ -338,6 +374,20 @@ func (in ServiceResolverSubset) toConsul() capi.ServiceResolverSubset {
 	}
 }
 
+func serviceResolverSubsetMapFromConsul(in map[string]capi.ServiceResolverSubset) ServiceResolverSubsetMap {
+	if in == nil {
+		return nil
+	}
+	m := make(ServiceResolverSubsetMap)
+	for k, v := range in {
+		m[k] = ServiceResolverSubset{
+			Filter:      v.Filter,
+			OnlyPassing: v.OnlyPassing,
+		}
+	}
+	return m
+}
+
 func (in *ServiceResolverRedirect) toConsul() *capi.ServiceResolverRedirect {
 	if in == nil {
 		return nil
@@ -350,6 +400,19 @@ func (in *ServiceResolverRedirect) toConsul() *capi.ServiceResolverRedirect {
 	}
 }
 
+func serviceResolverRedirectFromConsul(in *capi.ServiceResolverRedirect) *ServiceResolverRedirect {
+	if in == nil {
+		return nil
+	}
+	return &ServiceResolverRedirect{
+		Service:       in.Service,
+		ServiceSubset: in.ServiceSubset,
+		Namespace:     in.Namespace,
+		Partition:     in.Partition,
+		Datacenter:    in.Datacenter,
+	}
+}
+
 func (in ServiceResolverFailoverMap) toConsul() map[string]capi.ServiceResolverFailover {
 	if in == nil {
 		return nil
@@ -370,6 +433,22 @@ func (in ServiceResolverFailover) toConsul() capi.ServiceResolverFailover {
 	}
 }
 
+func serviceResolverFailoverMapFromConsul(in map[string]capi.ServiceResolverFailover) ServiceResolverFailoverMap {
+	if in == nil {
+		return nil
+	}
+	m := make(ServiceResolverFailoverMap)
+	for k, v := range in {
+		m[k] = ServiceResolverFailover{
+			Service:       v.Service,
+			ServiceSubset: v.ServiceSubset,
+			Namespace:     v.Namespace,
+			Datacenters:   v.Datacenters,
+		}
+	}
+	return m
+}
+
 func (in *LoadBalancer) toConsul() *capi.LoadBalancer {
 	if in == nil {
 		return nil
@@ -386,6 +465,38 @@ func (in *LoadBalancer) toConsul() *capi.LoadBalancer {
 	}
 }
 
+func loadBalancerFromConsul(in *capi.LoadBalancer) *LoadBalancer {
+	if in == nil {
+		return nil
+	}
+	var policies []HashPolicy
+	for _, p := range in.HashPolicies {
+		policies = append(policies, HashPolicy{
+			Field:        p.Field,
+			FieldValue:   p.FieldValue,
+			CookieConfig: cookieConfigFromConsul(p.CookieConfig),
+			SourceIP:     p.SourceIP,
+			Terminal:     p.Terminal,
+		})
+	}
+	lb := &LoadBalancer{
+		Policy:       in.Policy,
+		HashPolicies: policies,
+	}
+	if in.RingHashConfig != nil {
+		lb.RingHashConfig = &RingHashConfig{
+			MinimumRingSize: in.RingHashConfig.MinimumRingSize,
+			MaximumRingSize: in.RingHashConfig.MaximumRingSize,
+		}
+	}
+	if in.LeastRequestConfig != nil {
+		lb.LeastRequestConfig = &LeastRequestConfig{
+			ChoiceCount: in.LeastRequestConfig.ChoiceCount,
+		}
+	}
+	return lb
+}
+
 func (in *RingHashConfig) toConsul() *capi.RingHashConfig {
 	if in == nil {
 		return nil
@@ -427,6 +538,17 @@ func (in *CookieConfig) toConsul() *capi.CookieConfig {
 	}
 }
 
+func cookieConfigFromConsul(in *capi.CookieConfig) *CookieConfig {
+	if in == nil {
+		return nil
+	}
+	return &CookieConfig{
+		Session: in.Session,
+		TTL:     metav1.Duration{Duration: in.TTL},
+		Path:    in.Path,
+	}
+}
+
 func (in *CookieConfig) validate(path *field.Path) *field.Error {
 	if in == nil {
 		return nil
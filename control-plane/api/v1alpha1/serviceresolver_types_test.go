@@ -344,6 +344,163 @@ func TestServiceResolver_ToConsul(t *testing.T) {
 	}
 }
 
+func TestServiceResolverFromConsul(t *testing.T) {
+	cases := map[string]struct {
+		Consul *capi.ServiceResolverConfigEntry
+		Exp    ServiceResolver
+	}{
+		"empty fields": {
+			Consul: &capi.ServiceResolverConfigEntry{
+				Name: "name",
+				Kind: capi.ServiceResolver,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ServiceResolverSpec{},
+			},
+		},
+		"every field set": {
+			Consul: &capi.ServiceResolverConfigEntry{
+				Name:          "name",
+				Kind:          capi.ServiceResolver,
+				DefaultSubset: "default_subset",
+				Subsets: map[string]capi.ServiceResolverSubset{
+					"subset1": {
+						Filter:      "filter1",
+						OnlyPassing: true,
+					},
+					"subset2": {
+						Filter:      "filter2",
+						OnlyPassing: false,
+					},
+				},
+				Redirect: &capi.ServiceResolverRedirect{
+					Service:       "redirect",
+					ServiceSubset: "redirect_subset",
+					Namespace:     "redirect_namespace",
+					Datacenter:    "redirect_datacenter",
+				},
+				Failover: map[string]capi.ServiceResolverFailover{
+					"failover1": {
+						Service:       "failover1",
+						ServiceSubset: "failover_subset1",
+						Namespace:     "failover_namespace1",
+						Datacenters:   []string{"failover1_dc1", "failover1_dc2"},
+					},
+					"failover2": {
+						Service:       "failover2",
+						ServiceSubset: "failover_subset2",
+						Namespace:     "failover_namespace2",
+						Datacenters:   []string{"failover2_dc1", "failover2_dc2"},
+					},
+				},
+				ConnectTimeout: 1 * time.Second,
+				LoadBalancer: &capi.LoadBalancer{
+					Policy: "policy",
+					RingHashConfig: &capi.RingHashConfig{
+						MinimumRingSize: 1,
+						MaximumRingSize: 2,
+					},
+					LeastRequestConfig: &capi.LeastRequestConfig{
+						ChoiceCount: 1,
+					},
+					HashPolicies: []capi.HashPolicy{
+						{
+							Field:      "field",
+							FieldValue: "value",
+							CookieConfig: &capi.CookieConfig{
+								Session: true,
+								TTL:     1,
+								Path:    "path",
+							},
+							SourceIP: true,
+							Terminal: true,
+						},
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ServiceResolverSpec{
+					DefaultSubset: "default_subset",
+					Subsets: map[string]ServiceResolverSubset{
+						"subset1": {
+							Filter:      "filter1",
+							OnlyPassing: true,
+						},
+						"subset2": {
+							Filter:      "filter2",
+							OnlyPassing: false,
+						},
+					},
+					Redirect: &ServiceResolverRedirect{
+						Service:       "redirect",
+						ServiceSubset: "redirect_subset",
+						Namespace:     "redirect_namespace",
+						Datacenter:    "redirect_datacenter",
+					},
+					Failover: map[string]ServiceResolverFailover{
+						"failover1": {
+							Service:       "failover1",
+							ServiceSubset: "failover_subset1",
+							Namespace:     "failover_namespace1",
+							Datacenters:   []string{"failover1_dc1", "failover1_dc2"},
+						},
+						"failover2": {
+							Service:       "failover2",
+							ServiceSubset: "failover_subset2",
+							Namespace:     "failover_namespace2",
+							Datacenters:   []string{"failover2_dc1", "failover2_dc2"},
+						},
+					},
+					ConnectTimeout: metav1.Duration{Duration: 1 * time.Second},
+					LoadBalancer: &LoadBalancer{
+						Policy: "policy",
+						RingHashConfig: &RingHashConfig{
+							MinimumRingSize: 1,
+							MaximumRingSize: 2,
+						},
+						LeastRequestConfig: &LeastRequestConfig{
+							ChoiceCount: 1,
+						},
+						HashPolicies: []HashPolicy{
+							{
+								Field:      "field",
+								FieldValue: "value",
+								CookieConfig: &CookieConfig{
+									Session: true,
+									TTL:     metav1.Duration{Duration: 1},
+									Path:    "path",
+								},
+								SourceIP: true,
+								Terminal: true,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, &c.Exp, ServiceResolverFromConsul(c.Consul))
+		})
+	}
+}
+
 func TestServiceResolver_AddFinalizer(t *testing.T) {
 	serviceResolver := &ServiceResolver{}
 	serviceResolver.AddFinalizer("finalizer")
@@ -209,6 +209,24 @@ func (in *ServiceRouter) SetLastSyncedTime(time *metav1.Time) {
 	in.Status.LastSyncedTime = time
 }
 
+func (in *ServiceRouter) SetDriftedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.setCondition(Condition{
+		Type:               ConditionDrifted,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func (in *ServiceRouter) SetLastSyncedGeneration(generation int64) {
+	in.Status.LastSyncedGeneration = generation
+}
+
+func (in *ServiceRouter) LastSyncedGeneration() int64 {
+	return in.Status.LastSyncedGeneration
+}
+
 func (in *ServiceRouter) SyncedCondition() (status corev1.ConditionStatus, reason, message string) {
 	cond := in.Status.GetCondition(ConditionSynced)
 	if cond == nil {
@@ -242,6 +260,23 @@ func (in *ServiceRouter) ToConsul(datacenter string) capi.ConfigEntry {
 	}
 }
 
+// ServiceRouterFromConsul converts the Consul config entry into a
+// ServiceRouter resource. It's the reverse of ToConsul.
+func ServiceRouterFromConsul(entry *capi.ServiceRouterConfigEntry) *ServiceRouter {
+	var routes []ServiceRoute
+	for _, r := range entry.Routes {
+		routes = append(routes, serviceRouteFromConsul(r))
+	}
+	return &ServiceRouter{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: entry.Name,
+		},
+		Spec: ServiceRouterSpec{
+			Routes: routes,
+		},
+	}
+}
+
 func (in *ServiceRouter) MatchesConsul(candidate capi.ConfigEntry) bool {
 	configEntry, ok := candidate.(*capi.ServiceRouterConfigEntry)
 	if !ok {
@@ -294,6 +329,13 @@ func (in ServiceRoute) toConsul() capi.ServiceRoute {
 	}
 }
 
+func serviceRouteFromConsul(in capi.ServiceRoute) ServiceRoute {
+	return ServiceRoute{
+		Match:       serviceRouteMatchFromConsul(in.Match),
+		Destination: serviceRouteDestinationFromConsul(in.Destination),
+	}
+}
+
 func (in *ServiceRouteMatch) toConsul() *capi.ServiceRouteMatch {
 	if in == nil {
 		return nil
@@ -303,6 +345,15 @@ func (in *ServiceRouteMatch) toConsul() *capi.ServiceRouteMatch {
 	}
 }
 
+func serviceRouteMatchFromConsul(in *capi.ServiceRouteMatch) *ServiceRouteMatch {
+	if in == nil {
+		return nil
+	}
+	return &ServiceRouteMatch{
+		HTTP: serviceRouteHTTPMatchFromConsul(in.HTTP),
+	}
+}
+
 func (in ServiceRouteHTTPMatchHeader) toConsul() capi.ServiceRouteHTTPMatchHeader {
 	return capi.ServiceRouteHTTPMatchHeader{
 		Name:    in.Name,
@@ -343,6 +394,25 @@ func (in *ServiceRouteDestination) toConsul() *capi.ServiceRouteDestination {
 	}
 }
 
+func serviceRouteDestinationFromConsul(in *capi.ServiceRouteDestination) *ServiceRouteDestination {
+	if in == nil {
+		return nil
+	}
+	return &ServiceRouteDestination{
+		Service:               in.Service,
+		ServiceSubset:         in.ServiceSubset,
+		Namespace:             in.Namespace,
+		Partition:             in.Partition,
+		PrefixRewrite:         in.PrefixRewrite,
+		RequestTimeout:        metav1.Duration{Duration: in.RequestTimeout},
+		NumRetries:            in.NumRetries,
+		RetryOnConnectFailure: in.RetryOnConnectFailure,
+		RetryOnStatusCodes:    in.RetryOnStatusCodes,
+		RequestHeaders:        httpHeaderModifiersFromConsul(in.RequestHeaders),
+		ResponseHeaders:       httpHeaderModifiersFromConsul(in.ResponseHeaders),
+	}
+}
+
 func (in *ServiceRouter) validateEnterprise(consulMeta common.ConsulMeta) field.ErrorList {
 	var errs field.ErrorList
 	path := field.NewPath("spec")
@@ -409,6 +479,41 @@ func (in *ServiceRouteHTTPMatch) toConsul() *capi.ServiceRouteHTTPMatch {
 	}
 }
 
+func serviceRouteHTTPMatchFromConsul(in *capi.ServiceRouteHTTPMatch) *ServiceRouteHTTPMatch {
+	if in == nil {
+		return nil
+	}
+	var header []ServiceRouteHTTPMatchHeader
+	for _, h := range in.Header {
+		header = append(header, ServiceRouteHTTPMatchHeader{
+			Name:    h.Name,
+			Present: h.Present,
+			Exact:   h.Exact,
+			Prefix:  h.Prefix,
+			Suffix:  h.Suffix,
+			Regex:   h.Regex,
+			Invert:  h.Invert,
+		})
+	}
+	var query []ServiceRouteHTTPMatchQueryParam
+	for _, q := range in.QueryParam {
+		query = append(query, ServiceRouteHTTPMatchQueryParam{
+			Name:    q.Name,
+			Present: q.Present,
+			Exact:   q.Exact,
+			Regex:   q.Regex,
+		})
+	}
+	return &ServiceRouteHTTPMatch{
+		PathExact:  in.PathExact,
+		PathPrefix: in.PathPrefix,
+		PathRegex:  in.PathRegex,
+		Header:     header,
+		QueryParam: query,
+		Methods:    in.Methods,
+	}
+}
+
 func (in *ServiceRouteHTTPMatch) validate(path *field.Path) field.ErrorList {
 	var errs field.ErrorList
 	if in == nil {
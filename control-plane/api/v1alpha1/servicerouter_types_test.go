@@ -410,6 +410,195 @@ func TestServiceRouter_ToConsul(t *testing.T) {
 	}
 }
 
+func TestServiceRouterFromConsul(t *testing.T) {
+	cases := map[string]struct {
+		Consul *capi.ServiceRouterConfigEntry
+		Exp    ServiceRouter
+	}{
+		"empty fields": {
+			Consul: &capi.ServiceRouterConfigEntry{
+				Name: "name",
+				Kind: capi.ServiceRouter,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: ServiceRouter{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ServiceRouterSpec{},
+			},
+		},
+		"every field set": {
+			Consul: &capi.ServiceRouterConfigEntry{
+				Name: "name",
+				Kind: capi.ServiceRouter,
+				Routes: []capi.ServiceRoute{
+					{
+						Match: &capi.ServiceRouteMatch{
+							HTTP: &capi.ServiceRouteHTTPMatch{
+								PathExact:  "pathExact",
+								PathPrefix: "pathPrefix",
+								PathRegex:  "pathRegex",
+								Header: []capi.ServiceRouteHTTPMatchHeader{
+									{
+										Name:    "name",
+										Present: true,
+										Exact:   "exact",
+										Prefix:  "prefix",
+										Suffix:  "suffix",
+										Regex:   "regex",
+										Invert:  true,
+									},
+								},
+								QueryParam: []capi.ServiceRouteHTTPMatchQueryParam{
+									{
+										Name:    "name",
+										Present: true,
+										Exact:   "exact",
+										Regex:   "regex",
+									},
+								},
+								Methods: []string{"method1", "method2"},
+							},
+						},
+						Destination: &capi.ServiceRouteDestination{
+							Service:               "service",
+							ServiceSubset:         "serviceSubset",
+							Namespace:             "namespace",
+							PrefixRewrite:         "prefixRewrite",
+							RequestTimeout:        1 * time.Second,
+							NumRetries:            1,
+							RetryOnConnectFailure: true,
+							RetryOnStatusCodes:    []uint32{500, 400},
+							RequestHeaders: &capi.HTTPHeaderModifiers{
+								Add: map[string]string{
+									"foo":    "bar",
+									"source": "dest",
+								},
+								Set: map[string]string{
+									"bar": "baz",
+									"key": "car",
+								},
+								Remove: []string{
+									"foo",
+									"bar",
+									"baz",
+								},
+							},
+							ResponseHeaders: &capi.HTTPHeaderModifiers{
+								Add: map[string]string{
+									"doo":    "var",
+									"aource": "sest",
+								},
+								Set: map[string]string{
+									"var": "vaz",
+									"jey": "xar",
+								},
+								Remove: []string{
+									"doo",
+									"var",
+									"vaz",
+								},
+							},
+						},
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: ServiceRouter{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ServiceRouterSpec{
+					Routes: []ServiceRoute{
+						{
+							Match: &ServiceRouteMatch{
+								HTTP: &ServiceRouteHTTPMatch{
+									PathExact:  "pathExact",
+									PathPrefix: "pathPrefix",
+									PathRegex:  "pathRegex",
+									Header: []ServiceRouteHTTPMatchHeader{
+										{
+											Name:    "name",
+											Present: true,
+											Exact:   "exact",
+											Prefix:  "prefix",
+											Suffix:  "suffix",
+											Regex:   "regex",
+											Invert:  true,
+										},
+									},
+									QueryParam: []ServiceRouteHTTPMatchQueryParam{
+										{
+											Name:    "name",
+											Present: true,
+											Exact:   "exact",
+											Regex:   "regex",
+										},
+									},
+									Methods: []string{"method1", "method2"},
+								},
+							},
+							Destination: &ServiceRouteDestination{
+								Service:               "service",
+								ServiceSubset:         "serviceSubset",
+								Namespace:             "namespace",
+								PrefixRewrite:         "prefixRewrite",
+								RequestTimeout:        metav1.Duration{Duration: 1 * time.Second},
+								NumRetries:            1,
+								RetryOnConnectFailure: true,
+								RetryOnStatusCodes:    []uint32{500, 400},
+								RequestHeaders: &HTTPHeaderModifiers{
+									Add: map[string]string{
+										"foo":    "bar",
+										"source": "dest",
+									},
+									Set: map[string]string{
+										"bar": "baz",
+										"key": "car",
+									},
+									Remove: []string{
+										"foo",
+										"bar",
+										"baz",
+									},
+								},
+								ResponseHeaders: &HTTPHeaderModifiers{
+									Add: map[string]string{
+										"doo":    "var",
+										"aource": "sest",
+									},
+									Set: map[string]string{
+										"var": "vaz",
+										"jey": "xar",
+									},
+									Remove: []string{
+										"doo",
+										"var",
+										"vaz",
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, &c.Exp, ServiceRouterFromConsul(c.Consul))
+		})
+	}
+}
+
 func TestServiceRouter_AddFinalizer(t *testing.T) {
 	serviceRouter := &ServiceRouter{}
 	serviceRouter.AddFinalizer("finalizer")
@@ -54,6 +54,16 @@ func (v *ServiceRouterWebhook) List(ctx context.Context) ([]common.ConfigEntryRe
 	return entries, nil
 }
 
+// CrossValidate returns an error if the protocol of the service set by the
+// ServiceDefaults or ProxyDefaults resources doesn't support routing.
+func (v *ServiceRouterWebhook) CrossValidate(ctx context.Context, cfgEntry common.ConfigEntryResource) error {
+	errs, err := validateRoutingProtocol(ctx, v.Client, v.ConsulMeta, cfgEntry)
+	if err != nil {
+		return err
+	}
+	return crossValidationError(cfgEntry, errs)
+}
+
 func (v *ServiceRouterWebhook) InjectDecoder(d *admission.Decoder) error {
 	v.decoder = d
 	return nil
@@ -0,0 +1,120 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidateServiceRouter(t *testing.T) {
+	router := &ServiceRouter{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+		},
+		Spec: ServiceRouterSpec{
+			Routes: []ServiceRoute{
+				{
+					Match: &ServiceRouteMatch{
+						HTTP: &ServiceRouteHTTPMatch{
+							PathPrefix: "/admin",
+						},
+					},
+					Destination: &ServiceRouteDestination{
+						Service: "admin",
+					},
+				},
+			},
+		},
+	}
+
+	cases := map[string]struct {
+		existingResources []runtime.Object
+		expAllow          bool
+		expErrMessage     string
+	}{
+		"no protocol set": {
+			expAllow: true,
+		},
+		"http service defaults": {
+			existingResources: []runtime.Object{&ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       ServiceDefaultsSpec{Protocol: "http"},
+			}},
+			expAllow: true,
+		},
+		"grpc proxy defaults": {
+			existingResources: []runtime.Object{&ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: common.Global, Namespace: "default"},
+				Spec:       ProxyDefaultsSpec{Config: json.RawMessage(`{"protocol": "grpc"}`)},
+			}},
+			expAllow: true,
+		},
+		"tcp service defaults": {
+			existingResources: []runtime.Object{&ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+				Spec:       ServiceDefaultsSpec{Protocol: "tcp"},
+			}},
+			expAllow:      false,
+			expErrMessage: "servicerouter.consul.hashicorp.com \"foo\" is invalid: metadata.name: Invalid value: \"foo\": protocol \"tcp\" of the service set by ServiceDefaults or ProxyDefaults does not support service-router: protocol must be one of \"http\", \"http2\", \"grpc\"",
+		},
+		"tcp proxy defaults": {
+			existingResources: []runtime.Object{&ProxyDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: common.Global, Namespace: "default"},
+				Spec:       ProxyDefaultsSpec{Config: json.RawMessage(`{"protocol": "tcp"}`)},
+			}},
+			expAllow:      false,
+			expErrMessage: "servicerouter.consul.hashicorp.com \"foo\" is invalid: metadata.name: Invalid value: \"foo\": protocol \"tcp\" of the service set by ServiceDefaults or ProxyDefaults does not support service-router: protocol must be one of \"http\", \"http2\", \"grpc\"",
+		},
+		"tcp service defaults for another service": {
+			existingResources: []runtime.Object{&ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "default"},
+				Spec:       ServiceDefaultsSpec{Protocol: "tcp"},
+			}},
+			expAllow: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			marshalledRequestObject, err := json.Marshal(router)
+			require.NoError(t, err)
+			s := runtime.NewScheme()
+			s.AddKnownTypes(GroupVersion, &ServiceRouter{}, &ServiceRouterList{}, &ServiceDefaults{}, &ServiceDefaultsList{}, &ProxyDefaults{}, &ProxyDefaultsList{})
+			client := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(c.existingResources...).Build()
+			decoder, err := admission.NewDecoder(s)
+			require.NoError(t, err)
+
+			validator := &ServiceRouterWebhook{
+				Client:       client,
+				ConsulClient: nil,
+				Logger:       logrtest.TestLogger{T: t},
+				decoder:      decoder,
+			}
+			response := validator.Handle(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Name:      router.KubernetesName(),
+					Namespace: router.Namespace,
+					Operation: admissionv1.Create,
+					Object: runtime.RawExtension{
+						Raw: marshalledRequestObject,
+					},
+				},
+			})
+
+			require.Equal(t, c.expAllow, response.Allowed)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
@@ -127,6 +127,24 @@ func (in *ServiceSplitter) SetLastSyncedTime(time *metav1.Time) {
 	in.Status.LastSyncedTime = time
 }
 
+func (in *ServiceSplitter) SetDriftedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.setCondition(Condition{
+		Type:               ConditionDrifted,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func (in *ServiceSplitter) SetLastSyncedGeneration(generation int64) {
+	in.Status.LastSyncedGeneration = generation
+}
+
+func (in *ServiceSplitter) LastSyncedGeneration() int64 {
+	return in.Status.LastSyncedGeneration
+}
+
 func (in *ServiceSplitter) SyncedCondition() (status corev1.ConditionStatus, reason, message string) {
 	cond := in.Status.GetCondition(ConditionSynced)
 	if cond == nil {
@@ -152,6 +170,19 @@ func (in *ServiceSplitter) ToConsul(datacenter string) capi.ConfigEntry {
 	}
 }
 
+// ServiceSplitterFromConsul converts the Consul config entry into a
+// ServiceSplitter resource. It's the reverse of ToConsul.
+func ServiceSplitterFromConsul(entry *capi.ServiceSplitterConfigEntry) *ServiceSplitter {
+	return &ServiceSplitter{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: entry.Name,
+		},
+		Spec: ServiceSplitterSpec{
+			Splits: serviceSplitsFromConsul(entry.Splits),
+		},
+	}
+}
+
 func (in *ServiceSplitter) ConsulGlobalResource() bool {
 	return false
 }
@@ -208,6 +239,22 @@ func (in ServiceSplit) toConsul() capi.ServiceSplit {
 	}
 }
 
+func serviceSplitsFromConsul(in []capi.ServiceSplit) ServiceSplits {
+	var splits ServiceSplits
+	for _, split := range in {
+		splits = append(splits, ServiceSplit{
+			Weight:          split.Weight,
+			Service:         split.Service,
+			ServiceSubset:   split.ServiceSubset,
+			Namespace:       split.Namespace,
+			Partition:       split.Partition,
+			RequestHeaders:  httpHeaderModifiersFromConsul(split.RequestHeaders),
+			ResponseHeaders: httpHeaderModifiersFromConsul(split.ResponseHeaders),
+		})
+	}
+	return splits
+}
+
 func (in *ServiceSplitter) validateEnterprise(consulMeta common.ConsulMeta) field.ErrorList {
 	var errs field.ErrorList
 	path := field.NewPath("spec")
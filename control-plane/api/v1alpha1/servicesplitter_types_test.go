@@ -277,6 +277,129 @@ func TestServiceSplitter_ToConsul(t *testing.T) {
 	}
 }
 
+func TestServiceSplitterFromConsul(t *testing.T) {
+	cases := map[string]struct {
+		Consul *capi.ServiceSplitterConfigEntry
+		Exp    ServiceSplitter
+	}{
+		"empty fields": {
+			Consul: &capi.ServiceSplitterConfigEntry{
+				Name: "name",
+				Kind: capi.ServiceSplitter,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: ServiceSplitter{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ServiceSplitterSpec{},
+			},
+		},
+		"every field set": {
+			Consul: &capi.ServiceSplitterConfigEntry{
+				Name: "name",
+				Kind: capi.ServiceSplitter,
+				Splits: []capi.ServiceSplit{
+					{
+						Weight:        100,
+						Service:       "foo",
+						ServiceSubset: "bar",
+						Namespace:     "baz",
+						RequestHeaders: &capi.HTTPHeaderModifiers{
+							Add: map[string]string{
+								"foo":    "bar",
+								"source": "dest",
+							},
+							Set: map[string]string{
+								"bar": "baz",
+								"key": "car",
+							},
+							Remove: []string{
+								"foo",
+								"bar",
+								"baz",
+							},
+						},
+						ResponseHeaders: &capi.HTTPHeaderModifiers{
+							Add: map[string]string{
+								"doo":    "var",
+								"aource": "sest",
+							},
+							Set: map[string]string{
+								"var": "vaz",
+								"jey": "xar",
+							},
+							Remove: []string{
+								"doo",
+								"var",
+								"vaz",
+							},
+						},
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: ServiceSplitter{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: ServiceSplitterSpec{
+					Splits: []ServiceSplit{
+						{
+							Weight:        100,
+							Service:       "foo",
+							ServiceSubset: "bar",
+							Namespace:     "baz",
+							RequestHeaders: &HTTPHeaderModifiers{
+								Add: map[string]string{
+									"foo":    "bar",
+									"source": "dest",
+								},
+								Set: map[string]string{
+									"bar": "baz",
+									"key": "car",
+								},
+								Remove: []string{
+									"foo",
+									"bar",
+									"baz",
+								},
+							},
+							ResponseHeaders: &HTTPHeaderModifiers{
+								Add: map[string]string{
+									"doo":    "var",
+									"aource": "sest",
+								},
+								Set: map[string]string{
+									"var": "vaz",
+									"jey": "xar",
+								},
+								Remove: []string{
+									"doo",
+									"var",
+									"vaz",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, &c.Exp, ServiceSplitterFromConsul(c.Consul))
+		})
+	}
+}
+
 func TestServiceSplitter_AddFinalizer(t *testing.T) {
 	serviceSplitter := &ServiceSplitter{}
 	serviceSplitter.AddFinalizer("finalizer")
@@ -2,11 +2,13 @@ package v1alpha1
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/go-logr/logr"
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	capi "github.com/hashicorp/consul/api"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
@@ -55,6 +57,62 @@ func (v *ServiceSplitterWebhook) List(ctx context.Context) ([]common.ConfigEntry
 	return entries, nil
 }
 
+// CrossValidate returns an error if the protocol of the service set by the
+// ServiceDefaults or ProxyDefaults resources doesn't support splitting, or
+// if a split references a subset that isn't defined by the ServiceResolver of
+// its service.
+func (v *ServiceSplitterWebhook) CrossValidate(ctx context.Context, cfgEntry common.ConfigEntryResource) error {
+	splitter, ok := cfgEntry.(*ServiceSplitter)
+	if !ok {
+		return nil
+	}
+	errs, err := validateRoutingProtocol(ctx, v.Client, v.ConsulMeta, splitter)
+	if err != nil {
+		return err
+	}
+
+	var resolvers *ServiceResolverList
+	for i, split := range splitter.Spec.Splits {
+		// Resolvers in other partitions are managed by other clusters.
+		if split.ServiceSubset == "" || split.Partition != "" {
+			continue
+		}
+		if resolvers == nil {
+			resolvers = &ServiceResolverList{}
+			if err := v.Client.List(ctx, resolvers); err != nil {
+				return err
+			}
+		}
+		service := split.Service
+		if service == "" {
+			service = splitter.ConsulName()
+		}
+		ns := consulNamespace(splitter.ConsulMirroringNS(), v.ConsulMeta)
+		if split.Namespace != "" && v.ConsulMeta.NamespacesEnabled {
+			ns = split.Namespace
+		}
+		if !resolverDefinesSubset(resolvers, service, ns, split.ServiceSubset, v.ConsulMeta) {
+			errs = append(errs, field.Invalid(field.NewPath("spec").Child("splits").Index(i).Child("serviceSubset"), split.ServiceSubset,
+				fmt.Sprintf("subset is not defined by a ServiceResolver for service %q", service)))
+		}
+	}
+	return crossValidationError(splitter, errs)
+}
+
+// resolverDefinesSubset returns false if the ServiceResolver of the service
+// in the Consul namespace doesn't define the subset. It returns true if there
+// is no ServiceResolver for the service in the cluster since the resolver may
+// have been written in Consul directly.
+func resolverDefinesSubset(resolvers *ServiceResolverList, service, consulNS, subset string, consulMeta common.ConsulMeta) bool {
+	for _, item := range resolvers.Items {
+		if item.ConsulName() == service && consulNamespace(item.ConsulMirroringNS(), consulMeta) == consulNS {
+			_, ok := item.Spec.Subsets[subset]
+			return ok
+		}
+	}
+	return true
+}
+
 func (v *ServiceSplitterWebhook) InjectDecoder(d *admission.Decoder) error {
 	v.decoder = d
 	return nil
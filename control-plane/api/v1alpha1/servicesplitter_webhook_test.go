@@ -0,0 +1,130 @@
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidateServiceSplitter(t *testing.T) {
+	splitter := &ServiceSplitter{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+		},
+		Spec: ServiceSplitterSpec{
+			Splits: []ServiceSplit{
+				{
+					Weight:        90,
+					ServiceSubset: "v1",
+				},
+				{
+					Weight:        10,
+					ServiceSubset: "v2",
+				},
+			},
+		},
+	}
+	resolver := func(subsets ...string) *ServiceResolver {
+		subsetMap := ServiceResolverSubsetMap{}
+		for _, subset := range subsets {
+			subsetMap[subset] = ServiceResolverSubset{Filter: "Service.Meta.version == " + subset}
+		}
+		return &ServiceResolver{
+			ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+			Spec:       ServiceResolverSpec{Subsets: subsetMap},
+		}
+	}
+
+	cases := map[string]struct {
+		existingResources []runtime.Object
+		expAllow          bool
+		expErrMessage     string
+	}{
+		"subsets defined": {
+			existingResources: []runtime.Object{resolver("v1", "v2")},
+			expAllow:          true,
+		},
+		"subset not defined": {
+			existingResources: []runtime.Object{resolver("v1")},
+			expAllow:          false,
+			expErrMessage:     "servicesplitter.consul.hashicorp.com \"foo\" is invalid: spec.splits[1].serviceSubset: Invalid value: \"v2\": subset is not defined by a ServiceResolver for service \"foo\"",
+		},
+		"no service resolver": {
+			expAllow: true,
+		},
+		"service resolver of another service": {
+			existingResources: []runtime.Object{
+				&ServiceResolver{
+					ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "default"},
+					Spec:       ServiceResolverSpec{Subsets: ServiceResolverSubsetMap{"v3": {}}},
+				},
+			},
+			expAllow: true,
+		},
+		"tcp service defaults": {
+			existingResources: []runtime.Object{
+				resolver("v1", "v2"),
+				&ServiceDefaults{
+					ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+					Spec:       ServiceDefaultsSpec{Protocol: "tcp"},
+				},
+			},
+			expAllow:      false,
+			expErrMessage: "servicesplitter.consul.hashicorp.com \"foo\" is invalid: metadata.name: Invalid value: \"foo\": protocol \"tcp\" of the service set by ServiceDefaults or ProxyDefaults does not support service-splitter: protocol must be one of \"http\", \"http2\", \"grpc\"",
+		},
+		"http service defaults": {
+			existingResources: []runtime.Object{
+				resolver("v1", "v2"),
+				&ServiceDefaults{
+					ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+					Spec:       ServiceDefaultsSpec{Protocol: "http"},
+				},
+			},
+			expAllow: true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			marshalledRequestObject, err := json.Marshal(splitter)
+			require.NoError(t, err)
+			s := runtime.NewScheme()
+			s.AddKnownTypes(GroupVersion, &ServiceSplitter{}, &ServiceSplitterList{}, &ServiceResolver{}, &ServiceResolverList{},
+				&ServiceDefaults{}, &ServiceDefaultsList{}, &ProxyDefaults{}, &ProxyDefaultsList{})
+			client := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(c.existingResources...).Build()
+			decoder, err := admission.NewDecoder(s)
+			require.NoError(t, err)
+
+			validator := &ServiceSplitterWebhook{
+				Client:       client,
+				ConsulClient: nil,
+				Logger:       logrtest.TestLogger{T: t},
+				decoder:      decoder,
+			}
+			response := validator.Handle(ctx, admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Name:      splitter.KubernetesName(),
+					Namespace: splitter.Namespace,
+					Operation: admissionv1.Create,
+					Object: runtime.RawExtension{
+						Raw: marshalledRequestObject,
+					},
+				},
+			})
+
+			require.Equal(t, c.expAllow, response.Allowed)
+			if c.expErrMessage != "" {
+				require.Equal(t, c.expErrMessage, response.AdmissionResponse.Result.Message)
+			}
+		})
+	}
+}
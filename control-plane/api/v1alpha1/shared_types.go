@@ -79,6 +79,25 @@ type HTTPHeaderModifiers struct {
 	Remove []string `json:"remove,omitempty"`
 }
 
+// ResourceReference is a reference to another config entry, e.g. the API
+// gateway a route is bound to or an inline certificate of a gateway listener.
+type ResourceReference struct {
+	// Kind is the kind of the referenced config entry, e.g. api-gateway.
+	// It defaults to the only kind that can be referenced in the context
+	// the reference is used in.
+	Kind string `json:"kind,omitempty"`
+	// Name is the name of the referenced config entry.
+	Name string `json:"name,omitempty"`
+	// SectionName is the name of a section of the referenced config entry,
+	// e.g. the listener of an API gateway. If empty, the whole config entry
+	// is referenced.
+	SectionName string `json:"sectionName,omitempty"`
+	// Partition is the admin partition of the referenced config entry.
+	Partition string `json:"partition,omitempty"`
+	// Namespace is the namespace of the referenced config entry.
+	Namespace string `json:"namespace,omitempty"`
+}
+
 func (in MeshGateway) toConsul() capi.MeshGatewayConfig {
 	mode := capi.MeshGatewayMode(in.Mode)
 	switch mode {
@@ -93,6 +112,12 @@ func (in MeshGateway) toConsul() capi.MeshGatewayConfig {
 	}
 }
 
+func meshGatewayFromConsul(in capi.MeshGatewayConfig) MeshGateway {
+	return MeshGateway{
+		Mode: string(in.Mode),
+	}
+}
+
 func (in MeshGateway) validate(path *field.Path) *field.Error {
 	modes := []string{"remote", "local", "none", ""}
 	if !sliceContains(modes, in.Mode) {
@@ -117,6 +142,22 @@ func (in Expose) toConsul() capi.ExposeConfig {
 	}
 }
 
+func exposeFromConsul(in capi.ExposeConfig) Expose {
+	var paths []ExposePath
+	for _, path := range in.Paths {
+		paths = append(paths, ExposePath{
+			ListenerPort:  path.ListenerPort,
+			Path:          path.Path,
+			LocalPathPort: path.LocalPathPort,
+			Protocol:      path.Protocol,
+		})
+	}
+	return Expose{
+		Checks: in.Checks,
+		Paths:  paths,
+	}
+}
+
 func (in Expose) validate(path *field.Path) field.ErrorList {
 	var errs field.ErrorList
 	protocols := []string{"http", "http2"}
@@ -148,6 +189,24 @@ func (in *TransparentProxy) toConsul() *capi.TransparentProxyConfig {
 	}
 }
 
+func transparentProxyFromConsul(in *capi.TransparentProxyConfig) *TransparentProxy {
+	if in == nil || *in == (capi.TransparentProxyConfig{}) {
+		return nil
+	}
+	return &TransparentProxy{
+		OutboundListenerPort: in.OutboundListenerPort,
+		DialedDirectly:       in.DialedDirectly,
+	}
+}
+
+func proxyModeFromConsul(in capi.ProxyMode) *ProxyMode {
+	if in == capi.ProxyModeDefault {
+		return nil
+	}
+	mode := ProxyMode(in)
+	return &mode
+}
+
 func (in *TransparentProxy) validate(path *field.Path) *field.Error {
 	if in == nil {
 		return nil
@@ -176,6 +235,62 @@ func (in *HTTPHeaderModifiers) toConsul() *capi.HTTPHeaderModifiers {
 	}
 }
 
+func httpHeaderModifiersFromConsul(in *capi.HTTPHeaderModifiers) *HTTPHeaderModifiers {
+	if in == nil {
+		return nil
+	}
+	return &HTTPHeaderModifiers{
+		Add:    in.Add,
+		Set:    in.Set,
+		Remove: in.Remove,
+	}
+}
+
+func (in ResourceReference) toConsul(defaultKind string) capi.ResourceReference {
+	kind := in.Kind
+	if kind == "" {
+		kind = defaultKind
+	}
+	return capi.ResourceReference{
+		Kind:        kind,
+		Name:        in.Name,
+		SectionName: in.SectionName,
+		Partition:   in.Partition,
+		Namespace:   in.Namespace,
+	}
+}
+
+func resourceReferenceFromConsul(in capi.ResourceReference) ResourceReference {
+	return ResourceReference{
+		Kind:        in.Kind,
+		Name:        in.Name,
+		SectionName: in.SectionName,
+		Partition:   in.Partition,
+		Namespace:   in.Namespace,
+	}
+}
+
+// validate returns errors if the reference doesn't reference a config entry of
+// the given kind or sets Consul Enterprise fields that aren't enabled.
+func (in ResourceReference) validate(path *field.Path, kind string, consulMeta common.ConsulMeta) field.ErrorList {
+	var errs field.ErrorList
+	if in.Kind != "" && in.Kind != kind {
+		errs = append(errs, field.Invalid(path.Child("kind"), in.Kind, fmt.Sprintf("must be %q", kind)))
+	}
+	if in.Name == "" {
+		errs = append(errs, field.Required(path.Child("name"), "name is required"))
+	}
+	if in.Partition != "" && !consulMeta.PartitionsEnabled {
+		errs = append(errs, field.Invalid(path.Child("partition"), in.Partition,
+			"Consul Enterprise admin-partitions must be enabled to set partition"))
+	}
+	if in.Namespace != "" && !consulMeta.NamespacesEnabled {
+		errs = append(errs, field.Invalid(path.Child("namespace"), in.Namespace,
+			"Consul Enterprise namespaces must be enabled to set namespace"))
+	}
+	return errs
+}
+
 func notInSliceMessage(slice []string) string {
 	return fmt.Sprintf(`must be one of "%s"`, strings.Join(slice, `", "`))
 }
@@ -1,6 +1,9 @@
 package v1alpha1
 
 import (
+	"sort"
+
+	capi "github.com/hashicorp/consul/api"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -14,6 +17,10 @@ type ConditionType string
 const (
 	// ConditionSynced specifies that the resource has been synced with Consul.
 	ConditionSynced ConditionType = "Synced"
+	// ConditionDrifted specifies that the config entry in Consul has been
+	// changed or deleted outside of Kubernetes and no longer matches the
+	// resource.
+	ConditionDrifted ConditionType = "Drifted"
 )
 
 // Conditions define a readiness condition for a Consul resource.
@@ -78,6 +85,72 @@ type Status struct {
 	// LastSyncedTime is the last time the resource successfully synced with Consul.
 	// +optional
 	LastSyncedTime *metav1.Time `json:"lastSyncedTime,omitempty" description:"last time the condition transitioned from one status to another"`
+
+	// LastSyncedGeneration is the generation of the resource that was last
+	// successfully synced with Consul.
+	// +optional
+	LastSyncedGeneration int64 `json:"lastSyncedGeneration,omitempty" description:"generation of the resource that was last synced with Consul"`
+
+	// ConsulDatacenter is the datacenter that owns the config entry in Consul.
+	// +optional
+	ConsulDatacenter string `json:"consulDatacenter,omitempty" description:"datacenter that owns the config entry in Consul"`
+
+	// ConsulModifyIndex is the modify index of the config entry in Consul
+	// when the resource was last reconciled.
+	// +optional
+	ConsulModifyIndex uint64 `json:"consulModifyIndex,omitempty" description:"modify index of the config entry in Consul"`
+
+	// DiscoveryChain is the discovery chain Consul compiled for the service
+	// the config entry applies to. It is only set for service-router,
+	// service-splitter and service-resolver resources.
+	// +optional
+	DiscoveryChain *DiscoveryChainStatus `json:"discoveryChain,omitempty" description:"discovery chain compiled by Consul for the service"`
+}
+
+// DiscoveryChainStatus is the discovery chain of a service as compiled by Consul.
+// +k8s:deepcopy-gen=true
+// +k8s:openapi-gen=true
+type DiscoveryChainStatus struct {
+	// Protocol is the protocol shared by all the services in the discovery chain.
+	// +optional
+	Protocol string `json:"protocol,omitempty"`
+
+	// Targets are the targets requests to the service are resolved to,
+	// including failover targets.
+	// +optional
+	Targets []DiscoveryChainTarget `json:"targets,omitempty"`
+
+	// Warnings are the errors Consul returned when compiling the discovery
+	// chain, e.g. because the services in the chain use inconsistent
+	// protocols or a protocol that doesn't permit routing or splitting.
+	// Protocol mismatches are only reported through these errors; the
+	// controller doesn't check the protocols of the services itself.
+	// +optional
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// DiscoveryChainTarget is a target of a discovery chain, i.e. the service
+// instances a request is resolved to.
+// +k8s:deepcopy-gen=true
+// +k8s:openapi-gen=true
+type DiscoveryChainTarget struct {
+	// ID is the ID of the target in the discovery chain.
+	ID string `json:"id"`
+
+	// Service is the name of the service.
+	Service string `json:"service"`
+
+	// ServiceSubset is the subset of the service defined by its service-resolver.
+	// +optional
+	ServiceSubset string `json:"serviceSubset,omitempty"`
+
+	// Namespace is the Consul namespace of the service.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Datacenter is the datacenter of the service.
+	// +optional
+	Datacenter string `json:"datacenter,omitempty"`
 }
 
 func (s *Status) GetCondition(t ConditionType) *Condition {
@@ -88,3 +161,48 @@ func (s *Status) GetCondition(t ConditionType) *Condition {
 	}
 	return nil
 }
+
+// setCondition replaces the condition of the same type or adds it.
+func (s *Status) setCondition(condition Condition) {
+	for i, cond := range s.Conditions {
+		if cond.Type == condition.Type {
+			s.Conditions[i] = condition
+			return
+		}
+	}
+	s.Conditions = append(s.Conditions, condition)
+}
+
+// SetConsulStatus records the datacenter that owns the config entry in Consul
+// and its modify index.
+func (s *Status) SetConsulStatus(datacenter string, modifyIndex uint64) {
+	s.ConsulDatacenter = datacenter
+	s.ConsulModifyIndex = modifyIndex
+}
+
+// SetDiscoveryChainStatus records the discovery chain compiled by Consul or,
+// if compileErr is set, the error Consul returned when compiling it.
+func (s *Status) SetDiscoveryChainStatus(chain *capi.CompiledDiscoveryChain, compileErr string) {
+	status := &DiscoveryChainStatus{}
+	switch {
+	case compileErr != "":
+		status.Warnings = []string{compileErr}
+	case chain != nil:
+		status.Protocol = chain.Protocol
+		for _, target := range chain.Targets {
+			status.Targets = append(status.Targets, DiscoveryChainTarget{
+				ID:            target.ID,
+				Service:       target.Service,
+				ServiceSubset: target.ServiceSubset,
+				Namespace:     target.Namespace,
+				Datacenter:    target.Datacenter,
+			})
+		}
+		// Targets are sorted so that the status doesn't change between
+		// reconciles because of the map iteration order.
+		sort.Slice(status.Targets, func(i, j int) bool {
+			return status.Targets[i].ID < status.Targets[j].ID
+		})
+	}
+	s.DiscoveryChain = status
+}
@@ -0,0 +1,383 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTCPRoute_MatchesConsul(t *testing.T) {
+	cases := map[string]struct {
+		Ours    TCPRoute
+		Theirs  capi.ConfigEntry
+		Matches bool
+	}{
+		"empty fields matches": {
+			Ours: TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.TCPRouteConfigEntry{
+				Kind:        capi.TCPRoute,
+				Name:        "name",
+				Namespace:   "namespace",
+				CreateIndex: 1,
+				ModifyIndex: 2,
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Matches: true,
+		},
+		"all fields set matches": {
+			Ours: TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: TCPRouteSpec{
+					Parents:  []ResourceReference{{Name: "gateway", SectionName: "tcp"}},
+					Services: []TCPService{{Name: "db"}},
+				},
+			},
+			Theirs: &capi.TCPRouteConfigEntry{
+				Kind:     capi.TCPRoute,
+				Name:     "name",
+				Parents:  []capi.ResourceReference{{Kind: capi.APIGateway, Name: "gateway", SectionName: "tcp"}},
+				Services: []capi.TCPService{{Name: "db"}},
+				Status: capi.ConfigEntryStatus{
+					Conditions: []capi.Condition{{Type: "Bound", Status: "True"}},
+				},
+			},
+			Matches: true,
+		},
+		"mismatched types does not match": {
+			Ours: TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Theirs: &capi.HTTPRouteConfigEntry{
+				Kind: capi.HTTPRoute,
+				Name: "name",
+			},
+			Matches: false,
+		},
+		"different services does not match": {
+			Ours: TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: TCPRouteSpec{
+					Services: []TCPService{{Name: "db"}},
+				},
+			},
+			Theirs: &capi.TCPRouteConfigEntry{
+				Kind:     capi.TCPRoute,
+				Name:     "name",
+				Services: []capi.TCPService{{Name: "cache"}},
+			},
+			Matches: false,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.Matches, c.Ours.MatchesConsul(c.Theirs))
+		})
+	}
+}
+
+func TestTCPRoute_ToConsul(t *testing.T) {
+	cases := map[string]struct {
+		Ours TCPRoute
+		Exp  *capi.TCPRouteConfigEntry
+	}{
+		"empty fields": {
+			Ours: TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+			Exp: &capi.TCPRouteConfigEntry{
+				Kind: capi.TCPRoute,
+				Name: "name",
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
+		"every field set": {
+			Ours: TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: TCPRouteSpec{
+					Parents: []ResourceReference{
+						{
+							Name:        "gateway",
+							SectionName: "tcp",
+							Partition:   "partition",
+							Namespace:   "namespace",
+						},
+					},
+					Services: []TCPService{
+						{
+							Name:      "db",
+							Partition: "partition",
+							Namespace: "namespace",
+						},
+					},
+				},
+			},
+			Exp: &capi.TCPRouteConfigEntry{
+				Kind: capi.TCPRoute,
+				Name: "name",
+				Parents: []capi.ResourceReference{
+					{
+						Kind:        capi.APIGateway,
+						Name:        "gateway",
+						SectionName: "tcp",
+						Partition:   "partition",
+						Namespace:   "namespace",
+					},
+				},
+				Services: []capi.TCPService{
+					{
+						Name:      "db",
+						Partition: "partition",
+						Namespace: "namespace",
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			act := c.Ours.ToConsul("datacenter")
+			resource, ok := act.(*capi.TCPRouteConfigEntry)
+			require.True(t, ok, "could not cast")
+			require.Equal(t, c.Exp, resource)
+		})
+	}
+}
+
+func TestTCPRouteFromConsul(t *testing.T) {
+	cases := map[string]struct {
+		Consul *capi.TCPRouteConfigEntry
+		Exp    TCPRoute
+	}{
+		"empty fields": {
+			Consul: &capi.TCPRouteConfigEntry{
+				Kind: capi.TCPRoute,
+				Name: "name",
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+			},
+		},
+		"every field set": {
+			Consul: &capi.TCPRouteConfigEntry{
+				Kind: capi.TCPRoute,
+				Name: "name",
+				Parents: []capi.ResourceReference{
+					{
+						Kind:        capi.APIGateway,
+						Name:        "gateway",
+						SectionName: "tcp",
+						Partition:   "partition",
+						Namespace:   "namespace",
+					},
+				},
+				Services: []capi.TCPService{
+					{
+						Name:      "db",
+						Partition: "partition",
+						Namespace: "namespace",
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: TCPRouteSpec{
+					Parents: []ResourceReference{
+						{
+							Kind:        capi.APIGateway,
+							Name:        "gateway",
+							SectionName: "tcp",
+							Partition:   "partition",
+							Namespace:   "namespace",
+						},
+					},
+					Services: []TCPService{
+						{
+							Name:      "db",
+							Partition: "partition",
+							Namespace: "namespace",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, &c.Exp, TCPRouteFromConsul(c.Consul))
+		})
+	}
+}
+
+func TestTCPRoute_Validate(t *testing.T) {
+	cases := map[string]struct {
+		input           *TCPRoute
+		consulMeta      common.ConsulMeta
+		expectedErrMsgs []string
+	}{
+		"valid": {
+			input: &TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: TCPRouteSpec{
+					Parents:  []ResourceReference{{Kind: capi.APIGateway, Name: "gateway"}},
+					Services: []TCPService{{Name: "db"}},
+				},
+			},
+		},
+		"too many services": {
+			input: &TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: TCPRouteSpec{
+					Services: []TCPService{{Name: "db"}, {Name: "cache"}},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.services: Too many: 2: must have at most 1 items`,
+			},
+		},
+		"invalid parents and services": {
+			input: &TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: TCPRouteSpec{
+					Parents: []ResourceReference{
+						{Kind: capi.TerminatingGateway, Name: "gateway"},
+						{Namespace: "ns"},
+					},
+					Services: []TCPService{{Partition: "part"}},
+				},
+			},
+			expectedErrMsgs: []string{
+				`spec.parents[0].kind: Invalid value: "terminating-gateway": must be "api-gateway"`,
+				`spec.parents[1].name: Required value: name is required`,
+				`spec.parents[1].namespace: Invalid value: "ns": Consul Enterprise namespaces must be enabled to set namespace`,
+				`spec.services[0].name: Required value: name is required`,
+				`spec.services[0].partition: Invalid value: "part": Consul Enterprise admin-partitions must be enabled to set partition`,
+			},
+		},
+		"namespace and partition set when enabled": {
+			input: &TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "foo",
+				},
+				Spec: TCPRouteSpec{
+					Parents:  []ResourceReference{{Name: "gateway", Namespace: "ns", Partition: "part"}},
+					Services: []TCPService{{Name: "db", Namespace: "ns", Partition: "part"}},
+				},
+			},
+			consulMeta: common.ConsulMeta{NamespacesEnabled: true, PartitionsEnabled: true},
+		},
+	}
+
+	for name, testCase := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := testCase.input.Validate(testCase.consulMeta)
+			if len(testCase.expectedErrMsgs) != 0 {
+				require.Error(t, err)
+				for _, s := range testCase.expectedErrMsgs {
+					require.Contains(t, err.Error(), s)
+				}
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestTCPRoute_DefaultNamespaceFields(t *testing.T) {
+	cases := map[string]struct {
+		consulMeta          common.ConsulMeta
+		expectedDestination string
+	}{
+		"disabled": {
+			consulMeta:          common.ConsulMeta{},
+			expectedDestination: "",
+		},
+		"destinationNS": {
+			consulMeta: common.ConsulMeta{
+				NamespacesEnabled:    true,
+				DestinationNamespace: "foo",
+			},
+			expectedDestination: "foo",
+		},
+		"mirroringEnabledWithoutPrefix": {
+			consulMeta: common.ConsulMeta{
+				NamespacesEnabled: true,
+				Mirroring:         true,
+			},
+			expectedDestination: "bar",
+		},
+		"mirroringWithPrefix": {
+			consulMeta: common.ConsulMeta{
+				NamespacesEnabled: true,
+				Mirroring:         true,
+				Prefix:            "ns-",
+			},
+			expectedDestination: "ns-bar",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			input := &TCPRoute{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "foo",
+					Namespace: "bar",
+				},
+				Spec: TCPRouteSpec{
+					Parents:  []ResourceReference{{Name: "gateway"}},
+					Services: []TCPService{{Name: "db"}},
+				},
+			}
+			input.DefaultNamespaceFields(c.consulMeta)
+			require.Equal(t, c.expectedDestination, input.Spec.Parents[0].Namespace)
+			require.Equal(t, c.expectedDestination, input.Spec.Services[0].Namespace)
+		})
+	}
+}
@@ -140,6 +140,24 @@ func (in *TerminatingGateway) SetLastSyncedTime(time *metav1.Time) {
 	in.Status.LastSyncedTime = time
 }
 
+func (in *TerminatingGateway) SetDriftedCondition(status corev1.ConditionStatus, reason string, message string) {
+	in.Status.setCondition(Condition{
+		Type:               ConditionDrifted,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	})
+}
+
+func (in *TerminatingGateway) SetLastSyncedGeneration(generation int64) {
+	in.Status.LastSyncedGeneration = generation
+}
+
+func (in *TerminatingGateway) LastSyncedGeneration() int64 {
+	return in.Status.LastSyncedGeneration
+}
+
 func (in *TerminatingGateway) SyncedCondition() (status corev1.ConditionStatus, reason, message string) {
 	cond := in.Status.GetCondition(ConditionSynced)
 	if cond == nil {
@@ -169,6 +187,23 @@ func (in *TerminatingGateway) ToConsul(datacenter string) capi.ConfigEntry {
 	}
 }
 
+// TerminatingGatewayFromConsul converts the Consul config entry into a
+// TerminatingGateway resource. It's the reverse of ToConsul.
+func TerminatingGatewayFromConsul(entry *capi.TerminatingGatewayConfigEntry) *TerminatingGateway {
+	var svcs []LinkedService
+	for _, s := range entry.Services {
+		svcs = append(svcs, linkedServiceFromConsul(s))
+	}
+	return &TerminatingGateway{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: entry.Name,
+		},
+		Spec: TerminatingGatewaySpec{
+			Services: svcs,
+		},
+	}
+}
+
 func (in *TerminatingGateway) MatchesConsul(candidate capi.ConfigEntry) bool {
 	configEntry, ok := candidate.(*capi.TerminatingGatewayConfigEntry)
 	if !ok {
@@ -224,6 +259,17 @@ func (in LinkedService) toConsul() capi.LinkedService {
 	}
 }
 
+func linkedServiceFromConsul(in capi.LinkedService) LinkedService {
+	return LinkedService{
+		Namespace: in.Namespace,
+		Name:      in.Name,
+		CAFile:    in.CAFile,
+		CertFile:  in.CertFile,
+		KeyFile:   in.KeyFile,
+		SNI:       in.SNI,
+	}
+}
+
 func (in LinkedService) validate(path *field.Path) field.ErrorList {
 	var errs field.ErrorList
 	if (in.CertFile != "" && in.KeyFile == "") || (in.KeyFile != "" && in.CertFile == "") {
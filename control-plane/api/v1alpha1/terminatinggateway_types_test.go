@@ -182,6 +182,77 @@ func TestTerminatingGateway_ToConsul(t *testing.T) {
 	}
 }
 
+func TestTerminatingGatewayFromConsul(t *testing.T) {
+	cases := map[string]struct {
+		Consul *capi.TerminatingGatewayConfigEntry
+		Exp    TerminatingGateway
+	}{
+		"empty fields": {
+			Consul: &capi.TerminatingGatewayConfigEntry{
+				Kind: capi.TerminatingGateway,
+				Name: "name",
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: TerminatingGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: TerminatingGatewaySpec{},
+			},
+		},
+		"every field set": {
+			Consul: &capi.TerminatingGatewayConfigEntry{
+				Kind: capi.TerminatingGateway,
+				Name: "name",
+				Services: []capi.LinkedService{
+					{
+						Name:     "name",
+						CAFile:   "caFile",
+						CertFile: "certFile",
+						KeyFile:  "keyFile",
+						SNI:      "sni",
+					},
+					{
+						Name: "*",
+					},
+				},
+				Meta: map[string]string{
+					common.SourceKey:     common.SourceValue,
+					common.DatacenterKey: "datacenter",
+				},
+			},
+			Exp: TerminatingGateway{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "name",
+				},
+				Spec: TerminatingGatewaySpec{
+					Services: []LinkedService{
+						{
+							Name:     "name",
+							CAFile:   "caFile",
+							CertFile: "certFile",
+							KeyFile:  "keyFile",
+							SNI:      "sni",
+						},
+						{
+							Name: "*",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, &c.Exp, TerminatingGatewayFromConsul(c.Consul))
+		})
+	}
+}
+
 func TestTerminatingGateway_Validate(t *testing.T) {
 	cases := map[string]struct {
 		input             *TerminatingGateway
@@ -0,0 +1,113 @@
+// Package metrics contains the Prometheus metrics of the catalog sync in
+// both directions.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DirectionToConsul and DirectionToK8S are the values of the direction
+	// label for metrics of the K8S-to-Consul and Consul-to-K8S sync.
+	DirectionToConsul = "to-consul"
+	DirectionToK8S    = "to-k8s"
+
+	namespace = "consul_sync_catalog"
+)
+
+// Metrics are the metrics of the catalog sync. All metrics other than
+// ReapedServices are labeled with the direction of the sync.
+type Metrics struct {
+	// Registrations and Deregistrations count the services written to and
+	// removed from the destination of the sync.
+	Registrations   *prometheus.CounterVec
+	Deregistrations *prometheus.CounterVec
+
+	// ReapedServices counts the services found in Consul with the sync tag
+	// that are not known to the K8S-to-Consul sync and are removed.
+	ReapedServices prometheus.Counter
+
+	// Errors counts the failed API requests, labeled by operation.
+	Errors *prometheus.CounterVec
+
+	// LastSyncTimestamp is the Unix time of the last sync that completed
+	// without errors.
+	LastSyncTimestamp *prometheus.GaugeVec
+
+	// TrackedServices is the number of services currently being synced.
+	TrackedServices *prometheus.GaugeVec
+
+	// WriteDuration is the latency of the writes to the destination of the
+	// sync, labeled by operation.
+	WriteDuration *prometheus.HistogramVec
+}
+
+// New creates the metrics and registers them with the given registerer.
+// It panics if any of the metrics is already registered.
+func New(reg prometheus.Registerer) *Metrics {
+	m := &Metrics{
+		Registrations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "registrations_total",
+			Help:      "Number of service registrations written.",
+		}, []string{"direction"}),
+		Deregistrations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "deregistrations_total",
+			Help:      "Number of services deregistered.",
+		}, []string{"direction"}),
+		ReapedServices: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reaped_services_total",
+			Help:      "Number of untracked services scheduled for removal from Consul.",
+		}),
+		Errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "errors_total",
+			Help:      "Number of failed API requests by operation.",
+		}, []string{"direction", "operation"}),
+		LastSyncTimestamp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_sync_timestamp_seconds",
+			Help:      "Unix time of the last sync that completed without errors.",
+		}, []string{"direction"}),
+		TrackedServices: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "tracked_services",
+			Help:      "Number of services being synced.",
+		}, []string{"direction"}),
+		WriteDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "write_duration_seconds",
+			Help:      "Latency of writes by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"direction", "operation"}),
+	}
+
+	reg.MustRegister(
+		m.Registrations,
+		m.Deregistrations,
+		m.ReapedServices,
+		m.Errors,
+		m.LastSyncTimestamp,
+		m.TrackedServices,
+		m.WriteDuration,
+	)
+	return m
+}
+
+// ObserveWrite records the latency of a write that started at the given
+// time and counts it as an error if err is non-nil.
+func (m *Metrics) ObserveWrite(direction, operation string, start time.Time, err error) {
+	m.WriteDuration.WithLabelValues(direction, operation).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.Errors.WithLabelValues(direction, operation).Inc()
+	}
+}
+
+// ObserveError counts a failed API request.
+func (m *Metrics) ObserveError(direction, operation string) {
+	m.Errors.WithLabelValues(direction, operation).Inc()
+}
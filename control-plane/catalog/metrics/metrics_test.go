@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestObserveWrite(t *testing.T) {
+	m := New(prometheus.NewRegistry())
+
+	m.ObserveWrite(DirectionToConsul, "register", time.Now(), nil)
+	m.ObserveWrite(DirectionToConsul, "register", time.Now(), errors.New("error"))
+	m.ObserveError(DirectionToK8S, "get_endpoints")
+
+	require.Equal(t, 1, testutil.CollectAndCount(m.WriteDuration))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.Errors.WithLabelValues(DirectionToConsul, "register")))
+	require.Equal(t, float64(1), testutil.ToFloat64(m.Errors.WithLabelValues(DirectionToK8S, "get_endpoints")))
+}
+
+func TestNew_duplicateRegistration(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	New(reg)
+	require.Panics(t, func() { New(reg) })
+}
@@ -0,0 +1,234 @@
+package catalog
+
+import (
+	"strconv"
+
+	consulapi "github.com/hashicorp/consul/api"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// ingressHTTPPort and ingressHTTPSPort are the ports registered for
+	// Ingress hosts without and with TLS respectively.
+	ingressHTTPPort  = 80
+	ingressHTTPSPort = 443
+
+	// ingressServiceSuffix is appended to the name of an Ingress to get
+	// the default name of its Consul service. It keeps the Ingress from
+	// registering instances of the service of the same name, which usually
+	// is the service the Ingress routes to.
+	ingressServiceSuffix = "-ingress"
+)
+
+// IngressResource implements controller.Resource to sync Ingress resource
+// types from K8S. Each Ingress host is registered as an instance of a
+// Consul service at the Ingress' load balancer address. The service is
+// named <ingress name>-ingress unless it's set with the service name
+// annotation.
+//
+// IngressResource shares its configuration, state and Syncer with a
+// ServiceResource so that the registrations of both are synced together.
+type IngressResource struct {
+	Service *ServiceResource
+}
+
+// Informer implements the controller.Resource interface.
+func (t *IngressResource) Informer() cache.SharedIndexInformer {
+	// Watch all k8s namespaces. Events will be filtered out as appropriate
+	// based on the allow and deny lists in the `shouldSync` function.
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return t.Service.Client.NetworkingV1().Ingresses(metav1.NamespaceAll).List(t.Service.Ctx, options)
+			},
+
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return t.Service.Client.NetworkingV1().Ingresses(metav1.NamespaceAll).Watch(t.Service.Ctx, options)
+			},
+		},
+		&networkingv1.Ingress{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+// Upsert implements the controller.Resource interface.
+func (t *IngressResource) Upsert(key string, raw interface{}) error {
+	svc := t.Service
+	ingress, ok := raw.(*networkingv1.Ingress)
+	if !ok {
+		svc.Log.Warn("upsert got invalid type", "raw", raw)
+		return nil
+	}
+
+	svc.serviceLock.Lock()
+	defer svc.serviceLock.Unlock()
+
+	if !t.shouldSync(ingress) {
+		// Check if its in our map and delete it.
+		if _, ok := svc.ingressConsulMap[key]; ok {
+			svc.Log.Info("ingress should no longer be synced", "ingress", key)
+			t.doDelete(key)
+		} else {
+			svc.Log.Debug("[IngressResource.Upsert] syncing disabled for ingress, ignoring", "key", key)
+		}
+		return nil
+	}
+
+	// Update the registration and trigger a sync
+	t.generateRegistrations(key, ingress)
+	svc.sync()
+	svc.Log.Info("upsert ingress", "key", key)
+	return nil
+}
+
+// Delete implements the controller.Resource interface.
+func (t *IngressResource) Delete(key string, _ interface{}) error {
+	t.Service.serviceLock.Lock()
+	defer t.Service.serviceLock.Unlock()
+	t.doDelete(key)
+	t.Service.Log.Info("delete ingress", "key", key)
+	return nil
+}
+
+// doDelete is a helper function for deletion.
+//
+// Precondition: assumes t.Service.serviceLock is held.
+func (t *IngressResource) doDelete(key string) {
+	// If there were registrations related to this ingress, then
+	// delete them and sync.
+	if _, ok := t.Service.ingressConsulMap[key]; ok {
+		delete(t.Service.ingressConsulMap, key)
+		t.Service.sync()
+	}
+}
+
+// shouldSync returns true if resyncing should be enabled for the given ingress.
+// Ingresses are filtered by the same namespace lists and service-sync
+// annotation as services.
+func (t *IngressResource) shouldSync(ingress *networkingv1.Ingress) bool {
+	if !t.Service.shouldSyncNamespace(ingress.Namespace) {
+		t.Service.Log.Debug("[IngressResource.shouldSync] ingress namespace is not synced", "namespace", ingress.Namespace, "ingress", ingress.Name)
+		return false
+	}
+
+	return t.Service.syncAnnotationEnabled(ingress.ObjectMeta)
+}
+
+// generateRegistrations generates the Consul registrations for the given
+// ingress. An instance is registered for each host of the ingress and each
+// address of its load balancer. If the load balancer has no address yet,
+// then no registration will be generated.
+//
+// Precondition: the lock t.Service.serviceLock is held.
+func (t *IngressResource) generateRegistrations(key string, ingress *networkingv1.Ingress) {
+	svc := t.Service
+	svc.Log.Debug("[IngressResource.generateRegistrations] generating registration", "key", key)
+
+	if svc.ingressConsulMap == nil {
+		svc.ingressConsulMap = make(map[string][]*consulapi.CatalogRegistration)
+	}
+
+	// Begin by always clearing the old value out since we'll regenerate
+	// a new one if there is one.
+	delete(svc.ingressConsulMap, key)
+
+	baseNode := svc.baseNode()
+	meta := ingress.ObjectMeta
+	meta.Name += ingressServiceSuffix
+	baseService := svc.baseService(meta)
+	baseService.Meta[ConsulK8SRefKind] = "Ingress"
+	baseService.Meta[ConsulK8SRefValue] = ingress.Name
+	addAnnotationTagsAndMeta(&baseService, ingress.Annotations)
+
+	// An integer port annotation overrides the default HTTP/HTTPS ports.
+	var overridePort int
+	if v, err := strconv.ParseInt(ingress.Annotations[annotationServicePort], 0, 0); err == nil {
+		overridePort = int(v)
+	}
+
+	defer func() {
+		svc.Log.Debug("generated ingress registration",
+			"key", key,
+			"service", baseService.Service,
+			"namespace", baseService.Namespace,
+			"instances", len(svc.ingressConsulMap[key]))
+	}()
+
+	// We only support load balancer entries that have an address assigned.
+	var addrs []string
+	seenAddrs := map[string]struct{}{}
+	for _, lb := range ingress.Status.LoadBalancer.Ingress {
+		addr := lb.IP
+		if addr == "" {
+			addr = lb.Hostname
+		}
+		if addr == "" {
+			continue
+		}
+		if _, ok := seenAddrs[addr]; ok {
+			continue
+		}
+		seenAddrs[addr] = struct{}{}
+		addrs = append(addrs, addr)
+	}
+
+	tlsHosts := map[string]struct{}{}
+	for _, tls := range ingress.Spec.TLS {
+		for _, host := range tls.Hosts {
+			tlsHosts[host] = struct{}{}
+		}
+	}
+
+	// Rules without a host match all hosts, these are registered with an
+	// empty host.
+	var hosts []string
+	seenHosts := map[string]struct{}{}
+	for _, rule := range ingress.Spec.Rules {
+		if _, ok := seenHosts[rule.Host]; ok {
+			continue
+		}
+		seenHosts[rule.Host] = struct{}{}
+		hosts = append(hosts, rule.Host)
+	}
+	if len(hosts) == 0 && ingress.Spec.DefaultBackend != nil {
+		hosts = append(hosts, "")
+	}
+
+	for _, host := range hosts {
+		port := ingressHTTPPort
+		if _, ok := tlsHosts[host]; ok {
+			port = ingressHTTPSPort
+		}
+		if overridePort != 0 {
+			port = overridePort
+		}
+
+		for _, addr := range addrs {
+			r := baseNode
+			rs := baseService
+			r.Service = &rs
+			r.Service.ID = serviceID(r.Service.Service, addr)
+			if host != "" {
+				r.Service.ID = serviceID(r.Service.Service, host+"-"+addr)
+			}
+			r.Service.Address = addr
+			r.Service.Port = port
+			r.Service.Meta = make(map[string]string)
+			// Deepcopy baseService.Meta into r.Service.Meta as baseService is shared
+			// between all instances of a service
+			for k, v := range baseService.Meta {
+				r.Service.Meta[k] = v
+			}
+			if host != "" {
+				r.Service.Meta[ConsulK8SIngressHost] = host
+			}
+
+			svc.ingressConsulMap[key] = append(svc.ingressConsulMap[key], &r)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package catalog
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// Test that an instance is registered for each host of an ingress.
+func TestIngressResource_hosts(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ConsulK8STag = TestConsulK8STag
+
+	// Start the controller
+	closer := controller.TestControllerRun(&IngressResource{Service: &serviceResource})
+	defer closer()
+
+	// Insert an ingress with a TLS host and a plain HTTP host
+	ing := ingress("foo", metav1.NamespaceDefault, "1.2.3.4", "foo.example.com", "bar.example.com")
+	ing.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{"foo.example.com"}}}
+	ing.Annotations[annotationServiceTags] = "one,two"
+	_, err := client.NetworkingV1().Ingresses(metav1.NamespaceDefault).Create(context.Background(), ing, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		ports := map[string]int{}
+		for _, reg := range actual {
+			require.Equal(r, "foo-ingress", reg.Service.Service)
+			require.Equal(r, "1.2.3.4", reg.Service.Address)
+			require.Equal(r, []string{TestConsulK8STag, "one", "two"}, reg.Service.Tags)
+			require.Equal(r, "Ingress", reg.Service.Meta[ConsulK8SRefKind])
+			require.Equal(r, "foo", reg.Service.Meta[ConsulK8SRefValue])
+			ports[reg.Service.Meta[ConsulK8SIngressHost]] = reg.Service.Port
+		}
+		require.Equal(r, map[string]int{"foo.example.com": 443, "bar.example.com": 80}, ports)
+		require.NotEqual(r, actual[0].Service.ID, actual[1].Service.ID)
+	})
+}
+
+// Test that an ingress is registered once it has a load balancer address.
+func TestIngressResource_waitsForAddress(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+
+	// Start the controller
+	closer := controller.TestControllerRun(&IngressResource{Service: &serviceResource})
+	defer closer()
+
+	ing := ingress("foo", metav1.NamespaceDefault, "", "foo.example.com")
+	_, err := client.NetworkingV1().Ingresses(metav1.NamespaceDefault).Create(context.Background(), ing, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		require.Len(r, syncer.Registrations, 0)
+	})
+
+	// Update the ingress with a hostname address
+	ing.Status.LoadBalancer.Ingress = []apiv1.LoadBalancerIngress{{Hostname: "lb.example.com"}}
+	_, err = client.NetworkingV1().Ingresses(metav1.NamespaceDefault).UpdateStatus(context.Background(), ing, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, "lb.example.com", actual[0].Service.Address)
+		require.Equal(r, 80, actual[0].Service.Port)
+	})
+}
+
+// Test that the annotations and namespace filters are honoured.
+func TestIngressResource_shouldSync(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ExplicitEnable = true
+	serviceResource.DenyK8sNamespacesSet.Add("denied")
+
+	// Start the controller
+	closer := controller.TestControllerRun(&IngressResource{Service: &serviceResource})
+	defer closer()
+
+	// Not annotated, so not synced by default
+	ing := ingress("foo", metav1.NamespaceDefault, "1.2.3.4", "foo.example.com")
+	_, err := client.NetworkingV1().Ingresses(metav1.NamespaceDefault).Create(context.Background(), ing, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Annotated but in a denied namespace
+	ing = ingress("baz", "denied", "1.2.3.4", "baz.example.com")
+	ing.Annotations[annotationServiceSync] = "true"
+	_, err = client.NetworkingV1().Ingresses("denied").Create(context.Background(), ing, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Annotated with a name and port
+	ing = ingress("bar", metav1.NamespaceDefault, "1.2.3.4", "bar.example.com")
+	ing.Annotations[annotationServiceSync] = "true"
+	ing.Annotations[annotationServiceName] = "web"
+	ing.Annotations[annotationServicePort] = "8080"
+	_, err = client.NetworkingV1().Ingresses(metav1.NamespaceDefault).Create(context.Background(), ing, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, "web", actual[0].Service.Service)
+		require.Equal(r, 8080, actual[0].Service.Port)
+	})
+
+	// Deleting the ingress removes the registration
+	require.NoError(t, client.NetworkingV1().Ingresses(metav1.NamespaceDefault).Delete(context.Background(), "bar", metav1.DeleteOptions{}))
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		require.Len(r, syncer.Registrations, 0)
+	})
+}
+
+// ingress returns a Kubernetes ingress with a rule for each host and
+// the given load balancer IP, if set.
+func ingress(name, namespace, lbIP string, hosts ...string) *networkingv1.Ingress {
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Annotations: map[string]string{},
+		},
+	}
+	for _, host := range hosts {
+		ing.Spec.Rules = append(ing.Spec.Rules, networkingv1.IngressRule{Host: host})
+	}
+	if lbIP != "" {
+		ing.Status.LoadBalancer.Ingress = []apiv1.LoadBalancerIngress{{IP: lbIP}}
+	}
+	return ing
+}
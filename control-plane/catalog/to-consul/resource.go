@@ -9,14 +9,18 @@ import (
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/endpointslice"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	consulapi "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-hclog"
 	apiv1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
 )
 
@@ -32,6 +36,30 @@ const (
 	ConsulK8SRefKind  = "external-k8s-ref-kind"
 	ConsulK8SRefValue = "external-k8s-ref-name"
 	ConsulK8SNodeName = "external-k8s-node-name"
+
+	// ConsulK8SIngressHost is the key used in the meta to record the
+	// Ingress host a service instance was registered for.
+	ConsulK8SIngressHost = "external-k8s-ingress-host"
+
+	// ConsulK8SZone is the key used in the meta to record the topology
+	// zone of the endpoint a service instance was registered for.
+	ConsulK8SZone = "external-k8s-zone"
+
+	// ConsulK8SRegion is the key used in the node meta to record the
+	// topology region of the k8s node a Consul node was registered for.
+	ConsulK8SRegion = "external-k8s-region"
+
+	// ConsulK8SSyncNode is the key used in the node meta of the Consul
+	// nodes registered for each k8s node to record the name of the sync
+	// node they belong to. It's used to find the nodes to garbage collect.
+	ConsulK8SSyncNode = "external-k8s-sync-node"
+
+	// consulKubernetesCheckType and consulKubernetesCheckName are the type
+	// and name of the health check registered with service instances to
+	// reflect the readiness of their endpoints in Kubernetes.
+	consulKubernetesCheckType  = "kubernetes-readiness"
+	consulKubernetesCheckName  = "Kubernetes Readiness Check"
+	kubernetesSuccessReasonMsg = "Kubernetes health checks passing"
 )
 
 type NodePortSyncType string
@@ -59,6 +87,16 @@ type ServiceResource struct {
 	// Ctx is used to cancel processes kicked off by ServiceResource.
 	Ctx context.Context
 
+	// PodLister is used to read the pods of endpoint addresses that aren't
+	// ready without a request to the API server for every address. If it
+	// is nil or the pod isn't in its cache yet, the pod is read from Client.
+	PodLister corelisters.PodLister
+
+	// NodeLister is used to read the nodes of endpoint addresses without a
+	// request to the API server for every address. If it is nil or the node
+	// isn't in its cache yet, the node is read from Client.
+	NodeLister corelisters.NodeLister
+
 	// AllowK8sNamespacesSet is a set of k8s namespaces to explicitly allow for
 	// syncing. It supports the special character `*` which indicates that
 	// all k8s namespaces are eligible unless explicitly denied. This filter
@@ -89,6 +127,11 @@ type ServiceResource struct {
 	// LoadBalancerEndpointsSync set to true (default false) will sync ServiceTypeLoadBalancer endpoints.
 	LoadBalancerEndpointsSync bool
 
+	// EnableEndpointSlices set to true watches the EndpointSlices of
+	// services instead of the legacy Endpoints API, which truncates the
+	// addresses of services with more than 1000 endpoints.
+	EnableEndpointSlices bool
+
 	// NodeExternalIPSync set to true (the default) syncs NodePort services
 	// using the node's external ip address. When false, the node's internal
 	// ip address will be used instead.
@@ -125,6 +168,13 @@ type ServiceResource struct {
 	// The Consul node name to register service with.
 	ConsulNodeName string
 
+	// ConsulNodePerK8SNode set to true registers the service instances of
+	// endpoints and NodePorts under a Consul node for the k8s node they run
+	// on instead of ConsulNodeName. The Consul nodes are named
+	// <ConsulNodeName>-<k8s node name> and carry the node's address and
+	// topology labels as node meta.
+	ConsulNodePerK8SNode bool
+
 	// serviceLock must be held for any read/write to these maps.
 	serviceLock sync.RWMutex
 
@@ -136,10 +186,20 @@ type ServiceResource struct {
 	// of each service.
 	endpointsMap map[string]*apiv1.Endpoints
 
+	// endpointSlicesMap uses the same keys as serviceMap but maps to the
+	// EndpointSlices of each service by name when EnableEndpointSlices is
+	// set. The slices are merged into endpointsMap.
+	endpointSlicesMap map[string]map[string]*discoveryv1.EndpointSlice
+
 	// consulMap holds the services in Consul that we've registered from kube.
 	// It's populated via Consul's API and lets us diff what is actually in
 	// Consul vs. what we expect to be there.
 	consulMap map[string][]*consulapi.CatalogRegistration
+
+	// ingressConsulMap holds the registrations generated from Ingress
+	// resources by an IngressResource. Keys are in the form
+	// <kube namespace>/<kube ingress name>.
+	ingressConsulMap map[string][]*consulapi.CatalogRegistration
 }
 
 // Informer implements the controller.Resource interface.
@@ -194,7 +254,24 @@ func (t *ServiceResource) Upsert(key string, raw interface{}) error {
 	t.Log.Debug("[ServiceResource.Upsert] adding service to serviceMap", "key", key, "service", service)
 
 	// If we care about endpoints, we should do the initial endpoints load.
-	if t.shouldTrackEndpoints(key) {
+	if t.shouldTrackEndpoints(key) && t.EnableEndpointSlices {
+		slices, err := t.Client.DiscoveryV1().
+			EndpointSlices(service.Namespace).
+			List(t.Ctx, endpointslice.ListOptions(service.Name))
+		if err != nil {
+			t.Log.Warn("error loading initial endpoint slices",
+				"key", key,
+				"err", err)
+		} else {
+			// Start over from the current slices.
+			delete(t.endpointSlicesMap, key)
+			delete(t.endpointsMap, key)
+			for i := range slices.Items {
+				t.setEndpointSlice(key, &slices.Items[i])
+			}
+			t.Log.Debug("[ServiceResource.Upsert] adding service's endpoint slices to endpointsMap", "key", key, "service", service, "endpoints", t.endpointsMap[key])
+		}
+	} else if t.shouldTrackEndpoints(key) {
 		endpoints, err := t.Client.CoreV1().
 			Endpoints(service.Namespace).
 			Get(t.Ctx, service.Name, metav1.GetOptions{})
@@ -234,6 +311,7 @@ func (t *ServiceResource) doDelete(key string) {
 	delete(t.serviceMap, key)
 	t.Log.Debug("[doDelete] deleting service from serviceMap", "key", key)
 	delete(t.endpointsMap, key)
+	delete(t.endpointSlicesMap, key)
 	t.Log.Debug("[doDelete] deleting endpoints from endpointsMap", "key", key)
 	// If there were registrations related to this service, then
 	// delete them and sync.
@@ -245,6 +323,15 @@ func (t *ServiceResource) doDelete(key string) {
 
 // Run implements the controller.Backgrounder interface.
 func (t *ServiceResource) Run(ch <-chan struct{}) {
+	if t.EnableEndpointSlices {
+		t.Log.Info("starting runner for endpoint slices")
+		(&controller.Controller{
+			Log:      t.Log.Named("controller/endpointslices"),
+			Resource: &serviceEndpointSlicesResource{Service: t, Ctx: t.Ctx},
+		}).Run(ch)
+		return
+	}
+
 	t.Log.Info("starting runner for endpoints")
 	(&controller.Controller{
 		Log:      t.Log.Named("controller/endpoints"),
@@ -254,16 +341,8 @@ func (t *ServiceResource) Run(ch <-chan struct{}) {
 
 // shouldSync returns true if resyncing should be enabled for the given service.
 func (t *ServiceResource) shouldSync(svc *apiv1.Service) bool {
-	// Namespace logic
-	// If in deny list, don't sync
-	if t.DenyK8sNamespacesSet.Contains(svc.Namespace) {
-		t.Log.Debug("[shouldSync] service is in the deny list", "svc.Namespace", svc.Namespace, "service", svc)
-		return false
-	}
-
-	// If not in allow list or allow list is not *, don't sync
-	if !t.AllowK8sNamespacesSet.Contains("*") && !t.AllowK8sNamespacesSet.Contains(svc.Namespace) {
-		t.Log.Debug("[shouldSync] service not in allow list", "svc.Namespace", svc.Namespace, "service", svc)
+	if !t.shouldSyncNamespace(svc.Namespace) {
+		t.Log.Debug("[shouldSync] service namespace is not synced", "svc.Namespace", svc.Namespace, "service", svc)
 		return false
 	}
 
@@ -273,7 +352,25 @@ func (t *ServiceResource) shouldSync(svc *apiv1.Service) bool {
 		return false
 	}
 
-	raw, ok := svc.Annotations[annotationServiceSync]
+	return t.syncAnnotationEnabled(svc.ObjectMeta)
+}
+
+// shouldSyncNamespace returns true if objects in the given k8s namespace
+// are eligible for syncing based on the allow and deny lists.
+func (t *ServiceResource) shouldSyncNamespace(namespace string) bool {
+	// If in deny list, don't sync
+	if t.DenyK8sNamespacesSet.Contains(namespace) {
+		return false
+	}
+
+	// If not in allow list or allow list is not *, don't sync
+	return t.AllowK8sNamespacesSet.Contains("*") || t.AllowK8sNamespacesSet.Contains(namespace)
+}
+
+// syncAnnotationEnabled returns the value of the service-sync annotation
+// on the object, falling back to the default when it isn't set.
+func (t *ServiceResource) syncAnnotationEnabled(meta metav1.ObjectMeta) bool {
+	raw, ok := meta.Annotations[annotationServiceSync]
 	if !ok {
 		// If there is no explicit value, then set it to our current default.
 		return !t.ExplicitEnable
@@ -282,7 +379,7 @@ func (t *ServiceResource) shouldSync(svc *apiv1.Service) bool {
 	v, err := strconv.ParseBool(raw)
 	if err != nil {
 		t.Log.Warn("error parsing service-sync annotation",
-			"service-name", t.addPrefixAndK8SNamespace(svc.Name, svc.Namespace),
+			"service-name", t.addPrefixAndK8SNamespace(meta.Name, meta.Namespace),
 			"err", err)
 
 		// Fallback to default
@@ -340,39 +437,8 @@ func (t *ServiceResource) generateRegistrations(key string) {
 	// baseNode and baseService are the base that should be modified with
 	// service-type specific changes. These are not pointers, they should be
 	// shallow copied for each instance.
-	baseNode := consulapi.CatalogRegistration{
-		SkipNodeUpdate: true,
-		Node:           t.ConsulNodeName,
-		Address:        "127.0.0.1",
-		NodeMeta: map[string]string{
-			ConsulSourceKey: ConsulSourceValue,
-		},
-	}
-
-	baseService := consulapi.AgentService{
-		Service: t.addPrefixAndK8SNamespace(svc.Name, svc.Namespace),
-		Tags:    []string{t.ConsulK8STag},
-		Meta: map[string]string{
-			ConsulSourceKey: ConsulSourceValue,
-			ConsulK8SNS:     svc.Namespace,
-		},
-	}
-
-	// If the name is explicitly annotated, adopt that name
-	if v, ok := svc.Annotations[annotationServiceName]; ok {
-		baseService.Service = strings.TrimSpace(v)
-	}
-
-	// Update the Consul namespace based on namespace settings
-	consulNS := namespaces.ConsulNamespace(svc.Namespace,
-		t.EnableNamespaces,
-		t.ConsulDestinationNamespace,
-		t.EnableK8SNSMirroring,
-		t.K8SNSMirroringPrefix)
-	if consulNS != "" {
-		t.Log.Debug("[generateRegistrations] namespace being used", "key", key, "namespace", consulNS)
-		baseService.Namespace = consulNS
-	}
+	baseNode := t.baseNode()
+	baseService := t.baseService(svc.ObjectMeta)
 
 	// Determine the default port and set port annotations
 	var overridePortName string
@@ -436,18 +502,8 @@ func (t *ServiceResource) generateRegistrations(key string) {
 		}
 	}
 
-	// Parse any additional tags
-	if rawTags, ok := svc.Annotations[annotationServiceTags]; ok {
-		baseService.Tags = append(baseService.Tags, parseTags(rawTags)...)
-	}
-
-	// Parse any additional meta
-	for k, v := range svc.Annotations {
-		if strings.HasPrefix(k, annotationServiceMetaPrefix) {
-			k = strings.TrimPrefix(k, annotationServiceMetaPrefix)
-			baseService.Meta[k] = v
-		}
-	}
+	// Parse any additional tags and meta
+	addAnnotationTagsAndMeta(&baseService, svc.Annotations)
 
 	// Always log what we generated
 	defer func() {
@@ -530,7 +586,7 @@ func (t *ServiceResource) generateRegistrations(key string) {
 				}
 
 				// Look up the node's ip address by getting node info
-				node, err := t.Client.CoreV1().Nodes().Get(t.Ctx, *subsetAddr.NodeName, metav1.GetOptions{})
+				node, err := t.getNode(*subsetAddr.NodeName)
 				if err != nil {
 					t.Log.Warn("error getting node info", "error", err)
 					continue
@@ -550,7 +606,7 @@ func (t *ServiceResource) generateRegistrations(key string) {
 				for _, address := range node.Status.Addresses {
 					if address.Type == expectedType {
 						found = true
-						r := baseNode
+						r := t.k8sNodeRegistration(baseNode, node)
 						rs := baseService
 						r.Service = &rs
 						r.Service.ID = serviceID(r.Service.Service, subsetAddr.IP)
@@ -571,7 +627,7 @@ func (t *ServiceResource) generateRegistrations(key string) {
 				if t.NodePortSync == ExternalFirst && !found {
 					for _, address := range node.Status.Addresses {
 						if address.Type == apiv1.NodeInternalIP {
-							r := baseNode
+							r := t.k8sNodeRegistration(baseNode, node)
 							rs := baseService
 							r.Service = &rs
 							r.Service.ID = serviceID(r.Service.Service, subsetAddr.IP)
@@ -597,6 +653,121 @@ func (t *ServiceResource) generateRegistrations(key string) {
 	}
 }
 
+// baseNode returns the node registration that all synced service instances
+// are registered with.
+func (t *ServiceResource) baseNode() consulapi.CatalogRegistration {
+	return consulapi.CatalogRegistration{
+		SkipNodeUpdate: true,
+		Node:           t.ConsulNodeName,
+		Address:        "127.0.0.1",
+		NodeMeta: map[string]string{
+			ConsulSourceKey: ConsulSourceValue,
+		},
+	}
+}
+
+// nodeRegistration returns the node registration for a service instance
+// running on the k8s node with the given name. If ConsulNodePerK8SNode isn't
+// set, the node name is nil or the node can't be read, baseNode is returned.
+func (t *ServiceResource) nodeRegistration(baseNode consulapi.CatalogRegistration, nodeName *string) consulapi.CatalogRegistration {
+	if !t.ConsulNodePerK8SNode || nodeName == nil || *nodeName == "" {
+		return baseNode
+	}
+
+	node, err := t.getNode(*nodeName)
+	if err != nil {
+		t.Log.Warn("error getting node info, registering with the sync node", "node", *nodeName, "error", err)
+		return baseNode
+	}
+	return t.k8sNodeRegistration(baseNode, node)
+}
+
+// k8sNodeRegistration returns the registration of the Consul node for the
+// given k8s node if ConsulNodePerK8SNode is set, or baseNode otherwise.
+// The Consul node is registered with the node's internal address, falling
+// back to its external address, and its topology labels as node meta.
+func (t *ServiceResource) k8sNodeRegistration(baseNode consulapi.CatalogRegistration, node *apiv1.Node) consulapi.CatalogRegistration {
+	if !t.ConsulNodePerK8SNode {
+		return baseNode
+	}
+
+	r := baseNode
+	r.Node = t.ConsulNodeName + "-" + node.Name
+	// The node address and meta are owned by the sync so they're updated
+	// with every registration.
+	r.SkipNodeUpdate = false
+	r.NodeMeta = map[string]string{
+		ConsulSourceKey:   ConsulSourceValue,
+		ConsulK8SSyncNode: t.ConsulNodeName,
+		ConsulK8SNodeName: node.Name,
+	}
+	if zone := node.Labels[apiv1.LabelTopologyZone]; zone != "" {
+		r.NodeMeta[ConsulK8SZone] = zone
+	}
+	if region := node.Labels[apiv1.LabelTopologyRegion]; region != "" {
+		r.NodeMeta[ConsulK8SRegion] = region
+	}
+
+	for _, addrType := range []apiv1.NodeAddressType{apiv1.NodeInternalIP, apiv1.NodeExternalIP} {
+		for _, address := range node.Status.Addresses {
+			if address.Type == addrType {
+				r.Address = address.Address
+				return r
+			}
+		}
+	}
+	return r
+}
+
+// baseService returns the service registration shared by all instances
+// generated from the given k8s object. It does not include the tags and
+// meta from the object's annotations.
+func (t *ServiceResource) baseService(meta metav1.ObjectMeta) consulapi.AgentService {
+	baseService := consulapi.AgentService{
+		Service: t.addPrefixAndK8SNamespace(meta.Name, meta.Namespace),
+		Tags:    []string{t.ConsulK8STag},
+		Meta: map[string]string{
+			ConsulSourceKey: ConsulSourceValue,
+			ConsulK8SNS:     meta.Namespace,
+		},
+	}
+
+	// If the name is explicitly annotated, adopt that name
+	if v, ok := meta.Annotations[annotationServiceName]; ok {
+		baseService.Service = strings.TrimSpace(v)
+	}
+
+	// Update the Consul namespace based on namespace settings
+	consulNS := namespaces.ConsulNamespace(meta.Namespace,
+		t.EnableNamespaces,
+		t.ConsulDestinationNamespace,
+		t.EnableK8SNSMirroring,
+		t.K8SNSMirroringPrefix)
+	if consulNS != "" {
+		t.Log.Debug("[baseService] namespace being used", "name", meta.Name, "namespace", consulNS)
+		baseService.Namespace = consulNS
+	}
+
+	return baseService
+}
+
+// addAnnotationTagsAndMeta adds the tags and meta set through annotations
+// to the service.
+func addAnnotationTagsAndMeta(svc *consulapi.AgentService, annotations map[string]string) {
+	// Parse any additional tags
+	if rawTags, ok := annotations[annotationServiceTags]; ok {
+		svc.Tags = append(svc.Tags, parseTags(rawTags)...)
+	}
+
+	// Parse any additional meta
+	for k, v := range annotations {
+		if strings.HasPrefix(k, annotationServiceMetaPrefix) {
+			k = strings.TrimPrefix(k, annotationServiceMetaPrefix)
+			svc.Meta[k] = v
+		}
+	}
+}
+
 func (t *ServiceResource) registerServiceInstance(
 	baseNode consulapi.CatalogRegistration,
 	baseService consulapi.AgentService,
@@ -614,6 +785,11 @@ func (t *ServiceResource) registerServiceInstance(
 		return
 	}
 
+	var zones map[string]string
+	if slices, ok := t.endpointSlicesMap[key]; ok {
+		zones = endpointslice.Zones(slicesList(slices))
+	}
+
 	seen := map[string]struct{}{}
 	for _, subset := range endpoints.Subsets {
 		// For ClusterIP services and if LoadBalancerEndpointsSync is true, we use the endpoint port instead
@@ -636,46 +812,141 @@ func (t *ServiceResource) registerServiceInstance(
 				break
 			}
 		}
-		for _, subsetAddr := range subset.Addresses {
-			addr := subsetAddr.IP
-			if addr == "" && useHostname {
-				addr = subsetAddr.Hostname
-			}
-			if addr == "" {
-				continue
+		// Not ready addresses are registered too, with a critical health
+		// check, so that Consul can fail over instead of the instances
+		// being removed and re-added on every readiness change.
+		for _, ready := range []bool{true, false} {
+			addresses := subset.Addresses
+			if !ready {
+				addresses = subset.NotReadyAddresses
 			}
 
-			// Its not clear whether K8S guarantees ready addresses to
-			// be unique so we maintain a set to prevent duplicates just
-			// in case.
-			if _, ok := seen[addr]; ok {
-				continue
-			}
-			seen[addr] = struct{}{}
+			for _, subsetAddr := range addresses {
+				addr := subsetAddr.IP
+				if addr == "" && useHostname {
+					addr = subsetAddr.Hostname
+				}
+				if addr == "" {
+					continue
+				}
 
-			r := baseNode
-			rs := baseService
-			r.Service = &rs
-			r.Service.ID = serviceID(r.Service.Service, addr)
-			r.Service.Address = addr
-			r.Service.Port = epPort
-			r.Service.Meta = make(map[string]string)
-			// Deepcopy baseService.Meta into r.Service.Meta as baseService is shared
-			// between all nodes of a service
-			for k, v := range baseService.Meta {
-				r.Service.Meta[k] = v
-			}
-			if subsetAddr.TargetRef != nil {
-				r.Service.Meta[ConsulK8SRefValue] = subsetAddr.TargetRef.Name
-				r.Service.Meta[ConsulK8SRefKind] = subsetAddr.TargetRef.Kind
-			}
-			if subsetAddr.NodeName != nil {
-				r.Service.Meta[ConsulK8SNodeName] = *subsetAddr.NodeName
+				// Its not clear whether K8S guarantees ready addresses to
+				// be unique so we maintain a set to prevent duplicates just
+				// in case.
+				if _, ok := seen[addr]; ok {
+					continue
+				}
+				seen[addr] = struct{}{}
+
+				r := t.nodeRegistration(baseNode, subsetAddr.NodeName)
+				rs := baseService
+				r.Service = &rs
+				r.Service.ID = serviceID(r.Service.Service, addr)
+				r.Service.Address = addr
+				r.Service.Port = epPort
+				r.Service.Meta = make(map[string]string)
+				// Deepcopy baseService.Meta into r.Service.Meta as baseService is shared
+				// between all nodes of a service
+				for k, v := range baseService.Meta {
+					r.Service.Meta[k] = v
+				}
+				if subsetAddr.TargetRef != nil {
+					r.Service.Meta[ConsulK8SRefValue] = subsetAddr.TargetRef.Name
+					r.Service.Meta[ConsulK8SRefKind] = subsetAddr.TargetRef.Kind
+				}
+				if subsetAddr.NodeName != nil {
+					r.Service.Meta[ConsulK8SNodeName] = *subsetAddr.NodeName
+				}
+				if zone, ok := zones[addr]; ok {
+					r.Service.Meta[ConsulK8SZone] = zone
+				}
+				r.Check = t.readinessCheck(r.Service, subsetAddr, ready)
+
+				t.consulMap[key] = append(t.consulMap[key], &r)
 			}
+		}
+	}
+}
 
-			t.consulMap[key] = append(t.consulMap[key], &r)
+// readinessCheck returns the health check registered with a service instance
+// that reflects the readiness of its endpoint address in Kubernetes. For
+// addresses that aren't ready, the output includes the failing conditions of
+// the pod the address refers to.
+func (t *ServiceResource) readinessCheck(svc *consulapi.AgentService, addr apiv1.EndpointAddress, ready bool) *consulapi.AgentCheck {
+	check := &consulapi.AgentCheck{
+		CheckID:   consulHealthCheckID(svc.ID),
+		Name:      consulKubernetesCheckName,
+		Type:      consulKubernetesCheckType,
+		Status:    consulapi.HealthPassing,
+		ServiceID: svc.ID,
+		Namespace: svc.Namespace,
+		Output:    kubernetesSuccessReasonMsg,
+	}
+	if ready {
+		return check
+	}
+
+	check.Status = consulapi.HealthCritical
+	check.Output = "Kubernetes endpoint is not ready"
+	if addr.TargetRef == nil || addr.TargetRef.Kind != "Pod" {
+		return check
+	}
+
+	check.Output = fmt.Sprintf("Pod \"%s/%s\" is not ready", addr.TargetRef.Namespace, addr.TargetRef.Name)
+	pod, err := t.getPod(addr.TargetRef.Namespace, addr.TargetRef.Name)
+	if err != nil {
+		t.Log.Warn("error getting pod info", "pod", addr.TargetRef.Name, "namespace", addr.TargetRef.Namespace, "error", err)
+		return check
+	}
+	var failing []string
+	for _, cond := range pod.Status.Conditions {
+		if cond.Status == apiv1.ConditionTrue {
+			continue
+		}
+		msg := fmt.Sprintf("%s is %s", cond.Type, cond.Status)
+		if cond.Reason != "" {
+			msg = fmt.Sprintf("%s (%s)", msg, cond.Reason)
+		}
+		if cond.Message != "" {
+			msg = fmt.Sprintf("%s: %s", msg, cond.Message)
+		}
+		failing = append(failing, msg)
+	}
+	if len(failing) > 0 {
+		check.Output = fmt.Sprintf("%s: %s", check.Output, strings.Join(failing, "; "))
+	}
+	return check
+}
+
+// getPod returns the pod from PodLister, falling back to the API server if
+// it isn't cached yet.
+func (t *ServiceResource) getPod(namespace, name string) (*apiv1.Pod, error) {
+	if t.PodLister != nil {
+		pod, err := t.PodLister.Pods(namespace).Get(name)
+		if !k8serrors.IsNotFound(err) {
+			return pod, err
+		}
+	}
+	return t.Client.CoreV1().Pods(namespace).Get(t.Ctx, name, metav1.GetOptions{})
+}
+
+// getNode returns the node from NodeLister, falling back to the API server if
+// it isn't cached yet.
+func (t *ServiceResource) getNode(name string) (*apiv1.Node, error) {
+	if t.NodeLister != nil {
+		node, err := t.NodeLister.Get(name)
+		if !k8serrors.IsNotFound(err) {
+			return node, err
 		}
 	}
+	return t.Client.CoreV1().Nodes().Get(t.Ctx, name, metav1.GetOptions{})
+}
+
+// consulHealthCheckID deterministically generates the ID of the readiness
+// check of a service instance. It is unique on the synthetic node since
+// service IDs are.
+func consulHealthCheckID(serviceID string) string {
+	return fmt.Sprintf("%s/%s", serviceID, consulKubernetesCheckType)
 }
 
 // sync calls the Syncer.Sync function from the generated registrations.
@@ -686,10 +957,13 @@ func (t *ServiceResource) sync() {
 	// the times that sync are called are also not the most efficient. All
 	// of these are implementation details so lets improve this later when
 	// it becomes a performance issue and just do the easy thing first.
-	rs := make([]*consulapi.CatalogRegistration, 0, len(t.consulMap)*4)
+	rs := make([]*consulapi.CatalogRegistration, 0, (len(t.consulMap)+len(t.ingressConsulMap))*4)
 	for _, set := range t.consulMap {
 		rs = append(rs, set...)
 	}
+	for _, set := range t.ingressConsulMap {
+		rs = append(rs, set...)
+	}
 
 	// Sync, which should be non-blocking in real-world cases
 	t.Syncer.Sync(rs)
@@ -776,6 +1050,141 @@ func (t *serviceEndpointsResource) Delete(key string, _ interface{}) error {
 	return nil
 }
 
+// serviceEndpointSlicesResource implements controller.Resource and starts
+// a background watcher on EndpointSlices that is used by the ServiceResource
+// to keep track of changing endpoints for registered services when
+// EnableEndpointSlices is set.
+type serviceEndpointSlicesResource struct {
+	Service *ServiceResource
+	Ctx     context.Context
+}
+
+func (t *serviceEndpointSlicesResource) Informer() cache.SharedIndexInformer {
+	// Watch all k8s namespaces. Events will be filtered out as appropriate in the
+	// `shouldTrackEndpoints` function which checks whether the service is marked
+	// to be tracked by the `shouldSync` function which uses the allow and deny
+	// namespace lists.
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+				return t.Service.Client.DiscoveryV1().
+					EndpointSlices(metav1.NamespaceAll).
+					List(t.Ctx, options)
+			},
+
+			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+				return t.Service.Client.DiscoveryV1().
+					EndpointSlices(metav1.NamespaceAll).
+					Watch(t.Ctx, options)
+			},
+		},
+		&discoveryv1.EndpointSlice{},
+		0,
+		cache.Indexers{},
+	)
+}
+
+func (t *serviceEndpointSlicesResource) Upsert(key string, raw interface{}) error {
+	svc := t.Service
+	slice, ok := raw.(*discoveryv1.EndpointSlice)
+	if !ok {
+		svc.Log.Warn("upsert got invalid type", "raw", raw)
+		return nil
+	}
+
+	svc.serviceLock.Lock()
+	defer svc.serviceLock.Unlock()
+
+	// Check if we care about endpoints for the service of this slice
+	svcKey := serviceKeyForSlice(slice)
+	if svcKey == "" || !svc.shouldTrackEndpoints(svcKey) {
+		return nil
+	}
+
+	// We are tracking this service so let's keep track of the endpoints
+	svc.setEndpointSlice(svcKey, slice)
+
+	// Update the registration and trigger a sync
+	svc.generateRegistrations(svcKey)
+	svc.sync()
+	svc.Log.Info("upsert endpoint slice", "key", key, "service", svcKey)
+	return nil
+}
+
+func (t *serviceEndpointSlicesResource) Delete(key string, raw interface{}) error {
+	svc := t.Service
+	slice, ok := raw.(*discoveryv1.EndpointSlice)
+	if !ok {
+		svc.Log.Warn("delete got invalid type", "raw", raw)
+		return nil
+	}
+
+	svc.serviceLock.Lock()
+	defer svc.serviceLock.Unlock()
+
+	// This is a bit of an optimization. We only want to force a resync
+	// if we were tracking this endpoint slice to begin with.
+	svcKey := serviceKeyForSlice(slice)
+	if _, ok := svc.endpointSlicesMap[svcKey][slice.Name]; ok {
+		delete(svc.endpointSlicesMap[svcKey], slice.Name)
+		if len(svc.endpointSlicesMap[svcKey]) > 0 {
+			svc.endpointsMap[svcKey] = endpointslice.ToEndpoints(endpointslice.ServiceName(slice),
+				slice.Namespace, slicesList(svc.endpointSlicesMap[svcKey]))
+			svc.generateRegistrations(svcKey)
+			svc.sync()
+		} else {
+			delete(svc.endpointsMap, svcKey)
+			if _, ok := svc.consulMap[svcKey]; ok {
+				delete(svc.consulMap, svcKey)
+				svc.sync()
+			}
+		}
+	}
+
+	svc.Log.Info("delete endpoint slice", "key", key, "service", svcKey)
+	return nil
+}
+
+// setEndpointSlice stores the endpoint slice for the service with the
+// given key and merges the service's slices into its endpoints.
+//
+// Precondition: the lock t.serviceLock is held.
+func (t *ServiceResource) setEndpointSlice(key string, slice *discoveryv1.EndpointSlice) {
+	if t.endpointSlicesMap == nil {
+		t.endpointSlicesMap = make(map[string]map[string]*discoveryv1.EndpointSlice)
+	}
+	if t.endpointSlicesMap[key] == nil {
+		t.endpointSlicesMap[key] = make(map[string]*discoveryv1.EndpointSlice)
+	}
+	t.endpointSlicesMap[key][slice.Name] = slice
+
+	if t.endpointsMap == nil {
+		t.endpointsMap = make(map[string]*apiv1.Endpoints)
+	}
+	t.endpointsMap[key] = endpointslice.ToEndpoints(endpointslice.ServiceName(slice),
+		slice.Namespace, slicesList(t.endpointSlicesMap[key]))
+}
+
+// serviceKeyForSlice returns the key of the service the endpoint slice
+// belongs to, in the form <kube namespace>/<kube svc name>, or an empty
+// string if it doesn't belong to a service.
+func serviceKeyForSlice(slice *discoveryv1.EndpointSlice) string {
+	name := endpointslice.ServiceName(slice)
+	if name == "" {
+		return ""
+	}
+	return slice.Namespace + "/" + name
+}
+
+// slicesList returns the endpoint slices in the map as a list.
+func slicesList(slices map[string]*discoveryv1.EndpointSlice) []discoveryv1.EndpointSlice {
+	list := make([]discoveryv1.EndpointSlice, 0, len(slices))
+	for _, slice := range slices {
+		list = append(list, *slice)
+	}
+	return list
+}
+
 func (t *ServiceResource) addPrefixAndK8SNamespace(name, namespace string) string {
 	if t.ConsulServicePrefix != "" {
 		name = fmt.Sprintf("%s%s", t.ConsulServicePrefix, name)
@@ -6,14 +6,19 @@ import (
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
+	consulapi "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/hashicorp/go-hclog"
 	"github.com/stretchr/testify/require"
 	apiv1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
 )
 
 const nodeName1 = "ip-10-11-12-13.ec2.internal"
@@ -1303,6 +1308,278 @@ func TestServiceResource_targetRefInMeta(t *testing.T) {
 	})
 }
 
+// Test that each instance is registered with a readiness check reflecting
+// whether its endpoint address is ready.
+func TestServiceResource_readinessCheck(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the pod that is not ready
+	_, err := client.CoreV1().Pods(metav1.NamespaceDefault).Create(context.Background(), &apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "not-ready",
+			Namespace: metav1.NamespaceDefault,
+		},
+		Status: apiv1.PodStatus{
+			Conditions: []apiv1.PodCondition{
+				{Type: apiv1.PodScheduled, Status: apiv1.ConditionTrue},
+				{Type: apiv1.PodReady, Status: apiv1.ConditionFalse, Reason: "ContainersNotReady", Message: "containers with unready status: [web]"},
+			},
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	_, err = client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert the endpoints with a ready and a not ready address
+	_, err = client.CoreV1().Endpoints(metav1.NamespaceDefault).Create(
+		context.Background(),
+		&apiv1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "foo",
+				Namespace: metav1.NamespaceDefault,
+			},
+			Subsets: []apiv1.EndpointSubset{
+				{
+					Addresses: []apiv1.EndpointAddress{
+						{IP: "1.1.1.1"},
+					},
+					NotReadyAddresses: []apiv1.EndpointAddress{
+						{IP: "2.2.2.2", TargetRef: &apiv1.ObjectReference{Kind: "Pod", Name: "not-ready", Namespace: metav1.NamespaceDefault}},
+					},
+					Ports: []apiv1.EndpointPort{
+						{Name: "http", Port: 8080},
+					},
+				},
+			},
+		},
+		metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+
+		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
+		require.Equal(r, &consulapi.AgentCheck{
+			CheckID:   actual[0].Service.ID + "/kubernetes-readiness",
+			Name:      "Kubernetes Readiness Check",
+			Type:      "kubernetes-readiness",
+			Status:    consulapi.HealthPassing,
+			ServiceID: actual[0].Service.ID,
+			Output:    "Kubernetes health checks passing",
+		}, actual[0].Check)
+
+		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
+		require.Equal(r, &consulapi.AgentCheck{
+			CheckID:   actual[1].Service.ID + "/kubernetes-readiness",
+			Name:      "Kubernetes Readiness Check",
+			Type:      "kubernetes-readiness",
+			Status:    consulapi.HealthCritical,
+			ServiceID: actual[1].Service.ID,
+			Output:    `Pod "default/not-ready" is not ready: Ready is False (ContainersNotReady): containers with unready status: [web]`,
+		}, actual[1].Check)
+	})
+}
+
+// Test that the pods of not ready addresses are read from the PodLister.
+func TestServiceResource_readinessCheckPodLister(t *testing.T) {
+	t.Parallel()
+	// The pod only exists in the lister's cache so that the check output
+	// only includes its conditions if it's read from the lister.
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(&apiv1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "not-ready",
+			Namespace: metav1.NamespaceDefault,
+		},
+		Status: apiv1.PodStatus{
+			Conditions: []apiv1.PodCondition{
+				{Type: apiv1.PodReady, Status: apiv1.ConditionFalse, Reason: "ContainersNotReady"},
+			},
+		},
+	}))
+	serviceResource := defaultServiceResource(fake.NewSimpleClientset(), newTestSyncer())
+	serviceResource.PodLister = corelisters.NewPodLister(indexer)
+
+	check := serviceResource.readinessCheck(&consulapi.AgentService{ID: "foo-2.2.2.2"}, apiv1.EndpointAddress{
+		IP:        "2.2.2.2",
+		TargetRef: &apiv1.ObjectReference{Kind: "Pod", Name: "not-ready", Namespace: metav1.NamespaceDefault},
+	}, false)
+	require.Equal(t, `Pod "default/not-ready" is not ready: Ready is False (ContainersNotReady)`, check.Output)
+}
+
+// Test that nodes are read from the node lister when it's set.
+func TestServiceResource_getNodeLister(t *testing.T) {
+	t.Parallel()
+	// The node only exists in the lister's cache so that it can only be
+	// found if it's read from the lister.
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	require.NoError(t, indexer.Add(&apiv1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: nodeName1},
+	}))
+	serviceResource := defaultServiceResource(fake.NewSimpleClientset(), newTestSyncer())
+	serviceResource.NodeLister = corelisters.NewNodeLister(indexer)
+
+	node, err := serviceResource.getNode(nodeName1)
+	require.NoError(t, err)
+	require.Equal(t, nodeName1, node.Name)
+
+	// Nodes that aren't cached are read from the API server.
+	_, err = serviceResource.getNode(nodeName2)
+	require.True(t, k8serrors.IsNotFound(err))
+}
+
+// Test that the endpoint slices of a service are merged into registrations
+// when endpoint slices are enabled.
+func TestServiceResource_endpointSlices(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+	serviceResource.EnableEndpointSlices = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the service
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Insert two slices for the service, one with a terminating endpoint
+	node := nodeName1
+	zone := "us-east-1a"
+	ready, terminating := false, true
+	port, portName := int32(8080), "http"
+	for name, endpoint := range map[string]discoveryv1.Endpoint{
+		"foo-a": {Addresses: []string{"1.1.1.1"}, NodeName: &node, Zone: &zone},
+		"foo-b": {Addresses: []string{"2.2.2.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready, Terminating: &terminating}},
+	} {
+		_, err = client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Create(context.Background(), &discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: metav1.NamespaceDefault,
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "foo"},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints:   []discoveryv1.Endpoint{endpoint},
+			Ports:       []discoveryv1.EndpointPort{{Name: &portName, Port: &port}},
+		}, metav1.CreateOptions{})
+		require.NoError(t, err)
+	}
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
+		require.Equal(r, 8080, actual[0].Service.Port)
+		require.Equal(r, nodeName1, actual[0].Service.Meta[ConsulK8SNodeName])
+		require.Equal(r, zone, actual[0].Service.Meta[ConsulK8SZone])
+		require.Equal(r, consulapi.HealthPassing, actual[0].Check.Status)
+		require.Equal(r, "2.2.2.2", actual[1].Service.Address)
+		require.NotContains(r, actual[1].Service.Meta, ConsulK8SZone)
+		require.Equal(r, consulapi.HealthCritical, actual[1].Check.Status)
+	})
+
+	// Deleting a slice removes its instances only
+	require.NoError(t, client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Delete(context.Background(), "foo-b", metav1.DeleteOptions{}))
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 1)
+		require.Equal(r, "1.1.1.1", actual[0].Service.Address)
+	})
+
+	// Deleting the last slice removes the registrations
+	require.NoError(t, client.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).Delete(context.Background(), "foo-a", metav1.DeleteOptions{}))
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		require.Len(r, syncer.Registrations, 0)
+	})
+}
+
+// Test that instances are registered under a Consul node per k8s node
+// when ConsulNodePerK8SNode is set.
+func TestServiceResource_consulNodePerK8SNode(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	syncer := newTestSyncer()
+	serviceResource := defaultServiceResource(client, syncer)
+	serviceResource.ClusterIPSync = true
+	serviceResource.ConsulNodePerK8SNode = true
+
+	// Start the controller
+	closer := controller.TestControllerRun(&serviceResource)
+	defer closer()
+
+	// Insert the nodes, with topology labels on the first one
+	node1, _ := createNodes(t, client)
+	node1.Labels = map[string]string{
+		apiv1.LabelTopologyZone:   "us-east-1a",
+		apiv1.LabelTopologyRegion: "us-east-1",
+	}
+	_, err := client.CoreV1().Nodes().Update(context.Background(), node1, metav1.UpdateOptions{})
+	require.NoError(t, err)
+
+	// Insert the service and endpoints
+	svc := clusterIPService("foo", metav1.NamespaceDefault)
+	_, err = client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), svc, metav1.CreateOptions{})
+	require.NoError(t, err)
+	createEndpoints(t, client, "foo", metav1.NamespaceDefault)
+
+	// Verify what we got
+	retry.Run(t, func(r *retry.R) {
+		syncer.Lock()
+		defer syncer.Unlock()
+		actual := syncer.Registrations
+		require.Len(r, actual, 2)
+		byAddr := map[string]*consulapi.CatalogRegistration{}
+		for _, reg := range actual {
+			byAddr[reg.Service.Address] = reg
+		}
+
+		reg := byAddr["1.1.1.1"]
+		require.NotNil(r, reg)
+		require.Equal(r, ConsulSyncNodeName+"-"+nodeName1, reg.Node)
+		require.Equal(r, "4.5.6.7", reg.Address)
+		require.False(r, reg.SkipNodeUpdate)
+		require.Equal(r, map[string]string{
+			ConsulSourceKey:   ConsulSourceValue,
+			ConsulK8SSyncNode: ConsulSyncNodeName,
+			ConsulK8SNodeName: nodeName1,
+			ConsulK8SZone:     "us-east-1a",
+			ConsulK8SRegion:   "us-east-1",
+		}, reg.NodeMeta)
+
+		reg = byAddr["2.2.2.2"]
+		require.NotNil(r, reg)
+		require.Equal(r, ConsulSyncNodeName+"-"+nodeName2, reg.Node)
+		require.Equal(r, "3.4.5.6", reg.Address)
+		require.NotContains(r, reg.NodeMeta, ConsulK8SZone)
+	})
+}
+
 // Test allow/deny namespace lists.
 func TestServiceResource_AllowDenyNamespaces(t *testing.T) {
 	t.Parallel()
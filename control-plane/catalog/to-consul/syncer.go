@@ -2,14 +2,17 @@ package catalog
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff"
 	mapset "github.com/deckarep/golang-set"
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-hclog"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 const (
@@ -73,6 +76,15 @@ type ConsulSyncer struct {
 	// separate client for this API call that handles older version of Consul.
 	ConsulNodeServicesClient ConsulNodeServicesClient
 
+	// Metrics records the metrics of the sync. If nil, metrics are
+	// recorded to a registry that isn't exposed.
+	Metrics *metrics.Metrics
+
+	// DryRun disables all writes to Consul. The registrations and
+	// deregistrations that would have been written are logged instead and
+	// available from Plan.
+	DryRun bool
+
 	lock sync.Mutex
 	once sync.Once
 
@@ -93,21 +105,49 @@ type ConsulSyncer struct {
 	namespaces map[string]map[string]*api.CatalogRegistration
 	deregs     map[string]*api.CatalogDeregistration
 
+	// nodes is the set of Consul node names that services are registered
+	// with. nodeDeregs are the Consul nodes registered for k8s nodes that
+	// no longer have any services, mapped by node name.
+	nodes      mapset.Set
+	nodeDeregs map[string]*api.CatalogDeregistration
+
 	// watchers is all namespaces mapped to a map of Consul service
 	// names mapped to a cancel function for watcher routines
 	watchers map[string]map[string]context.CancelFunc
+
+	// plan is the result of the last full sync in dry-run mode.
+	plan SyncPlan
+}
+
+// SyncPlan holds the writes a full sync would have made to Consul in
+// dry-run mode. The registrations are split by how they compare to the
+// service instances in Consul: Create holds instances that don't exist,
+// Update holds instances that differ and Unchanged holds instances that
+// match, so re-registering them is a no-op.
+type SyncPlan struct {
+	Create     []*api.CatalogRegistration   `json:"create"`
+	Update     []*api.CatalogRegistration   `json:"update"`
+	Unchanged  []*api.CatalogRegistration   `json:"unchanged"`
+	Deregister []*api.CatalogDeregistration `json:"deregister"`
 }
 
 // Sync implements Syncer.
 func (s *ConsulSyncer) Sync(rs []*api.CatalogRegistration) {
+	// Sync may be called before Run, e.g. on standby replicas with leader
+	// election, so the state must be initialized here too.
+	s.once.Do(s.init)
+
 	// Grab the lock so we can replace the sync state
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
 	s.serviceNames = make(map[string]mapset.Set)
 	s.namespaces = make(map[string]map[string]*api.CatalogRegistration)
+	s.nodes = mapset.NewSet()
 
 	for _, r := range rs {
+		s.nodes.Add(r.Node)
+
 		// Determine the namespace the service is in to use for indexing
 		// against the s.serviceNames and s.namespaces maps.
 		// This will be "" for OSS.
@@ -140,6 +180,7 @@ func (s *ConsulSyncer) Run(ctx context.Context) {
 
 	// Start the background watchers
 	go s.watchReapableServices(ctx)
+	go s.watchReapableNodes(ctx)
 
 	reconcileTimer := time.NewTimer(s.SyncPeriod)
 	defer reconcileTimer.Stop()
@@ -188,6 +229,9 @@ func (s *ConsulSyncer) watchReapableServices(ctx context.Context) {
 		err := backoff.Retry(func() error {
 			var err error
 			services, meta, err = s.ConsulNodeServicesClient.NodeServices(s.ConsulK8STag, s.ConsulNodeName, *opts)
+			if err != nil {
+				s.Metrics.ObserveError(metrics.DirectionToConsul, "node_services")
+			}
 			return err
 		}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
 
@@ -215,35 +259,111 @@ func (s *ConsulSyncer) watchReapableServices(ctx context.Context) {
 
 		// Lock so we can modify the stored state
 		s.lock.Lock()
+		s.reapUntrackedServicesLocked(services)
+		s.lock.Unlock()
+	}
+}
 
-		// Go through the service array and find services that should be reaped
-		for _, service := range services {
-			// Check that the namespace exists in the valid service names map
-			// before checking whether it contains the service
-			if _, ok := s.serviceNames[service.Namespace]; ok {
-				// We only care if we don't know about this service at all.
-				if s.serviceNames[service.Namespace].Contains(service.Name) {
-					s.Log.Debug("[watchReapableServices] serviceNames contains service",
-						"namespace", service.Namespace,
-						"service-name", service.Name)
-					continue
-				}
-			}
+// watchReapableNodes is a long-running task started by Run that
+// periodically looks for the Consul nodes registered for k8s nodes.
+// Nodes that are no longer used by any registration are scheduled for
+// deletion, and the services on the remaining nodes are checked for
+// services that need to be reaped like in watchReapableServices.
+func (s *ConsulSyncer) watchReapableNodes(ctx context.Context) {
+	// We must wait for the initial sync to be complete and our maps to be
+	// populated, see watchReapableServices.
+	select {
+	case <-s.initialSync:
+	case <-ctx.Done():
+		return
+	}
 
-			s.Log.Info("invalid service found, scheduling for delete",
-				"service-name", service.Name, "service-consul-namespace", service.Namespace)
-			if err := s.scheduleReapServiceLocked(service.Name, service.Namespace); err != nil {
-				s.Log.Info("error querying service for delete",
-					"service-name", service.Name,
-					"service-consul-namespace", service.Namespace,
-					"err", err)
-			}
+	for {
+		s.reapNodes()
+
+		select {
+		case <-time.After(s.ServicePollPeriod):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reapNodes schedules the removal of the Consul nodes registered for k8s
+// nodes that no longer have registrations, and of the untracked services
+// on all other nodes registered for k8s nodes.
+func (s *ConsulSyncer) reapNodes() {
+	opts := &api.QueryOptions{
+		AllowStale: true,
+		NodeMeta:   map[string]string{ConsulK8SSyncNode: s.ConsulNodeName},
+	}
+	nodes, _, err := s.Client.Catalog().Nodes(opts)
+	if err != nil {
+		s.Metrics.ObserveError(metrics.DirectionToConsul, "catalog_nodes")
+		s.Log.Warn("error querying nodes, will retry", "err", err)
+		return
+	}
+
+	for _, node := range nodes {
+		s.lock.Lock()
+		tracked := s.nodes != nil && s.nodes.Contains(node.Node)
+		if !tracked {
+			s.Log.Info("invalid node found, scheduling for delete", "node-name", node.Node)
+			s.nodeDeregs[node.Node] = &api.CatalogDeregistration{Node: node.Node}
+		}
+		s.lock.Unlock()
+		if !tracked {
+			continue
 		}
 
+		svcOpts := api.QueryOptions{AllowStale: true}
+		if s.EnableNamespaces {
+			svcOpts.Namespace = "*"
+		}
+		services, _, err := s.ConsulNodeServicesClient.NodeServices(s.ConsulK8STag, node.Node, svcOpts)
+		if err != nil {
+			s.Metrics.ObserveError(metrics.DirectionToConsul, "node_services")
+			s.Log.Warn("error querying node services, will retry", "node-name", node.Node, "err", err)
+			continue
+		}
+
+		s.lock.Lock()
+		s.reapUntrackedServicesLocked(services)
 		s.lock.Unlock()
 	}
 }
 
+// reapUntrackedServicesLocked schedules the removal of the given services
+// if they aren't known to the sync at all.
+//
+// Precondition: lock must be held.
+func (s *ConsulSyncer) reapUntrackedServicesLocked(services []ConsulService) {
+	// Go through the service array and find services that should be reaped
+	for _, service := range services {
+		// Check that the namespace exists in the valid service names map
+		// before checking whether it contains the service
+		if _, ok := s.serviceNames[service.Namespace]; ok {
+			// We only care if we don't know about this service at all.
+			if s.serviceNames[service.Namespace].Contains(service.Name) {
+				s.Log.Debug("[reapUntrackedServicesLocked] serviceNames contains service",
+					"namespace", service.Namespace,
+					"service-name", service.Name)
+				continue
+			}
+		}
+
+		s.Log.Info("invalid service found, scheduling for delete",
+			"service-name", service.Name, "service-consul-namespace", service.Namespace)
+		if err := s.scheduleReapServiceLocked(service.Name, service.Namespace); err != nil {
+			s.Metrics.ObserveError(metrics.DirectionToConsul, "catalog_service")
+			s.Log.Info("error querying service for delete",
+				"service-name", service.Name,
+				"service-consul-namespace", service.Namespace,
+				"err", err)
+		}
+	}
+}
+
 // watchService watches all instances of a service by name for changes
 // and schedules re-registration or deletion if necessary.
 func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string) {
@@ -274,6 +394,9 @@ func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string)
 		err := backoff.Retry(func() error {
 			var err error
 			services, _, err = s.Client.Catalog().Service(name, s.ConsulK8STag, queryOpts)
+			if err != nil {
+				s.Metrics.ObserveError(metrics.DirectionToConsul, "catalog_service")
+			}
 			return err
 		}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
 		if err != nil {
@@ -290,8 +413,11 @@ func (s *ConsulSyncer) watchService(ctx context.Context, name, namespace string)
 		for _, svc := range services {
 			// Make sure the namespace exists before we run checks against it
 			if _, ok := s.serviceNames[namespace]; ok {
-				// If the service is valid and its info isn't nil, we don't deregister it
-				if s.serviceNames[namespace].Contains(svc.ServiceName) && s.namespaces[namespace][svc.ServiceID] != nil {
+				// If the service is valid and registered on the same node, we don't
+				// deregister it. Instances move between nodes when registering
+				// per k8s node is enabled or disabled.
+				r := s.namespaces[namespace][svc.ServiceID]
+				if s.serviceNames[namespace].Contains(svc.ServiceName) && r != nil && r.Node == svc.Node {
 					continue
 				}
 			}
@@ -330,6 +456,7 @@ func (s *ConsulSyncer) scheduleReapServiceLocked(name, namespace string) error {
 	if err != nil {
 		return err
 	}
+	s.Metrics.ReapedServices.Inc()
 
 	// Create deregistrations for all of these
 	for _, svc := range services {
@@ -359,6 +486,10 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 
 	s.Log.Info("registering services")
 
+	// synced is set to false if any write fails so that the time of the
+	// last successful sync is only updated if the full sync succeeded.
+	synced := true
+
 	// Update the service watchers
 	for ns, watchers := range s.watchers {
 		// If the service the watcher is watching is no longer valid,
@@ -373,7 +504,9 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 	}
 
 	// Start watchers for all services if they're not already running
+	var tracked int
 	for ns, services := range s.serviceNames {
+		tracked += services.Cardinality()
 		for svc := range services.Iter() {
 			if _, ok := s.watchers[ns][svc.(string)]; !ok {
 				svcCtx, cancelF := context.WithCancel(ctx)
@@ -390,6 +523,18 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 			}
 		}
 	}
+	s.Metrics.TrackedServices.WithLabelValues(metrics.DirectionToConsul).Set(float64(tracked))
+
+	// In dry-run mode the writes are only planned and logged. The lock is
+	// released while the registrations are compared against Consul.
+	if s.DryRun {
+		plan, registrations := s.planLocked()
+		s.lock.Unlock()
+		s.diffRegistrations(ctx, &plan, registrations)
+		s.lock.Lock()
+		s.plan = plan
+		return
+	}
 
 	// Do all deregistrations first
 	for _, r := range s.deregs {
@@ -397,19 +542,38 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 			"node-name", r.Node,
 			"service-id", r.ServiceID,
 			"service-consul-namespace", r.Namespace)
+		start := time.Now()
 		_, err := s.Client.Catalog().Deregister(r, nil)
+		s.Metrics.ObserveWrite(metrics.DirectionToConsul, "deregister", start, err)
 		if err != nil {
 			s.Log.Warn("error deregistering service",
 				"node-name", r.Node,
 				"service-id", r.ServiceID,
 				"service-consul-namespace", r.Namespace,
 				"err", err)
+			synced = false
+			continue
 		}
+		s.Metrics.Deregistrations.WithLabelValues(metrics.DirectionToConsul).Inc()
 	}
 
 	// Always clear deregistrations, they'll repopulate if we had errors
 	s.deregs = make(map[string]*api.CatalogDeregistration)
 
+	// Deregister the nodes of k8s nodes that are no longer used, which also
+	// removes any services left on them.
+	for _, r := range s.nodeDeregs {
+		s.Log.Info("deregistering node", "node-name", r.Node)
+		start := time.Now()
+		_, err := s.Client.Catalog().Deregister(r, nil)
+		s.Metrics.ObserveWrite(metrics.DirectionToConsul, "deregister_node", start, err)
+		if err != nil {
+			s.Log.Warn("error deregistering node", "node-name", r.Node, "err", err)
+			synced = false
+		}
+	}
+	s.nodeDeregs = make(map[string]*api.CatalogDeregistration)
+
 	// Register all the services. This will overwrite any changes that
 	// may have been made to the registered services.
 	for _, services := range s.namespaces {
@@ -417,6 +581,8 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 			if s.EnableNamespaces {
 				_, err := namespaces.EnsureExists(s.Client, r.Service.Namespace, s.CrossNamespaceACLPolicy)
 				if err != nil {
+					s.Metrics.ObserveError(metrics.DirectionToConsul, "ensure_namespace")
+					synced = false
 					s.Log.Warn("error checking and creating Consul namespace",
 						"node-name", r.Node,
 						"service-name", r.Service.Service,
@@ -427,15 +593,19 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 			}
 
 			// Register the service
+			start := time.Now()
 			_, err := s.Client.Catalog().Register(r, nil)
+			s.Metrics.ObserveWrite(metrics.DirectionToConsul, "register", start, err)
 			if err != nil {
 				s.Log.Warn("error registering service",
 					"node-name", r.Node,
 					"service-name", r.Service.Service,
 					"service", r.Service,
 					"err", err)
+				synced = false
 				continue
 			}
+			s.Metrics.Registrations.WithLabelValues(metrics.DirectionToConsul).Inc()
 
 			s.Log.Debug("registered service instance",
 				"node-name", r.Node,
@@ -444,6 +614,160 @@ func (s *ConsulSyncer) syncFull(ctx context.Context) {
 				"service", r.Service)
 		}
 	}
+
+	if synced {
+		s.Metrics.LastSyncTimestamp.WithLabelValues(metrics.DirectionToConsul).SetToCurrentTime()
+	}
+}
+
+// Plan returns the writes the last full sync would have made in dry-run
+// mode. It is empty if DryRun isn't set or no full sync has run yet.
+func (s *ConsulSyncer) Plan() SyncPlan {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.plan
+}
+
+// planLocked logs the pending deregistrations instead of writing them and
+// returns them as a plan along with the registrations to diff against
+// Consul. The deregistrations are cleared like after a real sync since the
+// watchers repopulate them.
+//
+// Precondition: lock must be held.
+func (s *ConsulSyncer) planLocked() (SyncPlan, []*api.CatalogRegistration) {
+	var plan SyncPlan
+	for _, r := range s.deregs {
+		s.Log.Info("[dry-run] would deregister service",
+			"node-name", r.Node,
+			"service-id", r.ServiceID,
+			"service-consul-namespace", r.Namespace)
+		plan.Deregister = append(plan.Deregister, r)
+	}
+	s.deregs = make(map[string]*api.CatalogDeregistration)
+
+	for _, r := range s.nodeDeregs {
+		s.Log.Info("[dry-run] would deregister node", "node-name", r.Node)
+		plan.Deregister = append(plan.Deregister, r)
+	}
+	s.nodeDeregs = make(map[string]*api.CatalogDeregistration)
+
+	// Sort the plan so that it is stable between syncs.
+	sort.Slice(plan.Deregister, func(i, j int) bool {
+		if plan.Deregister[i].ServiceID != plan.Deregister[j].ServiceID {
+			return plan.Deregister[i].ServiceID < plan.Deregister[j].ServiceID
+		}
+		return plan.Deregister[i].Node < plan.Deregister[j].Node
+	})
+
+	var registrations []*api.CatalogRegistration
+	for _, services := range s.namespaces {
+		for _, r := range services {
+			registrations = append(registrations, r)
+		}
+	}
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].Service.ID < registrations[j].Service.ID
+	})
+	return plan, registrations
+}
+
+// diffRegistrations reads the instances of the registered services from
+// Consul and adds each registration to the create, update or unchanged
+// list of the plan. If a service can't be read, its registrations are
+// planned as updates since they would be written.
+func (s *ConsulSyncer) diffRegistrations(ctx context.Context, plan *SyncPlan, registrations []*api.CatalogRegistration) {
+	// existing maps from namespace and service name to the instances of
+	// the service in Consul by node and service ID.
+	type serviceKey struct{ namespace, name string }
+	existing := make(map[serviceKey]map[string]*api.CatalogService)
+	for _, r := range registrations {
+		key := serviceKey{r.Service.Namespace, r.Service.Service}
+		instances, ok := existing[key]
+		if !ok {
+			opts := (&api.QueryOptions{AllowStale: true}).WithContext(ctx)
+			if s.EnableNamespaces {
+				opts.Namespace = key.namespace
+			}
+			services, _, err := s.Client.Catalog().Service(key.name, s.ConsulK8STag, opts)
+			if err != nil {
+				s.Metrics.ObserveError(metrics.DirectionToConsul, "catalog_service")
+				s.Log.Warn("[dry-run] error querying service, planning its instances as updates",
+					"service-name", key.name,
+					"service-namespace", key.namespace,
+					"err", err)
+			} else {
+				instances = make(map[string]*api.CatalogService, len(services))
+				for _, svc := range services {
+					instances[svc.Node+"/"+svc.ServiceID] = svc
+				}
+			}
+			existing[key] = instances
+		}
+
+		svc, found := instances[r.Node+"/"+r.Service.ID]
+		switch {
+		case instances != nil && !found:
+			s.Log.Info("[dry-run] would create service instance",
+				"node-name", r.Node,
+				"service-name", r.Service.Service,
+				"service-id", r.Service.ID,
+				"consul-namespace-name", r.Service.Namespace)
+			plan.Create = append(plan.Create, r)
+		case found && registrationMatches(r, svc):
+			s.Log.Debug("[dry-run] service instance is unchanged",
+				"node-name", r.Node,
+				"service-name", r.Service.Service,
+				"service-id", r.Service.ID,
+				"consul-namespace-name", r.Service.Namespace)
+			plan.Unchanged = append(plan.Unchanged, r)
+		default:
+			s.Log.Info("[dry-run] would update service instance",
+				"node-name", r.Node,
+				"service-name", r.Service.Service,
+				"service-id", r.Service.ID,
+				"consul-namespace-name", r.Service.Namespace)
+			plan.Update = append(plan.Update, r)
+		}
+	}
+}
+
+// registrationMatches returns true if registering r would not change the
+// service instance in Consul. Health checks aren't compared since the
+// catalog service endpoint doesn't return them.
+func registrationMatches(r *api.CatalogRegistration, svc *api.CatalogService) bool {
+	if r.Node != svc.Node ||
+		r.Service.Service != svc.ServiceName ||
+		r.Service.Address != svc.ServiceAddress ||
+		r.Service.Port != svc.ServicePort {
+		return false
+	}
+	// The node is only written if it isn't skipped.
+	if !r.SkipNodeUpdate && (r.Address != svc.Address || !stringMapsEqual(r.NodeMeta, svc.NodeMeta)) {
+		return false
+	}
+	if len(r.Service.Tags) != len(svc.ServiceTags) {
+		return false
+	}
+	for i := range r.Service.Tags {
+		if r.Service.Tags[i] != svc.ServiceTags[i] {
+			return false
+		}
+	}
+	return stringMapsEqual(r.Service.Meta, svc.ServiceMeta)
+}
+
+// stringMapsEqual returns true if the maps hold the same key/value pairs.
+// A nil map is equal to an empty map.
+func stringMapsEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if other, ok := b[k]; !ok || other != v {
+			return false
+		}
+	}
+	return true
 }
 
 func (s *ConsulSyncer) init() {
@@ -458,6 +782,9 @@ func (s *ConsulSyncer) init() {
 	if s.deregs == nil {
 		s.deregs = make(map[string]*api.CatalogDeregistration)
 	}
+	if s.nodeDeregs == nil {
+		s.nodeDeregs = make(map[string]*api.CatalogDeregistration)
+	}
 	if s.watchers == nil {
 		s.watchers = make(map[string]map[string]context.CancelFunc)
 	}
@@ -470,4 +797,7 @@ func (s *ConsulSyncer) init() {
 	if s.initialSync == nil {
 		s.initialSync = make(chan bool)
 	}
+	if s.Metrics == nil {
+		s.Metrics = metrics.New(prometheus.NewRegistry())
+	}
 }
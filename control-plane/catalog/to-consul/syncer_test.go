@@ -2,9 +2,12 @@ package catalog
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -264,6 +267,143 @@ func TestConsulSyncer_stopsGracefully(t *testing.T) {
 	require.LessOrEqual(t, callCount-beforeStopAPICount, 2)
 }
 
+// Test that Sync can be called before Run, like on a standby replica with
+// leader election.
+func TestConsulSyncer_syncBeforeRun(t *testing.T) {
+	t.Parallel()
+
+	s := &ConsulSyncer{Log: hclog.Default()}
+	require.NotPanics(t, func() {
+		s.Sync([]*api.CatalogRegistration{testRegistration(ConsulSyncNodeName, "foo", "default")})
+	})
+	select {
+	case <-s.initialSync:
+	default:
+		t.Fatal("initial sync not signalled")
+	}
+}
+
+// Test that in dry-run mode the registrations are planned by how they
+// compare to Consul but not written.
+func TestConsulSyncer_dryRun(t *testing.T) {
+	t.Parallel()
+
+	unchanged := testRegistration(ConsulSyncNodeName, "foo", "default")
+	created := testRegistration(ConsulSyncNodeName, "bar", "default")
+	updated := testRegistration(ConsulSyncNodeName, "baz", "default")
+	updated.Service.Port = 8080
+
+	// catalogService returns the instance in Consul for the registration.
+	catalogService := func(r *api.CatalogRegistration) *api.CatalogService {
+		return &api.CatalogService{
+			Node:           r.Node,
+			Address:        r.Address,
+			ServiceID:      r.Service.ID,
+			ServiceName:    r.Service.Service,
+			ServiceTags:    r.Service.Tags,
+			ServiceMeta:    r.Service.Meta,
+			ServicePort:    r.Service.Port,
+			ServiceAddress: r.Service.Address,
+		}
+	}
+	instances := map[string][]*api.CatalogService{
+		"/v1/catalog/service/foo": {catalogService(unchanged)},
+		"/v1/catalog/service/bar": {},
+		"/v1/catalog/service/baz": {catalogService(testRegistration(ConsulSyncNodeName, "baz", "default"))},
+	}
+
+	// We use a test http server here so we can serve the instances and
+	// count the writes.
+	var writes int32
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			atomic.AddInt32(&writes, 1)
+		}
+		if services, ok := instances[r.URL.Path]; ok && r.Method == http.MethodGet {
+			require.NoError(t, json.NewEncoder(w).Encode(services))
+			return
+		}
+		w.WriteHeader(500)
+	}))
+	defer consulServer.Close()
+
+	client, err := api.NewClient(&api.Config{
+		Address: consulServer.URL,
+	})
+	require.NoError(t, err)
+	s, closer := testConsulSyncerWithConfig(client, func(s *ConsulSyncer) {
+		s.DryRun = true
+	})
+	defer closer()
+
+	// Sync
+	s.Sync([]*api.CatalogRegistration{unchanged, created, updated})
+
+	retry.Run(t, func(r *retry.R) {
+		plan := s.Plan()
+		require.Equal(r, []*api.CatalogRegistration{created}, plan.Create)
+		require.Equal(r, []*api.CatalogRegistration{updated}, plan.Update)
+		require.Equal(r, []*api.CatalogRegistration{unchanged}, plan.Unchanged)
+	})
+	require.Zero(t, atomic.LoadInt32(&writes))
+}
+
+// Test that the Consul nodes registered for k8s nodes are deregistered once
+// no services are registered with them anymore.
+func TestConsulSyncer_reapNodes(t *testing.T) {
+	t.Parallel()
+
+	// We use a test http server here so we can serve the nodes and record
+	// the deregistrations.
+	var lock sync.Mutex
+	var deregistered []string
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/catalog/nodes":
+			// Only the nodes of this sync node must be queried.
+			if r.URL.Query().Get("node-meta") != ConsulK8SSyncNode+":"+ConsulSyncNodeName {
+				w.WriteHeader(400)
+				return
+			}
+			fmt.Fprintf(w, `[{"Node": %q}, {"Node": %q}]`, ConsulSyncNodeName+"-node1", ConsulSyncNodeName+"-stale")
+		case "/v1/catalog/deregister":
+			var dereg api.CatalogDeregistration
+			if err := json.NewDecoder(r.Body).Decode(&dereg); err != nil || dereg.ServiceID != "" {
+				w.WriteHeader(400)
+				return
+			}
+			lock.Lock()
+			deregistered = append(deregistered, dereg.Node)
+			lock.Unlock()
+			fmt.Fprint(w, "true")
+		case "/v1/catalog/register":
+			fmt.Fprint(w, "true")
+		default:
+			fmt.Fprint(w, "null")
+		}
+	}))
+	defer consulServer.Close()
+
+	client, err := api.NewClient(&api.Config{
+		Address: consulServer.URL,
+	})
+	require.NoError(t, err)
+	s, closer := testConsulSyncer(client)
+	defer closer()
+
+	// Sync a service on the node of a k8s node
+	s.Sync([]*api.CatalogRegistration{
+		testRegistration(ConsulSyncNodeName+"-node1", "foo", "default"),
+	})
+
+	retry.Run(t, func(r *retry.R) {
+		lock.Lock()
+		defer lock.Unlock()
+		require.Contains(r, deregistered, ConsulSyncNodeName+"-stale")
+		require.NotContains(r, deregistered, ConsulSyncNodeName+"-node1")
+	})
+}
+
 func testRegistration(node, service, k8sSrcNamespace string) *api.CatalogRegistration {
 	return &api.CatalogRegistration{
 		Node:           node,
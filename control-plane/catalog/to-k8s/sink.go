@@ -2,15 +2,22 @@ package catalog
 
 import (
 	"context"
+	"net"
+	"reflect"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/coalesce"
 	"github.com/hashicorp/go-hclog"
+	"github.com/prometheus/client_golang/prometheus"
 	apiv1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
@@ -23,6 +30,41 @@ const (
 	// K8SMaxPeriod is the maximum time to wait before forcing a sync, even
 	// if there are active changes going on.
 	K8SMaxPeriod = 5 * time.Second
+
+	// endpointsPortName is the name of the single port on services that
+	// are backed by Endpoints. Endpoint ports must have the same name as
+	// the service port for kube-proxy to route to them.
+	endpointsPortName = "default"
+
+	// ownerLabel is set on the Kubernetes services and Endpoints written by
+	// a sync process. Its value is the sink's Owner.
+	ownerLabel = "consul.hashicorp.com/sync-owner"
+)
+
+type K8SServiceType string
+
+const (
+	// Sync Consul services as ExternalName services pointing at their
+	// Consul DNS name. This requires Consul DNS to be resolvable from pods.
+	ExternalName K8SServiceType = "ExternalName"
+
+	// Sync Consul services as selectorless ClusterIP services whose
+	// Endpoints hold the addresses of the Consul service instances.
+	ClusterIP K8SServiceType = "ClusterIP"
+
+	// Sync Consul services as selectorless headless services whose
+	// Endpoints hold the addresses of the Consul service instances.
+	Headless K8SServiceType = "Headless"
+)
+
+type MissingNamespacePolicy string
+
+const (
+	// Create Kubernetes namespaces that don't exist yet when mirroring.
+	CreateNamespace MissingNamespacePolicy = "Create"
+
+	// Don't sync services into Kubernetes namespaces that don't exist.
+	SkipNamespace MissingNamespacePolicy = "Skip"
 )
 
 // Sink is the destination where services are registered.
@@ -32,8 +74,21 @@ const (
 type Sink interface {
 	// SetServices is called with the services that should be created.
 	// The key is the service name and the destination is the external DNS
-	// entry to point to.
+	// entry to point to. The key may also be in the form <namespace>/<name>
+	// to create the service in a specific Kubernetes namespace.
 	SetServices(map[string]string)
+
+	// SetEndpoints is called with all instances of a single service when
+	// the Source is syncing endpoints. The key is the service name as it
+	// was passed to SetServices.
+	SetEndpoints(string, []Endpoint)
+}
+
+// Endpoint is a single instance of a Consul service.
+type Endpoint struct {
+	Address string // Address is the instance's service address or node address
+	Port    int    // Port is the instance's service port
+	Healthy bool   // Healthy is true if all of the instance's checks are passing
 }
 
 // K8SSink is a Sink implementation that registers services with Kubernetes.
@@ -46,6 +101,29 @@ type K8SSink struct {
 	Namespace string               // Namespace is the namespace to sync to
 	Log       hclog.Logger         // Logger
 
+	// MirrorNamespaces set to true watches services in all namespaces and
+	// writes each service into the namespace given in its key, which must
+	// be in the form <namespace>/<name>. Otherwise, all services are
+	// written into Namespace.
+	MirrorNamespaces bool
+
+	// Owner identifies this sync process. Services it writes are labelled
+	// with it, and only services with the same owner are updated or deleted
+	// so that services labelled consul=true by other sync processes or by
+	// users, e.g. in other namespaces when mirroring, are left alone.
+	Owner string
+
+	// MissingNamespacePolicy decides whether namespaces that don't exist
+	// are created or skipped when MirrorNamespaces is true.
+	// Defaults to SkipNamespace.
+	MissingNamespacePolicy MissingNamespacePolicy
+
+	// ServiceType is the type of Kubernetes service to create for each
+	// Consul service. Defaults to ExternalName. For ClusterIP and Headless,
+	// the sink also manages an Endpoints object for each service which
+	// requires the Source to be syncing endpoints.
+	ServiceType K8SServiceType
+
 	// SyncPeriod is the duration to wait between registering or deregistering
 	// services in Kubernetes. This can be fairly short since no work will be
 	// done if there are no changes.
@@ -54,32 +132,64 @@ type K8SSink struct {
 	// Ctx is used to cancel the Sink.
 	Ctx context.Context
 
+	// Metrics records the metrics of the sync. If nil, metrics are
+	// recorded to a registry that isn't exposed.
+	Metrics *metrics.Metrics
+
+	// DryRun disables all writes to Kubernetes. The changes that would have
+	// been written are logged instead and available from Plan.
+	DryRun bool
+
+	// Elected, if set, holds off all writes to Kubernetes until it is closed.
+	// With leader election, standby replicas still watch services so that
+	// their state is current once they're elected.
+	Elected <-chan struct{}
+
 	// lock gates concurrent access to all the maps.
 	lock sync.Mutex
 
+	// All the maps below are keyed by Kube controller keys. Controller keys
+	// are in the form <kube namespace>/<kube svc name> e.g. default/foo,
+	// and are the keys Kube uses to inform that something changed.
+
 	// sourceServices holds Consul services that should be synced to Kube.
-	// It maps from Consul service names to Consul DNS entry, e.g.
-	// foo => foo.service.consul. It's populated from the Consul API.
+	// It maps from the key of the Kube service to the Consul DNS entry, e.g.
+	// default/foo => foo.service.consul. It's populated from the Consul API.
 	// We lowercase the Consul service names and DNS entries
 	// because Kube names must be lowercase.
 	sourceServices map[string]string
 
-	// keyToName maps from Kube controller keys to Kube service names.
-	// Controller keys are in the form <kube namespace>/<kube svc name>
-	// e.g. default/foo, and are the keys Kube uses to inform that something
-	// changed.
-	keyToName map[string]string
+	// sourceEndpoints maps from the keys of Consul services to all instances
+	// of that service. It's populated from the Consul API and is only used
+	// when ServiceType isn't ExternalName.
+	sourceEndpoints map[string][]Endpoint
 
-	// serviceMap holds all Kubernetes service names in the namespaces
-	// we're watching. The keys are Kubernetes service names and there are no
-	// values.
+	// serviceMap holds the keys of all Kubernetes services in the namespaces
+	// we're watching. There are no values.
 	serviceMap map[string]struct{}
 
 	// serviceMapConsul is a subset of serviceMap. It holds all Kube services
-	// that were created by this sync process. Keys are Kube service names.
-	// It's populated from Kubernetes data.
+	// that were created by this sync process. It's populated from Kubernetes
+	// data.
 	serviceMapConsul map[string]*apiv1.Service
-	triggerCh        chan struct{}
+
+	// endpointsMapConsul holds the Endpoints last written by this sync
+	// process. We use it to avoid writing Endpoints that haven't changed.
+	endpointsMapConsul map[string]*apiv1.Endpoints
+	triggerCh          chan struct{}
+
+	// plan is the result of the last sync in dry-run mode.
+	plan SinkPlan
+}
+
+// SinkPlan holds the writes a sync would have made to Kubernetes in
+// dry-run mode. Deletions are keys in the form <namespace>/<name>.
+type SinkPlan struct {
+	CreateServices  []*apiv1.Service   `json:"createServices"`
+	UpdateServices  []*apiv1.Service   `json:"updateServices"`
+	DeleteServices  []string           `json:"deleteServices"`
+	WriteEndpoints  []*apiv1.Endpoints `json:"writeEndpoints"`
+	DeleteEndpoints []string           `json:"deleteEndpoints"`
 }
 
 // SetServices implements Sink.
@@ -95,24 +205,50 @@ func (s *K8SSink) SetServices(svcs map[string]string) {
 	// but different cases, and so svcs will be unique even after lowercasing.
 	lowercasedSvcs := make(map[string]string)
 	for consulName, consulDNS := range svcs {
-		lowercasedSvcs[strings.ToLower(consulName)] = strings.ToLower(consulDNS)
+		lowercasedSvcs[s.key(consulName)] = strings.ToLower(consulDNS)
 	}
 
 	s.sourceServices = lowercasedSvcs
+
+	// Drop the instances of services that are no longer synced.
+	for key := range s.sourceEndpoints {
+		if _, ok := s.sourceServices[key]; !ok {
+			delete(s.sourceEndpoints, key)
+		}
+	}
+
 	s.trigger() // Any service change probably requires syncing
 }
 
+// SetEndpoints implements Sink.
+func (s *K8SSink) SetEndpoints(name string, endpoints []Endpoint) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.sourceEndpoints == nil {
+		s.sourceEndpoints = make(map[string][]Endpoint)
+	}
+
+	key := s.key(name)
+	if endpoints == nil {
+		delete(s.sourceEndpoints, key)
+	} else {
+		s.sourceEndpoints[key] = endpoints
+	}
+	s.trigger()
+}
+
 // Informer implements the controller.Resource interface.
 // It tells Kubernetes that we want to watch for changes to Services.
 func (s *K8SSink) Informer() cache.SharedIndexInformer {
 	return cache.NewSharedIndexInformer(
 		&cache.ListWatch{
 			ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
-				return s.Client.CoreV1().Services(s.namespace()).List(s.Ctx, options)
+				return s.Client.CoreV1().Services(s.watchNamespace()).List(s.Ctx, options)
 			},
 
 			WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
-				return s.Client.CoreV1().Services(s.namespace()).Watch(s.Ctx, options)
+				return s.Client.CoreV1().Services(s.watchNamespace()).Watch(s.Ctx, options)
 			},
 		},
 		&apiv1.Service{},
@@ -133,40 +269,51 @@ func (s *K8SSink) Upsert(key string, raw interface{}) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	// Store all the key to name mappings. We need this because the key
-	// is opaque but we want to do all the lookups by service name.
-	if s.keyToName == nil {
-		s.keyToName = make(map[string]string)
-	}
-	s.keyToName[key] = service.Name
-
 	if s.serviceMap == nil {
 		s.serviceMap = make(map[string]struct{})
 	}
-	s.serviceMap[service.Name] = struct{}{}
+	s.serviceMap[key] = struct{}{}
 
 	// If the service is a Consul-sourced service, then keep track of it
 	// separately for a quick lookup.
-	if service.Labels != nil && service.Labels["consul"] == "true" {
+	if s.owns(service) {
 		if s.serviceMapConsul == nil {
 			s.serviceMapConsul = make(map[string]*apiv1.Service)
 		}
 
-		s.serviceMapConsul[service.Name] = service
+		s.serviceMapConsul[key] = service
 		s.trigger() // Always trigger sync
+	} else if _, ok := s.serviceMapConsul[key]; ok {
+		// The service was relabelled so it's no longer ours.
+		delete(s.serviceMapConsul, key)
+		s.trigger()
 	}
 
 	s.Log.Info("upsert", "key", key)
 	return nil
 }
 
+// owns returns true if the service was written by this sync process.
+func (s *K8SSink) owns(service *apiv1.Service) bool {
+	if service.Labels["consul"] != "true" {
+		return false
+	}
+	if owner, ok := service.Labels[ownerLabel]; ok {
+		return owner == s.Owner
+	}
+
+	// Services written before the owner label was introduced are adopted
+	// unless we're mirroring, since then we watch all namespaces rather than
+	// just the one we write to. They're labelled on their next update.
+	return !s.MirrorNamespaces
+}
+
 // Delete implements the controller.Resource interface.
 func (s *K8SSink) Delete(key string, _ interface{}) error {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 
-	name, ok := s.keyToName[key]
-	if !ok {
+	if _, ok := s.serviceMap[key]; !ok {
 		// This is a weird scenario, but in unit tests we've seen this happen
 		// in cases where the delete happens very quickly after the create.
 		// Just to be sure, lets trigger a sync. This is cheap cause it'll
@@ -175,17 +322,20 @@ func (s *K8SSink) Delete(key string, _ interface{}) error {
 		return nil
 	}
 
-	delete(s.keyToName, key)
-	delete(s.serviceMap, name)
-	delete(s.serviceMapConsul, name)
+	delete(s.serviceMap, key)
+	delete(s.serviceMapConsul, key)
+
+	// Deleting a service also deletes its Endpoints so we need to
+	// write them again if the service is recreated.
+	delete(s.endpointsMapConsul, key)
 
 	// If the service that is deleted is part of Consul services, then
 	// we need to trigger a sync to recreate it.
-	if _, ok := s.sourceServices[name]; ok {
+	if _, ok := s.sourceServices[key]; ok {
 		s.trigger()
 	}
 
-	s.Log.Info("delete", "key", key, "name", name)
+	s.Log.Info("delete", "key", key)
 	return nil
 }
 
@@ -202,8 +352,20 @@ func (s *K8SSink) Run(ch <-chan struct{}) {
 		triggerCh <- struct{}{}
 		s.triggerCh = triggerCh
 	}
+	if s.Metrics == nil {
+		s.Metrics = metrics.New(prometheus.NewRegistry())
+	}
 	s.lock.Unlock()
 
+	if s.Elected != nil {
+		select {
+		case <-ch:
+			return
+		case <-s.Elected:
+			s.Log.Info("elected leader, starting writes")
+		}
+	}
+
 	for {
 		select {
 		case <-ch:
@@ -222,50 +384,313 @@ func (s *K8SSink) Run(ch <-chan struct{}) {
 
 		s.lock.Lock()
 		create, update, delete := s.crudList()
+		endpoints := s.endpointsList()
+		tracked := len(s.sourceServices)
 		s.lock.Unlock()
 		s.Log.Debug("sync triggered", "create", len(create), "update", len(update), "delete", len(delete))
+		s.Metrics.TrackedServices.WithLabelValues(metrics.DirectionToK8S).Set(float64(tracked))
+
+		// In dry-run mode the writes are only planned and logged.
+		if s.DryRun {
+			s.planDryRun(create, update, delete, endpoints)
+			continue
+		}
 
-		svcClient := s.Client.CoreV1().Services(s.namespace())
-		for _, name := range delete {
-			if err := svcClient.Delete(s.Ctx, name, metav1.DeleteOptions{}); err != nil {
-				s.Log.Warn("error deleting service", "name", name, "error", err)
+		// synced is set to false if any write fails so that the time of the
+		// last successful sync is only updated if everything was written.
+		synced := true
+
+		for _, key := range delete {
+			ns, name := splitKey(key)
+			start := time.Now()
+			err := s.Client.CoreV1().Services(ns).Delete(s.Ctx, name, metav1.DeleteOptions{})
+			s.Metrics.ObserveWrite(metrics.DirectionToK8S, "delete_service", start, err)
+			if err != nil {
+				s.Log.Warn("error deleting service", "namespace", ns, "name", name, "error", err)
+				synced = false
+				continue
 			}
+			s.Metrics.Deregistrations.WithLabelValues(metrics.DirectionToK8S).Inc()
 		}
 
 		for _, svc := range update {
-			_, err := svcClient.Update(s.Ctx, svc, metav1.UpdateOptions{})
+			start := time.Now()
+			_, err := s.Client.CoreV1().Services(svc.Namespace).Update(s.Ctx, svc, metav1.UpdateOptions{})
+			s.Metrics.ObserveWrite(metrics.DirectionToK8S, "update_service", start, err)
 			if err != nil {
-				s.Log.Warn("error updating service", "name", svc.Name, "error", err)
+				s.Log.Warn("error updating service", "namespace", svc.Namespace, "name", svc.Name, "error", err)
+				synced = false
+				continue
 			}
+			s.Metrics.Registrations.WithLabelValues(metrics.DirectionToK8S).Inc()
 		}
 
+		// Namespaces are only checked when mirroring since the write
+		// namespace is expected to exist otherwise.
+		namespaceExists := make(map[string]bool)
 		for _, svc := range create {
-			_, err := svcClient.Create(s.Ctx, svc, metav1.CreateOptions{})
+			if s.MirrorNamespaces {
+				exists, ok := namespaceExists[svc.Namespace]
+				if !ok {
+					exists = s.ensureNamespace(svc.Namespace)
+					namespaceExists[svc.Namespace] = exists
+				}
+				if !exists {
+					continue
+				}
+			}
+
+			start := time.Now()
+			_, err := s.Client.CoreV1().Services(svc.Namespace).Create(s.Ctx, svc, metav1.CreateOptions{})
+			s.Metrics.ObserveWrite(metrics.DirectionToK8S, "create_service", start, err)
 			if err != nil {
-				s.Log.Warn("error creating service", "name", svc.Name, "error", err)
+				s.Log.Warn("error creating service", "namespace", svc.Namespace, "name", svc.Name, "error", err)
+				synced = false
+				continue
 			}
+			s.Metrics.Registrations.WithLabelValues(metrics.DirectionToK8S).Inc()
+		}
+
+		if s.serviceType() != ExternalName && !s.syncEndpoints(endpoints) {
+			synced = false
+		}
+
+		if synced {
+			s.Metrics.LastSyncTimestamp.WithLabelValues(metrics.DirectionToK8S).SetToCurrentTime()
 		}
 	}
 }
 
+// syncEndpoints writes the given Endpoints to Kubernetes and deletes any
+// Endpoints previously written by this sync process that are not in the
+// list anymore. It returns false if any of the writes failed.
+func (s *K8SSink) syncEndpoints(endpoints []*apiv1.Endpoints) bool {
+	write, remove := s.endpointsChanges(endpoints)
+	s.Log.Debug("endpoints sync triggered", "write", len(write), "delete", len(remove))
+
+	synced := true
+	for _, key := range remove {
+		ns, name := splitKey(key)
+		start := time.Now()
+		err := s.Client.CoreV1().Endpoints(ns).Delete(s.Ctx, name, metav1.DeleteOptions{})
+		if k8serrors.IsNotFound(err) {
+			err = nil
+		}
+		s.Metrics.ObserveWrite(metrics.DirectionToK8S, "delete_endpoints", start, err)
+		if err != nil {
+			s.Log.Warn("error deleting endpoints", "namespace", ns, "name", name, "error", err)
+			synced = false
+			continue
+		}
+		s.lock.Lock()
+		delete(s.endpointsMapConsul, key)
+		s.lock.Unlock()
+	}
+
+	for _, ep := range write {
+		epClient := s.Client.CoreV1().Endpoints(ep.Namespace)
+		existing, err := epClient.Get(s.Ctx, ep.Name, metav1.GetOptions{})
+		switch {
+		case k8serrors.IsNotFound(err):
+			start := time.Now()
+			_, err = epClient.Create(s.Ctx, ep, metav1.CreateOptions{})
+			s.Metrics.ObserveWrite(metrics.DirectionToK8S, "create_endpoints", start, err)
+			if err != nil {
+				s.Log.Warn("error creating endpoints", "namespace", ep.Namespace, "name", ep.Name, "error", err)
+				synced = false
+				continue
+			}
+		case err != nil:
+			s.Metrics.ObserveError(metrics.DirectionToK8S, "get_endpoints")
+			s.Log.Warn("error getting endpoints", "namespace", ep.Namespace, "name", ep.Name, "error", err)
+			synced = false
+			continue
+		default:
+			existing.Labels = ep.Labels
+			existing.Subsets = ep.Subsets
+			start := time.Now()
+			_, err = epClient.Update(s.Ctx, existing, metav1.UpdateOptions{})
+			s.Metrics.ObserveWrite(metrics.DirectionToK8S, "update_endpoints", start, err)
+			if err != nil {
+				s.Log.Warn("error updating endpoints", "namespace", ep.Namespace, "name", ep.Name, "error", err)
+				synced = false
+				continue
+			}
+		}
+
+		s.lock.Lock()
+		s.endpointsMapConsul[ep.Namespace+"/"+ep.Name] = ep
+		s.lock.Unlock()
+	}
+	return synced
+}
+
+// Plan returns the writes the last sync would have made in dry-run mode.
+// It is empty if DryRun isn't set or no sync has run yet.
+func (s *K8SSink) Plan() SinkPlan {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.plan
+}
+
+// planDryRun logs the given changes instead of writing them and stores
+// them as the plan.
+func (s *K8SSink) planDryRun(create, update []*apiv1.Service, delete []string, endpoints []*apiv1.Endpoints) {
+	plan := SinkPlan{
+		CreateServices: create,
+		UpdateServices: update,
+		DeleteServices: delete,
+	}
+	if s.serviceType() != ExternalName {
+		plan.WriteEndpoints, plan.DeleteEndpoints = s.endpointsChanges(endpoints)
+	}
+
+	// Sort the plan so that it is stable between syncs.
+	sortServices := func(svcs []*apiv1.Service) {
+		sort.Slice(svcs, func(i, j int) bool {
+			return svcs[i].Namespace+"/"+svcs[i].Name < svcs[j].Namespace+"/"+svcs[j].Name
+		})
+	}
+	sortServices(plan.CreateServices)
+	sortServices(plan.UpdateServices)
+	sort.Strings(plan.DeleteServices)
+	sort.Slice(plan.WriteEndpoints, func(i, j int) bool {
+		return plan.WriteEndpoints[i].Namespace+"/"+plan.WriteEndpoints[i].Name <
+			plan.WriteEndpoints[j].Namespace+"/"+plan.WriteEndpoints[j].Name
+	})
+	sort.Strings(plan.DeleteEndpoints)
+
+	for _, svc := range plan.CreateServices {
+		s.Log.Info("[dry-run] would create service", "namespace", svc.Namespace, "name", svc.Name, "type", svc.Spec.Type)
+	}
+	for _, svc := range plan.UpdateServices {
+		s.Log.Info("[dry-run] would update service", "namespace", svc.Namespace, "name", svc.Name, "type", svc.Spec.Type)
+	}
+	for _, key := range plan.DeleteServices {
+		ns, name := splitKey(key)
+		s.Log.Info("[dry-run] would delete service", "namespace", ns, "name", name)
+	}
+	for _, ep := range plan.WriteEndpoints {
+		s.Log.Info("[dry-run] would write endpoints", "namespace", ep.Namespace, "name", ep.Name)
+	}
+	for _, key := range plan.DeleteEndpoints {
+		ns, name := splitKey(key)
+		s.Log.Info("[dry-run] would delete endpoints", "namespace", ns, "name", name)
+	}
+
+	s.lock.Lock()
+	s.plan = plan
+	s.lock.Unlock()
+}
+
+// endpointsChanges returns the Endpoints that have changed since they were
+// last written and the keys of the Endpoints that aren't desired anymore.
+func (s *K8SSink) endpointsChanges(endpoints []*apiv1.Endpoints) ([]*apiv1.Endpoints, []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.endpointsMapConsul == nil {
+		s.endpointsMapConsul = make(map[string]*apiv1.Endpoints)
+	}
+	var write []*apiv1.Endpoints
+	desired := make(map[string]struct{}, len(endpoints))
+	for _, ep := range endpoints {
+		key := ep.Namespace + "/" + ep.Name
+		desired[key] = struct{}{}
+		if existing, ok := s.endpointsMapConsul[key]; ok && reflect.DeepEqual(existing.Subsets, ep.Subsets) {
+			// Matching endpoints, no update required.
+			continue
+		}
+		write = append(write, ep)
+	}
+	var remove []string
+	for key := range s.endpointsMapConsul {
+		if _, ok := desired[key]; !ok {
+			remove = append(remove, key)
+		}
+	}
+	return write, remove
+}
+
+// ensureNamespace returns true if the given Kubernetes namespace exists.
+// If it doesn't exist, it is created if MissingNamespacePolicy is
+// CreateNamespace.
+func (s *K8SSink) ensureNamespace(name string) bool {
+	_, err := s.Client.CoreV1().Namespaces().Get(s.Ctx, name, metav1.GetOptions{})
+	if err == nil {
+		return true
+	}
+	if !k8serrors.IsNotFound(err) {
+		s.Metrics.ObserveError(metrics.DirectionToK8S, "get_namespace")
+		s.Log.Warn("error getting namespace", "namespace", name, "error", err)
+		return false
+	}
+
+	if s.MissingNamespacePolicy != CreateNamespace {
+		s.Log.Debug("namespace does not exist, not registering services", "namespace", name)
+		return false
+	}
+
+	_, err = s.Client.CoreV1().Namespaces().Create(s.Ctx, &apiv1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{"consul": "true"},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		s.Metrics.ObserveError(metrics.DirectionToK8S, "create_namespace")
+		s.Log.Warn("error creating namespace", "namespace", name, "error", err)
+		return false
+	}
+	s.Log.Info("created namespace", "namespace", name)
+	return true
+}
+
 // crudList returns the services to create, update, and delete (respectively).
 func (s *K8SSink) crudList() ([]*apiv1.Service, []*apiv1.Service, []string) {
 	var create, update []*apiv1.Service
 	var delete []string
 
 	// Determine what needs to be created or updated
-	for consulName, consulDNS := range s.sourceServices {
+	for key, consulDNS := range s.sourceServices {
+		spec, ok := s.serviceSpec(key, consulDNS)
+		if !ok {
+			s.Log.Debug("no instances known for service yet, not registering", "key", key)
+			continue
+		}
+
 		// If this is an already registered service, then update it
 		if s.serviceMapConsul != nil {
-			if svc, ok := s.serviceMapConsul[consulName]; ok {
-				if svc.Spec.ExternalName == consulDNS {
+			if svc, ok := s.serviceMapConsul[key]; ok {
+				_, labelled := svc.Labels[ownerLabel]
+				if labelled && serviceSpecMatches(svc.Spec, spec) {
 					// Matching service, no update required.
 					continue
 				}
 
-				svc.Spec = apiv1.ServiceSpec{
-					Type:         apiv1.ServiceTypeExternalName,
-					ExternalName: consulDNS,
+				// The cluster IP of a service can't be changed so switching
+				// between ClusterIP and headless services requires the service
+				// to be recreated.
+				if svc.Spec.Type == apiv1.ServiceTypeClusterIP && spec.Type == apiv1.ServiceTypeClusterIP &&
+					isHeadless(svc.Spec) != isHeadless(spec) {
+					delete = append(delete, key)
+					create = append(create, s.newService(key, spec))
+					continue
+				}
+
+				// The service is from the informer cache so copy it before
+				// making changes.
+				svc = svc.DeepCopy()
+				if svc.Labels == nil {
+					svc.Labels = make(map[string]string)
+				}
+				svc.Labels[ownerLabel] = s.Owner
+
+				// Keep the fields allocated by Kubernetes when updating the
+				// ports of an existing ClusterIP service.
+				if svc.Spec.Type == apiv1.ServiceTypeClusterIP && spec.Type == apiv1.ServiceTypeClusterIP {
+					svc.Spec.Ports = spec.Ports
+				} else {
+					svc.Spec = spec
 				}
 
 				update = append(update, svc)
@@ -274,27 +699,13 @@ func (s *K8SSink) crudList() ([]*apiv1.Service, []*apiv1.Service, []string) {
 		}
 
 		// If this is a registered K8S service, ignore.
-		if _, ok := s.serviceMap[consulName]; ok {
-			s.Log.Warn("service already registered in K8S, not registering", "name", consulName)
+		if _, ok := s.serviceMap[key]; ok {
+			s.Log.Warn("service already registered in K8S, not registering", "key", key)
 			continue
 		}
 
 		// Register!
-		create = append(create, &apiv1.Service{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:   consulName,
-				Labels: map[string]string{"consul": "true"},
-				Annotations: map[string]string{
-					// Ensure we don't sync the service back to Consul
-					"consul.hashicorp.com/service-sync": "false",
-				},
-			},
-
-			Spec: apiv1.ServiceSpec{
-				Type:         apiv1.ServiceTypeExternalName,
-				ExternalName: consulDNS,
-			},
-		})
+		create = append(create, s.newService(key, spec))
 	}
 
 	// Determine what needs to be deleted
@@ -307,6 +718,219 @@ func (s *K8SSink) crudList() ([]*apiv1.Service, []*apiv1.Service, []string) {
 	return create, update, delete
 }
 
+// endpointsList returns the Endpoints that should exist for the services
+// we're syncing. It returns nil if the sink is syncing ExternalName services.
+func (s *K8SSink) endpointsList() []*apiv1.Endpoints {
+	if s.serviceType() == ExternalName {
+		return nil
+	}
+
+	var endpoints []*apiv1.Endpoints
+	for key := range s.sourceServices {
+		// Only write endpoints for services we own. This also skips
+		// services that exist in K8S but weren't created by us.
+		if _, ok := s.serviceMapConsul[key]; !ok {
+			continue
+		}
+
+		// Group the addresses by port since an Endpoints subset
+		// shares its ports between all of its addresses.
+		subsets := make(map[int]*apiv1.EndpointSubset)
+		for _, ep := range s.sourceEndpoints[key] {
+			// Endpoints only support IP addresses.
+			if net.ParseIP(ep.Address) == nil {
+				s.Log.Debug("ignoring service instance without an IP address",
+					"key", key, "address", ep.Address)
+				continue
+			}
+
+			// The API server rejects Endpoints with a zero port. Instances
+			// without a port can only be reached by address, so they're only
+			// written for headless services as a subset without ports.
+			port := ep.Port
+			if port <= 0 {
+				if s.serviceType() != Headless {
+					s.Log.Debug("ignoring service instance without a port",
+						"key", key, "address", ep.Address)
+					continue
+				}
+				port = 0
+			}
+
+			subset, ok := subsets[port]
+			if !ok {
+				subset = &apiv1.EndpointSubset{}
+				if port > 0 {
+					subset.Ports = []apiv1.EndpointPort{{
+						Name:     endpointsPortName,
+						Port:     int32(port),
+						Protocol: apiv1.ProtocolTCP,
+					}}
+				}
+				subsets[port] = subset
+			}
+
+			addr := apiv1.EndpointAddress{IP: ep.Address}
+			if ep.Healthy {
+				subset.Addresses = append(subset.Addresses, addr)
+			} else {
+				subset.NotReadyAddresses = append(subset.NotReadyAddresses, addr)
+			}
+		}
+
+		ports := make([]int, 0, len(subsets))
+		for port := range subsets {
+			ports = append(ports, port)
+		}
+		sort.Ints(ports)
+
+		// Sort everything so that we can compare against what we wrote
+		// previously to avoid writes if nothing has changed.
+		ns, name := splitKey(key)
+		ep := &apiv1.Endpoints{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: ns,
+				Labels:    s.labels(),
+			},
+		}
+		for _, port := range ports {
+			subset := subsets[port]
+			sortAddresses(subset.Addresses)
+			sortAddresses(subset.NotReadyAddresses)
+			ep.Subsets = append(ep.Subsets, *subset)
+		}
+		endpoints = append(endpoints, ep)
+	}
+
+	return endpoints
+}
+
+// serviceSpec returns the spec of the Kubernetes service for the given
+// Consul service. It returns false if there isn't enough information yet
+// to create the service.
+func (s *K8SSink) serviceSpec(key, consulDNS string) (apiv1.ServiceSpec, bool) {
+	serviceType := s.serviceType()
+	if serviceType == ExternalName {
+		return apiv1.ServiceSpec{
+			Type:         apiv1.ServiceTypeExternalName,
+			ExternalName: consulDNS,
+		}, true
+	}
+
+	spec := apiv1.ServiceSpec{
+		Type: apiv1.ServiceTypeClusterIP,
+	}
+	if serviceType == Headless {
+		spec.ClusterIP = apiv1.ClusterIPNone
+	}
+
+	// Services need a port unless they're headless. We use the lowest
+	// instance port since all instance ports are routed via the named
+	// port regardless of their number.
+	port := 0
+	for _, ep := range s.sourceEndpoints[key] {
+		if ep.Port > 0 && (port == 0 || ep.Port < port) {
+			port = ep.Port
+		}
+	}
+	if port == 0 {
+		return spec, serviceType == Headless
+	}
+
+	spec.Ports = []apiv1.ServicePort{{
+		Name:       endpointsPortName,
+		Port:       int32(port),
+		TargetPort: intstr.FromInt(port),
+		Protocol:   apiv1.ProtocolTCP,
+	}}
+	return spec, true
+}
+
+// newService returns a new Kubernetes service owned by this sync process.
+func (s *K8SSink) newService(key string, spec apiv1.ServiceSpec) *apiv1.Service {
+	ns, name := splitKey(key)
+	return &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+			Labels:    s.labels(),
+			Annotations: map[string]string{
+				// Ensure we don't sync the service back to Consul
+				"consul.hashicorp.com/service-sync": "false",
+			},
+		},
+
+		Spec: spec,
+	}
+}
+
+// labels returns the labels of the services and Endpoints written by this
+// sync process.
+func (s *K8SSink) labels() map[string]string {
+	return map[string]string{"consul": "true", ownerLabel: s.Owner}
+}
+
+// key returns the lowercased controller key for a service passed to
+// SetServices or SetEndpoints. Services without a namespace are written
+// into Namespace.
+func (s *K8SSink) key(name string) string {
+	name = strings.ToLower(name)
+	if strings.Contains(name, "/") {
+		return name
+	}
+	return s.namespace() + "/" + name
+}
+
+// watchNamespace returns the K8S namespace to watch services in.
+func (s *K8SSink) watchNamespace() string {
+	if s.MirrorNamespaces {
+		return metav1.NamespaceAll
+	}
+	return s.namespace()
+}
+
+// serviceType returns the type of Kubernetes services we're syncing.
+func (s *K8SSink) serviceType() K8SServiceType {
+	if s.ServiceType != "" {
+		return s.ServiceType
+	}
+	return ExternalName
+}
+
+// serviceSpecMatches returns true if the existing service spec doesn't
+// need to be updated to match the desired spec. Only the fields that
+// the sink sets are compared since Kubernetes defaults the rest.
+func serviceSpecMatches(existing, desired apiv1.ServiceSpec) bool {
+	if existing.Type != desired.Type {
+		return false
+	}
+	if desired.Type == apiv1.ServiceTypeExternalName {
+		return existing.ExternalName == desired.ExternalName
+	}
+	if isHeadless(existing) != isHeadless(desired) || len(existing.Ports) != len(desired.Ports) {
+		return false
+	}
+	for i, p := range desired.Ports {
+		if existing.Ports[i].Name != p.Name || existing.Ports[i].Port != p.Port {
+			return false
+		}
+	}
+	return true
+}
+
+// isHeadless returns true if the spec is for a headless service.
+func isHeadless(spec apiv1.ServiceSpec) bool {
+	return spec.ClusterIP == apiv1.ClusterIPNone
+}
+
+// sortAddresses sorts endpoint addresses by IP.
+func sortAddresses(addrs []apiv1.EndpointAddress) {
+	sort.Slice(addrs, func(i, j int) bool {
+		return addrs[i].IP < addrs[j].IP
+	})
+}
+
 // namespace returns the K8S namespace to setup the resource watchers in.
 func (s *K8SSink) namespace() string {
 	if s.Namespace != "" {
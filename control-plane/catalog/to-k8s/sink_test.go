@@ -3,10 +3,14 @@ package catalog
 import (
 	"context"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
 	"github.com/hashicorp/go-hclog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 	apiv1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -393,12 +397,467 @@ func TestK8SSink_deleteReconcileLocal(t *testing.T) {
 	})
 }
 
+// Test that the writes of the sync are recorded in the metrics.
+func TestK8SSink_metrics(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	m := metrics.New(prometheus.NewRegistry())
+
+	// Start the controller
+	sink, closer := testSinkWithConfig(t, client, func(sink *K8SSink) {
+		sink.Metrics = m
+	})
+	defer closer()
+
+	// Set services and verify they're counted as registrations
+	sink.SetServices(map[string]string{"web": "web.service.local.", "db": "db.service.local."})
+	retry.Run(t, func(r *retry.R) {
+		require.Equal(r, float64(2), testutil.ToFloat64(m.Registrations.WithLabelValues(metrics.DirectionToK8S)))
+		require.Equal(r, float64(2), testutil.ToFloat64(m.TrackedServices.WithLabelValues(metrics.DirectionToK8S)))
+		require.NotZero(r, testutil.ToFloat64(m.LastSyncTimestamp.WithLabelValues(metrics.DirectionToK8S)))
+	})
+
+	// Remove a service and verify it's counted as a deregistration
+	sink.SetServices(map[string]string{"web": "web.service.local."})
+	retry.Run(t, func(r *retry.R) {
+		require.Equal(r, float64(1), testutil.ToFloat64(m.Deregistrations.WithLabelValues(metrics.DirectionToK8S)))
+		require.Equal(r, float64(1), testutil.ToFloat64(m.TrackedServices.WithLabelValues(metrics.DirectionToK8S)))
+	})
+	require.Equal(t, 0, testutil.CollectAndCount(m.Errors))
+}
+
+// Test that in dry-run mode the changes are planned but not written.
+func TestK8SSink_dryRun(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+
+	// An existing service created by the sync that is no longer in Consul
+	_, err := client.CoreV1().Services(metav1.NamespaceDefault).Create(context.Background(), &apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "stale",
+			Labels: map[string]string{"consul": "true"},
+		},
+	}, metav1.CreateOptions{})
+	require.NoError(t, err)
+
+	// Start the controller
+	sink, closer := testSinkWithConfig(t, client, func(sink *K8SSink) {
+		sink.DryRun = true
+		sink.Namespace = metav1.NamespaceDefault
+	})
+	defer closer()
+
+	sink.SetServices(map[string]string{"web": "web.service.local."})
+
+	retry.Run(t, func(r *retry.R) {
+		plan := sink.Plan()
+		require.Len(r, plan.CreateServices, 1)
+		require.Equal(r, "web", plan.CreateServices[0].Name)
+		require.Equal(r, []string{"default/stale"}, plan.DeleteServices)
+	})
+
+	// Nothing was written
+	list, err := client.CoreV1().Services(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	require.Equal(t, "stale", list.Items[0].Name)
+}
+
+// Test that no services are written until the sink is elected leader.
+func TestK8SSink_elected(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	elected := make(chan struct{})
+	sink, closer := testSinkWithConfig(t, client, func(s *K8SSink) {
+		s.Elected = elected
+	})
+	defer closer()
+
+	sink.SetServices(map[string]string{"foo": "foo.service.consul"})
+
+	// Give the sink time to sync. The service should not be created.
+	time.Sleep(2 * K8SMaxPeriod)
+	list, err := client.CoreV1().Services(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+	require.NoError(t, err)
+	require.Empty(t, list.Items)
+
+	close(elected)
+	retry.Run(t, func(r *retry.R) {
+		_, err := client.CoreV1().Services(metav1.NamespaceDefault).Get(context.Background(), "foo", metav1.GetOptions{})
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+	})
+}
+
+// Test that ClusterIP services are created with Endpoints holding the
+// service instances.
+func TestK8SSink_createClusterIP(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	client := fake.NewSimpleClientset()
+
+	// Start the controller
+	sink, closer := testSinkWithConfig(t, client, func(s *K8SSink) {
+		s.ServiceType = ClusterIP
+	})
+	defer closer()
+
+	// Set a service and its instances
+	sink.SetServices(map[string]string{"web": "web.service.local."})
+	sink.SetEndpoints("web", []Endpoint{
+		{Address: "10.0.0.2", Port: 8080, Healthy: true},
+		{Address: "10.0.0.1", Port: 8080, Healthy: true},
+		{Address: "10.0.0.3", Port: 9090, Healthy: false},
+		{Address: "not-an-ip", Port: 8080, Healthy: true},
+	})
+
+	// Verify the service and endpoints get registered
+	var actual *apiv1.Endpoints
+	retry.Run(t, func(r *retry.R) {
+		svc, err := client.CoreV1().Services(metav1.NamespaceDefault).Get(context.Background(), "web", metav1.GetOptions{})
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+		if svc.Spec.Type != apiv1.ServiceTypeClusterIP {
+			r.Fatalf("unexpected service type %s", svc.Spec.Type)
+		}
+
+		actual, err = client.CoreV1().Endpoints(metav1.NamespaceDefault).Get(context.Background(), "web", metav1.GetOptions{})
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+	})
+
+	svc, err := client.CoreV1().Services(metav1.NamespaceDefault).Get(context.Background(), "web", metav1.GetOptions{})
+	require.NoError(err)
+	require.Len(svc.Spec.Ports, 1)
+	require.Equal(int32(8080), svc.Spec.Ports[0].Port)
+	require.Empty(svc.Spec.ExternalName)
+
+	require.Equal([]apiv1.EndpointSubset{
+		{
+			Addresses: []apiv1.EndpointAddress{{IP: "10.0.0.1"}, {IP: "10.0.0.2"}},
+			Ports:     []apiv1.EndpointPort{{Name: endpointsPortName, Port: 8080, Protocol: apiv1.ProtocolTCP}},
+		},
+		{
+			NotReadyAddresses: []apiv1.EndpointAddress{{IP: "10.0.0.3"}},
+			Ports:             []apiv1.EndpointPort{{Name: endpointsPortName, Port: 9090, Protocol: apiv1.ProtocolTCP}},
+		},
+	}, actual.Subsets)
+
+	// Mark an instance as unhealthy and verify the endpoints are updated
+	sink.SetEndpoints("web", []Endpoint{
+		{Address: "10.0.0.1", Port: 8080, Healthy: true},
+		{Address: "10.0.0.2", Port: 8080, Healthy: false},
+	})
+	retry.Run(t, func(r *retry.R) {
+		ep, err := client.CoreV1().Endpoints(metav1.NamespaceDefault).Get(context.Background(), "web", metav1.GetOptions{})
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+		if len(ep.Subsets) != 1 || len(ep.Subsets[0].NotReadyAddresses) != 1 {
+			r.Fatalf("endpoints not updated: %v", ep.Subsets)
+		}
+	})
+
+	// Clear and verify the endpoints are deleted
+	sink.SetServices(map[string]string{})
+	retry.Run(t, func(r *retry.R) {
+		list, err := client.CoreV1().Endpoints(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+		if len(list.Items) > 0 {
+			r.Fatal("endpoints")
+		}
+	})
+}
+
+// Test that ClusterIP services aren't created until an instance port is known
+// but headless services are.
+func TestK8SSink_createWithoutEndpoints(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		serviceType K8SServiceType
+		expCreated  bool
+	}{
+		"clusterip": {ClusterIP, false},
+		"headless":  {Headless, true},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			sink, closer := testSinkWithConfig(t, client, func(s *K8SSink) {
+				s.ServiceType = c.serviceType
+			})
+			defer closer()
+
+			sink.SetServices(map[string]string{"web": "web.service.local."})
+			if c.expCreated {
+				retry.Run(t, func(r *retry.R) {
+					svc, err := client.CoreV1().Services(metav1.NamespaceDefault).Get(context.Background(), "web", metav1.GetOptions{})
+					if err != nil {
+						r.Fatalf("err: %s", err)
+					}
+					if svc.Spec.ClusterIP != apiv1.ClusterIPNone {
+						r.Fatal("service is not headless")
+					}
+				})
+				return
+			}
+
+			// Give the sink time to sync. The service should not be created.
+			time.Sleep(2 * K8SMaxPeriod)
+			list, err := client.CoreV1().Services(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+			require.NoError(t, err)
+			require.Empty(t, list.Items)
+		})
+	}
+}
+
+// Test that instances without a port are left out of the Endpoints of
+// ClusterIP services and written without ports for headless services since
+// the API server rejects Endpoints with a zero port.
+func TestK8SSink_endpointsWithoutPort(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		serviceType K8SServiceType
+		expSubsets  []apiv1.EndpointSubset
+	}{
+		"clusterip": {
+			serviceType: ClusterIP,
+			expSubsets: []apiv1.EndpointSubset{
+				{
+					Addresses: []apiv1.EndpointAddress{{IP: "10.0.0.1"}},
+					Ports:     []apiv1.EndpointPort{{Name: endpointsPortName, Port: 8080, Protocol: apiv1.ProtocolTCP}},
+				},
+			},
+		},
+		"headless": {
+			serviceType: Headless,
+			expSubsets: []apiv1.EndpointSubset{
+				{
+					Addresses: []apiv1.EndpointAddress{{IP: "10.0.0.2"}},
+				},
+				{
+					Addresses: []apiv1.EndpointAddress{{IP: "10.0.0.1"}},
+					Ports:     []apiv1.EndpointPort{{Name: endpointsPortName, Port: 8080, Protocol: apiv1.ProtocolTCP}},
+				},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset()
+			sink, closer := testSinkWithConfig(t, client, func(s *K8SSink) {
+				s.ServiceType = c.serviceType
+			})
+			defer closer()
+
+			sink.SetServices(map[string]string{"web": "web.service.local."})
+			sink.SetEndpoints("web", []Endpoint{
+				{Address: "10.0.0.1", Port: 8080, Healthy: true},
+				{Address: "10.0.0.2", Port: 0, Healthy: true},
+			})
+
+			var actual *apiv1.Endpoints
+			retry.Run(t, func(r *retry.R) {
+				var err error
+				actual, err = client.CoreV1().Endpoints(metav1.NamespaceDefault).Get(context.Background(), "web", metav1.GetOptions{})
+				if err != nil {
+					r.Fatalf("err: %s", err)
+				}
+			})
+			require.Equal(t, c.expSubsets, actual.Subsets)
+		})
+	}
+}
+
+// Test that services are written into the namespace in their key when
+// mirroring namespaces, and that missing namespaces are handled according
+// to the policy.
+func TestK8SSink_mirrorNamespaces(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		policy       MissingNamespacePolicy
+		expNamespace []string
+	}{
+		"skip missing namespaces": {
+			policy:       SkipNamespace,
+			expNamespace: []string{"team-a"},
+		},
+		"create missing namespaces": {
+			policy:       CreateNamespace,
+			expNamespace: []string{"team-a", "team-b"},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			client := fake.NewSimpleClientset(&apiv1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "team-a"},
+			})
+			sink, closer := testSinkWithConfig(t, client, func(s *K8SSink) {
+				s.MirrorNamespaces = true
+				s.MissingNamespacePolicy = c.policy
+			})
+			defer closer()
+
+			sink.SetServices(map[string]string{
+				"team-a/web": "web.service.team-a.ns.local.",
+				"team-b/web": "web.service.team-b.ns.local.",
+			})
+
+			var actual []string
+			retry.Run(t, func(r *retry.R) {
+				list, err := client.CoreV1().Services(metav1.NamespaceAll).List(context.Background(), metav1.ListOptions{})
+				if err != nil {
+					r.Fatalf("err: %s", err)
+				}
+				if len(list.Items) != len(c.expNamespace) {
+					r.Fatalf("expected %d services, got %d", len(c.expNamespace), len(list.Items))
+				}
+
+				actual = nil
+				for _, svc := range list.Items {
+					actual = append(actual, svc.Namespace)
+					if svc.Spec.ExternalName != "web.service."+svc.Namespace+".ns.local." {
+						r.Fatalf("unexpected external name %s", svc.Spec.ExternalName)
+					}
+				}
+			})
+			require.ElementsMatch(t, c.expNamespace, actual)
+
+			// Remove the service from one namespace and verify only it is deleted.
+			sink.SetServices(map[string]string{
+				"team-b/web": "web.service.team-b.ns.local.",
+			})
+			retry.Run(t, func(r *retry.R) {
+				_, err := client.CoreV1().Services("team-a").Get(context.Background(), "web", metav1.GetOptions{})
+				if err == nil {
+					r.Fatal("service not deleted")
+				}
+			})
+			if c.policy == CreateNamespace {
+				_, err := client.CoreV1().Services("team-b").Get(context.Background(), "web", metav1.GetOptions{})
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+// Test that when mirroring namespaces, services labelled consul=true that
+// weren't written by this sync process are neither updated nor deleted.
+func TestK8SSink_mirrorNamespacesIgnoresForeignServices(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset(
+		&apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&apiv1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "unlabelled",
+				Namespace: "team-a",
+				Labels:    map[string]string{"consul": "true"},
+			},
+			Spec: apiv1.ServiceSpec{Type: apiv1.ServiceTypeExternalName, ExternalName: "example.com."},
+		},
+		&apiv1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "other-owner",
+				Namespace: "team-a",
+				Labels:    map[string]string{"consul": "true", ownerLabel: "other"},
+			},
+			Spec: apiv1.ServiceSpec{Type: apiv1.ServiceTypeExternalName, ExternalName: "example.com."},
+		},
+	)
+	sink, closer := testSinkWithConfig(t, client, func(s *K8SSink) {
+		s.Owner = "sync"
+		s.MirrorNamespaces = true
+	})
+	defer closer()
+
+	sink.SetServices(map[string]string{
+		"team-a/web":         "web.service.team-a.ns.local.",
+		"team-a/other-owner": "other-owner.service.team-a.ns.local.",
+	})
+
+	retry.Run(t, func(r *retry.R) {
+		svc, err := client.CoreV1().Services("team-a").Get(context.Background(), "web", metav1.GetOptions{})
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+		if svc.Labels[ownerLabel] != "sync" {
+			r.Fatalf("unexpected labels %v", svc.Labels)
+		}
+	})
+
+	// Once our own service is deleted the sink has had the chance to delete
+	// the foreign ones too.
+	sink.SetServices(map[string]string{})
+	retry.Run(t, func(r *retry.R) {
+		_, err := client.CoreV1().Services("team-a").Get(context.Background(), "web", metav1.GetOptions{})
+		if err == nil {
+			r.Fatal("service not deleted")
+		}
+	})
+
+	unlabelled, err := client.CoreV1().Services("team-a").Get(context.Background(), "unlabelled", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "example.com.", unlabelled.Spec.ExternalName)
+	otherOwner, err := client.CoreV1().Services("team-a").Get(context.Background(), "other-owner", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, "example.com.", otherOwner.Spec.ExternalName)
+}
+
+// Test that services written before the owner label was introduced are
+// adopted and labelled when not mirroring namespaces.
+func TestK8SSink_adoptsUnlabelledServices(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset(&apiv1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web",
+			Namespace: metav1.NamespaceDefault,
+			Labels:    map[string]string{"consul": "true"},
+		},
+		Spec: apiv1.ServiceSpec{Type: apiv1.ServiceTypeExternalName, ExternalName: "web.service.local."},
+	})
+	sink, closer := testSinkWithConfig(t, client, func(s *K8SSink) {
+		s.Owner = "sync"
+	})
+	defer closer()
+
+	sink.SetServices(map[string]string{"web": "web.service.local."})
+
+	retry.Run(t, func(r *retry.R) {
+		svc, err := client.CoreV1().Services(metav1.NamespaceDefault).Get(context.Background(), "web", metav1.GetOptions{})
+		if err != nil {
+			r.Fatalf("err: %s", err)
+		}
+		if svc.Labels[ownerLabel] != "sync" {
+			r.Fatalf("unexpected labels %v", svc.Labels)
+		}
+	})
+}
+
 func testSink(t *testing.T, client kubernetes.Interface) (*K8SSink, func()) {
+	return testSinkWithConfig(t, client, func(*K8SSink) {})
+}
+
+func testSinkWithConfig(t *testing.T, client kubernetes.Interface, configurator func(*K8SSink)) (*K8SSink, func()) {
 	sink := &K8SSink{
 		Client: client,
 		Log:    hclog.Default(),
 		Ctx:    context.Background(),
 	}
+	configurator(sink)
 
 	closer := controller.TestControllerRun(sink)
 	return sink, closer
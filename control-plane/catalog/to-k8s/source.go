@@ -3,9 +3,13 @@ package catalog
 import (
 	"context"
 	"fmt"
+	"path"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/cenkalti/backoff"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-hclog"
 )
@@ -19,16 +23,168 @@ type Source struct {
 	Prefix       string       // Prefix is a prefix to prepend to services
 	Log          hclog.Logger // Logger
 	ConsulK8STag string       // The tag value for services registered
+
+	// SyncEndpoints set to true starts a health watcher for every synced
+	// service and passes its instances to the Sink via SetEndpoints.
+	// This is only needed when the Sink writes services that are backed
+	// by Endpoints rather than ExternalName services.
+	SyncEndpoints bool
+
+	// AllowServices is a list of glob patterns of Consul service names to
+	// sync. If it is empty, all services are eligible unless explicitly
+	// denied.
+	AllowServices []string
+
+	// DenyServices is a list of glob patterns of Consul service names to
+	// never sync. This filter takes precedence over AllowServices.
+	DenyServices []string
+
+	// RequiredTags are the tags a Consul service must have to be synced.
+	RequiredTags []string
+
+	// ServiceMeta holds meta key/value pairs that at least one instance
+	// of a Consul service must have for the service to be synced.
+	ServiceMeta map[string]string
+
+	// ConsulNamespace is the Consul namespace to sync services from. If it
+	// is empty, the namespace of the client's token is used. This is not
+	// used if EnableConsulNSMirroring is true.
+	// Only supported in Consul Enterprise.
+	ConsulNamespace string
+
+	// EnableConsulNSMirroring syncs the services of all Consul namespaces
+	// into the Kubernetes namespace that mirrors their Consul namespace.
+	// The keys passed to the Sink are then in the form <k8s namespace>/<name>.
+	// Only supported in Consul Enterprise.
+	EnableConsulNSMirroring bool
+
+	// ConsulNSMirroringPrefix is an optional prefix that is removed from
+	// Consul namespaces when mirroring. For example, if it is set to
+	// "k8s-", then services in the Consul `k8s-team-a` namespace are synced
+	// into the Kubernetes `team-a` namespace.
+	ConsulNSMirroringPrefix string
+
+	// lock gates concurrent access to the maps below since services of
+	// each Consul namespace are watched in their own routine.
+	lock sync.Mutex
+
+	// services maps from Consul namespaces to the set of Consul service
+	// names in that namespace that should be synced.
+	services map[string]map[string]struct{}
+
+	// pendingNamespaces is the set of Consul namespaces whose services
+	// watcher hasn't received its first response yet. The Sink isn't updated
+	// while any are pending since it would delete the services of those
+	// namespaces and recreate them once their watcher responds.
+	pendingNamespaces map[string]struct{}
+
+	// endpointWatchers maps from Consul service keys in the form
+	// <consul namespace>/<name> to the cancel function of the routine
+	// watching that service's health.
+	endpointWatchers map[string]context.CancelFunc
+
+	// metaWatchers maps from Consul service keys in the form
+	// <consul namespace>/<name> to the cancel function of the routine
+	// watching whether that service's instances match ServiceMeta.
+	metaWatchers map[string]context.CancelFunc
+
+	// metaMatches is the set of Consul service keys whose instances match
+	// ServiceMeta.
+	metaMatches map[string]struct{}
+
+	// pendingMeta is the set of Consul service keys whose meta watcher
+	// hasn't received its first response yet. Like pendingNamespaces, the
+	// Sink isn't updated while any are pending.
+	pendingMeta map[string]struct{}
 }
 
 // Run is the long-running runloop for watching Consul services and
 // updating the Sink.
 func (s *Source) Run(ctx context.Context) {
+	if !s.EnableConsulNSMirroring {
+		s.watchServices(ctx, s.ConsulNamespace)
+		return
+	}
+
+	// When mirroring we watch the Consul namespaces and run a services
+	// watcher for each of them.
 	opts := (&api.QueryOptions{
 		AllowStale: true,
 		WaitIndex:  1,
 		WaitTime:   1 * time.Minute,
 	}).WithContext(ctx)
+	watchers := make(map[string]context.CancelFunc)
+	for {
+		var namespaces []*api.Namespace
+		var meta *api.QueryMeta
+		err := backoff.Retry(func() error {
+			var err error
+			namespaces, meta, err = s.Client.Namespaces().List(opts)
+			return err
+		}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+
+		// If the context is ended, then we end
+		if ctx.Err() != nil {
+			return
+		}
+
+		// If there was an error, handle that
+		if err != nil {
+			s.Log.Warn("error querying namespaces, will retry", "err", err)
+			continue
+		}
+
+		// Update our blocking index
+		opts.WaitIndex = meta.LastIndex
+
+		current := make(map[string]struct{}, len(namespaces))
+		var added []string
+		for _, ns := range namespaces {
+			current[ns.Name] = struct{}{}
+			if _, ok := watchers[ns.Name]; !ok {
+				added = append(added, ns.Name)
+			}
+		}
+
+		// Mark all new namespaces as pending before starting any watcher
+		// so that the first watcher to respond doesn't update the Sink
+		// without the services of the others.
+		s.setPendingNamespaces(added)
+		for _, ns := range namespaces {
+			if _, ok := watchers[ns.Name]; ok {
+				continue
+			}
+
+			nsCtx, cancelF := context.WithCancel(ctx)
+			go s.watchServices(nsCtx, ns.Name)
+			watchers[ns.Name] = cancelF
+			s.Log.Debug("[Run] started services watcher", "consul-namespace", ns.Name)
+		}
+
+		// Stop watching deleted namespaces and remove their services.
+		for ns, cancelF := range watchers {
+			if _, ok := current[ns]; ok {
+				continue
+			}
+
+			cancelF()
+			delete(watchers, ns)
+			s.setServices(ctx, ns, nil)
+			s.Log.Debug("[Run] stopped services watcher", "consul-namespace", ns)
+		}
+	}
+}
+
+// watchServices is a long-running routine that holds a blocking query on
+// the services in the given Consul namespace and updates the Sink whenever
+// the services to sync change.
+func (s *Source) watchServices(ctx context.Context, namespace string) {
+	opts := (&api.QueryOptions{
+		AllowStale: true,
+		WaitIndex:  1,
+		WaitTime:   1 * time.Minute,
+		Namespace:  namespace,
+	}).WithContext(ctx)
 	for {
 		// Get all services with tags.
 		var serviceMap map[string][]string
@@ -46,7 +202,7 @@ func (s *Source) Run(ctx context.Context) {
 
 		// If there was an error, handle that
 		if err != nil {
-			s.Log.Warn("error querying services, will retry", "err", err)
+			s.Log.Warn("error querying services, will retry", "consul-namespace", namespace, "err", err)
 			continue
 		}
 
@@ -54,7 +210,7 @@ func (s *Source) Run(ctx context.Context) {
 		opts.WaitIndex = meta.LastIndex
 
 		// Setup the services
-		services := make(map[string]string, len(serviceMap))
+		names := make(map[string]struct{}, len(serviceMap))
 		for name, tags := range serviceMap {
 			// We ignore services that are synced from k8s so we can avoid
 			// circular syncing. Realistically this shouldn't happen since
@@ -68,12 +224,388 @@ func (s *Source) Run(ctx context.Context) {
 				}
 			}
 
-			if !k8s {
-				services[s.Prefix+name] = fmt.Sprintf("%s.service.%s", name, s.Domain)
+			if k8s || !s.shouldSync(name, tags) {
+				continue
+			}
+
+			names[name] = struct{}{}
+		}
+		s.Log.Info("received services from Consul", "consul-namespace", namespace, "count", len(names))
+
+		s.setServices(ctx, namespace, names)
+	}
+}
+
+// setServices replaces the services to sync for the given Consul namespace
+// and updates the Sink with the services of all namespaces. A nil names
+// removes the namespace.
+func (s *Source) setServices(ctx context.Context, namespace string, names map[string]struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// The watcher of a deleted namespace may have been cancelled while
+	// querying. Don't add its services back.
+	if ctx.Err() != nil {
+		return
+	}
+
+	if s.services == nil {
+		s.services = make(map[string]map[string]struct{})
+	}
+	if names == nil {
+		delete(s.services, namespace)
+	} else {
+		s.services[namespace] = names
+	}
+	delete(s.pendingNamespaces, namespace)
+	if len(s.ServiceMeta) > 0 {
+		s.updateMetaWatchers(ctx)
+	}
+	s.updateSink(ctx)
+}
+
+// updateSink updates the Sink with the services of all namespaces unless
+// some watchers haven't responded yet.
+//
+// Precondition: lock must be held.
+func (s *Source) updateSink(ctx context.Context) {
+	if len(s.pendingNamespaces) > 0 {
+		s.Log.Debug("waiting for services of all Consul namespaces before updating",
+			"pending", len(s.pendingNamespaces))
+		return
+	}
+	if len(s.pendingMeta) > 0 {
+		s.Log.Debug("waiting for the instances of all services before updating",
+			"pending", len(s.pendingMeta))
+		return
+	}
+
+	services := make(map[string]string)
+	for ns, nsNames := range s.services {
+		for name := range nsNames {
+			if s.matchesMetaSelector(ns, name) {
+				services[s.sinkKey(ns, name)] = s.dnsName(ns, name)
+			}
+		}
+	}
+	s.Sink.SetServices(services)
+
+	if s.SyncEndpoints {
+		s.updateEndpointWatchers(ctx)
+	}
+}
+
+// matchesMetaSelector returns true if the service has no meta selectors to
+// match or if its instances match them.
+//
+// Precondition: lock must be held.
+func (s *Source) matchesMetaSelector(namespace, name string) bool {
+	if len(s.ServiceMeta) == 0 {
+		return true
+	}
+	_, ok := s.metaMatches[namespace+"/"+name]
+	return ok
+}
+
+// updateMetaWatchers starts a meta watcher for each service that passes the
+// name and tag filters and doesn't have one yet and stops the watchers of
+// services that are no longer present.
+//
+// Precondition: lock must be held.
+func (s *Source) updateMetaWatchers(ctx context.Context) {
+	if s.metaWatchers == nil {
+		s.metaWatchers = make(map[string]context.CancelFunc)
+		s.metaMatches = make(map[string]struct{})
+		s.pendingMeta = make(map[string]struct{})
+	}
+
+	for key, cancelF := range s.metaWatchers {
+		ns, name := splitKey(key)
+		if _, ok := s.services[ns][name]; !ok {
+			cancelF()
+			delete(s.metaWatchers, key)
+			delete(s.metaMatches, key)
+			delete(s.pendingMeta, key)
+			s.Log.Debug("[updateMetaWatchers] stopped meta watcher", "service-name", name, "consul-namespace", ns)
+		}
+	}
+
+	for ns, names := range s.services {
+		for name := range names {
+			key := ns + "/" + name
+			if _, ok := s.metaWatchers[key]; ok {
+				continue
+			}
+
+			svcCtx, cancelF := context.WithCancel(ctx)
+			go s.watchMeta(svcCtx, ctx, ns, name)
+			s.metaWatchers[key] = cancelF
+			s.pendingMeta[key] = struct{}{}
+			s.Log.Debug("[updateMetaWatchers] started meta watcher", "service-name", name, "consul-namespace", ns)
+		}
+	}
+}
+
+// watchMeta holds a blocking query on the instances of the given Consul
+// service and updates the Sink whenever the service starts or stops
+// matching ServiceMeta. The services list doesn't include meta so each
+// service is watched separately. It returns once ctx is cancelled; sinkCtx
+// is the context of the services watcher used to update the Sink.
+func (s *Source) watchMeta(ctx, sinkCtx context.Context, namespace, name string) {
+	key := namespace + "/" + name
+	opts := (&api.QueryOptions{
+		AllowStale: true,
+		WaitIndex:  1,
+		WaitTime:   1 * time.Minute,
+		Namespace:  namespace,
+	}).WithContext(ctx)
+	for {
+		var instances []*api.CatalogService
+		var meta *api.QueryMeta
+		err := backoff.Retry(func() error {
+			var err error
+			instances, meta, err = s.Client.Catalog().Service(name, "", opts)
+			return err
+		}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+
+		// If the context is ended, then we end
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			s.Log.Warn("error querying service instances, will retry", "service-name", name, "err", err)
+			continue
+		}
+
+		// Update our blocking index
+		opts.WaitIndex = meta.LastIndex
+
+		matches := s.shouldSyncMeta(instances)
+		if !matches {
+			s.Log.Debug("service does not match meta selectors", "service-name", name)
+		}
+		s.setMetaMatch(ctx, sinkCtx, key, matches)
+	}
+}
+
+// setMetaMatch records whether the instances of the service with the given
+// key match ServiceMeta and updates the Sink if that changed.
+func (s *Source) setMetaMatch(ctx, sinkCtx context.Context, key string, matches bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// The watcher may have been stopped while querying.
+	if ctx.Err() != nil {
+		return
+	}
+
+	_, matched := s.metaMatches[key]
+	_, pending := s.pendingMeta[key]
+	if matched == matches && !pending {
+		return
+	}
+	if matches {
+		s.metaMatches[key] = struct{}{}
+	} else {
+		delete(s.metaMatches, key)
+	}
+	delete(s.pendingMeta, key)
+	s.updateSink(sinkCtx)
+}
+
+// setPendingNamespaces marks the Consul namespaces as pending until their
+// services watcher responds.
+func (s *Source) setPendingNamespaces(namespaces []string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.pendingNamespaces == nil {
+		s.pendingNamespaces = make(map[string]struct{})
+	}
+	for _, ns := range namespaces {
+		s.pendingNamespaces[ns] = struct{}{}
+	}
+}
+
+// sinkKey returns the key of the Consul service in the given Consul
+// namespace that is passed to the Sink.
+func (s *Source) sinkKey(namespace, name string) string {
+	if s.EnableConsulNSMirroring {
+		return fmt.Sprintf("%s/%s%s", namespaces.K8SNamespace(namespace, s.ConsulNSMirroringPrefix), s.Prefix, name)
+	}
+	return s.Prefix + name
+}
+
+// dnsName returns the Consul DNS name of the service in the given Consul
+// namespace.
+func (s *Source) dnsName(namespace, name string) string {
+	if namespace != "" {
+		return fmt.Sprintf("%s.service.%s.ns.%s", name, namespace, s.Domain)
+	}
+	return fmt.Sprintf("%s.service.%s", name, s.Domain)
+}
+
+// updateEndpointWatchers starts a health watcher for each service to sync
+// that doesn't have one yet and stops the watchers of services that are
+// no longer present.
+//
+// Precondition: lock must be held.
+func (s *Source) updateEndpointWatchers(ctx context.Context) {
+	if s.endpointWatchers == nil {
+		s.endpointWatchers = make(map[string]context.CancelFunc)
+	}
+
+	for key, cancelF := range s.endpointWatchers {
+		ns, name := splitKey(key)
+		if _, ok := s.services[ns][name]; !ok || !s.matchesMetaSelector(ns, name) {
+			cancelF()
+			delete(s.endpointWatchers, key)
+			s.Sink.SetEndpoints(s.sinkKey(ns, name), nil)
+			s.Log.Debug("[updateEndpointWatchers] stopped health watcher", "service-name", name, "consul-namespace", ns)
+		}
+	}
+
+	for ns, names := range s.services {
+		for name := range names {
+			key := ns + "/" + name
+			if _, ok := s.endpointWatchers[key]; ok || !s.matchesMetaSelector(ns, name) {
+				continue
+			}
+
+			svcCtx, cancelF := context.WithCancel(ctx)
+			go s.watchEndpoints(svcCtx, ns, name)
+			s.endpointWatchers[key] = cancelF
+			s.Log.Debug("[updateEndpointWatchers] started health watcher", "service-name", name, "consul-namespace", ns)
+		}
+	}
+}
+
+// watchEndpoints holds a blocking query on the health of the given
+// Consul service and updates the Sink with its instances whenever they
+// change. It returns once ctx is cancelled.
+func (s *Source) watchEndpoints(ctx context.Context, namespace, name string) {
+	opts := (&api.QueryOptions{
+		AllowStale: true,
+		WaitIndex:  1,
+		WaitTime:   1 * time.Minute,
+		Namespace:  namespace,
+	}).WithContext(ctx)
+	for {
+		var entries []*api.ServiceEntry
+		var meta *api.QueryMeta
+		err := backoff.Retry(func() error {
+			var err error
+			entries, meta, err = s.Client.Health().Service(name, "", false, opts)
+			return err
+		}, backoff.WithContext(backoff.NewExponentialBackOff(), ctx))
+
+		// If the context is ended, then we end
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			s.Log.Warn("error querying service health, will retry", "service-name", name, "err", err)
+			continue
+		}
+
+		// Update our blocking index
+		opts.WaitIndex = meta.LastIndex
+
+		endpoints := make([]Endpoint, 0, len(entries))
+		for _, entry := range entries {
+			// Service addresses are optional in Consul, in which case the
+			// node address is the address of the instance.
+			addr := entry.Service.Address
+			if addr == "" {
+				addr = entry.Node.Address
+			}
+
+			endpoints = append(endpoints, Endpoint{
+				Address: addr,
+				Port:    entry.Service.Port,
+				Healthy: entry.Checks.AggregatedStatus() == api.HealthPassing,
+			})
+		}
+		s.Log.Debug("received service instances from Consul", "service-name", name, "count", len(endpoints))
+
+		s.Sink.SetEndpoints(s.sinkKey(namespace, name), endpoints)
+	}
+}
+
+// shouldSync returns true if the Consul service with the given name and
+// tags passes the name and tag filters of the Source. Meta selectors are
+// checked separately by watchMeta since they require a query for the
+// service's instances.
+func (s *Source) shouldSync(name string, tags []string) bool {
+	// If in deny list, don't sync
+	if matchesAny(s.DenyServices, name) {
+		s.Log.Debug("[shouldSync] service is in the deny list", "service-name", name)
+		return false
+	}
+
+	// If there is an allow list and the service is not in it, don't sync
+	if len(s.AllowServices) > 0 && !matchesAny(s.AllowServices, name) {
+		s.Log.Debug("[shouldSync] service not in allow list", "service-name", name)
+		return false
+	}
+
+	// The service must have all required tags
+	for _, required := range s.RequiredTags {
+		found := false
+		for _, t := range tags {
+			if t == required {
+				found = true
+				break
 			}
 		}
-		s.Log.Info("received services from Consul", "count", len(services))
+		if !found {
+			s.Log.Debug("[shouldSync] service is missing required tag", "service-name", name, "tag", required)
+			return false
+		}
+	}
+
+	return true
+}
 
-		s.Sink.SetServices(services)
+// shouldSyncMeta returns true if any of the given service instances has
+// all the meta key/value pairs in ServiceMeta.
+func (s *Source) shouldSyncMeta(instances []*api.CatalogService) bool {
+	for _, instance := range instances {
+		if matchesMeta(s.ServiceMeta, instance.ServiceMeta) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesMeta returns true if meta contains all the key/value pairs of
+// selector.
+func matchesMeta(selector, meta map[string]string) bool {
+	for k, v := range selector {
+		if actual, ok := meta[k]; !ok || actual != v {
+			return false
+		}
+	}
+	return true
+}
+
+// splitKey splits a key in the form <namespace>/<name>.
+func splitKey(key string) (string, string) {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+// matchesAny returns true if name matches any of the glob patterns.
+// Patterns have already been validated so match errors are ignored.
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
 	}
+	return false
 }
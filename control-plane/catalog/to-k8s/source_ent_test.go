@@ -0,0 +1,81 @@
+//go:build enterprise
+
+package catalog
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/hashicorp/consul/sdk/testutil/retry"
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the source keys services by the Kubernetes namespace that
+// mirrors their Consul namespace.
+func TestSource_consulNamespaceMirroring(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		prefix   string
+		expected map[string]string
+	}{
+		"no prefix": {
+			expected: map[string]string{
+				"default/consul":  "consul.service.default.ns.test",
+				"k8s-team-a/svcA": "svcA.service.k8s-team-a.ns.test",
+				"team-b/svcB":     "svcB.service.team-b.ns.test",
+			},
+		},
+		"prefix": {
+			prefix: "k8s-",
+			expected: map[string]string{
+				"default/consul": "consul.service.default.ns.test",
+				"team-a/svcA":    "svcA.service.k8s-team-a.ns.test",
+				"team-b/svcB":    "svcB.service.team-b.ns.test",
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			a, err := testutil.NewTestServerConfigT(t, nil)
+			require.NoError(t, err)
+			defer a.Stop()
+
+			client, err := api.NewClient(&api.Config{
+				Address: a.HTTPAddr,
+			})
+			require.NoError(t, err)
+
+			for ns, svc := range map[string]string{"k8s-team-a": "svcA", "team-b": "svcB"} {
+				_, _, err = client.Namespaces().Create(&api.Namespace{Name: ns}, nil)
+				require.NoError(t, err)
+
+				reg := testRegistration("hostA", svc, nil)
+				reg.Service.Namespace = ns
+				_, err = client.Catalog().Register(reg, nil)
+				require.NoError(t, err)
+			}
+
+			_, sink, closer := testSourceWithConfig(client, func(s *Source) {
+				s.EnableConsulNSMirroring = true
+				s.ConsulNSMirroringPrefix = c.prefix
+			})
+			defer closer()
+
+			var actual map[string]string
+			retry.Run(t, func(r *retry.R) {
+				sink.Lock()
+				defer sink.Unlock()
+				actual = sink.Services
+				if len(actual) != 3 {
+					r.Fatal("services not found")
+				}
+			})
+
+			require.Equal(t, c.expected, actual)
+		})
+	}
+}
@@ -2,7 +2,13 @@ package catalog
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
 	toconsul "github.com/hashicorp/consul-k8s/control-plane/catalog/to-consul"
@@ -259,6 +265,319 @@ func TestSource_deleteServiceInstance(t *testing.T) {
 	})
 }
 
+// Test that the source only syncs services that pass its filters.
+func TestSource_filters(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Set up server, client
+	a, err := testutil.NewTestServerConfigT(t, nil)
+	require.NoError(err)
+	defer a.Stop()
+
+	client, err := api.NewClient(&api.Config{
+		Address: a.HTTPAddr,
+	})
+	require.NoError(err)
+
+	// Create services before the source is running
+	for _, reg := range []*api.CatalogRegistration{
+		testRegistration("hostA", "web", []string{"public"}),
+		testRegistration("hostA", "web-admin", []string{"public"}),
+		testRegistration("hostA", "api", []string{"public"}),
+		testRegistration("hostA", "db", nil),
+		testRegistration("hostA", "cache", []string{"public"}),
+	} {
+		if reg.Service.Service != "cache" {
+			reg.Service.Meta = map[string]string{"team": "a"}
+		}
+		_, err = client.Catalog().Register(reg, nil)
+		require.NoError(err)
+	}
+
+	_, sink, closer := testSourceWithConfig(client, func(s *Source) {
+		s.AllowServices = []string{"web*", "api", "db", "cache"}
+		s.DenyServices = []string{"*-admin"}
+		s.RequiredTags = []string{"public"}
+		s.ServiceMeta = map[string]string{"team": "a"}
+	})
+	defer closer()
+
+	var actual map[string]string
+	retry.Run(t, func(r *retry.R) {
+		sink.Lock()
+		defer sink.Unlock()
+		actual = sink.Services
+		if len(actual) != 2 {
+			r.Fatal("services not found")
+		}
+	})
+
+	expected := map[string]string{
+		"web": "web.service.test",
+		"api": "api.service.test",
+	}
+	require.Equal(expected, actual)
+}
+
+func TestSource_shouldSync(t *testing.T) {
+	t.Parallel()
+
+	cases := map[string]struct {
+		allow    []string
+		deny     []string
+		required []string
+		name     string
+		tags     []string
+		expected bool
+	}{
+		"no filters": {
+			name:     "web",
+			expected: true,
+		},
+		"allowed by name": {
+			allow:    []string{"web"},
+			name:     "web",
+			expected: true,
+		},
+		"allowed by glob": {
+			allow:    []string{"api", "web-*"},
+			name:     "web-frontend",
+			expected: true,
+		},
+		"not in allow list": {
+			allow:    []string{"api"},
+			name:     "web",
+			expected: false,
+		},
+		"denied by glob": {
+			deny:     []string{"*-sidecar-proxy"},
+			name:     "web-sidecar-proxy",
+			expected: false,
+		},
+		"deny takes precedence": {
+			allow:    []string{"*"},
+			deny:     []string{"web"},
+			name:     "web",
+			expected: false,
+		},
+		"has required tags": {
+			required: []string{"a", "b"},
+			name:     "web",
+			tags:     []string{"b", "c", "a"},
+			expected: true,
+		},
+		"missing required tag": {
+			required: []string{"a", "b"},
+			name:     "web",
+			tags:     []string{"a"},
+			expected: false,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := &Source{
+				AllowServices: c.allow,
+				DenyServices:  c.deny,
+				RequiredTags:  c.required,
+				Log:           hclog.Default(),
+			}
+			require.Equal(t, c.expected, s.shouldSync(c.name, c.tags))
+		})
+	}
+}
+
+func TestSource_shouldSyncMeta(t *testing.T) {
+	t.Parallel()
+
+	s := &Source{
+		ServiceMeta: map[string]string{"team": "a", "env": "prod"},
+		Log:         hclog.Default(),
+	}
+	require.False(t, s.shouldSyncMeta(nil))
+	require.False(t, s.shouldSyncMeta([]*api.CatalogService{
+		{ServiceMeta: map[string]string{"team": "a"}},
+		{ServiceMeta: map[string]string{"team": "b", "env": "prod"}},
+	}))
+	require.True(t, s.shouldSyncMeta([]*api.CatalogService{
+		{ServiceMeta: map[string]string{"team": "b"}},
+		{ServiceMeta: map[string]string{"team": "a", "env": "prod", "version": "2"}},
+	}))
+}
+
+// Test that when mirroring namespaces the sink isn't updated until the
+// services watcher of every namespace has responded.
+func TestSource_setServicesWaitsForPendingNamespaces(t *testing.T) {
+	t.Parallel()
+
+	sink := &TestSink{}
+	s := &Source{
+		Domain:                  "test",
+		Sink:                    sink,
+		Log:                     hclog.Default(),
+		EnableConsulNSMirroring: true,
+	}
+	ctx := context.Background()
+	s.setPendingNamespaces([]string{"default", "team-a"})
+
+	s.setServices(ctx, "default", map[string]struct{}{"web": {}})
+	require.Nil(t, sink.Services)
+
+	s.setServices(ctx, "team-a", map[string]struct{}{"api": {}})
+	require.Equal(t, map[string]string{
+		"default/web": "web.service.default.ns.test",
+		"team-a/api":  "api.service.team-a.ns.test",
+	}, sink.Services)
+
+	// A namespace that's deleted before its watcher responds isn't waited on.
+	s.setPendingNamespaces([]string{"team-b"})
+	s.setServices(ctx, "team-b", nil)
+	s.setServices(ctx, "default", map[string]struct{}{})
+	require.Equal(t, map[string]string{
+		"team-a/api": "api.service.team-a.ns.test",
+	}, sink.Services)
+}
+
+// Test that services are only passed to the sink once the meta watchers of
+// all services have responded, and that a change to the meta of the
+// instances of a service is picked up by its own blocking query.
+func TestSource_metaWatchers(t *testing.T) {
+	t.Parallel()
+
+	var lock sync.Mutex
+	index := 2
+	meta := map[string]map[string]string{
+		"web": {"team": "a"},
+		"api": {"team": "b"},
+	}
+	changed := make(chan struct{})
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := strings.TrimPrefix(r.URL.Path, "/v1/catalog/service/")
+		lock.Lock()
+		current := index
+		lock.Unlock()
+		// Blocking queries at the current index return once the meta has
+		// changed.
+		if r.URL.Query().Get("index") == strconv.Itoa(current) {
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		lock.Lock()
+		instances := []*api.CatalogService{{ServiceName: name, ServiceMeta: meta[name]}}
+		w.Header().Set("X-Consul-Index", strconv.Itoa(index))
+		lock.Unlock()
+		require.NoError(t, json.NewEncoder(w).Encode(instances))
+	}))
+	defer consulServer.Close()
+	client, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	sink := &TestSink{}
+	s := &Source{
+		Client:      client,
+		Domain:      "test",
+		Sink:        sink,
+		Log:         hclog.Default(),
+		ServiceMeta: map[string]string{"team": "a"},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s.setServices(ctx, "", map[string]struct{}{"web": {}, "api": {}})
+	retry.Run(t, func(r *retry.R) {
+		sink.Lock()
+		defer sink.Unlock()
+		if !reflect.DeepEqual(map[string]string{"web": "web.service.test"}, sink.Services) {
+			r.Fatalf("unexpected services: %v", sink.Services)
+		}
+	})
+
+	lock.Lock()
+	meta["api"] = map[string]string{"team": "a"}
+	index++
+	lock.Unlock()
+	close(changed)
+	retry.Run(t, func(r *retry.R) {
+		sink.Lock()
+		defer sink.Unlock()
+		if !reflect.DeepEqual(map[string]string{"web": "web.service.test", "api": "api.service.test"}, sink.Services) {
+			r.Fatalf("unexpected services: %v", sink.Services)
+		}
+	})
+}
+
+// Test that the source passes the instances of each service to the sink
+// when syncing endpoints.
+func TestSource_syncEndpoints(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Set up server, client
+	a, err := testutil.NewTestServerConfigT(t, nil)
+	require.NoError(err)
+	defer a.Stop()
+
+	client, err := api.NewClient(&api.Config{
+		Address: a.HTTPAddr,
+	})
+	require.NoError(err)
+
+	// Create services before the source is running
+	_, err = client.Catalog().Register(testRegistration("hostA", "svcA", nil), nil)
+	require.NoError(err)
+	reg := testRegistration("hostB", "svcA", nil)
+	reg.Service.Address = "127.0.0.2"
+	reg.Service.Port = 8080
+	reg.Check = &api.AgentCheck{
+		Node:      "hostB",
+		CheckID:   "svcA-check",
+		Name:      "svcA-check",
+		Status:    api.HealthCritical,
+		ServiceID: "svcA",
+	}
+	_, err = client.Catalog().Register(reg, nil)
+	require.NoError(err)
+
+	_, sink, closer := testSourceWithConfig(client, func(s *Source) {
+		s.Prefix = "foo-"
+		s.SyncEndpoints = true
+	})
+	defer closer()
+
+	var actual []Endpoint
+	retry.Run(t, func(r *retry.R) {
+		sink.Lock()
+		defer sink.Unlock()
+		actual = sink.Endpoints["foo-svcA"]
+		if len(actual) != 2 {
+			r.Fatal("endpoints not found")
+		}
+	})
+
+	require.ElementsMatch([]Endpoint{
+		{Address: "127.0.0.1", Port: 0, Healthy: true},
+		{Address: "127.0.0.2", Port: 8080, Healthy: false},
+	}, actual)
+
+	// Delete the service and verify its endpoints are removed
+	_, err = client.Catalog().Deregister(&api.CatalogDeregistration{Node: "hostA", ServiceID: "svcA"}, nil)
+	require.NoError(err)
+	_, err = client.Catalog().Deregister(&api.CatalogDeregistration{Node: "hostB", ServiceID: "svcA"}, nil)
+	require.NoError(err)
+
+	retry.Run(t, func(r *retry.R) {
+		sink.Lock()
+		defer sink.Unlock()
+		if len(sink.Endpoints["foo-svcA"]) != 0 {
+			r.Fatal("endpoints not removed")
+		}
+	})
+}
+
 // testRegistration creates a Consul test registration.
 func testRegistration(node, service string, tags []string) *api.CatalogRegistration {
 	return &api.CatalogRegistration{
@@ -8,7 +8,8 @@ import (
 // Reading/writing the services should be done only while the lock is held.
 type TestSink struct {
 	sync.Mutex
-	Services map[string]string
+	Services  map[string]string
+	Endpoints map[string][]Endpoint
 }
 
 func (s *TestSink) SetServices(raw map[string]string) {
@@ -16,3 +17,12 @@ func (s *TestSink) SetServices(raw map[string]string) {
 	defer s.Unlock()
 	s.Services = raw
 }
+
+func (s *TestSink) SetEndpoints(name string, endpoints []Endpoint) {
+	s.Lock()
+	defer s.Unlock()
+	if s.Endpoints == nil {
+		s.Endpoints = make(map[string][]Endpoint)
+	}
+	s.Endpoints[name] = endpoints
+}
@@ -10,8 +10,10 @@ import (
 	cmdController "github.com/hashicorp/consul-k8s/control-plane/subcommand/controller"
 	cmdCreateFederationSecret "github.com/hashicorp/consul-k8s/control-plane/subcommand/create-federation-secret"
 	cmdDeleteCompletedJob "github.com/hashicorp/consul-k8s/control-plane/subcommand/delete-completed-job"
+	cmdEnvoyLifecycle "github.com/hashicorp/consul-k8s/control-plane/subcommand/envoy-lifecycle"
 	cmdGetConsulClientCA "github.com/hashicorp/consul-k8s/control-plane/subcommand/get-consul-client-ca"
 	cmdGossipEncryptionAutogenerate "github.com/hashicorp/consul-k8s/control-plane/subcommand/gossip-encryption-autogenerate"
+	cmdImportConfigEntries "github.com/hashicorp/consul-k8s/control-plane/subcommand/import-config-entries"
 	cmdInjectConnect "github.com/hashicorp/consul-k8s/control-plane/subcommand/inject-connect"
 	cmdPartitionInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/partition-init"
 	cmdServerACLInit "github.com/hashicorp/consul-k8s/control-plane/subcommand/server-acl-init"
@@ -47,6 +49,10 @@ func init() {
 			return &cmdConsulSidecar.Command{UI: ui}, nil
 		},
 
+		"envoy-lifecycle": func() (cli.Command, error) {
+			return &cmdEnvoyLifecycle.Command{UI: ui}, nil
+		},
+
 		"consul-logout": func() (cli.Command, error) {
 			return &cmdConsulLogout.Command{UI: ui}, nil
 		},
@@ -98,6 +104,10 @@ func init() {
 		"gossip-encryption-autogenerate": func() (cli.Command, error) {
 			return &cmdGossipEncryptionAutogenerate.Command{UI: ui}, nil
 		},
+
+		"import-config-entries": func() (cli.Command, error) {
+			return &cmdImportConfigEntries.Command{UI: ui}, nil
+		},
 	}
 }
 
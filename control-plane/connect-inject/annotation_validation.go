@@ -0,0 +1,394 @@
+package connectinject
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// annotationPrefix is the prefix of all annotations read by the meshWebhook
+// and the endpoints controller.
+const annotationPrefix = "consul.hashicorp.com/"
+
+// annotationValidator validates the value of an annotation on the pod.
+type annotationValidator func(w *MeshWebhook, pod corev1.Pod, key, value string) error
+
+// podAnnotationValidators are the annotations that can be set on a pod and
+// the validation of their values. Annotations without value constraints
+// have a nil validator.
+var podAnnotationValidators = map[string]annotationValidator{
+	keyInjectStatus:                           nil,
+	keyManagedBy:                              nil,
+	annotationInject:                          validateBoolAnnotation,
+	annotationInjectMountVolumes:              nil,
+	annotationService:                         nil,
+	annotationKubernetesService:               nil,
+	annotationPort:                            validatePortsAnnotation,
+	annotationProtocol:                        nil,
+	annotationUpstreams:                       validateUpstreamsAnnotation,
+	annotationTags:                            nil,
+	annotationConnectTags:                     nil,
+	annotationSyncPeriod:                      nil,
+	annotationSidecarProxyCPULimit:            validateQuantityAnnotation,
+	annotationSidecarProxyCPURequest:          validateQuantityAnnotation,
+	annotationSidecarProxyMemoryLimit:         validateQuantityAnnotation,
+	annotationSidecarProxyMemoryRequest:       validateQuantityAnnotation,
+	annotationConsulSidecarCPULimit:           validateQuantityAnnotation,
+	annotationConsulSidecarCPURequest:         validateQuantityAnnotation,
+	annotationConsulSidecarMemoryLimit:        validateQuantityAnnotation,
+	annotationConsulSidecarMemoryRequest:      validateQuantityAnnotation,
+	annotationConsulSidecarUserVolume:         validateUserVolumeAnnotation,
+	annotationConsulSidecarUserVolumeMount:    validateUserVolumeMountAnnotation,
+	annotationEnvoyProxyConcurrency:           validateNonNegativeIntAnnotation,
+	annotationSidecarProxyShutdownGracePeriod: validateNonNegativeIntAnnotation,
+	annotationSidecarProxyHoldApplication:     validateBoolAnnotation,
+	annotationEnableMetrics:                   validateBoolAnnotation,
+	annotationEnableMetricsMerging:            validateBoolAnnotation,
+	annotationMergedMetricsPort:               validateUnprivilegedPortAnnotation,
+	annotationPrometheusScrapePort:            validateUnprivilegedPortAnnotation,
+	annotationPrometheusScrapePath:            nil,
+	annotationServiceMetricsPort:              validatePortAnnotation,
+	annotationServiceMetricsPath:              nil,
+	annotationMergedMetricsConfig:             validateMergedMetricsConfigAnnotation,
+	annotationPrometheusCAFile:                nil,
+	annotationPrometheusCAPath:                nil,
+	annotationPrometheusCertFile:              nil,
+	annotationPrometheusKeyFile:               nil,
+	annotationEnvoyExtraArgs:                  nil,
+	annotationProxyConfig:                     nil,
+	keyInjectionProfile:                       nil,
+	annotationConsulNamespace:                 nil,
+	keyConsulDNS:                              validateBoolAnnotation,
+	keyTransparentProxy:                       validateBoolAnnotation,
+	annotationTProxyExcludeInboundPorts:       validatePortListAnnotation,
+	annotationTProxyExcludeOutboundPorts:      validatePortListAnnotation,
+	annotationTProxyExcludeOutboundCIDRs:      validateCIDRListAnnotation,
+	annotationTProxyExcludeUIDs:               validateUIDListAnnotation,
+	annotationTProxyOutboundService:           validateTProxyOutboundServiceAnnotation,
+	annotationTransparentProxyOverwriteProbes: validateBoolAnnotation,
+	annotationOriginalPod:                     nil,
+}
+
+// validateAnnotations validates all consul.hashicorp.com annotations of the
+// pod. It returns an error for every unknown annotation, with a suggestion
+// if it looks like a typo of a known annotation, and for every annotation
+// with an invalid value. The errors are sorted by annotation.
+func (w *MeshWebhook) validateAnnotations(pod corev1.Pod) []error {
+	keys := make([]string, 0, len(pod.Annotations))
+	for k := range pod.Annotations {
+		if strings.HasPrefix(k, annotationPrefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, k := range keys {
+		if strings.HasPrefix(k, annotationMeta) {
+			if strings.TrimPrefix(k, annotationMeta) == "" {
+				errs = append(errs, fmt.Errorf("annotation %q must have a metadata key after the %q prefix", k, annotationMeta))
+			}
+			continue
+		}
+
+		validator, ok := podAnnotationValidators[k]
+		if !ok {
+			if suggestion := closestAnnotation(k); suggestion != "" {
+				errs = append(errs, fmt.Errorf("unknown annotation %q, did you mean %q?", k, suggestion))
+			} else {
+				errs = append(errs, fmt.Errorf("unknown annotation %q", k))
+			}
+			continue
+		}
+
+		if validator != nil {
+			if err := validator(w, pod, k, pod.Annotations[k]); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errs
+}
+
+// closestAnnotation returns the known annotation that is closest to the
+// given key by edit distance, or an empty string if none is close enough
+// to be a likely typo.
+func closestAnnotation(key string) string {
+	const maxDistance = 3
+
+	name := strings.TrimPrefix(key, annotationPrefix)
+	candidates := []string{annotationMeta}
+	for k := range podAnnotationValidators {
+		candidates = append(candidates, k)
+	}
+	// Sort so that ties are broken deterministically.
+	sort.Strings(candidates)
+
+	closest, closestDistance := "", maxDistance+1
+	for _, c := range candidates {
+		d := levenshtein(name, strings.TrimPrefix(c, annotationPrefix))
+		if d < closestDistance {
+			closest, closestDistance = c, d
+		}
+	}
+	return closest
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+func validateBoolAnnotation(_ *MeshWebhook, _ corev1.Pod, key, value string) error {
+	if _, err := strconv.ParseBool(value); err != nil {
+		return fmt.Errorf("annotation %q has invalid value %q: must be true or false", key, value)
+	}
+	return nil
+}
+
+func validateQuantityAnnotation(_ *MeshWebhook, _ corev1.Pod, key, value string) error {
+	if _, err := resource.ParseQuantity(value); err != nil {
+		return fmt.Errorf("annotation %q has invalid value %q: %s", key, value, err)
+	}
+	return nil
+}
+
+func validateNonNegativeIntAnnotation(_ *MeshWebhook, _ corev1.Pod, key, value string) error {
+	if v, err := strconv.Atoi(value); err != nil || v < 0 {
+		return fmt.Errorf("annotation %q has invalid value %q: must be an integer of 0 or greater", key, value)
+	}
+	return nil
+}
+
+func validatePortAnnotation(_ *MeshWebhook, pod corev1.Pod, key, _ string) error {
+	_, err := determineAndValidatePort(pod, key, "", true)
+	return err
+}
+
+func validateUnprivilegedPortAnnotation(_ *MeshWebhook, pod corev1.Pod, key, _ string) error {
+	_, err := determineAndValidatePort(pod, key, "", false)
+	return err
+}
+
+func validateMergedMetricsConfigAnnotation(w *MeshWebhook, pod corev1.Pod, _, _ string) error {
+	_, err := w.MetricsConfig.mergingConfig(pod)
+	return err
+}
+
+// validatePortsAnnotation validates the comma-separated ports of a
+// multi-port pod, each of which can be a named port of the pod.
+func validatePortsAnnotation(_ *MeshWebhook, pod corev1.Pod, key, value string) error {
+	for _, raw := range strings.Split(value, ",") {
+		if err := validatePodPort(pod, strings.TrimSpace(raw)); err != nil {
+			return fmt.Errorf("annotation %q has invalid value %q: %s", key, value, err)
+		}
+	}
+	return nil
+}
+
+func validatePortListAnnotation(_ *MeshWebhook, _ corev1.Pod, key, value string) error {
+	for _, raw := range strings.Split(value, ",") {
+		port, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("annotation %q has invalid port %q: must be between 1 and 65535", key, raw)
+		}
+	}
+	return nil
+}
+
+func validateCIDRListAnnotation(_ *MeshWebhook, _ corev1.Pod, key, value string) error {
+	for _, raw := range strings.Split(value, ",") {
+		raw = strings.TrimSpace(raw)
+		if _, _, err := net.ParseCIDR(raw); err != nil && net.ParseIP(raw) == nil {
+			return fmt.Errorf("annotation %q has invalid CIDR %q: must be an IP address or CIDR", key, raw)
+		}
+	}
+	return nil
+}
+
+func validateUIDListAnnotation(_ *MeshWebhook, _ corev1.Pod, key, value string) error {
+	for _, raw := range strings.Split(value, ",") {
+		if uid, err := strconv.Atoi(strings.TrimSpace(raw)); err != nil || uid < 0 {
+			return fmt.Errorf("annotation %q has invalid user ID %q: must be an integer of 0 or greater", key, raw)
+		}
+	}
+	return nil
+}
+
+func validateTProxyOutboundServiceAnnotation(w *MeshWebhook, pod corev1.Pod, key, value string) error {
+	services := w.annotatedServiceNames(pod)
+	for _, svc := range services {
+		if svc == value {
+			return nil
+		}
+	}
+	return fmt.Errorf("annotation %q has invalid value %q: must be one of the services of the pod %q", key, value, strings.Join(services, ","))
+}
+
+func validateUserVolumeAnnotation(_ *MeshWebhook, _ corev1.Pod, key, value string) error {
+	var volumes []corev1.Volume
+	if err := json.Unmarshal([]byte(value), &volumes); err != nil {
+		return fmt.Errorf("annotation %q is invalid: error unmarshalling sidecar user volumes: %s", key, err)
+	}
+	return nil
+}
+
+func validateUserVolumeMountAnnotation(_ *MeshWebhook, _ corev1.Pod, key, value string) error {
+	var volumeMounts []corev1.VolumeMount
+	if err := json.Unmarshal([]byte(value), &volumeMounts); err != nil {
+		return fmt.Errorf("annotation %q is invalid: error unmarshalling sidecar user volume mounts: %s", key, err)
+	}
+	return nil
+}
+
+// validateUpstreamsAnnotation validates the upstreams annotation against the
+// formats accepted by the endpoints controller:
+//
+//	[service-name].[service-namespace].[service-partition]:[port]:[optional datacenter]
+//	[service-name].svc.[service-namespace].ns.[service-peer].peer:[port]
+//	[service-name].svc.[service-namespace].ns.[service-partition].ap:[port]
+//	[service-name].svc.[service-namespace].ns.[service-datacenter].dc:[port]
+//	prepared_query:[query name]:[port]
+func validateUpstreamsAnnotation(w *MeshWebhook, pod corev1.Pod, key, value string) error {
+	namespacesOrPartitions := w.EnableNamespaces || w.ConsulPartition != ""
+	for _, raw := range strings.Split(value, ",") {
+		if err := validateUpstream(pod, strings.TrimSpace(raw), namespacesOrPartitions); err != nil {
+			return fmt.Errorf("annotation %q has invalid upstream %q: %s", key, strings.TrimSpace(raw), err)
+		}
+	}
+	return nil
+}
+
+func validateUpstream(pod corev1.Pod, raw string, namespacesOrPartitions bool) error {
+	parts := strings.SplitN(raw, ":", 3)
+	if len(parts) < 2 {
+		return fmt.Errorf("must be in the format <service>:<port>")
+	}
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+
+	if parts[0] == "prepared_query" {
+		if len(parts) != 3 || parts[1] == "" {
+			return fmt.Errorf("must be in the format prepared_query:<query name>:<port>")
+		}
+		return validatePodPort(pod, parts[2])
+	}
+
+	if err := validatePodPort(pod, parts[1]); err != nil {
+		return err
+	}
+
+	pieces := strings.Split(parts[0], ".")
+	if len(pieces) >= 2 && pieces[1] == "svc" {
+		if len(parts) == 3 {
+			return fmt.Errorf("the datacenter must be set with the .dc label instead of a third segment")
+		}
+		return validateLabeledUpstream(pieces, namespacesOrPartitions)
+	}
+
+	if len(parts) == 3 && parts[2] == "" {
+		return fmt.Errorf("datacenter must not be empty")
+	}
+	maxPieces := 1
+	if namespacesOrPartitions {
+		maxPieces = 3
+	}
+	if len(pieces) > maxPieces {
+		if namespacesOrPartitions {
+			return fmt.Errorf("service must be in the format <service>[.<namespace>[.<partition>]]")
+		}
+		return fmt.Errorf("service name must not contain a %q unless Consul namespaces or admin partitions are enabled", ".")
+	}
+	for _, p := range pieces {
+		if p == "" {
+			return fmt.Errorf("service, namespace and partition must not be empty")
+		}
+	}
+	return nil
+}
+
+func validateLabeledUpstream(pieces []string, namespacesOrPartitions bool) error {
+	for _, p := range pieces {
+		if p == "" {
+			return fmt.Errorf("labels and their values must not be empty")
+		}
+	}
+
+	validLengths, validLabels := []int{2, 4}, []string{"peer", "dc"}
+	format := "<service>.svc[.<peer>.peer|.<datacenter>.dc]"
+	if namespacesOrPartitions {
+		validLengths, validLabels = []int{2, 4, 6}, []string{"peer", "ap", "dc"}
+		format = "<service>.svc[.<namespace>.ns[.<peer>.peer|.<partition>.ap|.<datacenter>.dc]]"
+	}
+
+	valid := false
+	for _, l := range validLengths {
+		if len(pieces) == l {
+			valid = true
+		}
+	}
+	if !valid {
+		return fmt.Errorf("must be in the format %s", format)
+	}
+
+	if namespacesOrPartitions && len(pieces) >= 4 && pieces[3] != "ns" {
+		return fmt.Errorf("must be in the format %s", format)
+	}
+
+	end := pieces[len(pieces)-1]
+	if len(pieces) > 2 && !(namespacesOrPartitions && len(pieces) == 4) {
+		for _, l := range validLabels {
+			if end == l {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be in the format %s", format)
+	}
+	return nil
+}
+
+// validatePodPort validates that the value is either a named port of the
+// pod or a port number between 1 and 65535.
+func validatePodPort(pod corev1.Pod, value string) error {
+	port, err := portValue(pod, value)
+	if err != nil {
+		return fmt.Errorf("port %q is neither a named port of the pod nor a number", value)
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("port %d must be between 1 and 65535", port)
+	}
+	return nil
+}
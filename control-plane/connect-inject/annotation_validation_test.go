@@ -0,0 +1,230 @@
+package connectinject
+
+import (
+	"context"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestValidateAnnotations(t *testing.T) {
+	cases := []struct {
+		name                   string
+		annotations            map[string]string
+		namespacesOrPartitions bool
+		expErrs                []string
+	}{
+		{
+			name: "valid annotations",
+			annotations: map[string]string{
+				annotationInject:                     "true",
+				annotationPort:                       "http,9090",
+				annotationUpstreams:                  "db:1234, cache:2345:dc2, prepared_query:q:3456, api.svc.peer1.peer:4567",
+				annotationSidecarProxyCPULimit:       "100m",
+				annotationSidecarProxyMemoryRequest:  "64Mi",
+				annotationEnvoyProxyConcurrency:      "2",
+				annotationMergedMetricsPort:          "20100",
+				annotationServiceMetricsPort:         "80",
+				annotationTProxyExcludeOutboundCIDRs: "10.0.0.0/8,1.1.1.1",
+				annotationTProxyExcludeInboundPorts:  "8080, 9090",
+				annotationTProxyExcludeUIDs:          "0,5995",
+				annotationConsulSidecarUserVolume:    `[{"name":"vol","emptyDir":{}}]`,
+				annotationMeta + "owner":             "team",
+				"example.com/other":                  "ignored",
+			},
+		},
+		{
+			name: "labeled upstreams with namespaces",
+			annotations: map[string]string{
+				annotationUpstreams: "db.svc.ns1.ns:1234,cache.svc.ns1.ns.ap1.ap:2345,api.db.part:3456",
+			},
+			namespacesOrPartitions: true,
+		},
+		{
+			name: "typo",
+			annotations: map[string]string{
+				"consul.hashicorp.com/connect-service-upstream": "db:1234",
+			},
+			expErrs: []string{`unknown annotation "consul.hashicorp.com/connect-service-upstream", did you mean "consul.hashicorp.com/connect-service-upstreams"?`},
+		},
+		{
+			name: "unknown annotation",
+			annotations: map[string]string{
+				"consul.hashicorp.com/something-else": "true",
+			},
+			expErrs: []string{`unknown annotation "consul.hashicorp.com/something-else"`},
+		},
+		{
+			name: "invalid values",
+			annotations: map[string]string{
+				annotationInject:                     "yes",
+				annotationPort:                       "grpc",
+				annotationSidecarProxyCPULimit:       "lots",
+				annotationEnvoyProxyConcurrency:      "-1",
+				annotationMergedMetricsPort:          "80",
+				annotationTProxyExcludeOutboundCIDRs: "10.0.0.0/33",
+				annotationTProxyExcludeOutboundPorts: "70000",
+			},
+			expErrs: []string{
+				`annotation "consul.hashicorp.com/connect-inject" has invalid value "yes": must be true or false`,
+				`annotation "consul.hashicorp.com/connect-service-port" has invalid value "grpc": port "grpc" is neither a named port of the pod nor a number`,
+				`annotation "consul.hashicorp.com/consul-envoy-proxy-concurrency" has invalid value "-1": must be an integer of 0 or greater`,
+				`consul.hashicorp.com/merged-metrics-port annotation value of 80 is not in the unprivileged port range 1024-65535`,
+				`annotation "consul.hashicorp.com/sidecar-proxy-cpu-limit" has invalid value "lots": quantities must match the regular expression '^([+-]?[0-9.]+)([eEinumkKMGTP]*[-+]?[0-9]*)$'`,
+				`annotation "consul.hashicorp.com/transparent-proxy-exclude-outbound-cidrs" has invalid CIDR "10.0.0.0/33": must be an IP address or CIDR`,
+				`annotation "consul.hashicorp.com/transparent-proxy-exclude-outbound-ports" has invalid port "70000": must be between 1 and 65535`,
+			},
+		},
+		{
+			name: "upstream without port",
+			annotations: map[string]string{
+				annotationUpstreams: "db",
+			},
+			expErrs: []string{`annotation "consul.hashicorp.com/connect-service-upstreams" has invalid upstream "db": must be in the format <service>:<port>`},
+		},
+		{
+			name: "upstream with namespace when namespaces are disabled",
+			annotations: map[string]string{
+				annotationUpstreams: "db.ns1:1234",
+			},
+			expErrs: []string{`annotation "consul.hashicorp.com/connect-service-upstreams" has invalid upstream "db.ns1:1234": service name must not contain a "." unless Consul namespaces or admin partitions are enabled`},
+		},
+		{
+			name: "labeled upstream with unknown label",
+			annotations: map[string]string{
+				annotationUpstreams: "db.svc.dc1.datacenter:1234",
+			},
+			expErrs: []string{`annotation "consul.hashicorp.com/connect-service-upstreams" has invalid upstream "db.svc.dc1.datacenter:1234": must be in the format <service>.svc[.<peer>.peer|.<datacenter>.dc]`},
+		},
+		{
+			name: "labeled upstream with partition when partitions are disabled",
+			annotations: map[string]string{
+				annotationUpstreams: "db.svc.ap1.ap:1234",
+			},
+			expErrs: []string{`annotation "consul.hashicorp.com/connect-service-upstreams" has invalid upstream "db.svc.ap1.ap:1234": must be in the format <service>.svc[.<peer>.peer|.<datacenter>.dc]`},
+		},
+		{
+			name: "prepared query without name",
+			annotations: map[string]string{
+				annotationUpstreams: "prepared_query:1234",
+			},
+			expErrs: []string{`annotation "consul.hashicorp.com/connect-service-upstreams" has invalid upstream "prepared_query:1234": must be in the format prepared_query:<query name>:<port>`},
+		},
+		{
+			name: "invalid user volume",
+			annotations: map[string]string{
+				annotationConsulSidecarUserVolume: `{"name":"vol"}`,
+			},
+			expErrs: []string{`annotation "consul.hashicorp.com/consul-sidecar-user-volume" is invalid: error unmarshalling sidecar user volumes: json: cannot unmarshal object into Go value of type []v1.Volume`},
+		},
+		{
+			name: "valid merged metrics config",
+			annotations: map[string]string{
+				annotationMergedMetricsConfig: `{"targets": [{"name": "app", "port": "http", "labels": {"source": "app"}}]}`,
+			},
+		},
+		{
+			name: "merged metrics config with unknown named port",
+			annotations: map[string]string{
+				annotationMergedMetricsConfig: `{"targets": [{"name": "app", "port": "metrics"}]}`,
+			},
+			expErrs: []string{`consul.hashicorp.com/merged-metrics-config annotation value is invalid: port "metrics" of target "app" is not a valid port`},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := MeshWebhook{EnableNamespaces: c.namespacesOrPartitions}
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:  "web",
+							Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+						},
+					},
+				},
+			}
+
+			var actual []string
+			for _, err := range w.validateAnnotations(pod) {
+				actual = append(actual, err.Error())
+			}
+			require.Equal(t, c.expErrs, actual)
+		})
+	}
+}
+
+func TestHandler_InvalidAnnotations(t *testing.T) {
+	cases := map[string]struct {
+		namespace   string
+		warnOnly    bool
+		expAllowed  bool
+		expMessage  string
+		expWarnings []string
+	}{
+		"not injected": {
+			namespace:  metav1.NamespaceSystem,
+			expAllowed: true,
+		},
+		"rejected": {
+			namespace:  "default",
+			expAllowed: false,
+			expMessage: `invalid annotations: unknown annotation "consul.hashicorp.com/connect-injct", did you mean "consul.hashicorp.com/connect-inject"?`,
+		},
+		"warn only": {
+			namespace:   "default",
+			warnOnly:    true,
+			expAllowed:  true,
+			expWarnings: []string{`unknown annotation "consul.hashicorp.com/connect-injct", did you mean "consul.hashicorp.com/connect-inject"?`},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			s.AddKnownTypes(schema.GroupVersion{Group: "", Version: "v1"}, &corev1.Pod{})
+			decoder, err := admission.NewDecoder(s)
+			require.NoError(t, err)
+
+			webhook := MeshWebhook{
+				Log:                      logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet:    mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:     mapset.NewSet(),
+				WarnOnInvalidAnnotations: c.warnOnly,
+				decoder:                  decoder,
+				Clientset:                defaultTestClientWithNamespace(),
+			}
+
+			request := admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: c.namespace,
+					Object: encodeRaw(t, &corev1.Pod{
+						ObjectMeta: metav1.ObjectMeta{
+							Annotations: map[string]string{"consul.hashicorp.com/connect-injct": "true"},
+						},
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "web"}},
+						},
+					}),
+				},
+			}
+
+			response := webhook.Handle(context.Background(), request)
+			require.Equal(t, c.expAllowed, response.Allowed)
+			if !c.expAllowed {
+				require.Equal(t, c.expMessage, response.Result.Message)
+			}
+			require.Equal(t, c.expWarnings, response.Warnings)
+		})
+	}
+}
@@ -93,6 +93,15 @@ const (
 	// annotations for sidecar concurrency.
 	annotationEnvoyProxyConcurrency = "consul.hashicorp.com/consul-envoy-proxy-concurrency"
 
+	// annotationSidecarProxyShutdownGracePeriod is how many seconds the Envoy
+	// sidecar drains its inbound connections for when the pod is terminated.
+	// Zero disables draining.
+	annotationSidecarProxyShutdownGracePeriod = "consul.hashicorp.com/sidecar-proxy-shutdown-grace-period-seconds"
+
+	// annotationSidecarProxyHoldApplication controls whether the application
+	// containers are only started once the Envoy sidecar is ready.
+	annotationSidecarProxyHoldApplication = "consul.hashicorp.com/sidecar-proxy-hold-application-until-ready"
+
 	// annotations for metrics to configure where Prometheus scrapes
 	// metrics from, whether to run a merged metrics endpoint on the consul
 	// sidecar, and configure the connect service metrics.
@@ -104,6 +113,20 @@ const (
 	annotationServiceMetricsPort   = "consul.hashicorp.com/service-metrics-port"
 	annotationServiceMetricsPath   = "consul.hashicorp.com/service-metrics-path"
 
+	// annotationMergedMetricsConfig is a JSON configuration of the metrics
+	// endpoints of the pod to merge with the Envoy metrics, how to relabel
+	// the metrics of each endpoint and of Envoy, and the format of the merged
+	// metrics. If it configures targets, they replace the endpoint configured
+	// by the service-metrics-port and service-metrics-path annotations, e.g.
+	// {"envoy": {"labels": {"source": "envoy"}},
+	//  "targets": [{"name": "app", "port": "8080", "labels": {"source": "app"}},
+	//              {"name": "jvm", "port": "9404", "prefix": "jvm_", "scheme": "https",
+	//               "tls": {"volume": "jvm-certs", "caFile": "ca.crt"}}]}
+	// The TLS files are read from the named volume of the pod, which is
+	// mounted into the consul-sidecar container.
+	// See the metrics.MergingConfig type for all fields.
+	annotationMergedMetricsConfig = "consul.hashicorp.com/merged-metrics-config"
+
 	// annotations for configuring TLS for Prometheus.
 	annotationPrometheusCAFile   = "consul.hashicorp.com/prometheus-ca-file"
 	annotationPrometheusCAPath   = "consul.hashicorp.com/prometheus-ca-path"
@@ -117,6 +140,15 @@ const (
 	// passed via the -envoy-extra-args flag.
 	annotationEnvoyExtraArgs = "consul.hashicorp.com/envoy-extra-args"
 
+	// annotationProxyConfig is the name of the ProxyConfig resource whose settings were
+	// applied to the pod by the meshWebhook.
+	annotationProxyConfig = "consul.hashicorp.com/proxy-config"
+
+	// keyInjectionProfile selects the injection profile whose sidecar settings are applied to the pod. It can
+	// also be set as a label on a namespace to select the profile of the connect-injected pods in the namespace
+	// which do not select one with their own annotation. The meshWebhook sets it to the profile that was applied.
+	keyInjectionProfile = "consul.hashicorp.com/injection-profile"
+
 	// annotationConsulNamespace is the Consul namespace the service is registered into.
 	annotationConsulNamespace = "consul.hashicorp.com/consul-namespace"
 
@@ -144,6 +176,12 @@ const (
 	// annotationTProxyExcludeUIDs is a comma-separated list of additional user IDs to exclude from traffic redirection.
 	annotationTProxyExcludeUIDs = "consul.hashicorp.com/transparent-proxy-exclude-uids"
 
+	// annotationTProxyOutboundService is the name of the service of a multi-port pod whose proxy
+	// the outbound traffic of the pod is redirected to. The traffic leaves the pod with the
+	// identity of that service, so intentions to the upstreams must allow it. It must be set
+	// on multi-port pods that use transparent proxy.
+	annotationTProxyOutboundService = "consul.hashicorp.com/transparent-proxy-outbound-service"
+
 	// annotationTransparentProxyOverwriteProbes controls whether the Kubernetes probes should be overwritten
 	// to point to the Envoy proxy when running in Transparent Proxy mode.
 	annotationTransparentProxyOverwriteProbes = "consul.hashicorp.com/transparent-proxy-overwrite-probes"
@@ -152,6 +190,12 @@ const (
 	// webhook/meshWebhook.
 	annotationOriginalPod = "consul.hashicorp.com/original-pod"
 
+	// annotationServiceIdentities is a comma-separated list of the services whose identity the
+	// services of multi-port pods that share a ServiceAccount may log in with. It is set on the
+	// ServiceAccount so that only its owner, not the author of a pod, grants identities to it.
+	// The service named after the ServiceAccount doesn't need to be listed.
+	annotationServiceIdentities = "consul.hashicorp.com/service-identities"
+
 	// annotationPeeringVersion is the version of the peering resource and can be utilized
 	// to explicitly perform the peering operation again.
 	annotationPeeringVersion = "consul.hashicorp.com/peering-version"
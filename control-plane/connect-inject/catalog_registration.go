@@ -0,0 +1,347 @@
+package connectinject
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// syntheticNodeSuffix is appended to the name of a Kubernetes node to
+	// get the name of the Consul node its pods are registered on when they
+	// are registered directly with the Consul servers.
+	syntheticNodeSuffix = "-virtual"
+
+	// MetaKeySyntheticNode is the node meta key that marks the Consul nodes
+	// registered for Kubernetes nodes by the endpoints controller.
+	MetaKeySyntheticNode = "synthetic-node"
+
+	// kubernetesHealthCheckType is the type of the health checks that
+	// reflect the readiness of pods when services are registered with the
+	// catalog. No agent runs these checks; their status is set by the
+	// endpoints controller.
+	kubernetesHealthCheckType = "kubernetes-readiness"
+)
+
+// ConsulNodeName returns the name of the Consul node that the services of
+// pods running on the Kubernetes node with the given name are registered on
+// when they are registered directly with the Consul servers.
+func ConsulNodeName(k8sNodeName string) string {
+	return k8sNodeName + syntheticNodeSuffix
+}
+
+// registerServicesWithCatalog registers the service and proxy service
+// instances of the pod and their health checks with the catalog of the
+// Consul servers instead of with the client agent on the pod's node.
+// The instances are registered on a synthetic Consul node for the pod's
+// Kubernetes node. Since no agent runs health checks for them, the checks of
+// the proxy registration are replaced by a check that reflects the readiness
+// of the pod, the same as the check of the service.
+func (r *EndpointsController) registerServicesWithCatalog(pod corev1.Pod, serviceEndpoints corev1.Endpoints, healthStatus string) error {
+	if pod.Spec.NodeName == "" {
+		return fmt.Errorf("pod %s/%s is not scheduled on a node", pod.Namespace, pod.Name)
+	}
+
+	serviceRegistration, proxyServiceRegistration, err := r.createServiceRegistrations(pod, serviceEndpoints)
+	if err != nil {
+		r.Log.Error(err, "failed to create service registrations for endpoints", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+		return err
+	}
+
+	client, err := r.serverConsulClient(r.consulNamespace(pod.Namespace))
+	if err != nil {
+		r.Log.Error(err, "failed to create a new Consul client")
+		return err
+	}
+
+	nodeName := ConsulNodeName(pod.Spec.NodeName)
+	reason := getHealthCheckStatusReason(healthStatus, pod.Name, pod.Namespace)
+
+	// The service must be registered before the proxy service, the same as
+	// when registering with an agent.
+	for _, registration := range []*api.AgentServiceRegistration{serviceRegistration, proxyServiceRegistration} {
+		r.Log.Info("registering service with Consul catalog", "name", registration.Name,
+			"id", registration.ID, "node", nodeName)
+		_, err = client.Catalog().Register(&api.CatalogRegistration{
+			Node:    nodeName,
+			Address: pod.Status.HostIP,
+			NodeMeta: map[string]string{
+				MetaKeySyntheticNode: "true",
+				MetaKeyManagedBy:     managedByValue,
+			},
+			Service: agentServiceFromRegistration(registration),
+			Check: &api.AgentCheck{
+				CheckID:     getConsulHealthCheckID(pod, registration.ID),
+				Name:        "Kubernetes Readiness Check",
+				Type:        kubernetesHealthCheckType,
+				Status:      healthStatus,
+				Output:      reason,
+				ServiceID:   registration.ID,
+				ServiceName: registration.Name,
+				Namespace:   registration.Namespace,
+			},
+		}, nil)
+		if err != nil {
+			r.Log.Error(err, "failed to register service with Consul catalog", "name", registration.Name)
+			return err
+		}
+	}
+	return nil
+}
+
+// deregisterServiceFromCatalog deregisters the service instances of the
+// Kubernetes service from the synthetic nodes in the catalog of the Consul
+// servers. If endpointsAddressesMap is nil all instances are deregistered,
+// otherwise only those whose address isn't in the map.
+//
+// The instances are looked up by their Consul service names, and the names
+// of their proxies, if serviceNames is set. Otherwise, e.g. once the
+// Kubernetes service has been deleted and the names of its Consul services
+// are no longer known, the services of every synthetic node are searched.
+func (r *EndpointsController) deregisterServiceFromCatalog(ctx context.Context, k8sSvcName, k8sSvcNamespace string, endpointsAddressesMap map[string]bool, serviceNames mapset.Set) error {
+	client, err := r.serverConsulClient(r.consulNamespace(k8sSvcNamespace))
+	if err != nil {
+		r.Log.Error(err, "failed to create a new Consul client")
+		return err
+	}
+
+	filter := fmt.Sprintf(`ServiceMeta[%q] == %q and ServiceMeta[%q] == %q and ServiceMeta[%q] == %q`,
+		MetaKeyKubeServiceName, k8sSvcName, MetaKeyKubeNS, k8sSvcNamespace, MetaKeyManagedBy, managedByValue)
+	var instances []*api.CatalogService
+	if serviceNames != nil {
+		instances, err = r.catalogServiceInstancesByName(client, serviceNames, filter)
+	} else {
+		instances, err = r.catalogServiceInstancesOnSyntheticNodes(client, k8sSvcName, k8sSvcNamespace)
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, svc := range instances {
+		if endpointsAddressesMap != nil && endpointsAddressesMap[svc.ServiceAddress] {
+			continue
+		}
+
+		r.Log.Info("deregistering service from consul catalog", "svc", svc.ServiceID, "node", svc.Node)
+		_, err = client.Catalog().Deregister(&api.CatalogDeregistration{
+			Node:      svc.Node,
+			ServiceID: svc.ServiceID,
+			Namespace: svc.Namespace,
+		}, nil)
+		r.recordDeregistration(ctx, k8sSvcName, k8sSvcNamespace, svc.ServiceID, err)
+		if err != nil {
+			r.Log.Error(err, "failed to deregister service instance", "id", svc.ServiceID)
+			return err
+		}
+
+		if r.AuthMethod != "" {
+			r.Log.Info("reconciling ACL tokens for service", "svc", svc.ServiceName)
+			err = r.deleteACLTokensForServiceInstance(client, svc.ServiceName, k8sSvcNamespace, svc.ServiceMeta[MetaKeyPodName])
+			if err != nil {
+				r.Log.Error(err, "failed to reconcile ACL tokens for service", "svc", svc.ServiceName)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// catalogServiceInstancesByName returns the instances on synthetic nodes of
+// the Consul services with the given names and of their proxies that match
+// the filter.
+func (r *EndpointsController) catalogServiceInstancesByName(client *api.Client, serviceNames mapset.Set, filter string) ([]*api.CatalogService, error) {
+	var names []string
+	for name := range serviceNames.Iter() {
+		names = append(names, name.(string), fmt.Sprintf("%s-sidecar-proxy", name))
+	}
+	sort.Strings(names)
+
+	var instances []*api.CatalogService
+	for _, name := range names {
+		services, _, err := client.Catalog().Service(name, "", &api.QueryOptions{
+			NodeMeta: map[string]string{MetaKeySyntheticNode: "true"},
+			Filter:   filter,
+		})
+		if err != nil {
+			r.Log.Error(err, "failed to get service instances", "name", name)
+			return nil, err
+		}
+		instances = append(instances, services...)
+	}
+	return instances, nil
+}
+
+// catalogServiceInstancesOnSyntheticNodes returns the instances of the
+// Kubernetes service on all synthetic nodes.
+func (r *EndpointsController) catalogServiceInstancesOnSyntheticNodes(client *api.Client, k8sSvcName, k8sSvcNamespace string) ([]*api.CatalogService, error) {
+	nodes, _, err := client.Catalog().Nodes(&api.QueryOptions{
+		NodeMeta: map[string]string{MetaKeySyntheticNode: "true"},
+	})
+	if err != nil {
+		r.Log.Error(err, "failed to get Consul nodes")
+		return nil, err
+	}
+
+	filter := fmt.Sprintf(`Meta[%q] == %q and Meta[%q] == %q and Meta[%q] == %q`,
+		MetaKeyKubeServiceName, k8sSvcName, MetaKeyKubeNS, k8sSvcNamespace, MetaKeyManagedBy, managedByValue)
+	var instances []*api.CatalogService
+	for _, node := range nodes {
+		services, _, err := client.Catalog().NodeServiceList(node.Node, &api.QueryOptions{Filter: filter})
+		if err != nil {
+			r.Log.Error(err, "failed to get service instances", "name", k8sSvcName, "node", node.Node)
+			return nil, err
+		}
+		if services == nil {
+			continue
+		}
+		for _, svc := range services.Services {
+			instances = append(instances, &api.CatalogService{
+				Node:           node.Node,
+				ServiceID:      svc.ID,
+				ServiceName:    svc.Service,
+				ServiceAddress: svc.Address,
+				ServiceMeta:    svc.Meta,
+				Namespace:      svc.Namespace,
+			})
+		}
+	}
+	return instances, nil
+}
+
+// serverConsulClient returns a client for the Consul servers the endpoints
+// controller is configured with, scoped to the given Consul namespace.
+func (r *EndpointsController) serverConsulClient(namespace string) (*api.Client, error) {
+	cfg := *r.ConsulClientCfg
+	cfg.Namespace = namespace
+	return consul.NewClient(&cfg, r.ConsulAPITimeout)
+}
+
+// agentServiceFromRegistration converts an agent service registration into
+// the service of a catalog registration.
+func agentServiceFromRegistration(registration *api.AgentServiceRegistration) *api.AgentService {
+	return &api.AgentService{
+		Kind:            registration.Kind,
+		ID:              registration.ID,
+		Service:         registration.Name,
+		Tags:            registration.Tags,
+		Meta:            registration.Meta,
+		Port:            registration.Port,
+		Address:         registration.Address,
+		TaggedAddresses: registration.TaggedAddresses,
+		Proxy:           registration.Proxy,
+		Namespace:       registration.Namespace,
+		Partition:       registration.Partition,
+	}
+}
+
+// syntheticNodeRequeueAfter is how long the SyntheticNodeController waits
+// before checking again whether the services on a synthetic node of a
+// deleted Kubernetes node have been deregistered.
+const syntheticNodeRequeueAfter = 1 * time.Minute
+
+// SyntheticNodeController deregisters the synthetic Consul nodes of
+// Kubernetes nodes that no longer exist. The services of a synthetic node
+// are deregistered by the endpoints controller once the pods of the deleted
+// Kubernetes node are gone, so a synthetic node is only deregistered once it
+// has no services left; deregistering it earlier would leave the ACL tokens
+// of its services behind.
+//
+// Every event maps to the same request, so all synthetic nodes are checked
+// at once, including those of Kubernetes nodes that were deleted while the
+// controller wasn't running.
+type SyntheticNodeController struct {
+	client.Client
+	// ConsulClient points at the Consul servers.
+	ConsulClient *api.Client
+	// EnableConsulNamespaces indicates that a user is running Consul Enterprise
+	// with version 1.7+ which supports namespaces.
+	EnableConsulNamespaces bool
+	Log                    logr.Logger
+}
+
+func (r *SyntheticNodeController) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	var k8sNodes corev1.NodeList
+	if err := r.Client.List(ctx, &k8sNodes); err != nil {
+		r.Log.Error(err, "failed to list Kubernetes nodes")
+		return ctrl.Result{}, err
+	}
+	existing := make(map[string]bool, len(k8sNodes.Items))
+	for _, node := range k8sNodes.Items {
+		existing[ConsulNodeName(node.Name)] = true
+	}
+
+	consulNodes, _, err := r.ConsulClient.Catalog().Nodes(&api.QueryOptions{
+		NodeMeta: map[string]string{MetaKeySyntheticNode: "true", MetaKeyManagedBy: managedByValue},
+	})
+	if err != nil {
+		r.Log.Error(err, "failed to get Consul nodes")
+		return ctrl.Result{}, err
+	}
+
+	var requeue bool
+	for _, node := range consulNodes {
+		if existing[node.Node] {
+			continue
+		}
+		// The node holds the services of all Consul namespaces.
+		opts := &api.QueryOptions{}
+		if r.EnableConsulNamespaces {
+			opts.Namespace = wildcard
+		}
+		services, _, err := r.ConsulClient.Catalog().NodeServiceList(node.Node, opts)
+		if err != nil {
+			r.Log.Error(err, "failed to get services of Consul node", "node", node.Node)
+			return ctrl.Result{}, err
+		}
+		if services != nil && len(services.Services) > 0 {
+			r.Log.Info("waiting for the services of the synthetic node of a deleted Kubernetes node to be deregistered",
+				"node", node.Node, "services", len(services.Services))
+			requeue = true
+			continue
+		}
+
+		r.Log.Info("deregistering synthetic node of deleted Kubernetes node", "node", node.Node)
+		if _, err := r.ConsulClient.Catalog().Deregister(&api.CatalogDeregistration{Node: node.Node}, nil); err != nil {
+			r.Log.Error(err, "failed to deregister Consul node", "node", node.Node)
+			return ctrl.Result{}, err
+		}
+	}
+
+	if requeue {
+		return ctrl.Result{RequeueAfter: syntheticNodeRequeueAfter}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+func (r *SyntheticNodeController) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("synthetic-node").
+		Watches(
+			&source.Kind{Type: &corev1.Node{}},
+			handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+				return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "synthetic-nodes"}}}
+			}),
+			// Only the creation and deletion of nodes matter, not their
+			// frequent status updates.
+			builder.WithPredicates(predicate.Funcs{
+				UpdateFunc: func(event.UpdateEvent) bool { return false },
+			}),
+		).
+		Complete(r)
+}
@@ -0,0 +1,232 @@
+package connectinject
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestConsulNodeName(t *testing.T) {
+	require.Equal(t, "node1-virtual", ConsulNodeName("node1"))
+}
+
+// fakeCatalog is a mock of the catalog API of the Consul servers that records
+// registrations and deregistrations.
+type fakeCatalog struct {
+	mu              sync.Mutex
+	registrations   []api.CatalogRegistration
+	deregistrations []api.CatalogDeregistration
+	// nodeServices are the services returned for each node.
+	nodeServices map[string][]*api.AgentService
+	// nodeServicesRequests counts the requests for the services of a node.
+	nodeServicesRequests int
+}
+
+func (f *fakeCatalog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch {
+	case r.URL.Path == "/v1/catalog/register" && r.Method == "PUT":
+		var reg api.CatalogRegistration
+		if err := json.NewDecoder(r.Body).Decode(&reg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.registrations = append(f.registrations, reg)
+		w.Write([]byte("true"))
+	case r.URL.Path == "/v1/catalog/deregister" && r.Method == "PUT":
+		var dereg api.CatalogDeregistration
+		if err := json.NewDecoder(r.Body).Decode(&dereg); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.deregistrations = append(f.deregistrations, dereg)
+		w.Write([]byte("true"))
+	case r.URL.Path == "/v1/catalog/nodes" && r.Method == "GET":
+		var nodes []*api.Node
+		for name := range f.nodeServices {
+			nodes = append(nodes, &api.Node{Node: name})
+		}
+		json.NewEncoder(w).Encode(nodes)
+	case strings.HasPrefix(r.URL.Path, "/v1/catalog/node-services/"):
+		f.nodeServicesRequests++
+		node := strings.TrimPrefix(r.URL.Path, "/v1/catalog/node-services/")
+		json.NewEncoder(w).Encode(api.CatalogNodeServiceList{
+			Node:     &api.Node{Node: node},
+			Services: f.nodeServices[node],
+		})
+	case strings.HasPrefix(r.URL.Path, "/v1/catalog/service/"):
+		name := strings.TrimPrefix(r.URL.Path, "/v1/catalog/service/")
+		services := []*api.CatalogService{}
+		for node, nodeServices := range f.nodeServices {
+			for _, svc := range nodeServices {
+				if svc.Service == name {
+					services = append(services, &api.CatalogService{
+						Node:           node,
+						ServiceID:      svc.ID,
+						ServiceName:    svc.Service,
+						ServiceAddress: svc.Address,
+						ServiceMeta:    svc.Meta,
+					})
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(services)
+	default:
+		// Agent endpoints must not be used.
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+func TestReconcile_AgentlessRegistration(t *testing.T) {
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	pod.Spec.NodeName = "node1"
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "service-created", Namespace: "default"},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{
+					{
+						IP:        "1.2.3.4",
+						NodeName:  &pod.Spec.NodeName,
+						TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "pod1", Namespace: "default"},
+					},
+				},
+			},
+		},
+	}
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, ns).Build()
+
+	catalog := &fakeCatalog{
+		nodeServices: map[string][]*api.AgentService{
+			"node1-virtual": {
+				{ID: "pod1-service-created", Service: "service-created", Address: "1.2.3.4"},
+				// An instance of a pod that no longer exists.
+				{ID: "pod2-service-created", Service: "service-created", Address: "2.2.2.2"},
+			},
+		},
+	}
+	consulServer := httptest.NewServer(catalog)
+	defer consulServer.Close()
+
+	ep := &EndpointsController{
+		Client:                      fakeClient,
+		ConsulClientCfg:             &api.Config{Address: consulServer.URL},
+		AllowK8sNamespacesSet:       mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:        mapset.NewSetWith(),
+		ReleaseName:                 "consul",
+		ReleaseNamespace:            "default",
+		EnableAgentlessRegistration: true,
+		Log:                         logrtest.TestLogger{T: t},
+		Context:                     context.Background(),
+	}
+
+	_, err := ep.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "service-created", Namespace: "default"},
+	})
+	require.NoError(t, err)
+
+	// The service is registered before its proxy on the node's synthetic node.
+	require.Len(t, catalog.registrations, 2)
+	for _, reg := range catalog.registrations {
+		require.Equal(t, "node1-virtual", reg.Node)
+		require.Equal(t, "127.0.0.1", reg.Address)
+		require.Equal(t, "true", reg.NodeMeta[MetaKeySyntheticNode])
+		require.Equal(t, kubernetesHealthCheckType, reg.Check.Type)
+		require.Equal(t, api.HealthPassing, reg.Check.Status)
+		require.Equal(t, kubernetesSuccessReasonMsg, reg.Check.Output)
+		require.Equal(t, reg.Service.ID, reg.Check.ServiceID)
+	}
+	require.Equal(t, "pod1-service-created", catalog.registrations[0].Service.ID)
+	require.Equal(t, "pod1-service-created-sidecar-proxy", catalog.registrations[1].Service.ID)
+	require.Equal(t, api.ServiceKindConnectProxy, catalog.registrations[1].Service.Kind)
+	require.Equal(t, "pod1-service-created", catalog.registrations[1].Service.Proxy.DestinationServiceID)
+
+	// Only the instance that is no longer in the endpoints is deregistered.
+	// The instances are looked up by service name rather than by listing
+	// the services of every synthetic node.
+	require.Equal(t, []api.CatalogDeregistration{
+		{Node: "node1-virtual", ServiceID: "pod2-service-created"},
+	}, catalog.deregistrations)
+	require.Equal(t, 0, catalog.nodeServicesRequests)
+}
+
+func TestReconcile_AgentlessRegistration_DeletedEndpoints(t *testing.T) {
+	fakeClient := fake.NewClientBuilder().Build()
+	catalog := &fakeCatalog{
+		nodeServices: map[string][]*api.AgentService{
+			"node1-virtual": {{ID: "pod1-service-deleted", Service: "service-deleted", Address: "1.2.3.4"}},
+			"node2-virtual": {{ID: "pod2-service-deleted", Service: "service-deleted", Address: "2.2.2.2"}},
+		},
+	}
+	consulServer := httptest.NewServer(catalog)
+	defer consulServer.Close()
+
+	ep := &EndpointsController{
+		Client:                      fakeClient,
+		ConsulClientCfg:             &api.Config{Address: consulServer.URL},
+		AllowK8sNamespacesSet:       mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:        mapset.NewSetWith(),
+		EnableAgentlessRegistration: true,
+		Log:                         logrtest.TestLogger{T: t},
+		Context:                     context.Background(),
+	}
+
+	_, err := ep.Reconcile(context.Background(), ctrl.Request{
+		NamespacedName: types.NamespacedName{Name: "service-deleted", Namespace: "default"},
+	})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []api.CatalogDeregistration{
+		{Node: "node1-virtual", ServiceID: "pod1-service-deleted"},
+		{Node: "node2-virtual", ServiceID: "pod2-service-deleted"},
+	}, catalog.deregistrations)
+}
+
+func TestSyntheticNodeController(t *testing.T) {
+	k8sNode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(k8sNode).Build()
+	catalog := &fakeCatalog{
+		nodeServices: map[string][]*api.AgentService{
+			// The Kubernetes node still exists.
+			"node1-virtual": {{ID: "pod1-web", Service: "web"}},
+			// The Kubernetes node was deleted but its services are still
+			// registered.
+			"node2-virtual": {{ID: "pod2-web", Service: "web"}},
+			// The Kubernetes node was deleted and its services are gone.
+			"node3-virtual": nil,
+		},
+	}
+	consulServer := httptest.NewServer(catalog)
+	defer consulServer.Close()
+	consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+	require.NoError(t, err)
+
+	controller := &SyntheticNodeController{
+		Client:       fakeClient,
+		ConsulClient: consulClient,
+		Log:          logrtest.TestLogger{T: t},
+	}
+	result, err := controller.Reconcile(context.Background(), ctrl.Request{})
+	require.NoError(t, err)
+
+	// Only the empty node of a deleted Kubernetes node is deregistered. The
+	// node with services left is checked again later.
+	require.Equal(t, []api.CatalogDeregistration{{Node: "node3-virtual"}}, catalog.deregistrations)
+	require.Equal(t, syntheticNodeRequeueAfter, result.RequeueAfter)
+}
@@ -1,6 +1,7 @@
 package connectinject
 
 import (
+	"encoding/json"
 	"fmt"
 
 	corev1 "k8s.io/api/core/v1"
@@ -33,18 +34,40 @@ func (w *MeshWebhook) consulSidecar(pod corev1.Pod) (corev1.Container, error) {
 		fmt.Sprintf("-log-level=%s", w.LogLevel),
 		fmt.Sprintf("-log-json=%t", w.LogJSON),
 	}
+	volumeMounts := []corev1.VolumeMount{
+		{
+			Name:      volumeName,
+			MountPath: "/consul/connect-inject",
+		},
+	}
+	if metricsPorts.mergingConfig != nil {
+		mergingConfig, err := json.Marshal(metricsPorts.mergingConfig)
+		if err != nil {
+			return corev1.Container{}, err
+		}
+		command = append(command, fmt.Sprintf("-merged-metrics-config=%s", mergingConfig))
+
+		// Mount the volumes holding the TLS files of the targets.
+		mounted := make(map[string]bool)
+		for _, target := range metricsPorts.mergingConfig.Targets {
+			if target.TLS == nil || target.TLS.Volume == "" || mounted[target.TLS.Volume] {
+				continue
+			}
+			mounted[target.TLS.Volume] = true
+			volumeMounts = append(volumeMounts, corev1.VolumeMount{
+				Name:      target.TLS.Volume,
+				MountPath: target.TLS.MountPath(),
+				ReadOnly:  true,
+			})
+		}
+	}
 
 	return corev1.Container{
-		Name:  "consul-sidecar",
-		Image: w.ImageConsulK8S,
-		VolumeMounts: []corev1.VolumeMount{
-			{
-				Name:      volumeName,
-				MountPath: "/consul/connect-inject",
-			},
-		},
-		Command:   command,
-		Resources: resources,
+		Name:         "consul-sidecar",
+		Image:        w.ImageConsulK8S,
+		VolumeMounts: volumeMounts,
+		Command:      command,
+		Resources:    resources,
 	}, nil
 }
 
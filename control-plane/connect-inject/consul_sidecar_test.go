@@ -45,6 +45,86 @@ func TestConsulSidecar_MetricsFlags(t *testing.T) {
 	require.Contains(t, container.Command, "-service-metrics-path=/metrics")
 }
 
+// Test that the merged metrics config is passed to consul sidecar with the
+// named ports of its targets resolved.
+func TestConsulSidecar_MergedMetricsConfig(t *testing.T) {
+	meshWebhook := MeshWebhook{
+		Log:            logrtest.TestLogger{T: t},
+		ImageConsulK8S: "hashicorp/consul-k8s:9.9.9",
+		MetricsConfig: MetricsConfig{
+			DefaultEnableMetrics:        true,
+			DefaultEnableMetricsMerging: true,
+		},
+	}
+	container, err := meshWebhook.consulSidecar(corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotationMergedMetricsPort:   "20100",
+				annotationMergedMetricsConfig: `{"format": "openmetrics", "targets": [{"name": "app", "port": "metrics", "labels": {"source": "app"}}]}`,
+			},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:  "web",
+					Ports: []corev1.ContainerPort{{Name: "metrics", ContainerPort: 9090}},
+				},
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.Contains(t, container.Command, "-enable-metrics-merging=true")
+	require.Contains(t, container.Command,
+		`-merged-metrics-config={"format":"openmetrics","envoy":{},"targets":[{"labels":{"source":"app"},"name":"app","port":"9090","path":"/metrics","scheme":"http"}]}`)
+}
+
+// Test that the volumes of the TLS files of the merged metrics targets are
+// mounted into consul sidecar.
+func TestConsulSidecar_MergedMetricsConfigTLSVolumes(t *testing.T) {
+	meshWebhook := MeshWebhook{
+		Log:            logrtest.TestLogger{T: t},
+		ImageConsulK8S: "hashicorp/consul-k8s:9.9.9",
+		MetricsConfig: MetricsConfig{
+			DefaultEnableMetrics:        true,
+			DefaultEnableMetricsMerging: true,
+		},
+	}
+	container, err := meshWebhook.consulSidecar(corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotationMergedMetricsPort: "20100",
+				annotationMergedMetricsConfig: `{"targets": [
+  {"name": "app", "port": "9090", "scheme": "https", "tls": {"volume": "certs", "caFile": "ca.crt"}},
+  {"name": "jvm", "port": "9404", "scheme": "https", "tls": {"volume": "certs", "certFile": "tls.crt", "keyFile": "tls.key"}},
+  {"name": "sidecar", "port": "9405", "scheme": "https", "tls": {"insecureSkipVerify": true}}
+]}`,
+			},
+		},
+		Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{
+				{
+					Name:         "certs",
+					VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "app-certs"}},
+				},
+			},
+		},
+	})
+
+	require.NoError(t, err)
+	require.Equal(t, []corev1.VolumeMount{
+		{
+			Name:      volumeName,
+			MountPath: "/consul/connect-inject",
+		},
+		{
+			Name:      "certs",
+			MountPath: "/consul/metrics-tls/certs",
+			ReadOnly:  true,
+		},
+	}, container.VolumeMounts)
+}
+
 func TestHandlerConsulSidecar_Resources(t *testing.T) {
 	mem1 := resource.MustParse("100Mi")
 	mem2 := resource.MustParse("200Mi")
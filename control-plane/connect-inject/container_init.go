@@ -1,133 +1,26 @@
 package connectinject
 
 import (
-	"bytes"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
-	"text/template"
-	"time"
 
 	corev1 "k8s.io/api/core/v1"
 )
 
 const (
-	InjectInitCopyContainerName = "copy-consul-bin"
-	InjectInitContainerName     = "consul-connect-inject-init"
-	rootUserAndGroupID          = 0
-	envoyUserAndGroupID         = 5995
-	copyContainerUserAndGroupID = 5996
-	netAdminCapability          = "NET_ADMIN"
-	dnsServiceHostEnvSuffix     = "DNS_SERVICE_HOST"
+	InjectInitContainerName = "consul-connect-inject-init"
+	rootUserAndGroupID      = 0
+	envoyUserAndGroupID     = 5995
+	netAdminCapability      = "NET_ADMIN"
+	dnsServiceHostEnvSuffix = "DNS_SERVICE_HOST"
 )
 
-type initContainerCommandData struct {
-	ServiceName        string
-	ServiceAccountName string
-	AuthMethod         string
-	// ConsulPartition is the Consul admin partition to register the service
-	// and proxy in. An empty string indicates partitions are not
-	// enabled in Consul (necessary for OSS).
-	ConsulPartition string
-	// ConsulNamespace is the Consul namespace to register the service
-	// and proxy in. An empty string indicates namespaces are not
-	// enabled in Consul (necessary for OSS).
-	ConsulNamespace           string
-	NamespaceMirroringEnabled bool
-
-	// The PEM-encoded CA certificate to use when
-	// communicating with Consul clients
-	ConsulCACert string
-	// EnableMetrics adds a listener to Envoy where Prometheus will scrape
-	// metrics from.
-	EnableMetrics bool
-	// PrometheusScrapePath configures the path on the listener on Envoy where
-	// Prometheus will scrape metrics from.
-	PrometheusScrapePath string
-	// PrometheusBackendPort configures where the listener on Envoy will point to.
-	PrometheusBackendPort string
-	// The file paths to use for configuring TLS on the Prometheus metrics endpoint.
-	PrometheusCAFile   string
-	PrometheusCAPath   string
-	PrometheusCertFile string
-	PrometheusKeyFile  string
-	// EnvoyUID is the Linux user id that will be used when tproxy is enabled.
-	EnvoyUID int
-
-	// EnableTransparentProxy configures this init container to run in transparent proxy mode,
-	// i.e. run consul connect redirect-traffic command and add the required privileges to the
-	// container to do that.
-	EnableTransparentProxy bool
-
-	// TProxyExcludeInboundPorts is a list of inbound ports to exclude from traffic redirection via
-	// the consul connect redirect-traffic command.
-	TProxyExcludeInboundPorts []string
-
-	// TProxyExcludeOutboundPorts is a list of outbound ports to exclude from traffic redirection via
-	// the consul connect redirect-traffic command.
-	TProxyExcludeOutboundPorts []string
-
-	// TProxyExcludeOutboundCIDRs is a list of outbound CIDRs to exclude from traffic redirection via
-	// the consul connect redirect-traffic command.
-	TProxyExcludeOutboundCIDRs []string
-
-	// TProxyExcludeUIDs is a list of additional user IDs to exclude from traffic redirection via
-	// the consul connect redirect-traffic command.
-	TProxyExcludeUIDs []string
-
-	// ConsulDNSClusterIP is the IP of the Consul DNS Service.
-	ConsulDNSClusterIP string
-
-	// MultiPort determines whether this is a multi port Pod, which configures the init container to be specific to one
-	// of the services on the multi port Pod.
-	MultiPort bool
-
-	// EnvoyAdminPort configures the admin port of the Envoy sidecar. This will be unique per service in a multi port
-	// Pod.
-	EnvoyAdminPort int
-
-	// BearerTokenFile configures where the service account token can be found. This will be unique per service in a
-	// multi port Pod.
-	BearerTokenFile string
-
-	// ConsulAPITimeout is the duration that the consul API client will
-	// wait for a response from the API before cancelling the request.
-	ConsulAPITimeout time.Duration
-}
-
-// initCopyContainer returns the init container spec for the copy container which places
-// the consul binary into the shared volume.
-func (w *MeshWebhook) initCopyContainer() corev1.Container {
-	// Copy the Consul binary from the image to the shared volume.
-	cmd := "cp /bin/consul /consul/connect-inject/consul"
-	container := corev1.Container{
-		Name:      InjectInitCopyContainerName,
-		Image:     w.ImageConsul,
-		Resources: w.InitContainerResources,
-		VolumeMounts: []corev1.VolumeMount{
-			{
-				Name:      volumeName,
-				MountPath: "/consul/connect-inject",
-			},
-		},
-		Command: []string{"/bin/sh", "-ec", cmd},
-	}
-	// If running on OpenShift, don't set the security context and instead let OpenShift set a random user/group for us.
-	if !w.EnableOpenShift {
-		container.SecurityContext = &corev1.SecurityContext{
-			// Set RunAsUser because the default user for the consul container is root and we want to run non-root.
-			RunAsUser:              pointerToInt64(copyContainerUserAndGroupID),
-			RunAsGroup:             pointerToInt64(copyContainerUserAndGroupID),
-			RunAsNonRoot:           pointerToBool(true),
-			ReadOnlyRootFilesystem: pointerToBool(true),
-		}
-	}
-	return container
-}
-
-// containerInit returns the init container spec for connect-init that polls for the service and the connect proxy service to be registered
-// so that it can save the proxy service id to the shared volume and boostrap Envoy with the proxy-id.
+// containerInit returns the init container spec for connect-init that polls for the service and the connect proxy
+// service to be registered so that it can save the proxy service id to the shared volume, generate the Envoy bootstrap
+// and, if transparent proxy is enabled, redirect the traffic of the pod to Envoy. The container runs connect-init
+// directly so that neither a shell nor the Consul binary are needed.
 func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod, mpi multiPortInfo) (corev1.Container, error) {
 	// Check if tproxy is enabled on this pod.
 	tproxyEnabled, err := transparentProxyEnabled(namespace, pod, w.EnableTransparentProxy)
@@ -153,22 +46,14 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 
 	multiPort := mpi.serviceName != ""
 
-	data := initContainerCommandData{
-		AuthMethod:                 w.AuthMethod,
-		ConsulPartition:            w.ConsulPartition,
-		ConsulNamespace:            w.consulNamespace(namespace.Name),
-		NamespaceMirroringEnabled:  w.EnableK8SNSMirroring,
-		ConsulCACert:               w.ConsulCACert,
-		EnableTransparentProxy:     tproxyEnabled,
-		TProxyExcludeInboundPorts:  splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeInboundPorts, pod),
-		TProxyExcludeOutboundPorts: splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeOutboundPorts, pod),
-		TProxyExcludeOutboundCIDRs: splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeOutboundCIDRs, pod),
-		TProxyExcludeUIDs:          splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeUIDs, pod),
-		ConsulDNSClusterIP:         consulDNSClusterIP,
-		EnvoyUID:                   envoyUserAndGroupID,
-		MultiPort:                  multiPort,
-		EnvoyAdminPort:             19000 + mpi.serviceIndex,
-		ConsulAPITimeout:           w.ConsulAPITimeout,
+	// The Consul API connect-init and Envoy talk to. It's the client agent
+	// on the host of the pod unless services are registered without agents.
+	// Client agents serve gRPC on 8502.
+	consulHost := "$(HOST_IP)"
+	consulGRPCPort := 8502
+	if w.EnableAgentlessRegistration {
+		consulHost = w.ConsulServerHost
+		consulGRPCPort = w.ConsulServerGRPCPort
 	}
 
 	// Create expected volume mounts
@@ -179,33 +64,87 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 		},
 	}
 
+	serviceName := pod.Annotations[annotationService]
 	if multiPort {
-		data.ServiceName = mpi.serviceName
-	} else {
-		data.ServiceName = pod.Annotations[annotationService]
+		serviceName = mpi.serviceName
+	}
+
+	args := []string{
+		"connect-init",
+		"-pod-name=$(POD_NAME)",
+		"-pod-namespace=$(POD_NAMESPACE)",
+		fmt.Sprintf("-consul-api-timeout=%s", w.ConsulAPITimeout),
 	}
 	if w.AuthMethod != "" {
-		if multiPort {
-			// If multi port then we require that the service account name
-			// matches the service name.
-			data.ServiceAccountName = mpi.serviceName
-		} else {
-			data.ServiceAccountName = pod.Spec.ServiceAccountName
+		// If multi port then we require that the service account name
+		// matches the service name unless the services share the service
+		// account of the pod.
+		serviceAccountName := pod.Spec.ServiceAccountName
+		perServiceAccount := multiPort && !w.EnableMultiPortSharedServiceAccount
+		if perServiceAccount {
+			serviceAccountName = mpi.serviceName
 		}
 		// Extract the service account token's volume mount
-		saTokenVolumeMount, bearerTokenFile, err := findServiceAccountVolumeMount(pod, multiPort, mpi.serviceName)
+		saTokenVolumeMount, bearerTokenFile, err := findServiceAccountVolumeMount(pod, perServiceAccount, mpi.serviceName)
 		if err != nil {
 			return corev1.Container{}, err
 		}
-		data.BearerTokenFile = bearerTokenFile
-
 		// Append to volume mounts
 		volMounts = append(volMounts, saTokenVolumeMount)
+
+		args = append(args,
+			fmt.Sprintf("-acl-auth-method=%s", w.AuthMethod),
+			fmt.Sprintf("-service-account-name=%s", serviceAccountName),
+			fmt.Sprintf("-service-name=%s", serviceName),
+			fmt.Sprintf("-bearer-token-file=%s", bearerTokenFile),
+		)
+		if multiPort {
+			args = append(args, fmt.Sprintf("-acl-token-sink=/consul/connect-inject/acl-token-%s", serviceName))
+			if w.EnableMultiPortSharedServiceAccount {
+				args = append(args, "-shared-service-account=true")
+			}
+		}
+		if consulNamespace := w.consulNamespace(namespace.Name); consulNamespace != "" {
+			// If namespace mirroring is enabled, the auth method is
+			// defined in the default namespace.
+			authMethodNamespace := consulNamespace
+			if w.EnableK8SNSMirroring {
+				authMethodNamespace = "default"
+			}
+			args = append(args, fmt.Sprintf("-auth-method-namespace=%s", authMethodNamespace))
+		}
+	}
+	if multiPort {
+		args = append(args,
+			"-multiport=true",
+			fmt.Sprintf("-proxy-id-file=/consul/connect-inject/proxyid-%s", serviceName),
+		)
+		if w.AuthMethod == "" {
+			args = append(args, fmt.Sprintf("-service-name=%s", serviceName))
+		}
+	}
+	if w.EnableAgentlessRegistration {
+		args = append(args, fmt.Sprintf("-consul-node-name=%s", ConsulNodeName("$(NODE_NAME)")))
+	}
+	if w.ConsulPartition != "" {
+		args = append(args, fmt.Sprintf("-partition=%s", w.ConsulPartition))
+	}
+	if consulNamespace := w.consulNamespace(namespace.Name); consulNamespace != "" {
+		args = append(args, fmt.Sprintf("-consul-service-namespace=%s", consulNamespace))
 	}
 
-	// This determines how to configure the consul connect envoy command: what
-	// metrics backend to use and what path to expose on the
-	// envoy_prometheus_bind_addr listener for scraping.
+	// Configure the Envoy bootstrap.
+	if multiPort {
+		args = append(args,
+			fmt.Sprintf("-envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap-%s.yaml", serviceName),
+			fmt.Sprintf("-envoy-admin-bind=127.0.0.1:%d", 19000+mpi.serviceIndex),
+		)
+	} else {
+		args = append(args, "-envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap.yaml")
+	}
+
+	// This determines what metrics backend to use and what path to expose on
+	// the envoy_prometheus_bind_addr listener for scraping.
 	metricsServer, err := w.MetricsConfig.shouldRunMergedMetricsServer(pod)
 	if err != nil {
 		return corev1.Container{}, err
@@ -216,44 +155,85 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 		if err != nil {
 			return corev1.Container{}, err
 		}
-		data.PrometheusScrapePath = prometheusScrapePath
-		data.PrometheusBackendPort = mergedMetricsPort
+		args = append(args,
+			fmt.Sprintf("-prometheus-scrape-path=%s", prometheusScrapePath),
+			fmt.Sprintf("-prometheus-backend-port=%s", mergedMetricsPort),
+		)
 	}
 	// Pull the TLS config from the relevant annotations.
-	if raw, ok := pod.Annotations[annotationPrometheusCAFile]; ok && raw != "" {
-		data.PrometheusCAFile = raw
-	}
-	if raw, ok := pod.Annotations[annotationPrometheusCAPath]; ok && raw != "" {
-		data.PrometheusCAPath = raw
-	}
-	if raw, ok := pod.Annotations[annotationPrometheusCertFile]; ok && raw != "" {
-		data.PrometheusCertFile = raw
-	}
-	if raw, ok := pod.Annotations[annotationPrometheusKeyFile]; ok && raw != "" {
-		data.PrometheusKeyFile = raw
-	}
+	prometheusCAFile := pod.Annotations[annotationPrometheusCAFile]
+	prometheusCAPath := pod.Annotations[annotationPrometheusCAPath]
+	prometheusCertFile := pod.Annotations[annotationPrometheusCertFile]
+	prometheusKeyFile := pod.Annotations[annotationPrometheusKeyFile]
 
 	// Validate required Prometheus TLS config is present if set.
-	if data.PrometheusCertFile != "" || data.PrometheusKeyFile != "" || data.PrometheusCAFile != "" || data.PrometheusCAPath != "" {
-		if data.PrometheusCAFile == "" && data.PrometheusCAPath == "" {
+	if prometheusCertFile != "" || prometheusKeyFile != "" || prometheusCAFile != "" || prometheusCAPath != "" {
+		if prometheusCAFile == "" && prometheusCAPath == "" {
 			return corev1.Container{}, fmt.Errorf("Must set one of %q or %q when providing prometheus TLS config", annotationPrometheusCAFile, annotationPrometheusCAPath)
 		}
-		if data.PrometheusCertFile == "" {
+		if prometheusCertFile == "" {
 			return corev1.Container{}, fmt.Errorf("Must set %q when providing prometheus TLS config", annotationPrometheusCertFile)
 		}
-		if data.PrometheusKeyFile == "" {
+		if prometheusKeyFile == "" {
 			return corev1.Container{}, fmt.Errorf("Must set %q when providing prometheus TLS config", annotationPrometheusKeyFile)
 		}
 	}
+	if prometheusCAFile != "" {
+		args = append(args, fmt.Sprintf("-prometheus-ca-file=%s", prometheusCAFile))
+	}
+	if prometheusCAPath != "" {
+		args = append(args, fmt.Sprintf("-prometheus-ca-path=%s", prometheusCAPath))
+	}
+	if prometheusCertFile != "" {
+		args = append(args, fmt.Sprintf("-prometheus-cert-file=%s", prometheusCertFile))
+	}
+	if prometheusKeyFile != "" {
+		args = append(args, fmt.Sprintf("-prometheus-key-file=%s", prometheusKeyFile))
+	}
+
+	// The traffic of a multi-port pod is redirected to the proxy of the service
+	// it selects with the transparent-proxy-outbound-service annotation. The
+	// other proxies are only reached on their public listeners, so those ports
+	// are excluded from the redirection.
+	redirectTraffic := tproxyEnabled && (!multiPort || mpi.serviceName == pod.Annotations[annotationTProxyOutboundService])
+
+	// Apply traffic redirection rules.
+	if redirectTraffic {
+		args = append(args, "-redirect-traffic=true")
+		if multiPort {
+			for i, svc := range w.annotatedServiceNames(pod) {
+				if svc != mpi.serviceName {
+					args = append(args, fmt.Sprintf("-exclude-inbound-port=%d", envoyInboundPort+i))
+				}
+			}
+		}
+		if consulDNSClusterIP != "" {
+			args = append(args, fmt.Sprintf("-consul-dns-ip=%s", consulDNSClusterIP))
+		}
+		for _, port := range splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeInboundPorts, pod) {
+			args = append(args, fmt.Sprintf("-exclude-inbound-port=%s", port))
+		}
+		for _, port := range splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeOutboundPorts, pod) {
+			args = append(args, fmt.Sprintf("-exclude-outbound-port=%s", port))
+		}
+		for _, cidr := range splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeOutboundCIDRs, pod) {
+			args = append(args, fmt.Sprintf("-exclude-outbound-cidr=%s", cidr))
+		}
+		for _, uid := range splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeUIDs, pod) {
+			args = append(args, fmt.Sprintf("-exclude-uid=%s", uid))
+		}
+		args = append(args, fmt.Sprintf("-proxy-uid=%d", envoyUserAndGroupID))
+	}
 
-	// Render the command
-	var buf bytes.Buffer
-	tpl := template.Must(template.New("root").Parse(strings.TrimSpace(
-		initContainerCommandTpl)))
-	err = tpl.Execute(&buf, &data)
+	// Copy the binary to the shared volume for the lifecycle hooks of the
+	// Envoy sidecar.
+	lifecycle, err := w.sidecarLifecycle(pod)
 	if err != nil {
 		return corev1.Container{}, err
 	}
+	if lifecycle.needsBinary() {
+		args = append(args, fmt.Sprintf("-lifecycle-binary=%s", lifecycleBinary))
+	}
 
 	initContainerName := InjectInitContainerName
 	if multiPort {
@@ -290,11 +270,34 @@ func (w *MeshWebhook) containerInit(namespace corev1.Namespace, pod corev1.Pod,
 		},
 		Resources:    w.InitContainerResources,
 		VolumeMounts: volMounts,
-		Command:      []string{"/bin/sh", "-ec", buf.String()},
+		Command:      append([]string{"consul-k8s-control-plane"}, args...),
+	}
+
+	if w.EnableAgentlessRegistration {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name: "NODE_NAME",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+			},
+		})
 	}
 
-	if tproxyEnabled {
-		// Running consul connect redirect-traffic with iptables
+	// The addresses reference the env vars above, which Kubernetes expands.
+	if w.ConsulCACert != "" {
+		container.Env = append(container.Env,
+			corev1.EnvVar{Name: "CONSUL_HTTP_ADDR", Value: fmt.Sprintf("https://%s:8501", consulHost)},
+			corev1.EnvVar{Name: "CONSUL_GRPC_ADDR", Value: fmt.Sprintf("https://%s:%d", consulHost, consulGRPCPort)},
+			corev1.EnvVar{Name: "CONSUL_CACERT_PEM", Value: w.ConsulCACert},
+		)
+	} else {
+		container.Env = append(container.Env,
+			corev1.EnvVar{Name: "CONSUL_HTTP_ADDR", Value: fmt.Sprintf("%s:8500", consulHost)},
+			corev1.EnvVar{Name: "CONSUL_GRPC_ADDR", Value: fmt.Sprintf("%s:%d", consulHost, consulGRPCPort)},
+		)
+	}
+
+	if redirectTraffic {
+		// Applying the traffic redirection rules with iptables
 		// requires both being a root user and having NET_ADMIN capability.
 		container.SecurityContext = &corev1.SecurityContext{
 			RunAsUser:  pointerToInt64(rootUserAndGroupID),
@@ -377,130 +380,3 @@ func splitCommaSeparatedItemsFromAnnotation(annotation string, pod corev1.Pod) [
 
 	return items
 }
-
-// initContainerCommandTpl is the template for the command executed by
-// the init container.
-const initContainerCommandTpl = `
-{{- if .ConsulCACert}}
-export CONSUL_HTTP_ADDR="https://${HOST_IP}:8501"
-export CONSUL_GRPC_ADDR="https://${HOST_IP}:8502"
-export CONSUL_CACERT=/consul/connect-inject/consul-ca.pem
-cat <<EOF >/consul/connect-inject/consul-ca.pem
-{{ .ConsulCACert }}
-EOF
-{{- else}}
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-{{- end}}
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout={{ .ConsulAPITimeout }} \
-  {{- if .AuthMethod }}
-  -acl-auth-method="{{ .AuthMethod }}" \
-  -service-account-name="{{ .ServiceAccountName }}" \
-  -service-name="{{ .ServiceName }}" \
-  -bearer-token-file={{ .BearerTokenFile }} \
-  {{- if .MultiPort }}
-  -acl-token-sink=/consul/connect-inject/acl-token-{{ .ServiceName }} \
-  {{- end }}
-  {{- if .ConsulNamespace }}
-  {{- if .NamespaceMirroringEnabled }}
-  {{- /* If namespace mirroring is enabled, the auth method is
-         defined in the default namespace */}}
-  -auth-method-namespace="default" \
-  {{- else }}
-  -auth-method-namespace="{{ .ConsulNamespace }}" \
-  {{- end }}
-  {{- end }}
-  {{- end }}
-  {{- if .MultiPort }}
-  -multiport=true \
-  -proxy-id-file=/consul/connect-inject/proxyid-{{ .ServiceName }} \
-  {{- if not .AuthMethod }}
-  -service-name="{{ .ServiceName }}" \
-  {{- end }}
-  {{- end }}
-  {{- if .ConsulPartition }}
-  -partition="{{ .ConsulPartition }}" \
-  {{- end }}
-  {{- if .ConsulNamespace }}
-  -consul-service-namespace="{{ .ConsulNamespace }}" \
-  {{- end }}
-
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  {{- if .MultiPort }}
-  -proxy-id="$(cat /consul/connect-inject/proxyid-{{.ServiceName}})" \
-  {{- else }}
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  {{- end }}
-  {{- if .PrometheusScrapePath }}
-  -prometheus-scrape-path="{{ .PrometheusScrapePath }}" \
-  {{- end }}
-  {{- if .PrometheusBackendPort }}
-  -prometheus-backend-port="{{ .PrometheusBackendPort }}" \
-  {{- end }}
-  {{- if .PrometheusCAFile }}
-  -prometheus-ca-file="{{ .PrometheusCAFile }}" \
-  {{- end }}
-  {{- if .PrometheusCAPath }}
-  -prometheus-ca-path="{{ .PrometheusCAPath }}" \
-  {{- end }}
-  {{- if .PrometheusCertFile }}
-  -prometheus-cert-file="{{ .PrometheusCertFile }}" \
-  {{- end }}
-  {{- if .PrometheusKeyFile }}
-  -prometheus-key-file="{{ .PrometheusKeyFile }}" \
-  {{- end }}
-  {{- if .AuthMethod }}
-  {{- if .MultiPort }}
-  -token-file="/consul/connect-inject/acl-token-{{ .ServiceName }}" \
-  {{- else }}
-  -token-file="/consul/connect-inject/acl-token" \
-  {{- end }}
-  {{- end }}
-  {{- if .ConsulPartition }}
-  -partition="{{ .ConsulPartition }}" \
-  {{- end }}
-  {{- if .ConsulNamespace }}
-  -namespace="{{ .ConsulNamespace }}" \
-  {{- end }}
-  {{- if .MultiPort }}
-  -admin-bind=127.0.0.1:{{ .EnvoyAdminPort }} \
-  {{- end }}
-  -bootstrap > {{ if .MultiPort }}/consul/connect-inject/envoy-bootstrap-{{.ServiceName}}.yaml{{ else }}/consul/connect-inject/envoy-bootstrap.yaml{{ end }}
-
-
-{{- if .EnableTransparentProxy }}
-{{- /* The newline below is intentional to allow extra space
-       in the rendered template between this and the previous commands. */}}
-
-# Apply traffic redirection rules.
-/consul/connect-inject/consul connect redirect-traffic \
-  {{- if .AuthMethod }}
-  -token-file="/consul/connect-inject/acl-token" \
-  {{- end }}
-  {{- if .ConsulPartition }}
-  -partition="{{ .ConsulPartition }}" \
-  {{- end }}
-  {{- if .ConsulNamespace }}
-  -namespace="{{ .ConsulNamespace }}" \
-  {{- end }}
-  {{- if .ConsulDNSClusterIP }}
-  -consul-dns-ip="{{ .ConsulDNSClusterIP }}" \
-  {{- end }}
-  {{- range .TProxyExcludeInboundPorts }}
-  -exclude-inbound-port="{{ . }}" \
-  {{- end }}
-  {{- range .TProxyExcludeOutboundPorts }}
-  -exclude-outbound-port="{{ . }}" \
-  {{- end }}
-  {{- range .TProxyExcludeOutboundCIDRs }}
-  -exclude-outbound-cidr="{{ . }}" \
-  {{- end }}
-  {{- range .TProxyExcludeUIDs }}
-  -exclude-uid="{{ . }}" \
-  {{- end }}
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid={{ .EnvoyUID }}
-{{- end }}
-`
@@ -60,16 +60,7 @@ func TestHandlerContainerInit(t *testing.T) {
 				return pod
 			},
 			MeshWebhook{},
-			`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=0s \
-
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+			`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=0s -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap.yaml`,
 			"",
 			"",
 		},
@@ -91,27 +82,19 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 				AuthMethod:       "an-auth-method",
 				ConsulAPITimeout: 5 * time.Second,
 			},
-			`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -acl-auth-method="an-auth-method" \
-  -service-account-name="a-service-account-name" \
-  -service-name="web" \
-`,
+			`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -acl-auth-method=an-auth-method -service-account-name=a-service-account-name -service-name=web`,
 			"",
 			"",
 		},
 		{
-			"When running the merged metrics server, configures consul connect envoy command",
+			"When running the merged metrics server, configures the Envoy bootstrap",
 			func(pod *corev1.Pod) *corev1.Pod {
 				// The annotations to enable metrics, enable merging, and
 				// service metrics port make the condition to run the merged
 				// metrics server true. When that is the case,
 				// prometheusScrapePath and mergedMetricsPort should get
-				// rendered as -prometheus-scrape-path and
-				// -prometheus-backend-port to the consul connect envoy command.
+				// passed as -prometheus-scrape-path and
+				// -prometheus-backend-port to connect-init.
 				pod.Annotations[annotationService] = "web"
 				pod.Annotations[annotationEnableMetrics] = "true"
 				pod.Annotations[annotationEnableMetricsMerging] = "true"
@@ -128,16 +111,7 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 			MeshWebhook{
 				ConsulAPITimeout: 5 * time.Second,
 			},
-			`# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -prometheus-scrape-path="/scrape-path" \
-  -prometheus-backend-port="20100" \
-  -prometheus-ca-file="/certs/ca.crt" \
-  -prometheus-ca-path="/certs/ca/" \
-  -prometheus-cert-file="/certs/server.crt" \
-  -prometheus-key-file="/certs/key.pem" \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+			`-envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap.yaml -prometheus-scrape-path=/scrape-path -prometheus-backend-port=20100 -prometheus-ca-file=/certs/ca.crt -prometheus-ca-path=/certs/ca/ -prometheus-cert-file=/certs/server.crt -prometheus-key-file=/certs/key.pem`,
 			"",
 			"",
 		},
@@ -235,9 +209,7 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
 		"enabled globally, ns not set, annotation not provided": {
 			true,
 			nil,
-			`/consul/connect-inject/consul connect redirect-traffic \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`-redirect-traffic=true -proxy-uid=5995`,
 			"",
 			nil,
 		},
@@ -245,17 +217,13 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
 			true,
 			map[string]string{keyTransparentProxy: "false"},
 			"",
-			`/consul/connect-inject/consul connect redirect-traffic \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`-redirect-traffic=true -proxy-uid=5995`,
 			nil,
 		},
 		"enabled globally, ns not set, annotation is true": {
 			true,
 			map[string]string{keyTransparentProxy: "true"},
-			`/consul/connect-inject/consul connect redirect-traffic \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`-redirect-traffic=true -proxy-uid=5995`,
 			"",
 			nil,
 		},
@@ -263,26 +231,20 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
 			false,
 			nil,
 			"",
-			`/consul/connect-inject/consul connect redirect-traffic \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`-redirect-traffic=true -proxy-uid=5995`,
 			nil,
 		},
 		"disabled globally, ns not set, annotation is false": {
 			false,
 			map[string]string{keyTransparentProxy: "false"},
 			"",
-			`/consul/connect-inject/consul connect redirect-traffic \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`-redirect-traffic=true -proxy-uid=5995`,
 			nil,
 		},
 		"disabled globally, ns not set, annotation is true": {
 			false,
 			map[string]string{keyTransparentProxy: "true"},
-			`/consul/connect-inject/consul connect redirect-traffic \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`-redirect-traffic=true -proxy-uid=5995`,
 			"",
 			nil,
 		},
@@ -292,11 +254,7 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
 				keyTransparentProxy:                 "true",
 				annotationTProxyExcludeInboundPorts: "9090,9091",
 			},
-			`/consul/connect-inject/consul connect redirect-traffic \
-  -exclude-inbound-port="9090" \
-  -exclude-inbound-port="9091" \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`-redirect-traffic=true -exclude-inbound-port=9090 -exclude-inbound-port=9091 -proxy-uid=5995`,
 			"",
 			nil,
 		},
@@ -306,11 +264,7 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
 				keyTransparentProxy:                  "true",
 				annotationTProxyExcludeOutboundPorts: "9090,9091",
 			},
-			`/consul/connect-inject/consul connect redirect-traffic \
-  -exclude-outbound-port="9090" \
-  -exclude-outbound-port="9091" \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`-redirect-traffic=true -exclude-outbound-port=9090 -exclude-outbound-port=9091 -proxy-uid=5995`,
 			"",
 			nil,
 		},
@@ -320,11 +274,7 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
 				keyTransparentProxy:                  "true",
 				annotationTProxyExcludeOutboundCIDRs: "1.1.1.1,2.2.2.2/24",
 			},
-			`/consul/connect-inject/consul connect redirect-traffic \
-  -exclude-outbound-cidr="1.1.1.1" \
-  -exclude-outbound-cidr="2.2.2.2/24" \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`-redirect-traffic=true -exclude-outbound-cidr=1.1.1.1 -exclude-outbound-cidr=2.2.2.2/24 -proxy-uid=5995`,
 			"",
 			nil,
 		},
@@ -334,20 +284,14 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
 				keyTransparentProxy:         "true",
 				annotationTProxyExcludeUIDs: "6000,7000",
 			},
-			`/consul/connect-inject/consul connect redirect-traffic \
-  -exclude-uid="6000" \
-  -exclude-uid="7000" \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`-redirect-traffic=true -exclude-uid=6000 -exclude-uid=7000 -proxy-uid=5995`,
 			"",
 			nil,
 		},
 		"disabled globally, ns enabled, annotation not set": {
 			false,
 			nil,
-			`/consul/connect-inject/consul connect redirect-traffic \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`-redirect-traffic=true -proxy-uid=5995`,
 			"",
 			map[string]string{keyTransparentProxy: "true"},
 		},
@@ -355,9 +299,7 @@ func TestHandlerContainerInit_transparentProxy(t *testing.T) {
 			true,
 			nil,
 			"",
-			`/consul/connect-inject/consul connect redirect-traffic \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`-redirect-traffic=true -proxy-uid=5995`,
 			map[string]string{keyTransparentProxy: "false"},
 		},
 	}
@@ -404,58 +346,38 @@ func TestHandlerContainerInit_consulDNS(t *testing.T) {
 		namespaceLabel      map[string]string
 	}{
 		"enabled globally, ns not set, annotation not provided": {
-			globalEnabled: true,
-			expectedContainsCmd: `/consul/connect-inject/consul connect redirect-traffic \
-  -consul-dns-ip="10.0.34.16" \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			globalEnabled:       true,
+			expectedContainsCmd: `-redirect-traffic=true -consul-dns-ip=10.0.34.16 -proxy-uid=5995`,
 		},
 		"enabled globally, ns not set, annotation is false": {
-			globalEnabled: true,
-			annotations:   map[string]string{keyConsulDNS: "false"},
-			expectedContainsCmd: `/consul/connect-inject/consul connect redirect-traffic \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			globalEnabled:       true,
+			annotations:         map[string]string{keyConsulDNS: "false"},
+			expectedContainsCmd: `-redirect-traffic=true -proxy-uid=5995`,
 		},
 		"enabled globally, ns not set, annotation is true": {
-			globalEnabled: true,
-			annotations:   map[string]string{keyConsulDNS: "true"},
-			expectedContainsCmd: `/consul/connect-inject/consul connect redirect-traffic \
-  -consul-dns-ip="10.0.34.16" \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			globalEnabled:       true,
+			annotations:         map[string]string{keyConsulDNS: "true"},
+			expectedContainsCmd: `-redirect-traffic=true -consul-dns-ip=10.0.34.16 -proxy-uid=5995`,
 		},
 		"disabled globally, ns not set, annotation not provided": {
-			expectedContainsCmd: `/consul/connect-inject/consul connect redirect-traffic \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			expectedContainsCmd: `-redirect-traffic=true -proxy-uid=5995`,
 		},
 		"disabled globally, ns not set, annotation is false": {
-			annotations: map[string]string{keyConsulDNS: "false"},
-			expectedContainsCmd: `/consul/connect-inject/consul connect redirect-traffic \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			annotations:         map[string]string{keyConsulDNS: "false"},
+			expectedContainsCmd: `-redirect-traffic=true -proxy-uid=5995`,
 		},
 		"disabled globally, ns not set, annotation is true": {
-			annotations: map[string]string{keyConsulDNS: "true"},
-			expectedContainsCmd: `/consul/connect-inject/consul connect redirect-traffic \
-  -consul-dns-ip="10.0.34.16" \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			annotations:         map[string]string{keyConsulDNS: "true"},
+			expectedContainsCmd: `-redirect-traffic=true -consul-dns-ip=10.0.34.16 -proxy-uid=5995`,
 		},
 		"disabled globally, ns enabled, annotation not set": {
-			expectedContainsCmd: `/consul/connect-inject/consul connect redirect-traffic \
-  -consul-dns-ip="10.0.34.16" \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
-			namespaceLabel: map[string]string{keyConsulDNS: "true"},
+			expectedContainsCmd: `-redirect-traffic=true -consul-dns-ip=10.0.34.16 -proxy-uid=5995`,
+			namespaceLabel:      map[string]string{keyConsulDNS: "true"},
 		},
 		"enabled globally, ns disabled, annotation not set": {
-			globalEnabled: true,
-			expectedContainsCmd: `/consul/connect-inject/consul connect redirect-traffic \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
-			namespaceLabel: map[string]string{keyConsulDNS: "false"},
+			globalEnabled:       true,
+			expectedContainsCmd: `-redirect-traffic=true -proxy-uid=5995`,
+			namespaceLabel:      map[string]string{keyConsulDNS: "false"},
 		},
 	}
 	for name, c := range cases {
@@ -560,18 +482,7 @@ func TestHandlerContainerInit_namespacesAndPartitionsEnabled(t *testing.T) {
 				ConsulPartition:            "",
 				ConsulAPITimeout:           5 * time.Second,
 			},
-			`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -consul-service-namespace="default" \
-
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -namespace="default" \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+			`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -consul-service-namespace=default -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap.yaml`,
 		},
 		{
 			"whole template, default namespace, default partition",
@@ -585,20 +496,7 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 				ConsulPartition:            "default",
 				ConsulAPITimeout:           5 * time.Second,
 			},
-			`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -partition="default" \
-  -consul-service-namespace="default" \
-
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -partition="default" \
-  -namespace="default" \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+			`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -partition=default -consul-service-namespace=default -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap.yaml`,
 		},
 		{
 			"whole template, non-default namespace, no partition",
@@ -612,18 +510,7 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 				ConsulPartition:            "",
 				ConsulAPITimeout:           5 * time.Second,
 			},
-			`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -consul-service-namespace="non-default" \
-
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -namespace="non-default" \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+			`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -consul-service-namespace=non-default -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap.yaml`,
 		},
 		{
 			"whole template, non-default namespace, non-default partition",
@@ -637,20 +524,7 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 				ConsulPartition:            "non-default-part",
 				ConsulAPITimeout:           5 * time.Second,
 			},
-			`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -partition="non-default-part" \
-  -consul-service-namespace="non-default" \
-
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -partition="non-default-part" \
-  -namespace="non-default" \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+			`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -partition=non-default-part -consul-service-namespace=non-default -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap.yaml`,
 		},
 		{
 			"Whole template, auth method, non-default namespace, mirroring disabled, default partition",
@@ -665,26 +539,7 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 				ConsulPartition:            "default",
 				ConsulAPITimeout:           5 * time.Second,
 			},
-			`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -acl-auth-method="auth-method" \
-  -service-account-name="web" \
-  -service-name="" \
-  -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token \
-  -auth-method-namespace="non-default" \
-  -partition="default" \
-  -consul-service-namespace="non-default" \
-
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -token-file="/consul/connect-inject/acl-token" \
-  -partition="default" \
-  -namespace="non-default" \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+			`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -acl-auth-method=auth-method -service-account-name=web -service-name= -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token -auth-method-namespace=non-default -partition=default -consul-service-namespace=non-default -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap.yaml`,
 		},
 		{
 			"Whole template, auth method, non-default namespace, mirroring enabled, non-default partition",
@@ -700,26 +555,7 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 				ConsulPartition:            "non-default",
 				ConsulAPITimeout:           5 * time.Second,
 			},
-			`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -acl-auth-method="auth-method" \
-  -service-account-name="web" \
-  -service-name="" \
-  -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token \
-  -auth-method-namespace="default" \
-  -partition="non-default" \
-  -consul-service-namespace="k8snamespace" \
-
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -token-file="/consul/connect-inject/acl-token" \
-  -partition="non-default" \
-  -namespace="k8snamespace" \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`,
+			`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -acl-auth-method=auth-method -service-account-name=web -service-name= -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token -auth-method-namespace=default -partition=non-default -consul-service-namespace=k8snamespace -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap.yaml`,
 		},
 		{
 			"whole template, default namespace, tproxy enabled, no partition",
@@ -734,24 +570,7 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 				EnableTransparentProxy:     true,
 				ConsulAPITimeout:           5 * time.Second,
 			},
-			`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -consul-service-namespace="default" \
-
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -namespace="default" \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml
-
-# Apply traffic redirection rules.
-/consul/connect-inject/consul connect redirect-traffic \
-  -namespace="default" \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -consul-service-namespace=default -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap.yaml -redirect-traffic=true -proxy-uid=5995`,
 		},
 		{
 			"whole template, non-default namespace, tproxy enabled, default partition",
@@ -766,27 +585,7 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 				EnableTransparentProxy:     true,
 				ConsulAPITimeout:           5 * time.Second,
 			},
-			`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -partition="default" \
-  -consul-service-namespace="non-default" \
-
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -partition="default" \
-  -namespace="non-default" \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml
-
-# Apply traffic redirection rules.
-/consul/connect-inject/consul connect redirect-traffic \
-  -partition="default" \
-  -namespace="non-default" \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -partition=default -consul-service-namespace=non-default -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap.yaml -redirect-traffic=true -proxy-uid=5995`,
 		},
 
 		{
@@ -804,34 +603,7 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 				EnableTransparentProxy:     true,
 				ConsulAPITimeout:           5 * time.Second,
 			},
-			`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -acl-auth-method="auth-method" \
-  -service-account-name="web" \
-  -service-name="web" \
-  -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token \
-  -auth-method-namespace="default" \
-  -partition="non-default" \
-  -consul-service-namespace="k8snamespace" \
-
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -token-file="/consul/connect-inject/acl-token" \
-  -partition="non-default" \
-  -namespace="k8snamespace" \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml
-
-# Apply traffic redirection rules.
-/consul/connect-inject/consul connect redirect-traffic \
-  -token-file="/consul/connect-inject/acl-token" \
-  -partition="non-default" \
-  -namespace="k8snamespace" \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -proxy-uid=5995`,
+			`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -acl-auth-method=auth-method -service-account-name=web -service-name=web -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token -auth-method-namespace=default -partition=non-default -consul-service-namespace=k8snamespace -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap.yaml -redirect-traffic=true -proxy-uid=5995`,
 		},
 	}
 
@@ -916,35 +688,9 @@ func TestHandlerContainerInit_Multiport(t *testing.T) {
 					serviceName:  "web-admin",
 				},
 			},
-			[]string{`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -multiport=true \
-  -proxy-id-file=/consul/connect-inject/proxyid-web \
-  -service-name="web" \
-
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid-web)" \
-  -admin-bind=127.0.0.1:19000 \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap-web.yaml`,
+			[]string{`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -multiport=true -proxy-id-file=/consul/connect-inject/proxyid-web -service-name=web -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap-web.yaml -envoy-admin-bind=127.0.0.1:19000`,
 
-				`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -multiport=true \
-  -proxy-id-file=/consul/connect-inject/proxyid-web-admin \
-  -service-name="web-admin" \
-
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid-web-admin)" \
-  -admin-bind=127.0.0.1:19001 \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap-web-admin.yaml`,
+				`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -multiport=true -proxy-id-file=/consul/connect-inject/proxyid-web-admin -service-name=web-admin -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap-web-admin.yaml -envoy-admin-bind=127.0.0.1:19001`,
 			},
 		},
 		{
@@ -967,45 +713,61 @@ consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD
 					serviceName:  "web-admin",
 				},
 			},
-			[]string{`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -acl-auth-method="auth-method" \
-  -service-account-name="web" \
-  -service-name="web" \
-  -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token \
-  -acl-token-sink=/consul/connect-inject/acl-token-web \
-  -multiport=true \
-  -proxy-id-file=/consul/connect-inject/proxyid-web \
+			[]string{`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -acl-auth-method=auth-method -service-account-name=web -service-name=web -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token -acl-token-sink=/consul/connect-inject/acl-token-web -multiport=true -proxy-id-file=/consul/connect-inject/proxyid-web -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap-web.yaml -envoy-admin-bind=127.0.0.1:19000`,
 
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid-web)" \
-  -token-file="/consul/connect-inject/acl-token-web" \
-  -admin-bind=127.0.0.1:19000 \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap-web.yaml`,
+				`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -acl-auth-method=auth-method -service-account-name=web-admin -service-name=web-admin -bearer-token-file=/consul/serviceaccount-web-admin/token -acl-token-sink=/consul/connect-inject/acl-token-web-admin -multiport=true -proxy-id-file=/consul/connect-inject/proxyid-web-admin -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap-web-admin.yaml -envoy-admin-bind=127.0.0.1:19001`,
+			},
+		},
+		{
+			"Whole template, multiport, auth method, shared service account",
+			func(pod *corev1.Pod) *corev1.Pod {
+				return pod
+			},
+			MeshWebhook{
+				AuthMethod:                          "auth-method",
+				EnableMultiPortSharedServiceAccount: true,
+				ConsulAPITimeout:                    5 * time.Second,
+			},
+			2,
+			[]multiPortInfo{
+				{
+					serviceIndex: 0,
+					serviceName:  "web",
+				},
+				{
+					serviceIndex: 1,
+					serviceName:  "web-admin",
+				},
+			},
+			[]string{`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -acl-auth-method=auth-method -service-account-name=web -service-name=web -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token -acl-token-sink=/consul/connect-inject/acl-token-web -shared-service-account=true -multiport=true -proxy-id-file=/consul/connect-inject/proxyid-web -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap-web.yaml -envoy-admin-bind=127.0.0.1:19000`,
 
-				`/bin/sh -ec 
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -acl-auth-method="auth-method" \
-  -service-account-name="web-admin" \
-  -service-name="web-admin" \
-  -bearer-token-file=/consul/serviceaccount-web-admin/token \
-  -acl-token-sink=/consul/connect-inject/acl-token-web-admin \
-  -multiport=true \
-  -proxy-id-file=/consul/connect-inject/proxyid-web-admin \
+				`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -acl-auth-method=auth-method -service-account-name=web -service-name=web-admin -bearer-token-file=/var/run/secrets/kubernetes.io/serviceaccount/token -acl-token-sink=/consul/connect-inject/acl-token-web-admin -shared-service-account=true -multiport=true -proxy-id-file=/consul/connect-inject/proxyid-web-admin -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap-web-admin.yaml -envoy-admin-bind=127.0.0.1:19001`,
+			},
+		},
+		{
+			"Whole template, multiport, transparent proxy",
+			func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[annotationTProxyOutboundService] = "web-admin"
+				return pod
+			},
+			MeshWebhook{
+				EnableTransparentProxy: true,
+				ConsulAPITimeout:       5 * time.Second,
+			},
+			2,
+			[]multiPortInfo{
+				{
+					serviceIndex: 0,
+					serviceName:  "web",
+				},
+				{
+					serviceIndex: 1,
+					serviceName:  "web-admin",
+				},
+			},
+			[]string{`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -multiport=true -proxy-id-file=/consul/connect-inject/proxyid-web -service-name=web -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap-web.yaml -envoy-admin-bind=127.0.0.1:19000`,
 
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid-web-admin)" \
-  -token-file="/consul/connect-inject/acl-token-web-admin" \
-  -admin-bind=127.0.0.1:19001 \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap-web-admin.yaml`,
+				`consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -multiport=true -proxy-id-file=/consul/connect-inject/proxyid-web-admin -service-name=web-admin -envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap-web-admin.yaml -envoy-admin-bind=127.0.0.1:19001 -redirect-traffic=true -exclude-inbound-port=20000 -proxy-uid=5995`,
 			},
 		},
 	}
@@ -1057,16 +819,8 @@ func TestHandlerContainerInit_authMethod(t *testing.T) {
 	container, err := w.containerInit(testNS, *pod, multiPortInfo{})
 	require.NoError(err)
 	actual := strings.Join(container.Command, " ")
-	require.Contains(actual, `
-consul-k8s-control-plane connect-init -pod-name=${POD_NAME} -pod-namespace=${POD_NAMESPACE} \
-  -consul-api-timeout=5s \
-  -acl-auth-method="release-name-consul-k8s-auth-method"`)
-	require.Contains(actual, `
-# Generate the envoy bootstrap code
-/consul/connect-inject/consul connect envoy \
-  -proxy-id="$(cat /consul/connect-inject/proxyid)" \
-  -token-file="/consul/connect-inject/acl-token" \
-  -bootstrap > /consul/connect-inject/envoy-bootstrap.yaml`)
+	require.Contains(actual, `consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -acl-auth-method=release-name-consul-k8s-auth-method`)
+	require.Contains(actual, `-envoy-bootstrap-file=/consul/connect-inject/envoy-bootstrap.yaml`)
 }
 
 // If Consul CA cert is set,
@@ -1095,17 +849,83 @@ func TestHandlerContainerInit_WithTLS(t *testing.T) {
 	}
 	container, err := w.containerInit(testNS, *pod, multiPortInfo{})
 	require.NoError(err)
+	require.Contains(container.Env, corev1.EnvVar{Name: "CONSUL_HTTP_ADDR", Value: "https://$(HOST_IP):8501"})
+	require.Contains(container.Env, corev1.EnvVar{Name: "CONSUL_GRPC_ADDR", Value: "https://$(HOST_IP):8502"})
+	require.Contains(container.Env, corev1.EnvVar{Name: "CONSUL_CACERT_PEM", Value: "consul-ca-cert"})
+	require.NotContains(container.Env, corev1.EnvVar{Name: "CONSUL_HTTP_ADDR", Value: "$(HOST_IP):8500"})
+}
+
+// Without TLS the Consul addresses should use HTTP and
+// reference the host IP of the pod, which Kubernetes expands.
+func TestHandlerContainerInit_consulAddresses(t *testing.T) {
+	require := require.New(t)
+	w := MeshWebhook{ConsulAPITimeout: 5 * time.Second}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotationService: "foo",
+			},
+		},
+
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "web",
+				},
+			},
+		},
+	}
+	container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+	require.NoError(err)
+	// HOST_IP must be defined before the variables that reference it.
+	require.Equal("HOST_IP", container.Env[0].Name)
+	require.Contains(container.Env, corev1.EnvVar{Name: "CONSUL_HTTP_ADDR", Value: "$(HOST_IP):8500"})
+	require.Contains(container.Env, corev1.EnvVar{Name: "CONSUL_GRPC_ADDR", Value: "$(HOST_IP):8502"})
+	for _, env := range container.Env {
+		require.NotEqual("CONSUL_CACERT_PEM", env.Name)
+	}
+	// The command runs without a shell.
+	require.Equal([]string{"consul-k8s-control-plane", "connect-init"}, container.Command[:2])
+}
+
+// If agentless registration is enabled, connect-init and Envoy
+// should talk to the Consul servers and look up the proxy on the
+// synthetic Consul node of the pod's Kubernetes node.
+func TestHandlerContainerInit_agentlessRegistration(t *testing.T) {
+	require := require.New(t)
+	w := MeshWebhook{
+		EnableAgentlessRegistration: true,
+		ConsulServerHost:            "consul-server.default.svc",
+		ConsulServerGRPCPort:        8503,
+		ConsulAPITimeout:            5 * time.Second,
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				annotationService: "foo",
+			},
+		},
+
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name: "web",
+				},
+			},
+		},
+	}
+	container, err := w.containerInit(testNS, *pod, multiPortInfo{})
+	require.NoError(err)
 	actual := strings.Join(container.Command, " ")
-	require.Contains(actual, `
-export CONSUL_HTTP_ADDR="https://${HOST_IP}:8501"
-export CONSUL_GRPC_ADDR="https://${HOST_IP}:8502"
-export CONSUL_CACERT=/consul/connect-inject/consul-ca.pem
-cat <<EOF >/consul/connect-inject/consul-ca.pem
-consul-ca-cert
-EOF`)
-	require.NotContains(actual, `
-export CONSUL_HTTP_ADDR="${HOST_IP}:8500"
-export CONSUL_GRPC_ADDR="${HOST_IP}:8502"`)
+	require.Contains(container.Env, corev1.EnvVar{Name: "CONSUL_HTTP_ADDR", Value: "consul-server.default.svc:8500"})
+	require.Contains(container.Env, corev1.EnvVar{Name: "CONSUL_GRPC_ADDR", Value: "consul-server.default.svc:8503"})
+	require.Contains(actual, `consul-k8s-control-plane connect-init -pod-name=$(POD_NAME) -pod-namespace=$(POD_NAMESPACE) -consul-api-timeout=5s -consul-node-name=$(NODE_NAME)-virtual`)
+	require.Contains(container.Env, corev1.EnvVar{
+		Name: "NODE_NAME",
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"},
+		},
+	})
 }
 
 func TestHandlerContainerInit_Resources(t *testing.T) {
@@ -1152,34 +972,6 @@ func TestHandlerContainerInit_Resources(t *testing.T) {
 	}, container.Resources)
 }
 
-// Test that the init copy container has the correct command and SecurityContext.
-func TestHandlerInitCopyContainer(t *testing.T) {
-	openShiftEnabledCases := []bool{false, true}
-
-	for _, openShiftEnabled := range openShiftEnabledCases {
-		t.Run(fmt.Sprintf("openshift enabled: %t", openShiftEnabled), func(t *testing.T) {
-			w := MeshWebhook{EnableOpenShift: openShiftEnabled, ConsulAPITimeout: 5 * time.Second}
-
-			container := w.initCopyContainer()
-
-			if openShiftEnabled {
-				require.Nil(t, container.SecurityContext)
-			} else {
-				expectedSecurityContext := &corev1.SecurityContext{
-					RunAsUser:              pointerToInt64(copyContainerUserAndGroupID),
-					RunAsGroup:             pointerToInt64(copyContainerUserAndGroupID),
-					RunAsNonRoot:           pointerToBool(true),
-					ReadOnlyRootFilesystem: pointerToBool(true),
-				}
-				require.Equal(t, expectedSecurityContext, container.SecurityContext)
-			}
-
-			actual := strings.Join(container.Command, " ")
-			require.Contains(t, actual, `cp /bin/consul /consul/connect-inject/consul`)
-		})
-	}
-}
-
 var testNS = corev1.Namespace{
 	ObjectMeta: metav1.ObjectMeta{
 		Name: k8sNamespace,
@@ -12,17 +12,22 @@ import (
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
 	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/endpointslice"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/iptables"
 	"github.com/hashicorp/go-multierror"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -38,6 +43,7 @@ const (
 	MetaKeyKubeNS              = "k8s-namespace"
 	MetaKeyManagedBy           = "managed-by"
 	TokenMetaPodNameKey        = "pod"
+	TokenMetaServiceNameKey    = "service"
 	kubernetesSuccessReasonMsg = "Kubernetes health checks passing"
 	envoyPrometheusBindAddr    = "envoy_prometheus_bind_addr"
 	envoySidecarContainer      = "envoy-sidecar"
@@ -114,6 +120,34 @@ type EndpointsController struct {
 	// ConsulAPITimeout is the duration that the consul API client will
 	// wait for a response from the API before cancelling the request.
 	ConsulAPITimeout time.Duration
+	// EnableEndpointSlices controls whether the EndpointSlices of services
+	// are reconciled instead of the legacy Endpoints API, which truncates the
+	// addresses of services with more than 1000 endpoints.
+	EnableEndpointSlices bool
+	// EnableProxyConfigs controls whether the upstreams of the ProxyConfig
+	// resource that applies to a pod are registered if the pod doesn't
+	// have the upstreams annotation.
+	EnableProxyConfigs bool
+	// EnableRegistrationStatus controls whether the registered condition of
+	// pods is maintained and events are emitted on pods and services when
+	// their instances are registered and deregistered.
+	EnableRegistrationStatus bool
+	// Recorder emits the registration events.
+	Recorder record.EventRecorder
+	// EnableAgentlessRegistration controls whether services are registered
+	// directly with the catalog of the Consul servers that ConsulClientCfg
+	// points at, on a synthetic Consul node per Kubernetes node, instead of
+	// with the Consul client agent on the pod's node.
+	EnableAgentlessRegistration bool
+	// EnableMultiPortSharedServiceAccount controls whether the services of
+	// multi-port pods share the ServiceAccount of the pod. An ACL binding
+	// rule per service grants the ServiceAccount the service identity of
+	// each service the pod is registered as.
+	EnableMultiPortSharedServiceAccount bool
+	// APIReader reads the ServiceAccounts of multi-port pods that share them
+	// from the API so that the controller doesn't cache all ServiceAccounts.
+	// The Client is used if it is nil.
+	APIReader client.Reader
 
 	MetricsConfig MetricsConfig
 	Log           logr.Logger
@@ -133,7 +167,7 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, nil
 	}
 
-	err := r.Client.Get(ctx, req.NamespacedName, &serviceEndpoints)
+	err := r.getEndpoints(ctx, req.NamespacedName, &serviceEndpoints)
 
 	// endpointPods holds a set of all pods this endpoints object is currently pointing to.
 	// We use this later when we reconcile ACL tokens to decide whether an ACL token in Consul
@@ -146,6 +180,9 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 		// Deregister all instances in Consul for this service. The function deregisterServiceOnAllAgents handles
 		// the case where the Consul service name is different from the Kubernetes service name.
 		err = r.deregisterServiceOnAllAgents(ctx, req.Name, req.Namespace, nil)
+		if err == nil && r.EnableMultiPortSharedServiceAccount && r.AuthMethod != "" {
+			err = r.deleteMultiPortBindingRules(req.Name, req.Namespace, nil)
+		}
 		return ctrl.Result{}, err
 	} else if err != nil {
 		r.Log.Error(err, "failed to get Endpoints", "name", req.Name, "ns", req.Namespace)
@@ -167,6 +204,14 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 	// against service instances in Consul to deregister them if they are not in the map.
 	endpointAddressMap := map[string]bool{}
 
+	// consulServiceNames stores the names of the Consul services of the pods in the Endpoints object. Instances of
+	// the Kubernetes service are registered under the name of the service unless the pods override it.
+	consulServiceNames := mapset.NewSetWith(serviceEndpoints.Name)
+
+	// bindingRuleServiceAccounts stores the service accounts of the multi-port pods sharing them that the binding
+	// rules of the service are needed for. The binding rules of other service accounts are deleted.
+	bindingRuleServiceAccounts := mapset.NewSet()
+
 	// Register all addresses of this Endpoints object as service instances in Consul.
 	for _, subset := range serviceEndpoints.Subsets {
 		for address, healthStatus := range mapAddresses(subset) {
@@ -188,10 +233,13 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 
 				if hasBeenInjected(pod) {
 					endpointPods.Add(address.TargetRef.Name)
-					if err := r.registerServicesAndHealthCheck(pod, serviceEndpoints, healthStatus, endpointAddressMap); err != nil {
+					consulServiceNames.Add(getServiceName(pod, serviceEndpoints))
+					err := r.registerServicesAndHealthCheck(pod, serviceEndpoints, healthStatus, endpointAddressMap, bindingRuleServiceAccounts)
+					if err != nil {
 						r.Log.Error(err, "failed to register services or health check", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
 						errs = multierror.Append(errs, err)
 					}
+					r.recordRegistration(ctx, pod, serviceEndpoints, err)
 				}
 			}
 		}
@@ -199,12 +247,27 @@ func (r *EndpointsController) Reconcile(ctx context.Context, req ctrl.Request) (
 
 	// Compare service instances in Consul with addresses in Endpoints. If an address is not in Endpoints, deregister
 	// from Consul. This uses endpointAddressMap which is populated with the addresses in the Endpoints object during
-	// the registration codepath.
-	if err = r.deregisterServiceOnAllAgents(ctx, serviceEndpoints.Name, serviceEndpoints.Namespace, endpointAddressMap); err != nil {
+	// the registration codepath. Without agents, the instances are looked up by the names of the Consul services
+	// of the pods.
+	if r.EnableAgentlessRegistration {
+		err = r.deregisterServiceFromCatalog(ctx, serviceEndpoints.Name, serviceEndpoints.Namespace, endpointAddressMap, consulServiceNames)
+	} else {
+		err = r.deregisterServiceOnAllAgents(ctx, serviceEndpoints.Name, serviceEndpoints.Namespace, endpointAddressMap)
+	}
+	if err != nil {
 		r.Log.Error(err, "failed to deregister endpoints on all agents", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
 		errs = multierror.Append(errs, err)
 	}
 
+	// Only delete the binding rules of service accounts no pod uses anymore once all pods have been registered, so
+	// that a pod whose registration failed doesn't lose its binding rule.
+	if errs == nil && r.EnableMultiPortSharedServiceAccount && r.AuthMethod != "" {
+		if err := r.deleteMultiPortBindingRules(serviceEndpoints.Name, serviceEndpoints.Namespace, bindingRuleServiceAccounts); err != nil {
+			r.Log.Error(err, "failed to delete binding rules for multi-port service", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+			errs = multierror.Append(errs, err)
+		}
+	}
+
 	return ctrl.Result{}, errs
 }
 
@@ -213,20 +276,105 @@ func (r *EndpointsController) Logger(name types.NamespacedName) logr.Logger {
 }
 
 func (r *EndpointsController) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&corev1.Endpoints{}).
-		Watches(
+	b := ctrl.NewControllerManagedBy(mgr)
+	if r.EnableEndpointSlices {
+		// Requests are for the service that the EndpointSlices belong to so
+		// that all of its slices are reconciled together.
+		b = b.For(&corev1.Service{}).
+			Watches(
+				&source.Kind{Type: &discoveryv1.EndpointSlice{}},
+				handler.EnqueueRequestsFromMapFunc(requestForEndpointSlice),
+			)
+	} else {
+		b = b.For(&corev1.Endpoints{})
+	}
+
+	// Without agents, there is no need to re-register services when agents
+	// restart.
+	if !r.EnableAgentlessRegistration {
+		b = b.Watches(
 			&source.Kind{Type: &corev1.Pod{}},
 			handler.EnqueueRequestsFromMapFunc(r.requestsForRunningAgentPods),
 			builder.WithPredicates(predicate.NewPredicateFuncs(r.filterAgentPods)),
-		).Complete(r)
+		)
+	}
+
+	if r.EnableProxyConfigs {
+		b = b.Watches(
+			&source.Kind{Type: &v1alpha1.ProxyConfig{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForProxyConfig),
+		)
+	}
+
+	return b.Complete(r)
+}
+
+// getEndpoints gets the Endpoints of the service with the given name. When
+// EndpointSlices are enabled, the slices of the service are merged into an
+// Endpoints object and a NotFound error is returned if the service has none.
+func (r *EndpointsController) getEndpoints(ctx context.Context, name types.NamespacedName, serviceEndpoints *corev1.Endpoints) error {
+	if !r.EnableEndpointSlices {
+		return r.Client.Get(ctx, name, serviceEndpoints)
+	}
+
+	var slices discoveryv1.EndpointSliceList
+	err := r.Client.List(ctx, &slices,
+		client.InNamespace(name.Namespace),
+		client.MatchingLabels{discoveryv1.LabelServiceName: name.Name})
+	if err != nil {
+		return err
+	}
+	if len(slices.Items) == 0 {
+		return k8serrors.NewNotFound(discoveryv1.Resource("endpointslices"), name.Name)
+	}
+
+	*serviceEndpoints = *endpointslice.ToEndpoints(name.Name, name.Namespace, slices.Items)
+	return nil
+}
+
+// requestForEndpointSlice maps an EndpointSlice to a request for the service
+// it belongs to.
+func requestForEndpointSlice(object client.Object) []ctrl.Request {
+	slice, ok := object.(*discoveryv1.EndpointSlice)
+	if !ok {
+		return nil
+	}
+	serviceName := endpointslice.ServiceName(slice)
+	if serviceName == "" {
+		return nil
+	}
+	return []ctrl.Request{{NamespacedName: types.NamespacedName{Name: serviceName, Namespace: slice.Namespace}}}
 }
 
 // registerServicesAndHealthCheck creates Consul registrations for the service and proxy and registers them with Consul.
 // It also upserts a Kubernetes health check for the service based on whether the endpoint address is ready.
-func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, serviceEndpoints corev1.Endpoints, healthStatus string, endpointAddressMap map[string]bool) error {
+func (r *EndpointsController) registerServicesAndHealthCheck(pod corev1.Pod, serviceEndpoints corev1.Endpoints, healthStatus string, endpointAddressMap map[string]bool, bindingRuleServiceAccounts mapset.Set) error {
 	podHostIP := pod.Status.HostIP
 
+	// The binding rule must exist before the service is registered since
+	// connect-init logs in again once the service is registered if its token
+	// doesn't have the service identity yet.
+	if r.usesSharedServiceAccount(pod) && pod.Labels[keyManagedBy] == managedByValue {
+		serviceAccountName, err := r.ensureMultiPortBindingRule(r.Context, pod, serviceEndpoints)
+		if err != nil {
+			r.Log.Error(err, "failed to create binding rule for multi-port service", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+			return err
+		}
+		if serviceAccountName != "" {
+			bindingRuleServiceAccounts.Add(serviceAccountName)
+		}
+	}
+
+	if r.EnableAgentlessRegistration {
+		// Only pods managed by this controller can be registered without
+		// an agent.
+		if !hasBeenInjected(pod) || pod.Labels[keyManagedBy] != managedByValue {
+			return nil
+		}
+		endpointAddressMap[pod.Status.PodIP] = true
+		return r.registerServicesWithCatalog(pod, serviceEndpoints, healthStatus)
+	}
+
 	if hasBeenInjected(pod) {
 		// Build the endpointAddressMap up for deregistering service instances later.
 		endpointAddressMap[pod.Status.PodIP] = true
@@ -493,9 +641,10 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 	}
 	proxyConfig.Upstreams = upstreams
 
-	proxyPort := 20000
-	if idx := getMultiPortIdx(pod, serviceEndpoints); idx >= 0 {
-		proxyPort += idx
+	multiPortIdx := getMultiPortIdx(pod, serviceEndpoints)
+	proxyPort := envoyInboundPort
+	if multiPortIdx >= 0 {
+		proxyPort += multiPortIdx
 	}
 	proxyService := &api.AgentServiceRegistration{
 		Kind:      api.ServiceKindConnectProxy,
@@ -579,6 +728,15 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 			proxyService.TaggedAddresses = taggedAddresses
 
 			proxyService.Proxy.Mode = api.ProxyModeTransparent
+			// The outbound traffic of a multi-port pod is redirected to the
+			// proxy of the service it selects. The proxies of the other
+			// services need their own outbound listener port so that they
+			// can start.
+			if !handlesOutboundTraffic(pod, serviceEndpoints) {
+				proxyService.Proxy.TransparentProxy = &api.TransparentProxyConfig{
+					OutboundListenerPort: iptables.DefaultTProxyOutboundPort + multiPortIdx,
+				}
+			}
 		} else {
 			r.Log.Info("skipping syncing service cluster IP to Consul", "name", k8sService.Name, "ns", k8sService.Namespace, "ip", k8sService.Spec.ClusterIP)
 		}
@@ -588,7 +746,10 @@ func (r *EndpointsController) createServiceRegistrations(pod corev1.Pod, service
 		if err != nil {
 			return nil, nil, err
 		}
-		if overwriteProbes {
+		// The probes of a multi-port pod are only exposed by the proxy its
+		// traffic is redirected to since the listeners of the proxies can't
+		// share ports.
+		if overwriteProbes && handlesOutboundTraffic(pod, serviceEndpoints) {
 			var originalPod corev1.Pod
 			err := json.Unmarshal([]byte(pod.Annotations[annotationOriginalPod]), &originalPod)
 			if err != nil {
@@ -682,7 +843,12 @@ func getHealthCheckStatusReason(healthCheckStatus, podName, podNamespace string)
 // The argument endpointsAddressesMap decides whether to deregister *all* service instances or selectively deregister
 // them only if they are not in endpointsAddressesMap. If the map is nil, it will deregister all instances. If the map
 // has addresses, it will only deregister instances not in the map.
+// If EnableAgentlessRegistration is set, the instances are deregistered from the catalog instead.
 func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context, k8sSvcName, k8sSvcNamespace string, endpointsAddressesMap map[string]bool) error {
+	if r.EnableAgentlessRegistration {
+		return r.deregisterServiceFromCatalog(ctx, k8sSvcName, k8sSvcNamespace, endpointsAddressesMap, nil)
+	}
+
 	// Get all agents by getting pods with label component=client, app=consul and release=<ReleaseName>
 	agents := corev1.PodList{}
 	listOptions := client.ListOptions{
@@ -736,16 +902,20 @@ func (r *EndpointsController) deregisterServiceOnAllAgents(ctx context.Context,
 					r.Log.Info("deregistering service from consul", "svc", svcID)
 					if err = client.Agent().ServiceDeregister(svcID); err != nil {
 						r.Log.Error(err, "failed to deregister service instance", "id", svcID)
+						r.recordDeregistration(ctx, k8sSvcName, k8sSvcNamespace, svcID, err)
 						return err
 					}
+					r.recordDeregistration(ctx, k8sSvcName, k8sSvcNamespace, svcID, nil)
 					serviceDeregistered = true
 				}
 			} else {
 				r.Log.Info("deregistering service from consul", "svc", svcID)
 				if err = client.Agent().ServiceDeregister(svcID); err != nil {
 					r.Log.Error(err, "failed to deregister service instance", "id", svcID)
+					r.recordDeregistration(ctx, k8sSvcName, k8sSvcNamespace, svcID, err)
 					return err
 				}
+				r.recordDeregistration(ctx, k8sSvcName, k8sSvcNamespace, svcID, nil)
 				serviceDeregistered = true
 			}
 
@@ -780,14 +950,18 @@ func (r *EndpointsController) deleteACLTokensForServiceInstance(client *api.Clie
 	for _, token := range tokens {
 		// Only delete tokens that:
 		// * have been created with the auth method configured for this endpoints controller
-		// * have a single service identity whose service name is the same as 'serviceName'
-		if token.AuthMethod == r.AuthMethod &&
-			len(token.ServiceIdentities) == 1 &&
-			token.ServiceIdentities[0].ServiceName == serviceName {
+		// * have a single service identity whose service name is the same as 'serviceName', or
+		//   have been created for 'serviceName' by a multi-port pod sharing its service account,
+		//   whose tokens have the service identities of all services of the pod
+		if token.AuthMethod == r.AuthMethod && tokenHasServiceIdentity(token, serviceName) {
 			tokenMeta, err := getTokenMetaFromDescription(token.Description)
 			if err != nil {
 				return fmt.Errorf("failed to parse token metadata: %s", err)
 			}
+			if tokenService, ok := tokenMeta[TokenMetaServiceNameKey]; ok && tokenService != serviceName ||
+				!ok && len(token.ServiceIdentities) != 1 {
+				continue
+			}
 
 			tokenPodName := strings.TrimPrefix(tokenMeta[TokenMetaPodNameKey], k8sNS+"/")
 
@@ -807,8 +981,19 @@ func (r *EndpointsController) deleteACLTokensForServiceInstance(client *api.Clie
 	return nil
 }
 
-// processUpstreams reads the list of upstreams from the Pod annotation and converts them into a list of api.Upstream
-// objects.
+// tokenHasServiceIdentity returns true if the token has the service identity
+// of the service.
+func tokenHasServiceIdentity(token *api.ACLTokenListEntry, serviceName string) bool {
+	for _, identity := range token.ServiceIdentities {
+		if identity.ServiceName == serviceName {
+			return true
+		}
+	}
+	return false
+}
+
+// processUpstreams reads the list of upstreams from the Pod annotation, or from the ProxyConfig that applies to the Pod
+// if it isn't set, and converts them into a list of api.Upstream objects.
 func (r *EndpointsController) processUpstreams(pod corev1.Pod, endpoints corev1.Endpoints) ([]api.Upstream, error) {
 	// In a multiport pod, only the first service's proxy should have upstreams configured. This skips configuring
 	// upstreams on additional services on the pod.
@@ -858,6 +1043,10 @@ func (r *EndpointsController) processUpstreams(pod corev1.Pod, endpoints corev1.
 
 			upstreams = append(upstreams, upstream)
 		}
+	} else if r.EnableProxyConfigs {
+		// Upstreams from a ProxyConfig are only used if the pod doesn't
+		// have the upstreams annotation.
+		return r.processProxyConfigUpstreams(pod)
 	}
 
 	return upstreams, nil
@@ -944,23 +1133,9 @@ func (r *EndpointsController) processUnlabeledUpstream(pod corev1.Pod, rawUpstre
 	if len(parts) > 2 {
 		datacenter = strings.TrimSpace(parts[2])
 
-		// Check if there's a proxy defaults config with mesh gateway
-		// mode set to local or remote. This helps users from
-		// accidentally forgetting to set a mesh gateway mode
-		// and then being confused as to why their traffic isn't
-		// routing.
-		entry, _, err := r.ConsulClient.ConfigEntries().Get(api.ProxyDefaults, api.ProxyConfigGlobal, nil)
-		if err != nil && strings.Contains(err.Error(), "Unexpected response code: 404") {
-			return api.Upstream{}, fmt.Errorf("upstream %q is invalid: there is no ProxyDefaults config to set mesh gateway mode", rawUpstream)
-		} else if err == nil {
-			mode := entry.(*api.ProxyConfigEntry).MeshGateway.Mode
-			if mode != api.MeshGatewayModeLocal && mode != api.MeshGatewayModeRemote {
-				return api.Upstream{}, fmt.Errorf("upstream %q is invalid: ProxyDefaults mesh gateway mode is neither %q nor %q", rawUpstream, api.MeshGatewayModeLocal, api.MeshGatewayModeRemote)
-			}
+		if err := r.checkMeshGatewayMode(rawUpstream); err != nil {
+			return api.Upstream{}, err
 		}
-		// NOTE: If we can't reach Consul we don't error out because
-		// that would fail the pod scheduling and this is a nice-to-have
-		// check, not something that should block during a Consul hiccup.
 	}
 	if port > 0 {
 		upstream = api.Upstream{
@@ -977,6 +1152,26 @@ func (r *EndpointsController) processUnlabeledUpstream(pod corev1.Pod, rawUpstre
 
 }
 
+// checkMeshGatewayMode checks if there's a proxy defaults config with mesh gateway
+// mode set to local or remote for an upstream in another datacenter. This helps
+// users from accidentally forgetting to set a mesh gateway mode and then being
+// confused as to why their traffic isn't routing.
+func (r *EndpointsController) checkMeshGatewayMode(rawUpstream string) error {
+	entry, _, err := r.ConsulClient.ConfigEntries().Get(api.ProxyDefaults, api.ProxyConfigGlobal, nil)
+	if err != nil && strings.Contains(err.Error(), "Unexpected response code: 404") {
+		return fmt.Errorf("upstream %q is invalid: there is no ProxyDefaults config to set mesh gateway mode", rawUpstream)
+	} else if err == nil {
+		mode := entry.(*api.ProxyConfigEntry).MeshGateway.Mode
+		if mode != api.MeshGatewayModeLocal && mode != api.MeshGatewayModeRemote {
+			return fmt.Errorf("upstream %q is invalid: ProxyDefaults mesh gateway mode is neither %q nor %q", rawUpstream, api.MeshGatewayModeLocal, api.MeshGatewayModeRemote)
+		}
+	}
+	// NOTE: If we can't reach Consul we don't error out because
+	// that would fail the pod scheduling and this is a nice-to-have
+	// check, not something that should block during a Consul hiccup.
+	return nil
+}
+
 // processLabeledUpstream processes an upstream in the format:
 // [service-name].svc.[service-namespace].ns.[service-peer].peer:[port]
 // [service-name].svc.[service-namespace].ns.[service-partition].ap:[port]
@@ -1151,7 +1346,11 @@ func (r *EndpointsController) requestsForRunningAgentPods(object client.Object)
 
 	// Get the list of all endpoints.
 	var endpointsList corev1.EndpointsList
-	err = r.Client.List(r.Context, &endpointsList)
+	if r.EnableEndpointSlices {
+		endpointsList.Items, err = r.listEndpointsFromSlices(r.Context)
+	} else {
+		err = r.Client.List(r.Context, &endpointsList)
+	}
 	if err != nil {
 		r.Log.Error(err, "failed to list endpoints")
 		return []ctrl.Request{}
@@ -1175,6 +1374,31 @@ func (r *EndpointsController) requestsForRunningAgentPods(object client.Object)
 	return requests
 }
 
+// listEndpointsFromSlices lists all EndpointSlices and merges them into an
+// Endpoints object per service.
+func (r *EndpointsController) listEndpointsFromSlices(ctx context.Context) ([]corev1.Endpoints, error) {
+	var slices discoveryv1.EndpointSliceList
+	if err := r.Client.List(ctx, &slices); err != nil {
+		return nil, err
+	}
+
+	slicesByService := make(map[types.NamespacedName][]discoveryv1.EndpointSlice)
+	for _, slice := range slices.Items {
+		serviceName := endpointslice.ServiceName(&slice)
+		if serviceName == "" {
+			continue
+		}
+		name := types.NamespacedName{Name: serviceName, Namespace: slice.Namespace}
+		slicesByService[name] = append(slicesByService[name], slice)
+	}
+
+	var endpoints []corev1.Endpoints
+	for name, serviceSlices := range slicesByService {
+		endpoints = append(endpoints, *endpointslice.ToEndpoints(name.Name, name.Namespace, serviceSlices))
+	}
+	return endpoints, nil
+}
+
 // consulNamespace returns the Consul destination namespace for a provided Kubernetes namespace
 // depending on Consul Namespaces being enabled and the value of namespace mirroring.
 func (r *EndpointsController) consulNamespace(namespace string) string {
@@ -1238,6 +1462,17 @@ func consulTags(pod corev1.Pod) []string {
 	return interpolatedTags
 }
 
+// handlesOutboundTraffic returns true if the traffic of the pod is redirected
+// to the proxy of the service of the endpoints with transparent proxy. That is
+// the only proxy of single port pods and the proxy of the service that multi
+// port pods select with the transparent-proxy-outbound-service annotation.
+func handlesOutboundTraffic(pod corev1.Pod, serviceEndpoints corev1.Endpoints) bool {
+	if !strings.Contains(pod.Annotations[annotationService], ",") {
+		return true
+	}
+	return pod.Annotations[annotationTProxyOutboundService] == getServiceName(pod, serviceEndpoints)
+}
+
 func getMultiPortIdx(pod corev1.Pod, serviceEndpoints corev1.Endpoints) int {
 	for i, name := range strings.Split(pod.Annotations[annotationService], ",") {
 		if name == getServiceName(pod, serviceEndpoints) {
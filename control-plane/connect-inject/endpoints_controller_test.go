@@ -15,6 +15,8 @@ import (
 	"github.com/hashicorp/consul/sdk/testutil"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -4416,6 +4418,81 @@ func TestRequestsForRunningAgentPods(t *testing.T) {
 	}
 }
 
+// Test that the EndpointSlices of services on the node of the agent are
+// enqueued by service name when EndpointSlices are enabled.
+func TestRequestsForRunningAgentPods_endpointSlices(t *testing.T) {
+	t.Parallel()
+	logger := logrtest.TestLogger{T: t}
+	s := runtime.NewScheme()
+	s.AddKnownTypes(corev1.SchemeGroupVersion, &corev1.Pod{})
+	s.AddKnownTypes(discoveryv1.SchemeGroupVersion, &discoveryv1.EndpointSlice{}, &discoveryv1.EndpointSliceList{})
+	agentPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "consul-agent",
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node-foo",
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+		},
+	}
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(
+		agentPod,
+		endpointSlice("service-1-abc", "service-1", "node-foo", "1.1.1.1"),
+		endpointSlice("service-1-def", "service-1", "node-foo", "2.2.2.2"),
+		endpointSlice("service-2-abc", "service-2", "node-bar", "3.3.3.3"),
+		endpointSlice("unmanaged", "", "node-foo", "4.4.4.4"),
+	).Build()
+
+	controller := &EndpointsController{
+		Client:               fakeClient,
+		Scheme:               s,
+		Log:                  logger,
+		EnableEndpointSlices: true,
+	}
+	requests := controller.requestsForRunningAgentPods(agentPod)
+	require.ElementsMatch(t, []ctrl.Request{
+		{NamespacedName: types.NamespacedName{Name: "service-1", Namespace: "default"}},
+		{NamespacedName: types.NamespacedName{Name: "service-1", Namespace: "default"}},
+	}, requests)
+}
+
+// Test that the EndpointSlices of a service are merged when EndpointSlices
+// are enabled.
+func TestGetEndpoints_endpointSlices(t *testing.T) {
+	t.Parallel()
+	s := runtime.NewScheme()
+	s.AddKnownTypes(discoveryv1.SchemeGroupVersion, &discoveryv1.EndpointSlice{}, &discoveryv1.EndpointSliceList{})
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(
+		endpointSlice("service-1-abc", "service-1", "node-foo", "1.1.1.1"),
+		endpointSlice("service-1-def", "service-1", "node-bar", "2.2.2.2"),
+		endpointSlice("service-2-abc", "service-2", "node-foo", "3.3.3.3"),
+	).Build()
+	controller := &EndpointsController{
+		Client:               fakeClient,
+		EnableEndpointSlices: true,
+	}
+
+	var endpoints corev1.Endpoints
+	err := controller.getEndpoints(context.Background(), types.NamespacedName{Name: "service-1", Namespace: "default"}, &endpoints)
+	require.NoError(t, err)
+	require.Equal(t, "service-1", endpoints.Name)
+	require.Len(t, endpoints.Subsets, 2)
+	require.Equal(t, "1.1.1.1", endpoints.Subsets[0].Addresses[0].IP)
+	require.Equal(t, "2.2.2.2", endpoints.Subsets[1].Addresses[0].IP)
+
+	err = controller.getEndpoints(context.Background(), types.NamespacedName{Name: "service-3", Namespace: "default"}, &endpoints)
+	require.True(t, k8serrors.IsNotFound(err))
+}
+
+func TestRequestForEndpointSlice(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, []ctrl.Request{{NamespacedName: types.NamespacedName{Name: "service-1", Namespace: "default"}}},
+		requestForEndpointSlice(endpointSlice("service-1-abc", "service-1", "node-foo", "1.1.1.1")))
+	require.Empty(t, requestForEndpointSlice(endpointSlice("unmanaged", "", "node-foo", "1.1.1.1")))
+}
+
 func TestServiceInstancesForK8SServiceNameAndNamespace(t *testing.T) {
 	t.Parallel()
 
@@ -5844,6 +5921,54 @@ func TestCreateServiceRegistrations_withTransparentProxy(t *testing.T) {
 	}
 }
 
+// TestCreateServiceRegistrations_multiPortTransparentProxy tests that the
+// proxies of the services of a multi-port pod other than the service whose
+// proxy handles its outbound traffic get their own outbound listener port.
+func TestCreateServiceRegistrations_multiPortTransparentProxy(t *testing.T) {
+	t.Parallel()
+
+	for idx, serviceName := range []string{"web", "web-admin"} {
+		t.Run(serviceName, func(t *testing.T) {
+			pod := createPod("test-pod-1", "1.2.3.4", true, true)
+			pod.Annotations[annotationService] = "web,web-admin"
+			pod.Annotations[annotationPort] = "8080,9090"
+			pod.Annotations[annotationTProxyOutboundService] = "web-admin"
+			endpoints := &corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      serviceName,
+					Namespace: "default",
+				},
+			}
+			service := &corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      serviceName,
+					Namespace: "default",
+				},
+				Spec: corev1.ServiceSpec{
+					ClusterIP: "10.0.0.1",
+					Ports:     []corev1.ServicePort{{Port: 80}},
+				},
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: pod.Namespace}}
+			epCtrl := EndpointsController{
+				Client:                 fake.NewClientBuilder().WithRuntimeObjects(pod, endpoints, service, &ns).Build(),
+				EnableTransparentProxy: true,
+				Log:                    logrtest.TestLogger{T: t},
+			}
+
+			_, proxyServiceRegistration, err := epCtrl.createServiceRegistrations(*pod, *endpoints)
+			require.NoError(t, err)
+			require.Equal(t, api.ProxyModeTransparent, proxyServiceRegistration.Proxy.Mode)
+			require.Equal(t, 20000+idx, proxyServiceRegistration.Port)
+			if serviceName == "web-admin" {
+				require.Nil(t, proxyServiceRegistration.Proxy.TransparentProxy)
+			} else {
+				require.Equal(t, &api.TransparentProxyConfig{OutboundListenerPort: 15001 + idx}, proxyServiceRegistration.Proxy.TransparentProxy)
+			}
+		})
+	}
+}
+
 func TestGetTokenMetaFromDescription(t *testing.T) {
 	t.Parallel()
 	cases := map[string]struct {
@@ -5969,3 +6094,25 @@ func createPod(name, ip string, inject bool, managedByEndpointsController bool)
 func toStringPtr(input string) *string {
 	return &input
 }
+
+// endpointSlice returns an EndpointSlice in the default namespace for the
+// given service with a single ready endpoint on the node.
+func endpointSlice(name, serviceName, nodeName, ip string) *discoveryv1.EndpointSlice {
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints: []discoveryv1.Endpoint{
+			{
+				Addresses: []string{ip},
+				NodeName:  toStringPtr(nodeName),
+			},
+		},
+	}
+	if serviceName != "" {
+		slice.Labels = map[string]string{discoveryv1.LabelServiceName: serviceName}
+	}
+	return slice
+}
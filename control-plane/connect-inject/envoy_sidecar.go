@@ -91,6 +91,12 @@ func (w *MeshWebhook) envoySidecar(namespace corev1.Namespace, pod corev1.Pod, m
 		}
 	}
 
+	lifecycle, err := w.sidecarLifecycle(pod)
+	if err != nil {
+		return corev1.Container{}, err
+	}
+	lifecycle.configureEnvoySidecar(&container, mpi)
+
 	return container, nil
 }
 func (w *MeshWebhook) getContainerSidecarCommand(pod corev1.Pod, multiPortSvcName string, multiPortSvcIdx int) ([]string, error) {
@@ -104,6 +110,10 @@ func (w *MeshWebhook) getContainerSidecarCommand(pod corev1.Pod, multiPortSvcNam
 	}
 	if multiPortSvcName != "" {
 		// --base-id is needed so multiple Envoy proxies can run on the same host.
+		// Multi-port pods run one Envoy per service because Consul serves the
+		// xDS configuration of exactly one proxy service registration to each
+		// Envoy, so the services can't share a single Envoy with one listener
+		// per service.
 		cmd = append(cmd, "--base-id", fmt.Sprintf("%d", multiPortSvcIdx))
 	}
 
@@ -0,0 +1,83 @@
+package connectinject
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// injectionProfileForPod returns the name and settings of the injection
+// profile the pod selects with its annotation or, if it has none, the profile
+// its namespace selects with its label. It returns an empty name if no profile
+// is selected. The label of the namespace is ignored if injection profiles are
+// disabled since it applies to the pods of all releases in the namespace.
+func (w *MeshWebhook) injectionProfileForPod(ctx context.Context, ns corev1.Namespace, pod corev1.Pod) (string, sidecarSettings, error) {
+	name, ok := pod.Annotations[keyInjectionProfile]
+	if !ok {
+		name = ns.Labels[keyInjectionProfile]
+		if name != "" && w.InjectionProfilesConfigMap.Name == "" {
+			w.Log.Info("ignoring the injection profile selected by the namespace since injection profiles are not enabled",
+				"ns", ns.Name, "injection-profile", name)
+			return "", sidecarSettings{}, nil
+		}
+	}
+	if name == "" {
+		return "", sidecarSettings{}, nil
+	}
+
+	if w.InjectionProfilesConfigMap.Name == "" {
+		return "", sidecarSettings{}, fmt.Errorf("injection profile %q is selected but injection profiles are not enabled", name)
+	}
+	configMap, err := w.getInjectionProfilesConfigMap(ctx)
+	if err != nil {
+		return "", sidecarSettings{}, fmt.Errorf("reading injection profiles from ConfigMap %s: %s", w.InjectionProfilesConfigMap, err)
+	}
+	raw, ok := configMap.Data[name]
+	if !ok {
+		return "", sidecarSettings{}, fmt.Errorf("injection profile %q does not exist in ConfigMap %s", name, w.InjectionProfilesConfigMap)
+	}
+	settings, err := w.parseInjectionProfile(raw)
+	if err != nil {
+		return "", sidecarSettings{}, fmt.Errorf("injection profile %q is invalid: %s", name, err)
+	}
+	return name, settings, nil
+}
+
+// getInjectionProfilesConfigMap returns the ConfigMap with the injection
+// profiles from the lister, or from the API if it isn't in the cache.
+func (w *MeshWebhook) getInjectionProfilesConfigMap(ctx context.Context) (*corev1.ConfigMap, error) {
+	if w.InjectionProfilesLister != nil {
+		configMap, err := w.InjectionProfilesLister.Get(w.InjectionProfilesConfigMap.Name)
+		if !k8serrors.IsNotFound(err) {
+			return configMap, err
+		}
+	}
+	return w.Clientset.CoreV1().ConfigMaps(w.InjectionProfilesConfigMap.Namespace).Get(ctx, w.InjectionProfilesConfigMap.Name, metav1.GetOptions{})
+}
+
+// parseInjectionProfile parses the YAML or JSON settings of an injection
+// profile. The settings are validated like the annotations they are applied
+// as.
+func (w *MeshWebhook) parseInjectionProfile(raw string) (sidecarSettings, error) {
+	var settings sidecarSettings
+	if err := yaml.UnmarshalStrict([]byte(raw), &settings); err != nil {
+		return sidecarSettings{}, err
+	}
+
+	var pod corev1.Pod
+	applySidecarSettings(&pod, settings)
+	if errs := w.validateAnnotations(pod); len(errs) > 0 {
+		var msgs []string
+		for _, e := range errs {
+			msgs = append(msgs, e.Error())
+		}
+		return sidecarSettings{}, errors.New(strings.Join(msgs, "; "))
+	}
+	return settings, nil
+}
@@ -0,0 +1,207 @@
+package connectinject
+
+import (
+	"context"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestInjectionProfileForPod(t *testing.T) {
+	profiles := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "consul-injection-profiles", Namespace: "consul"},
+		Data: map[string]string{
+			"small": `
+resources:
+  requests:
+    cpu: 50m
+  limits:
+    memory: 64Mi
+concurrency: 1
+`,
+			"large": `{"concurrency": 4, "metrics": {"enabled": true, "prometheusScrapePort": 20300}}`,
+			"unknown-field": `concurrency: 1
+replicas: 3
+`,
+			"invalid-setting": `{"metrics": {"prometheusScrapePort": 80}}`,
+		},
+	}
+	cpu := resource.MustParse("50m")
+	memory := resource.MustParse("64Mi")
+	concurrency := func(i int) *int { return &i }
+	enabled := true
+
+	cases := map[string]struct {
+		nsLabels       map[string]string
+		podAnnotations map[string]string
+		disabled       bool
+		cached         bool
+		expName        string
+		expSettings    sidecarSettings
+		expErr         string
+	}{
+		"no profile selected": {},
+		"namespace label": {
+			nsLabels: map[string]string{keyInjectionProfile: "small"},
+			expName:  "small",
+			expSettings: sidecarSettings{
+				Resources: &corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: cpu},
+					Limits:   corev1.ResourceList{corev1.ResourceMemory: memory},
+				},
+				Concurrency: concurrency(1),
+			},
+		},
+		"pod annotation takes precedence over the namespace label": {
+			nsLabels:       map[string]string{keyInjectionProfile: "small"},
+			podAnnotations: map[string]string{keyInjectionProfile: "large"},
+			expName:        "large",
+			expSettings: sidecarSettings{
+				Concurrency: concurrency(4),
+				Metrics:     &v1alpha1.ProxyConfigMetrics{Enabled: &enabled, PrometheusScrapePort: 20300},
+			},
+		},
+		"empty pod annotation opts out of the namespace profile": {
+			nsLabels:       map[string]string{keyInjectionProfile: "small"},
+			podAnnotations: map[string]string{keyInjectionProfile: ""},
+		},
+		"profiles are disabled": {
+			podAnnotations: map[string]string{keyInjectionProfile: "small"},
+			disabled:       true,
+			expErr:         `injection profile "small" is selected but injection profiles are not enabled`,
+		},
+		"namespace label is ignored if profiles are disabled": {
+			nsLabels: map[string]string{keyInjectionProfile: "small"},
+			disabled: true,
+		},
+		"profile from the cache": {
+			podAnnotations: map[string]string{keyInjectionProfile: "cached"},
+			cached:         true,
+			expName:        "cached",
+			expSettings:    sidecarSettings{Concurrency: concurrency(2)},
+		},
+		"profile does not exist": {
+			podAnnotations: map[string]string{keyInjectionProfile: "medium"},
+			expErr:         `injection profile "medium" does not exist in ConfigMap consul/consul-injection-profiles`,
+		},
+		"unknown field": {
+			podAnnotations: map[string]string{keyInjectionProfile: "unknown-field"},
+			expErr:         `injection profile "unknown-field" is invalid: error unmarshaling JSON: while decoding JSON: json: unknown field "replicas"`,
+		},
+		"invalid setting": {
+			podAnnotations: map[string]string{keyInjectionProfile: "invalid-setting"},
+			expErr:         `injection profile "invalid-setting" is invalid: consul.hashicorp.com/prometheus-scrape-port annotation value of 80 is not in the unprivileged port range 1024-65535`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{
+				Log:                        logrtest.TestLogger{T: t},
+				Clientset:                  fake.NewSimpleClientset(profiles),
+				InjectionProfilesConfigMap: types.NamespacedName{Namespace: "consul", Name: "consul-injection-profiles"},
+			}
+			if c.disabled {
+				w.InjectionProfilesConfigMap = types.NamespacedName{}
+			}
+			if c.cached {
+				// The cache has a newer version of the ConfigMap than the API.
+				indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+				cachedProfiles := profiles.DeepCopy()
+				cachedProfiles.Data["cached"] = `concurrency: 2`
+				require.NoError(t, indexer.Add(cachedProfiles))
+				w.InjectionProfilesLister = corelisters.NewConfigMapLister(indexer).ConfigMaps("consul")
+			}
+			ns := corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: c.nsLabels}}
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: c.podAnnotations}}
+
+			name, settings, err := w.injectionProfileForPod(context.Background(), ns, pod)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expName, name)
+			require.Equal(t, c.expSettings, settings)
+		})
+	}
+}
+
+func TestHandlerHandle_injectionProfile(t *testing.T) {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{
+		Group:   "",
+		Version: "v1",
+	}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	concurrency := 4
+	pc := proxyConfig("web", "default", map[string]string{"app": "web"})
+	pc.Spec.Concurrency = &concurrency
+
+	w := MeshWebhook{
+		Log:                   logrtest.TestLogger{T: t},
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSet(),
+		Clientset: fake.NewSimpleClientset(
+			&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "default",
+				Labels: map[string]string{keyInjectionProfile: "small"},
+			}},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-injection-profiles", Namespace: "consul"},
+				Data: map[string]string{
+					"small": `{"concurrency": 1, "envoyExtraArgs": ["--log-level", "warn"], "resources": {"limits": {"cpu": "100m"}}}`,
+				},
+			},
+		),
+		Client:                     proxyConfigClient(pc),
+		EnableProxyConfigs:         true,
+		InjectionProfilesConfigMap: types.NamespacedName{Namespace: "consul", Name: "consul-injection-profiles"},
+		decoder:                    decoder,
+	}
+
+	resp := w.Handle(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Namespace: "default",
+			Object: encodeRaw(t, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "web"},
+					Annotations: map[string]string{
+						annotationEnvoyExtraArgs: "--log-level info",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "web"}},
+				},
+			}),
+		},
+	})
+	require.True(t, resp.Allowed, resp.Result)
+
+	patches := make(map[string]interface{})
+	for _, p := range resp.Patches {
+		patches[p.Path] = p.Value
+	}
+	require.Equal(t, "small", patches["/metadata/annotations/"+escapeJSONPointer(keyInjectionProfile)])
+	require.Equal(t, "100m", patches["/metadata/annotations/"+escapeJSONPointer(annotationSidecarProxyCPULimit)])
+	// The proxy config takes precedence over the profile and the annotation
+	// on the pod over both.
+	require.Equal(t, "4", patches["/metadata/annotations/"+escapeJSONPointer(annotationEnvoyProxyConcurrency)])
+	require.NotContains(t, patches, "/metadata/annotations/"+escapeJSONPointer(annotationEnvoyExtraArgs))
+}
@@ -13,15 +13,19 @@ import (
 
 	mapset "github.com/deckarep/golang-set"
 	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/hashicorp/consul/api"
 	"gomodules.xyz/jsonpatch/v2"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 )
 
@@ -36,7 +40,12 @@ type MeshWebhook struct {
 	ConsulClient *api.Client
 	Clientset    kubernetes.Interface
 
-	// ImageConsul is the container image for Consul to use.
+	// Client is used to read ProxyConfig resources. It must be set if
+	// EnableProxyConfigs is true.
+	Client client.Client
+
+	// ImageConsul is the container image for Consul to use. Injected pods
+	// don't run it since connect-init generates the Envoy bootstrap itself.
 	// ImageEnvoy is the container image for Envoy to use.
 	//
 	// Both of these MUST be set.
@@ -157,6 +166,63 @@ type MeshWebhook struct {
 	// wait for a response from the API before cancelling the request.
 	ConsulAPITimeout time.Duration
 
+	// EnableProxyConfigs applies the settings of the ProxyConfig resource
+	// that selects a pod to the pod. Pod annotations take precedence.
+	EnableProxyConfigs bool
+
+	// InjectionProfilesConfigMap is the ConfigMap with the injection profiles
+	// that pods select with the injection-profile annotation or their
+	// namespace with the label of the same name. Each key is the name of a
+	// profile and its value are the sidecar settings of the profile in YAML
+	// or JSON. Injection profiles are disabled if the name is empty.
+	InjectionProfilesConfigMap types.NamespacedName
+	// InjectionProfilesLister lists the ConfigMaps of the namespace of
+	// InjectionProfilesConfigMap from a cache so that it isn't read from the
+	// API on every admission. The API is used if it is nil.
+	InjectionProfilesLister corelisters.ConfigMapNamespaceLister
+
+	// WarnOnInvalidAnnotations admits pods with unknown or invalid
+	// consul.hashicorp.com annotations and returns the validation errors as
+	// admission warnings instead of rejecting them.
+	WarnOnInvalidAnnotations bool
+
+	// EnableAgentlessRegistration configures connect-init and Envoy for
+	// services that the endpoints controller registers directly with the
+	// Consul servers at ConsulServerHost instead of with client agents.
+	// Envoy talks to the gRPC API of the servers on ConsulServerGRPCPort.
+	EnableAgentlessRegistration bool
+	ConsulServerHost            string
+	ConsulServerGRPCPort        int
+
+	// EnableRegistrationReadinessGate adds a readiness gate for the
+	// registered condition to pods so that they only become ready once they
+	// are registered with Consul. The condition is maintained by the
+	// endpoints controller.
+	EnableRegistrationReadinessGate bool
+
+	// DefaultSidecarProxyShutdownGracePeriodSeconds is how long the Envoy
+	// sidecar drains its inbound connections for in its preStop hook. Zero
+	// disables draining. It can be overridden with an annotation.
+	DefaultSidecarProxyShutdownGracePeriodSeconds int
+
+	// DefaultSidecarProxyHoldApplicationUntilReady holds the start of the
+	// application containers until the Envoy sidecar is ready. It can be
+	// overridden with an annotation.
+	DefaultSidecarProxyHoldApplicationUntilReady bool
+
+	// EnableNativeSidecars injects the Envoy sidecar and the consul-sidecar
+	// as native sidecars, i.e. init containers with a restartPolicy of
+	// Always, which Kubernetes starts before and stops after the application
+	// containers. It requires Kubernetes 1.29+.
+	EnableNativeSidecars bool
+
+	// EnableMultiPortSharedServiceAccount lets the services of multi-port
+	// pods log in with the service account of the pod instead of requiring
+	// a service account per service. The service account must list the
+	// services in its service-identities annotation. The endpoints controller
+	// creates the binding rules that grant their identities to it.
+	EnableMultiPortSharedServiceAccount bool
+
 	// Log
 	Log logr.Logger
 	// Log settings for consul-sidecar
@@ -194,6 +260,10 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		return admission.Errored(http.StatusBadRequest, err)
 	}
 
+	// Keep the annotations as they were submitted so that they can be
+	// validated once we know the pod will be injected.
+	origPod := *pod.DeepCopy()
+
 	// Setup the default annotation values that are used for the container.
 	// This MUST be done before shouldInject is called since that function
 	// uses these annotations.
@@ -211,8 +281,64 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		return admission.Allowed(fmt.Sprintf("%s %s does not require injection", pod.Kind, pod.Name))
 	}
 
+	// Validate the consul.hashicorp.com annotations of the pod. Pods that
+	// won't be injected are never rejected because of their annotations. In
+	// warn-only mode the pod is admitted and the errors are returned as
+	// warnings.
+	var warnings []string
+	if errs := w.validateAnnotations(origPod); len(errs) > 0 {
+		var msgs []string
+		for _, e := range errs {
+			msgs = append(msgs, e.Error())
+		}
+		if !w.WarnOnInvalidAnnotations {
+			err := fmt.Errorf("invalid annotations: %s", strings.Join(msgs, "; "))
+			w.Log.Error(err, "error validating pod annotations", "request name", req.Name)
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		w.Log.Info("pod has invalid annotations", "request name", req.Name, "errors", msgs)
+		warnings = msgs
+	}
+
 	w.Log.Info("received pod", "name", req.Name, "ns", req.Namespace)
 
+	// Apply the settings of the ProxyConfig that selects the pod, if any,
+	// as annotations that aren't already set on the pod.
+	var proxyConfig *v1alpha1.ProxyConfig
+	if w.EnableProxyConfigs {
+		proxyConfig, err = proxyConfigForPod(ctx, w.Client, pod, req.Namespace)
+		if err != nil {
+			w.Log.Error(err, "error fetching proxy config", "request name", req.Name)
+			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error fetching proxy config: %s", err))
+		}
+		if proxyConfig != nil {
+			w.Log.Info("applying proxy config", "name", req.Name, "proxy-config", proxyConfig.Name)
+			applyProxyConfig(&pod, proxyConfig)
+		}
+	}
+
+	// A user can enable/disable tproxy or select an injection profile for an
+	// entire namespace via a label.
+	ns, err := w.Clientset.CoreV1().Namespaces().Get(ctx, req.Namespace, metav1.GetOptions{})
+	if err != nil {
+		w.Log.Error(err, "error fetching namespace metadata for container", "request name", req.Name)
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error getting namespace metadata for container: %s", err))
+	}
+
+	// Apply the settings of the injection profile selected by the pod or its
+	// namespace as annotations that aren't already set on the pod or by the
+	// ProxyConfig.
+	profileName, profile, err := w.injectionProfileForPod(ctx, *ns, pod)
+	if err != nil {
+		w.Log.Error(err, "error resolving injection profile", "request name", req.Name)
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("error resolving injection profile: %s", err))
+	}
+	if profileName != "" {
+		w.Log.Info("applying injection profile", "name", req.Name, "injection-profile", profileName)
+		applySidecarSettings(&pod, profile)
+		pod.Annotations[keyInjectionProfile] = profileName
+	}
+
 	// Add our volume that will be shared by the init container and
 	// the sidecar for passing data in the pod.
 	pod.Spec.Volumes = append(pod.Spec.Volumes, w.containerVolume())
@@ -233,6 +359,9 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 	// Add the upstream services as environment variables for easy
 	// service discovery.
 	containerEnvVars := w.containerEnvVars(pod)
+	if _, ok := pod.Annotations[annotationUpstreams]; !ok && proxyConfig != nil {
+		containerEnvVars = append(containerEnvVars, proxyConfigEnvVars(proxyConfig.Spec.Upstreams)...)
+	}
 	for i := range pod.Spec.InitContainers {
 		pod.Spec.InitContainers[i].Env = append(pod.Spec.InitContainers[i].Env, containerEnvVars...)
 	}
@@ -241,22 +370,15 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		pod.Spec.Containers[i].Env = append(pod.Spec.Containers[i].Env, containerEnvVars...)
 	}
 
-	// Add the init container which copies the Consul binary to /consul/connect-inject/.
-	initCopyContainer := w.initCopyContainer()
-	pod.Spec.InitContainers = append(pod.Spec.InitContainers, initCopyContainer)
-
-	// A user can enable/disable tproxy for an entire namespace via a label.
-	ns, err := w.Clientset.CoreV1().Namespaces().Get(ctx, req.Namespace, metav1.GetOptions{})
-	if err != nil {
-		w.Log.Error(err, "error fetching namespace metadata for container", "request name", req.Name)
-		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error getting namespace metadata for container: %s", err))
-	}
-
 	// Get service names from the annotation. If theres 0-1 service names, it's a single port pod, otherwise it's multi
 	// port.
 	annotatedSvcNames := w.annotatedServiceNames(pod)
 	multiPort := len(annotatedSvcNames) > 1
 
+	// The sidecars are appended to the containers of the pod and moved to
+	// where they start in the right order once the pod is mutated.
+	firstSidecar := len(pod.Spec.Containers)
+
 	// For single port pods, add the single init container and envoy sidecar.
 	if !multiPort {
 		// Add the init container that registers the service and sets up the Envoy configuration.
@@ -276,11 +398,12 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		pod.Spec.Containers = append(pod.Spec.Containers, envoySidecar)
 	} else {
 		// For multi port pods, check for unsupported cases, mount all relevant service account tokens, and mount an init
-		// container and envoy sidecar per port. Tproxy, metrics, and metrics merging are not supported for multi port pods.
+		// container and envoy sidecar per port. Metrics and metrics merging are not supported for multi port pods.
 		// In a single port pod, the service account specified in the pod is sufficient for mounting the service account
 		// token to the pod. In a multi port pod, where multiple services are registered with Consul, we also require a
-		// service account per service. So, this will look for service accounts whose name matches the service and mount
-		// those tokens if not already specified via the pod's serviceAccountName.
+		// service account per service unless the services share the service account of the pod. So, this will look for
+		// service accounts whose name matches the service and mount those tokens if not already specified via the pod's
+		// serviceAccountName.
 
 		w.Log.Info("processing multiport pod")
 		err := w.checkUnsupportedMultiPortCases(*ns, pod)
@@ -288,9 +411,15 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 			w.Log.Error(err, "checking unsupported cases for multi port pods")
 			return admission.Errored(http.StatusInternalServerError, err)
 		}
+		if w.AuthMethod != "" && w.EnableMultiPortSharedServiceAccount {
+			if err := w.checkSharedServiceAccount(ctx, pod, req.Namespace); err != nil {
+				w.Log.Error(err, "checking the shared service account of multi port pod")
+				return admission.Errored(http.StatusBadRequest, err)
+			}
+		}
 		for i, svc := range annotatedSvcNames {
 			w.Log.Info(fmt.Sprintf("service: %s", svc))
-			if w.AuthMethod != "" {
+			if w.AuthMethod != "" && !w.EnableMultiPortSharedServiceAccount {
 				if svc != "" && pod.Spec.ServiceAccountName != svc {
 					sa, err := w.Clientset.CoreV1().ServiceAccounts(req.Namespace).Get(ctx, svc, metav1.GetOptions{})
 					if err != nil {
@@ -382,6 +511,10 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		pod.Annotations[annotationConsulNamespace] = w.consulNamespace(req.Namespace)
 	}
 
+	if w.EnableRegistrationReadinessGate {
+		pod.Spec.ReadinessGates = append(pod.Spec.ReadinessGates, corev1.PodReadinessGate{ConditionType: conditionRegistered})
+	}
+
 	// Overwrite readiness/liveness probes if needed.
 	err = w.overwriteProbes(*ns, &pod)
 	if err != nil {
@@ -389,6 +522,13 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error overwriting readiness or liveness probes: %s", err))
 	}
 
+	lifecycle, err := w.sidecarLifecycle(pod)
+	if err != nil {
+		w.Log.Error(err, "error configuring sidecar lifecycle", "request name", req.Name)
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error configuring sidecar lifecycle: %s", err))
+	}
+	nativeSidecars := lifecycle.placeSidecars(&pod, firstSidecar)
+
 	// Marshall the pod into JSON after it has the desired envs, annotations, labels,
 	// sidecars and initContainers appended to it.
 	updatedPodJson, err := json.Marshal(pod)
@@ -402,6 +542,7 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 	if err != nil {
 		return admission.Errored(http.StatusBadRequest, err)
 	}
+	patches = append(patches, nativeSidecarPatches(pod, nativeSidecars)...)
 
 	// Check and potentially create Consul resources. This is done after
 	// all patches are created to guarantee no errors were encountered in
@@ -416,7 +557,7 @@ func (w *MeshWebhook) Handle(ctx context.Context, req admission.Request) admissi
 
 	// Return a Patched response along with the patches we intend on applying to the
 	// Pod received by the meshWebhook.
-	return admission.Patched(fmt.Sprintf("valid %s request", pod.Kind), patches...)
+	return admission.Patched(fmt.Sprintf("valid %s request", pod.Kind), patches...).WithWarnings(warnings...)
 }
 
 // shouldOverwriteProbes returns true if we need to overwrite readiness/liveness probes for this pod.
@@ -643,8 +784,10 @@ func (w *MeshWebhook) checkUnsupportedMultiPortCases(ns corev1.Namespace, pod co
 	if err != nil {
 		return fmt.Errorf("couldn't check if metrics merging is enabled: %s", err)
 	}
-	if tproxyEnabled {
-		return fmt.Errorf("multi port services are not compatible with transparent proxy")
+	// The outbound traffic of the pod leaves it with the identity of one of
+	// its services, so the pod has to choose which one.
+	if _, ok := pod.Annotations[annotationTProxyOutboundService]; tproxyEnabled && !ok {
+		return fmt.Errorf("multi port services with transparent proxy must set the %s annotation to the service whose proxy handles their outbound traffic", annotationTProxyOutboundService)
 	}
 	if metricsEnabled {
 		return fmt.Errorf("multi port services are not compatible with metrics")
@@ -655,6 +798,23 @@ func (w *MeshWebhook) checkUnsupportedMultiPortCases(ns corev1.Namespace, pod co
 	return nil
 }
 
+// checkSharedServiceAccount checks that the service account of the multi port
+// pod may log in with the identities of all services of the pod, so that a
+// pod can't get the identity of a service by naming it in its annotation.
+func (w *MeshWebhook) checkSharedServiceAccount(ctx context.Context, pod corev1.Pod, namespace string) error {
+	serviceAccountName := podServiceAccountName(pod)
+	sa, err := w.Clientset.CoreV1().ServiceAccounts(namespace).Get(ctx, serviceAccountName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("couldn't get service account %s: %s", serviceAccountName, err)
+	}
+	for _, svc := range w.annotatedServiceNames(pod) {
+		if !serviceAccountAllowsService(*sa, svc) {
+			return fmt.Errorf("service account %s doesn't allow the identity of service %s: add the service to its %s annotation", serviceAccountName, svc, annotationServiceIdentities)
+		}
+	}
+	return nil
+}
+
 func (w *MeshWebhook) InjectDecoder(d *admission.Decoder) error {
 	w.decoder = d
 	return nil
@@ -132,6 +132,53 @@ func TestHandlerHandle(t *testing.T) {
 			},
 		},
 
+		{
+			"pod with registration readiness gate",
+			MeshWebhook{
+				Log:                             logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet:           mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:            mapset.NewSet(),
+				EnableRegistrationReadinessGate: true,
+				decoder:                         decoder,
+				Clientset:                       defaultTestClientWithNamespace(),
+			},
+			admission.Request{
+				AdmissionRequest: admissionv1.AdmissionRequest{
+					Namespace: namespaces.DefaultNamespace,
+					Object: encodeRaw(t, &corev1.Pod{
+						Spec: basicSpec,
+					}),
+				},
+			},
+			"",
+			[]jsonpatch.Operation{
+				{
+					Operation: "add",
+					Path:      "/metadata/labels",
+				},
+				{
+					Operation: "add",
+					Path:      "/metadata/annotations",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/volumes",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/initContainers",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/containers/1",
+				},
+				{
+					Operation: "add",
+					Path:      "/spec/readinessGates",
+				},
+			},
+		},
+
 		{
 			"pod with upstreams specified",
 			MeshWebhook{
@@ -1880,9 +1927,9 @@ func TestHandler_checkUnsupportedMultiPortCases(t *testing.T) {
 		expErr      string
 	}{
 		{
-			name:        "tproxy",
+			name:        "tproxy without outbound service",
 			annotations: map[string]string{keyTransparentProxy: "true"},
-			expErr:      "multi port services are not compatible with transparent proxy",
+			expErr:      "multi port services with transparent proxy must set the consul.hashicorp.com/transparent-proxy-outbound-service annotation to the service whose proxy handles their outbound traffic",
 		},
 		{
 			name:        "metrics",
@@ -1907,6 +1954,48 @@ func TestHandler_checkUnsupportedMultiPortCases(t *testing.T) {
 
 	}
 
+	// Transparent proxy is supported once the pod selects the service whose
+	// proxy handles its outbound traffic.
+	pod := minimal()
+	pod.Annotations = map[string]string{keyTransparentProxy: "true", annotationTProxyOutboundService: "web"}
+	require.NoError(t, (&MeshWebhook{}).checkUnsupportedMultiPortCases(corev1.Namespace{}, *pod))
+}
+
+func TestHandler_checkSharedServiceAccount(t *testing.T) {
+	cases := map[string]struct {
+		serviceAccount *corev1.ServiceAccount
+		expErr         string
+	}{
+		"all services are allowed": {
+			serviceAccount: &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+				Name:        "web",
+				Namespace:   "default",
+				Annotations: map[string]string{annotationServiceIdentities: "web-admin"},
+			}},
+		},
+		"service is not allowed": {
+			serviceAccount: &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+			expErr:         "service account web doesn't allow the identity of service web-admin: add the service to its consul.hashicorp.com/service-identities annotation",
+		},
+		"service account does not exist": {
+			serviceAccount: &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "default"}},
+			expErr:         `couldn't get service account web: serviceaccounts "web" not found`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			w := MeshWebhook{Clientset: fake.NewSimpleClientset(c.serviceAccount)}
+			pod := minimal()
+			pod.Annotations = map[string]string{annotationService: "web,web-admin"}
+			pod.Spec.ServiceAccountName = "web"
+			err := w.checkSharedServiceAccount(context.Background(), *pod, "default")
+			if c.expErr == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, c.expErr)
+			}
+		})
+	}
 }
 
 // encodeRaw is a helper to encode some data into a RawExtension.
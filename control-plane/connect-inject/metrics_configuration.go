@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strconv"
 
+	"github.com/hashicorp/consul-k8s/control-plane/helper/metrics"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -21,6 +22,9 @@ type metricsPorts struct {
 	mergedPort  string
 	servicePort string
 	servicePath string
+	// mergingConfig is the configuration of the metrics targets to merge if
+	// it's set with the merged-metrics-config annotation.
+	mergingConfig *metrics.MergingConfig
 }
 
 const (
@@ -53,10 +57,23 @@ func (mc MetricsConfig) mergedMetricsServerConfiguration(pod corev1.Pod) (metric
 
 	serviceMetricsPath := mc.serviceMetricsPath(pod)
 
+	// Don't need to check the error since it's checked in the call to
+	// mc.shouldRunMergedMetricsServer() above.
+	mergingConfig, _ := mc.mergingConfig(pod)
+
+	// If the merging configuration only relabels the Envoy metrics or sets
+	// the format, the service metrics endpoint is its only target.
+	if mergingConfig != nil && len(mergingConfig.Targets) == 0 {
+		mergingConfig.Targets = []metrics.Target{
+			{Name: "service", Port: serviceMetricsPort, Path: serviceMetricsPath},
+		}
+	}
+
 	metricsPorts := metricsPorts{
-		mergedPort:  mergedMetricsPort,
-		servicePort: serviceMetricsPort,
-		servicePath: serviceMetricsPath,
+		mergedPort:    mergedMetricsPort,
+		servicePort:   serviceMetricsPort,
+		servicePath:   serviceMetricsPath,
+		mergingConfig: mergingConfig,
 	}
 	return metricsPorts, nil
 }
@@ -143,6 +160,46 @@ func (mc MetricsConfig) serviceMetricsPath(pod corev1.Pod) string {
 	return defaultServiceMetricsPath
 }
 
+// mergingConfig returns the configuration of the metrics targets to merge
+// from the merged-metrics-config annotation with the named ports of the
+// targets resolved to numbers, or nil if the annotation isn't set. The
+// volumes of the TLS files of the targets must be volumes of the pod.
+func (mc MetricsConfig) mergingConfig(pod corev1.Pod) (*metrics.MergingConfig, error) {
+	raw, ok := pod.Annotations[annotationMergedMetricsConfig]
+	if !ok || raw == "" {
+		return nil, nil
+	}
+
+	config, err := metrics.ParseMergingConfig(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s annotation value is invalid: %s", annotationMergedMetricsConfig, err)
+	}
+	for i, target := range config.Targets {
+		port, err := portValue(pod, target.Port)
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("%s annotation value is invalid: port %q of target %q is not a valid port",
+				annotationMergedMetricsConfig, target.Port, target.Name)
+		}
+		config.Targets[i].Port = fmt.Sprint(port)
+
+		if target.TLS != nil && target.TLS.Volume != "" && !hasVolume(pod, target.TLS.Volume) {
+			return nil, fmt.Errorf("%s annotation value is invalid: tls volume %q of target %q is not a volume of the pod",
+				annotationMergedMetricsConfig, target.TLS.Volume, target.Name)
+		}
+	}
+	return &config, nil
+}
+
+// hasVolume returns true if the pod has a volume of the name.
+func hasVolume(pod corev1.Pod, name string) bool {
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // shouldRunMergedMetricsServer returns whether we need to run a merged metrics
 // server. This is used to configure the consul sidecar command, and the init
 // container, so it can pass appropriate arguments to the consul connect envoy
@@ -160,12 +217,17 @@ func (mc MetricsConfig) shouldRunMergedMetricsServer(pod corev1.Pod) (bool, erro
 	if err != nil {
 		return false, err
 	}
+	mergingConfig, err := mc.mergingConfig(pod)
+	if err != nil {
+		return false, err
+	}
 
 	// Don't need to check error here since serviceMetricsPort has been
 	// validated by calling mc.serviceMetricsPort above.
 	smp, _ := strconv.Atoi(serviceMetricsPort)
+	hasTargets := mergingConfig != nil && len(mergingConfig.Targets) > 0
 
-	if enableMetrics && enableMetricsMerging && smp > 0 {
+	if enableMetrics && enableMetricsMerging && (smp > 0 || hasTargets) {
 		return true, nil
 	}
 	return false, nil
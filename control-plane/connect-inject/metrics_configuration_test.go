@@ -3,6 +3,7 @@ package connectinject
 import (
 	"testing"
 
+	"github.com/hashicorp/consul-k8s/control-plane/helper/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
 	"github.com/stretchr/testify/require"
 	corev1 "k8s.io/api/core/v1"
@@ -288,6 +289,32 @@ func TestMetricsConfigShouldRunMergedMetricsServer(t *testing.T) {
 			},
 			Expected: false,
 		},
+		{
+			Name: "Returns true when the merged metrics config has targets and the service metrics port is 0",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[annotationPort] = "0"
+				pod.Annotations[annotationMergedMetricsConfig] = `{"targets": [{"name": "app", "port": "9090"}]}`
+				return pod
+			},
+			MetricsConfig: MetricsConfig{
+				DefaultEnableMetrics:        true,
+				DefaultEnableMetricsMerging: true,
+			},
+			Expected: true,
+		},
+		{
+			Name: "Returns false when the merged metrics config has no targets and the service metrics port is 0",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[annotationPort] = "0"
+				pod.Annotations[annotationMergedMetricsConfig] = `{"format": "openmetrics"}`
+				return pod
+			},
+			MetricsConfig: MetricsConfig{
+				DefaultEnableMetrics:        true,
+				DefaultEnableMetricsMerging: true,
+			},
+			Expected: false,
+		},
 	}
 
 	for _, tt := range cases {
@@ -455,6 +482,7 @@ func TestMetricsConfigMergedMetricsServerConfiguration(t *testing.T) {
 		ExpectedMergedMetricsPort  string
 		ExpectedServiceMetricsPort string
 		ExpectedServiceMetricsPath string
+		ExpectedMergingConfig      *metrics.MergingConfig
 		ExpErr                     string
 	}{
 		{
@@ -484,6 +512,92 @@ func TestMetricsConfigMergedMetricsServerConfiguration(t *testing.T) {
 			},
 			ExpErr: "metrics merging should be enabled in order to return the metrics server configuration",
 		},
+		{
+			Name: "Returns the merged metrics config with named ports resolved",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Spec.Containers[1].Ports = []corev1.ContainerPort{{Name: "jvm-metrics", ContainerPort: 9404}}
+				pod.Annotations[annotationMergedMetricsConfig] = `{"targets": [{"name": "jvm", "port": "jvm-metrics", "prefix": "jvm_"}]}`
+				return pod
+			},
+			MetricsConfig: MetricsConfig{
+				DefaultEnableMetrics:        true,
+				DefaultEnableMetricsMerging: true,
+				DefaultMergedMetricsPort:    "12345",
+			},
+			ExpectedMergedMetricsPort:  "12345",
+			ExpectedServiceMetricsPort: "0",
+			ExpectedServiceMetricsPath: "/metrics",
+			ExpectedMergingConfig: &metrics.MergingConfig{
+				Targets: []metrics.Target{
+					{
+						Relabeling: metrics.Relabeling{Prefix: "jvm_"},
+						Name:       "jvm",
+						Port:       "9404",
+						Path:       "/metrics",
+						Scheme:     "http",
+					},
+				},
+			},
+		},
+		{
+			Name: "Returns the service metrics endpoint as the target of a merged metrics config without targets",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[annotationPort] = "1234"
+				pod.Annotations[annotationServiceMetricsPath] = "/app-metrics"
+				pod.Annotations[annotationMergedMetricsConfig] = `{"envoy": {"labels": {"source": "envoy"}}}`
+				return pod
+			},
+			MetricsConfig: MetricsConfig{
+				DefaultEnableMetrics:        true,
+				DefaultEnableMetricsMerging: true,
+				DefaultMergedMetricsPort:    "12345",
+			},
+			ExpectedMergedMetricsPort:  "12345",
+			ExpectedServiceMetricsPort: "1234",
+			ExpectedServiceMetricsPath: "/app-metrics",
+			ExpectedMergingConfig: &metrics.MergingConfig{
+				Envoy: metrics.Relabeling{Labels: map[string]string{"source": "envoy"}},
+				Targets: []metrics.Target{
+					{Name: "service", Port: "1234", Path: "/app-metrics"},
+				},
+			},
+		},
+		{
+			Name: "Returns an error when a target port is not a port of the pod",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[annotationMergedMetricsConfig] = `{"targets": [{"name": "jvm", "port": "jvm-metrics"}]}`
+				return pod
+			},
+			MetricsConfig: MetricsConfig{
+				DefaultEnableMetrics:        true,
+				DefaultEnableMetricsMerging: true,
+			},
+			ExpErr: `consul.hashicorp.com/merged-metrics-config annotation value is invalid: port "jvm-metrics" of target "jvm" is not a valid port`,
+		},
+		{
+			Name: "Returns an error when the volume of the TLS files of a target is not a volume of the pod",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[annotationMergedMetricsConfig] = `{"targets": [{"name": "jvm", "port": "9404", "scheme": "https", "tls": {"volume": "certs", "caFile": "ca.crt"}}]}`
+				return pod
+			},
+			MetricsConfig: MetricsConfig{
+				DefaultEnableMetrics:        true,
+				DefaultEnableMetricsMerging: true,
+			},
+			ExpErr: `consul.hashicorp.com/merged-metrics-config annotation value is invalid: tls volume "certs" of target "jvm" is not a volume of the pod`,
+		},
+		{
+			Name: "Returns an error when the merged metrics config is invalid",
+			Pod: func(pod *corev1.Pod) *corev1.Pod {
+				pod.Annotations[annotationMergedMetricsConfig] = `{"format": "json"}`
+				return pod
+			},
+			MetricsConfig: MetricsConfig{
+				DefaultEnableMetrics:        true,
+				DefaultEnableMetricsMerging: true,
+			},
+			ExpErr: `consul.hashicorp.com/merged-metrics-config annotation value is invalid: format "json" must be one of "prometheus" or "openmetrics"`,
+		},
 	}
 
 	for _, tt := range cases {
@@ -500,6 +614,7 @@ func TestMetricsConfigMergedMetricsServerConfiguration(t *testing.T) {
 				require.Equal(tt.ExpectedMergedMetricsPort, metricsPorts.mergedPort)
 				require.Equal(tt.ExpectedServiceMetricsPort, metricsPorts.servicePort)
 				require.Equal(tt.ExpectedServiceMetricsPath, metricsPorts.servicePath)
+				require.Equal(tt.ExpectedMergingConfig, metricsPorts.mergingConfig)
 			}
 		})
 	}
@@ -0,0 +1,164 @@
+package connectinject
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// multiPortBindingRuleDescriptionPrefix prefixes the description of the
+// binding rules created for multi-port pods sharing their service account.
+// The description identifies the Kubernetes service and the service account
+// the binding rule has been created for so that the rules of the service
+// accounts of all pods of a service coexist, and so that they can be deleted
+// once the service or the service account no longer need them.
+const multiPortBindingRuleDescriptionPrefix = "Binding rule for multi-port service"
+
+// usesSharedServiceAccount returns true if the pod is a multi-port pod whose
+// services all log in with the service account of the pod instead of one
+// service account per service.
+func (r *EndpointsController) usesSharedServiceAccount(pod corev1.Pod) bool {
+	if !r.EnableMultiPortSharedServiceAccount || r.AuthMethod == "" || !hasBeenInjected(pod) {
+		return false
+	}
+	return len(strings.Split(pod.Annotations[annotationService], ",")) > 1
+}
+
+// ensureMultiPortBindingRule creates a binding rule that grants tokens of the
+// service account of the pod the service identity of the service of the
+// endpoints. The identity is only granted if the pod names the service in its
+// annotation and the service account allows it with its service-identities
+// annotation, not because the pod happens to be selected by the service. The
+// binding rule created by server-acl-init already grants the service identity
+// named after the service account, so no binding rule is created for that
+// service. It returns the name of the service account the binding rule has
+// been created for, or an empty string if none was needed.
+func (r *EndpointsController) ensureMultiPortBindingRule(ctx context.Context, pod corev1.Pod, serviceEndpoints corev1.Endpoints) (string, error) {
+	if getMultiPortIdx(pod, serviceEndpoints) < 0 {
+		return "", nil
+	}
+	serviceName := getServiceName(pod, serviceEndpoints)
+	serviceAccountName := podServiceAccountName(pod)
+	if serviceAccountName == serviceName {
+		return "", nil
+	}
+
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	var sa corev1.ServiceAccount
+	if err := reader.Get(ctx, types.NamespacedName{Name: serviceAccountName, Namespace: pod.Namespace}, &sa); err != nil {
+		return "", fmt.Errorf("failed to get service account %s: %s", serviceAccountName, err)
+	}
+	if !serviceAccountAllowsService(sa, serviceName) {
+		return "", fmt.Errorf("service account %s doesn't allow the identity of service %s in its %s annotation", serviceAccountName, serviceName, annotationServiceIdentities)
+	}
+
+	authMethodNamespace := r.authMethodNamespace(pod.Namespace)
+	rules, _, err := r.ConsulClient.ACL().BindingRuleList(r.AuthMethod, &api.QueryOptions{Namespace: authMethodNamespace})
+	if err != nil {
+		return "", fmt.Errorf("failed to list binding rules: %s", err)
+	}
+
+	rule := &api.ACLBindingRule{
+		Description: multiPortBindingRuleDescription(serviceEndpoints.Namespace, serviceEndpoints.Name, serviceAccountName),
+		AuthMethod:  r.AuthMethod,
+		Selector:    fmt.Sprintf("serviceaccount.namespace==%q and serviceaccount.name==%q", pod.Namespace, serviceAccountName),
+		BindType:    api.BindingRuleBindTypeService,
+		BindName:    serviceName,
+	}
+	for _, existing := range rules {
+		if existing.Description != rule.Description {
+			continue
+		}
+		if existing.Selector == rule.Selector && existing.BindName == rule.BindName {
+			return serviceAccountName, nil
+		}
+		// The service has been renamed.
+		rule.ID = existing.ID
+		_, _, err = r.ConsulClient.ACL().BindingRuleUpdate(rule, &api.WriteOptions{Namespace: authMethodNamespace})
+		if err != nil {
+			return "", fmt.Errorf("failed to update binding rule: %s", err)
+		}
+		r.Log.Info("updated binding rule for multi-port service", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace, "serviceAccount", serviceAccountName)
+		return serviceAccountName, nil
+	}
+
+	_, _, err = r.ConsulClient.ACL().BindingRuleCreate(rule, &api.WriteOptions{Namespace: authMethodNamespace})
+	if err != nil {
+		return "", fmt.Errorf("failed to create binding rule: %s", err)
+	}
+	r.Log.Info("created binding rule for multi-port service", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace, "serviceAccount", serviceAccountName)
+	return serviceAccountName, nil
+}
+
+// deleteMultiPortBindingRules deletes the binding rules created for the
+// Kubernetes service by ensureMultiPortBindingRule, except for those of the
+// service accounts to keep. A nil set deletes all of them.
+func (r *EndpointsController) deleteMultiPortBindingRules(k8sSvcName, k8sSvcNamespace string, serviceAccountsToKeep mapset.Set) error {
+	authMethodNamespace := r.authMethodNamespace(k8sSvcNamespace)
+	rules, _, err := r.ConsulClient.ACL().BindingRuleList(r.AuthMethod, &api.QueryOptions{Namespace: authMethodNamespace})
+	if err != nil {
+		return fmt.Errorf("failed to list binding rules: %s", err)
+	}
+
+	prefix := multiPortBindingRuleDescription(k8sSvcNamespace, k8sSvcName, "")
+	for _, rule := range rules {
+		if !strings.HasPrefix(rule.Description, prefix) {
+			continue
+		}
+		serviceAccountName := strings.TrimPrefix(rule.Description, prefix)
+		if serviceAccountsToKeep != nil && serviceAccountsToKeep.Contains(serviceAccountName) {
+			continue
+		}
+		r.Log.Info("deleting binding rule for multi-port service", "name", k8sSvcName, "ns", k8sSvcNamespace, "serviceAccount", serviceAccountName)
+		_, err = r.ConsulClient.ACL().BindingRuleDelete(rule.ID, &api.WriteOptions{Namespace: authMethodNamespace})
+		if err != nil {
+			return fmt.Errorf("failed to delete binding rule: %s", err)
+		}
+	}
+	return nil
+}
+
+// authMethodNamespace returns the Consul namespace the auth method used by
+// pods in the Kubernetes namespace is defined in. If namespace mirroring is
+// enabled, the auth method is defined in the default namespace.
+func (r *EndpointsController) authMethodNamespace(k8sNamespace string) string {
+	consulNamespace := r.consulNamespace(k8sNamespace)
+	if consulNamespace != "" && r.EnableNSMirroring {
+		return "default"
+	}
+	return consulNamespace
+}
+
+// serviceAccountAllowsService returns true if the tokens of the service
+// account may have the identity of the service: the service is named after
+// the service account or listed in its service-identities annotation.
+func serviceAccountAllowsService(sa corev1.ServiceAccount, serviceName string) bool {
+	if sa.Name == serviceName {
+		return true
+	}
+	for _, name := range strings.Split(sa.Annotations[annotationServiceIdentities], ",") {
+		if name = strings.TrimSpace(name); name != "" && name == serviceName {
+			return true
+		}
+	}
+	return false
+}
+
+func podServiceAccountName(pod corev1.Pod) string {
+	if pod.Spec.ServiceAccountName == "" {
+		return "default"
+	}
+	return pod.Spec.ServiceAccountName
+}
+
+func multiPortBindingRuleDescription(k8sSvcNamespace, k8sSvcName, serviceAccountName string) string {
+	return fmt.Sprintf("%s %s/%s and service account %s", multiPortBindingRuleDescriptionPrefix, k8sSvcNamespace, k8sSvcName, serviceAccountName)
+}
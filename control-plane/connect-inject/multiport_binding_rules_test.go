@@ -0,0 +1,187 @@
+package connectinject
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/test"
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestUsesSharedServiceAccount(t *testing.T) {
+	cases := map[string]struct {
+		enabled    bool
+		authMethod string
+		inject     bool
+		service    string
+		exp        bool
+	}{
+		"multi port pod": {
+			enabled:    true,
+			authMethod: "auth-method",
+			inject:     true,
+			service:    "web,web-admin",
+			exp:        true,
+		},
+		"disabled": {
+			authMethod: "auth-method",
+			inject:     true,
+			service:    "web,web-admin",
+		},
+		"no auth method": {
+			enabled: true,
+			inject:  true,
+			service: "web,web-admin",
+		},
+		"not injected": {
+			enabled:    true,
+			authMethod: "auth-method",
+			service:    "web,web-admin",
+		},
+		"single port pod": {
+			enabled:    true,
+			authMethod: "auth-method",
+			inject:     true,
+			service:    "web",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", c.inject, true)
+			pod.Annotations[annotationService] = c.service
+			ep := EndpointsController{
+				AuthMethod:                          c.authMethod,
+				EnableMultiPortSharedServiceAccount: c.enabled,
+			}
+			require.Equal(t, c.exp, ep.usesSharedServiceAccount(*pod))
+		})
+	}
+}
+
+func TestMultiPortBindingRules(t *testing.T) {
+	adminToken := "123e4567-e89b-12d3-a456-426614174000"
+	consul, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) {
+		c.ACL.Enabled = true
+		c.ACL.Tokens.InitialManagement = adminToken
+	})
+	require.NoError(t, err)
+	defer consul.Stop()
+	consul.WaitForLeader(t)
+
+	consulClient, err := api.NewClient(&api.Config{Address: consul.HTTPAddr, Token: adminToken})
+	require.NoError(t, err)
+	test.SetupK8sAuthMethod(t, consulClient, "web", "default")
+
+	serviceAccount := func(name, identities string) *corev1.ServiceAccount {
+		return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "default",
+			Annotations: map[string]string{annotationServiceIdentities: identities},
+		}}
+	}
+	ep := EndpointsController{
+		Client: fake.NewClientBuilder().WithRuntimeObjects(
+			serviceAccount("web", "web-admin"),
+			serviceAccount("backend", "web-admin"),
+			serviceAccount("other", "web"),
+		).Build(),
+		Log:                                 logrtest.TestLogger{T: t},
+		ConsulClient:                        consulClient,
+		AuthMethod:                          test.AuthMethod,
+		EnableMultiPortSharedServiceAccount: true,
+	}
+	ctx := context.Background()
+
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	pod.Annotations[annotationService] = "web,web-admin"
+	pod.Spec.ServiceAccountName = "web"
+	endpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web-admin", Namespace: "default"}}
+
+	multiPortRules := func() map[string]*api.ACLBindingRule {
+		rules, _, err := consulClient.ACL().BindingRuleList(test.AuthMethod, nil)
+		require.NoError(t, err)
+		multiPort := make(map[string]*api.ACLBindingRule)
+		for _, rule := range rules {
+			if strings.HasPrefix(rule.Description, multiPortBindingRuleDescriptionPrefix) {
+				multiPort[rule.Description] = rule
+			}
+		}
+		return multiPort
+	}
+
+	// The binding rule is created once.
+	serviceAccountName, err := ep.ensureMultiPortBindingRule(ctx, *pod, endpoints)
+	require.NoError(t, err)
+	require.Equal(t, "web", serviceAccountName)
+	_, err = ep.ensureMultiPortBindingRule(ctx, *pod, endpoints)
+	require.NoError(t, err)
+	rules := multiPortRules()
+	require.Len(t, rules, 1)
+	rule := rules["Binding rule for multi-port service default/web-admin and service account web"]
+	require.NotNil(t, rule)
+	require.Equal(t, `serviceaccount.namespace=="default" and serviceaccount.name=="web"`, rule.Selector)
+	require.Equal(t, api.BindingRuleBindTypeService, rule.BindType)
+	require.Equal(t, "web-admin", rule.BindName)
+
+	// Pods of the same service with another service account get their own
+	// binding rule.
+	otherPod := pod.DeepCopy()
+	otherPod.Spec.ServiceAccountName = "backend"
+	_, err = ep.ensureMultiPortBindingRule(ctx, *otherPod, endpoints)
+	require.NoError(t, err)
+	rules = multiPortRules()
+	require.Len(t, rules, 2)
+	require.Equal(t, `serviceaccount.namespace=="default" and serviceaccount.name=="backend"`,
+		rules["Binding rule for multi-port service default/web-admin and service account backend"].Selector)
+
+	// No binding rule is needed for the service named after the service
+	// account.
+	serviceAccountName, err = ep.ensureMultiPortBindingRule(ctx, *pod, corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}})
+	require.NoError(t, err)
+	require.Empty(t, serviceAccountName)
+	require.Len(t, multiPortRules(), 2)
+
+	// No binding rule is created for a service the pod doesn't name in its
+	// annotation, even if the service selects it.
+	serviceAccountName, err = ep.ensureMultiPortBindingRule(ctx, *pod, corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "payments", Namespace: "default"}})
+	require.NoError(t, err)
+	require.Empty(t, serviceAccountName)
+	require.Len(t, multiPortRules(), 2)
+
+	// No binding rule is created if the service account doesn't allow the
+	// identity of the service.
+	otherPod.Spec.ServiceAccountName = "other"
+	_, err = ep.ensureMultiPortBindingRule(ctx, *otherPod, endpoints)
+	require.EqualError(t, err, "service account other doesn't allow the identity of service web-admin in its consul.hashicorp.com/service-identities annotation")
+	require.Len(t, multiPortRules(), 2)
+
+	// The binding rules of the service accounts no pod uses anymore are
+	// deleted.
+	require.NoError(t, ep.deleteMultiPortBindingRules("web-admin", "default", mapset.NewSetWith("web")))
+	rules = multiPortRules()
+	require.Len(t, rules, 1)
+	require.Contains(t, rules, "Binding rule for multi-port service default/web-admin and service account web")
+
+	require.NoError(t, ep.deleteMultiPortBindingRules("web-admin", "default", nil))
+	require.Empty(t, multiPortRules())
+}
+
+func TestServiceAccountAllowsService(t *testing.T) {
+	sa := corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Name:        "web",
+		Annotations: map[string]string{annotationServiceIdentities: "web-admin, web-grpc"},
+	}}
+	require.True(t, serviceAccountAllowsService(sa, "web"))
+	require.True(t, serviceAccountAllowsService(sa, "web-admin"))
+	require.True(t, serviceAccountAllowsService(sa, "web-grpc"))
+	require.False(t, serviceAccountAllowsService(sa, "payments"))
+	require.False(t, serviceAccountAllowsService(corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "web"}}, ""))
+}
@@ -0,0 +1,555 @@
+package connectinject
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/namespaces"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+const (
+	// envoyInboundPort is the port of the public listener of the sidecar
+	// proxy. Pods with multiple services use one port per service, starting
+	// at this port.
+	envoyInboundPort = 20000
+
+	// networkPolicyManagedByValue is the value of keyManagedBy on the
+	// NetworkPolicies created by the NetworkPolicyController.
+	networkPolicyManagedByValue = "consul-k8s-network-policy-controller"
+
+	// networkPolicyNamePrefix is prepended to the name of the ServiceIntentions
+	// resource to get the name of its NetworkPolicy.
+	networkPolicyNamePrefix = "consul-intentions-"
+
+	// networkPolicyResyncPeriod is how often the NetworkPolicies are regenerated
+	// to pick up changes to the intentions that were made directly in Consul.
+	networkPolicyResyncPeriod = 1 * time.Minute
+
+	// labelNamespaceName is the label Kubernetes sets on every namespace to
+	// its name.
+	labelNamespaceName = "kubernetes.io/metadata.name"
+
+	// wildcard matches all services or namespaces in intentions.
+	wildcard = "*"
+
+	// unlistedSource is the name of a source that no intention is written
+	// for. Checking if it may connect to a destination returns the default
+	// Consul applies to sources without intentions.
+	unlistedSource = "consul-k8s-network-policy-unlisted-source"
+)
+
+// gatewayComponents are the values of the component label of the gateway
+// pods of the Helm release.
+var gatewayComponents = []string{"ingress-gateway", "mesh-gateway", "terminating-gateway"}
+
+// NetworkPolicyController generates a NetworkPolicy for each ServiceIntentions
+// resource. The NetworkPolicy selects the pods of the Kubernetes Service named
+// like the destination of the intentions and only allows ingress to the
+// inbound port of their sidecar proxies from the pods of the Kubernetes
+// Services named like the sources the intentions allow. This keeps the
+// intentions enforced when the proxy is bypassed, e.g. with
+// transparent-proxy-exclude-uids.
+//
+// Ingress to the proxies is always allowed from the gateway pods of the Helm
+// release. Traffic from ingress gateways, from peered clusters and from other
+// admin partitions reaches the proxies through them, and the proxies enforce
+// the intentions of their sources.
+//
+// The sources are read from the effective intentions in Consul so that
+// intentions that were written to Consul directly are honored. The spec of
+// the resource is used if they don't exist in Consul yet. If Consul allows
+// sources without intentions by default, ingress to the proxies is allowed
+// from all pods.
+//
+// The ports that bypass the proxy on purpose (the excluded inbound ports, the
+// Prometheus scrape port and the probe ports, including the listeners of the
+// exposed paths) are always open.
+type NetworkPolicyController struct {
+	client.Client
+	// ConsulClient points at the agent local to the connect-inject deployment pod.
+	ConsulClient *api.Client
+
+	// EnableConsulNamespaces indicates that a user is running Consul Enterprise
+	// with version 1.7+ which supports namespaces.
+	EnableConsulNamespaces bool
+	// ConsulDestinationNamespace is the name of the Consul namespace to look
+	// intentions up in when namespace mirroring is disabled.
+	ConsulDestinationNamespace string
+	// EnableNSMirroring causes Consul namespaces to be created to match the
+	// k8s namespace of any service being registered into Consul. Sources are
+	// then only matched with Kubernetes Services of the mirrored namespace.
+	EnableNSMirroring bool
+	// NSMirroringPrefix is an optional prefix that can be added to the Consul
+	// namespaces created while mirroring.
+	NSMirroringPrefix string
+
+	// AllowedPorts are ports of the destination pods that are open to all
+	// pods, e.g. the port Prometheus scrapes merged metrics from.
+	AllowedPorts []int32
+
+	// ReleaseName and ReleaseNamespace are the name and namespace of the
+	// Helm release of the gateways that are allowed to connect to the proxies.
+	ReleaseName      string
+	ReleaseNamespace string
+
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	context.Context
+}
+
+//+kubebuilder:rbac:groups=consul.hashicorp.com,resources=serviceintentions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;delete
+
+// Reconcile creates, updates or deletes the NetworkPolicy of a
+// ServiceIntentions resource.
+func (r *NetworkPolicyController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	intentions := &consulv1alpha1.ServiceIntentions{}
+	err := r.Client.Get(ctx, req.NamespacedName, intentions)
+	// The NetworkPolicy is owned by the resource so it is garbage collected
+	// once the resource is deleted.
+	if k8serrors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	} else if err != nil {
+		r.Log.Error(err, "failed to get ServiceIntentions", "name", req.Name, "ns", req.Namespace)
+		return ctrl.Result{}, err
+	}
+	if !intentions.GetDeletionTimestamp().IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	destination := intentions.Spec.Destination.Name
+	var destinationSvc corev1.Service
+	if destination == wildcard {
+		r.Log.Info("intentions with a wildcard destination don't generate a NetworkPolicy", "name", req.Name, "ns", req.Namespace)
+		return ctrl.Result{}, r.deleteNetworkPolicy(ctx, req.NamespacedName)
+	}
+	err = r.Client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: destination}, &destinationSvc)
+	if k8serrors.IsNotFound(err) || (err == nil && len(destinationSvc.Spec.Selector) == 0) {
+		r.Log.Info("no Kubernetes Service with a selector for the destination of the intentions", "destination", destination, "ns", req.Namespace)
+		return ctrl.Result{}, r.deleteNetworkPolicy(ctx, req.NamespacedName)
+	} else if err != nil {
+		r.Log.Error(err, "failed to get Service", "name", destination, "ns", req.Namespace)
+		return ctrl.Result{}, err
+	}
+
+	sources, err := r.effectiveSources(intentions)
+	if err != nil {
+		r.Log.Error(err, "failed to read intentions from Consul", "destination", destination)
+		return ctrl.Result{}, err
+	}
+
+	var peers []networkingv1.NetworkPolicyPeer
+	for _, src := range sources {
+		srcPeers, err := r.sourcePeers(ctx, req.Namespace, src)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		peers = append(peers, srcPeers...)
+	}
+	peers = append(peers, r.gatewayPeer())
+
+	defaultAllow, err := r.defaultAllow(intentions)
+	if err != nil {
+		r.Log.Error(err, "failed to check the default intention in Consul", "destination", destination)
+		return ctrl.Result{}, err
+	}
+
+	proxyPorts, openPorts, err := r.destinationPorts(ctx, destinationSvc)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      networkPolicyNamePrefix + intentions.Name,
+			Namespace: intentions.Namespace,
+		},
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, policy, func() error {
+		if policy.Labels == nil {
+			policy.Labels = make(map[string]string)
+		}
+		policy.Labels[keyManagedBy] = networkPolicyManagedByValue
+		policy.Spec = networkPolicySpec(destinationSvc.Spec.Selector, proxyPorts, peers, defaultAllow, mergePorts(r.AllowedPorts, openPorts))
+		return controllerutil.SetControllerReference(intentions, policy, r.Scheme)
+	})
+	if err != nil {
+		r.Log.Error(err, "failed to create or update NetworkPolicy", "name", policy.Name, "ns", policy.Namespace)
+		return ctrl.Result{}, err
+	}
+	if result != controllerutil.OperationResultNone {
+		r.Log.Info("reconciled NetworkPolicy", "name", policy.Name, "ns", policy.Namespace, "operation", result)
+	}
+
+	return ctrl.Result{RequeueAfter: networkPolicyResyncPeriod}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *NetworkPolicyController) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&consulv1alpha1.ServiceIntentions{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Watches(
+			&source.Kind{Type: &corev1.Service{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForService),
+		).
+		Watches(
+			&source.Kind{Type: &corev1.Pod{}},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForPod),
+			// The ports of a pod only depend on its labels and annotations, not
+			// on its frequent status updates.
+			builder.WithPredicates(predicate.Funcs{
+				UpdateFunc: func(e event.UpdateEvent) bool {
+					return !reflect.DeepEqual(e.ObjectOld.GetLabels(), e.ObjectNew.GetLabels()) ||
+						!reflect.DeepEqual(e.ObjectOld.GetAnnotations(), e.ObjectNew.GetAnnotations())
+				},
+			}),
+		).Complete(r)
+}
+
+// effectiveSources returns the sources of the intentions of the destination
+// as they are in Consul, or as they are in the spec if the intentions haven't
+// been written to Consul.
+func (r *NetworkPolicyController) effectiveSources(intentions *consulv1alpha1.ServiceIntentions) ([]*api.SourceIntention, error) {
+	if r.ConsulClient != nil {
+		consulNS := r.destinationConsulNamespace(intentions)
+		entry, _, err := r.ConsulClient.ConfigEntries().Get(api.ServiceIntentions, intentions.Spec.Destination.Name, &api.QueryOptions{Namespace: consulNS})
+		if err == nil {
+			return entry.(*api.ServiceIntentionsConfigEntry).Sources, nil
+		}
+		if !strings.Contains(err.Error(), "Unexpected response code: 404") {
+			return nil, err
+		}
+	}
+	return intentions.ToConsul("").(*api.ServiceIntentionsConfigEntry).Sources, nil
+}
+
+// defaultAllow returns true if Consul allows sources without intentions to
+// connect to the destination, i.e. if the ACL default policy is allow and no
+// wildcard intention denies them. Without a Consul client, sources without
+// intentions are denied.
+func (r *NetworkPolicyController) defaultAllow(intentions *consulv1alpha1.ServiceIntentions) (bool, error) {
+	if r.ConsulClient == nil {
+		return false, nil
+	}
+	allowed, _, err := r.ConsulClient.Connect().IntentionCheck(&api.IntentionCheck{
+		Source:      unlistedSource,
+		Destination: intentions.Spec.Destination.Name,
+		SourceType:  api.IntentionSourceConsul,
+	}, &api.QueryOptions{Namespace: r.destinationConsulNamespace(intentions)})
+	return allowed, err
+}
+
+// destinationConsulNamespace returns the Consul namespace of the destination
+// of the intentions.
+func (r *NetworkPolicyController) destinationConsulNamespace(intentions *consulv1alpha1.ServiceIntentions) string {
+	if intentions.Spec.Destination.Namespace != "" {
+		return intentions.Spec.Destination.Namespace
+	}
+	return r.consulNamespace(intentions.Namespace)
+}
+
+// sourcePeers returns the peers of the NetworkPolicy for the source: the pods
+// selected by the Kubernetes Services named like the source, or all pods if
+// the source is a wildcard. No peers are returned if the source isn't allowed
+// or isn't in this cluster, since sources in peered clusters and other
+// partitions connect through the gateways.
+func (r *NetworkPolicyController) sourcePeers(ctx context.Context, k8sNS string, src *api.SourceIntention) ([]networkingv1.NetworkPolicyPeer, error) {
+	if !sourceAllowed(src) {
+		return nil, nil
+	}
+	if src.Peer != "" || (src.Partition != "" && src.Partition != "default") {
+		r.Log.Info("intention source outside of this cluster is allowed through the gateways", "name", src.Name, "peer", src.Peer, "partition", src.Partition)
+		return nil, nil
+	}
+
+	// Without mirroring, services of the same name in all Kubernetes namespaces
+	// are registered as the same Consul service.
+	var sourceNS string
+	if r.EnableConsulNamespaces && r.EnableNSMirroring {
+		sourceNS = k8sNS
+		if src.Namespace == wildcard {
+			sourceNS = ""
+		} else if src.Namespace != "" {
+			sourceNS = namespaces.K8SNamespace(src.Namespace, r.NSMirroringPrefix)
+		}
+	}
+
+	if src.Name == wildcard {
+		peer := networkingv1.NetworkPolicyPeer{
+			PodSelector:       &metav1.LabelSelector{},
+			NamespaceSelector: &metav1.LabelSelector{},
+		}
+		if sourceNS != "" {
+			peer.NamespaceSelector.MatchLabels = map[string]string{labelNamespaceName: sourceNS}
+		}
+		return []networkingv1.NetworkPolicyPeer{peer}, nil
+	}
+
+	var services corev1.ServiceList
+	if err := r.Client.List(ctx, &services, client.InNamespace(sourceNS)); err != nil {
+		r.Log.Error(err, "failed to list Services", "ns", sourceNS)
+		return nil, err
+	}
+	sort.Slice(services.Items, func(i, j int) bool {
+		return services.Items[i].Namespace < services.Items[j].Namespace
+	})
+	var peers []networkingv1.NetworkPolicyPeer
+	for _, svc := range services.Items {
+		if svc.Name != src.Name || len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		peers = append(peers, networkingv1.NetworkPolicyPeer{
+			PodSelector:       &metav1.LabelSelector{MatchLabels: svc.Spec.Selector},
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{labelNamespaceName: svc.Namespace}},
+		})
+	}
+	return peers, nil
+}
+
+// gatewayPeer returns the peer of the NetworkPolicy that selects the gateway
+// pods of the Helm release.
+func (r *NetworkPolicyController) gatewayPeer() networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		PodSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				"app":     "consul",
+				"release": r.ReleaseName,
+			},
+			MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      "component",
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   gatewayComponents,
+			}},
+		},
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{labelNamespaceName: r.ReleaseNamespace}},
+	}
+}
+
+// destinationPorts returns the inbound ports of the sidecar proxies of the
+// destination pods and the ports of the pods that are open to all pods. Pods
+// with multiple services use a different proxy port for each of them.
+func (r *NetworkPolicyController) destinationPorts(ctx context.Context, svc corev1.Service) ([]int32, []int32, error) {
+	var pods corev1.PodList
+	if err := r.Client.List(ctx, &pods, client.InNamespace(svc.Namespace), client.MatchingLabels(svc.Spec.Selector)); err != nil {
+		r.Log.Error(err, "failed to list pods", "ns", svc.Namespace)
+		return nil, nil, err
+	}
+
+	proxyPorts := []int32{envoyInboundPort}
+	var openPorts []int32
+	for _, pod := range pods.Items {
+		for i, name := range strings.Split(pod.Annotations[annotationService], ",") {
+			if name == svc.Name {
+				proxyPorts = append(proxyPorts, envoyInboundPort+int32(i))
+			}
+		}
+		openPorts = append(openPorts, podOpenPorts(pod)...)
+	}
+	return mergePorts(proxyPorts), mergePorts(openPorts), nil
+}
+
+// podOpenPorts returns the ports of the pod that bypass its sidecar proxy:
+// the inbound ports excluded from traffic redirection, the port Prometheus
+// scrapes and the ports of the probes. Probes that were overwritten to go
+// through the exposed paths of the proxy use the listener ports of the paths.
+func podOpenPorts(pod corev1.Pod) []int32 {
+	var ports []int32
+	for _, raw := range splitCommaSeparatedItemsFromAnnotation(annotationTProxyExcludeInboundPorts, pod) {
+		if port, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 32); err == nil {
+			ports = append(ports, int32(port))
+		}
+	}
+	if raw, ok := pod.Annotations[annotationPrometheusPort]; ok {
+		if port, err := strconv.ParseInt(raw, 10, 32); err == nil {
+			ports = append(ports, int32(port))
+		}
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, probe := range []*corev1.Probe{container.LivenessProbe, container.ReadinessProbe, container.StartupProbe} {
+			if probe == nil {
+				continue
+			}
+			var port intstr.IntOrString
+			if probe.HTTPGet != nil {
+				port = probe.HTTPGet.Port
+			} else if probe.TCPSocket != nil {
+				port = probe.TCPSocket.Port
+			} else {
+				continue
+			}
+			if p, err := portValueFromIntOrString(pod, port); err == nil && p > 0 {
+				ports = append(ports, int32(p))
+			}
+		}
+	}
+	return ports
+}
+
+// mergePorts returns the distinct ports of the lists in ascending order.
+func mergePorts(lists ...[]int32) []int32 {
+	set := make(map[int32]bool)
+	for _, list := range lists {
+		for _, port := range list {
+			set[port] = true
+		}
+	}
+	sorted := make([]int32, 0, len(set))
+	for port := range set {
+		sorted = append(sorted, port)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted
+}
+
+// deleteNetworkPolicy deletes the NetworkPolicy of the ServiceIntentions
+// resource if it exists.
+func (r *NetworkPolicyController) deleteNetworkPolicy(ctx context.Context, intentions types.NamespacedName) error {
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      networkPolicyNamePrefix + intentions.Name,
+			Namespace: intentions.Namespace,
+		},
+	}
+	if err := r.Client.Delete(ctx, policy); err != nil && !k8serrors.IsNotFound(err) {
+		r.Log.Error(err, "failed to delete NetworkPolicy", "name", policy.Name, "ns", policy.Namespace)
+		return err
+	}
+	return nil
+}
+
+// requestsForService enqueues the ServiceIntentions resources whose
+// destination or sources may be the Consul service of the Kubernetes Service.
+func (r *NetworkPolicyController) requestsForService(object client.Object) []reconcile.Request {
+	var intentionsList consulv1alpha1.ServiceIntentionsList
+	if err := r.Client.List(r.Context, &intentionsList); err != nil {
+		r.Log.Error(err, "failed to list ServiceIntentions")
+		return []ctrl.Request{}
+	}
+
+	var requests []reconcile.Request
+	for _, intentions := range intentionsList.Items {
+		matches := intentions.Namespace == object.GetNamespace() && intentions.Spec.Destination.Name == object.GetName()
+		for _, src := range intentions.Spec.Sources {
+			if src.Name == object.GetName() || src.Name == wildcard {
+				matches = true
+			}
+		}
+		if matches {
+			requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: intentions.Namespace, Name: intentions.Name}})
+		}
+	}
+	return requests
+}
+
+// requestsForPod enqueues the ServiceIntentions resources whose destination
+// is the Consul service of a Kubernetes Service that selects the pod, so that
+// changes to the ports of the pod are applied to their NetworkPolicies.
+func (r *NetworkPolicyController) requestsForPod(object client.Object) []reconcile.Request {
+	var services corev1.ServiceList
+	if err := r.Client.List(r.Context, &services, client.InNamespace(object.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list Services", "ns", object.GetNamespace())
+		return []ctrl.Request{}
+	}
+	destinations := make(map[string]bool)
+	for _, svc := range services.Items {
+		if len(svc.Spec.Selector) > 0 && labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(object.GetLabels())) {
+			destinations[svc.Name] = true
+		}
+	}
+	if len(destinations) == 0 {
+		return []ctrl.Request{}
+	}
+
+	var intentionsList consulv1alpha1.ServiceIntentionsList
+	if err := r.Client.List(r.Context, &intentionsList, client.InNamespace(object.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list ServiceIntentions", "ns", object.GetNamespace())
+		return []ctrl.Request{}
+	}
+	var requests []reconcile.Request
+	for _, intentions := range intentionsList.Items {
+		if destinations[intentions.Spec.Destination.Name] {
+			requests = append(requests, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: intentions.Namespace, Name: intentions.Name}})
+		}
+	}
+	return requests
+}
+
+func (r *NetworkPolicyController) consulNamespace(k8sNS string) string {
+	return namespaces.ConsulNamespace(k8sNS, r.EnableConsulNamespaces, r.ConsulDestinationNamespace, r.EnableNSMirroring, r.NSMirroringPrefix)
+}
+
+// networkPolicySpec returns the spec of a NetworkPolicy that only allows
+// ingress to the proxy ports of the selected pods from the peers, and to the
+// open ports from everywhere. If Consul allows sources without intentions by
+// default, ingress to the proxy ports is allowed from everywhere.
+func networkPolicySpec(selector map[string]string, proxyPorts []int32, peers []networkingv1.NetworkPolicyPeer, defaultAllow bool, openPorts []int32) networkingv1.NetworkPolicySpec {
+	spec := networkingv1.NetworkPolicySpec{
+		PodSelector: metav1.LabelSelector{MatchLabels: selector},
+		PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+	}
+	if defaultAllow {
+		spec.Ingress = append(spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+			Ports: tcpPorts(proxyPorts),
+		})
+	} else if len(peers) > 0 {
+		spec.Ingress = append(spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+			Ports: tcpPorts(proxyPorts),
+			From:  peers,
+		})
+	}
+	if len(openPorts) > 0 {
+		spec.Ingress = append(spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+			Ports: tcpPorts(openPorts),
+		})
+	}
+	return spec
+}
+
+func tcpPorts(ports []int32) []networkingv1.NetworkPolicyPort {
+	protocol := corev1.ProtocolTCP
+	var policyPorts []networkingv1.NetworkPolicyPort
+	for _, port := range ports {
+		port := intstr.FromInt(int(port))
+		policyPorts = append(policyPorts, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &port})
+	}
+	return policyPorts
+}
+
+// sourceAllowed returns true if the intention allows any traffic from the
+// source, either with an allow action or with an L7 permission that allows.
+func sourceAllowed(src *api.SourceIntention) bool {
+	if src.Action == api.IntentionActionAllow {
+		return true
+	}
+	for _, perm := range src.Permissions {
+		if perm.Action == api.IntentionActionAllow {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,494 @@
+package connectinject
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNetworkPolicyController_Reconcile(t *testing.T) {
+	service := func(name, namespace string) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": name}},
+		}
+	}
+	intentions := func(sources ...*v1alpha1.SourceIntention) *v1alpha1.ServiceIntentions {
+		return &v1alpha1.ServiceIntentions{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-intentions", Namespace: "default"},
+			Spec: v1alpha1.ServiceIntentionsSpec{
+				Destination: v1alpha1.Destination{Name: "web"},
+				Sources:     sources,
+			},
+		}
+	}
+	peer := func(app, namespace string) networkingv1.NetworkPolicyPeer {
+		return networkingv1.NetworkPolicyPeer{
+			PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+			NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{labelNamespaceName: namespace}},
+		}
+	}
+	gateways := testGatewayPeer()
+
+	cases := map[string]struct {
+		k8sObjects        []runtime.Object
+		enableMirroring   bool
+		allowedPorts      []int32
+		expSpec           *networkingv1.NetworkPolicySpec
+		expPolicyDeleted  bool
+		expRequeueSkipped bool
+	}{
+		"allowed sources in all namespaces": {
+			k8sObjects: []runtime.Object{
+				intentions(
+					&v1alpha1.SourceIntention{Name: "api", Action: "allow"},
+					&v1alpha1.SourceIntention{Name: "db", Action: "deny"},
+					&v1alpha1.SourceIntention{Name: "frontend", Permissions: v1alpha1.IntentionPermissions{
+						{Action: "allow", HTTP: &v1alpha1.IntentionHTTPPermission{PathPrefix: "/"}},
+					}},
+					&v1alpha1.SourceIntention{Name: "api", Peer: "other-cluster", Action: "allow"},
+				),
+				service("web", "default"),
+				service("api", "default"),
+				service("api", "other"),
+				service("db", "default"),
+				service("frontend", "other"),
+			},
+			allowedPorts: []int32{20200},
+			expSpec: &networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						Ports: tcpPorts([]int32{20000}),
+						From:  []networkingv1.NetworkPolicyPeer{peer("api", "default"), peer("api", "other"), peer("frontend", "other"), gateways},
+					},
+					{
+						Ports: tcpPorts([]int32{20200}),
+					},
+				},
+			},
+		},
+		"sources in the mirrored namespace only": {
+			k8sObjects: []runtime.Object{
+				intentions(
+					&v1alpha1.SourceIntention{Name: "api", Action: "allow"},
+					&v1alpha1.SourceIntention{Name: "frontend", Namespace: "k8s-other", Action: "allow"},
+				),
+				service("web", "default"),
+				service("api", "default"),
+				service("api", "other"),
+				service("frontend", "default"),
+				service("frontend", "other"),
+			},
+			enableMirroring: true,
+			expSpec: &networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						Ports: tcpPorts([]int32{20000}),
+						From:  []networkingv1.NetworkPolicyPeer{peer("api", "default"), peer("frontend", "other"), gateways},
+					},
+				},
+			},
+		},
+		"wildcard source": {
+			k8sObjects: []runtime.Object{
+				intentions(&v1alpha1.SourceIntention{Name: "*", Action: "allow"}),
+				service("web", "default"),
+			},
+			expSpec: &networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						Ports: tcpPorts([]int32{20000}),
+						From: []networkingv1.NetworkPolicyPeer{
+							{
+								PodSelector:       &metav1.LabelSelector{},
+								NamespaceSelector: &metav1.LabelSelector{},
+							},
+							gateways,
+						},
+					},
+				},
+			},
+		},
+		"no allowed sources only allows the gateways": {
+			k8sObjects: []runtime.Object{
+				intentions(&v1alpha1.SourceIntention{Name: "api", Action: "deny"}),
+				service("web", "default"),
+				service("api", "default"),
+			},
+			expSpec: &networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						Ports: tcpPorts([]int32{20000}),
+						From:  []networkingv1.NetworkPolicyPeer{gateways},
+					},
+				},
+			},
+		},
+		"sources in peered clusters and other partitions are allowed through the gateways": {
+			k8sObjects: []runtime.Object{
+				intentions(
+					&v1alpha1.SourceIntention{Name: "api", Peer: "other-cluster", Action: "allow"},
+					&v1alpha1.SourceIntention{Name: "frontend", Partition: "other", Action: "allow"},
+				),
+				service("web", "default"),
+				service("api", "default"),
+				service("frontend", "default"),
+			},
+			expSpec: &networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						Ports: tcpPorts([]int32{20000}),
+						From:  []networkingv1.NetworkPolicyPeer{gateways},
+					},
+				},
+			},
+		},
+		"ingress gateway source is allowed through the gateways": {
+			k8sObjects: []runtime.Object{
+				intentions(&v1alpha1.SourceIntention{Name: "ingress-gateway", Action: "allow"}),
+				service("web", "default"),
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{Name: "consul-ingress-gateway", Namespace: "consul"},
+					Spec: corev1.ServiceSpec{Selector: map[string]string{
+						"app":       "consul",
+						"release":   "consul",
+						"component": "ingress-gateway",
+					}},
+				},
+			},
+			expSpec: &networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						Ports: tcpPorts([]int32{20000}),
+						From:  []networkingv1.NetworkPolicyPeer{gateways},
+					},
+				},
+			},
+		},
+		"multi port pods": {
+			k8sObjects: []runtime.Object{
+				intentions(&v1alpha1.SourceIntention{Name: "api", Action: "allow"}),
+				service("web", "default"),
+				service("api", "default"),
+				&corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+					Name:        "web-pod",
+					Namespace:   "default",
+					Labels:      map[string]string{"app": "web"},
+					Annotations: map[string]string{annotationService: "web-admin,web"},
+				}},
+			},
+			expSpec: &networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						Ports: tcpPorts([]int32{20000, 20001}),
+						From:  []networkingv1.NetworkPolicyPeer{peer("api", "default"), gateways},
+					},
+				},
+			},
+		},
+		"ports that bypass the proxy are open": {
+			k8sObjects: []runtime.Object{
+				intentions(&v1alpha1.SourceIntention{Name: "api", Action: "allow"}),
+				service("web", "default"),
+				service("api", "default"),
+				&corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "web-pod",
+						Namespace: "default",
+						Labels:    map[string]string{"app": "web"},
+						Annotations: map[string]string{
+							annotationTProxyExcludeInboundPorts: "8081, 8082",
+							annotationPrometheusPort:            "20200",
+						},
+					},
+					Spec: corev1.PodSpec{Containers: []corev1.Container{{
+						Name:  "web",
+						Ports: []corev1.ContainerPort{{Name: "health", ContainerPort: 8090}},
+						LivenessProbe: &corev1.Probe{Handler: corev1.Handler{
+							HTTPGet: &corev1.HTTPGetAction{Port: intstr.FromInt(20300)},
+						}},
+						ReadinessProbe: &corev1.Probe{Handler: corev1.Handler{
+							TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString("health")},
+						}},
+						StartupProbe: &corev1.Probe{Handler: corev1.Handler{
+							Exec: &corev1.ExecAction{Command: []string{"true"}},
+						}},
+					}}},
+				},
+			},
+			allowedPorts: []int32{20200},
+			expSpec: &networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						Ports: tcpPorts([]int32{20000}),
+						From:  []networkingv1.NetworkPolicyPeer{peer("api", "default"), gateways},
+					},
+					{
+						Ports: tcpPorts([]int32{8081, 8082, 8090, 20200, 20300}),
+					},
+				},
+			},
+		},
+		"destination service is missing": {
+			k8sObjects: []runtime.Object{
+				intentions(&v1alpha1.SourceIntention{Name: "api", Action: "allow"}),
+				&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "consul-intentions-web-intentions", Namespace: "default"}},
+			},
+			expPolicyDeleted:  true,
+			expRequeueSkipped: true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			fakeClient := fake.NewClientBuilder().WithScheme(networkPolicyTestScheme()).WithRuntimeObjects(c.k8sObjects...).Build()
+			controller := &NetworkPolicyController{
+				Client:                 fakeClient,
+				EnableConsulNamespaces: c.enableMirroring,
+				EnableNSMirroring:      c.enableMirroring,
+				NSMirroringPrefix:      "k8s-",
+				AllowedPorts:           c.allowedPorts,
+				ReleaseName:            "consul",
+				ReleaseNamespace:       "consul",
+				Log:                    logrtest.TestLogger{T: t},
+				Scheme:                 fakeClient.Scheme(),
+				Context:                context.Background(),
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "web-intentions", Namespace: "default"}}
+			resp, err := controller.Reconcile(context.Background(), req)
+			require.NoError(t, err)
+			require.Equal(t, c.expRequeueSkipped, resp.RequeueAfter == 0)
+
+			var policy networkingv1.NetworkPolicy
+			err = fakeClient.Get(context.Background(), types.NamespacedName{Name: "consul-intentions-web-intentions", Namespace: "default"}, &policy)
+			if c.expPolicyDeleted {
+				require.True(t, k8serrors.IsNotFound(err))
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, networkPolicyManagedByValue, policy.Labels[keyManagedBy])
+			require.Len(t, policy.OwnerReferences, 1)
+			require.Equal(t, "web-intentions", policy.OwnerReferences[0].Name)
+			require.Equal(t, *c.expSpec, policy.Spec)
+		})
+	}
+}
+
+// Test that the sources of the intentions in Consul take precedence over the
+// spec of the resource, and that ingress to the proxies is allowed from all
+// pods if Consul allows sources without intentions by default.
+func TestNetworkPolicyController_EffectiveIntentions(t *testing.T) {
+	cases := map[string]struct {
+		defaultAllow bool
+		expIngress   []networkingv1.NetworkPolicyIngressRule
+	}{
+		"default deny": {
+			defaultAllow: false,
+			expIngress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: tcpPorts([]int32{20000}),
+				From: []networkingv1.NetworkPolicyPeer{
+					{
+						PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "frontend"}},
+						NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{labelNamespaceName: "default"}},
+					},
+					testGatewayPeer(),
+				},
+			}},
+		},
+		"default allow": {
+			defaultAllow: true,
+			expIngress: []networkingv1.NetworkPolicyIngressRule{{
+				Ports: tcpPorts([]int32{20000}),
+			}},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/v1/config/service-intentions/web":
+					require.NoError(t, json.NewEncoder(w).Encode(&api.ServiceIntentionsConfigEntry{
+						Kind: api.ServiceIntentions,
+						Name: "web",
+						Sources: []*api.SourceIntention{
+							{Name: "frontend", Action: api.IntentionActionAllow},
+						},
+					}))
+				case "/v1/connect/intentions/check":
+					require.Equal(t, unlistedSource, r.URL.Query().Get("source"))
+					require.Equal(t, "web", r.URL.Query().Get("destination"))
+					require.NoError(t, json.NewEncoder(w).Encode(map[string]bool{"Allowed": c.defaultAllow}))
+				default:
+					t.Fatalf("unexpected request to %s", r.URL.Path)
+				}
+			}))
+			defer consulServer.Close()
+			consulClient, err := api.NewClient(&api.Config{Address: consulServer.URL})
+			require.NoError(t, err)
+
+			intentions := &v1alpha1.ServiceIntentions{
+				ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+				Spec: v1alpha1.ServiceIntentionsSpec{
+					Destination: v1alpha1.Destination{Name: "web"},
+					Sources:     v1alpha1.SourceIntentions{{Name: "api", Action: "allow"}},
+				},
+			}
+			fakeClient := fake.NewClientBuilder().WithScheme(networkPolicyTestScheme()).WithRuntimeObjects(
+				intentions,
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+					Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+				},
+				&corev1.Service{
+					ObjectMeta: metav1.ObjectMeta{Name: "frontend", Namespace: "default"},
+					Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "frontend"}},
+				},
+			).Build()
+			controller := &NetworkPolicyController{
+				Client:           fakeClient,
+				ConsulClient:     consulClient,
+				ReleaseName:      "consul",
+				ReleaseNamespace: "consul",
+				Log:              logrtest.TestLogger{T: t},
+				Scheme:           fakeClient.Scheme(),
+				Context:          context.Background(),
+			}
+
+			_, err = controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "web", Namespace: "default"}})
+			require.NoError(t, err)
+			var policy networkingv1.NetworkPolicy
+			require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "consul-intentions-web", Namespace: "default"}, &policy))
+			require.Equal(t, c.expIngress, policy.Spec.Ingress)
+		})
+	}
+}
+
+func TestNetworkPolicyController_requestsForService(t *testing.T) {
+	intentions := func(name, namespace, destination string, sources ...string) *v1alpha1.ServiceIntentions {
+		in := &v1alpha1.ServiceIntentions{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       v1alpha1.ServiceIntentionsSpec{Destination: v1alpha1.Destination{Name: destination}},
+		}
+		for _, src := range sources {
+			in.Spec.Sources = append(in.Spec.Sources, &v1alpha1.SourceIntention{Name: src, Action: "allow"})
+		}
+		return in
+	}
+
+	fakeClient := fake.NewClientBuilder().WithScheme(networkPolicyTestScheme()).WithRuntimeObjects(
+		intentions("web", "default", "web", "api"),
+		intentions("web", "other", "web"),
+		intentions("db", "default", "db", "web"),
+		intentions("cache", "default", "cache", "*"),
+		intentions("billing", "default", "billing", "api"),
+	).Build()
+	controller := &NetworkPolicyController{
+		Client:  fakeClient,
+		Log:     logrtest.TestLogger{T: t},
+		Context: context.Background(),
+	}
+
+	var svc client.Object = &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	requests := controller.requestsForService(svc)
+	require.ElementsMatch(t, []ctrl.Request{
+		{NamespacedName: types.NamespacedName{Name: "web", Namespace: "default"}},
+		{NamespacedName: types.NamespacedName{Name: "db", Namespace: "default"}},
+		{NamespacedName: types.NamespacedName{Name: "cache", Namespace: "default"}},
+	}, requests)
+}
+
+func TestNetworkPolicyController_requestsForPod(t *testing.T) {
+	fakeClient := fake.NewClientBuilder().WithScheme(networkPolicyTestScheme()).WithRuntimeObjects(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "web"}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+			Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "api"}},
+		},
+		&v1alpha1.ServiceIntentions{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec:       v1alpha1.ServiceIntentionsSpec{Destination: v1alpha1.Destination{Name: "web"}},
+		},
+		&v1alpha1.ServiceIntentions{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+			Spec:       v1alpha1.ServiceIntentionsSpec{Destination: v1alpha1.Destination{Name: "api"}},
+		},
+		&v1alpha1.ServiceIntentions{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "other"},
+			Spec:       v1alpha1.ServiceIntentionsSpec{Destination: v1alpha1.Destination{Name: "web"}},
+		},
+	).Build()
+	controller := &NetworkPolicyController{
+		Client:  fakeClient,
+		Log:     logrtest.TestLogger{T: t},
+		Context: context.Background(),
+	}
+
+	var pod client.Object = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name:      "web-pod",
+		Namespace: "default",
+		Labels:    map[string]string{"app": "web", "version": "v1"},
+	}}
+	require.Equal(t, []ctrl.Request{
+		{NamespacedName: types.NamespacedName{Name: "web", Namespace: "default"}},
+	}, controller.requestsForPod(pod))
+}
+
+func networkPolicyTestScheme() *runtime.Scheme {
+	s := scheme.Scheme
+	s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.ServiceIntentions{}, &v1alpha1.ServiceIntentionsList{})
+	return s
+}
+
+// testGatewayPeer returns the peer of the gateway pods of the release named
+// consul in the consul namespace.
+func testGatewayPeer() networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{
+		PodSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"app": "consul", "release": "consul"},
+			MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      "component",
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   []string{"ingress-gateway", "mesh-gateway", "terminating-gateway"},
+			}},
+		},
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{labelNamespaceName: "consul"}},
+	}
+}
@@ -0,0 +1,229 @@
+package connectinject
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul/api"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// proxyConfigForPod returns the ProxyConfig in the given namespace that
+// applies to the pod, or nil if there is none. If several configs apply,
+// the first one by name is used so that the result is deterministic.
+func proxyConfigForPod(ctx context.Context, c client.Client, pod corev1.Pod, namespace string) (*v1alpha1.ProxyConfig, error) {
+	var list v1alpha1.ProxyConfigList
+	if err := c.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	for i := range list.Items {
+		if list.Items[i].Matches(pod) {
+			return &list.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// sidecarSettings are the settings of the sidecar proxy that ProxyConfig
+// resources and injection profiles set as pod annotations.
+type sidecarSettings struct {
+	Resources        *corev1.ResourceRequirements          `json:"resources,omitempty"`
+	Concurrency      *int                                  `json:"concurrency,omitempty"`
+	EnvoyExtraArgs   []string                              `json:"envoyExtraArgs,omitempty"`
+	Metrics          *v1alpha1.ProxyConfigMetrics          `json:"metrics,omitempty"`
+	TransparentProxy *v1alpha1.ProxyConfigTransparentProxy `json:"transparentProxy,omitempty"`
+}
+
+// applyProxyConfig sets the annotations of the pod that correspond to the
+// sidecar settings of the ProxyConfig. Annotations that are already set on
+// the pod take precedence and are not overwritten. Upstreams are not
+// converted to annotations so that changes to them are picked up by the
+// endpoints controller without recreating the pod.
+func applyProxyConfig(pod *corev1.Pod, proxyConfig *v1alpha1.ProxyConfig) {
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	pod.Annotations[annotationProxyConfig] = proxyConfig.Name
+
+	spec := proxyConfig.Spec
+	applySidecarSettings(pod, sidecarSettings{
+		Resources:        spec.Resources,
+		Concurrency:      spec.Concurrency,
+		EnvoyExtraArgs:   spec.EnvoyExtraArgs,
+		Metrics:          spec.Metrics,
+		TransparentProxy: spec.TransparentProxy,
+	})
+}
+
+// applySidecarSettings sets the annotations of the pod that correspond to
+// the settings. Annotations that are already set on the pod take precedence
+// and are not overwritten.
+func applySidecarSettings(pod *corev1.Pod, settings sidecarSettings) {
+	if pod.Annotations == nil {
+		pod.Annotations = make(map[string]string)
+	}
+	setDefault := func(key, value string) {
+		if _, ok := pod.Annotations[key]; !ok && value != "" {
+			pod.Annotations[key] = value
+		}
+	}
+
+	if settings.Resources != nil {
+		if q, ok := settings.Resources.Requests[corev1.ResourceCPU]; ok {
+			setDefault(annotationSidecarProxyCPURequest, q.String())
+		}
+		if q, ok := settings.Resources.Limits[corev1.ResourceCPU]; ok {
+			setDefault(annotationSidecarProxyCPULimit, q.String())
+		}
+		if q, ok := settings.Resources.Requests[corev1.ResourceMemory]; ok {
+			setDefault(annotationSidecarProxyMemoryRequest, q.String())
+		}
+		if q, ok := settings.Resources.Limits[corev1.ResourceMemory]; ok {
+			setDefault(annotationSidecarProxyMemoryLimit, q.String())
+		}
+	}
+
+	if settings.Concurrency != nil {
+		setDefault(annotationEnvoyProxyConcurrency, strconv.Itoa(*settings.Concurrency))
+	}
+	setDefault(annotationEnvoyExtraArgs, strings.Join(settings.EnvoyExtraArgs, " "))
+
+	if m := settings.Metrics; m != nil {
+		if m.Enabled != nil {
+			setDefault(annotationEnableMetrics, strconv.FormatBool(*m.Enabled))
+		}
+		if m.EnableMerging != nil {
+			setDefault(annotationEnableMetricsMerging, strconv.FormatBool(*m.EnableMerging))
+		}
+		setDefault(annotationMergedMetricsPort, portString(m.MergedMetricsPort))
+		setDefault(annotationPrometheusScrapePort, portString(m.PrometheusScrapePort))
+		setDefault(annotationPrometheusScrapePath, m.PrometheusScrapePath)
+		setDefault(annotationServiceMetricsPort, portString(m.ServiceMetricsPort))
+		setDefault(annotationServiceMetricsPath, m.ServiceMetricsPath)
+	}
+
+	if t := settings.TransparentProxy; t != nil {
+		setDefault(annotationTProxyExcludeInboundPorts, joinInts(t.ExcludeInboundPorts))
+		setDefault(annotationTProxyExcludeOutboundPorts, joinInts(t.ExcludeOutboundPorts))
+		setDefault(annotationTProxyExcludeOutboundCIDRs, strings.Join(t.ExcludeOutboundCIDRs, ","))
+		setDefault(annotationTProxyExcludeUIDs, joinInts(t.ExcludeUIDs))
+	}
+}
+
+// proxyConfigEnvVars returns the environment variables for the upstreams of
+// the ProxyConfig in the same format as containerEnvVars.
+func proxyConfigEnvVars(upstreams []v1alpha1.ProxyConfigUpstream) []corev1.EnvVar {
+	var result []corev1.EnvVar
+	for _, u := range upstreams {
+		name := u.Name
+		if u.PreparedQuery != "" {
+			name = u.PreparedQuery
+		}
+		name = strings.ToUpper(strings.Replace(name, "-", "_", -1))
+		result = append(result, corev1.EnvVar{
+			Name:  fmt.Sprintf("%s_CONNECT_SERVICE_HOST", name),
+			Value: "127.0.0.1",
+		}, corev1.EnvVar{
+			Name:  fmt.Sprintf("%s_CONNECT_SERVICE_PORT", name),
+			Value: strconv.Itoa(u.LocalBindPort),
+		})
+	}
+	return result
+}
+
+// processProxyConfigUpstreams converts the upstreams of the ProxyConfig that
+// applies to the pod into a list of api.Upstream objects.
+func (r *EndpointsController) processProxyConfigUpstreams(pod corev1.Pod) ([]api.Upstream, error) {
+	proxyConfig, err := proxyConfigForPod(r.Context, r.Client, pod, pod.Namespace)
+	if err != nil || proxyConfig == nil {
+		return nil, err
+	}
+
+	var upstreams []api.Upstream
+	for _, u := range proxyConfig.Spec.Upstreams {
+		if u.PreparedQuery != "" {
+			upstreams = append(upstreams, api.Upstream{
+				DestinationType: api.UpstreamDestTypePreparedQuery,
+				DestinationName: u.PreparedQuery,
+				LocalBindPort:   u.LocalBindPort,
+			})
+			continue
+		}
+
+		if u.Datacenter != "" {
+			if err := r.checkMeshGatewayMode(fmt.Sprintf("%s:%d:%s", u.Name, u.LocalBindPort, u.Datacenter)); err != nil {
+				return nil, err
+			}
+		}
+
+		upstream := api.Upstream{
+			DestinationType: api.UpstreamDestTypeService,
+			DestinationPeer: u.Peer,
+			DestinationName: u.Name,
+			Datacenter:      u.Datacenter,
+			LocalBindPort:   u.LocalBindPort,
+		}
+		if r.EnableConsulNamespaces || r.EnableConsulPartitions {
+			upstream.DestinationNamespace = u.Namespace
+			upstream.DestinationPartition = u.Partition
+		}
+		upstreams = append(upstreams, upstream)
+	}
+	return upstreams, nil
+}
+
+// requestsForProxyConfig enqueues the services in the namespace of the
+// ProxyConfig so that their proxies are re-registered with its upstreams.
+func (r *EndpointsController) requestsForProxyConfig(object client.Object) []ctrl.Request {
+	var names []string
+	if r.EnableEndpointSlices {
+		var services corev1.ServiceList
+		if err := r.Client.List(r.Context, &services, client.InNamespace(object.GetNamespace())); err != nil {
+			r.Log.Error(err, "failed to list services", "namespace", object.GetNamespace())
+			return nil
+		}
+		for _, svc := range services.Items {
+			names = append(names, svc.Name)
+		}
+	} else {
+		var endpointsList corev1.EndpointsList
+		if err := r.Client.List(r.Context, &endpointsList, client.InNamespace(object.GetNamespace())); err != nil {
+			r.Log.Error(err, "failed to list endpoints", "namespace", object.GetNamespace())
+			return nil
+		}
+		for _, ep := range endpointsList.Items {
+			names = append(names, ep.Name)
+		}
+	}
+
+	var requests []ctrl.Request
+	for _, name := range names {
+		requests = append(requests, ctrl.Request{
+			NamespacedName: client.ObjectKey{Namespace: object.GetNamespace(), Name: name},
+		})
+	}
+	return requests
+}
+
+func portString(port int) string {
+	if port == 0 {
+		return ""
+	}
+	return strconv.Itoa(port)
+}
+
+func joinInts(values []int) string {
+	strs := make([]string, 0, len(values))
+	for _, v := range values {
+		strs = append(strs, strconv.Itoa(v))
+	}
+	return strings.Join(strs, ",")
+}
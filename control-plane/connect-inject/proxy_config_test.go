@@ -0,0 +1,286 @@
+package connectinject
+
+import (
+	"context"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestProxyConfigForPod(t *testing.T) {
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	pod.Labels["app"] = "web"
+
+	cases := map[string]struct {
+		proxyConfigs []runtime.Object
+		expName      string
+	}{
+		"no proxy configs": {},
+		"no matching proxy config": {
+			proxyConfigs: []runtime.Object{
+				proxyConfig("api", "default", map[string]string{"app": "api"}),
+			},
+		},
+		"matching proxy config in another namespace": {
+			proxyConfigs: []runtime.Object{
+				proxyConfig("web", "other", map[string]string{"app": "web"}),
+			},
+		},
+		"matching proxy config": {
+			proxyConfigs: []runtime.Object{
+				proxyConfig("api", "default", map[string]string{"app": "api"}),
+				proxyConfig("web", "default", map[string]string{"app": "web"}),
+			},
+			expName: "web",
+		},
+		"several matching proxy configs uses the first by name": {
+			proxyConfigs: []runtime.Object{
+				proxyConfig("web-b", "default", map[string]string{"app": "web"}),
+				proxyConfig("web-a", "default", nil),
+			},
+			expName: "web-a",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual, err := proxyConfigForPod(context.Background(), proxyConfigClient(c.proxyConfigs...), *pod, "default")
+			require.NoError(t, err)
+			if c.expName == "" {
+				require.Nil(t, actual)
+			} else {
+				require.NotNil(t, actual)
+				require.Equal(t, c.expName, actual.Name)
+			}
+		})
+	}
+}
+
+func TestApplyProxyConfig(t *testing.T) {
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	pod.Annotations[annotationSidecarProxyCPULimit] = "200m"
+	pod.Annotations[annotationEnableMetrics] = "false"
+
+	concurrency := 2
+	enabled := true
+	pc := proxyConfig("web", "default", nil)
+	pc.Spec.Resources = &corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("50m")},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse("100m"),
+			corev1.ResourceMemory: resource.MustParse("128Mi"),
+		},
+	}
+	pc.Spec.Concurrency = &concurrency
+	pc.Spec.EnvoyExtraArgs = []string{"--log-level", "debug"}
+	pc.Spec.Metrics = &v1alpha1.ProxyConfigMetrics{Enabled: &enabled, PrometheusScrapePort: 20200}
+	pc.Spec.TransparentProxy = &v1alpha1.ProxyConfigTransparentProxy{
+		ExcludeOutboundPorts: []int{5432, 6379},
+		ExcludeOutboundCIDRs: []string{"10.0.0.0/8"},
+	}
+	pc.Spec.Upstreams = []v1alpha1.ProxyConfigUpstream{{Name: "db", LocalBindPort: 1234}}
+
+	applyProxyConfig(pod, pc)
+
+	require.Equal(t, map[string]string{
+		keyInjectStatus:                      injected,
+		annotationProxyConfig:                "web",
+		annotationSidecarProxyCPURequest:     "50m",
+		annotationSidecarProxyCPULimit:       "200m",
+		annotationSidecarProxyMemoryLimit:    "128Mi",
+		annotationEnvoyProxyConcurrency:      "2",
+		annotationEnvoyExtraArgs:             "--log-level debug",
+		annotationEnableMetrics:              "false",
+		annotationPrometheusScrapePort:       "20200",
+		annotationTProxyExcludeOutboundPorts: "5432,6379",
+		annotationTProxyExcludeOutboundCIDRs: "10.0.0.0/8",
+	}, pod.Annotations)
+}
+
+func TestProcessUpstreams_proxyConfig(t *testing.T) {
+	pc := proxyConfig("web", "default", nil)
+	pc.Spec.Upstreams = []v1alpha1.ProxyConfigUpstream{
+		{Name: "db", Namespace: "ns1", LocalBindPort: 1234},
+		{Name: "api", Peer: "peer1", LocalBindPort: 1235},
+		{PreparedQuery: "query", LocalBindPort: 1236},
+	}
+
+	cases := map[string]struct {
+		annotation              string
+		enableProxyConfigs      bool
+		consulNamespacesEnabled bool
+		expected                []api.Upstream
+	}{
+		"proxy configs disabled": {
+			enableProxyConfigs: false,
+			expected:           nil,
+		},
+		"upstreams from proxy config": {
+			enableProxyConfigs: true,
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "db", LocalBindPort: 1234},
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", DestinationPeer: "peer1", LocalBindPort: 1235},
+				{DestinationType: api.UpstreamDestTypePreparedQuery, DestinationName: "query", LocalBindPort: 1236},
+			},
+		},
+		"upstreams from proxy config with namespaces": {
+			enableProxyConfigs:      true,
+			consulNamespacesEnabled: true,
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "db", DestinationNamespace: "ns1", LocalBindPort: 1234},
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "api", DestinationPeer: "peer1", LocalBindPort: 1235},
+				{DestinationType: api.UpstreamDestTypePreparedQuery, DestinationName: "query", LocalBindPort: 1236},
+			},
+		},
+		"annotation overrides proxy config": {
+			annotation:         "upstream1.svc:4321",
+			enableProxyConfigs: true,
+			expected: []api.Upstream{
+				{DestinationType: api.UpstreamDestTypeService, DestinationName: "upstream1", LocalBindPort: 4321},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			if c.annotation != "" {
+				pod.Annotations[annotationUpstreams] = c.annotation
+			}
+
+			ep := &EndpointsController{
+				Client:                 proxyConfigClient(pc),
+				Log:                    logrtest.TestLogger{T: t},
+				AllowK8sNamespacesSet:  mapset.NewSetWith("*"),
+				DenyK8sNamespacesSet:   mapset.NewSetWith(),
+				EnableConsulNamespaces: c.consulNamespacesEnabled,
+				EnableProxyConfigs:     c.enableProxyConfigs,
+				Context:                context.Background(),
+			}
+
+			upstreams, err := ep.processUpstreams(*pod, corev1.Endpoints{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "web",
+					Namespace: "default",
+				},
+			})
+			require.NoError(t, err)
+			require.Equal(t, c.expected, upstreams)
+		})
+	}
+}
+
+func TestRequestsForProxyConfig(t *testing.T) {
+	pc := proxyConfig("web", "default", nil)
+	objs := []runtime.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "other"}},
+		&corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"}},
+	}
+
+	for _, enableEndpointSlices := range []bool{true, false} {
+		ep := &EndpointsController{
+			Client:               proxyConfigClient(objs...),
+			Log:                  logrtest.TestLogger{T: t},
+			EnableEndpointSlices: enableEndpointSlices,
+			Context:              context.Background(),
+		}
+		expName := "db"
+		if enableEndpointSlices {
+			expName = "web"
+		}
+		require.Equal(t, []ctrl.Request{{NamespacedName: client.ObjectKey{Namespace: "default", Name: expName}}},
+			ep.requestsForProxyConfig(pc))
+	}
+}
+
+func TestHandlerHandle_proxyConfig(t *testing.T) {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{
+		Group:   "",
+		Version: "v1",
+	}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	concurrency := 4
+	pc := proxyConfig("web", "default", map[string]string{"app": "web"})
+	pc.Spec.Concurrency = &concurrency
+	pc.Spec.EnvoyExtraArgs = []string{"--log-level debug"}
+	pc.Spec.Upstreams = []v1alpha1.ProxyConfigUpstream{{Name: "db", LocalBindPort: 1234}}
+
+	w := MeshWebhook{
+		Log:                   logrtest.TestLogger{T: t},
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSet(),
+		Clientset:             defaultTestClientWithNamespace(),
+		Client:                proxyConfigClient(pc),
+		EnableProxyConfigs:    true,
+		decoder:               decoder,
+	}
+
+	resp := w.Handle(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Namespace: "default",
+			Object: encodeRaw(t, &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{"app": "web"},
+					Annotations: map[string]string{
+						annotationEnvoyExtraArgs: "--log-level info",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "web"}},
+				},
+			}),
+		},
+	})
+	require.True(t, resp.Allowed, resp.Result)
+
+	patches := make(map[string]interface{})
+	for _, p := range resp.Patches {
+		patches[p.Path] = p.Value
+	}
+	require.Equal(t, "web", patches["/metadata/annotations/"+escapeJSONPointer(annotationProxyConfig)])
+	require.Equal(t, "4", patches["/metadata/annotations/"+escapeJSONPointer(annotationEnvoyProxyConcurrency)])
+	// The annotation on the pod takes precedence over the proxy config.
+	require.NotContains(t, patches, "/metadata/annotations/"+escapeJSONPointer(annotationEnvoyExtraArgs))
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"name": "DB_CONNECT_SERVICE_HOST", "value": "127.0.0.1"},
+		map[string]interface{}{"name": "DB_CONNECT_SERVICE_PORT", "value": "1234"},
+	}, patches["/spec/containers/0/env"])
+}
+
+func proxyConfig(name, namespace string, selector map[string]string) *v1alpha1.ProxyConfig {
+	pc := &v1alpha1.ProxyConfig{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+	if selector != nil {
+		pc.Spec.Selector = &metav1.LabelSelector{MatchLabels: selector}
+	}
+	return pc
+}
+
+func proxyConfigClient(objs ...runtime.Object) client.Client {
+	s := scheme.Scheme
+	s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.ProxyConfig{}, &v1alpha1.ProxyConfigList{})
+	return fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(objs...).Build()
+}
@@ -0,0 +1,217 @@
+package connectinject
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// conditionRegistered is the type of the pod condition that reports
+	// whether the services of the pod are registered with Consul. It can be
+	// used as a readiness gate so that pods only become ready once they are
+	// in the Consul catalog.
+	conditionRegistered corev1.PodConditionType = "consul.hashicorp.com/registered"
+
+	// Reasons of the registration events and of the registered condition.
+	eventReasonRegistered           = "Registered"
+	eventReasonRegistrationFailed   = "RegistrationFailed"
+	eventReasonDeregistered         = "Deregistered"
+	eventReasonDeregistrationFailed = "DeregistrationFailed"
+
+	// conditionReasonNotSelected is the reason of the registered condition
+	// of pods that aren't selected by any Kubernetes service, so they are
+	// never registered with Consul.
+	conditionReasonNotSelected = "NotSelected"
+)
+
+// recordRegistration records the result of registering the pod as an
+// instance of the service with Consul. It sets the registered condition of
+// the pod and emits an event on the pod and on the Kubernetes service when
+// the condition changes, so that repeated reconciles of a registered pod
+// don't create new events. The condition message doesn't include the error
+// so that it doesn't change with every failed attempt; the caller logs it.
+func (r *EndpointsController) recordRegistration(ctx context.Context, pod corev1.Pod, serviceEndpoints corev1.Endpoints, registrationErr error) {
+	if !r.EnableRegistrationStatus {
+		return
+	}
+
+	serviceName := getServiceName(pod, serviceEndpoints)
+	condition := corev1.PodCondition{
+		Type:    conditionRegistered,
+		Status:  corev1.ConditionTrue,
+		Reason:  eventReasonRegistered,
+		Message: fmt.Sprintf("Registered service %q with Consul", serviceName),
+	}
+	eventType := corev1.EventTypeNormal
+	if registrationErr != nil {
+		condition.Status = corev1.ConditionFalse
+		condition.Reason = eventReasonRegistrationFailed
+		condition.Message = fmt.Sprintf("Failed to register service %q with Consul", serviceName)
+		eventType = corev1.EventTypeWarning
+	}
+
+	if !setRegisteredCondition(ctx, r.Client, r.Log, pod, condition) {
+		return
+	}
+
+	if r.Recorder != nil {
+		r.Recorder.Event(&pod, eventType, condition.Reason, condition.Message)
+		var svc corev1.Service
+		err := r.Client.Get(ctx, types.NamespacedName{Name: serviceEndpoints.Name, Namespace: serviceEndpoints.Namespace}, &svc)
+		if err == nil {
+			r.Recorder.Eventf(&svc, eventType, condition.Reason, "%s for pod %q", condition.Message, pod.Name)
+		} else if !k8serrors.IsNotFound(err) {
+			r.Log.Error(err, "failed to get service", "name", serviceEndpoints.Name, "ns", serviceEndpoints.Namespace)
+		}
+	}
+}
+
+// setRegisteredCondition sets the registered condition of the pod to the
+// given condition. It returns false without updating the pod if the pod
+// already has the same condition.
+func setRegisteredCondition(ctx context.Context, c client.Client, log logr.Logger, pod corev1.Pod, condition corev1.PodCondition) bool {
+	for _, existing := range pod.Status.Conditions {
+		if existing.Type == condition.Type && existing.Status == condition.Status && existing.Reason == condition.Reason && existing.Message == condition.Message {
+			return false
+		}
+	}
+
+	condition.LastTransitionTime = metav1.Now()
+	updated := pod.DeepCopy()
+	found := false
+	for i, existing := range updated.Status.Conditions {
+		if existing.Type == condition.Type {
+			if existing.Status == condition.Status {
+				condition.LastTransitionTime = existing.LastTransitionTime
+			}
+			updated.Status.Conditions[i] = condition
+			found = true
+		}
+	}
+	if !found {
+		updated.Status.Conditions = append(updated.Status.Conditions, condition)
+	}
+
+	if err := c.Status().Patch(ctx, updated, client.StrategicMergeFrom(&pod)); err != nil {
+		log.Error(err, "failed to update registered condition of pod", "name", pod.Name, "ns", pod.Namespace)
+	}
+	return true
+}
+
+// recordDeregistration emits an event on the Kubernetes service when one of
+// its instances is deregistered from Consul or fails to be deregistered.
+// No event is emitted if the service no longer exists.
+func (r *EndpointsController) recordDeregistration(ctx context.Context, k8sSvcName, k8sSvcNamespace, serviceID string, deregistrationErr error) {
+	if !r.EnableRegistrationStatus || r.Recorder == nil {
+		return
+	}
+
+	var svc corev1.Service
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: k8sSvcName, Namespace: k8sSvcNamespace}, &svc); err != nil {
+		if !k8serrors.IsNotFound(err) {
+			r.Log.Error(err, "failed to get service", "name", k8sSvcName, "ns", k8sSvcNamespace)
+		}
+		return
+	}
+
+	if deregistrationErr != nil {
+		r.Recorder.Eventf(&svc, corev1.EventTypeWarning, eventReasonDeregistrationFailed,
+			"Failed to deregister service instance %q from Consul: %s", serviceID, deregistrationErr)
+		return
+	}
+	r.Recorder.Eventf(&svc, corev1.EventTypeNormal, eventReasonDeregistered,
+		"Deregistered service instance %q from Consul", serviceID)
+}
+
+// RegistrationGateController sets the registered condition of injected pods
+// with the registered readiness gate that aren't selected by any Kubernetes
+// service. Such pods are never in an Endpoints object, so the endpoints
+// controller never sets the condition and they would otherwise never become
+// ready.
+type RegistrationGateController struct {
+	client.Client
+	Log logr.Logger
+}
+
+func (r *RegistrationGateController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var pod corev1.Pod
+	if err := r.Client.Get(ctx, req.NamespacedName, &pod); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if !hasBeenInjected(pod) || !hasRegisteredReadinessGate(pod) || !pod.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+	// The condition is owned by the endpoints controller once it's set.
+	for _, c := range pod.Status.Conditions {
+		if c.Type == conditionRegistered {
+			return ctrl.Result{}, nil
+		}
+	}
+
+	selected, err := r.isSelectedByService(ctx, pod)
+	if err != nil {
+		r.Log.Error(err, "failed to list services", "ns", pod.Namespace)
+		return ctrl.Result{}, err
+	}
+	if selected {
+		return ctrl.Result{}, nil
+	}
+
+	setRegisteredCondition(ctx, r.Client, r.Log, pod, corev1.PodCondition{
+		Type:    conditionRegistered,
+		Status:  corev1.ConditionTrue,
+		Reason:  conditionReasonNotSelected,
+		Message: "Pod is not selected by any service, so there is nothing to register with Consul",
+	})
+	return ctrl.Result{}, nil
+}
+
+// isSelectedByService returns true if a Kubernetes service in the namespace
+// of the pod selects it. If the pod sets the kubernetes-service annotation,
+// only that service is considered since the endpoints controller ignores
+// the pod in the Endpoints of other services.
+func (r *RegistrationGateController) isSelectedByService(ctx context.Context, pod corev1.Pod) (bool, error) {
+	var services corev1.ServiceList
+	if err := r.Client.List(ctx, &services, client.InNamespace(pod.Namespace)); err != nil {
+		return false, err
+	}
+	explicitService, hasExplicitService := pod.Annotations[annotationKubernetesService]
+	for _, svc := range services.Items {
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		if hasExplicitService && svc.Name != explicitService {
+			continue
+		}
+		if labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// hasRegisteredReadinessGate returns true if the pod has the registered
+// readiness gate.
+func hasRegisteredReadinessGate(pod corev1.Pod) bool {
+	for _, gate := range pod.Spec.ReadinessGates {
+		if gate.ConditionType == conditionRegistered {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RegistrationGateController) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&corev1.Pod{}).
+		Complete(r)
+}
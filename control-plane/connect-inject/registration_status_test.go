@@ -0,0 +1,190 @@
+package connectinject
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestRecordRegistration(t *testing.T) {
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	endpoints := corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(pod, svc).Build()
+	recorder := record.NewFakeRecorder(10)
+	ep := &EndpointsController{
+		Client:                   fakeClient,
+		Log:                      logrtest.TestLogger{T: t},
+		EnableRegistrationStatus: true,
+		Recorder:                 recorder,
+	}
+	ctx := context.Background()
+
+	getPod := func() corev1.Pod {
+		var p corev1.Pod
+		require.NoError(t, fakeClient.Get(ctx, types.NamespacedName{Name: "pod1", Namespace: "default"}, &p))
+		return p
+	}
+	registeredConditions := func() []corev1.PodCondition {
+		var conditions []corev1.PodCondition
+		for _, c := range getPod().Status.Conditions {
+			if c.Type == conditionRegistered {
+				conditions = append(conditions, c)
+			}
+		}
+		return conditions
+	}
+
+	// A successful registration sets the condition and emits an event on
+	// the pod and the service.
+	ep.recordRegistration(ctx, getPod(), endpoints, nil)
+	conditions := registeredConditions()
+	require.Len(t, conditions, 1)
+	require.Equal(t, corev1.ConditionTrue, conditions[0].Status)
+	require.Equal(t, eventReasonRegistered, conditions[0].Reason)
+	require.Equal(t, `Normal Registered Registered service "web" with Consul`, <-recorder.Events)
+	require.Equal(t, `Normal Registered Registered service "web" with Consul for pod "pod1"`, <-recorder.Events)
+
+	// Registering again doesn't emit another event.
+	ep.recordRegistration(ctx, getPod(), endpoints, nil)
+	require.Len(t, recorder.Events, 0)
+
+	// A failed registration updates the condition and emits a warning. The
+	// message doesn't include the error.
+	ep.recordRegistration(ctx, getPod(), endpoints, errors.New("agent unreachable"))
+	conditions = registeredConditions()
+	require.Len(t, conditions, 1)
+	require.Equal(t, corev1.ConditionFalse, conditions[0].Status)
+	require.Equal(t, eventReasonRegistrationFailed, conditions[0].Reason)
+	require.Equal(t, `Failed to register service "web" with Consul`, conditions[0].Message)
+	require.Equal(t, `Warning RegistrationFailed Failed to register service "web" with Consul`, <-recorder.Events)
+	require.Equal(t, `Warning RegistrationFailed Failed to register service "web" with Consul for pod "pod1"`, <-recorder.Events)
+
+	// Failing again with a different error doesn't emit another event.
+	ep.recordRegistration(ctx, getPod(), endpoints, errors.New("agent still unreachable"))
+	require.Len(t, recorder.Events, 0)
+}
+
+func TestRecordRegistration_Disabled(t *testing.T) {
+	pod := createPod("pod1", "1.2.3.4", true, true)
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(pod).Build()
+	recorder := record.NewFakeRecorder(10)
+	ep := &EndpointsController{
+		Client:   fakeClient,
+		Log:      logrtest.TestLogger{T: t},
+		Recorder: recorder,
+	}
+
+	ep.recordRegistration(context.Background(), *pod, corev1.Endpoints{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}, nil)
+	var p corev1.Pod
+	require.NoError(t, fakeClient.Get(context.Background(), types.NamespacedName{Name: "pod1", Namespace: "default"}, &p))
+	require.Equal(t, pod.Status.Conditions, p.Status.Conditions)
+	require.Len(t, recorder.Events, 0)
+}
+
+func TestRecordDeregistration(t *testing.T) {
+	svc := &corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	fakeClient := fake.NewClientBuilder().WithRuntimeObjects(svc).Build()
+	recorder := record.NewFakeRecorder(10)
+	ep := &EndpointsController{
+		Client:                   fakeClient,
+		Log:                      logrtest.TestLogger{T: t},
+		EnableRegistrationStatus: true,
+		Recorder:                 recorder,
+	}
+	ctx := context.Background()
+
+	ep.recordDeregistration(ctx, "web", "default", "pod1-web", nil)
+	require.Equal(t, `Normal Deregistered Deregistered service instance "pod1-web" from Consul`, <-recorder.Events)
+
+	ep.recordDeregistration(ctx, "web", "default", "pod1-web", errors.New("agent unreachable"))
+	require.Equal(t, `Warning DeregistrationFailed Failed to deregister service instance "pod1-web" from Consul: agent unreachable`, <-recorder.Events)
+
+	// No events are emitted for services that no longer exist.
+	ep.recordDeregistration(ctx, "deleted", "default", "pod1-deleted", nil)
+	require.Len(t, recorder.Events, 0)
+}
+
+func TestRegistrationGateController(t *testing.T) {
+	cases := map[string]struct {
+		services      []runtime.Object
+		annotations   map[string]string
+		readinessGate bool
+		expCondition  bool
+	}{
+		"no services": {
+			readinessGate: true,
+			expCondition:  true,
+		},
+		"without readiness gate": {
+			readinessGate: false,
+			expCondition:  false,
+		},
+		"selected by a service": {
+			services:      []runtime.Object{serviceSelecting("web", map[string]string{"app": "web"})},
+			readinessGate: true,
+			expCondition:  false,
+		},
+		"services select other pods": {
+			services:      []runtime.Object{serviceSelecting("api", map[string]string{"app": "api"})},
+			readinessGate: true,
+			expCondition:  true,
+		},
+		"only selected by a service other than the explicit service": {
+			services:      []runtime.Object{serviceSelecting("web", map[string]string{"app": "web"})},
+			annotations:   map[string]string{annotationKubernetesService: "web-admin"},
+			readinessGate: true,
+			expCondition:  true,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			pod := createPod("pod1", "1.2.3.4", true, true)
+			pod.Labels["app"] = "web"
+			for k, v := range c.annotations {
+				pod.Annotations[k] = v
+			}
+			if c.readinessGate {
+				pod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: conditionRegistered}}
+			}
+			fakeClient := fake.NewClientBuilder().WithRuntimeObjects(append(c.services, pod)...).Build()
+			controller := &RegistrationGateController{
+				Client: fakeClient,
+				Log:    logrtest.TestLogger{T: t},
+			}
+
+			namespacedName := types.NamespacedName{Name: "pod1", Namespace: "default"}
+			_, err := controller.Reconcile(context.Background(), ctrl.Request{NamespacedName: namespacedName})
+			require.NoError(t, err)
+
+			var p corev1.Pod
+			require.NoError(t, fakeClient.Get(context.Background(), namespacedName, &p))
+			var found bool
+			for _, cond := range p.Status.Conditions {
+				if cond.Type == conditionRegistered {
+					found = true
+					require.Equal(t, corev1.ConditionTrue, cond.Status)
+					require.Equal(t, conditionReasonNotSelected, cond.Reason)
+				}
+			}
+			require.Equal(t, c.expCondition, found)
+		})
+	}
+}
+
+func serviceSelecting(name string, selector map[string]string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Selector: selector},
+	}
+}
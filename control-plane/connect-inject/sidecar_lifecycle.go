@@ -0,0 +1,163 @@
+package connectinject
+
+import (
+	"fmt"
+	"strconv"
+
+	"gomodules.xyz/jsonpatch/v2"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// lifecycleBinary is where connect-init copies the consul-k8s-control-plane
+	// binary to so that the lifecycle hooks of the Envoy sidecar can run it.
+	lifecycleBinary = "/consul/connect-inject/consul-k8s-control-plane"
+
+	// annotationDefaultContainer is the annotation kubectl reads to select
+	// the container of kubectl logs and kubectl exec.
+	annotationDefaultContainer = "kubectl.kubernetes.io/default-container"
+)
+
+// sidecarLifecycle configures how the injected sidecars are started and
+// stopped relative to the application containers.
+type sidecarLifecycle struct {
+	// shutdownGracePeriodSeconds is how long the Envoy sidecar drains its
+	// inbound connections for before it is stopped. Zero disables draining.
+	shutdownGracePeriodSeconds int
+	// holdApplicationUntilReady starts the application containers only once
+	// the Envoy sidecar is ready.
+	holdApplicationUntilReady bool
+	// native injects the sidecars as native sidecars.
+	native bool
+}
+
+// sidecarLifecycle returns the sidecar lifecycle of the pod from its
+// annotations, falling back to the defaults of the webhook.
+func (w *MeshWebhook) sidecarLifecycle(pod corev1.Pod) (sidecarLifecycle, error) {
+	lifecycle := sidecarLifecycle{
+		shutdownGracePeriodSeconds: w.DefaultSidecarProxyShutdownGracePeriodSeconds,
+		holdApplicationUntilReady:  w.DefaultSidecarProxyHoldApplicationUntilReady,
+		native:                     w.EnableNativeSidecars,
+	}
+	if raw, ok := pod.Annotations[annotationSidecarProxyShutdownGracePeriod]; ok {
+		val, err := strconv.Atoi(raw)
+		if err != nil || val < 0 {
+			return sidecarLifecycle{}, fmt.Errorf("unable to parse annotation %q: must be an integer of 0 or greater", annotationSidecarProxyShutdownGracePeriod)
+		}
+		lifecycle.shutdownGracePeriodSeconds = val
+	}
+	if raw, ok := pod.Annotations[annotationSidecarProxyHoldApplication]; ok {
+		val, err := strconv.ParseBool(raw)
+		if err != nil {
+			return sidecarLifecycle{}, fmt.Errorf("unable to parse annotation %q: %s", annotationSidecarProxyHoldApplication, err)
+		}
+		lifecycle.holdApplicationUntilReady = val
+	}
+	return lifecycle, nil
+}
+
+// needsBinary returns true if the lifecycle hooks of the Envoy sidecar run
+// the consul-k8s-control-plane binary, which connect-init then has to copy to
+// the shared volume.
+func (l sidecarLifecycle) needsBinary() bool {
+	return !l.native && (l.shutdownGracePeriodSeconds > 0 || l.holdApplicationUntilReady)
+}
+
+// configureEnvoySidecar adds the lifecycle hooks or probes to the Envoy
+// sidecar. Native sidecars are stopped only after the application
+// containers have exited, so they don't need to drain their connections, and
+// Kubernetes holds the start of the application containers until their
+// startup probe succeeds.
+func (l sidecarLifecycle) configureEnvoySidecar(container *corev1.Container, mpi multiPortInfo) {
+	if l.native {
+		if l.holdApplicationUntilReady {
+			// Envoy only opens its public listener once it has received its
+			// configuration.
+			container.StartupProbe = &corev1.Probe{
+				Handler: corev1.Handler{
+					TCPSocket: &corev1.TCPSocketAction{
+						Port: intstr.FromInt(envoyInboundPort + mpi.serviceIndex),
+					},
+				},
+				PeriodSeconds:    1,
+				FailureThreshold: 60,
+			}
+		}
+		return
+	}
+
+	adminAddr := fmt.Sprintf("-envoy-admin-addr=127.0.0.1:%d", 19000+mpi.serviceIndex)
+	if l.holdApplicationUntilReady || l.shutdownGracePeriodSeconds > 0 {
+		container.Lifecycle = &corev1.Lifecycle{}
+	}
+	if l.holdApplicationUntilReady {
+		// The kubelet starts the next container of the pod only once the
+		// postStart hook of the previous one has completed.
+		container.Lifecycle.PostStart = &corev1.Handler{
+			Exec: &corev1.ExecAction{
+				Command: []string{lifecycleBinary, "envoy-lifecycle", "-wait-ready", adminAddr},
+			},
+		}
+	}
+	if l.shutdownGracePeriodSeconds > 0 {
+		container.Lifecycle.PreStop = &corev1.Handler{
+			Exec: &corev1.ExecAction{
+				Command: []string{
+					lifecycleBinary, "envoy-lifecycle", "-drain",
+					fmt.Sprintf("-timeout=%ds", l.shutdownGracePeriodSeconds),
+					fmt.Sprintf("-inbound-port=%d", envoyInboundPort+mpi.serviceIndex),
+					adminAddr,
+				},
+			},
+		}
+	}
+}
+
+// placeSidecars moves the injected sidecars, which are the containers of the
+// pod from index firstSidecar on, to where Kubernetes starts them in the
+// right order. Native sidecars are moved to the end of the init containers,
+// after the connect-inject init containers that generate their bootstrap.
+// To hold the application, the sidecars are moved in front of the
+// application containers. It returns the names of the native sidecars.
+func (l sidecarLifecycle) placeSidecars(pod *corev1.Pod, firstSidecar int) []string {
+	sidecars := append([]corev1.Container{}, pod.Spec.Containers[firstSidecar:]...)
+	apps := append([]corev1.Container{}, pod.Spec.Containers[:firstSidecar]...)
+
+	if l.native {
+		var names []string
+		for _, c := range sidecars {
+			names = append(names, c.Name)
+		}
+		pod.Spec.InitContainers = append(pod.Spec.InitContainers, sidecars...)
+		pod.Spec.Containers = apps
+		return names
+	}
+
+	if l.holdApplicationUntilReady {
+		// Keep the application the default container of kubectl.
+		if _, ok := pod.Annotations[annotationDefaultContainer]; !ok && len(apps) > 0 {
+			pod.Annotations[annotationDefaultContainer] = apps[0].Name
+		}
+		pod.Spec.Containers = append(sidecars, apps...)
+	}
+	return nil
+}
+
+// nativeSidecarPatches returns the patches that set the restartPolicy of the
+// native sidecars to Always. The Kubernetes API version this is built with
+// doesn't have the restartPolicy field of containers, so it can't be set on
+// the pod before the patches are created.
+func nativeSidecarPatches(pod corev1.Pod, names []string) []jsonpatch.JsonPatchOperation {
+	var patches []jsonpatch.JsonPatchOperation
+	for i, c := range pod.Spec.InitContainers {
+		if sliceContains(names, c.Name) {
+			patches = append(patches, jsonpatch.JsonPatchOperation{
+				Operation: "add",
+				Path:      fmt.Sprintf("/spec/initContainers/%d/restartPolicy", i),
+				Value:     "Always",
+			})
+		}
+	}
+	return patches
+}
@@ -0,0 +1,230 @@
+package connectinject
+
+import (
+	"context"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set"
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/stretchr/testify/require"
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/kubernetes/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+func TestHandlerEnvoySidecar_lifecycle(t *testing.T) {
+	cases := map[string]struct {
+		webhook        MeshWebhook
+		annotations    map[string]string
+		mpi            multiPortInfo
+		expLifecycle   *corev1.Lifecycle
+		expStartup     *corev1.Probe
+		expNeedsBinary bool
+		expErr         string
+	}{
+		"disabled by default": {},
+		"defaults": {
+			webhook: MeshWebhook{
+				DefaultSidecarProxyShutdownGracePeriodSeconds: 15,
+				DefaultSidecarProxyHoldApplicationUntilReady:  true,
+			},
+			expLifecycle: &corev1.Lifecycle{
+				PostStart: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{
+					lifecycleBinary, "envoy-lifecycle", "-wait-ready", "-envoy-admin-addr=127.0.0.1:19000",
+				}}},
+				PreStop: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{
+					lifecycleBinary, "envoy-lifecycle", "-drain", "-timeout=15s", "-inbound-port=20000", "-envoy-admin-addr=127.0.0.1:19000",
+				}}},
+			},
+			expNeedsBinary: true,
+		},
+		"annotations override the defaults": {
+			webhook: MeshWebhook{
+				DefaultSidecarProxyShutdownGracePeriodSeconds: 15,
+				DefaultSidecarProxyHoldApplicationUntilReady:  true,
+			},
+			annotations: map[string]string{
+				annotationSidecarProxyShutdownGracePeriod: "0",
+				annotationSidecarProxyHoldApplication:     "false",
+			},
+		},
+		"multi port": {
+			annotations: map[string]string{
+				annotationSidecarProxyShutdownGracePeriod: "30",
+			},
+			mpi: multiPortInfo{serviceName: "web-admin", serviceIndex: 1},
+			expLifecycle: &corev1.Lifecycle{
+				PreStop: &corev1.Handler{Exec: &corev1.ExecAction{Command: []string{
+					lifecycleBinary, "envoy-lifecycle", "-drain", "-timeout=30s", "-inbound-port=20001", "-envoy-admin-addr=127.0.0.1:19001",
+				}}},
+			},
+			expNeedsBinary: true,
+		},
+		"native sidecar": {
+			webhook: MeshWebhook{
+				DefaultSidecarProxyShutdownGracePeriodSeconds: 15,
+				EnableNativeSidecars:                          true,
+			},
+			annotations: map[string]string{
+				annotationSidecarProxyHoldApplication: "true",
+			},
+			expStartup: &corev1.Probe{
+				Handler: corev1.Handler{
+					TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(20000)},
+				},
+				PeriodSeconds:    1,
+				FailureThreshold: 60,
+			},
+		},
+		"invalid grace period": {
+			annotations: map[string]string{
+				annotationSidecarProxyShutdownGracePeriod: "-1",
+			},
+			expErr: `unable to parse annotation "consul.hashicorp.com/sidecar-proxy-shutdown-grace-period-seconds": must be an integer of 0 or greater`,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			annotations := map[string]string{annotationService: "web"}
+			for k, v := range c.annotations {
+				annotations[k] = v
+			}
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: annotations},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "web"}},
+				},
+			}
+
+			container, err := c.webhook.envoySidecar(testNS, pod, c.mpi)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.expLifecycle, container.Lifecycle)
+			require.Equal(t, c.expStartup, container.StartupProbe)
+
+			initContainer, err := c.webhook.containerInit(testNS, pod, c.mpi)
+			require.NoError(t, err)
+			if c.expNeedsBinary {
+				require.Contains(t, initContainer.Command, "-lifecycle-binary="+lifecycleBinary)
+			} else {
+				require.NotContains(t, initContainer.Command, "-lifecycle-binary="+lifecycleBinary)
+			}
+		})
+	}
+}
+
+func TestPlaceSidecars(t *testing.T) {
+	cases := map[string]struct {
+		lifecycle           sidecarLifecycle
+		annotations         map[string]string
+		expInit             []string
+		expContainers       []string
+		expNative           []string
+		expDefaultContainer string
+	}{
+		"sidecars stay after the application": {
+			expInit:       []string{"migrate", "consul-connect-inject-init"},
+			expContainers: []string{"web", "logs", "envoy-sidecar", "consul-sidecar"},
+		},
+		"hold application": {
+			lifecycle:           sidecarLifecycle{holdApplicationUntilReady: true},
+			expInit:             []string{"migrate", "consul-connect-inject-init"},
+			expContainers:       []string{"envoy-sidecar", "consul-sidecar", "web", "logs"},
+			expDefaultContainer: "web",
+		},
+		"hold application keeps the default container": {
+			lifecycle:           sidecarLifecycle{holdApplicationUntilReady: true},
+			annotations:         map[string]string{annotationDefaultContainer: "logs"},
+			expInit:             []string{"migrate", "consul-connect-inject-init"},
+			expContainers:       []string{"envoy-sidecar", "consul-sidecar", "web", "logs"},
+			expDefaultContainer: "logs",
+		},
+		"native sidecars": {
+			lifecycle:     sidecarLifecycle{native: true, holdApplicationUntilReady: true},
+			expInit:       []string{"migrate", "consul-connect-inject-init", "envoy-sidecar", "consul-sidecar"},
+			expContainers: []string{"web", "logs"},
+			expNative:     []string{"envoy-sidecar", "consul-sidecar"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			annotations := map[string]string{}
+			for k, v := range c.annotations {
+				annotations[k] = v
+			}
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Annotations: annotations},
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: "migrate"}, {Name: "consul-connect-inject-init"}},
+					Containers:     []corev1.Container{{Name: "web"}, {Name: "logs"}, {Name: "envoy-sidecar"}, {Name: "consul-sidecar"}},
+				},
+			}
+
+			native := c.lifecycle.placeSidecars(&pod, 2)
+			require.Equal(t, c.expNative, native)
+			var initNames, containerNames []string
+			for _, ic := range pod.Spec.InitContainers {
+				initNames = append(initNames, ic.Name)
+			}
+			for _, ic := range pod.Spec.Containers {
+				containerNames = append(containerNames, ic.Name)
+			}
+			require.Equal(t, c.expInit, initNames)
+			require.Equal(t, c.expContainers, containerNames)
+			require.Equal(t, c.expDefaultContainer, pod.Annotations[annotationDefaultContainer])
+		})
+	}
+}
+
+func TestHandlerHandle_nativeSidecars(t *testing.T) {
+	s := runtime.NewScheme()
+	s.AddKnownTypes(schema.GroupVersion{
+		Group:   "",
+		Version: "v1",
+	}, &corev1.Pod{})
+	decoder, err := admission.NewDecoder(s)
+	require.NoError(t, err)
+
+	w := MeshWebhook{
+		Log:                   logrtest.TestLogger{T: t},
+		AllowK8sNamespacesSet: mapset.NewSetWith("*"),
+		DenyK8sNamespacesSet:  mapset.NewSet(),
+		Clientset:             fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}),
+		EnableNativeSidecars:  true,
+		decoder:               decoder,
+	}
+
+	resp := w.Handle(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Namespace: "default",
+			Object: encodeRaw(t, &corev1.Pod{
+				Spec: corev1.PodSpec{
+					InitContainers: []corev1.Container{{Name: "migrate"}},
+					Containers:     []corev1.Container{{Name: "web"}},
+				},
+			}),
+		},
+	})
+	require.True(t, resp.Allowed, resp.Result)
+
+	var restartPolicyPatches []jsonpatch.JsonPatchOperation
+	for _, p := range resp.Patches {
+		require.NotEqual(t, "/spec/containers/1", p.Path, "the Envoy sidecar must not be injected as a container")
+		if p.Path == "/spec/initContainers/2/restartPolicy" || p.Path == "/spec/initContainers/1/restartPolicy" {
+			restartPolicyPatches = append(restartPolicyPatches, p)
+		}
+	}
+	// The Envoy sidecar is started after the connect-inject init container.
+	require.Equal(t, []jsonpatch.JsonPatchOperation{
+		{Operation: "add", Path: "/spec/initContainers/2/restartPolicy", Value: "Always"},
+	}, restartPolicyPatches)
+}
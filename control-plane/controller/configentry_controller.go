@@ -3,7 +3,9 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -13,14 +15,18 @@ import (
 	capi "github.com/hashicorp/consul/api"
 	"golang.org/x/time/rate"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 const (
@@ -28,6 +34,9 @@ const (
 	ConsulAgentError             = "ConsulAgentError"
 	ExternallyManagedConfigError = "ExternallyManagedConfigError"
 	MigrationFailedError         = "MigrationFailedError"
+	ConfigEntryModifiedError     = "ConfigEntryModifiedInConsul"
+	ConfigEntryDeletedError      = "ConfigEntryDeletedInConsul"
+	ReferenceError               = "ReferenceError"
 )
 
 // Controller is implemented by CRD-specific controllers. It is used by
@@ -46,6 +55,22 @@ type Controller interface {
 	Logger(types.NamespacedName) logr.Logger
 }
 
+// ReferenceResolver is implemented by the controllers of resources that
+// reference other Kubernetes resources, e.g. the Secret of an inline
+// certificate, so that they are read before the config entry is written
+// to Consul.
+type ReferenceResolver interface {
+	// ResolveReferences reads the resources referenced by configEntry and
+	// sets their contents on it.
+	ResolveReferences(ctx context.Context, configEntry common.ConfigEntryResource) error
+}
+
+// watch is an additional source of reconcile requests for a controller.
+type watch struct {
+	source  source.Source
+	handler handler.EventHandler
+}
+
 // ConfigEntryController is a generic controller that is used to reconcile
 // all config entry types, e.g. ServiceDefaults, ServiceResolver, etc, since
 // they share the same reconcile behaviour.
@@ -79,6 +104,13 @@ type ConfigEntryController struct {
 	// any created Consul namespaces to allow cross namespace service discovery.
 	// Only necessary if ACLs are enabled.
 	CrossNSACLPolicy string
+
+	// EnableDriftDetection watches the config entries in Consul with blocking
+	// queries and reconciles the resources of a kind whenever the config
+	// entries of that kind change in Consul, so that config entries changed
+	// or deleted outside of Kubernetes are restored or reported depending on
+	// the drift policy of the resource.
+	EnableDriftDetection bool
 }
 
 // ReconcileEntry reconciles an update to a resource. CRD-specific controller's
@@ -97,6 +129,14 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 		return ctrl.Result{}, err
 	}
 
+	// The referenced resources aren't needed to delete the config entry and
+	// might have been deleted already.
+	if resolver, ok := crdCtrl.(ReferenceResolver); ok && configEntry.GetDeletionTimestamp().IsZero() {
+		if err := resolver.ResolveReferences(ctx, configEntry); err != nil {
+			return r.syncFailed(ctx, logger, crdCtrl, configEntry, ReferenceError, err)
+		}
+	}
+
 	consulEntry := configEntry.ToConsul(r.DatacenterName)
 
 	if configEntry.GetDeletionTimestamp().IsZero() {
@@ -156,6 +196,13 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 	// If a config entry with this name does not exist
 	if isNotFoundErr(err) {
 		logger.Info("config entry not found in consul")
+		if syncedAtCurrentGeneration(configEntry) {
+			logger.Info("config entry has been deleted in consul")
+			if driftPolicy(configEntry) == common.DriftPolicyReport {
+				return r.syncDrifted(ctx, crdCtrl, configEntry, ConfigEntryDeletedError,
+					"config entry has been deleted in Consul")
+			}
+		}
 
 		// If Consul namespaces are enabled we may need to create the
 		// destination consul namespace first.
@@ -180,6 +227,7 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 				fmt.Errorf("writing config entry to consul: %w", err))
 		}
 		logger.Info("config entry created", "request-time", writeMeta.RequestTime)
+		r.refreshConsulStatus(logger, configEntry, nil)
 		return r.syncSuccessful(ctx, crdCtrl, configEntry)
 	}
 
@@ -192,10 +240,15 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 	requiresMigration := false
 	sourceDatacenter := entry.GetMeta()[common.DatacenterKey]
 
+	// A config entry written in Consul directly, e.g. with consul config
+	// write, loses the metadata of the datacenter. If the resource hasn't
+	// changed since it was synced, the config entry is ours and has drifted.
+	ownedEntryOverwritten := sourceDatacenter == "" && syncedAtCurrentGeneration(configEntry)
+
 	// Check if the config entry is managed by our datacenter.
 	// Do not process resource if the entry was not created within our datacenter
 	// as it was created in a different cluster which will be managing that config entry.
-	if sourceDatacenter != r.DatacenterName {
+	if sourceDatacenter != r.DatacenterName && !ownedEntryOverwritten {
 
 		// Note that there is a special case where we will migrate a config entry
 		// that wasn't created by the controller if it has the migrate-entry annotation set to true.
@@ -203,6 +256,7 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 		// chart versions where they had previously created config entries themselves but
 		// now want to manage them through custom resources.
 		if configEntry.GetObjectMeta().Annotations[common.MigrateEntryKey] != common.MigrateEntryTrue {
+			configEntry.SetConsulStatus(sourceDatacenter, entry.GetModifyIndex())
 			return r.syncFailed(ctx, logger, crdCtrl, configEntry, ExternallyManagedConfigError,
 				sourceDatacenterMismatchErr(sourceDatacenter))
 		}
@@ -210,7 +264,7 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 		requiresMigration = true
 	}
 
-	if !configEntry.MatchesConsul(entry) {
+	if !configEntry.MatchesConsul(entry) || ownedEntryOverwritten {
 		if requiresMigration {
 			// If we're migrating this config entry but the custom resource
 			// doesn't match what's in Consul currently we error out so that
@@ -220,6 +274,13 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 		}
 
 		logger.Info("config entry does not match consul", "modify-index", entry.GetModifyIndex())
+		if syncedAtCurrentGeneration(configEntry) {
+			logger.Info("config entry has been modified in consul")
+			if driftPolicy(configEntry) == common.DriftPolicyReport {
+				return r.syncDrifted(ctx, crdCtrl, configEntry, ConfigEntryModifiedError,
+					"config entry has been modified in Consul")
+			}
+		}
 		_, writeMeta, err := r.ConsulClient.ConfigEntries().Set(consulEntry, &capi.WriteOptions{
 			Namespace: r.consulNamespace(consulEntry, configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource()),
 		})
@@ -228,6 +289,7 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 				fmt.Errorf("updating config entry in consul: %w", err))
 		}
 		logger.Info("config entry updated", "request-time", writeMeta.RequestTime)
+		r.refreshConsulStatus(logger, configEntry, nil)
 		return r.syncSuccessful(ctx, crdCtrl, configEntry)
 	} else if requiresMigration && entry.GetMeta()[common.DatacenterKey] != r.DatacenterName {
 		// If we get here then we're doing a migration and the entry in Consul
@@ -242,17 +304,30 @@ func (r *ConfigEntryController) ReconcileEntry(ctx context.Context, crdCtrl Cont
 				fmt.Errorf("updating config entry in consul: %w", err))
 		}
 		logger.Info("config entry migrated", "request-time", writeMeta.RequestTime)
+		r.refreshConsulStatus(logger, configEntry, nil)
 		return r.syncSuccessful(ctx, crdCtrl, configEntry)
-	} else if configEntry.SyncedConditionStatus() != corev1.ConditionTrue {
+	}
+
+	// The discovery chain also depends on other config entries so the status
+	// is refreshed even if the config entry itself hasn't changed.
+	previous := configEntry.DeepCopyObject()
+	r.refreshConsulStatus(logger, configEntry, entry)
+	if configEntry.SyncedConditionStatus() != corev1.ConditionTrue ||
+		configEntry.LastSyncedGeneration() != configEntry.GetGeneration() {
 		return r.syncSuccessful(ctx, crdCtrl, configEntry)
 	}
+	if !equality.Semantic.DeepEqual(previous, configEntry) {
+		return ctrl.Result{}, crdCtrl.UpdateStatus(ctx, configEntry)
+	}
 
 	return ctrl.Result{}, nil
 }
 
 // setupWithManager sets up the controller manager for the given resource
-// with our default options.
-func setupWithManager(mgr ctrl.Manager, resource client.Object, reconciler reconcile.Reconciler) error {
+// with our default options. If drift detection is enabled, it also watches
+// the config entries of the resource's kind in Consul. The watches are added
+// to the controller as well.
+func (r *ConfigEntryController) setupWithManager(mgr ctrl.Manager, resource common.ConfigEntryResource, reconciler reconcile.Reconciler, watches ...watch) error {
 	options := controller.Options{
 		// Taken from https://github.com/kubernetes/client-go/blob/master/util/workqueue/default_rate_limiters.go#L39
 		// and modified from a starting backoff of 5ms and max of 1000s to a
@@ -275,10 +350,28 @@ func setupWithManager(mgr ctrl.Manager, resource client.Object, reconciler recon
 		),
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(resource).
-		WithOptions(options).
-		Complete(reconciler)
+		WithOptions(options)
+
+	if r.EnableDriftDetection {
+		gvk, err := apiutil.GVKForObject(resource, mgr.GetScheme())
+		if err != nil {
+			return err
+		}
+		watcher := newConfigEntryWatcher(r.ConsulClient, resource.ConsulKind(), r.EnableConsulNamespaces,
+			mgr.GetLogger().WithName("configentry-watcher"))
+		if err := mgr.Add(watcher); err != nil {
+			return err
+		}
+		builder = builder.Watches(&source.Channel{Source: watcher.events},
+			handler.EnqueueRequestsFromMapFunc(r.requestsForConfigEntry(mgr.GetClient(), mgr.GetScheme(), gvk, watcher.log)))
+	}
+	for _, w := range watches {
+		builder = builder.Watches(w.source, w.handler)
+	}
+
+	return builder.Complete(reconciler)
 }
 
 func (r *ConfigEntryController) consulNamespace(configEntry capi.ConfigEntry, namespace string, globalResource bool) string {
@@ -304,6 +397,44 @@ func (r *ConfigEntryController) consulNamespace(configEntry capi.ConfigEntry, na
 	return ""
 }
 
+// refreshConsulStatus records the datacenter that owns the config entry in
+// Consul and its modify index. For config entries that are compiled into the
+// discovery chain of a service, it also records the discovery chain Consul
+// compiles for the service. If entry is nil, the config entry is read from
+// Consul, e.g. because it has just been written. Errors are only logged since
+// the config entry has been synced regardless.
+func (r *ConfigEntryController) refreshConsulStatus(logger logr.Logger, configEntry common.ConfigEntryResource, entry capi.ConfigEntry) {
+	consulNS := r.consulNamespace(configEntry.ToConsul(r.DatacenterName), configEntry.ConsulMirroringNS(), configEntry.ConsulGlobalResource())
+	if entry == nil {
+		var err error
+		entry, _, err = r.ConsulClient.ConfigEntries().Get(configEntry.ConsulKind(), configEntry.ConsulName(), &capi.QueryOptions{
+			Namespace: consulNS,
+		})
+		if err != nil {
+			logger.Error(err, "reading config entry from consul")
+			return
+		}
+	}
+	configEntry.SetConsulStatus(entry.GetMeta()[common.DatacenterKey], entry.GetModifyIndex())
+
+	if !compilesIntoDiscoveryChain(configEntry.ConsulKind()) {
+		return
+	}
+	resp, _, err := r.ConsulClient.DiscoveryChain().Get(configEntry.ConsulName(), nil, &capi.QueryOptions{
+		Namespace: consulNS,
+	})
+	var statusErr capi.StatusError
+	switch {
+	case err == nil:
+		configEntry.SetDiscoveryChainStatus(resp.Chain, "")
+	case errors.As(err, &statusErr) && statusErr.Code == http.StatusInternalServerError:
+		// Consul returns a 500 if the discovery chain can't be compiled.
+		configEntry.SetDiscoveryChainStatus(nil, statusErr.Body)
+	default:
+		logger.Error(err, "reading discovery chain from consul")
+	}
+}
+
 func (r *ConfigEntryController) syncFailed(ctx context.Context, logger logr.Logger, updater Controller, configEntry common.ConfigEntryResource, errType string, err error) (ctrl.Result, error) {
 	configEntry.SetSyncedCondition(corev1.ConditionFalse, errType, err.Error())
 	if updateErr := updater.UpdateStatus(ctx, configEntry); updateErr != nil {
@@ -319,6 +450,23 @@ func (r *ConfigEntryController) syncSuccessful(ctx context.Context, updater Cont
 	configEntry.SetSyncedCondition(corev1.ConditionTrue, "", "")
 	timeNow := metav1.NewTime(time.Now())
 	configEntry.SetLastSyncedTime(&timeNow)
+	configEntry.SetLastSyncedGeneration(configEntry.GetGeneration())
+	return ctrl.Result{}, updater.UpdateStatus(ctx, configEntry)
+}
+
+// syncDrifted reports that the config entry has drifted from the resource.
+// It doesn't return an error since retrying doesn't help: the resource is
+// reconciled again once the config entry changes in Consul or the resource
+// changes. The Drifted condition is cleared by the next sync since setting the
+// Synced condition replaces all conditions.
+func (r *ConfigEntryController) syncDrifted(ctx context.Context, updater Controller, configEntry common.ConfigEntryResource, reason, message string) (ctrl.Result, error) {
+	// Updating the status triggers another reconcile, so only update it if
+	// the drift hasn't been reported yet.
+	if status, currentReason, _ := configEntry.SyncedCondition(); status == corev1.ConditionFalse && currentReason == reason {
+		return ctrl.Result{}, nil
+	}
+	configEntry.SetSyncedCondition(corev1.ConditionFalse, reason, message)
+	configEntry.SetDriftedCondition(corev1.ConditionTrue, reason, message)
 	return ctrl.Result{}, updater.UpdateStatus(ctx, configEntry)
 }
 
@@ -361,6 +509,27 @@ func (r *ConfigEntryController) nonMatchingMigrationError(kubeEntry common.Confi
 	return fmt.Errorf("migration failed: Kubernetes resource does not match existing Consul config entry: consul=%s, kube=%s", consulJSON, kubeJSON)
 }
 
+// syncedAtCurrentGeneration returns true if the resource hasn't changed since
+// it was last synced with Consul, so if the config entry in Consul doesn't
+// match it, the config entry has been changed in Consul.
+func syncedAtCurrentGeneration(configEntry common.ConfigEntryResource) bool {
+	return configEntry.LastSyncedGeneration() != 0 && configEntry.LastSyncedGeneration() == configEntry.GetGeneration()
+}
+
+// driftPolicy returns the drift policy of the resource.
+func driftPolicy(configEntry common.ConfigEntryResource) string {
+	if policy := configEntry.GetAnnotations()[common.DriftPolicyKey]; policy != "" {
+		return policy
+	}
+	return common.DriftPolicyCorrect
+}
+
+// compilesIntoDiscoveryChain returns true if config entries of the kind are
+// compiled into the discovery chain of the service they're named after.
+func compilesIntoDiscoveryChain(kind string) bool {
+	return kind == capi.ServiceRouter || kind == capi.ServiceSplitter || kind == capi.ServiceResolver
+}
+
 func isNotFoundErr(err error) bool {
 	return err != nil && strings.Contains(err.Error(), "404")
 }
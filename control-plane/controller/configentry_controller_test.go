@@ -2,7 +2,11 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
 	"time"
 
@@ -12,6 +16,7 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/hashicorp/consul-k8s/control-plane/api/common"
 	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/helper/cert"
 	capi "github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil"
 	"github.com/stretchr/testify/require"
@@ -22,6 +27,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const datacenterName = "datacenter"
@@ -1398,6 +1404,305 @@ func TestConfigEntryControllers_errorUpdatesSyncStatus(t *testing.T) {
 	req.Contains(errMsg, expErr)
 }
 
+// Test that if a resource referenced by the config entry can't be read then
+// the status is updated and nothing is written to Consul.
+func TestConfigEntryControllers_referenceErrorUpdatesSyncStatus(t *testing.T) {
+	t.Parallel()
+	kubeNS := "default"
+
+	req := require.New(t)
+	ctx := context.Background()
+	certificate := &v1alpha1.InlineCertificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: kubeNS,
+		},
+		Spec: v1alpha1.InlineCertificateSpec{
+			SecretRef: &v1alpha1.InlineCertificateSecretRef{Name: "tls"},
+		},
+	}
+
+	s := runtime.NewScheme()
+	s.AddKnownTypes(v1alpha1.GroupVersion, certificate)
+	s.AddKnownTypes(corev1.SchemeGroupVersion, &corev1.Secret{})
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(certificate).Build()
+
+	// The Consul client would error if it was used.
+	consulClient, err := capi.NewClient(&capi.Config{
+		Address: "incorrect-address",
+	})
+	req.NoError(err)
+	reconciler := &InlineCertificateController{
+		Client:           fakeClient,
+		Log:              logrtest.TestLogger{T: t},
+		APIReader:        fakeClient,
+		EnableSecretRefs: true,
+		ConfigEntryController: &ConfigEntryController{
+			ConsulClient:   consulClient,
+			DatacenterName: datacenterName,
+		},
+	}
+
+	namespacedName := types.NamespacedName{
+		Namespace: kubeNS,
+		Name:      certificate.KubernetesName(),
+	}
+	_, err = reconciler.Reconcile(ctx, ctrl.Request{
+		NamespacedName: namespacedName,
+	})
+	req.Error(err)
+	req.Contains(err.Error(), `reading secret "tls"`)
+
+	// Check that the status is "synced=false".
+	err = fakeClient.Get(ctx, namespacedName, certificate)
+	req.NoError(err)
+	status, reason, errMsg := certificate.SyncedCondition()
+	req.Equal(corev1.ConditionFalse, status)
+	req.Equal(ReferenceError, reason)
+	req.Contains(errMsg, `reading secret "tls"`)
+	req.NotContains(errMsg, "incorrect-address")
+}
+
+// Test that the Secret referenced by an inline certificate is read from the
+// API server rather than from the cache, which only holds TLS Secrets.
+func TestInlineCertificateController_ResolveReferencesReadsFromAPIReader(t *testing.T) {
+	t.Parallel()
+
+	_, keyPem, certPem, _, err := cert.GenerateCA("test")
+	require.NoError(t, err)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "default"},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       []byte(certPem),
+			corev1.TLSPrivateKeyKey: []byte(keyPem),
+		},
+	}
+	certificate := &v1alpha1.InlineCertificate{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"},
+		Spec: v1alpha1.InlineCertificateSpec{
+			SecretRef: &v1alpha1.InlineCertificateSecretRef{Name: "tls"},
+		},
+	}
+
+	s := runtime.NewScheme()
+	s.AddKnownTypes(v1alpha1.GroupVersion, certificate)
+	s.AddKnownTypes(corev1.SchemeGroupVersion, &corev1.Secret{})
+	reconciler := &InlineCertificateController{
+		Client:           fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(certificate).Build(),
+		Log:              logrtest.TestLogger{T: t},
+		APIReader:        fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(secret).Build(),
+		EnableSecretRefs: true,
+	}
+
+	require.NoError(t, reconciler.ResolveReferences(context.Background(), certificate))
+	entry := certificate.ToConsul(datacenterName).(*capi.InlineCertificateConfigEntry)
+	require.Equal(t, certPem, entry.Certificate)
+	require.Equal(t, keyPem, entry.PrivateKey)
+
+	// Secrets aren't read if secret references are disabled.
+	reconciler.EnableSecretRefs = false
+	err = reconciler.ResolveReferences(context.Background(), certificate)
+	require.EqualError(t, err, "secretRef is not supported since reading secrets is disabled in the controller")
+}
+
+func TestInlineCertificateController_requestsForSecret(t *testing.T) {
+	t.Parallel()
+
+	s := runtime.NewScheme()
+	s.AddKnownTypes(v1alpha1.GroupVersion, &v1alpha1.InlineCertificate{}, &v1alpha1.InlineCertificateList{})
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(
+		&v1alpha1.InlineCertificate{
+			ObjectMeta: metav1.ObjectMeta{Name: "match", Namespace: "default"},
+			Spec:       v1alpha1.InlineCertificateSpec{SecretRef: &v1alpha1.InlineCertificateSecretRef{Name: "tls"}},
+		},
+		&v1alpha1.InlineCertificate{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-secret", Namespace: "default"},
+			Spec:       v1alpha1.InlineCertificateSpec{SecretRef: &v1alpha1.InlineCertificateSecretRef{Name: "other"}},
+		},
+		&v1alpha1.InlineCertificate{
+			ObjectMeta: metav1.ObjectMeta{Name: "inline", Namespace: "default"},
+			Spec:       v1alpha1.InlineCertificateSpec{Certificate: "certificate", PrivateKey: "private-key"},
+		},
+		&v1alpha1.InlineCertificate{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-namespace", Namespace: "other"},
+			Spec:       v1alpha1.InlineCertificateSpec{SecretRef: &v1alpha1.InlineCertificateSecretRef{Name: "tls"}},
+		},
+	).Build()
+
+	reconciler := &InlineCertificateController{
+		Client: fakeClient,
+		Log:    logrtest.TestLogger{T: t},
+	}
+	requests := reconciler.requestsForSecret(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "default"},
+	})
+	require.Equal(t, []reconcile.Request{
+		{NamespacedName: types.NamespacedName{Namespace: "default", Name: "match"}},
+	}, requests)
+}
+
+// Test that the status of synced resources records the config entry in
+// Consul and, for routing config entries, the compiled discovery chain.
+func TestConfigEntryControllers_updatesConsulStatus(t *testing.T) {
+	t.Parallel()
+	kubeNS := "default"
+
+	cases := map[string]struct {
+		configEntryResource common.ConfigEntryResource
+		reconciler          func(client.Client, *capi.Client, logr.Logger) testReconciler
+		chainStatusCode     int
+		chainResponse       string
+		expChain            *v1alpha1.DiscoveryChainStatus
+	}{
+		"service-defaults": {
+			configEntryResource: &v1alpha1.ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: kubeNS},
+				Spec:       v1alpha1.ServiceDefaultsSpec{Protocol: "http"},
+			},
+			reconciler: func(client client.Client, consulClient *capi.Client, logger logr.Logger) testReconciler {
+				return &ServiceDefaultsController{
+					Client: client,
+					Log:    logger,
+					ConfigEntryController: &ConfigEntryController{
+						ConsulClient:   consulClient,
+						DatacenterName: datacenterName,
+					},
+				}
+			},
+		},
+		"service-router": {
+			configEntryResource: &v1alpha1.ServiceRouter{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: kubeNS},
+				Spec: v1alpha1.ServiceRouterSpec{
+					Routes: []v1alpha1.ServiceRoute{{Destination: &v1alpha1.ServiceRouteDestination{Service: "bar"}}},
+				},
+			},
+			reconciler: func(client client.Client, consulClient *capi.Client, logger logr.Logger) testReconciler {
+				return &ServiceRouterController{
+					Client: client,
+					Log:    logger,
+					ConfigEntryController: &ConfigEntryController{
+						ConsulClient:   consulClient,
+						DatacenterName: datacenterName,
+					},
+				}
+			},
+			chainStatusCode: http.StatusOK,
+			chainResponse: `{"Chain": {"ServiceName": "foo", "Protocol": "http", "Targets": {
+				"foo.default.default.datacenter": {"ID": "foo.default.default.datacenter", "Service": "foo", "Namespace": "default", "Datacenter": "datacenter"},
+				"bar.default.default.datacenter": {"ID": "bar.default.default.datacenter", "Service": "bar", "Namespace": "default", "Datacenter": "datacenter"}
+			}}}`,
+			expChain: &v1alpha1.DiscoveryChainStatus{
+				Protocol: "http",
+				Targets: []v1alpha1.DiscoveryChainTarget{
+					{ID: "bar.default.default.datacenter", Service: "bar", Namespace: "default", Datacenter: "datacenter"},
+					{ID: "foo.default.default.datacenter", Service: "foo", Namespace: "default", Datacenter: "datacenter"},
+				},
+			},
+		},
+		"service-splitter with protocol mismatch": {
+			configEntryResource: &v1alpha1.ServiceSplitter{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: kubeNS},
+				Spec: v1alpha1.ServiceSplitterSpec{
+					Splits: []v1alpha1.ServiceSplit{{Weight: 100, Service: "bar"}},
+				},
+			},
+			reconciler: func(client client.Client, consulClient *capi.Client, logger logr.Logger) testReconciler {
+				return &ServiceSplitterController{
+					Client: client,
+					Log:    logger,
+					ConfigEntryController: &ConfigEntryController{
+						ConsulClient:   consulClient,
+						DatacenterName: datacenterName,
+					},
+				}
+			},
+			chainStatusCode: http.StatusInternalServerError,
+			chainResponse:   `discovery chain "foo" uses inconsistent protocols; service "bar" has "tcp" which is not "http"`,
+			expChain: &v1alpha1.DiscoveryChainStatus{
+				Warnings: []string{`discovery chain "foo" uses inconsistent protocols; service "bar" has "tcp" which is not "http"`},
+			},
+		},
+		"service-resolver with compile error": {
+			configEntryResource: &v1alpha1.ServiceResolver{
+				ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: kubeNS},
+				Spec: v1alpha1.ServiceResolverSpec{
+					Redirect: &v1alpha1.ServiceResolverRedirect{Service: "bar"},
+				},
+			},
+			reconciler: func(client client.Client, consulClient *capi.Client, logger logr.Logger) testReconciler {
+				return &ServiceResolverController{
+					Client: client,
+					Log:    logger,
+					ConfigEntryController: &ConfigEntryController{
+						ConsulClient:   consulClient,
+						DatacenterName: datacenterName,
+					},
+				}
+			},
+			chainStatusCode: http.StatusInternalServerError,
+			chainResponse:   `detected circular resolver redirect: [foo bar foo]`,
+			expChain: &v1alpha1.DiscoveryChainStatus{
+				Warnings: []string{`detected circular resolver redirect: [foo bar foo]`},
+			},
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ctx := context.Background()
+
+			// The resource is already synced at its current generation.
+			c.configEntryResource.SetGeneration(1)
+			c.configEntryResource.AddFinalizer(FinalizerName)
+			c.configEntryResource.SetSyncedCondition(corev1.ConditionTrue, "", "")
+			c.configEntryResource.SetLastSyncedGeneration(1)
+
+			consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case fmt.Sprintf("/v1/config/%s/foo", c.configEntryResource.ConsulKind()):
+					entry, err := json.Marshal(c.configEntryResource.ToConsul(datacenterName))
+					require.NoError(t, err)
+					var raw map[string]interface{}
+					require.NoError(t, json.Unmarshal(entry, &raw))
+					raw["ModifyIndex"] = 42
+					require.NoError(t, json.NewEncoder(w).Encode(raw))
+				case "/v1/discovery-chain/foo":
+					require.NotZero(t, c.chainStatusCode, "discovery chain should not be read")
+					w.WriteHeader(c.chainStatusCode)
+					fmt.Fprint(w, c.chainResponse)
+				default:
+					t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+					w.WriteHeader(http.StatusNotFound)
+				}
+			}))
+			defer consulServer.Close()
+			consulClient, err := capi.NewClient(&capi.Config{Address: consulServer.URL})
+			require.NoError(t, err)
+
+			s := runtime.NewScheme()
+			s.AddKnownTypes(v1alpha1.GroupVersion, c.configEntryResource)
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(c.configEntryResource).Build()
+
+			r := c.reconciler(fakeClient, consulClient, logrtest.TestLogger{T: t})
+			namespacedName := types.NamespacedName{Namespace: kubeNS, Name: "foo"}
+			_, err = r.Reconcile(ctx, ctrl.Request{NamespacedName: namespacedName})
+			require.NoError(t, err)
+
+			err = fakeClient.Get(ctx, namespacedName, c.configEntryResource)
+			require.NoError(t, err)
+			status := reflect.ValueOf(c.configEntryResource).Elem().FieldByName("Status").Interface().(v1alpha1.Status)
+			require.Equal(t, datacenterName, status.ConsulDatacenter)
+			require.Equal(t, uint64(42), status.ConsulModifyIndex)
+			require.Equal(t, c.expChain, status.DiscoveryChain)
+			require.Equal(t, corev1.ConditionTrue, c.configEntryResource.SyncedConditionStatus())
+		})
+	}
+}
+
 // Test that if the config entry hasn't changed in Consul but our resource
 // synced status isn't set to true then we update its status.
 func TestConfigEntryControllers_setsSyncedToTrue(t *testing.T) {
@@ -1647,6 +1952,140 @@ func TestConfigEntryControllers_doesNotDeleteUnownedConfig(t *testing.T) {
 	}
 }
 
+// Test that a config entry changed or deleted in Consul after the resource
+// has been synced is restored or reported depending on the drift policy.
+func TestConfigEntryControllers_drift(t *testing.T) {
+	t.Parallel()
+	kubeNS := "default"
+
+	cases := map[string]struct {
+		driftPolicy string
+		// consulProtocol is the protocol of the config entry written in
+		// Consul outside of Kubernetes. If empty, the config entry is
+		// deleted from Consul.
+		consulProtocol string
+		expProtocol    string
+		expSynced      corev1.ConditionStatus
+		expReason      string
+	}{
+		"modified and corrected": {
+			consulProtocol: "tcp",
+			expProtocol:    "http",
+			expSynced:      corev1.ConditionTrue,
+		},
+		"deleted and corrected": {
+			expProtocol: "http",
+			expSynced:   corev1.ConditionTrue,
+		},
+		"modified and reported": {
+			driftPolicy:    common.DriftPolicyReport,
+			consulProtocol: "tcp",
+			expProtocol:    "tcp",
+			expSynced:      corev1.ConditionFalse,
+			expReason:      ConfigEntryModifiedError,
+		},
+		"deleted and reported": {
+			driftPolicy: common.DriftPolicyReport,
+			expSynced:   corev1.ConditionFalse,
+			expReason:   ConfigEntryDeletedError,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := require.New(t)
+			ctx := context.Background()
+
+			s := runtime.NewScheme()
+			svcDefaults := &v1alpha1.ServiceDefaults{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "foo",
+					Namespace:  kubeNS,
+					Generation: 1,
+					Finalizers: []string{FinalizerName},
+				},
+				Spec: v1alpha1.ServiceDefaultsSpec{
+					Protocol: "http",
+				},
+				Status: v1alpha1.Status{
+					Conditions: v1alpha1.Conditions{
+						{
+							Type:   v1alpha1.ConditionSynced,
+							Status: corev1.ConditionTrue,
+						},
+					},
+					LastSyncedGeneration: 1,
+				},
+			}
+			if c.driftPolicy != "" {
+				svcDefaults.Annotations = map[string]string{common.DriftPolicyKey: c.driftPolicy}
+			}
+			s.AddKnownTypes(v1alpha1.GroupVersion, svcDefaults)
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(svcDefaults).Build()
+
+			consul, err := testutil.NewTestServerConfigT(t, nil)
+			req.NoError(err)
+			defer consul.Stop()
+
+			consul.WaitForServiceIntentions(t)
+			consulClient, err := capi.NewClient(&capi.Config{
+				Address: consul.HTTPAddr,
+			})
+			req.NoError(err)
+
+			// Mimic a config entry written with the Consul CLI, which doesn't
+			// keep the metadata of the datacenter.
+			if c.consulProtocol != "" {
+				written, _, err := consulClient.ConfigEntries().Set(&capi.ServiceConfigEntry{
+					Kind:     capi.ServiceDefaults,
+					Name:     svcDefaults.ConsulName(),
+					Protocol: c.consulProtocol,
+				}, nil)
+				req.NoError(err)
+				req.True(written)
+			}
+
+			reconciler := &ServiceDefaultsController{
+				Client: fakeClient,
+				Log:    logrtest.TestLogger{T: t},
+				ConfigEntryController: &ConfigEntryController{
+					ConsulClient:   consulClient,
+					DatacenterName: datacenterName,
+				},
+			}
+			namespacedName := types.NamespacedName{
+				Namespace: kubeNS,
+				Name:      svcDefaults.KubernetesName(),
+			}
+			resp, err := reconciler.Reconcile(ctx, ctrl.Request{
+				NamespacedName: namespacedName,
+			})
+			req.NoError(err)
+			req.False(resp.Requeue)
+
+			entry, _, err := consulClient.ConfigEntries().Get(capi.ServiceDefaults, svcDefaults.ConsulName(), nil)
+			if c.expProtocol == "" {
+				req.True(isNotFoundErr(err))
+			} else {
+				req.NoError(err)
+				req.Equal(c.expProtocol, entry.(*capi.ServiceConfigEntry).Protocol)
+			}
+
+			err = fakeClient.Get(ctx, namespacedName, svcDefaults)
+			req.NoError(err)
+			status, reason, _ := svcDefaults.SyncedCondition()
+			req.Equal(c.expSynced, status)
+			req.Equal(c.expReason, reason)
+			drifted := svcDefaults.Status.GetCondition(v1alpha1.ConditionDrifted)
+			if c.expReason == "" {
+				req.Nil(drifted)
+			} else {
+				req.NotNil(drifted)
+				req.Equal(corev1.ConditionTrue, drifted.Status)
+			}
+		})
+	}
+}
+
 func TestConfigEntryControllers_updatesStatusWhenDeleteFails(t *testing.T) {
 	ctx := context.Background()
 	kubeNS := "default"
@@ -1891,3 +2330,105 @@ func TestConfigEntryController_Migration(t *testing.T) {
 		})
 	}
 }
+
+func TestRequestsForDiscoveryChainDependents(t *testing.T) {
+	t.Parallel()
+
+	s := runtime.NewScheme()
+	s.AddKnownTypes(v1alpha1.GroupVersion,
+		&v1alpha1.ServiceRouter{}, &v1alpha1.ServiceRouterList{},
+		&v1alpha1.ServiceSplitter{}, &v1alpha1.ServiceSplitterList{},
+		&v1alpha1.ServiceResolver{}, &v1alpha1.ServiceResolverList{})
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(
+		// web routes to api, which splits to db.
+		&v1alpha1.ServiceRouter{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+			Spec: v1alpha1.ServiceRouterSpec{
+				Routes: []v1alpha1.ServiceRoute{{Destination: &v1alpha1.ServiceRouteDestination{Service: "api"}}},
+			},
+		},
+		&v1alpha1.ServiceSplitter{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+			Spec: v1alpha1.ServiceSplitterSpec{
+				Splits: []v1alpha1.ServiceSplit{{Weight: 50}, {Weight: 50, Service: "db"}},
+			},
+		},
+		&v1alpha1.ServiceResolver{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+			Spec: v1alpha1.ServiceResolverSpec{
+				Failover: map[string]v1alpha1.ServiceResolverFailover{"*": {Service: "db-backup"}},
+			},
+		},
+		&v1alpha1.ServiceRouter{
+			ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "default"},
+		},
+	).Build()
+
+	cases := map[string]struct {
+		object      client.Object
+		kind        string
+		expRequests []reconcile.Request
+	}{
+		"service-defaults of a service without resources": {
+			object: &v1alpha1.ServiceDefaults{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default"}},
+			kind:   capi.ServiceRouter,
+		},
+		"service-defaults of the service of a router": {
+			object: &v1alpha1.ServiceDefaults{ObjectMeta: metav1.ObjectMeta{Name: "admin", Namespace: "default"}},
+			kind:   capi.ServiceRouter,
+			expRequests: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "default", Name: "admin"}},
+			},
+		},
+		"service-defaults of a failover target reconciles the routers that reach it": {
+			object: &v1alpha1.ServiceDefaults{ObjectMeta: metav1.ObjectMeta{Name: "db-backup", Namespace: "default"}},
+			kind:   capi.ServiceRouter,
+			expRequests: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}},
+			},
+		},
+		"service-defaults of a split target reconciles the splitter": {
+			object: &v1alpha1.ServiceDefaults{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"}},
+			kind:   capi.ServiceSplitter,
+			expRequests: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "default", Name: "api"}},
+			},
+		},
+		"service-defaults of a service only reconciles resources of the kind": {
+			object: &v1alpha1.ServiceDefaults{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}},
+			kind:   capi.ServiceResolver,
+		},
+		"service-resolver of a failover source reconciles the routers that reach it": {
+			object: &v1alpha1.ServiceResolver{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"}},
+			kind:   capi.ServiceRouter,
+			expRequests: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}},
+			},
+		},
+		"service-router of a split target reconciles the splitter": {
+			object: &v1alpha1.ServiceRouter{ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"}},
+			kind:   capi.ServiceSplitter,
+			expRequests: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "default", Name: "api"}},
+			},
+		},
+		"service-splitter only reconciles the resolvers in its discovery chain": {
+			object: &v1alpha1.ServiceSplitter{ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"}},
+			kind:   capi.ServiceResolver,
+		},
+		"proxy-defaults reconciles all resources of the kind": {
+			object: &v1alpha1.ProxyDefaults{ObjectMeta: metav1.ObjectMeta{Name: common.Global, Namespace: "default"}},
+			kind:   capi.ServiceRouter,
+			expRequests: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "default", Name: "admin"}},
+				{NamespacedName: types.NamespacedName{Namespace: "default", Name: "web"}},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			requests := requestsForDiscoveryChainDependents(fakeClient, c.kind, logrtest.TestLogger{T: t})(c.object)
+			require.ElementsMatch(t, c.expRequests, requests)
+		})
+	}
+}
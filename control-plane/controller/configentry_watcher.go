@@ -0,0 +1,193 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	capi "github.com/hashicorp/consul/api"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// configEntryWatchWaitTime is how long a blocking query for config
+	// entries waits for a change before it returns.
+	configEntryWatchWaitTime = 5 * time.Minute
+	// configEntryWatchRetryInterval is how long the watcher waits before
+	// querying Consul again after a failed query.
+	configEntryWatchRetryInterval = 5 * time.Second
+)
+
+// configEntryWatcher watches the config entries of one kind in Consul with
+// blocking queries. Whenever one of them is created, modified or deleted, it
+// sends an event for that config entry so that the resources of the kind
+// that manage it are reconciled and compared with Consul again.
+// It implements manager.Runnable.
+type configEntryWatcher struct {
+	consulClient *capi.Client
+	// kind is the Consul config entry kind, e.g. service-defaults.
+	kind string
+	// namespace is the Consul namespace to watch. It's "*" to watch all
+	// namespaces if Consul namespaces are enabled.
+	namespace string
+	events    chan event.GenericEvent
+	log       logr.Logger
+
+	// modifyIndexes holds the modify index of each config entry returned by
+	// the previous query. It's nil until the first query succeeds.
+	modifyIndexes map[configEntryRef]uint64
+
+	// retryInterval is how long to wait after a failed query. It is only
+	// changed in tests.
+	retryInterval time.Duration
+}
+
+// configEntryRef identifies a config entry of the watched kind in Consul.
+type configEntryRef struct {
+	namespace string
+	name      string
+}
+
+func newConfigEntryWatcher(consulClient *capi.Client, kind string, enableConsulNamespaces bool, log logr.Logger) *configEntryWatcher {
+	namespace := ""
+	if enableConsulNamespaces {
+		namespace = "*"
+	}
+	return &configEntryWatcher{
+		consulClient:  consulClient,
+		kind:          kind,
+		namespace:     namespace,
+		events:        make(chan event.GenericEvent),
+		log:           log.WithValues("kind", kind),
+		retryInterval: configEntryWatchRetryInterval,
+	}
+}
+
+// Start watches the config entries until ctx is cancelled.
+func (w *configEntryWatcher) Start(ctx context.Context) error {
+	var index uint64
+	for {
+		opts := &capi.QueryOptions{
+			Namespace: w.namespace,
+			WaitIndex: index,
+			WaitTime:  configEntryWatchWaitTime,
+		}
+		entries, meta, err := w.consulClient.ConfigEntries().List(w.kind, opts.WithContext(ctx))
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			w.log.Error(err, "watching config entries in consul")
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(w.retryInterval):
+			}
+			continue
+		}
+
+		// The first query only records the config entries. The resources are
+		// reconciled when the controller starts anyway.
+		initialized := w.modifyIndexes != nil
+		changed := w.update(entries)
+		if initialized {
+			for _, ref := range changed {
+				w.log.Info("config entry changed in consul", "name", ref.name, "namespace", ref.namespace)
+				select {
+				case <-ctx.Done():
+					return nil
+				case w.events <- event.GenericEvent{Object: ref.object()}:
+				}
+			}
+		}
+
+		// Reset the index if it goes backwards, e.g. after the servers have
+		// been restored from a snapshot.
+		if meta.LastIndex < index {
+			index = 0
+		} else {
+			index = meta.LastIndex
+		}
+	}
+}
+
+// update records the modify indexes of the entries and returns the config
+// entries that were created, modified or deleted since the previous call.
+func (w *configEntryWatcher) update(entries []capi.ConfigEntry) []configEntryRef {
+	modifyIndexes := make(map[configEntryRef]uint64, len(entries))
+	var changed []configEntryRef
+	for _, entry := range entries {
+		ref := configEntryRef{namespace: entry.GetNamespace(), name: entry.GetName()}
+		modifyIndexes[ref] = entry.GetModifyIndex()
+		if previous, ok := w.modifyIndexes[ref]; !ok || previous != entry.GetModifyIndex() {
+			changed = append(changed, ref)
+		}
+	}
+	for ref := range w.modifyIndexes {
+		if _, ok := modifyIndexes[ref]; !ok {
+			changed = append(changed, ref)
+		}
+	}
+	w.modifyIndexes = modifyIndexes
+	return changed
+}
+
+// object returns the object sent in the event for the config entry. Its
+// namespace is the Consul namespace of the config entry.
+func (ref configEntryRef) object() client.Object {
+	return &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Namespace: ref.namespace, Name: ref.name}}
+}
+
+// requestsForConfigEntry returns a function that maps the events of the
+// watcher to requests for the resources of the kind with the given
+// GroupVersionKind that manage the changed config entry. The resources are
+// listed from the cache of the client.
+func (r *ConfigEntryController) requestsForConfigEntry(c client.Client, scheme *runtime.Scheme, gvk schema.GroupVersionKind, log logr.Logger) func(client.Object) []reconcile.Request {
+	return func(obj client.Object) []reconcile.Request {
+		list, err := scheme.New(gvk.GroupVersion().WithKind(gvk.Kind + "List"))
+		if err != nil {
+			log.Error(err, "creating list of resources to reconcile", "kind", gvk.Kind)
+			return nil
+		}
+		objList, ok := list.(client.ObjectList)
+		if !ok {
+			log.Error(fmt.Errorf("%T is not a list", list), "creating list of resources to reconcile", "kind", gvk.Kind)
+			return nil
+		}
+		if err := c.List(context.Background(), objList); err != nil {
+			log.Error(err, "listing resources to reconcile", "kind", gvk.Kind)
+			return nil
+		}
+		items, err := meta.ExtractList(objList)
+		if err != nil {
+			log.Error(err, "listing resources to reconcile", "kind", gvk.Kind)
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, item := range items {
+			resource, ok := item.(common.ConfigEntryResource)
+			if !ok || resource.ConsulName() != obj.GetName() {
+				continue
+			}
+			// The namespace of config entries is ignored if Consul namespaces
+			// are disabled since Consul Enterprise still returns "default".
+			if r.EnableConsulNamespaces &&
+				r.consulNamespace(resource.ToConsul(r.DatacenterName), resource.ConsulMirroringNS(), resource.ConsulGlobalResource()) != obj.GetNamespace() {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: resource.GetObjectMeta().Namespace, Name: resource.KubernetesName()},
+			})
+		}
+		return requests
+	}
+}
@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"testing"
+
+	logrtest "github.com/go-logr/logr/testing"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestConfigEntryWatcher_update(t *testing.T) {
+	w := newConfigEntryWatcher(nil, capi.ServiceDefaults, false, logrtest.TestLogger{T: t})
+
+	entries := []capi.ConfigEntry{
+		&capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "foo", ModifyIndex: 1},
+		&capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "bar", ModifyIndex: 2},
+	}
+	require.ElementsMatch(t, []configEntryRef{{name: "foo"}, {name: "bar"}}, w.update(entries))
+	require.Empty(t, w.update(entries))
+
+	// foo is modified, bar is deleted and baz is created.
+	entries = []capi.ConfigEntry{
+		&capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "foo", ModifyIndex: 3},
+		&capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "baz", ModifyIndex: 4},
+	}
+	require.ElementsMatch(t, []configEntryRef{{name: "foo"}, {name: "bar"}, {name: "baz"}}, w.update(entries))
+}
+
+func TestConfigEntryController_requestsForConfigEntry(t *testing.T) {
+	cases := map[string]struct {
+		enableConsulNamespaces bool
+		enableNSMirroring      bool
+		event                  configEntryRef
+		expRequests            []reconcile.Request
+	}{
+		"namespaces disabled": {
+			event: configEntryRef{namespace: "default", name: "foo"},
+			expRequests: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "default", Name: "foo"}},
+				{NamespacedName: types.NamespacedName{Namespace: "other", Name: "foo"}},
+			},
+		},
+		"namespaces mirrored": {
+			enableConsulNamespaces: true,
+			enableNSMirroring:      true,
+			event:                  configEntryRef{namespace: "other", name: "foo"},
+			expRequests: []reconcile.Request{
+				{NamespacedName: types.NamespacedName{Namespace: "other", Name: "foo"}},
+			},
+		},
+		"unknown config entry": {
+			event: configEntryRef{name: "unknown"},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			require.NoError(t, v1alpha1.AddToScheme(s))
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithRuntimeObjects(
+				&v1alpha1.ServiceDefaults{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}},
+				&v1alpha1.ServiceDefaults{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "other"}},
+				&v1alpha1.ServiceDefaults{ObjectMeta: metav1.ObjectMeta{Name: "bar", Namespace: "default"}},
+				&v1alpha1.ServiceResolver{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "default"}},
+			).Build()
+
+			r := &ConfigEntryController{
+				EnableConsulNamespaces: c.enableConsulNamespaces,
+				EnableNSMirroring:      c.enableNSMirroring,
+			}
+			mapFn := r.requestsForConfigEntry(fakeClient, s, v1alpha1.GroupVersion.WithKind("ServiceDefaults"), logrtest.TestLogger{T: t})
+			require.ElementsMatch(t, c.expRequests, mapFn(c.event.object()))
+		})
+	}
+}
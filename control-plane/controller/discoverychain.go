@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// discoveryChainWatches returns the watches that reconcile the resources of
+// the kind, a service-router, service-splitter or service-resolver, when a
+// ServiceDefaults or ProxyDefaults resource changes the protocol of the
+// services in their discovery chain, or when a ServiceRouter, ServiceSplitter
+// or ServiceResolver resource changes their discovery chain, so that their
+// discovery chain status is refreshed.
+func discoveryChainWatches(c client.Client, kind string, log logr.Logger) []watch {
+	mapFn := handler.EnqueueRequestsFromMapFunc(requestsForDiscoveryChainDependents(c, kind, log))
+	return []watch{
+		{source: &source.Kind{Type: &v1alpha1.ServiceDefaults{}}, handler: mapFn},
+		{source: &source.Kind{Type: &v1alpha1.ProxyDefaults{}}, handler: mapFn},
+		{source: &source.Kind{Type: &v1alpha1.ServiceRouter{}}, handler: mapFn},
+		{source: &source.Kind{Type: &v1alpha1.ServiceSplitter{}}, handler: mapFn},
+		{source: &source.Kind{Type: &v1alpha1.ServiceResolver{}}, handler: mapFn},
+	}
+}
+
+// requestsForDiscoveryChainDependents returns requests for the resources of
+// the kind whose discovery chain includes the service of the ServiceDefaults,
+// ServiceRouter, ServiceSplitter or ServiceResolver, or for all of them if
+// the object is a ProxyDefaults. Services are matched by name only, so
+// resources in other Consul namespaces may be reconciled needlessly but
+// never missed.
+func requestsForDiscoveryChainDependents(c client.Client, kind string, log logr.Logger) func(client.Object) []reconcile.Request {
+	return func(obj client.Object) []reconcile.Request {
+		resources, err := listDiscoveryChainResources(context.Background(), c)
+		if err != nil {
+			log.Error(err, "listing resources to reconcile", "kind", kind)
+			return nil
+		}
+		var affected map[string]bool
+		switch obj.(type) {
+		case *v1alpha1.ServiceDefaults, *v1alpha1.ServiceRouter, *v1alpha1.ServiceSplitter, *v1alpha1.ServiceResolver:
+			affected = servicesDependingOn(resources, obj.(common.ConfigEntryResource).ConsulName())
+		}
+		var requests []reconcile.Request
+		for _, resource := range resources {
+			if resource.ConsulKind() != kind {
+				continue
+			}
+			if affected != nil && !affected[resource.ConsulName()] {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: resource.GetObjectMeta().Namespace, Name: resource.KubernetesName()},
+			})
+		}
+		return requests
+	}
+}
+
+// listDiscoveryChainResources lists the service-router, service-splitter and
+// service-resolver resources in the cluster.
+func listDiscoveryChainResources(ctx context.Context, c client.Client) ([]common.ConfigEntryResource, error) {
+	var resources []common.ConfigEntryResource
+	var routers v1alpha1.ServiceRouterList
+	if err := c.List(ctx, &routers); err != nil {
+		return nil, err
+	}
+	for i := range routers.Items {
+		resources = append(resources, &routers.Items[i])
+	}
+	var splitters v1alpha1.ServiceSplitterList
+	if err := c.List(ctx, &splitters); err != nil {
+		return nil, err
+	}
+	for i := range splitters.Items {
+		resources = append(resources, &splitters.Items[i])
+	}
+	var resolvers v1alpha1.ServiceResolverList
+	if err := c.List(ctx, &resolvers); err != nil {
+		return nil, err
+	}
+	for i := range resolvers.Items {
+		resources = append(resources, &resolvers.Items[i])
+	}
+	return resources, nil
+}
+
+// servicesDependingOn returns the services whose discovery chain includes the
+// service: the service itself and the services that route, split, redirect
+// or fail over to it, directly or through other services.
+func servicesDependingOn(resources []common.ConfigEntryResource, service string) map[string]bool {
+	referrers := make(map[string][]string)
+	for _, resource := range resources {
+		for _, target := range discoveryChainTargets(resource) {
+			if target != "" && target != resource.ConsulName() {
+				referrers[target] = append(referrers[target], resource.ConsulName())
+			}
+		}
+	}
+
+	affected := map[string]bool{service: true}
+	queue := []string{service}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, referrer := range referrers[current] {
+			if !affected[referrer] {
+				affected[referrer] = true
+				queue = append(queue, referrer)
+			}
+		}
+	}
+	return affected
+}
+
+// discoveryChainTargets returns the services the resource routes, splits,
+// redirects or fails over to.
+func discoveryChainTargets(resource common.ConfigEntryResource) []string {
+	var targets []string
+	switch resource := resource.(type) {
+	case *v1alpha1.ServiceRouter:
+		for _, route := range resource.Spec.Routes {
+			if route.Destination != nil {
+				targets = append(targets, route.Destination.Service)
+			}
+		}
+	case *v1alpha1.ServiceSplitter:
+		for _, split := range resource.Spec.Splits {
+			targets = append(targets, split.Service)
+		}
+	case *v1alpha1.ServiceResolver:
+		if resource.Spec.Redirect != nil {
+			targets = append(targets, resource.Spec.Redirect.Service)
+		}
+		for _, failover := range resource.Spec.Failover {
+			targets = append(targets, failover.Service)
+		}
+	}
+	return targets
+}
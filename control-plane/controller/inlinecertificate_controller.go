@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	consulv1alpha1 "github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+)
+
+// InlineCertificateController is the controller for InlineCertificate resources.
+type InlineCertificateController struct {
+	client.Client
+	Log                   logr.Logger
+	Scheme                *runtime.Scheme
+	ConfigEntryController *ConfigEntryController
+
+	// APIReader reads Secrets directly from the API server. The cache of
+	// the manager only holds Secrets of type kubernetes.io/tls so that the
+	// controller doesn't keep every Secret of the cluster in memory.
+	APIReader client.Reader
+
+	// EnableSecretRefs allows inline certificates to reference a Secret with
+	// spec.secretRef. The controller only reads and watches Secrets if it's
+	// true, so it doesn't need access to Secrets otherwise.
+	EnableSecretRefs bool
+}
+
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=inlinecertificates,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=consul.hashicorp.com,resources=inlinecertificates/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+
+func (r *InlineCertificateController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	return r.ConfigEntryController.ReconcileEntry(ctx, r, req, &consulv1alpha1.InlineCertificate{})
+}
+
+func (r *InlineCertificateController) Logger(name types.NamespacedName) logr.Logger {
+	return r.Log.WithValues("request", name)
+}
+
+func (r *InlineCertificateController) UpdateStatus(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	return r.Status().Update(ctx, obj, opts...)
+}
+
+// ResolveReferences reads the certificate and private key from the Secret
+// referenced by the inline certificate, if any.
+func (r *InlineCertificateController) ResolveReferences(ctx context.Context, configEntry common.ConfigEntryResource) error {
+	certificate, ok := configEntry.(*consulv1alpha1.InlineCertificate)
+	if !ok || certificate.Spec.SecretRef == nil {
+		return nil
+	}
+	if !r.EnableSecretRefs {
+		return errors.New("secretRef is not supported since reading secrets is disabled in the controller")
+	}
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	var secret corev1.Secret
+	name := types.NamespacedName{Namespace: certificate.Namespace, Name: certificate.Spec.SecretRef.Name}
+	if err := reader.Get(ctx, name, &secret); err != nil {
+		// The error isn't wrapped so that a missing Secret isn't mistaken
+		// for a deleted resource.
+		return fmt.Errorf("reading secret %q: %s", name.Name, err)
+	}
+	return certificate.SetSecret(&secret)
+}
+
+func (r *InlineCertificateController) SetupWithManager(mgr ctrl.Manager) error {
+	if !r.EnableSecretRefs {
+		return r.ConfigEntryController.setupWithManager(mgr, &consulv1alpha1.InlineCertificate{}, r)
+	}
+	return r.ConfigEntryController.setupWithManager(mgr, &consulv1alpha1.InlineCertificate{}, r, watch{
+		source:  &source.Kind{Type: &corev1.Secret{}},
+		handler: handler.EnqueueRequestsFromMapFunc(r.requestsForSecret),
+	})
+}
+
+// SecretCacheSelector restricts the Secrets cached by the manager to those
+// of type kubernetes.io/tls, the only type an inline certificate can
+// reference.
+func SecretCacheSelector() fields.Selector {
+	return fields.OneTermEqualSelector("type", string(corev1.SecretTypeTLS))
+}
+
+// requestsForSecret returns requests for the inline certificates that
+// reference the Secret so that the config entries are updated when the
+// Secret changes.
+func (r *InlineCertificateController) requestsForSecret(secret client.Object) []reconcile.Request {
+	var list consulv1alpha1.InlineCertificateList
+	if err := r.List(context.Background(), &list, client.InNamespace(secret.GetNamespace())); err != nil {
+		r.Log.Error(err, "listing inline certificates", "secret", secret.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, item := range list.Items {
+		if item.Spec.SecretRef != nil && item.Spec.SecretRef.Name == secret.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: item.Namespace, Name: item.Name},
+			})
+		}
+	}
+	return requests
+}
@@ -36,5 +36,5 @@ func (r *MeshController) UpdateStatus(ctx context.Context, obj client.Object, op
 }
 
 func (r *MeshController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.Mesh{}, r)
+	return r.ConfigEntryController.setupWithManager(mgr, &consulv1alpha1.Mesh{}, r)
 }
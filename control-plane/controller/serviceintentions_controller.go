@@ -36,5 +36,5 @@ func (r *ServiceIntentionsController) UpdateStatus(ctx context.Context, obj clie
 }
 
 func (r *ServiceIntentionsController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ServiceIntentions{}, r)
+	return r.ConfigEntryController.setupWithManager(mgr, &consulv1alpha1.ServiceIntentions{}, r)
 }
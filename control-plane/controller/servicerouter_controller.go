@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/go-logr/logr"
+	capi "github.com/hashicorp/consul/api"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -36,5 +37,6 @@ func (r *ServiceRouterController) UpdateStatus(ctx context.Context, obj client.O
 }
 
 func (r *ServiceRouterController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ServiceRouter{}, r)
+	return r.ConfigEntryController.setupWithManager(mgr, &consulv1alpha1.ServiceRouter{}, r,
+		discoveryChainWatches(r.Client, capi.ServiceRouter, r.Log)...)
 }
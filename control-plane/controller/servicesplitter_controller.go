@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/go-logr/logr"
+	capi "github.com/hashicorp/consul/api"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -36,5 +37,6 @@ func (r *ServiceSplitterController) UpdateStatus(ctx context.Context, obj client
 }
 
 func (r *ServiceSplitterController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.ServiceSplitter{}, r)
+	return r.ConfigEntryController.setupWithManager(mgr, &consulv1alpha1.ServiceSplitter{}, r,
+		discoveryChainWatches(r.Client, capi.ServiceSplitter, r.Log)...)
 }
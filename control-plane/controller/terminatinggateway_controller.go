@@ -36,5 +36,5 @@ func (r *TerminatingGatewayController) UpdateStatus(ctx context.Context, obj cli
 }
 
 func (r *TerminatingGatewayController) SetupWithManager(mgr ctrl.Manager) error {
-	return setupWithManager(mgr, &consulv1alpha1.TerminatingGateway{}, r)
+	return r.ConfigEntryController.setupWithManager(mgr, &consulv1alpha1.TerminatingGateway{}, r)
 }
@@ -0,0 +1,177 @@
+package endpointslice
+
+import (
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServiceName returns the name of the service the EndpointSlice belongs to
+// or an empty string if it isn't managed for a service.
+func ServiceName(slice *discoveryv1.EndpointSlice) string {
+	return slice.Labels[discoveryv1.LabelServiceName]
+}
+
+// ListOptions returns the list options selecting the EndpointSlices of the
+// service with the given name.
+func ListOptions(serviceName string) metav1.ListOptions {
+	return metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(&metav1.LabelSelector{
+			MatchLabels: map[string]string{discoveryv1.LabelServiceName: serviceName},
+		}),
+	}
+}
+
+// IsReady returns true if the endpoint is ready to receive traffic.
+// A nil ready condition is interpreted as ready as documented by the API,
+// and terminating endpoints are never ready even if they are still serving
+// so that traffic is drained from them.
+func IsReady(endpoint discoveryv1.Endpoint) bool {
+	if IsTerminating(endpoint) {
+		return false
+	}
+	return endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready
+}
+
+// IsServing returns true if the endpoint can serve traffic regardless of
+// whether it is terminating. A nil serving condition falls back to the
+// ready condition.
+func IsServing(endpoint discoveryv1.Endpoint) bool {
+	if endpoint.Conditions.Serving != nil {
+		return *endpoint.Conditions.Serving
+	}
+	return endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready
+}
+
+// IsTerminating returns true if the endpoint is terminating.
+func IsTerminating(endpoint discoveryv1.Endpoint) bool {
+	return endpoint.Conditions.Terminating != nil && *endpoint.Conditions.Terminating
+}
+
+// ToEndpoints merges the EndpointSlices of a service into the equivalent
+// Endpoints object. Each slice becomes a subset of the Endpoints, with
+// ready endpoints in Addresses and all others in NotReadyAddresses.
+// If none of the endpoints are ready, terminating endpoints that are still
+// serving are used in Addresses so that traffic isn't dropped while all of
+// the service's pods are replaced, as kube-proxy does.
+// Endpoints that are in multiple slices, such as a dual-stack pod with
+// an IPv4 and an IPv6 slice, are only included once, preferring IPv4.
+// The labels of the slices, which are copied from the service, are merged.
+func ToEndpoints(name, namespace string, slices []discoveryv1.EndpointSlice) *corev1.Endpoints {
+	endpoints := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+
+	// Sort the slices so that the result is deterministic and IPv4 slices
+	// come before IPv6 slices.
+	sorted := make([]discoveryv1.EndpointSlice, len(slices))
+	copy(sorted, slices)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].AddressType != sorted[j].AddressType {
+			return sorted[i].AddressType < sorted[j].AddressType
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	anyReady := false
+	for _, slice := range sorted {
+		for _, endpoint := range slice.Endpoints {
+			if IsReady(endpoint) {
+				anyReady = true
+			}
+		}
+	}
+
+	seen := make(map[string]bool)
+
+	for _, slice := range sorted {
+		for k, v := range slice.Labels {
+			if endpoints.Labels == nil {
+				endpoints.Labels = make(map[string]string)
+			}
+			endpoints.Labels[k] = v
+		}
+
+		var subset corev1.EndpointSubset
+		for _, port := range slice.Ports {
+			p := corev1.EndpointPort{AppProtocol: port.AppProtocol}
+			if port.Name != nil {
+				p.Name = *port.Name
+			}
+			if port.Port != nil {
+				p.Port = *port.Port
+			}
+			if port.Protocol != nil {
+				p.Protocol = *port.Protocol
+			}
+			subset.Ports = append(subset.Ports, p)
+		}
+
+		for _, endpoint := range slice.Endpoints {
+			for _, addr := range endpoint.Addresses {
+				key := endpointKey(endpoint, addr)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+
+				address := corev1.EndpointAddress{
+					NodeName:  endpoint.NodeName,
+					TargetRef: endpoint.TargetRef,
+				}
+				if endpoint.Hostname != nil {
+					address.Hostname = *endpoint.Hostname
+				}
+				if slice.AddressType == discoveryv1.AddressTypeFQDN {
+					address.Hostname = addr
+				} else {
+					address.IP = addr
+				}
+
+				if IsReady(endpoint) || (!anyReady && IsTerminating(endpoint) && IsServing(endpoint)) {
+					subset.Addresses = append(subset.Addresses, address)
+				} else {
+					subset.NotReadyAddresses = append(subset.NotReadyAddresses, address)
+				}
+			}
+		}
+
+		if len(subset.Addresses) > 0 || len(subset.NotReadyAddresses) > 0 {
+			endpoints.Subsets = append(endpoints.Subsets, subset)
+		}
+	}
+
+	return endpoints
+}
+
+// endpointKey returns the key that identifies the endpoint across slices.
+// Endpoints of pods are identified by the pod since a dual-stack pod has
+// a different address in the slice of each address family.
+func endpointKey(endpoint discoveryv1.Endpoint, addr string) string {
+	if ref := endpoint.TargetRef; ref != nil && ref.Kind == "Pod" {
+		return "pod/" + ref.Namespace + "/" + ref.Name
+	}
+	return "address/" + addr
+}
+
+// Zones returns the zones of the endpoints of the slices mapped by address.
+// Addresses without a zone are omitted.
+func Zones(slices []discoveryv1.EndpointSlice) map[string]string {
+	zones := make(map[string]string)
+	for _, slice := range slices {
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Zone == nil || *endpoint.Zone == "" {
+				continue
+			}
+			for _, addr := range endpoint.Addresses {
+				zones[addr] = *endpoint.Zone
+			}
+		}
+	}
+	return zones
+}
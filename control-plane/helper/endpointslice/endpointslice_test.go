@@ -0,0 +1,229 @@
+package endpointslice
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIsReady(t *testing.T) {
+	cases := map[string]struct {
+		Conditions discoveryv1.EndpointConditions
+		Exp        bool
+	}{
+		"no conditions": {
+			Conditions: discoveryv1.EndpointConditions{},
+			Exp:        true,
+		},
+		"ready": {
+			Conditions: discoveryv1.EndpointConditions{Ready: boolPtr(true)},
+			Exp:        true,
+		},
+		"not ready": {
+			Conditions: discoveryv1.EndpointConditions{Ready: boolPtr(false)},
+			Exp:        false,
+		},
+		"terminating and serving": {
+			Conditions: discoveryv1.EndpointConditions{Ready: boolPtr(false), Serving: boolPtr(true), Terminating: boolPtr(true)},
+			Exp:        false,
+		},
+		"terminating with nil ready": {
+			Conditions: discoveryv1.EndpointConditions{Terminating: boolPtr(true)},
+			Exp:        false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.Exp, IsReady(discoveryv1.Endpoint{Conditions: c.Conditions}))
+		})
+	}
+}
+
+func TestToEndpoints(t *testing.T) {
+	node := "node-1"
+	targetRef := &corev1.ObjectReference{Kind: "Pod", Name: "pod-1", Namespace: "default"}
+	slices := []discoveryv1.EndpointSlice{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "foo-b",
+				Labels: map[string]string{discoveryv1.LabelServiceName: "foo", "app": "foo"},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Ports:       []discoveryv1.EndpointPort{{Name: stringPtr("http"), Port: int32Ptr(8080)}},
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"2.2.2.2"}, Conditions: discoveryv1.EndpointConditions{Ready: boolPtr(false)}},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   "foo-a",
+				Labels: map[string]string{discoveryv1.LabelServiceName: "foo"},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Ports:       []discoveryv1.EndpointPort{{Name: stringPtr("http"), Port: int32Ptr(8080)}},
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"1.1.1.1"}, NodeName: &node, TargetRef: targetRef, Zone: stringPtr("zone-a")},
+			},
+		},
+		{
+			// Slices without endpoints don't create a subset.
+			ObjectMeta:  metav1.ObjectMeta{Name: "foo-c"},
+			AddressType: discoveryv1.AddressTypeIPv4,
+		},
+	}
+
+	actual := ToEndpoints("foo", "default", slices)
+	require.Equal(t, &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "foo",
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "foo", "app": "foo"},
+		},
+		Subsets: []corev1.EndpointSubset{
+			{
+				Addresses: []corev1.EndpointAddress{{IP: "1.1.1.1", NodeName: &node, TargetRef: targetRef}},
+				Ports:     []corev1.EndpointPort{{Name: "http", Port: 8080}},
+			},
+			{
+				NotReadyAddresses: []corev1.EndpointAddress{{IP: "2.2.2.2"}},
+				Ports:             []corev1.EndpointPort{{Name: "http", Port: 8080}},
+			},
+		},
+	}, actual)
+
+	require.Equal(t, map[string]string{"1.1.1.1": "zone-a"}, Zones(slices))
+}
+
+func TestIsServing(t *testing.T) {
+	cases := map[string]struct {
+		Conditions discoveryv1.EndpointConditions
+		Exp        bool
+	}{
+		"no conditions": {
+			Conditions: discoveryv1.EndpointConditions{},
+			Exp:        true,
+		},
+		"not ready with nil serving": {
+			Conditions: discoveryv1.EndpointConditions{Ready: boolPtr(false)},
+			Exp:        false,
+		},
+		"terminating and serving": {
+			Conditions: discoveryv1.EndpointConditions{Ready: boolPtr(false), Serving: boolPtr(true), Terminating: boolPtr(true)},
+			Exp:        true,
+		},
+		"terminating and not serving": {
+			Conditions: discoveryv1.EndpointConditions{Ready: boolPtr(false), Serving: boolPtr(false), Terminating: boolPtr(true)},
+			Exp:        false,
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.Exp, IsServing(discoveryv1.Endpoint{Conditions: c.Conditions}))
+		})
+	}
+}
+
+func TestToEndpoints_TerminatingServing(t *testing.T) {
+	terminatingServing := discoveryv1.EndpointConditions{Ready: boolPtr(false), Serving: boolPtr(true), Terminating: boolPtr(true)}
+	terminating := discoveryv1.EndpointConditions{Ready: boolPtr(false), Serving: boolPtr(false), Terminating: boolPtr(true)}
+	cases := map[string]struct {
+		Endpoints []discoveryv1.Endpoint
+		Exp       corev1.EndpointSubset
+	}{
+		"ready endpoints": {
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"1.1.1.1"}},
+				{Addresses: []string{"2.2.2.2"}, Conditions: terminatingServing},
+			},
+			Exp: corev1.EndpointSubset{
+				Addresses:         []corev1.EndpointAddress{{IP: "1.1.1.1"}},
+				NotReadyAddresses: []corev1.EndpointAddress{{IP: "2.2.2.2"}},
+			},
+		},
+		"no ready endpoints": {
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"1.1.1.1"}, Conditions: terminating},
+				{Addresses: []string{"2.2.2.2"}, Conditions: terminatingServing},
+			},
+			Exp: corev1.EndpointSubset{
+				Addresses:         []corev1.EndpointAddress{{IP: "2.2.2.2"}},
+				NotReadyAddresses: []corev1.EndpointAddress{{IP: "1.1.1.1"}},
+			},
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			actual := ToEndpoints("foo", "default", []discoveryv1.EndpointSlice{
+				{
+					ObjectMeta:  metav1.ObjectMeta{Name: "foo-a"},
+					AddressType: discoveryv1.AddressTypeIPv4,
+					Endpoints:   c.Endpoints,
+				},
+			})
+			require.Equal(t, []corev1.EndpointSubset{c.Exp}, actual.Subsets)
+		})
+	}
+}
+
+func TestToEndpoints_Dedupe(t *testing.T) {
+	pod1 := &corev1.ObjectReference{Kind: "Pod", Name: "pod-1", Namespace: "default"}
+	pod2 := &corev1.ObjectReference{Kind: "Pod", Name: "pod-2", Namespace: "default"}
+	slices := []discoveryv1.EndpointSlice{
+		{
+			// The IPv6 slice of dual-stack pods sorts before the IPv4 slice
+			// by name but its endpoints are only used for pods that aren't
+			// in the IPv4 slice.
+			ObjectMeta:  metav1.ObjectMeta{Name: "foo-a"},
+			AddressType: discoveryv1.AddressTypeIPv6,
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"fd00::1"}, TargetRef: pod1},
+				{Addresses: []string{"fd00::2"}, TargetRef: pod2},
+			},
+		},
+		{
+			ObjectMeta:  metav1.ObjectMeta{Name: "foo-b"},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"1.1.1.1"}, TargetRef: pod1},
+				{Addresses: []string{"3.3.3.3"}},
+			},
+		},
+		{
+			// An endpoint without a pod that moved between slices.
+			ObjectMeta:  metav1.ObjectMeta{Name: "foo-c"},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints: []discoveryv1.Endpoint{
+				{Addresses: []string{"3.3.3.3"}},
+			},
+		},
+	}
+
+	actual := ToEndpoints("foo", "default", slices)
+	require.Equal(t, []corev1.EndpointSubset{
+		{
+			Addresses: []corev1.EndpointAddress{{IP: "1.1.1.1", TargetRef: pod1}, {IP: "3.3.3.3"}},
+		},
+		{
+			Addresses: []corev1.EndpointAddress{{IP: "fd00::2", TargetRef: pod2}},
+		},
+	}, actual.Subsets)
+}
+
+func TestListOptions(t *testing.T) {
+	require.Equal(t, "kubernetes.io/service-name=foo", ListOptions("foo").LabelSelector)
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
@@ -0,0 +1,358 @@
+// Package metrics contains the configuration of the merged metrics server of
+// the consul-sidecar and the helpers to relabel and re-encode the metrics it
+// merges.
+package metrics
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	// FormatPrometheus is the Prometheus text exposition format.
+	FormatPrometheus = "prometheus"
+	// FormatOpenMetrics is the OpenMetrics text format.
+	FormatOpenMetrics = "openmetrics"
+
+	defaultPath   = "/metrics"
+	defaultScheme = "http"
+
+	// TLSVolumesPath is the directory of the consul-sidecar container that
+	// the volumes holding the TLS files of the targets are mounted in.
+	TLSVolumesPath = "/consul/metrics-tls"
+)
+
+// MergingConfig configures which metrics endpoints of a pod the merged
+// metrics server scrapes in addition to Envoy and how their metrics are
+// merged.
+type MergingConfig struct {
+	// Format is the format of the merged metrics, either "prometheus"
+	// (the default) or "openmetrics".
+	Format string `json:"format,omitempty"`
+	// Envoy relabels the metrics of the Envoy sidecar.
+	Envoy Relabeling `json:"envoy,omitempty"`
+	// Targets are the metrics endpoints of the pod that are merged with the
+	// Envoy metrics.
+	Targets []Target `json:"targets,omitempty"`
+}
+
+// Relabeling configures how the metrics of a source are changed before they
+// are merged.
+type Relabeling struct {
+	// Prefix is prepended to the names of all metrics of the source to avoid
+	// collisions with the metrics of other sources.
+	Prefix string `json:"prefix,omitempty"`
+	// Labels are added to all metrics of the source, replacing labels of the
+	// same name, e.g. source="envoy".
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// Target is a metrics endpoint of the pod.
+type Target struct {
+	Relabeling
+
+	// Name identifies the target in logs and in the label of the success
+	// metric.
+	Name string `json:"name"`
+	// Port is the port the target is served on. The webhook resolves named
+	// ports of the pod to numbers.
+	Port string `json:"port"`
+	// Path is the path the target is served on, "/metrics" by default.
+	Path string `json:"path,omitempty"`
+	// Scheme is either "http" (the default) or "https".
+	Scheme string `json:"scheme,omitempty"`
+	// TLS configures the client when Scheme is "https".
+	TLS *TLSConfig `json:"tls,omitempty"`
+}
+
+// TLSConfig configures the TLS client of a target. The files are read from
+// Volume, a volume of the pod, e.g. of a Secret, that the webhook mounts into
+// the consul-sidecar container at TLSVolumesPath/<volume>. Their paths are
+// relative to the root of the volume.
+type TLSConfig struct {
+	Volume             string `json:"volume,omitempty"`
+	CAFile             string `json:"caFile,omitempty"`
+	CertFile           string `json:"certFile,omitempty"`
+	KeyFile            string `json:"keyFile,omitempty"`
+	ServerName         string `json:"serverName,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+}
+
+// ParseMergingConfig parses and validates the JSON merging configuration
+// and sets the defaults of its targets.
+func ParseMergingConfig(raw string) (MergingConfig, error) {
+	var config MergingConfig
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		return MergingConfig{}, err
+	}
+	for i := range config.Targets {
+		if config.Targets[i].Path == "" {
+			config.Targets[i].Path = defaultPath
+		}
+		if config.Targets[i].Scheme == "" {
+			config.Targets[i].Scheme = defaultScheme
+		}
+	}
+	if err := config.Validate(); err != nil {
+		return MergingConfig{}, err
+	}
+	return config, nil
+}
+
+// Validate returns an error if the configuration is invalid.
+func (c MergingConfig) Validate() error {
+	if c.Format != "" && c.Format != FormatPrometheus && c.Format != FormatOpenMetrics {
+		return fmt.Errorf("format %q must be one of %q or %q", c.Format, FormatPrometheus, FormatOpenMetrics)
+	}
+	if err := c.Envoy.validate(); err != nil {
+		return fmt.Errorf("envoy: %s", err)
+	}
+
+	names := make(map[string]bool)
+	for i, t := range c.Targets {
+		if t.Name == "" {
+			return fmt.Errorf("targets[%d]: name must be set", i)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("targets[%d]: duplicate name %q", i, t.Name)
+		}
+		names[t.Name] = true
+
+		if t.Port == "" {
+			return fmt.Errorf("target %q: port must be set", t.Name)
+		}
+		if t.Path != "" && !strings.HasPrefix(t.Path, "/") {
+			return fmt.Errorf("target %q: path %q must start with /", t.Name, t.Path)
+		}
+		if t.Scheme != "" && t.Scheme != "http" && t.Scheme != "https" {
+			return fmt.Errorf("target %q: scheme %q must be http or https", t.Name, t.Scheme)
+		}
+		if t.TLS != nil && t.Scheme != "https" {
+			return fmt.Errorf("target %q: tls can only be set if scheme is https", t.Name)
+		}
+		if t.TLS != nil && (t.TLS.CertFile == "") != (t.TLS.KeyFile == "") {
+			return fmt.Errorf("target %q: tls certFile and keyFile must be set together", t.Name)
+		}
+		if t.TLS != nil {
+			if err := t.TLS.validateFiles(); err != nil {
+				return fmt.Errorf("target %q: %s", t.Name, err)
+			}
+		}
+		if err := t.Relabeling.validate(); err != nil {
+			return fmt.Errorf("target %q: %s", t.Name, err)
+		}
+	}
+	return nil
+}
+
+// OpenMetrics returns true if the merged metrics are in the OpenMetrics
+// format.
+func (c MergingConfig) OpenMetrics() bool {
+	return c.Format == FormatOpenMetrics
+}
+
+// URL returns the URL the target is scraped from.
+func (t Target) URL() string {
+	scheme := t.Scheme
+	if scheme == "" {
+		scheme = defaultScheme
+	}
+	path := t.Path
+	if path == "" {
+		path = defaultPath
+	}
+	return fmt.Sprintf("%s://127.0.0.1:%s%s", scheme, t.Port, path)
+}
+
+// HTTPClient returns the client to scrape the target with.
+func (t Target) HTTPClient(timeout time.Duration) (*http.Client, error) {
+	client := &http.Client{Timeout: timeout}
+	if t.TLS == nil {
+		return client, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         t.TLS.ServerName,
+		InsecureSkipVerify: t.TLS.InsecureSkipVerify,
+	}
+	if t.TLS.CAFile != "" {
+		caPEM, err := ioutil.ReadFile(t.TLS.path(t.TLS.CAFile))
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file of target %q: %s", t.Name, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("CA file of target %q contains no certificates", t.Name)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if t.TLS.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.TLS.path(t.TLS.CertFile), t.TLS.path(t.TLS.KeyFile))
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate of target %q: %s", t.Name, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	return client, nil
+}
+
+// MountPath returns where the volume of the TLS files is mounted in the
+// consul-sidecar container.
+func (c TLSConfig) MountPath() string {
+	return path.Join(TLSVolumesPath, c.Volume)
+}
+
+// path returns the path of the file in the consul-sidecar container.
+func (c TLSConfig) path(file string) string {
+	return path.Join(c.MountPath(), file)
+}
+
+func (c TLSConfig) validateFiles() error {
+	files := map[string]string{"caFile": c.CAFile, "certFile": c.CertFile, "keyFile": c.KeyFile}
+	var names []string
+	for name, file := range files {
+		if file != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	if c.Volume == "" {
+		return fmt.Errorf("tls volume must be set to read %s from", strings.Join(names, ", "))
+	}
+	for _, name := range names {
+		file := files[name]
+		if path.IsAbs(file) || strings.HasPrefix(path.Clean(file), "..") {
+			return fmt.Errorf("tls %s %q must be a path relative to the volume", name, file)
+		}
+	}
+	return nil
+}
+
+// IsZero returns true if the relabeling doesn't change any metrics.
+func (r Relabeling) IsZero() bool {
+	return r.Prefix == "" && len(r.Labels) == 0
+}
+
+func (r Relabeling) validate() error {
+	if r.Prefix != "" && !model.IsValidMetricName(model.LabelValue(r.Prefix)) {
+		return fmt.Errorf("prefix %q is not a valid metric name prefix", r.Prefix)
+	}
+	for name := range r.Labels {
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return fmt.Errorf("label name %q is invalid", name)
+		}
+	}
+	return nil
+}
+
+// Apply prefixes the names of the metric families and adds the labels to
+// their metrics.
+func (r Relabeling) Apply(families []*dto.MetricFamily) {
+	labelNames := make([]string, 0, len(r.Labels))
+	for name := range r.Labels {
+		labelNames = append(labelNames, name)
+	}
+	sort.Strings(labelNames)
+
+	for _, family := range families {
+		if r.Prefix != "" {
+			family.Name = stringPtr(r.Prefix + family.GetName())
+		}
+		for _, metric := range family.Metric {
+			for _, name := range labelNames {
+				setLabel(metric, name, r.Labels[name])
+			}
+		}
+	}
+}
+
+// Decode parses metrics in the Prometheus text format. The families are
+// returned sorted by name.
+func Decode(body []byte) ([]*dto.MetricFamily, error) {
+	var parser expfmt.TextParser
+	familiesByName, err := parser.TextToMetricFamilies(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	families := make([]*dto.MetricFamily, 0, len(familiesByName))
+	for _, family := range familiesByName {
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].GetName() < families[j].GetName() })
+	return families, nil
+}
+
+// Encode writes the metric families to w in the Prometheus text format or,
+// if openMetrics is true, in the OpenMetrics format. The final "# EOF" line
+// of the OpenMetrics format isn't written so that the metrics of several
+// sources can be encoded one after the other.
+func Encode(w io.Writer, families []*dto.MetricFamily, openMetrics bool) error {
+	for _, family := range families {
+		var err error
+		if openMetrics {
+			_, err = expfmt.MetricFamilyToOpenMetrics(w, family)
+		} else {
+			_, err = expfmt.MetricFamilyToText(w, family)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Gauge returns a gauge metric family with one metric per label value.
+func Gauge(name, labelName string, values map[string]float64) *dto.MetricFamily {
+	labelValues := make([]string, 0, len(values))
+	for labelValue := range values {
+		labelValues = append(labelValues, labelValue)
+	}
+	sort.Strings(labelValues)
+
+	family := &dto.MetricFamily{
+		Name: stringPtr(name),
+		Type: dto.MetricType_GAUGE.Enum(),
+	}
+	for _, labelValue := range labelValues {
+		value := values[labelValue]
+		metric := &dto.Metric{Gauge: &dto.Gauge{Value: &value}}
+		setLabel(metric, labelName, labelValue)
+		family.Metric = append(family.Metric, metric)
+	}
+	return family
+}
+
+func setLabel(metric *dto.Metric, name, value string) {
+	for _, label := range metric.Label {
+		if label.GetName() == name {
+			label.Value = stringPtr(value)
+			return
+		}
+	}
+	metric.Label = append(metric.Label, &dto.LabelPair{Name: stringPtr(name), Value: stringPtr(value)})
+	sort.Slice(metric.Label, func(i, j int) bool { return metric.Label[i].GetName() < metric.Label[j].GetName() })
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
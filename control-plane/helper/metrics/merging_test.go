@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMergingConfig(t *testing.T) {
+	cases := map[string]struct {
+		raw    string
+		exp    MergingConfig
+		expErr string
+	}{
+		"defaults are set": {
+			raw: `{"targets": [{"name": "app", "port": "8080"}]}`,
+			exp: MergingConfig{
+				Targets: []Target{{Name: "app", Port: "8080", Path: "/metrics", Scheme: "http"}},
+			},
+		},
+		"all fields": {
+			raw: `{
+  "format": "openmetrics",
+  "envoy": {"labels": {"source": "envoy"}},
+  "targets": [
+    {"name": "jvm", "port": "9404", "path": "/jvm", "scheme": "https", "prefix": "jvm_", "labels": {"source": "jvm"},
+     "tls": {"volume": "jvm-certs", "caFile": "ca.pem", "serverName": "localhost"}}
+  ]
+}`,
+			exp: MergingConfig{
+				Format: FormatOpenMetrics,
+				Envoy:  Relabeling{Labels: map[string]string{"source": "envoy"}},
+				Targets: []Target{
+					{
+						Relabeling: Relabeling{Prefix: "jvm_", Labels: map[string]string{"source": "jvm"}},
+						Name:       "jvm",
+						Port:       "9404",
+						Path:       "/jvm",
+						Scheme:     "https",
+						TLS:        &TLSConfig{Volume: "jvm-certs", CAFile: "ca.pem", ServerName: "localhost"},
+					},
+				},
+			},
+		},
+		"invalid JSON": {
+			raw:    `{"targets": [`,
+			expErr: "unexpected EOF",
+		},
+		"unknown field": {
+			raw:    `{"target": []}`,
+			expErr: `json: unknown field "target"`,
+		},
+		"invalid format": {
+			raw:    `{"format": "json"}`,
+			expErr: `format "json" must be one of "prometheus" or "openmetrics"`,
+		},
+		"invalid envoy label": {
+			raw:    `{"envoy": {"labels": {"source-name": "envoy"}}}`,
+			expErr: `envoy: label name "source-name" is invalid`,
+		},
+		"reserved label": {
+			raw:    `{"envoy": {"labels": {"__name__": "envoy"}}}`,
+			expErr: `envoy: label name "__name__" is invalid`,
+		},
+		"missing name": {
+			raw:    `{"targets": [{"port": "8080"}]}`,
+			expErr: "targets[0]: name must be set",
+		},
+		"duplicate name": {
+			raw:    `{"targets": [{"name": "app", "port": "8080"}, {"name": "app", "port": "9090"}]}`,
+			expErr: `targets[1]: duplicate name "app"`,
+		},
+		"missing port": {
+			raw:    `{"targets": [{"name": "app"}]}`,
+			expErr: `target "app": port must be set`,
+		},
+		"relative path": {
+			raw:    `{"targets": [{"name": "app", "port": "8080", "path": "metrics"}]}`,
+			expErr: `target "app": path "metrics" must start with /`,
+		},
+		"invalid scheme": {
+			raw:    `{"targets": [{"name": "app", "port": "8080", "scheme": "tcp"}]}`,
+			expErr: `target "app": scheme "tcp" must be http or https`,
+		},
+		"tls without https": {
+			raw:    `{"targets": [{"name": "app", "port": "8080", "tls": {"insecureSkipVerify": true}}]}`,
+			expErr: `target "app": tls can only be set if scheme is https`,
+		},
+		"cert without key": {
+			raw:    `{"targets": [{"name": "app", "port": "8080", "scheme": "https", "tls": {"volume": "certs", "certFile": "tls.crt"}}]}`,
+			expErr: `target "app": tls certFile and keyFile must be set together`,
+		},
+		"files without volume": {
+			raw:    `{"targets": [{"name": "app", "port": "8080", "scheme": "https", "tls": {"caFile": "ca.crt", "certFile": "tls.crt", "keyFile": "tls.key"}}]}`,
+			expErr: `target "app": tls volume must be set to read caFile, certFile, keyFile from`,
+		},
+		"absolute file": {
+			raw:    `{"targets": [{"name": "app", "port": "8080", "scheme": "https", "tls": {"volume": "certs", "caFile": "/certs/ca.crt"}}]}`,
+			expErr: `target "app": tls caFile "/certs/ca.crt" must be a path relative to the volume`,
+		},
+		"file outside of volume": {
+			raw:    `{"targets": [{"name": "app", "port": "8080", "scheme": "https", "tls": {"volume": "certs", "caFile": "../ca.crt"}}]}`,
+			expErr: `target "app": tls caFile "../ca.crt" must be a path relative to the volume`,
+		},
+		"invalid prefix": {
+			raw:    `{"targets": [{"name": "app", "port": "8080", "prefix": "app-"}]}`,
+			expErr: `target "app": prefix "app-" is not a valid metric name prefix`,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			config, err := ParseMergingConfig(c.raw)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, config)
+		})
+	}
+}
+
+func TestTLSConfig_paths(t *testing.T) {
+	config := TLSConfig{Volume: "certs", CAFile: "ca/ca.crt"}
+	require.Equal(t, "/consul/metrics-tls/certs", config.MountPath())
+	require.Equal(t, "/consul/metrics-tls/certs/ca/ca.crt", config.path(config.CAFile))
+}
+
+func TestTarget_URL(t *testing.T) {
+	require.Equal(t, "http://127.0.0.1:8080/metrics", Target{Port: "8080"}.URL())
+	require.Equal(t, "https://127.0.0.1:9404/jvm", Target{Port: "9404", Path: "/jvm", Scheme: "https"}.URL())
+}
+
+func TestRelabeling(t *testing.T) {
+	body := []byte(`# HELP requests_total The number of requests.
+# TYPE requests_total counter
+requests_total{code="200",source="app"} 3
+# TYPE up gauge
+up 1
+`)
+
+	families, err := Decode(body)
+	require.NoError(t, err)
+	Relabeling{Prefix: "app_", Labels: map[string]string{"source": "jvm", "pod": "web"}}.Apply(families)
+
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, families, false))
+	require.Equal(t, `# HELP app_requests_total The number of requests.
+# TYPE app_requests_total counter
+app_requests_total{code="200",pod="web",source="jvm"} 3
+# TYPE app_up gauge
+app_up{pod="web",source="jvm"} 1
+`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, Encode(&buf, families, true))
+	require.Equal(t, `# HELP app_requests The number of requests.
+# TYPE app_requests counter
+app_requests_total{code="200",pod="web",source="jvm"} 3.0
+# TYPE app_up gauge
+app_up{pod="web",source="jvm"} 1.0
+`, buf.String())
+}
+
+func TestGauge(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, Encode(&buf, nil, false))
+	require.Empty(t, buf.String())
+
+	family := Gauge("consul_merged_service_metrics_success", "target", map[string]float64{"jvm": 0, "app": 1})
+	require.NoError(t, Encode(&buf, []*dto.MetricFamily{family}, false))
+	require.Equal(t, `# TYPE consul_merged_service_metrics_success gauge
+consul_merged_service_metrics_success{target="app"} 1
+consul_merged_service_metrics_success{target="jvm"} 0
+`, buf.String())
+}
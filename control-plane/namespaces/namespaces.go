@@ -4,6 +4,7 @@ package namespaces
 
 import (
 	"fmt"
+	"strings"
 
 	capi "github.com/hashicorp/consul/api"
 )
@@ -67,3 +68,11 @@ func ConsulNamespace(kubeNS string, enableConsulNamespaces bool, consulDestNS st
 
 	return consulDestNS
 }
+
+// K8SNamespace returns the Kubernetes namespace that mirrors the given
+// Consul namespace when syncing Consul services into Kubernetes. If the
+// Consul namespace starts with mirroringPrefix, the prefix is removed.
+// Kubernetes namespaces must be lowercase so the result is lowercased.
+func K8SNamespace(consulNS string, mirroringPrefix string) string {
+	return strings.ToLower(strings.TrimPrefix(consulNS, mirroringPrefix))
+}
@@ -204,3 +204,36 @@ func TestConsulNamespace(t *testing.T) {
 		})
 	}
 }
+
+func TestK8SNamespace(t *testing.T) {
+	cases := map[string]struct {
+		consulNS        string
+		mirroringPrefix string
+		expNS           string
+	}{
+		"no prefix": {
+			consulNS: "team-a",
+			expNS:    "team-a",
+		},
+		"prefix removed": {
+			consulNS:        "k8s-team-a",
+			mirroringPrefix: "k8s-",
+			expNS:           "team-a",
+		},
+		"namespace without prefix": {
+			consulNS:        "team-a",
+			mirroringPrefix: "k8s-",
+			expNS:           "team-a",
+		},
+		"lowercased": {
+			consulNS: "Team-A",
+			expNS:    "team-a",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			require.Equal(t, c.expNS, K8SNamespace(c.consulNS, c.mirroringPrefix))
+		})
+	}
+}
@@ -4,7 +4,11 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
+	"net"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +18,7 @@ import (
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
 	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/iptables"
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
 )
@@ -22,6 +27,12 @@ const (
 	defaultBearerTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
 	defaultTokenSinkFile   = "/consul/connect-inject/acl-token"
 	defaultProxyIDFile     = "/consul/connect-inject/proxyid"
+	defaultEnvoyAdminBind  = "127.0.0.1:19000"
+
+	// consulCACertPEMEnvName is the environment variable that holds the
+	// PEM-encoded CA certificate of the Consul API. It's an alternative to
+	// CONSUL_CACERT that doesn't require writing the CA to a file.
+	consulCACertPEMEnvName = "CONSUL_CACERT_PEM"
 
 	// The number of times to attempt to read this service (120s).
 	defaultServicePollingRetries = 120
@@ -37,6 +48,7 @@ type Command struct {
 	flagConsulServiceNamespace string // Consul destination namespace for the service.
 	flagServiceAccountName     string // Service account name.
 	flagServiceName            string // Service name.
+	flagConsulNodeName         string // Consul node the service is registered on if registered without an agent.
 	flagLogLevel               string
 	flagLogJSON                bool
 
@@ -44,8 +56,37 @@ type Command struct {
 	flagACLTokenSink                   string // Location to write the output token. Default is defaultTokenSinkFile.
 	flagProxyIDFile                    string // Location to write the output proxyID. Default is defaultProxyIDFile.
 	flagMultiPort                      bool
+	flagSharedServiceAccount           bool   // If the services of the multi-port pod share its service account.
 	serviceRegistrationPollingAttempts uint64 // Number of times to poll for this service to be registered.
 
+	// Flags to generate the Envoy bootstrap of the proxy.
+	flagEnvoyBootstrapFile    string // Location to write the Envoy bootstrap to. If empty, no bootstrap is written.
+	flagEnvoyAdminBind        string // Address of the Envoy admin API.
+	flagGRPCAddr              string // Address of the Consul gRPC API Envoy gets its configuration from.
+	flagPrometheusScrapePath  string
+	flagPrometheusBackendPort string
+	flagPrometheusCAFile      string
+	flagPrometheusCAPath      string
+	flagPrometheusCertFile    string
+	flagPrometheusKeyFile     string
+
+	// Flags to redirect the traffic of the pod to the proxy.
+	flagRedirectTraffic      bool
+	flagConsulDNSIP          string
+	flagProxyUID             int
+	flagExcludeInboundPorts  flags.AppendSliceValue
+	flagExcludeOutboundPorts flags.AppendSliceValue
+	flagExcludeOutboundCIDRs flags.AppendSliceValue
+	flagExcludeUIDs          flags.AppendSliceValue
+
+	// flagLifecycleBinary is where the binary is copied to so that the
+	// lifecycle hooks of the Envoy sidecar can run the envoy-lifecycle command.
+	flagLifecycleBinary string
+
+	// iptablesProvider applies the traffic redirection rules. It's only set
+	// in tests, by default the rules are applied with the iptables binary.
+	iptablesProvider iptables.Provider
+
 	flagSet *flag.FlagSet
 	http    *flags.HTTPFlags
 
@@ -63,10 +104,50 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagConsulServiceNamespace, "consul-service-namespace", "", "Consul destination namespace of the service.")
 	c.flagSet.StringVar(&c.flagServiceAccountName, "service-account-name", "", "Service account name on the pod.")
 	c.flagSet.StringVar(&c.flagServiceName, "service-name", "", "Service name as specified via the pod annotation.")
+	c.flagSet.StringVar(&c.flagConsulNodeName, "consul-node-name", "",
+		"Name of the Consul node the service is registered on in the catalog. If set, the service is looked up "+
+			"in the catalog of the Consul servers instead of on the local agent.")
 	c.flagSet.StringVar(&c.flagBearerTokenFile, "bearer-token-file", defaultBearerTokenFile, "Path to service account token file.")
 	c.flagSet.StringVar(&c.flagACLTokenSink, "acl-token-sink", defaultTokenSinkFile, "File name where where ACL token should be saved.")
 	c.flagSet.StringVar(&c.flagProxyIDFile, "proxy-id-file", defaultProxyIDFile, "File name where proxy's Consul service ID should be saved.")
 	c.flagSet.BoolVar(&c.flagMultiPort, "multiport", false, "If the pod is a multi port pod.")
+	c.flagSet.BoolVar(&c.flagSharedServiceAccount, "shared-service-account", false,
+		"If the services of the multi port pod share the service account of the pod. The ACL token is only used "+
+			"once it has the service identity of -service-name.")
+	c.flagSet.StringVar(&c.flagEnvoyBootstrapFile, "envoy-bootstrap-file", "",
+		"File name where the Envoy bootstrap of the proxy should be saved. If not set, no bootstrap is generated.")
+	c.flagSet.StringVar(&c.flagEnvoyAdminBind, "envoy-admin-bind", defaultEnvoyAdminBind,
+		"The address:port the Envoy admin API listens on.")
+	c.flagSet.StringVar(&c.flagGRPCAddr, "grpc-addr", "",
+		"The address of the Consul gRPC API Envoy gets its configuration from. Prefix it with https:// to use TLS. "+
+			"This can also be specified via the CONSUL_GRPC_ADDR environment variable.")
+	c.flagSet.StringVar(&c.flagPrometheusScrapePath, "prometheus-scrape-path", defaultPrometheusScrapePath,
+		"The path of the envoy_prometheus_bind_addr listener Prometheus scrapes.")
+	c.flagSet.StringVar(&c.flagPrometheusBackendPort, "prometheus-backend-port", "",
+		"The port on localhost the Prometheus scrape path is routed to instead of the Envoy metrics.")
+	c.flagSet.StringVar(&c.flagPrometheusCAFile, "prometheus-ca-file", "",
+		"Path to a CA file in the Envoy container to verify the clients of the Prometheus listener.")
+	c.flagSet.StringVar(&c.flagPrometheusCAPath, "prometheus-ca-path", "",
+		"Path to a directory of CA certificates in the Envoy container to verify the clients of the Prometheus listener.")
+	c.flagSet.StringVar(&c.flagPrometheusCertFile, "prometheus-cert-file", "",
+		"Path to the certificate file in the Envoy container the Prometheus listener serves.")
+	c.flagSet.StringVar(&c.flagPrometheusKeyFile, "prometheus-key-file", "",
+		"Path to the private key file in the Envoy container the Prometheus listener serves.")
+	c.flagSet.StringVar(&c.flagLifecycleBinary, "lifecycle-binary", "",
+		"File name where this binary is copied to so that the Envoy sidecar lifecycle hooks can run it. If not set, the binary is not copied.")
+	c.flagSet.BoolVar(&c.flagRedirectTraffic, "redirect-traffic", false,
+		"Redirect the inbound and outbound traffic of the pod to the proxy with iptables.")
+	c.flagSet.StringVar(&c.flagConsulDNSIP, "consul-dns-ip", "",
+		"IP of the Consul DNS server DNS queries of the pod are redirected to.")
+	c.flagSet.IntVar(&c.flagProxyUID, "proxy-uid", -1, "The user ID the proxy runs as.")
+	c.flagSet.Var(&c.flagExcludeInboundPorts, "exclude-inbound-port",
+		"Inbound port to exclude from traffic redirection. May be provided multiple times.")
+	c.flagSet.Var(&c.flagExcludeOutboundPorts, "exclude-outbound-port",
+		"Outbound port to exclude from traffic redirection. May be provided multiple times.")
+	c.flagSet.Var(&c.flagExcludeOutboundCIDRs, "exclude-outbound-cidr",
+		"Outbound CIDR to exclude from traffic redirection. May be provided multiple times.")
+	c.flagSet.Var(&c.flagExcludeUIDs, "exclude-uid",
+		"Additional user ID to exclude from traffic redirection. May be provided multiple times.")
 	c.flagSet.StringVar(&c.flagLogLevel, "log-level", "info",
 		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
 			"\"debug\", \"info\", \"warn\", and \"error\".")
@@ -104,9 +185,19 @@ func (c *Command) Run(args []string) int {
 			return 1
 		}
 	}
+	if c.flagLifecycleBinary != "" {
+		if err := copyExecutable(c.flagLifecycleBinary); err != nil {
+			c.logger.Error("Unable to copy binary for the Envoy lifecycle hooks", "error", err)
+			return 1
+		}
+	}
+
 	cfg := api.DefaultConfig()
 	cfg.Namespace = c.flagConsulServiceNamespace
 	c.http.MergeOntoConfig(cfg)
+	if caPEM := os.Getenv(consulCACertPEMEnvName); caPEM != "" && cfg.TLSConfig.CAFile == "" {
+		cfg.TLSConfig.CAPem = []byte(caPEM)
+	}
 	consulClient, err := consul.NewClient(cfg, c.http.ConsulAPITimeout())
 	if err != nil {
 		c.logger.Error("Unable to get client connection", "error", err)
@@ -114,10 +205,17 @@ func (c *Command) Run(args []string) int {
 	}
 
 	// First do the ACL Login, if necessary.
+	var loginParams common.LoginParams
 	if c.flagACLAuthMethod != "" {
 		// loginMeta is the default metadata that we pass to the consul login API.
 		loginMeta := map[string]string{"pod": fmt.Sprintf("%s/%s", c.flagPodNamespace, c.flagPodName)}
-		loginParams := common.LoginParams{
+		if c.flagSharedServiceAccount {
+			// The tokens of all services of the pod have the same service
+			// identities, so the endpoints controller finds the tokens of a
+			// service by its name in the metadata.
+			loginMeta["service"] = c.flagServiceName
+		}
+		loginParams = common.LoginParams{
 			AuthMethod:      c.flagACLAuthMethod,
 			Namespace:       c.flagAuthMethodNamespace,
 			BearerTokenFile: c.flagBearerTokenFile,
@@ -141,6 +239,7 @@ func (c *Command) Run(args []string) int {
 	// Now wait for the service to be registered. Do this by querying the Agent for a service
 	// which maps to this pod+namespace.
 	var proxyID string
+	var proxyService *api.AgentService
 	registrationRetryCount := 0
 	var errServiceNameMismatch error
 	// We need a new client so that we can use the ACL token that was fetched during login to do the next bit,
@@ -159,7 +258,7 @@ func (c *Command) Run(args []string) int {
 			// this one Pod. If so, we want to ensure the service and proxy matching our expected name is registered.
 			filter += fmt.Sprintf(` and (Service == %q or Service == "%s-sidecar-proxy")`, c.flagServiceName, c.flagServiceName)
 		}
-		serviceList, err := consulClient.Agent().ServicesWithFilter(filter)
+		serviceList, err := c.registeredServices(consulClient, filter)
 		if err != nil {
 			c.logger.Error("Unable to get Agent services", "error", err)
 			return err
@@ -183,7 +282,7 @@ func (c *Command) Run(args []string) int {
 		}
 		for _, svc := range serviceList {
 			c.logger.Info("Registered service has been detected", "service", svc.Service)
-			if c.flagACLAuthMethod != "" {
+			if c.flagACLAuthMethod != "" && !c.flagSharedServiceAccount {
 				if c.flagServiceName != "" && c.flagServiceAccountName != c.flagServiceName {
 					// Set the error but return nil so we don't retry.
 					errServiceNameMismatch = fmt.Errorf("service account name %s doesn't match annotation service name %s", c.flagServiceAccountName, c.flagServiceName)
@@ -199,6 +298,7 @@ func (c *Command) Run(args []string) int {
 			if svc.Kind == api.ServiceKindConnectProxy {
 				// This is the proxy service ID.
 				proxyID = svc.ID
+				proxyService = svc
 			}
 		}
 
@@ -218,16 +318,86 @@ func (c *Command) Run(args []string) int {
 		c.logger.Error(errServiceNameMismatch.Error())
 		return 1
 	}
-	// Write the proxy ID to the shared volume so `consul connect envoy` can use it for bootstrapping.
+	if c.flagACLAuthMethod != "" && c.flagSharedServiceAccount {
+		if err := c.loginWithServiceIdentity(consulClient, cfg, loginParams); err != nil {
+			c.logger.Error("Unable to get an ACL token with the service identity of the service", "service", c.flagServiceName, "error", err)
+			return 1
+		}
+		consulClient, err = consul.NewClient(cfg, c.http.ConsulAPITimeout())
+		if err != nil {
+			c.logger.Error("Unable to update client connection", "error", err)
+			return 1
+		}
+	}
+	// Write the proxy ID to the shared volume so the other containers of the pod can read it.
 	err = common.WriteFileWithPerms(c.flagProxyIDFile, proxyID, os.FileMode(0444))
 	if err != nil {
 		c.logger.Error("Unable to write proxy ID to file", "error", err)
 		return 1
 	}
+
+	if c.flagEnvoyBootstrapFile != "" || c.flagRedirectTraffic {
+		// Services registered with the local agent are read from it
+		// so that the proxy config is the one the agent serves to Envoy.
+		if c.flagConsulNodeName == "" {
+			proxyService, _, err = consulClient.Agent().Service(proxyID, nil)
+			if err != nil {
+				c.logger.Error("Unable to read proxy service", "error", err)
+				return 1
+			}
+		}
+	}
+	if c.flagEnvoyBootstrapFile != "" {
+		if err := c.writeEnvoyBootstrap(cfg, proxyService); err != nil {
+			c.logger.Error("Unable to write Envoy bootstrap", "error", err)
+			return 1
+		}
+	}
+	if c.flagRedirectTraffic {
+		iptablesCfg, err := c.iptablesConfig(proxyService)
+		if err != nil {
+			c.logger.Error("Unable to configure traffic redirection", "error", err)
+			return 1
+		}
+		if err := iptables.Setup(iptablesCfg); err != nil {
+			c.logger.Error("Unable to apply traffic redirection rules", "error", err)
+			return 1
+		}
+	}
 	c.logger.Info("Connect initialization completed")
 	return 0
 }
 
+// loginWithServiceIdentity makes sure that the ACL token in cfg has the
+// service identity of the service. The services of a multi-port pod sharing
+// its service account only get their service identity through the binding
+// rule the endpoints controller creates before it registers the service, so a
+// token created before that lacks it. Such tokens are logged out and
+// connect-init logs in again.
+func (c *Command) loginWithServiceIdentity(consulClient *api.Client, cfg *api.Config, loginParams common.LoginParams) error {
+	return backoff.Retry(func() error {
+		token, _, err := consulClient.ACL().TokenReadSelf(&api.QueryOptions{Token: cfg.Token})
+		if err != nil {
+			return fmt.Errorf("unable to read ACL token: %s", err)
+		}
+		for _, identity := range token.ServiceIdentities {
+			if identity.ServiceName == c.flagServiceName {
+				return nil
+			}
+		}
+
+		c.logger.Info("ACL token doesn't have the service identity of the service; logging in again", "service", c.flagServiceName)
+		if _, err := consulClient.ACL().Logout(&api.WriteOptions{Token: cfg.Token}); err != nil {
+			c.logger.Warn("Unable to log out ACL token", "error", err)
+		}
+		cfg.Token, err = common.ConsulLogin(consulClient, loginParams, c.logger)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("ACL token doesn't have the service identity of service %s", c.flagServiceName)
+	}, backoff.WithMaxRetries(backoff.NewConstantBackOff(1*time.Second), c.serviceRegistrationPollingAttempts))
+}
+
 func (c *Command) validateFlags() error {
 	if c.flagPodName == "" {
 		return errors.New("-pod-name must be set")
@@ -238,13 +408,108 @@ func (c *Command) validateFlags() error {
 	if c.flagACLAuthMethod != "" && c.flagServiceAccountName == "" {
 		return errors.New("-service-account-name must be set when ACLs are enabled")
 	}
+	if c.flagSharedServiceAccount && (!c.flagMultiPort || c.flagServiceName == "") {
+		return errors.New("-multiport and -service-name must be set when -shared-service-account is set")
+	}
 
 	if c.http.ConsulAPITimeout() <= 0 {
 		return errors.New("-consul-api-timeout must be set to a value greater than 0")
 	}
+	if c.flagEnvoyBootstrapFile != "" {
+		if c.flagGRPCAddr == "" {
+			c.flagGRPCAddr = os.Getenv(api.GRPCAddrEnvName)
+		}
+		if c.flagGRPCAddr == "" {
+			return errors.New("-grpc-addr must be set if -envoy-bootstrap-file is set")
+		}
+		if _, _, err := net.SplitHostPort(c.flagEnvoyAdminBind); err != nil {
+			return fmt.Errorf("-envoy-admin-bind is invalid: %s", err)
+		}
+		if c.flagPrometheusCertFile != "" || c.flagPrometheusKeyFile != "" || c.flagPrometheusCAFile != "" || c.flagPrometheusCAPath != "" {
+			if c.flagPrometheusCAFile == "" && c.flagPrometheusCAPath == "" {
+				return errors.New("-prometheus-ca-file or -prometheus-ca-path must be set when providing Prometheus TLS config")
+			}
+			if c.flagPrometheusCertFile == "" || c.flagPrometheusKeyFile == "" {
+				return errors.New("-prometheus-cert-file and -prometheus-key-file must be set when providing Prometheus TLS config")
+			}
+		}
+	}
+	if c.flagRedirectTraffic && c.flagProxyUID < 0 {
+		return errors.New("-proxy-uid must be set if -redirect-traffic is set")
+	}
 	return nil
 }
 
+// writeEnvoyBootstrap generates the Envoy bootstrap of the proxy and writes
+// it to the -envoy-bootstrap-file.
+func (c *Command) writeEnvoyBootstrap(cfg *api.Config, proxyService *api.AgentService) error {
+	adminHost, adminPortStr, _ := net.SplitHostPort(c.flagEnvoyAdminBind)
+	adminPort, err := strconv.Atoi(adminPortStr)
+	if err != nil {
+		return fmt.Errorf("invalid admin port %q: %s", adminPortStr, err)
+	}
+
+	token := cfg.Token
+	if token == "" && cfg.TokenFile != "" {
+		data, err := ioutil.ReadFile(cfg.TokenFile)
+		if err != nil {
+			return fmt.Errorf("reading token file: %s", err)
+		}
+		token = strings.TrimSpace(string(data))
+	}
+
+	caPEM := string(cfg.TLSConfig.CAPem)
+	if caPEM == "" && cfg.TLSConfig.CAFile != "" {
+		data, err := ioutil.ReadFile(cfg.TLSConfig.CAFile)
+		if err != nil {
+			return fmt.Errorf("reading CA file: %s", err)
+		}
+		caPEM = string(data)
+	}
+
+	bootstrap, err := envoyBootstrapConfig{
+		Proxy:                 proxyService,
+		Token:                 token,
+		NodeName:              c.flagConsulNodeName,
+		AdminBindAddress:      adminHost,
+		AdminBindPort:         adminPort,
+		GRPCAddr:              c.flagGRPCAddr,
+		GRPCCAPEM:             caPEM,
+		PrometheusScrapePath:  c.flagPrometheusScrapePath,
+		PrometheusBackendPort: c.flagPrometheusBackendPort,
+		PrometheusCAFile:      c.flagPrometheusCAFile,
+		PrometheusCAPath:      c.flagPrometheusCAPath,
+		PrometheusCertFile:    c.flagPrometheusCertFile,
+		PrometheusKeyFile:     c.flagPrometheusKeyFile,
+	}.generate()
+	if err != nil {
+		return err
+	}
+	return common.WriteFileWithPerms(c.flagEnvoyBootstrapFile, string(bootstrap), os.FileMode(0444))
+}
+
+// copyExecutable copies the running binary to dest. It is written to a
+// temporary file first so that a container never runs a partially written
+// binary.
+func copyExecutable(dest string) error {
+	src, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("reading %s: %s", src, err)
+	}
+	tmp := dest + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0755); err != nil {
+		return fmt.Errorf("writing %s: %s", tmp, err)
+	}
+	if err := os.Chmod(tmp, 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dest)
+}
+
 func (c *Command) Synopsis() string { return synopsis }
 func (c *Command) Help() string {
 	c.once.Do(c.init)
@@ -255,6 +520,32 @@ const synopsis = "Inject connect init command."
 const help = `
 Usage: consul-k8s-control-plane connect-init [options]
 
-  Bootstraps connect-injected pod components.
+  Bootstraps connect-injected pod components: waits for the services of the
+  pod to be registered, generates the Envoy bootstrap of their proxy and
+  redirects the traffic of the pod to the proxy if transparent proxy is
+  enabled.
   Not intended for stand-alone use.
 `
+
+// registeredServices returns the services matching the filter that are
+// registered on the local agent or, if -consul-node-name is set, on that
+// node in the catalog.
+func (c *Command) registeredServices(consulClient *api.Client, filter string) ([]*api.AgentService, error) {
+	if c.flagConsulNodeName != "" {
+		nodeServices, _, err := consulClient.Catalog().NodeServiceList(c.flagConsulNodeName, &api.QueryOptions{Filter: filter})
+		if err != nil || nodeServices == nil {
+			return nil, err
+		}
+		return nodeServices.Services, nil
+	}
+
+	services, err := consulClient.Agent().ServicesWithFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	var serviceList []*api.AgentService
+	for _, svc := range services {
+		serviceList = append(serviceList, svc)
+	}
+	return serviceList, nil
+}
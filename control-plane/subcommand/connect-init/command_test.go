@@ -1,6 +1,7 @@
 package connectinit
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -9,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -59,6 +61,54 @@ func TestRun_FlagValidation(t *testing.T) {
 				"-log-level", "invalid"},
 			expErr: "unknown log level: invalid",
 		},
+		{
+			flags: []string{
+				"-pod-name", testPodName,
+				"-pod-namespace", testPodNamespace,
+				"-consul-api-timeout", "5s",
+				"-envoy-bootstrap-file", "/consul/connect-inject/envoy-bootstrap.yaml",
+				"-grpc-addr", "10.0.0.1:8502",
+				"-envoy-admin-bind", "127.0.0.1"},
+			expErr: "-envoy-admin-bind is invalid: address 127.0.0.1: missing port in address",
+		},
+		{
+			flags: []string{
+				"-pod-name", testPodName,
+				"-pod-namespace", testPodNamespace,
+				"-consul-api-timeout", "5s",
+				"-envoy-bootstrap-file", "/consul/connect-inject/envoy-bootstrap.yaml",
+				"-grpc-addr", "10.0.0.1:8502",
+				"-prometheus-cert-file", "/certs/tls.crt",
+				"-prometheus-key-file", "/certs/tls.key"},
+			expErr: "-prometheus-ca-file or -prometheus-ca-path must be set when providing Prometheus TLS config",
+		},
+		{
+			flags: []string{
+				"-pod-name", testPodName,
+				"-pod-namespace", testPodNamespace,
+				"-consul-api-timeout", "5s",
+				"-envoy-bootstrap-file", "/consul/connect-inject/envoy-bootstrap.yaml",
+				"-grpc-addr", "10.0.0.1:8502",
+				"-prometheus-ca-file", "/certs/ca.crt",
+				"-prometheus-cert-file", "/certs/tls.crt"},
+			expErr: "-prometheus-cert-file and -prometheus-key-file must be set when providing Prometheus TLS config",
+		},
+		{
+			flags: []string{
+				"-pod-name", testPodName,
+				"-pod-namespace", testPodNamespace,
+				"-consul-api-timeout", "5s",
+				"-redirect-traffic"},
+			expErr: "-proxy-uid must be set if -redirect-traffic is set",
+		},
+		{
+			flags: []string{
+				"-pod-name", testPodName,
+				"-pod-namespace", testPodNamespace,
+				"-consul-api-timeout", "5s",
+				"-shared-service-account"},
+			expErr: "-multiport and -service-name must be set when -shared-service-account is set",
+		},
 	}
 	for _, c := range cases {
 		t.Run(c.expErr, func(t *testing.T) {
@@ -241,6 +291,98 @@ func TestRun_ServicePollingWithACLsAndTLS(t *testing.T) {
 	}
 }
 
+// TestRun_SharedServiceAccount validates that the service of a multi port pod
+// sharing the service account of the pod logs in again until its ACL token has
+// the service identity granted by the binding rule of the service.
+func TestRun_SharedServiceAccount(t *testing.T) {
+	t.Parallel()
+	bearerFile := common.WriteTempFile(t, test.ServiceAccountJWTToken)
+	tokenFile := fmt.Sprintf("/tmp/%d1", rand.Int())
+	proxyFile := fmt.Sprintf("/tmp/%d2", rand.Int())
+	t.Cleanup(func() {
+		os.Remove(proxyFile)
+		os.Remove(tokenFile)
+	})
+
+	masterToken := "b78d37c7-0ca7-5f4d-99ee-6d9975ce4586"
+	server, err := testutil.NewTestServerConfigT(t, func(c *testutil.TestServerConfig) {
+		c.ACL.Enabled = true
+		c.ACL.DefaultPolicy = "deny"
+		c.ACL.Tokens.InitialManagement = masterToken
+	})
+	require.NoError(t, err)
+	defer server.Stop()
+	server.WaitForLeader(t)
+	consulClient, err := api.NewClient(&api.Config{Address: server.HTTPAddr, Token: masterToken})
+	require.NoError(t, err)
+
+	test.SetupK8sAuthMethod(t, consulClient, testServiceAccountName, "default")
+
+	for _, svc := range []api.AgentServiceRegistration{consulCountingSvc, consulCountingSvcSidecar, consulCountingSvcMultiport, consulCountingSvcSidecarMultiport} {
+		require.NoError(t, consulClient.Agent().ServiceRegister(&svc))
+	}
+
+	// The binding rule of the service is only created once connect-init
+	// has logged in.
+	go func() {
+		time.Sleep(2 * time.Second)
+		_, _, err := consulClient.ACL().BindingRuleCreate(&api.ACLBindingRule{
+			AuthMethod: test.AuthMethod,
+			BindType:   api.BindingRuleBindTypeService,
+			BindName:   "counting-admin",
+			Selector:   fmt.Sprintf("serviceaccount.name==%q", testServiceAccountName),
+		}, nil)
+		require.NoError(t, err)
+	}()
+
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:                                 ui,
+		serviceRegistrationPollingAttempts: 10,
+	}
+	code := cmd.Run([]string{
+		"-pod-name", testPodName,
+		"-pod-namespace", testPodNamespace,
+		"-acl-auth-method", test.AuthMethod,
+		"-service-account-name", testServiceAccountName,
+		"-service-name", "counting-admin",
+		"-shared-service-account",
+		"-multiport",
+		"-http-addr", server.HTTPAddr,
+		"-bearer-token-file", bearerFile,
+		"-acl-token-sink", tokenFile,
+		"-proxy-id-file", proxyFile,
+		"-consul-api-timeout=5s",
+	})
+	require.Equal(t, 0, code, ui.ErrorWriter.String())
+
+	tokenData, err := ioutil.ReadFile(tokenFile)
+	require.NoError(t, err)
+	token, _, err := consulClient.ACL().TokenReadSelf(&api.QueryOptions{Token: string(tokenData)})
+	require.NoError(t, err)
+	require.Equal(t, "token created via login: {\"pod\":\"default-ns/counting-pod\",\"service\":\"counting-admin\"}", token.Description)
+	var identities []string
+	for _, identity := range token.ServiceIdentities {
+		identities = append(identities, identity.ServiceName)
+	}
+	require.ElementsMatch(t, []string{"counting", "counting-admin"}, identities)
+
+	// The token without the service identity has been logged out.
+	tokens, _, err := consulClient.ACL().TokenList(nil)
+	require.NoError(t, err)
+	loginTokens := 0
+	for _, token := range tokens {
+		if token.AuthMethod == test.AuthMethod {
+			loginTokens++
+		}
+	}
+	require.Equal(t, 1, loginTokens)
+
+	data, err := ioutil.ReadFile(proxyFile)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "counting-admin-sidecar-proxy-id")
+}
+
 // This test validates service polling works in a happy case scenario with and without TLS.
 func TestRun_ServicePollingOnly(t *testing.T) {
 	t.Parallel()
@@ -852,6 +994,170 @@ func TestRun_EnsureTokenExists(t *testing.T) {
 	}
 }
 
+// Test that services are looked up in the catalog on the given node
+// when -consul-node-name is set.
+func TestRun_ServicePollingFromCatalog(t *testing.T) {
+	t.Parallel()
+	proxyFile := common.WriteTempFile(t, "")
+
+	var agentServices map[string]*api.AgentService
+	require.NoError(t, json.Unmarshal([]byte(testServiceListResponse), &agentServices))
+	nodeServices := api.CatalogNodeServiceList{
+		Node: &api.Node{Node: "node1-virtual", Address: "10.0.0.1"},
+		Services: []*api.AgentService{
+			agentServices["counting-counting"],
+			agentServices["counting-counting-sidecar-proxy"],
+		},
+	}
+	nodeServicesJSON, err := json.Marshal(nodeServices)
+	require.NoError(t, err)
+
+	var filter string
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r != nil && r.URL.Path == "/v1/catalog/node-services/node1-virtual" && r.Method == "GET" {
+			filter = r.URL.Query().Get("filter")
+			w.Write(nodeServicesJSON)
+			return
+		}
+		// The agent must not be queried.
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer consulServer.Close()
+
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI: ui,
+	}
+	code := cmd.Run([]string{
+		"-pod-name", testPodName,
+		"-pod-namespace", testPodNamespace,
+		"-consul-node-name", "node1-virtual",
+		"-proxy-id-file", proxyFile,
+		"-http-addr", consulServer.URL,
+		"-consul-api-timeout", "5s",
+	})
+	require.Equal(t, 0, code, ui.ErrorWriter.String())
+	require.Contains(t, filter, fmt.Sprintf("Meta[%q] == %q", metaKeyPodName, testPodName))
+
+	proxydata, err := ioutil.ReadFile(proxyFile)
+	require.NoError(t, err)
+	require.Equal(t, "counting-counting-sidecar-proxy", string(proxydata))
+}
+
+// Test that the Envoy bootstrap is generated from the proxy service read
+// from the agent and that the traffic redirection rules are applied.
+func TestRun_EnvoyBootstrapAndRedirectTraffic(t *testing.T) {
+	t.Parallel()
+	proxyFile := common.WriteTempFile(t, "")
+	bootstrapFile := common.WriteTempFile(t, "")
+	require.NoError(t, os.Remove(bootstrapFile))
+	lifecycleBinary := common.WriteTempFile(t, "")
+
+	var agentServices map[string]*api.AgentService
+	require.NoError(t, json.Unmarshal([]byte(testServiceListResponse), &agentServices))
+	proxyService := *agentServices["counting-counting-sidecar-proxy"]
+	proxyService.Proxy.Config = map[string]interface{}{
+		"envoy_prometheus_bind_addr": "0.0.0.0:20200",
+		"envoy_stats_flush_interval": "10s",
+	}
+	proxyServiceJSON, err := json.Marshal(proxyService)
+	require.NoError(t, err)
+
+	consulServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r != nil && r.URL.Path == "/v1/agent/services" && r.Method == "GET" {
+			w.Write([]byte(testServiceListResponse))
+			return
+		}
+		if r != nil && r.URL.Path == "/v1/agent/service/counting-counting-sidecar-proxy" && r.Method == "GET" {
+			w.Write(proxyServiceJSON)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer consulServer.Close()
+
+	iptablesProvider := &fakeIptablesProvider{}
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:               ui,
+		iptablesProvider: iptablesProvider,
+	}
+	code := cmd.Run([]string{
+		"-pod-name", testPodName,
+		"-pod-namespace", testPodNamespace,
+		"-proxy-id-file", proxyFile,
+		"-http-addr", consulServer.URL,
+		"-token", "test-token",
+		"-consul-api-timeout", "5s",
+		"-envoy-bootstrap-file", bootstrapFile,
+		"-envoy-admin-bind", "127.0.0.1:19001",
+		"-grpc-addr", "10.0.0.1:8502",
+		"-prometheus-scrape-path", "/scrape",
+		"-prometheus-backend-port", "20100",
+		"-redirect-traffic",
+		"-consul-dns-ip", "10.0.34.16",
+		"-exclude-inbound-port", "9090",
+		"-exclude-outbound-cidr", "1.1.1.1/32",
+		"-proxy-uid", "5995",
+		"-lifecycle-binary", lifecycleBinary,
+	})
+	require.Equal(t, 0, code, ui.ErrorWriter.String())
+
+	// The running binary is copied for the lifecycle hooks of Envoy.
+	info, err := os.Stat(lifecycleBinary)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	require.NotZero(t, info.Size())
+
+	data, err := ioutil.ReadFile(bootstrapFile)
+	require.NoError(t, err)
+	var bootstrap interface{}
+	require.NoError(t, json.Unmarshal(data, &bootstrap))
+	for path, exp := range map[string]interface{}{
+		"node.id":      "counting-counting-sidecar-proxy",
+		"node.cluster": "counting",
+		"admin.address.socket_address.port_value": 19001.0,
+		"static_resources.clusters.0.load_assignment.endpoints.0.lb_endpoints.0.endpoint.address.socket_address.address": "10.0.0.1",
+		"static_resources.clusters.1.name":                                    "prometheus_backend",
+		"dynamic_resources.ads_config.grpc_services.initial_metadata.0.value": "test-token",
+		"stats_flush_interval":                                                "10s",
+	} {
+		require.Equal(t, exp, lookupPath(bootstrap, path), path)
+	}
+
+	rules := strings.Join(iptablesProvider.Rules(), "\n")
+	require.True(t, iptablesProvider.applied)
+	// Traffic is redirected to the public listener of the proxy.
+	require.Contains(t, rules, "--to-port 20000")
+	require.Contains(t, rules, "--to-port 15001")
+	require.Contains(t, rules, "--dport 9090 -j RETURN")
+	// The Prometheus listener must be reachable without the proxy.
+	require.Contains(t, rules, "--dport 20200 -j RETURN")
+	require.Contains(t, rules, "-d 1.1.1.1/32 -j RETURN")
+	require.Contains(t, rules, "--uid-owner 5995 -j RETURN")
+	require.Contains(t, rules, "10.0.34.16")
+}
+
+// fakeIptablesProvider records the traffic redirection rules instead of
+// applying them.
+type fakeIptablesProvider struct {
+	rules   []string
+	applied bool
+}
+
+func (f *fakeIptablesProvider) AddRule(_ string, args ...string) {
+	f.rules = append(f.rules, strings.Join(args, " "))
+}
+
+func (f *fakeIptablesProvider) ApplyRules() error {
+	f.applied = true
+	return nil
+}
+
+func (f *fakeIptablesProvider) Rules() []string {
+	return f.rules
+}
+
 const (
 	metaKeyPodName         = "pod-name"
 	metaKeyKubeNS          = "k8s-namespace"
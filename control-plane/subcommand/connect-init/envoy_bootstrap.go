@@ -0,0 +1,818 @@
+package connectinit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// Keys of the proxy config that are honored when generating the Envoy
+	// bootstrap. They match the keys `consul connect envoy` understands.
+	envoyPrometheusBindAddr       = "envoy_prometheus_bind_addr"
+	envoyStatsBindAddr            = "envoy_stats_bind_addr"
+	envoyStatsTags                = "envoy_stats_tags"
+	envoyStatsFlushInterval       = "envoy_stats_flush_interval"
+	envoyStatsdURL                = "envoy_statsd_url"
+	envoyDogstatsdURL             = "envoy_dogstatsd_url"
+	envoyStatsSinksJSON           = "envoy_stats_sinks_json"
+	envoyExtraStatsSinksJSON      = "envoy_extra_stats_sinks_json"
+	envoyTracingJSON              = "envoy_tracing_json"
+	envoyExtraStaticClustersJSON  = "envoy_extra_static_clusters_json"
+	envoyExtraStaticListenersJSON = "envoy_extra_static_listeners_json"
+	envoyStatsConfigJSON          = "envoy_stats_config_json"
+	envoyBootstrapJSONTpl         = "envoy_bootstrap_json_tpl"
+	envoyLocalAgentClusterName    = "local_agent"
+	envoySelfAdminClusterName     = "self_admin"
+	envoyPrometheusBackendCluster = "prometheus_backend"
+	envoyPrometheusStatsPath      = "/stats/prometheus"
+	defaultPrometheusScrapePath   = "/metrics"
+
+	// re2MaxProgramSize is the largest regex program Envoy accepts. It's
+	// raised from Envoy's default so that the regexes of the stats tags and
+	// of intentions with many sources are accepted.
+	re2MaxProgramSize = 1048576
+)
+
+// xdsEscapeHatches are the keys of the proxy config that replace parts of
+// the xDS configuration that Consul serves to Envoy. Consul applies them,
+// not the bootstrap, but they are validated here so that invalid JSON fails
+// the init container instead of leaving Envoy without configuration.
+var xdsEscapeHatches = []string{
+	"envoy_local_cluster_json",
+	"envoy_public_listener_json",
+	"envoy_listener_json",
+	"envoy_cluster_json",
+}
+
+// defaultStatsTagRegexes are the stats tags Consul adds to the stats of
+// the clusters and upstream listeners it generates. They are the same as
+// the ones `consul connect envoy` generates, without the deprecated tags
+// that aren't prefixed with consul.destination.
+//
+// The cluster names are
+// [<subset>.]<service>.<namespace>.[<partition>.]<datacenter>.<internal|external>.<trust domain>.consul
+// and the stat prefixes of upstream listeners are
+// upstream.<service>[.<namespace>][.<partition>].<datacenter>.
+var defaultStatsTagRegexes = []struct {
+	name  string
+	regex string
+}{
+	{
+		name:  "consul.destination.custom_hash",
+		regex: `^cluster\.(?:passthrough~)?((?:([^.]+)~)?(?:[^.]+\.)?[^.]+\.[^.]+\.(?:[^.]+\.)?[^.]+\.[^.]+\.[^.]+\.consul\.)`,
+	},
+	{
+		name:  "consul.destination.service_subset",
+		regex: `^cluster\.(?:passthrough~)?((?:[^.]+~)?([^.]+)\.(?:[^.]+\.)?[^.]+\.[^.]+\.(?:[^.]+\.)?[^.]+\.[^.]+\.[^.]+\.consul\.)`,
+	},
+	{
+		name:  "consul.destination.service",
+		regex: `^cluster\.(?:passthrough~)?((?:[^.]+~)?(?:[^.]+\.)?([^.]+)\.[^.]+\.(?:[^.]+\.)?[^.]+\.[^.]+\.[^.]+\.consul\.)`,
+	},
+	{
+		name:  "consul.destination.namespace",
+		regex: `^cluster\.(?:passthrough~)?((?:[^.]+~)?(?:[^.]+\.)?[^.]+\.([^.]+)\.(?:[^.]+\.)?[^.]+\.[^.]+\.[^.]+\.consul\.)`,
+	},
+	{
+		name:  "consul.destination.partition",
+		regex: `^cluster\.(?:passthrough~)?((?:[^.]+~)?(?:[^.]+\.)?[^.]+\.[^.]+\.(?:([^.]+)\.)?[^.]+\.[^.]+\.[^.]+\.consul\.)`,
+	},
+	{
+		name:  "consul.destination.datacenter",
+		regex: `^cluster\.(?:passthrough~)?((?:[^.]+~)?(?:[^.]+\.)?[^.]+\.[^.]+\.(?:[^.]+\.)?([^.]+)\.[^.]+\.[^.]+\.consul\.)`,
+	},
+	{
+		name:  "consul.destination.routing_type",
+		regex: `^cluster\.(?:passthrough~)?((?:[^.]+~)?(?:[^.]+\.)?[^.]+\.[^.]+\.(?:[^.]+\.)?[^.]+\.([^.]+)\.[^.]+\.consul\.)`,
+	},
+	{
+		name:  "consul.destination.trust_domain",
+		regex: `^cluster\.(?:passthrough~)?((?:[^.]+~)?(?:[^.]+\.)?[^.]+\.[^.]+\.(?:[^.]+\.)?[^.]+\.[^.]+\.([^.]+)\.consul\.)`,
+	},
+	{
+		name:  "consul.destination.target",
+		regex: `^cluster\.(?:passthrough~)?(((?:[^.]+~)?(?:[^.]+\.)?[^.]+\.[^.]+\.(?:[^.]+\.)?[^.]+)\.[^.]+\.[^.]+\.consul\.)`,
+	},
+	{
+		name:  "consul.destination.full_target",
+		regex: `^cluster\.(?:passthrough~)?(((?:[^.]+~)?(?:[^.]+\.)?[^.]+\.[^.]+\.(?:[^.]+\.)?[^.]+\.[^.]+\.[^.]+)\.consul\.)`,
+	},
+	{
+		name:  "consul.upstream.service",
+		regex: `^(?:tcp|http)\.upstream\.(([^.]+)(?:\.[^.]+)?(?:\.[^.]+)?\.[^.]+\.)`,
+	},
+	{
+		name:  "consul.upstream.namespace",
+		regex: `^(?:tcp|http)\.upstream\.([^.]+(?:\.([^.]+))?(?:\.[^.]+)?\.[^.]+\.)`,
+	},
+	{
+		name:  "consul.upstream.partition",
+		regex: `^(?:tcp|http)\.upstream\.([^.]+(?:\.[^.]+)?(?:\.([^.]+))?\.[^.]+\.)`,
+	},
+	{
+		name:  "consul.upstream.datacenter",
+		regex: `^(?:tcp|http)\.upstream\.([^.]+(?:\.[^.]+)?(?:\.[^.]+)?\.([^.]+)\.)`,
+	},
+}
+
+// jsonObject is a node of the Envoy bootstrap. The bootstrap is written as
+// JSON, which is valid YAML, with its keys sorted so that it's stable.
+type jsonObject = map[string]interface{}
+
+// envoyBootstrapConfig is the input of the Envoy bootstrap of a proxy.
+type envoyBootstrapConfig struct {
+	// Proxy is the proxy service as registered in Consul.
+	Proxy *api.AgentService
+	// Token is the ACL token Envoy uses to fetch its configuration.
+	Token string
+	// NodeName is the Consul node the proxy is registered on if it's
+	// registered without an agent. The Consul servers look the proxy up on
+	// this node when Envoy connects to them.
+	NodeName string
+
+	// AdminBindAddress and AdminBindPort are where Envoy serves its admin API.
+	AdminBindAddress string
+	AdminBindPort    int
+
+	// GRPCAddr is the address of the Consul gRPC API Envoy gets its
+	// configuration from, e.g. "https://10.0.0.1:8502" or "10.0.0.1:8502".
+	GRPCAddr string
+	// GRPCCAPEM is the PEM-encoded CA that verifies the gRPC API if it is
+	// served over TLS.
+	GRPCCAPEM string
+
+	// PrometheusScrapePath is the path of the envoy_prometheus_bind_addr
+	// listener Prometheus scrapes.
+	PrometheusScrapePath string
+	// PrometheusBackendPort is the port on localhost the scrape path is
+	// routed to instead of Envoy's own metrics, i.e. the merged metrics
+	// server.
+	PrometheusBackendPort string
+	// The files that configure TLS on the envoy_prometheus_bind_addr
+	// listener. They must exist in the Envoy container.
+	PrometheusCAFile   string
+	PrometheusCAPath   string
+	PrometheusCertFile string
+	PrometheusKeyFile  string
+}
+
+// generate returns the Envoy bootstrap of the proxy. If the proxy config
+// sets envoy_bootstrap_json_tpl, the bootstrap is rendered from that
+// template instead.
+func (b envoyBootstrapConfig) generate() ([]byte, error) {
+	if b.Proxy == nil || b.Proxy.Proxy == nil {
+		return nil, fmt.Errorf("service is not a connect proxy")
+	}
+	proxyConfig := b.Proxy.Proxy.Config
+
+	for _, key := range xdsEscapeHatches {
+		if raw := configString(proxyConfig, key); raw != "" && !json.Valid([]byte(raw)) {
+			return nil, fmt.Errorf("%s: invalid JSON", key)
+		}
+	}
+
+	localAgent, err := b.localAgentCluster()
+	if err != nil {
+		return nil, err
+	}
+	// The static clusters and listeners other than the cluster of the
+	// Consul gRPC API.
+	var clusters, listeners []interface{}
+
+	if addr := configString(proxyConfig, envoyPrometheusBindAddr); addr != "" {
+		listener, extraClusters, err := b.prometheusListener(addr)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+		clusters = appendClusters(clusters, extraClusters...)
+	}
+	if addr := configString(proxyConfig, envoyStatsBindAddr); addr != "" {
+		listener, err := adminListener("envoy_metrics_listener", addr, "/stats", envoySelfAdminClusterName, "/stats", nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", envoyStatsBindAddr, err)
+		}
+		listeners = append(listeners, listener)
+		clusters = appendClusters(clusters, b.selfAdminCluster())
+	}
+
+	extraClusters, err := configJSONList(proxyConfig, envoyExtraStaticClustersJSON)
+	if err != nil {
+		return nil, err
+	}
+	clusters = append(clusters, extraClusters...)
+	extraListeners, err := configJSONList(proxyConfig, envoyExtraStaticListenersJSON)
+	if err != nil {
+		return nil, err
+	}
+	listeners = append(listeners, extraListeners...)
+
+	namespace := b.Proxy.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	partition := b.Proxy.Partition
+	if partition == "" {
+		partition = "default"
+	}
+
+	statsConfig, err := b.statsConfig(namespace, partition)
+	if err != nil {
+		return nil, err
+	}
+	sinks, err := statsSinks(proxyConfig)
+	if err != nil {
+		return nil, err
+	}
+	var tracing interface{}
+	if raw := configString(proxyConfig, envoyTracingJSON); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &tracing); err != nil {
+			return nil, fmt.Errorf("%s: %s", envoyTracingJSON, err)
+		}
+	}
+
+	if tpl := configString(proxyConfig, envoyBootstrapJSONTpl); tpl != "" {
+		return b.renderTemplate(tpl, bootstrapTemplateArgs{
+			namespace:   namespace,
+			partition:   partition,
+			clusters:    clusters,
+			listeners:   listeners,
+			statsConfig: statsConfig,
+			sinks:       sinks,
+			tracing:     tracing,
+		})
+	}
+
+	staticResources := jsonObject{"clusters": append([]interface{}{localAgent}, clusters...)}
+	if len(listeners) > 0 {
+		staticResources["listeners"] = listeners
+	}
+	nodeMetadata := jsonObject{
+		"namespace": namespace,
+		"partition": partition,
+	}
+	if b.NodeName != "" {
+		nodeMetadata["node_name"] = b.NodeName
+	}
+	bootstrap := jsonObject{
+		"admin": jsonObject{
+			"access_log_path": os.DevNull,
+			"address":         socketAddress(b.AdminBindAddress, b.AdminBindPort),
+		},
+		"node": jsonObject{
+			"cluster":  b.Proxy.Proxy.DestinationServiceName,
+			"id":       b.Proxy.ID,
+			"metadata": nodeMetadata,
+		},
+		"layered_runtime": jsonObject{
+			"layers": []interface{}{
+				jsonObject{
+					"name": "base",
+					"static_layer": jsonObject{
+						"re2.max_program_size.error_level": re2MaxProgramSize,
+					},
+				},
+			},
+		},
+		"static_resources": staticResources,
+		"stats_config":     statsConfig,
+		"dynamic_resources": jsonObject{
+			"lds_config": jsonObject{"ads": jsonObject{}, "resource_api_version": "V3"},
+			"cds_config": jsonObject{"ads": jsonObject{}, "resource_api_version": "V3"},
+			"ads_config": jsonObject{
+				"api_type":              "DELTA_GRPC",
+				"transport_api_version": "V3",
+				"grpc_services": jsonObject{
+					"initial_metadata": []interface{}{
+						jsonObject{"key": "x-consul-token", "value": b.Token},
+					},
+					"envoy_grpc": jsonObject{"cluster_name": envoyLocalAgentClusterName},
+				},
+			},
+		},
+	}
+	if len(sinks) > 0 {
+		bootstrap["stats_sinks"] = sinks
+	}
+	if interval := configString(proxyConfig, envoyStatsFlushInterval); interval != "" {
+		bootstrap["stats_flush_interval"] = interval
+	}
+	if tracing != nil {
+		bootstrap["tracing"] = tracing
+	}
+
+	return json.MarshalIndent(bootstrap, "", "  ")
+}
+
+// bootstrapTemplateArgs are the parts of the bootstrap that are generated
+// before it's rendered from envoy_bootstrap_json_tpl.
+type bootstrapTemplateArgs struct {
+	namespace   string
+	partition   string
+	clusters    []interface{}
+	listeners   []interface{}
+	statsConfig interface{}
+	sinks       []interface{}
+	tracing     interface{}
+}
+
+// bootstrapTpl are the fields envoy_bootstrap_json_tpl is rendered with.
+// They have the same names as the fields `consul connect envoy` renders the
+// template with, so that templates written for it keep working. The *JSON
+// fields of lists hold the comma-separated items without brackets.
+type bootstrapTpl struct {
+	GRPC                  bootstrapTplGRPC
+	ProxyCluster          string
+	ProxyID               string
+	NodeName              string
+	ProxySourceService    string
+	AgentCAPEM            string
+	AdminAccessLogPath    string
+	AdminBindAddress      string
+	AdminBindPort         string
+	LocalAgentClusterName string
+	Token                 string
+	StaticClustersJSON    string
+	StaticListenersJSON   string
+	StatsSinksJSON        string
+	StatsConfigJSON       string
+	StatsFlushInterval    string
+	TracingConfigJSON     string
+	Namespace             string
+	Partition             string
+	Datacenter            string
+	PrometheusBackendPort string
+	PrometheusScrapePath  string
+	PrometheusCAFile      string
+	PrometheusCAPath      string
+	PrometheusCertFile    string
+	PrometheusKeyFile     string
+}
+
+type bootstrapTplGRPC struct {
+	AgentAddress string
+	AgentPort    string
+	AgentTLS     bool
+}
+
+// renderTemplate renders the bootstrap from the envoy_bootstrap_json_tpl
+// template of the proxy config.
+func (b envoyBootstrapConfig) renderTemplate(tpl string, args bootstrapTemplateArgs) ([]byte, error) {
+	t, err := template.New("bootstrap").Parse(tpl)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", envoyBootstrapJSONTpl, err)
+	}
+
+	host, port, useTLS, err := b.grpcAddress()
+	if err != nil {
+		return nil, err
+	}
+	// The CA is rendered into a JSON string.
+	caPEM, err := json.Marshal(b.GRPCCAPEM)
+	if err != nil {
+		return nil, err
+	}
+	statsConfig, err := json.Marshal(args.statsConfig)
+	if err != nil {
+		return nil, err
+	}
+	data := bootstrapTpl{
+		GRPC: bootstrapTplGRPC{
+			AgentAddress: host,
+			AgentPort:    strconv.Itoa(port),
+			AgentTLS:     useTLS,
+		},
+		ProxyCluster:          b.Proxy.Proxy.DestinationServiceName,
+		ProxyID:               b.Proxy.ID,
+		NodeName:              b.NodeName,
+		ProxySourceService:    b.Proxy.Proxy.DestinationServiceName,
+		AgentCAPEM:            strings.Trim(string(caPEM), `"`),
+		AdminAccessLogPath:    os.DevNull,
+		AdminBindAddress:      b.AdminBindAddress,
+		AdminBindPort:         strconv.Itoa(b.AdminBindPort),
+		LocalAgentClusterName: envoyLocalAgentClusterName,
+		Token:                 b.Token,
+		StatsConfigJSON:       string(statsConfig),
+		StatsFlushInterval:    configString(b.Proxy.Proxy.Config, envoyStatsFlushInterval),
+		Namespace:             args.namespace,
+		Partition:             args.partition,
+		Datacenter:            b.Proxy.Datacenter,
+		PrometheusBackendPort: b.PrometheusBackendPort,
+		PrometheusScrapePath:  b.PrometheusScrapePath,
+		PrometheusCAFile:      b.PrometheusCAFile,
+		PrometheusCAPath:      b.PrometheusCAPath,
+		PrometheusCertFile:    b.PrometheusCertFile,
+		PrometheusKeyFile:     b.PrometheusKeyFile,
+	}
+	if data.StaticClustersJSON, err = joinJSON(args.clusters); err != nil {
+		return nil, err
+	}
+	if data.StaticListenersJSON, err = joinJSON(args.listeners); err != nil {
+		return nil, err
+	}
+	if len(args.sinks) > 0 {
+		sinks, err := json.Marshal(args.sinks)
+		if err != nil {
+			return nil, err
+		}
+		data.StatsSinksJSON = string(sinks)
+	}
+	if args.tracing != nil {
+		tracing, err := json.Marshal(args.tracing)
+		if err != nil {
+			return nil, err
+		}
+		data.TracingConfigJSON = string(tracing)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("%s: %s", envoyBootstrapJSONTpl, err)
+	}
+	if !json.Valid(buf.Bytes()) {
+		return nil, fmt.Errorf("%s: rendered bootstrap is not valid JSON", envoyBootstrapJSONTpl)
+	}
+	return buf.Bytes(), nil
+}
+
+// joinJSON returns the items as comma-separated JSON without brackets.
+func joinJSON(items []interface{}) (string, error) {
+	var parts []string
+	for _, item := range items {
+		raw, err := json.Marshal(item)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, string(raw))
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// localAgentCluster returns the cluster of the Consul gRPC API.
+func (b envoyBootstrapConfig) localAgentCluster() (jsonObject, error) {
+	host, port, useTLS, err := b.grpcAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	c := cluster(envoyLocalAgentClusterName, host, port)
+	c["typed_extension_protocol_options"] = jsonObject{
+		"envoy.extensions.upstreams.http.v3.HttpProtocolOptions": jsonObject{
+			"@type":                "type.googleapis.com/envoy.extensions.upstreams.http.v3.HttpProtocolOptions",
+			"explicit_http_config": jsonObject{"http2_protocol_options": jsonObject{}},
+		},
+	}
+	if useTLS {
+		if b.GRPCCAPEM == "" {
+			return nil, fmt.Errorf("a CA must be set when the gRPC address %q uses TLS", b.GRPCAddr)
+		}
+		c["transport_socket"] = jsonObject{
+			"name": "tls",
+			"typed_config": jsonObject{
+				"@type": "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.UpstreamTlsContext",
+				"common_tls_context": jsonObject{
+					"validation_context": jsonObject{
+						"trusted_ca": jsonObject{"inline_string": b.GRPCCAPEM},
+					},
+				},
+			},
+		}
+	}
+	return c, nil
+}
+
+// grpcAddress returns the host and port of the Consul gRPC API and whether
+// it's served over TLS.
+func (b envoyBootstrapConfig) grpcAddress() (string, int, bool, error) {
+	addr := b.GRPCAddr
+	useTLS := false
+	if strings.Contains(addr, "://") {
+		u, err := url.Parse(addr)
+		if err != nil {
+			return "", 0, false, fmt.Errorf("invalid gRPC address %q: %s", b.GRPCAddr, err)
+		}
+		useTLS = u.Scheme == "https"
+		addr = u.Host
+	}
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", 0, false, fmt.Errorf("invalid gRPC address %q: %s", b.GRPCAddr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, false, fmt.Errorf("invalid gRPC address %q: %s", b.GRPCAddr, err)
+	}
+	return host, port, useTLS, nil
+}
+
+// selfAdminCluster returns the cluster that routes to Envoy's own admin API.
+func (b envoyBootstrapConfig) selfAdminCluster() jsonObject {
+	return cluster(envoySelfAdminClusterName, b.AdminBindAddress, b.AdminBindPort)
+}
+
+// prometheusListener returns the envoy_prometheus_bind_addr listener and
+// the clusters it routes to.
+func (b envoyBootstrapConfig) prometheusListener(addr string) (jsonObject, []jsonObject, error) {
+	scrapePath := b.PrometheusScrapePath
+	if scrapePath == "" {
+		scrapePath = defaultPrometheusScrapePath
+	}
+
+	targetCluster := b.selfAdminCluster()
+	if b.PrometheusBackendPort != "" {
+		port, err := strconv.Atoi(b.PrometheusBackendPort)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid prometheus backend port %q: %s", b.PrometheusBackendPort, err)
+		}
+		targetCluster = cluster(envoyPrometheusBackendCluster, "127.0.0.1", port)
+	}
+
+	var transportSocket jsonObject
+	if b.PrometheusCertFile != "" {
+		validationContext := jsonObject{}
+		if b.PrometheusCAFile != "" {
+			validationContext["trusted_ca"] = jsonObject{"filename": b.PrometheusCAFile}
+		} else if b.PrometheusCAPath != "" {
+			validationContext["watched_directory"] = jsonObject{"path": b.PrometheusCAPath}
+		}
+		transportSocket = jsonObject{
+			"name": "tls",
+			"typed_config": jsonObject{
+				"@type": "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.DownstreamTlsContext",
+				"common_tls_context": jsonObject{
+					"tls_certificates": []interface{}{
+						jsonObject{
+							"certificate_chain": jsonObject{"filename": b.PrometheusCertFile},
+							"private_key":       jsonObject{"filename": b.PrometheusKeyFile},
+						},
+					},
+					"validation_context": validationContext,
+				},
+			},
+		}
+	}
+
+	listener, err := adminListener("envoy_prometheus_metrics_listener", addr, scrapePath, targetCluster["name"].(string), envoyPrometheusStatsPath, transportSocket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", envoyPrometheusBindAddr, err)
+	}
+	return listener, []jsonObject{targetCluster}, nil
+}
+
+// statsConfig returns the stats config of Envoy. If the proxy config sets
+// envoy_stats_config_json, it's used as is. Otherwise, the tags of the stats
+// are the fixed tags of the proxy config, followed by Consul's tags of the
+// clusters and upstreams it generates and the tags that identify the proxy.
+// Consul's tags aren't added if the proxy config sets a tag of the same
+// name.
+func (b envoyBootstrapConfig) statsConfig(namespace, partition string) (interface{}, error) {
+	if raw := configString(b.Proxy.Proxy.Config, envoyStatsConfigJSON); raw != "" {
+		var statsConfig interface{}
+		if err := json.Unmarshal([]byte(raw), &statsConfig); err != nil {
+			return nil, fmt.Errorf("%s: %s", envoyStatsConfigJSON, err)
+		}
+		return statsConfig, nil
+	}
+
+	var tags []interface{}
+	custom := make(map[string]bool)
+	for _, tag := range configStringList(b.Proxy.Proxy.Config, envoyStatsTags) {
+		parts := strings.SplitN(tag, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		custom[parts[0]] = true
+		tags = append(tags, jsonObject{"tag_name": parts[0], "fixed_value": parts[1]})
+	}
+	for _, tag := range defaultStatsTagRegexes {
+		if custom[tag.name] {
+			continue
+		}
+		tags = append(tags, jsonObject{"tag_name": tag.name, "regex": tag.regex})
+	}
+	for _, tag := range []struct{ name, value string }{
+		{"local_cluster", b.Proxy.Proxy.DestinationServiceName},
+		{"consul.source.service", b.Proxy.Proxy.DestinationServiceName},
+		{"consul.source.namespace", namespace},
+		{"consul.source.partition", partition},
+		{"consul.source.datacenter", b.Proxy.Datacenter},
+	} {
+		if custom[tag.name] || tag.value == "" {
+			continue
+		}
+		tags = append(tags, jsonObject{"tag_name": tag.name, "fixed_value": tag.value})
+	}
+	return jsonObject{
+		"stats_tags":           tags,
+		"use_all_default_tags": true,
+	}, nil
+}
+
+// statsSinks returns the stats sinks the proxy config sets.
+func statsSinks(proxyConfig map[string]interface{}) ([]interface{}, error) {
+	var sinks []interface{}
+	for _, key := range []string{envoyStatsdURL, envoyDogstatsdURL} {
+		raw := configString(proxyConfig, key)
+		if raw == "" {
+			continue
+		}
+		// Allow the address to be set from the environment, e.g.
+		// udp://${HOST_IP}:8125.
+		u, err := url.Parse(os.ExpandEnv(raw))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", key, err)
+		}
+		if u.Scheme != "udp" {
+			return nil, fmt.Errorf("%s: scheme %q must be udp", key, u.Scheme)
+		}
+		port, err := strconv.Atoi(u.Port())
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid port %q", key, u.Port())
+		}
+		name, sinkType := "envoy.stat_sinks.statsd", "StatsdSink"
+		if key == envoyDogstatsdURL {
+			name, sinkType = "envoy.stat_sinks.dog_statsd", "DogStatsdSink"
+		}
+		sinks = append(sinks, jsonObject{
+			"name": name,
+			"typed_config": jsonObject{
+				"@type":   "type.googleapis.com/envoy.config.metrics.v3." + sinkType,
+				"address": socketAddress(u.Hostname(), port),
+			},
+		})
+	}
+
+	if raw := configString(proxyConfig, envoyStatsSinksJSON); raw != "" {
+		configured, err := configJSONList(proxyConfig, envoyStatsSinksJSON)
+		if err != nil {
+			return nil, err
+		}
+		// The stats sinks replace the sinks of the URLs.
+		sinks = configured
+	}
+	extra, err := configJSONList(proxyConfig, envoyExtraStatsSinksJSON)
+	if err != nil {
+		return nil, err
+	}
+	return append(sinks, extra...), nil
+}
+
+// adminListener returns a listener on addr that routes the path to the
+// cluster, rewriting it to rewritePath, and rejects all other paths.
+func adminListener(name, addr, path, clusterName, rewritePath string, transportSocket jsonObject) (jsonObject, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", portStr)
+	}
+
+	filterChain := jsonObject{
+		"filters": []interface{}{
+			jsonObject{
+				"name": "envoy.filters.network.http_connection_manager",
+				"typed_config": jsonObject{
+					"@type":       "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager",
+					"stat_prefix": name,
+					"codec_type":  "HTTP1",
+					"route_config": jsonObject{
+						"name": "self_admin_route",
+						"virtual_hosts": []interface{}{
+							jsonObject{
+								"name":    "self_admin",
+								"domains": []interface{}{"*"},
+								"routes": []interface{}{
+									jsonObject{
+										"match": jsonObject{"path": path},
+										"route": jsonObject{
+											"cluster":        clusterName,
+											"prefix_rewrite": rewritePath,
+										},
+									},
+									jsonObject{
+										"match":           jsonObject{"prefix": "/"},
+										"direct_response": jsonObject{"status": 404},
+									},
+								},
+							},
+						},
+					},
+					"http_filters": []interface{}{
+						jsonObject{
+							"name":         "envoy.filters.http.router",
+							"typed_config": jsonObject{"@type": "type.googleapis.com/envoy.extensions.filters.http.router.v3.Router"},
+						},
+					},
+				},
+			},
+		},
+	}
+	if transportSocket != nil {
+		filterChain["transport_socket"] = transportSocket
+	}
+	return jsonObject{
+		"name":          name + ":" + addr,
+		"address":       socketAddress(host, port),
+		"filter_chains": []interface{}{filterChain},
+	}, nil
+}
+
+// cluster returns a cluster with a single endpoint. Hostnames are resolved
+// by Envoy.
+func cluster(name, host string, port int) jsonObject {
+	clusterType := "STATIC"
+	if net.ParseIP(host) == nil {
+		clusterType = "LOGICAL_DNS"
+	}
+	return jsonObject{
+		"name":                          name,
+		"ignore_health_on_host_removal": false,
+		"connect_timeout":               "1s",
+		"type":                          clusterType,
+		"load_assignment": jsonObject{
+			"cluster_name": name,
+			"endpoints": []interface{}{
+				jsonObject{
+					"lb_endpoints": []interface{}{
+						jsonObject{"endpoint": jsonObject{"address": socketAddress(host, port)}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// appendClusters appends the clusters that aren't in the list yet.
+func appendClusters(clusters []interface{}, toAdd ...jsonObject) []interface{} {
+	for _, c := range toAdd {
+		found := false
+		for _, existing := range clusters {
+			if existing.(jsonObject)["name"] == c["name"] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			clusters = append(clusters, c)
+		}
+	}
+	return clusters
+}
+
+func socketAddress(host string, port int) jsonObject {
+	return jsonObject{"socket_address": jsonObject{"address": host, "port_value": port}}
+}
+
+// configString returns the string value of the key of the proxy config.
+func configString(proxyConfig map[string]interface{}, key string) string {
+	if v, ok := proxyConfig[key].(string); ok {
+		return strings.TrimSpace(v)
+	}
+	return ""
+}
+
+// configStringList returns the value of the key of the proxy config that is
+// either a list of strings or a comma-separated string.
+func configStringList(proxyConfig map[string]interface{}, key string) []string {
+	var list []string
+	switch v := proxyConfig[key].(type) {
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+	}
+	return list
+}
+
+// configJSONList parses the value of the key of the proxy config, which is
+// one or more comma-separated JSON objects.
+func configJSONList(proxyConfig map[string]interface{}, key string) ([]interface{}, error) {
+	raw := configString(proxyConfig, key)
+	if raw == "" {
+		return nil, nil
+	}
+	var list []interface{}
+	if err := json.Unmarshal([]byte("["+raw+"]"), &list); err != nil {
+		return nil, fmt.Errorf("%s: %s", key, err)
+	}
+	return list, nil
+}
@@ -0,0 +1,373 @@
+package connectinit
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvoyBootstrap(t *testing.T) {
+	proxy := func(config map[string]interface{}) *api.AgentService {
+		return &api.AgentService{
+			Kind:       api.ServiceKindConnectProxy,
+			ID:         "counting-counting-sidecar-proxy",
+			Service:    "counting-sidecar-proxy",
+			Datacenter: "dc1",
+			Proxy: &api.AgentServiceConnectProxyConfig{
+				DestinationServiceName: "counting",
+				DestinationServiceID:   "counting-counting",
+				Config:                 config,
+			},
+		}
+	}
+
+	cases := map[string]struct {
+		config envoyBootstrapConfig
+		// exp are the expected values of the paths of the bootstrap.
+		exp    map[string]interface{}
+		expErr string
+	}{
+		"defaults": {
+			config: envoyBootstrapConfig{
+				Proxy:            proxy(nil),
+				Token:            "token",
+				AdminBindAddress: "127.0.0.1",
+				AdminBindPort:    19000,
+				GRPCAddr:         "10.0.0.1:8502",
+			},
+			exp: map[string]interface{}{
+				"node.id":                 "counting-counting-sidecar-proxy",
+				"node.cluster":            "counting",
+				"node.metadata.namespace": "default",
+				"node.metadata.node_name": nil,
+				"admin.address.socket_address.port_value":                             19000.0,
+				"static_resources.clusters.0.name":                                    "local_agent",
+				"static_resources.clusters.0.type":                                    "STATIC",
+				"static_resources.clusters.0.transport_socket":                        nil,
+				"static_resources.listeners":                                          nil,
+				"dynamic_resources.ads_config.api_type":                               "DELTA_GRPC",
+				"dynamic_resources.ads_config.grpc_services.initial_metadata.0.value": "token",
+				"stats_config.stats_tags.0.tag_name":                                  "consul.destination.custom_hash",
+				"stats_config.stats_tags.18.tag_name":                                 "consul.source.datacenter",
+				"stats_config.stats_tags.18.fixed_value":                              "dc1",
+				"layered_runtime.layers.0.name":                                       "base",
+				"stats_sinks":                                                         nil,
+				"tracing":                                                             nil,
+			},
+		},
+		"registered without an agent": {
+			config: envoyBootstrapConfig{
+				Proxy:            proxy(nil),
+				NodeName:         "node-1-virtual",
+				AdminBindAddress: "127.0.0.1",
+				AdminBindPort:    19000,
+				GRPCAddr:         "10.0.0.2:8503",
+			},
+			exp: map[string]interface{}{
+				"node.id":                 "counting-counting-sidecar-proxy",
+				"node.metadata.node_name": "node-1-virtual",
+			},
+		},
+		"TLS and hostname": {
+			config: envoyBootstrapConfig{
+				Proxy:            proxy(nil),
+				AdminBindAddress: "127.0.0.1",
+				AdminBindPort:    19001,
+				GRPCAddr:         "https://consul-server.default.svc:8503",
+				GRPCCAPEM:        "ca-pem",
+			},
+			exp: map[string]interface{}{
+				"admin.address.socket_address.port_value": 19001.0,
+				"static_resources.clusters.0.type":        "LOGICAL_DNS",
+				"static_resources.clusters.0.load_assignment.endpoints.0.lb_endpoints.0.endpoint.address.socket_address.address":           "consul-server.default.svc",
+				"static_resources.clusters.0.transport_socket.typed_config.common_tls_context.validation_context.trusted_ca.inline_string": "ca-pem",
+			},
+		},
+		"TLS without CA": {
+			config: envoyBootstrapConfig{
+				Proxy:    proxy(nil),
+				GRPCAddr: "https://10.0.0.1:8502",
+			},
+			expErr: `a CA must be set when the gRPC address "https://10.0.0.1:8502" uses TLS`,
+		},
+		"invalid gRPC address": {
+			config: envoyBootstrapConfig{
+				Proxy:    proxy(nil),
+				GRPCAddr: "10.0.0.1",
+			},
+			expErr: `invalid gRPC address "10.0.0.1": address 10.0.0.1: missing port in address`,
+		},
+		"prometheus listener routed to the merged metrics server": {
+			config: envoyBootstrapConfig{
+				Proxy:                 proxy(map[string]interface{}{envoyPrometheusBindAddr: "0.0.0.0:20200"}),
+				AdminBindAddress:      "127.0.0.1",
+				AdminBindPort:         19000,
+				GRPCAddr:              "10.0.0.1:8502",
+				PrometheusScrapePath:  "/scrape",
+				PrometheusBackendPort: "20100",
+				PrometheusCAFile:      "/certs/ca.crt",
+				PrometheusCertFile:    "/certs/tls.crt",
+				PrometheusKeyFile:     "/certs/tls.key",
+			},
+			exp: map[string]interface{}{
+				"static_resources.clusters.1.name": "prometheus_backend",
+				"static_resources.clusters.1.load_assignment.endpoints.0.lb_endpoints.0.endpoint.address.socket_address.port_value":                     20100.0,
+				"static_resources.listeners.0.address.socket_address.port_value":                                                                        20200.0,
+				"static_resources.listeners.0.filter_chains.0.filters.0.typed_config.route_config.virtual_hosts.0.routes.0.match.path":                  "/scrape",
+				"static_resources.listeners.0.filter_chains.0.filters.0.typed_config.route_config.virtual_hosts.0.routes.0.route.cluster":               "prometheus_backend",
+				"static_resources.listeners.0.filter_chains.0.filters.0.typed_config.route_config.virtual_hosts.0.routes.0.route.prefix_rewrite":        "/stats/prometheus",
+				"static_resources.listeners.0.filter_chains.0.transport_socket.typed_config.common_tls_context.validation_context.trusted_ca.filename":  "/certs/ca.crt",
+				"static_resources.listeners.0.filter_chains.0.transport_socket.typed_config.common_tls_context.tls_certificates.0.private_key.filename": "/certs/tls.key",
+			},
+		},
+		"prometheus listener routed to the admin API": {
+			config: envoyBootstrapConfig{
+				Proxy:            proxy(map[string]interface{}{envoyPrometheusBindAddr: "0.0.0.0:20200"}),
+				AdminBindAddress: "127.0.0.1",
+				AdminBindPort:    19000,
+				GRPCAddr:         "10.0.0.1:8502",
+			},
+			exp: map[string]interface{}{
+				"static_resources.clusters.1.name": "self_admin",
+				"static_resources.clusters.1.load_assignment.endpoints.0.lb_endpoints.0.endpoint.address.socket_address.port_value":       19000.0,
+				"static_resources.listeners.0.filter_chains.0.filters.0.typed_config.route_config.virtual_hosts.0.routes.0.match.path":    "/metrics",
+				"static_resources.listeners.0.filter_chains.0.filters.0.typed_config.route_config.virtual_hosts.0.routes.0.route.cluster": "self_admin",
+				"static_resources.listeners.0.filter_chains.0.transport_socket":                                                           nil,
+			},
+		},
+		"proxy config": {
+			config: envoyBootstrapConfig{
+				Proxy: proxy(map[string]interface{}{
+					envoyStatsTags:                []interface{}{"team=payments", "invalid", "local_cluster=override"},
+					envoyStatsFlushInterval:       "10s",
+					envoyStatsdURL:                "udp://127.0.0.1:8125",
+					envoyDogstatsdURL:             "udp://127.0.0.1:8126",
+					envoyExtraStatsSinksJSON:      `{"name": "extra_sink"}`,
+					envoyTracingJSON:              `{"http": {"name": "envoy.tracers.zipkin"}}`,
+					envoyExtraStaticClustersJSON:  `{"name": "zipkin"}, {"name": "jaeger"}`,
+					envoyExtraStaticListenersJSON: `{"name": "extra_listener"}`,
+				}),
+				AdminBindAddress: "127.0.0.1",
+				AdminBindPort:    19000,
+				GRPCAddr:         "10.0.0.1:8502",
+			},
+			exp: map[string]interface{}{
+				"stats_config.stats_tags.0.tag_name":                           "team",
+				"stats_config.stats_tags.0.fixed_value":                        "payments",
+				"stats_config.stats_tags.1.tag_name":                           "local_cluster",
+				"stats_config.stats_tags.1.fixed_value":                        "override",
+				"stats_config.stats_tags.2.tag_name":                           "consul.destination.custom_hash",
+				"stats_config.stats_tags.16.tag_name":                          "consul.source.service",
+				"stats_flush_interval":                                         "10s",
+				"stats_sinks.0.name":                                           "envoy.stat_sinks.statsd",
+				"stats_sinks.0.typed_config.address.socket_address.port_value": 8125.0,
+				"stats_sinks.1.name":                                           "envoy.stat_sinks.dog_statsd",
+				"stats_sinks.2.name":                                           "extra_sink",
+				"tracing.http.name":                                            "envoy.tracers.zipkin",
+				"static_resources.clusters.1.name":                             "zipkin",
+				"static_resources.clusters.2.name":                             "jaeger",
+				"static_resources.listeners.0.name":                            "extra_listener",
+			},
+		},
+		"stats sinks replace the statsd URL": {
+			config: envoyBootstrapConfig{
+				Proxy: proxy(map[string]interface{}{
+					envoyStatsdURL:      "udp://127.0.0.1:8125",
+					envoyStatsSinksJSON: `{"name": "custom_sink"}`,
+				}),
+				GRPCAddr: "10.0.0.1:8502",
+			},
+			exp: map[string]interface{}{
+				"stats_sinks.0.name": "custom_sink",
+				"stats_sinks.1":      nil,
+			},
+		},
+		"stats config replaces the stats tags": {
+			config: envoyBootstrapConfig{
+				Proxy: proxy(map[string]interface{}{
+					envoyStatsTags:       "team=payments",
+					envoyStatsConfigJSON: `{"use_all_default_tags": false}`,
+				}),
+				GRPCAddr: "10.0.0.1:8502",
+			},
+			exp: map[string]interface{}{
+				"stats_config.use_all_default_tags": false,
+				"stats_config.stats_tags":           nil,
+			},
+		},
+		"invalid xDS escape hatch": {
+			config: envoyBootstrapConfig{
+				Proxy:    proxy(map[string]interface{}{"envoy_local_cluster_json": `{"name":`}),
+				GRPCAddr: "10.0.0.1:8502",
+			},
+			expErr: "envoy_local_cluster_json: invalid JSON",
+		},
+		"bootstrap template": {
+			config: envoyBootstrapConfig{
+				Proxy: proxy(map[string]interface{}{
+					envoyExtraStaticClustersJSON: `{"name": "zipkin"}`,
+					envoyBootstrapJSONTpl: `{
+  "node": {"id": "{{ .ProxyID }}", "cluster": "{{ .ProxyCluster }}"},
+  "static_resources": {
+    "clusters": [
+      {"name": "{{ .LocalAgentClusterName }}", "address": "{{ .GRPC.AgentAddress }}:{{ .GRPC.AgentPort }}", "ca": "{{ .AgentCAPEM }}"}{{ if .StaticClustersJSON }},{{ .StaticClustersJSON }}{{ end }}
+    ]
+  },
+  "stats_config": {{ .StatsConfigJSON }}
+}`,
+				}),
+				GRPCAddr:  "https://10.0.0.1:8502",
+				GRPCCAPEM: "line1\nline2",
+			},
+			exp: map[string]interface{}{
+				"node.id":                             "counting-counting-sidecar-proxy",
+				"node.cluster":                        "counting",
+				"static_resources.clusters.0.address": "10.0.0.1:8502",
+				"static_resources.clusters.0.ca":      "line1\nline2",
+				"static_resources.clusters.1.name":    "zipkin",
+				"stats_config.use_all_default_tags":   true,
+			},
+		},
+		"bootstrap template that isn't valid JSON": {
+			config: envoyBootstrapConfig{
+				Proxy:    proxy(map[string]interface{}{envoyBootstrapJSONTpl: `{"node": {{ .ProxyID }}}`}),
+				GRPCAddr: "10.0.0.1:8502",
+			},
+			expErr: "envoy_bootstrap_json_tpl: rendered bootstrap is not valid JSON",
+		},
+		"invalid tracing JSON": {
+			config: envoyBootstrapConfig{
+				Proxy:    proxy(map[string]interface{}{envoyTracingJSON: `{"http":`}),
+				GRPCAddr: "10.0.0.1:8502",
+			},
+			expErr: "envoy_tracing_json: unexpected end of JSON input",
+		},
+		"invalid statsd URL": {
+			config: envoyBootstrapConfig{
+				Proxy:    proxy(map[string]interface{}{envoyStatsdURL: "tcp://127.0.0.1:8125"}),
+				GRPCAddr: "10.0.0.1:8502",
+			},
+			expErr: `envoy_statsd_url: scheme "tcp" must be udp`,
+		},
+		"not a proxy": {
+			config: envoyBootstrapConfig{
+				Proxy:    &api.AgentService{ID: "counting-counting"},
+				GRPCAddr: "10.0.0.1:8502",
+			},
+			expErr: "service is not a connect proxy",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			bootstrap, err := c.config.generate()
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var parsed interface{}
+			require.NoError(t, json.Unmarshal(bootstrap, &parsed))
+			for path, exp := range c.exp {
+				require.Equal(t, exp, lookupPath(parsed, path), path)
+			}
+		})
+	}
+}
+
+// The regexes of the stats tags must extract the parts of the names of the
+// clusters and upstream listeners Consul generates. Envoy removes the first
+// capture group from the name and uses the second one as the tag value.
+func TestDefaultStatsTagRegexes(t *testing.T) {
+	cases := map[string]map[string]string{
+		"cluster.v2.pong.ns1.part1.dc2.internal.e5b08d03-bfc3-c870-1833-baddb116e648.consul.upstream_cx_total": {
+			"consul.destination.service_subset": "v2",
+			"consul.destination.service":        "pong",
+			"consul.destination.namespace":      "ns1",
+			"consul.destination.partition":      "part1",
+			"consul.destination.datacenter":     "dc2",
+			"consul.destination.routing_type":   "internal",
+			"consul.destination.trust_domain":   "e5b08d03-bfc3-c870-1833-baddb116e648",
+			"consul.destination.target":         "v2.pong.ns1.part1.dc2",
+			"consul.destination.full_target":    "v2.pong.ns1.part1.dc2.internal.e5b08d03-bfc3-c870-1833-baddb116e648",
+		},
+		"cluster.f8f8f8f8~pong.default.dc2.internal.e5b08d03-bfc3-c870-1833-baddb116e648.consul.upstream_cx_total": {
+			"consul.destination.custom_hash":  "f8f8f8f8",
+			"consul.destination.service":      "pong",
+			"consul.destination.namespace":    "default",
+			"consul.destination.partition":    "",
+			"consul.destination.datacenter":   "dc2",
+			"consul.destination.routing_type": "internal",
+			"consul.destination.target":       "f8f8f8f8~pong.default.dc2",
+		},
+		"tcp.upstream.db.ns1.part1.dc1.downstream_cx_total": {
+			"consul.upstream.service":    "db",
+			"consul.upstream.namespace":  "ns1",
+			"consul.upstream.partition":  "part1",
+			"consul.upstream.datacenter": "dc1",
+		},
+	}
+
+	regexes := make(map[string]*regexp.Regexp)
+	for _, tag := range defaultStatsTagRegexes {
+		regexes[tag.name] = regexp.MustCompile(tag.regex)
+	}
+	for statName, expTags := range cases {
+		for tagName, exp := range expTags {
+			re, ok := regexes[tagName]
+			require.True(t, ok, tagName)
+			match := re.FindStringSubmatch(statName)
+			require.Len(t, match, 3, "%s: %s", tagName, statName)
+			require.Equal(t, exp, match[2], "%s: %s", tagName, statName)
+		}
+	}
+}
+
+// Envoy's limit of the size of regex programs is raised for the stats tags.
+func TestEnvoyBootstrap_layeredRuntime(t *testing.T) {
+	bootstrap, err := envoyBootstrapConfig{
+		Proxy: &api.AgentService{
+			ID:    "counting-counting-sidecar-proxy",
+			Proxy: &api.AgentServiceConnectProxyConfig{DestinationServiceName: "counting"},
+		},
+		GRPCAddr: "10.0.0.1:8502",
+	}.generate()
+	require.NoError(t, err)
+
+	var parsed struct {
+		LayeredRuntime struct {
+			Layers []struct {
+				StaticLayer map[string]interface{} `json:"static_layer"`
+			} `json:"layers"`
+		} `json:"layered_runtime"`
+	}
+	require.NoError(t, json.Unmarshal(bootstrap, &parsed))
+	require.Len(t, parsed.LayeredRuntime.Layers, 1)
+	require.Equal(t, float64(re2MaxProgramSize), parsed.LayeredRuntime.Layers[0].StaticLayer["re2.max_program_size.error_level"])
+}
+
+// lookupPath returns the value at the dot-separated path of the parsed JSON
+// or nil if it doesn't exist. List elements are addressed by index.
+func lookupPath(v interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
@@ -0,0 +1,65 @@
+package connectinit
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/iptables"
+)
+
+// bindPortConfig is the key of the proxy config that overrides the port of
+// the public listener of the proxy.
+const bindPortConfig = "bind_port"
+
+// iptablesConfig returns the traffic redirection rules of the proxy, the
+// same ones `consul connect redirect-traffic` applies. Traffic to the
+// listeners that must be reached without going through the proxy, e.g. the
+// Prometheus listener or exposed paths, is excluded.
+func (c *Command) iptablesConfig(proxy *api.AgentService) (iptables.Config, error) {
+	if proxy.Proxy == nil {
+		return iptables.Config{}, fmt.Errorf("service %s is not a proxy service", proxy.ID)
+	}
+
+	cfg := iptables.Config{
+		ConsulDNSIP:          c.flagConsulDNSIP,
+		ProxyUserID:          strconv.Itoa(c.flagProxyUID),
+		ProxyInboundPort:     proxy.Port,
+		ProxyOutboundPort:    iptables.DefaultTProxyOutboundPort,
+		ExcludeInboundPorts:  append([]string(nil), c.flagExcludeInboundPorts...),
+		ExcludeOutboundPorts: c.flagExcludeOutboundPorts,
+		ExcludeOutboundCIDRs: c.flagExcludeOutboundCIDRs,
+		ExcludeUIDs:          c.flagExcludeUIDs,
+		IptablesProvider:     c.iptablesProvider,
+	}
+
+	if bindPort, ok := proxy.Proxy.Config[bindPortConfig]; ok {
+		port, err := strconv.Atoi(fmt.Sprint(bindPort))
+		if err != nil {
+			return iptables.Config{}, fmt.Errorf("failed parsing %s from proxy config: %s", bindPortConfig, err)
+		}
+		cfg.ProxyInboundPort = port
+	}
+	if proxy.Proxy.TransparentProxy != nil && proxy.Proxy.TransparentProxy.OutboundListenerPort != 0 {
+		cfg.ProxyOutboundPort = proxy.Proxy.TransparentProxy.OutboundListenerPort
+	}
+
+	for _, key := range []string{envoyPrometheusBindAddr, envoyStatsBindAddr} {
+		addr := configString(proxy.Proxy.Config, key)
+		if addr == "" {
+			continue
+		}
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return iptables.Config{}, fmt.Errorf("failed parsing %s from proxy config: %s", key, err)
+		}
+		cfg.ExcludeInboundPorts = append(cfg.ExcludeInboundPorts, port)
+	}
+	for _, path := range proxy.Proxy.Expose.Paths {
+		if path.ListenerPort != 0 {
+			cfg.ExcludeInboundPorts = append(cfg.ExcludeInboundPorts, strconv.Itoa(path.ListenerPort))
+		}
+	}
+	return cfg, nil
+}
@@ -1,10 +1,12 @@
 package consulsidecar
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
@@ -15,10 +17,13 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/hashicorp/consul-k8s/control-plane/helper/metrics"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
 	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 )
 
 const (
@@ -46,9 +51,17 @@ type Command struct {
 	flagMergedMetricsPort    string
 	flagServiceMetricsPort   string
 	flagServiceMetricsPath   string
+	flagMergedMetricsConfig  string
+
+	// mergingConfig is the parsed -merged-metrics-config. If it's set, the
+	// targets it configures are merged instead of the service metrics.
+	mergingConfig *metrics.MergingConfig
 
 	envoyMetricsGetter   metricsGetter
 	serviceMetricsGetter metricsGetter
+	// targetMetricsGetters are the getters of the targets of mergingConfig
+	// by target name.
+	targetMetricsGetters map[string]metricsGetter
 
 	consulCommand []string
 
@@ -86,6 +99,10 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagMergedMetricsPort, "merged-metrics-port", "20100", "Port to serve merged Envoy and application metrics. Defaults to 20100.")
 	c.flagSet.StringVar(&c.flagServiceMetricsPort, "service-metrics-port", "0", "Port where application metrics are being served. Defaults to 0.")
 	c.flagSet.StringVar(&c.flagServiceMetricsPath, "service-metrics-path", "/metrics", "Path where application metrics are being served. Defaults to /metrics.")
+	c.flagSet.StringVar(&c.flagMergedMetricsConfig, "merged-metrics-config", "",
+		"JSON configuration of the application metrics endpoints to merge, how to relabel the metrics of each "+
+			"endpoint and of Envoy, and the format of the merged metrics. If set, -service-metrics-port and "+
+			"-service-metrics-path are ignored.")
 	c.help = flags.Usage(help, c.flagSet)
 	c.http = &flags.HTTPFlags{}
 	flags.Merge(c.flagSet, c.http.Flags())
@@ -134,6 +151,7 @@ func (c *Command) Run(args []string) int {
 		"merged-metrics-port", c.flagMergedMetricsPort,
 		"service-metrics-port", c.flagServiceMetricsPort,
 		"service-metrics-path", c.flagServiceMetricsPath,
+		"merged-metrics-config", c.flagMergedMetricsConfig,
 	)
 
 	// signalCtx that we pass in to the main work loop, signal handling is handled in another thread
@@ -154,7 +172,11 @@ func (c *Command) Run(args []string) int {
 	srvExitCh := make(chan error)
 	if c.flagEnableMetricsMerging {
 		c.logger.Info("Metrics is enabled, creating merged metrics server.")
-		server = c.createMergedMetricsServer()
+		server, err = c.createMergedMetricsServer()
+		if err != nil {
+			c.logger.Error("Unable to create merged metrics server", "err", err)
+			return 1
+		}
 
 		// Run the merged metrics server.
 		c.logger.Info("Running merged metrics server.")
@@ -238,9 +260,13 @@ func (c *Command) shutdownMetricsServer(server *http.Server) {
 }
 
 // createMergedMetricsServer sets up the merged metrics server.
-func (c *Command) createMergedMetricsServer() *http.Server {
+func (c *Command) createMergedMetricsServer() (*http.Server, error) {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/stats/prometheus", c.mergedMetricsHandler)
+	if c.mergingConfig != nil {
+		mux.HandleFunc("/stats/prometheus", c.mergedTargetsMetricsHandler)
+	} else {
+		mux.HandleFunc("/stats/prometheus", c.mergedMetricsHandler)
+	}
 
 	mergedMetricsServerAddr := fmt.Sprintf("127.0.0.1:%s", c.flagMergedMetricsPort)
 	server := &http.Server{Addr: mergedMetricsServerAddr, Handler: mux}
@@ -259,8 +285,18 @@ func (c *Command) createMergedMetricsServer() *http.Server {
 	if c.serviceMetricsGetter == nil {
 		c.serviceMetricsGetter = client
 	}
+	if c.mergingConfig != nil && c.targetMetricsGetters == nil {
+		c.targetMetricsGetters = make(map[string]metricsGetter)
+		for _, target := range c.mergingConfig.Targets {
+			targetClient, err := target.HTTPClient(client.Timeout)
+			if err != nil {
+				return nil, err
+			}
+			c.targetMetricsGetters[target.Name] = targetClient
+		}
+	}
 
-	return server
+	return server, nil
 }
 
 // mergedMetricsHandler has the logic to append both Envoy and service metrics
@@ -331,6 +367,96 @@ func (c *Command) mergedMetricsHandler(rw http.ResponseWriter, _ *http.Request)
 	writeResponse(rw, serviceMetricSuccess(true), "service metrics success", c.logger)
 }
 
+// mergedTargetsMetricsHandler merges the Envoy metrics with the metrics of
+// all targets of the merging configuration, relabeling the metrics of each
+// source as configured. Like mergedMetricsHandler, it responds with a 500 if
+// the Envoy scrape fails and with a 200 if a target scrape fails, and reports
+// the success of each target scrape in a metric labeled with its name.
+func (c *Command) mergedTargetsMetricsHandler(rw http.ResponseWriter, _ *http.Request) {
+	openMetrics := c.mergingConfig.OpenMetrics()
+
+	envoyMetricsBody, err := scrape(c.envoyMetricsGetter, envoyMetricsAddr)
+	if err != nil {
+		c.logger.Error("Error scraping Envoy proxy metrics", "err", err)
+		http.Error(rw, fmt.Sprintf("Error scraping Envoy proxy metrics: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	// The response is buffered so that a 500 can still be returned if the
+	// Envoy metrics can't be relabeled.
+	var merged bytes.Buffer
+	if err := writeMetrics(&merged, envoyMetricsBody, c.mergingConfig.Envoy, openMetrics); err != nil {
+		c.logger.Error("Could not parse Envoy proxy metrics", "err", err)
+		http.Error(rw, fmt.Sprintf("Could not parse Envoy proxy metrics: %s", err), http.StatusInternalServerError)
+		return
+	}
+
+	success := make(map[string]float64)
+	for _, target := range c.mergingConfig.Targets {
+		success[target.Name] = 0
+		body, err := scrape(c.targetMetricsGetters[target.Name], target.URL())
+		if err != nil {
+			c.logger.Warn("Error scraping service metrics", "target", target.Name, "err", err)
+			continue
+		}
+		var targetMetrics bytes.Buffer
+		if err := writeMetrics(&targetMetrics, body, target.Relabeling, openMetrics); err != nil {
+			c.logger.Warn("Could not parse service metrics", "target", target.Name, "err", err)
+			continue
+		}
+		merged.Write(targetMetrics.Bytes())
+		success[target.Name] = 1
+	}
+
+	if len(success) > 0 {
+		successMetric := metrics.Gauge(prometheusServiceMetricsSuccessKey, "target", success)
+		if err := metrics.Encode(&merged, []*dto.MetricFamily{successMetric}, openMetrics); err != nil {
+			c.logger.Error("Could not encode service metrics success", "err", err)
+		}
+	}
+
+	if openMetrics {
+		_, _ = expfmt.FinalizeOpenMetrics(&merged)
+		rw.Header().Set("Content-Type", string(expfmt.FmtOpenMetrics))
+	} else {
+		rw.Header().Set("Content-Type", string(expfmt.FmtText))
+	}
+	writeResponse(rw, merged.Bytes(), "merged metrics", c.logger)
+}
+
+// scrape gets the metrics at url and returns the body of the response.
+func scrape(getter metricsGetter, url string) ([]byte, error) {
+	resp, err := getter.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response: %s", err)
+	}
+	if non2xxCode(resp.StatusCode) {
+		return nil, fmt.Errorf("received non-2xx status code: %d: %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// writeMetrics writes the metrics in body to w. They are written verbatim
+// unless they need to be relabeled or converted to OpenMetrics.
+func writeMetrics(w io.Writer, body []byte, relabeling metrics.Relabeling, openMetrics bool) error {
+	if relabeling.IsZero() && !openMetrics {
+		_, err := w.Write(body)
+		return err
+	}
+	families, err := metrics.Decode(body)
+	if err != nil {
+		return err
+	}
+	relabeling.Apply(families)
+	return metrics.Encode(w, families, openMetrics)
+}
+
 // writeResponse is a helper method to write resp to rw and log if there is an error writing.
 // respName is the name of this response that will be used in the error log.
 func writeResponse(rw http.ResponseWriter, resp []byte, respName string, logger hclog.Logger) {
@@ -345,6 +471,13 @@ func (c *Command) validateFlags() error {
 	if !c.flagEnableServiceRegistration && !c.flagEnableMetricsMerging {
 		return errors.New("at least one of -enable-service-registration or -enable-metrics-merging must be true")
 	}
+	if c.flagEnableMetricsMerging && c.flagMergedMetricsConfig != "" {
+		config, err := metrics.ParseMergingConfig(c.flagMergedMetricsConfig)
+		if err != nil {
+			return fmt.Errorf("-merged-metrics-config is invalid: %s", err)
+		}
+		c.mergingConfig = &config
+	}
 	if c.flagEnableServiceRegistration {
 		if c.flagSyncPeriod == 0 {
 			// if sync period is 0, then the select loop will
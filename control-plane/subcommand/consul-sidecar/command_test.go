@@ -12,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/hashicorp/consul-k8s/control-plane/helper/metrics"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/freeport"
 	"github.com/hashicorp/consul/sdk/testutil"
@@ -317,7 +318,8 @@ func TestMergedMetricsServer(t *testing.T) {
 				serviceMetricsGetter:     c.serviceMetricsGetter,
 			}
 
-			server := cmd.createMergedMetricsServer()
+			server, err := cmd.createMergedMetricsServer()
+			require.NoError(t, err)
 			go func() {
 				_ = server.ListenAndServe()
 			}()
@@ -343,6 +345,128 @@ func TestMergedMetricsServer(t *testing.T) {
 	}
 }
 
+// mockMetricsGetter returns the same response for every URL.
+type mockMetricsGetter struct {
+	// reqURL is the last URL that was passed to Get(url)
+	reqURL string
+
+	respStatusCode int
+	respBody       string
+}
+
+func (m *mockMetricsGetter) Get(url string) (resp *http.Response, err error) {
+	m.reqURL = url
+	return &http.Response{
+		StatusCode: m.respStatusCode,
+		Body:       ioutil.NopCloser(bytes.NewReader([]byte(m.respBody))),
+	}, nil
+}
+
+func TestMergedMetricsServer_Targets(t *testing.T) {
+	envoyMetrics := "# TYPE envoy_cluster_upstream_rq counter\nenvoy_cluster_upstream_rq{envoy_cluster_name=\"web\"} 7\n"
+	appMetrics := "# TYPE requests_total counter\nrequests_total 3\n"
+	jvmMetrics := "# TYPE heap_bytes gauge\nheap_bytes 512\n"
+
+	cases := []struct {
+		name               string
+		config             string
+		jvmStatusCode      int
+		expectedStatusCode int
+		expectedOutput     string
+	}{
+		{
+			name:               "metrics are merged verbatim without relabeling",
+			config:             `{"targets": [{"name": "app", "port": "8080"}, {"name": "jvm", "port": "9404", "path": "/jvm"}]}`,
+			jvmStatusCode:      200,
+			expectedStatusCode: 200,
+			expectedOutput: envoyMetrics + appMetrics + jvmMetrics +
+				"# TYPE consul_merged_service_metrics_success gauge\n" +
+				"consul_merged_service_metrics_success{target=\"app\"} 1\n" +
+				"consul_merged_service_metrics_success{target=\"jvm\"} 1\n",
+		},
+		{
+			name: "metrics are relabeled",
+			config: `{"envoy": {"labels": {"source": "envoy"}}, "targets": [
+  {"name": "app", "port": "8080", "labels": {"source": "app"}},
+  {"name": "jvm", "port": "9404", "path": "/jvm", "prefix": "jvm_", "labels": {"source": "jvm"}}]}`,
+			jvmStatusCode:      200,
+			expectedStatusCode: 200,
+			expectedOutput: "# TYPE envoy_cluster_upstream_rq counter\n" +
+				"envoy_cluster_upstream_rq{envoy_cluster_name=\"web\",source=\"envoy\"} 7\n" +
+				"# TYPE requests_total counter\n" +
+				"requests_total{source=\"app\"} 3\n" +
+				"# TYPE jvm_heap_bytes gauge\n" +
+				"jvm_heap_bytes{source=\"jvm\"} 512\n" +
+				"# TYPE consul_merged_service_metrics_success gauge\n" +
+				"consul_merged_service_metrics_success{target=\"app\"} 1\n" +
+				"consul_merged_service_metrics_success{target=\"jvm\"} 1\n",
+		},
+		{
+			name:               "failed target is reported and skipped",
+			config:             `{"targets": [{"name": "app", "port": "8080"}, {"name": "jvm", "port": "9404", "path": "/jvm"}]}`,
+			jvmStatusCode:      503,
+			expectedStatusCode: 200,
+			expectedOutput: envoyMetrics + appMetrics +
+				"# TYPE consul_merged_service_metrics_success gauge\n" +
+				"consul_merged_service_metrics_success{target=\"app\"} 1\n" +
+				"consul_merged_service_metrics_success{target=\"jvm\"} 0\n",
+		},
+		{
+			name:               "openmetrics",
+			config:             `{"format": "openmetrics", "targets": [{"name": "jvm", "port": "9404", "path": "/jvm"}]}`,
+			jvmStatusCode:      200,
+			expectedStatusCode: 200,
+			expectedOutput: "# TYPE envoy_cluster_upstream_rq unknown\n" +
+				"envoy_cluster_upstream_rq{envoy_cluster_name=\"web\"} 7.0\n" +
+				"# TYPE heap_bytes gauge\n" +
+				"heap_bytes 512.0\n" +
+				"# TYPE consul_merged_service_metrics_success gauge\n" +
+				"consul_merged_service_metrics_success{target=\"jvm\"} 1.0\n" +
+				"# EOF\n",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			mergingConfig, err := metrics.ParseMergingConfig(c.config)
+			require.NoError(t, err)
+
+			appGetter := &mockMetricsGetter{respStatusCode: 200, respBody: appMetrics}
+			jvmGetter := &mockMetricsGetter{respStatusCode: c.jvmStatusCode, respBody: jvmMetrics}
+			randomPorts := freeport.GetN(t, 1)
+			cmd := Command{
+				UI:                       cli.NewMockUi(),
+				flagEnableMetricsMerging: true,
+				flagMergedMetricsPort:    fmt.Sprint(randomPorts[0]),
+				mergingConfig:            &mergingConfig,
+				logger:                   hclog.Default(),
+				envoyMetricsGetter:       &mockMetricsGetter{respStatusCode: 200, respBody: envoyMetrics},
+				targetMetricsGetters: map[string]metricsGetter{
+					"app": appGetter,
+					"jvm": jvmGetter,
+				},
+			}
+
+			server, err := cmd.createMergedMetricsServer()
+			require.NoError(t, err)
+			go func() {
+				_ = server.ListenAndServe()
+			}()
+			defer server.Close()
+
+			retry.Run(t, func(r *retry.R) {
+				resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/stats/prometheus", randomPorts[0]))
+				require.NoError(r, err)
+				bytes, err := ioutil.ReadAll(resp.Body)
+				require.NoError(r, err)
+				require.Equal(r, c.expectedStatusCode, resp.StatusCode)
+				require.Equal(r, c.expectedOutput, string(bytes))
+				require.Equal(r, "http://127.0.0.1:9404/jvm", jvmGetter.reqURL)
+			})
+		})
+	}
+}
+
 func TestRun_FlagValidation(t *testing.T) {
 	t.Parallel()
 	cases := []struct {
@@ -384,6 +508,14 @@ func TestRun_FlagValidation(t *testing.T) {
 			},
 			ExpErr: "-consul-api-timeout must be set to a value greater than 0",
 		},
+		{
+			Flags: []string{
+				"-enable-service-registration=false",
+				"-enable-metrics-merging=true",
+				`-merged-metrics-config={"format": "json"}`,
+			},
+			ExpErr: `-merged-metrics-config is invalid: format "json" must be one of "prometheus" or "openmetrics"`,
+		},
 	}
 
 	for _, c := range cases {
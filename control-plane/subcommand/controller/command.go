@@ -18,6 +18,7 @@ import (
 	"github.com/hashicorp/consul/api"
 	"github.com/mitchellh/cli"
 	"go.uber.org/zap/zapcore"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
@@ -25,6 +26,7 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 )
 
@@ -36,14 +38,16 @@ type Command struct {
 	flagSet   *flag.FlagSet
 	httpFlags *flags.HTTPFlags
 
-	flagWebhookTLSCertDir     string
-	flagEnableLeaderElection  bool
-	flagEnableWebhooks        bool
-	flagDatacenter            string
-	flagLogLevel              string
-	flagLogJSON               bool
-	flagResourcePrefix        string
-	flagEnableWebhookCAUpdate bool
+	flagWebhookTLSCertDir              string
+	flagEnableLeaderElection           bool
+	flagEnableWebhooks                 bool
+	flagDatacenter                     string
+	flagLogLevel                       string
+	flagLogJSON                        bool
+	flagResourcePrefix                 string
+	flagEnableWebhookCAUpdate          bool
+	flagEnableDriftDetection           bool
+	flagEnableInlineCertificateSecrets bool
 
 	// Flags to support Consul Enterprise namespaces.
 	flagEnableNamespaces           bool
@@ -94,6 +98,10 @@ func (c *Command) init() {
 		"Release prefix of the Consul installation used to prepend on the webhook name that will have its CA bundle updated.")
 	c.flagSet.BoolVar(&c.flagEnableWebhookCAUpdate, "enable-webhook-ca-update", false,
 		"Enables updating the CABundle on the webhook within this controller rather than using the webhook-cert-manager.")
+	c.flagSet.BoolVar(&c.flagEnableDriftDetection, "enable-drift-detection", false,
+		"Watch config entries in Consul and reconcile custom resources whose config entries have been changed or deleted outside of Kubernetes.")
+	c.flagSet.BoolVar(&c.flagEnableInlineCertificateSecrets, "enable-inline-certificate-secrets", false,
+		"Allow InlineCertificate resources to reference a Secret of type kubernetes.io/tls. The controller reads and watches Secrets only if enabled.")
 	c.flagSet.StringVar(&c.flagLogLevel, "log-level", zapcore.InfoLevel.String(),
 		fmt.Sprintf("Log verbosity level. Supported values (in order of detail) are "+
 			"%q, %q, %q, and %q.", zapcore.DebugLevel.String(), zapcore.InfoLevel.String(), zapcore.WarnLevel.String(), zapcore.ErrorLevel.String()))
@@ -125,13 +133,23 @@ func (c *Command) Run(args []string) int {
 	ctrl.SetLogger(zapLogger)
 	klog.SetLogger(zapLogger)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	mgrOptions := ctrl.Options{
 		Scheme:           scheme,
 		Port:             9443,
 		LeaderElection:   c.flagEnableLeaderElection,
 		LeaderElectionID: "consul.hashicorp.com",
 		Logger:           zapLogger,
-	})
+	}
+	if c.flagEnableInlineCertificateSecrets {
+		// The inline certificate controller watches Secrets. Only cache the
+		// Secrets it can reference instead of every Secret in the cluster.
+		mgrOptions.NewCache = cache.BuilderWithOptions(cache.Options{
+			SelectorsByObject: cache.SelectorsByObject{
+				&corev1.Secret{}: {Field: controller.SecretCacheSelector()},
+			},
+		})
+	}
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), mgrOptions)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		return 1
@@ -163,6 +181,7 @@ func (c *Command) Run(args []string) int {
 		EnableNSMirroring:          c.flagEnableNSMirroring,
 		NSMirroringPrefix:          c.flagNSMirroringPrefix,
 		CrossNSACLPolicy:           c.flagCrossNSACLPolicy,
+		EnableDriftDetection:       c.flagEnableDriftDetection,
 	}
 	if err = (&controller.ServiceDefaultsController{
 		ConfigEntryController: configEntryReconciler,
@@ -254,6 +273,44 @@ func (c *Command) Run(args []string) int {
 		setupLog.Error(err, "unable to create controller", "controller", common.TerminatingGateway)
 		return 1
 	}
+	if err = (&controller.APIGatewayController{
+		ConfigEntryController: configEntryReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controller").WithName(common.APIGateway),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", common.APIGateway)
+		return 1
+	}
+	if err = (&controller.HTTPRouteController{
+		ConfigEntryController: configEntryReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controller").WithName(common.HTTPRoute),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", common.HTTPRoute)
+		return 1
+	}
+	if err = (&controller.TCPRouteController{
+		ConfigEntryController: configEntryReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controller").WithName(common.TCPRoute),
+		Scheme:                mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", common.TCPRoute)
+		return 1
+	}
+	if err = (&controller.InlineCertificateController{
+		ConfigEntryController: configEntryReconciler,
+		Client:                mgr.GetClient(),
+		Log:                   ctrl.Log.WithName("controller").WithName(common.InlineCertificate),
+		Scheme:                mgr.GetScheme(),
+		APIReader:             mgr.GetAPIReader(),
+		EnableSecretRefs:      c.flagEnableInlineCertificateSecrets,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", common.InlineCertificate)
+		return 1
+	}
 
 	if c.flagEnableWebhooks {
 		// This webhook server sets up a Cert Watcher on the CertDir. This watches for file changes and updates the webhook certificates
@@ -331,6 +388,34 @@ func (c *Command) Run(args []string) int {
 				Logger:       ctrl.Log.WithName("webhooks").WithName(common.TerminatingGateway),
 				ConsulMeta:   consulMeta,
 			}})
+		mgr.GetWebhookServer().Register("/mutate-v1alpha1-apigateway",
+			&webhook.Admission{Handler: &v1alpha1.APIGatewayWebhook{
+				Client:       mgr.GetClient(),
+				ConsulClient: consulClient,
+				Logger:       ctrl.Log.WithName("webhooks").WithName(common.APIGateway),
+				ConsulMeta:   consulMeta,
+			}})
+		mgr.GetWebhookServer().Register("/mutate-v1alpha1-httproute",
+			&webhook.Admission{Handler: &v1alpha1.HTTPRouteWebhook{
+				Client:       mgr.GetClient(),
+				ConsulClient: consulClient,
+				Logger:       ctrl.Log.WithName("webhooks").WithName(common.HTTPRoute),
+				ConsulMeta:   consulMeta,
+			}})
+		mgr.GetWebhookServer().Register("/mutate-v1alpha1-tcproute",
+			&webhook.Admission{Handler: &v1alpha1.TCPRouteWebhook{
+				Client:       mgr.GetClient(),
+				ConsulClient: consulClient,
+				Logger:       ctrl.Log.WithName("webhooks").WithName(common.TCPRoute),
+				ConsulMeta:   consulMeta,
+			}})
+		mgr.GetWebhookServer().Register("/mutate-v1alpha1-inlinecertificate",
+			&webhook.Admission{Handler: &v1alpha1.InlineCertificateWebhook{
+				Client:       mgr.GetClient(),
+				ConsulClient: consulClient,
+				Logger:       ctrl.Log.WithName("webhooks").WithName(common.InlineCertificate),
+				ConsulMeta:   consulMeta,
+			}})
 	}
 	// +kubebuilder:scaffold:builder
 
@@ -0,0 +1,233 @@
+package envoylifecycle
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/common"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	"github.com/hashicorp/go-hclog"
+	"github.com/mitchellh/cli"
+)
+
+const (
+	defaultPollInterval = 200 * time.Millisecond
+	// adminTimeout is the timeout of a single request to the Envoy admin API.
+	adminTimeout = 2 * time.Second
+)
+
+// The envoy-lifecycle command is run by the lifecycle hooks of the Envoy
+// sidecar. With -wait-ready it blocks until Envoy is ready so that the
+// application containers only start once the proxy can serve their traffic.
+// With -drain it drains the inbound listeners of Envoy and blocks until it has
+// no active connections left so that the application can finish in-flight
+// requests before Envoy is stopped.
+type Command struct {
+	UI cli.Ui
+
+	flagEnvoyAdminAddr string
+	flagInboundPort    int
+	flagWaitReady      bool
+	flagDrain          bool
+	flagTimeout        time.Duration
+	flagLogLevel       string
+	flagLogJSON        bool
+
+	flagSet *flag.FlagSet
+
+	// pollInterval is how often Envoy is polled. It is only changed in tests.
+	pollInterval time.Duration
+	httpClient   *http.Client
+
+	once   sync.Once
+	help   string
+	logger hclog.Logger
+}
+
+func (c *Command) init() {
+	c.flagSet = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flagSet.StringVar(&c.flagEnvoyAdminAddr, "envoy-admin-addr", "127.0.0.1:19000",
+		"Address of the Envoy admin API.")
+	c.flagSet.IntVar(&c.flagInboundPort, "inbound-port", 20000,
+		"Port of the public listener of Envoy. Only its connections are waited for when draining.")
+	c.flagSet.BoolVar(&c.flagWaitReady, "wait-ready", false,
+		"Block until Envoy is ready. Fails if Envoy isn't ready within -timeout.")
+	c.flagSet.BoolVar(&c.flagDrain, "drain", false,
+		"Gracefully drain the inbound listeners of Envoy and block until there are no active connections or "+
+			"-timeout has elapsed.")
+	c.flagSet.DurationVar(&c.flagTimeout, "timeout", 30*time.Second,
+		"How long to wait for Envoy to become ready or to drain its connections.")
+	c.flagSet.StringVar(&c.flagLogLevel, "log-level", "info",
+		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
+			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flagSet.BoolVar(&c.flagLogJSON, "log-json", false,
+		"Enable or disable JSON output format for logging.")
+
+	c.help = flags.Usage(help, c.flagSet)
+	if c.pollInterval == 0 {
+		c.pollInterval = defaultPollInterval
+	}
+	if c.httpClient == nil {
+		c.httpClient = &http.Client{Timeout: adminTimeout}
+	}
+}
+
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	if err := c.flagSet.Parse(args); err != nil {
+		return 1
+	}
+	if c.flagWaitReady == c.flagDrain {
+		c.UI.Error("exactly one of -wait-ready or -drain must be set")
+		return 1
+	}
+	if c.flagTimeout <= 0 {
+		c.UI.Error("-timeout must be greater than 0")
+		return 1
+	}
+	if c.flagInboundPort < 1 || c.flagInboundPort > 65535 {
+		c.UI.Error("-inbound-port must be a valid port")
+		return 1
+	}
+
+	if c.logger == nil {
+		var err error
+		c.logger, err = common.Logger(c.flagLogLevel, c.flagLogJSON)
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+	}
+
+	if c.flagWaitReady {
+		if err := c.waitReady(); err != nil {
+			c.logger.Error("Envoy did not become ready", "error", err)
+			return 1
+		}
+		c.logger.Info("Envoy is ready")
+		return 0
+	}
+
+	// Draining is best effort: Envoy is stopped once the hook returns, so
+	// failures are logged but don't fail the hook.
+	if err := c.drain(); err != nil {
+		c.logger.Warn("Envoy did not drain", "error", err)
+		return 0
+	}
+	c.logger.Info("Envoy drained its connections")
+	return 0
+}
+
+// waitReady polls the readiness endpoint of Envoy until it returns 200, which
+// it does once Envoy has received its initial configuration.
+func (c *Command) waitReady() error {
+	deadline := time.Now().Add(c.flagTimeout)
+	var lastErr error
+	for {
+		resp, err := c.httpClient.Get(c.adminURL("/ready"))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+			err = fmt.Errorf("unexpected response code %d", resp.StatusCode)
+		}
+		lastErr = err
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s: %s", c.flagTimeout, lastErr)
+		}
+		time.Sleep(c.pollInterval)
+	}
+}
+
+// drain starts a graceful drain of the inbound listeners and waits until the
+// public listener has no active connections. Outbound connections of the
+// application are left alone since they are closed by the application.
+func (c *Command) drain() error {
+	resp, err := c.httpClient.Post(c.adminURL("/drain_listeners?inboundonly&graceful"), "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("draining listeners: unexpected response code %d", resp.StatusCode)
+	}
+	c.logger.Info("Draining Envoy listeners", "timeout", c.flagTimeout)
+
+	deadline := time.Now().Add(c.flagTimeout)
+	for {
+		active, err := c.activeConnections()
+		if err == nil && active == 0 {
+			return nil
+		}
+		if err != nil {
+			c.logger.Debug("Failed reading active connections", "error", err)
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out after %s with %d active connections", c.flagTimeout, active)
+		}
+		time.Sleep(c.pollInterval)
+	}
+}
+
+// activeConnections returns the number of active downstream connections of
+// the public listener of Envoy.
+func (c *Command) activeConnections() (int, error) {
+	resp, err := c.httpClient.Get(c.adminURL("/stats?filter=downstream_cx_active"))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return 0, fmt.Errorf("unexpected response code %d: %s", resp.StatusCode, body)
+	}
+
+	active := 0
+	portSuffix := fmt.Sprintf("_%d", c.flagInboundPort)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		// Lines have the format "listener.0.0.0.0_20000.downstream_cx_active: 2"
+		// where the listener is named after its address and port.
+		name, value, ok := strings.Cut(scanner.Text(), ": ")
+		if !ok || !strings.HasPrefix(name, "listener.") || !strings.HasSuffix(name, ".downstream_cx_active") {
+			continue
+		}
+		address := strings.TrimSuffix(strings.TrimPrefix(name, "listener."), ".downstream_cx_active")
+		if !strings.HasSuffix(address, portSuffix) {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("parsing stat %q: %s", name, err)
+		}
+		active += n
+	}
+	return active, scanner.Err()
+}
+
+func (c *Command) adminURL(path string) string {
+	return fmt.Sprintf("http://%s%s", c.flagEnvoyAdminAddr, path)
+}
+
+func (c *Command) Synopsis() string { return synopsis }
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+const synopsis = "Run the lifecycle hooks of an Envoy sidecar."
+const help = `
+Usage: consul-k8s-control-plane envoy-lifecycle [options]
+
+  Waits for the Envoy sidecar to become ready with -wait-ready, or drains its
+  connections with -drain. Run by the postStart and preStop hooks of the
+  Envoy sidecar container. Not intended for stand-alone use.
+`
@@ -0,0 +1,155 @@
+package envoylifecycle
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		flags  []string
+		expErr string
+	}{
+		{
+			flags:  []string{},
+			expErr: "exactly one of -wait-ready or -drain must be set",
+		},
+		{
+			flags:  []string{"-wait-ready", "-drain"},
+			expErr: "exactly one of -wait-ready or -drain must be set",
+		},
+		{
+			flags:  []string{"-drain", "-timeout=0s"},
+			expErr: "-timeout must be greater than 0",
+		},
+		{
+			flags:  []string{"-drain", "-inbound-port=0"},
+			expErr: "-inbound-port must be a valid port",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{
+				UI: ui,
+			}
+			exitCode := cmd.Run(c.flags)
+			require.Equal(t, 1, exitCode, ui.ErrorWriter.String())
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+func TestRun_WaitReady(t *testing.T) {
+	t.Parallel()
+	var requests int32
+	envoy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/ready", r.URL.Path)
+		// Envoy is ready on the third request.
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, "PRE_INITIALIZING")
+			return
+		}
+		fmt.Fprint(w, "LIVE")
+	}))
+	defer envoy.Close()
+
+	ui := cli.NewMockUi()
+	cmd := Command{UI: ui, pollInterval: 10 * time.Millisecond}
+	code := cmd.Run([]string{"-wait-ready", "-envoy-admin-addr", strings.TrimPrefix(envoy.URL, "http://")})
+	require.Equal(t, 0, code, ui.ErrorWriter.String())
+	require.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestRun_WaitReadyTimeout(t *testing.T) {
+	t.Parallel()
+	envoy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer envoy.Close()
+
+	ui := cli.NewMockUi()
+	cmd := Command{UI: ui, pollInterval: 10 * time.Millisecond}
+	code := cmd.Run([]string{"-wait-ready", "-timeout=50ms", "-envoy-admin-addr", strings.TrimPrefix(envoy.URL, "http://")})
+	require.Equal(t, 1, code)
+}
+
+func TestRun_Drain(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		// connections are the active connections of the public listener
+		// returned by consecutive stats requests. The last value is
+		// returned for all later requests.
+		connections []int
+		timeout     string
+		expStats    int32
+	}{
+		"no active connections": {
+			connections: []int{0},
+			timeout:     "1s",
+			expStats:    1,
+		},
+		"connections close": {
+			connections: []int{2, 1, 0},
+			timeout:     "1s",
+			expStats:    3,
+		},
+		"connections stay open": {
+			connections: []int{1},
+			timeout:     "50ms",
+		},
+	}
+
+	for name, c := range cases {
+		c := c
+		t.Run(name, func(t *testing.T) {
+			var drained, stats int32
+			envoy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/drain_listeners":
+					require.Equal(t, http.MethodPost, r.Method)
+					require.Equal(t, "inboundonly&graceful", r.URL.RawQuery)
+					atomic.StoreInt32(&drained, 1)
+				case "/stats":
+					require.Equal(t, int32(1), atomic.LoadInt32(&drained), "stats were read before draining")
+					i := int(atomic.AddInt32(&stats, 1)) - 1
+					if i >= len(c.connections) {
+						i = len(c.connections) - 1
+					}
+					// The outbound listener, the listener of the other service
+					// of a multi-port pod and the admin listener have active
+					// connections that don't hold off the drain.
+					fmt.Fprintf(w, "http.public_listener.downstream_cx_active: 7\n"+
+						"listener.0.0.0.0_20001.downstream_cx_active: %d\n"+
+						"listener.0.0.0.0_20000.downstream_cx_active: 4\n"+
+						"listener.127.0.0.1_15001.downstream_cx_active: 3\n"+
+						"listener.admin.downstream_cx_active: 1\n", c.connections[i])
+				default:
+					t.Errorf("unexpected request %s", r.URL)
+				}
+			}))
+			defer envoy.Close()
+
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui, pollInterval: 10 * time.Millisecond}
+			code := cmd.Run([]string{"-drain", "-timeout", c.timeout, "-inbound-port", "20001", "-envoy-admin-addr", strings.TrimPrefix(envoy.URL, "http://")})
+			// Draining never fails the hook.
+			require.Equal(t, 0, code, ui.ErrorWriter.String())
+			require.Equal(t, int32(1), atomic.LoadInt32(&drained))
+			if c.expStats != 0 {
+				require.Equal(t, c.expStats, atomic.LoadInt32(&stats))
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package importconfigentries
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	"github.com/hashicorp/consul-k8s/control-plane/consul"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand"
+	"github.com/hashicorp/consul-k8s/control-plane/subcommand/flags"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/mitchellh/cli"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+// supportedKinds are the config entry kinds that have a custom resource, in
+// the order they are imported.
+var supportedKinds = []string{
+	capi.ProxyDefaults,
+	capi.MeshConfig,
+	capi.ServiceDefaults,
+	capi.ServiceResolver,
+	capi.ServiceRouter,
+	capi.ServiceSplitter,
+	capi.ServiceIntentions,
+	capi.IngressGateway,
+	capi.TerminatingGateway,
+	capi.ExportedServices,
+}
+
+// Command imports the config entries in Consul as custom resources.
+type Command struct {
+	UI cli.Ui
+
+	flags *flag.FlagSet
+	k8s   *flags.K8SFlags
+	http  *flags.HTTPFlags
+
+	flagK8sNamespace     string
+	flagOutputDir        string
+	flagApply            bool
+	flagKinds            []string
+	flagEnableNamespaces bool
+
+	// consulClient and k8sClient might be set in tests.
+	consulClient *capi.Client
+	k8sClient    client.Client
+	scheme       *runtime.Scheme
+
+	once sync.Once
+	help string
+}
+
+func (c *Command) init() {
+	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
+	c.flags.StringVar(&c.flagK8sNamespace, "k8s-namespace", "default",
+		"Kubernetes namespace the custom resources are created in.")
+	c.flags.StringVar(&c.flagOutputDir, "output-dir", "",
+		"Directory to write a manifest for each custom resource to.")
+	c.flags.BoolVar(&c.flagApply, "apply", false,
+		"Create the custom resources in Kubernetes.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagKinds), "kind",
+		fmt.Sprintf("Config entry kind to import. May be specified multiple times. "+
+			"Defaults to all kinds that have a custom resource: %s.", strings.Join(supportedKinds, ", ")))
+	c.flags.BoolVar(&c.flagEnableNamespaces, "enable-namespaces", false,
+		"[Enterprise Only] Validate the custom resources for a controller with Consul Enterprise namespaces enabled.")
+
+	c.k8s = &flags.K8SFlags{}
+	c.http = &flags.HTTPFlags{}
+	flags.Merge(c.flags, c.k8s.Flags())
+	flags.Merge(c.flags, c.http.Flags())
+	c.help = flags.Usage(help, c.flags)
+}
+
+func (c *Command) Run(args []string) int {
+	c.once.Do(c.init)
+	if err := c.flags.Parse(args); err != nil {
+		return 1
+	}
+	if len(c.flags.Args()) > 0 {
+		c.UI.Error("Should have no non-flag arguments.")
+		return 1
+	}
+	if err := c.validateFlags(); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+	kinds := c.flagKinds
+	if len(kinds) == 0 {
+		kinds = supportedKinds
+	}
+
+	if c.scheme == nil {
+		c.scheme = runtime.NewScheme()
+		if err := v1alpha1.AddToScheme(c.scheme); err != nil {
+			c.UI.Error(fmt.Sprintf("Error adding custom resources to scheme: %s", err))
+			return 1
+		}
+	}
+	if c.consulClient == nil {
+		cfg := capi.DefaultConfig()
+		c.http.MergeOntoConfig(cfg)
+		var err error
+		c.consulClient, err = consul.NewClient(cfg, c.http.ConsulAPITimeout())
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error creating Consul client: %s", err))
+			return 1
+		}
+	}
+	if c.flagApply && c.k8sClient == nil {
+		config, err := subcommand.K8SConfig(c.k8s.KubeConfig())
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error retrieving Kubernetes auth: %s", err))
+			return 1
+		}
+		c.k8sClient, err = client.New(config, client.Options{Scheme: c.scheme})
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error initializing Kubernetes client: %s", err))
+			return 1
+		}
+	}
+
+	consulMeta := common.ConsulMeta{
+		PartitionsEnabled: c.http.Partition() != "",
+		Partition:         c.http.Partition(),
+		NamespacesEnabled: c.flagEnableNamespaces,
+	}
+
+	imported := 0
+	var failed []string
+	for _, kind := range kinds {
+		entries, _, err := c.consulClient.ConfigEntries().List(kind, nil)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error listing %s config entries: %s", kind, err))
+			return 1
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].GetName() < entries[j].GetName() })
+
+		for _, entry := range entries {
+			// Config entries created by the controller already have a custom
+			// resource, so importing them would create a duplicate.
+			if entry.GetMeta()[common.SourceKey] == common.SourceValue {
+				c.UI.Info(fmt.Sprintf("Skipped %s/%s: already managed by Kubernetes", entry.GetKind(), entry.GetName()))
+				continue
+			}
+			resource, err := c.importConfigEntry(entry, consulMeta)
+			if err != nil {
+				failed = append(failed, fmt.Sprintf("%s/%s: %s", entry.GetKind(), entry.GetName(), err))
+				continue
+			}
+			c.UI.Info(fmt.Sprintf("Imported %s/%s as %s/%s", entry.GetKind(), entry.GetName(), resource.KubeKind(), resource.KubernetesName()))
+			imported++
+		}
+	}
+
+	c.UI.Info(fmt.Sprintf("Imported %d config entries", imported))
+	if len(failed) > 0 {
+		c.UI.Error(fmt.Sprintf("%d config entries could not be imported:", len(failed)))
+		for _, f := range failed {
+			c.UI.Error("  " + f)
+		}
+		return 1
+	}
+	return 0
+}
+
+// importConfigEntry converts the config entry into a custom resource and
+// writes it to the output directory or creates it in Kubernetes. The
+// resource has the migrate-entry annotation so that the controller takes
+// over the config entry even though it was not created from Kubernetes.
+func (c *Command) importConfigEntry(entry capi.ConfigEntry, consulMeta common.ConsulMeta) (common.ConfigEntryResource, error) {
+	resource, err := fromConsul(entry)
+	if err != nil {
+		return nil, err
+	}
+	if errs := validation.IsDNS1123Subdomain(resource.KubernetesName()); len(errs) > 0 {
+		return nil, fmt.Errorf("name is not a valid Kubernetes name: %s", strings.Join(errs, ", "))
+	}
+	// Fields of the config entry that the custom resource doesn't support
+	// are lost in the conversion. The controller wouldn't migrate the config
+	// entry since it doesn't match the custom resource.
+	if !resource.MatchesConsul(entry) {
+		return nil, errors.New("config entry has fields that are not supported by the custom resource")
+	}
+	if err := resource.Validate(consulMeta); err != nil {
+		return nil, err
+	}
+
+	gvk, err := apiutil.GVKForObject(resource, c.scheme)
+	if err != nil {
+		return nil, err
+	}
+	resource.GetObjectKind().SetGroupVersionKind(gvk)
+	resource.SetNamespace(c.flagK8sNamespace)
+	resource.SetAnnotations(map[string]string{common.MigrateEntryKey: common.MigrateEntryTrue})
+
+	if c.flagApply {
+		err := c.k8sClient.Create(context.Background(), resource)
+		if k8serrors.IsAlreadyExists(err) {
+			return nil, errors.New("custom resource already exists in Kubernetes")
+		} else if err != nil {
+			return nil, fmt.Errorf("creating custom resource: %s", err)
+		}
+	}
+	if c.flagOutputDir != "" {
+		manifest, err := manifest(resource)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(c.flagOutputDir, fmt.Sprintf("%s-%s.yaml", resource.KubeKind(), resource.KubernetesName()))
+		if err := os.WriteFile(path, manifest, 0644); err != nil {
+			return nil, fmt.Errorf("writing manifest: %s", err)
+		}
+	}
+	return resource, nil
+}
+
+func (c *Command) validateFlags() error {
+	if c.flagOutputDir == "" && !c.flagApply {
+		return errors.New("-output-dir or -apply must be set")
+	}
+	if c.flagOutputDir != "" {
+		if info, err := os.Stat(c.flagOutputDir); err != nil || !info.IsDir() {
+			return fmt.Errorf("-output-dir %q must be an existing directory", c.flagOutputDir)
+		}
+	}
+	for _, kind := range c.flagKinds {
+		if !contains(supportedKinds, kind) {
+			return fmt.Errorf("-kind %q must be one of %s", kind, strings.Join(supportedKinds, ", "))
+		}
+	}
+	return nil
+}
+
+// fromConsul converts the config entry into the custom resource of its kind.
+func fromConsul(entry capi.ConfigEntry) (common.ConfigEntryResource, error) {
+	switch e := entry.(type) {
+	case *capi.ProxyConfigEntry:
+		return v1alpha1.ProxyDefaultsFromConsul(e), nil
+	case *capi.MeshConfigEntry:
+		return v1alpha1.MeshFromConsul(e), nil
+	case *capi.ServiceConfigEntry:
+		return v1alpha1.ServiceDefaultsFromConsul(e), nil
+	case *capi.ServiceResolverConfigEntry:
+		return v1alpha1.ServiceResolverFromConsul(e), nil
+	case *capi.ServiceRouterConfigEntry:
+		return v1alpha1.ServiceRouterFromConsul(e), nil
+	case *capi.ServiceSplitterConfigEntry:
+		return v1alpha1.ServiceSplitterFromConsul(e), nil
+	case *capi.ServiceIntentionsConfigEntry:
+		return v1alpha1.ServiceIntentionsFromConsul(e), nil
+	case *capi.IngressGatewayConfigEntry:
+		return v1alpha1.IngressGatewayFromConsul(e), nil
+	case *capi.TerminatingGatewayConfigEntry:
+		return v1alpha1.TerminatingGatewayFromConsul(e), nil
+	case *capi.ExportedServicesConfigEntry:
+		return v1alpha1.ExportedServicesFromConsul(e), nil
+	default:
+		return nil, fmt.Errorf("config entry kind %q has no custom resource", entry.GetKind())
+	}
+}
+
+// manifest returns the YAML manifest of the resource without its status and
+// the fields set by Kubernetes.
+func manifest(resource common.ConfigEntryResource) ([]byte, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(resource)
+	if err != nil {
+		return nil, err
+	}
+	delete(obj, "status")
+	unstructured.RemoveNestedField(obj, "metadata", "creationTimestamp")
+	return yaml.Marshal(obj)
+}
+
+func contains(slice []string, s string) bool {
+	for _, e := range slice {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Command) Synopsis() string { return synopsis }
+func (c *Command) Help() string {
+	c.once.Do(c.init)
+	return c.help
+}
+
+const synopsis = "Import config entries from Consul as custom resources."
+const help = `
+Usage: consul-k8s-control-plane import-config-entries [options]
+
+  Reads the config entries of all kinds that have a custom resource from
+  Consul and converts them into custom resources. The custom resources are
+  written as manifests to -output-dir and/or created in Kubernetes with
+  -apply. They have the consul.hashicorp.com/migrate-entry annotation so
+  that the controller takes over the existing config entries.
+
+  Config entries that cannot be represented as custom resources, e.g.
+  because they have fields the custom resource doesn't support, are
+  reported and the command exits with an error.
+
+  Config entries that were created from Kubernetes are skipped since they
+  already have a custom resource.
+
+  With Consul Enterprise namespaces, run the command once per Consul
+  namespace, set with CONSUL_NAMESPACE, and set -k8s-namespace to the
+  Kubernetes namespace the controller maps to that Consul namespace.
+`
@@ -0,0 +1,202 @@
+package importconfigentries
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/consul-k8s/control-plane/api/common"
+	"github.com/hashicorp/consul-k8s/control-plane/api/v1alpha1"
+	capi "github.com/hashicorp/consul/api"
+	"github.com/hashicorp/consul/sdk/testutil"
+	"github.com/mitchellh/cli"
+	"github.com/stretchr/testify/require"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/yaml"
+)
+
+func TestRun_FlagValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		args   []string
+		expErr string
+	}{
+		{
+			args:   []string{},
+			expErr: "-output-dir or -apply must be set",
+		},
+		{
+			args:   []string{"-output-dir=/does/not/exist"},
+			expErr: `-output-dir "/does/not/exist" must be an existing directory`,
+		},
+		{
+			args:   []string{"-apply", "-kind=api-gateway"},
+			expErr: `-kind "api-gateway" must be one of`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.expErr, func(t *testing.T) {
+			ui := cli.NewMockUi()
+			cmd := Command{UI: ui}
+			responseCode := cmd.Run(c.args)
+			require.Equal(t, 1, responseCode)
+			require.Contains(t, ui.ErrorWriter.String(), c.expErr)
+		})
+	}
+}
+
+func TestImportConfigEntry(t *testing.T) {
+	t.Parallel()
+	cases := map[string]struct {
+		entry    capi.ConfigEntry
+		existing bool
+		expErr   string
+	}{
+		"service defaults": {
+			entry: &capi.ServiceConfigEntry{
+				Kind:     capi.ServiceDefaults,
+				Name:     "web",
+				Protocol: "http",
+			},
+		},
+		"name is not a valid kubernetes name": {
+			entry: &capi.ServiceConfigEntry{
+				Kind:     capi.ServiceDefaults,
+				Name:     "Web_1",
+				Protocol: "http",
+			},
+			expErr: "name is not a valid Kubernetes name",
+		},
+		"unsupported field": {
+			entry: &capi.ServiceConfigEntry{
+				Kind:        capi.ServiceDefaults,
+				Name:        "web",
+				Destination: &capi.DestinationConfig{Addresses: []string{"example.com"}, Port: 443},
+			},
+			expErr: "config entry has fields that are not supported by the custom resource",
+		},
+		"invalid custom resource": {
+			entry: &capi.ServiceConfigEntry{
+				Kind:             capi.ServiceDefaults,
+				Name:             "web",
+				TransparentProxy: &capi.TransparentProxyConfig{OutboundListenerPort: 1000},
+			},
+			expErr: "spec.transparentProxy.outboundListenerPort: Invalid value: 1000",
+		},
+		"custom resource already exists": {
+			entry: &capi.ServiceConfigEntry{
+				Kind:     capi.ServiceDefaults,
+				Name:     "web",
+				Protocol: "http",
+			},
+			existing: true,
+			expErr:   "custom resource already exists in Kubernetes",
+		},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := runtime.NewScheme()
+			require.NoError(t, v1alpha1.AddToScheme(s))
+			clientBuilder := fake.NewClientBuilder().WithScheme(s)
+			if c.existing {
+				clientBuilder.WithRuntimeObjects(&v1alpha1.ServiceDefaults{
+					ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "consul"},
+				})
+			}
+			k8sClient := clientBuilder.Build()
+			outputDir := t.TempDir()
+
+			cmd := Command{
+				UI:        cli.NewMockUi(),
+				k8sClient: k8sClient,
+				scheme:    s,
+			}
+			cmd.init()
+			require.NoError(t, cmd.flags.Parse([]string{"-apply", "-output-dir", outputDir, "-k8s-namespace", "consul"}))
+
+			_, err := cmd.importConfigEntry(c.entry, common.ConsulMeta{})
+			if c.expErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.expErr)
+				return
+			}
+			require.NoError(t, err)
+
+			// The custom resource is created in Kubernetes.
+			var svcDefaults v1alpha1.ServiceDefaults
+			require.NoError(t, k8sClient.Get(context.Background(), types.NamespacedName{Name: "web", Namespace: "consul"}, &svcDefaults))
+			require.Equal(t, common.MigrateEntryTrue, svcDefaults.Annotations[common.MigrateEntryKey])
+			require.Equal(t, "http", svcDefaults.Spec.Protocol)
+
+			// The manifest is written to the output directory.
+			manifest, err := os.ReadFile(filepath.Join(outputDir, "servicedefaults-web.yaml"))
+			require.NoError(t, err)
+			var fromManifest v1alpha1.ServiceDefaults
+			require.NoError(t, yaml.Unmarshal(manifest, &fromManifest))
+			require.Equal(t, "ServiceDefaults", fromManifest.Kind)
+			require.Equal(t, "consul.hashicorp.com/v1alpha1", fromManifest.APIVersion)
+			require.Equal(t, "consul", fromManifest.Namespace)
+			require.Equal(t, common.MigrateEntryTrue, fromManifest.Annotations[common.MigrateEntryKey])
+			require.Equal(t, svcDefaults.Spec, fromManifest.Spec)
+			require.NotContains(t, string(manifest), "status")
+		})
+	}
+}
+
+// Test that config entries created outside of Kubernetes are imported and
+// the ones that cannot be represented as custom resources are reported.
+func TestRun_ImportsConfigEntries(t *testing.T) {
+	t.Parallel()
+	consul, err := testutil.NewTestServerConfigT(t, nil)
+	require.NoError(t, err)
+	defer consul.Stop()
+	consul.WaitForServiceIntentions(t)
+
+	consulClient, err := capi.NewClient(&capi.Config{Address: consul.HTTPAddr})
+	require.NoError(t, err)
+	entries := []capi.ConfigEntry{
+		&capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "web", Protocol: "http"},
+		&capi.ServiceResolverConfigEntry{Kind: capi.ServiceResolver, Name: "web", DefaultSubset: "v1", Subsets: map[string]capi.ServiceResolverSubset{
+			"v1": {Filter: "Service.Meta.version == v1"},
+		}},
+		&capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "Legacy_Service", Protocol: "http"},
+		&capi.ServiceConfigEntry{Kind: capi.ServiceDefaults, Name: "api", Protocol: "http", Meta: map[string]string{
+			common.SourceKey: common.SourceValue,
+		}},
+	}
+	for _, entry := range entries {
+		_, _, err := consulClient.ConfigEntries().Set(entry, nil)
+		require.NoError(t, err)
+	}
+
+	s := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(s))
+	k8sClient := fake.NewClientBuilder().WithScheme(s).Build()
+	ui := cli.NewMockUi()
+	cmd := Command{
+		UI:           ui,
+		consulClient: consulClient,
+		k8sClient:    k8sClient,
+		scheme:       s,
+	}
+	responseCode := cmd.Run([]string{"-apply"})
+	require.Equal(t, 1, responseCode)
+	require.Contains(t, ui.OutputWriter.String(), "Imported 2 config entries")
+	require.Contains(t, ui.ErrorWriter.String(), "service-defaults/Legacy_Service: name is not a valid Kubernetes name")
+	require.Contains(t, ui.OutputWriter.String(), "Skipped service-defaults/api: already managed by Kubernetes")
+
+	var resolver v1alpha1.ServiceResolver
+	require.NoError(t, k8sClient.Get(context.Background(), types.NamespacedName{Name: "web", Namespace: "default"}, &resolver))
+	require.Equal(t, "v1", resolver.Spec.DefaultSubset)
+	require.True(t, resolver.MatchesConsul(entries[1]))
+
+	// Config entries created from Kubernetes are not imported again.
+	var svcDefaults v1alpha1.ServiceDefaults
+	err = k8sClient.Get(context.Background(), types.NamespacedName{Name: "api", Namespace: "default"}, &svcDefaults)
+	require.True(t, k8serrors.IsNotFound(err))
+}
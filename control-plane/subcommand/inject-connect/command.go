@@ -22,10 +22,15 @@ import (
 	"go.uber.org/zap/zapcore"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/klog/v2"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -65,8 +70,9 @@ type Command struct {
 	flagCrossNamespaceACLPolicy    string // The name of the ACL policy to add to every created namespace if ACLs are enabled
 
 	// Flags for endpoints controller.
-	flagReleaseName      string
-	flagReleaseNamespace string
+	flagReleaseName          string
+	flagReleaseNamespace     string
+	flagEnableEndpointSlices bool
 
 	// Proxy resource settings.
 	flagDefaultSidecarProxyCPULimit      string
@@ -97,10 +103,37 @@ type Command struct {
 	// Transparent proxy flags.
 	flagDefaultEnableTransparentProxy          bool
 	flagTransparentProxyDefaultOverwriteProbes bool
+	flagEnableNetworkPolicies                  bool
 
 	// Peering flags.
 	flagEnablePeering bool
 
+	// ProxyConfig flags.
+	flagEnableProxyConfigs bool
+
+	// Injection profile flags.
+	flagInjectionProfilesConfigMap string
+
+	// Annotation validation flags.
+	flagWarnOnInvalidAnnotations bool
+
+	// Registration status flags.
+	flagEnableRegistrationStatus        bool
+	flagEnableRegistrationReadinessGate bool
+
+	// Sidecar lifecycle flags.
+	flagDefaultSidecarProxyShutdownGracePeriodSeconds int
+	flagDefaultSidecarProxyHoldApplicationUntilReady  bool
+	flagEnableNativeSidecars                          bool
+
+	// Multi-port flags.
+	flagEnableMultiPortSharedServiceAccount bool
+
+	// Agentless registration flags.
+	flagEnableAgentlessRegistration bool
+	flagConsulServerHost            string
+	flagConsulServerGRPCPort        int
+
 	// Consul DNS flags.
 	flagEnableConsulDNS bool
 	flagResourcePrefix  string
@@ -124,7 +157,7 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
-	// We need v1alpha1 here to add the peering api to the scheme
+	// We need v1alpha1 here to add the peering and proxy config apis to the scheme
 	utilruntime.Must(v1alpha1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
@@ -142,6 +175,37 @@ func (c *Command) init() {
 	c.flagSet.StringVar(&c.flagConsulK8sImage, "consul-k8s-image", "",
 		"Docker image for consul-k8s. Used for the connect sidecar.")
 	c.flagSet.BoolVar(&c.flagEnablePeering, "enable-peering", false, "Enable cluster peering controllers.")
+	c.flagSet.BoolVar(&c.flagEnableProxyConfigs, "enable-proxy-configs", false,
+		"Apply the sidecar settings and upstreams of ProxyConfig resources to the pods they select.")
+	c.flagSet.StringVar(&c.flagInjectionProfilesConfigMap, "injection-profiles-configmap", "",
+		"Name of the ConfigMap in the release namespace with the injection profiles that pods and namespaces "+
+			"can select with the consul.hashicorp.com/injection-profile annotation and label.")
+	c.flagSet.BoolVar(&c.flagWarnOnInvalidAnnotations, "warn-on-invalid-annotations", false,
+		"Admit pods with unknown or invalid consul.hashicorp.com annotations with admission warnings instead of rejecting them.")
+	c.flagSet.BoolVar(&c.flagEnableRegistrationStatus, "enable-registration-status", false,
+		"Emit events on pods and services when their instances are registered with or deregistered from Consul "+
+			"and maintain the consul.hashicorp.com/registered condition of pods.")
+	c.flagSet.BoolVar(&c.flagEnableRegistrationReadinessGate, "enable-registration-readiness-gate", false,
+		"Add a readiness gate for the consul.hashicorp.com/registered condition to injected pods. "+
+			"Requires -enable-registration-status.")
+	c.flagSet.IntVar(&c.flagDefaultSidecarProxyShutdownGracePeriodSeconds, "default-sidecar-proxy-shutdown-grace-period-seconds", 0,
+		"Default number of seconds the Envoy sidecar drains its inbound connections for before it is stopped. "+
+			"It must be lower than the terminationGracePeriodSeconds of the pods. 0 disables draining.")
+	c.flagSet.BoolVar(&c.flagDefaultSidecarProxyHoldApplicationUntilReady, "default-sidecar-proxy-hold-application-until-ready", false,
+		"Start the application containers of injected pods only once the Envoy sidecar is ready by default.")
+	c.flagSet.BoolVar(&c.flagEnableNativeSidecars, "enable-native-sidecars", false,
+		"Inject the sidecars as native sidecar containers, which Kubernetes starts before and stops after the "+
+			"application containers so that Jobs complete. Ignored if the Kubernetes version is older than 1.29.")
+	c.flagSet.BoolVar(&c.flagEnableMultiPortSharedServiceAccount, "enable-multiport-shared-service-account", false,
+		"Let the services of multi port pods log in with the service account of the pod instead of one service "+
+			"account per service. ACL binding rules grant the service account the service identity of each service.")
+	c.flagSet.BoolVar(&c.flagEnableAgentlessRegistration, "enable-agentless-registration", false,
+		"Register services directly with the catalog of the Consul servers instead of with the Consul client "+
+			"agents running on the nodes of the pods.")
+	c.flagSet.StringVar(&c.flagConsulServerHost, "consul-server-host", "",
+		"Host of the Consul servers that injected pods talk to when -enable-agentless-registration is set.")
+	c.flagSet.IntVar(&c.flagConsulServerGRPCPort, "consul-server-grpc-port", 8503,
+		"Port of the gRPC API of the Consul servers that Envoy talks to when -enable-agentless-registration is set.")
 	c.flagSet.StringVar(&c.flagEnvoyExtraArgs, "envoy-extra-args", "",
 		"Extra envoy command line args to be set when starting envoy (e.g \"--log-level debug --disable-hot-restart\").")
 	c.flagSet.StringVar(&c.flagACLAuthMethod, "acl-auth-method", "",
@@ -158,6 +222,8 @@ func (c *Command) init() {
 		"K8s namespaces to explicitly deny. Takes precedence over allow. May be specified multiple times.")
 	c.flagSet.StringVar(&c.flagReleaseName, "release-name", "consul", "The Consul Helm installation release name, e.g 'helm install <RELEASE-NAME>'")
 	c.flagSet.StringVar(&c.flagReleaseNamespace, "release-namespace", "default", "The Consul Helm installation namespace, e.g 'helm install <RELEASE-NAME> --namespace <RELEASE-NAMESPACE>'")
+	c.flagSet.BoolVar(&c.flagEnableEndpointSlices, "enable-endpoint-slices", false,
+		"Reconcile the EndpointSlices of services instead of Endpoints. Requires Kubernetes 1.21+.")
 	c.flagSet.BoolVar(&c.flagEnablePartitions, "enable-partitions", false,
 		"[Enterprise Only] Enables Admin Partitions.")
 	c.flagSet.BoolVar(&c.flagEnableNamespaces, "enable-namespaces", false,
@@ -176,6 +242,9 @@ func (c *Command) init() {
 		"Enable transparent proxy mode for all Consul service mesh applications by default.")
 	c.flagSet.BoolVar(&c.flagTransparentProxyDefaultOverwriteProbes, "transparent-proxy-default-overwrite-probes", true,
 		"Overwrite Kubernetes probes to point to Envoy by default when in Transparent Proxy mode.")
+	c.flagSet.BoolVar(&c.flagEnableNetworkPolicies, "enable-network-policies", false,
+		"Generate a NetworkPolicy for each ServiceIntentions resource that only allows ingress to the "+
+			"sidecar proxies of the destination from the pods of the allowed sources and the gateways of the release.")
 	c.flagSet.BoolVar(&c.flagEnableConsulDNS, "enable-consul-dns", false,
 		"Enables Consul DNS lookup for services in the mesh.")
 	c.flagSet.StringVar(&c.flagResourcePrefix, "resource-prefix", "",
@@ -316,6 +385,22 @@ func (c *Command) Run(args []string) int {
 		}
 	}
 
+	// Native sidecars are only supported by Kubernetes 1.29+. Older API
+	// servers drop the restartPolicy of the sidecars, which then block the
+	// application containers from starting.
+	enableNativeSidecars := c.flagEnableNativeSidecars
+	if enableNativeSidecars {
+		supported, err := nativeSidecarsSupported(c.clientset)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("error checking the Kubernetes version for native sidecars: %s", err))
+			return 1
+		}
+		if !supported {
+			c.UI.Warn("-enable-native-sidecars requires Kubernetes 1.29+, injecting sidecars as regular containers")
+			enableNativeSidecars = false
+		}
+	}
+
 	// Create Consul API config object.
 	cfg := api.DefaultConfig()
 	c.http.MergeOntoConfig(cfg)
@@ -405,34 +490,63 @@ func (c *Command) Run(args []string) int {
 	}
 
 	if err = (&connectinject.EndpointsController{
-		Client:                     mgr.GetClient(),
-		ConsulClient:               c.consulClient,
-		ConsulScheme:               consulURL.Scheme,
-		ConsulPort:                 consulURL.Port(),
-		AllowK8sNamespacesSet:      allowK8sNamespaces,
-		DenyK8sNamespacesSet:       denyK8sNamespaces,
-		MetricsConfig:              metricsConfig,
-		ConsulClientCfg:            cfg,
-		EnableConsulPartitions:     c.flagEnablePartitions,
-		EnableConsulNamespaces:     c.flagEnableNamespaces,
-		ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
-		EnableNSMirroring:          c.flagEnableK8SNSMirroring,
-		NSMirroringPrefix:          c.flagK8SNSMirroringPrefix,
-		CrossNSACLPolicy:           c.flagCrossNamespaceACLPolicy,
-		EnableTransparentProxy:     c.flagDefaultEnableTransparentProxy,
-		TProxyOverwriteProbes:      c.flagTransparentProxyDefaultOverwriteProbes,
-		AuthMethod:                 c.flagACLAuthMethod,
-		Log:                        ctrl.Log.WithName("controller").WithName("endpoints"),
-		Scheme:                     mgr.GetScheme(),
-		ReleaseName:                c.flagReleaseName,
-		ReleaseNamespace:           c.flagReleaseNamespace,
-		Context:                    ctx,
-		ConsulAPITimeout:           c.http.ConsulAPITimeout(),
+		Client:                              mgr.GetClient(),
+		ConsulClient:                        c.consulClient,
+		ConsulScheme:                        consulURL.Scheme,
+		ConsulPort:                          consulURL.Port(),
+		AllowK8sNamespacesSet:               allowK8sNamespaces,
+		DenyK8sNamespacesSet:                denyK8sNamespaces,
+		MetricsConfig:                       metricsConfig,
+		ConsulClientCfg:                     cfg,
+		EnableConsulPartitions:              c.flagEnablePartitions,
+		EnableConsulNamespaces:              c.flagEnableNamespaces,
+		ConsulDestinationNamespace:          c.flagConsulDestinationNamespace,
+		EnableNSMirroring:                   c.flagEnableK8SNSMirroring,
+		NSMirroringPrefix:                   c.flagK8SNSMirroringPrefix,
+		CrossNSACLPolicy:                    c.flagCrossNamespaceACLPolicy,
+		EnableTransparentProxy:              c.flagDefaultEnableTransparentProxy,
+		TProxyOverwriteProbes:               c.flagTransparentProxyDefaultOverwriteProbes,
+		AuthMethod:                          c.flagACLAuthMethod,
+		Log:                                 ctrl.Log.WithName("controller").WithName("endpoints"),
+		Scheme:                              mgr.GetScheme(),
+		ReleaseName:                         c.flagReleaseName,
+		ReleaseNamespace:                    c.flagReleaseNamespace,
+		Context:                             ctx,
+		ConsulAPITimeout:                    c.http.ConsulAPITimeout(),
+		EnableEndpointSlices:                c.flagEnableEndpointSlices,
+		EnableProxyConfigs:                  c.flagEnableProxyConfigs,
+		EnableRegistrationStatus:            c.flagEnableRegistrationStatus,
+		Recorder:                            mgr.GetEventRecorderFor("consul-endpoints-controller"),
+		EnableAgentlessRegistration:         c.flagEnableAgentlessRegistration,
+		EnableMultiPortSharedServiceAccount: c.flagEnableMultiPortSharedServiceAccount,
+		APIReader:                           mgr.GetAPIReader(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", connectinject.EndpointsController{})
 		return 1
 	}
 
+	if c.flagEnableAgentlessRegistration {
+		if err = (&connectinject.SyntheticNodeController{
+			Client:                 mgr.GetClient(),
+			ConsulClient:           c.consulClient,
+			EnableConsulNamespaces: c.flagEnableNamespaces,
+			Log:                    ctrl.Log.WithName("controller").WithName("synthetic-node"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "synthetic-node")
+			return 1
+		}
+	}
+
+	if c.flagEnableRegistrationReadinessGate {
+		if err = (&connectinject.RegistrationGateController{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controller").WithName("registration-gate"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "registration-gate")
+			return 1
+		}
+	}
+
 	if err = mgr.AddReadyzCheck("ready", connectinject.ReadinessCheck{CertDir: c.flagCertDir}.Ready); err != nil {
 		setupLog.Error(err, "unable to create readiness check", "controller", connectinject.EndpointsController{})
 		return 1
@@ -474,44 +588,105 @@ func (c *Command) Run(args []string) int {
 			}})
 	}
 
+	if c.flagEnableNetworkPolicies {
+		// The merged metrics are scraped from outside the mesh so their port
+		// stays open to all pods.
+		var allowedPorts []int32
+		if c.flagDefaultEnableMetrics {
+			port, _ := strconv.ParseInt(c.flagDefaultPrometheusScrapePort, 10, 32)
+			allowedPorts = append(allowedPorts, int32(port))
+		}
+		if err = (&connectinject.NetworkPolicyController{
+			Client:                     mgr.GetClient(),
+			ConsulClient:               c.consulClient,
+			EnableConsulNamespaces:     c.flagEnableNamespaces,
+			ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
+			EnableNSMirroring:          c.flagEnableK8SNSMirroring,
+			NSMirroringPrefix:          c.flagK8SNSMirroringPrefix,
+			AllowedPorts:               allowedPorts,
+			ReleaseName:                c.flagReleaseName,
+			ReleaseNamespace:           c.flagReleaseNamespace,
+			Log:                        ctrl.Log.WithName("controller").WithName("network-policy"),
+			Scheme:                     mgr.GetScheme(),
+			Context:                    ctx,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "network-policy")
+			return 1
+		}
+	}
+
+	if c.flagEnableProxyConfigs {
+		mgr.GetWebhookServer().Register("/mutate-v1alpha1-proxyconfigs",
+			&webhook.Admission{Handler: &v1alpha1.ProxyConfigWebhook{
+				Client: mgr.GetClient(),
+				Logger: ctrl.Log.WithName("webhooks").WithName("proxy-config"),
+			}})
+	}
+
+	// Injection profiles are read from a cache of the ConfigMap so that it
+	// isn't read from the API on every admission.
+	var injectionProfilesLister corelisters.ConfigMapNamespaceLister
+	if c.flagInjectionProfilesConfigMap != "" {
+		informerFactory := informers.NewSharedInformerFactoryWithOptions(c.clientset, 0,
+			informers.WithNamespace(c.flagReleaseNamespace),
+			informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+				opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", c.flagInjectionProfilesConfigMap).String()
+			}))
+		injectionProfilesLister = informerFactory.Core().V1().ConfigMaps().Lister().ConfigMaps(c.flagReleaseNamespace)
+		informerFactory.Start(ctx.Done())
+	}
+
 	mgr.GetWebhookServer().CertDir = c.flagCertDir
 
 	mgr.GetWebhookServer().Register("/mutate",
 		&webhook.Admission{Handler: &connectinject.MeshWebhook{
-			Clientset:                     c.clientset,
-			ConsulClient:                  c.consulClient,
-			ImageConsul:                   c.flagConsulImage,
-			ImageEnvoy:                    c.flagEnvoyImage,
-			EnvoyExtraArgs:                c.flagEnvoyExtraArgs,
-			ImageConsulK8S:                c.flagConsulK8sImage,
-			RequireAnnotation:             !c.flagDefaultInject,
-			AuthMethod:                    c.flagACLAuthMethod,
-			ConsulCACert:                  string(consulCACert),
-			DefaultProxyCPURequest:        sidecarProxyCPURequest,
-			DefaultProxyCPULimit:          sidecarProxyCPULimit,
-			DefaultProxyMemoryRequest:     sidecarProxyMemoryRequest,
-			DefaultProxyMemoryLimit:       sidecarProxyMemoryLimit,
-			DefaultEnvoyProxyConcurrency:  c.flagDefaultEnvoyProxyConcurrency,
-			MetricsConfig:                 metricsConfig,
-			InitContainerResources:        initResources,
-			DefaultConsulSidecarResources: consulSidecarResources,
-			ConsulPartition:               c.http.Partition(),
-			AllowK8sNamespacesSet:         allowK8sNamespaces,
-			DenyK8sNamespacesSet:          denyK8sNamespaces,
-			EnableNamespaces:              c.flagEnableNamespaces,
-			ConsulDestinationNamespace:    c.flagConsulDestinationNamespace,
-			EnableK8SNSMirroring:          c.flagEnableK8SNSMirroring,
-			K8SNSMirroringPrefix:          c.flagK8SNSMirroringPrefix,
-			CrossNamespaceACLPolicy:       c.flagCrossNamespaceACLPolicy,
-			EnableTransparentProxy:        c.flagDefaultEnableTransparentProxy,
-			TProxyOverwriteProbes:         c.flagTransparentProxyDefaultOverwriteProbes,
-			EnableConsulDNS:               c.flagEnableConsulDNS,
-			ResourcePrefix:                c.flagResourcePrefix,
-			EnableOpenShift:               c.flagEnableOpenShift,
-			Log:                           ctrl.Log.WithName("handler").WithName("connect"),
-			LogLevel:                      c.flagLogLevel,
-			LogJSON:                       c.flagLogJSON,
-			ConsulAPITimeout:              c.http.ConsulAPITimeout(),
+			Clientset:                       c.clientset,
+			Client:                          mgr.GetClient(),
+			ConsulClient:                    c.consulClient,
+			ImageConsul:                     c.flagConsulImage,
+			ImageEnvoy:                      c.flagEnvoyImage,
+			EnvoyExtraArgs:                  c.flagEnvoyExtraArgs,
+			ImageConsulK8S:                  c.flagConsulK8sImage,
+			RequireAnnotation:               !c.flagDefaultInject,
+			AuthMethod:                      c.flagACLAuthMethod,
+			ConsulCACert:                    string(consulCACert),
+			DefaultProxyCPURequest:          sidecarProxyCPURequest,
+			DefaultProxyCPULimit:            sidecarProxyCPULimit,
+			DefaultProxyMemoryRequest:       sidecarProxyMemoryRequest,
+			DefaultProxyMemoryLimit:         sidecarProxyMemoryLimit,
+			DefaultEnvoyProxyConcurrency:    c.flagDefaultEnvoyProxyConcurrency,
+			MetricsConfig:                   metricsConfig,
+			InitContainerResources:          initResources,
+			DefaultConsulSidecarResources:   consulSidecarResources,
+			ConsulPartition:                 c.http.Partition(),
+			AllowK8sNamespacesSet:           allowK8sNamespaces,
+			DenyK8sNamespacesSet:            denyK8sNamespaces,
+			EnableNamespaces:                c.flagEnableNamespaces,
+			ConsulDestinationNamespace:      c.flagConsulDestinationNamespace,
+			EnableK8SNSMirroring:            c.flagEnableK8SNSMirroring,
+			K8SNSMirroringPrefix:            c.flagK8SNSMirroringPrefix,
+			CrossNamespaceACLPolicy:         c.flagCrossNamespaceACLPolicy,
+			EnableTransparentProxy:          c.flagDefaultEnableTransparentProxy,
+			TProxyOverwriteProbes:           c.flagTransparentProxyDefaultOverwriteProbes,
+			EnableConsulDNS:                 c.flagEnableConsulDNS,
+			ResourcePrefix:                  c.flagResourcePrefix,
+			EnableOpenShift:                 c.flagEnableOpenShift,
+			Log:                             ctrl.Log.WithName("handler").WithName("connect"),
+			LogLevel:                        c.flagLogLevel,
+			LogJSON:                         c.flagLogJSON,
+			ConsulAPITimeout:                c.http.ConsulAPITimeout(),
+			EnableProxyConfigs:              c.flagEnableProxyConfigs,
+			InjectionProfilesConfigMap:      types.NamespacedName{Namespace: c.flagReleaseNamespace, Name: c.flagInjectionProfilesConfigMap},
+			InjectionProfilesLister:         injectionProfilesLister,
+			WarnOnInvalidAnnotations:        c.flagWarnOnInvalidAnnotations,
+			EnableRegistrationReadinessGate: c.flagEnableRegistrationReadinessGate,
+			DefaultSidecarProxyShutdownGracePeriodSeconds: c.flagDefaultSidecarProxyShutdownGracePeriodSeconds,
+			DefaultSidecarProxyHoldApplicationUntilReady:  c.flagDefaultSidecarProxyHoldApplicationUntilReady,
+			EnableNativeSidecars:                          enableNativeSidecars,
+			EnableMultiPortSharedServiceAccount:           c.flagEnableMultiPortSharedServiceAccount,
+			EnableAgentlessRegistration:                   c.flagEnableAgentlessRegistration,
+			ConsulServerHost:                              c.flagConsulServerHost,
+			ConsulServerGRPCPort:                          c.flagConsulServerGRPCPort,
 		}})
 
 	if c.flagEnableWebhookCAUpdate {
@@ -576,8 +751,43 @@ func (c *Command) validateFlags() error {
 	if c.http.ConsulAPITimeout() <= 0 {
 		return errors.New("-consul-api-timeout must be set to a value greater than 0")
 	}
+
+	if c.flagEnableRegistrationReadinessGate && !c.flagEnableRegistrationStatus {
+		return errors.New("-enable-registration-status must be set to 'true' if -enable-registration-readiness-gate is set")
+	}
+
+	if c.flagEnableAgentlessRegistration && c.flagConsulServerHost == "" {
+		return errors.New("-consul-server-host must be set if -enable-agentless-registration is set")
+	}
+
+	if c.flagEnableAgentlessRegistration && (c.flagConsulServerGRPCPort <= 0 || c.flagConsulServerGRPCPort > 65535) {
+		return errors.New("-consul-server-grpc-port must be a valid port if -enable-agentless-registration is set")
+	}
+
+	if c.flagDefaultSidecarProxyShutdownGracePeriodSeconds < 0 {
+		return errors.New("-default-sidecar-proxy-shutdown-grace-period-seconds must be >= 0 if set")
+	}
 	return nil
 }
+
+// nativeSidecarsSupported returns true if the Kubernetes API server supports
+// native sidecar containers, which are enabled by default since 1.29.
+func nativeSidecarsSupported(clientset kubernetes.Interface) (bool, error) {
+	serverVersion, err := clientset.Discovery().ServerVersion()
+	if err != nil {
+		return false, err
+	}
+	major, err := strconv.Atoi(serverVersion.Major)
+	if err != nil {
+		return false, fmt.Errorf("invalid major version %q", serverVersion.Major)
+	}
+	// Some providers suffix the minor version, e.g. "29+".
+	minor, err := strconv.Atoi(strings.TrimSuffix(serverVersion.Minor, "+"))
+	if err != nil {
+		return false, fmt.Errorf("invalid minor version %q", serverVersion.Minor)
+	}
+	return major > 1 || (major == 1 && minor >= 29), nil
+}
 func (c *Command) parseAndValidateResourceFlags() (corev1.ResourceRequirements, corev1.ResourceRequirements, error) {
 	// Init container
 	var initContainerCPULimit, initContainerCPURequest, initContainerMemoryLimit, initContainerMemoryRequest resource.Quantity
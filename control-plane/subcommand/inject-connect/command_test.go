@@ -7,6 +7,8 @@ import (
 	"github.com/hashicorp/consul/api"
 	"github.com/mitchellh/cli"
 	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
 	"k8s.io/client-go/kubernetes/fake"
 )
 
@@ -61,6 +63,27 @@ func TestRun_FlagValidation(t *testing.T) {
 				"-consul-api-timeout", "5s", "-partition", "default"},
 			expErr: "-enable-partitions must be set to 'true' if -partition-name is set",
 		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-enable-registration-readiness-gate"},
+			expErr: "-enable-registration-status must be set to 'true' if -enable-registration-readiness-gate is set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-enable-agentless-registration"},
+			expErr: "-consul-server-host must be set if -enable-agentless-registration is set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-enable-agentless-registration", "-consul-server-host", "consul-server",
+				"-consul-server-grpc-port", "0"},
+			expErr: "-consul-server-grpc-port must be a valid port if -enable-agentless-registration is set",
+		},
+		{
+			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
+				"-consul-api-timeout", "5s", "-default-sidecar-proxy-shutdown-grace-period-seconds=-1"},
+			expErr: "-default-sidecar-proxy-shutdown-grace-period-seconds must be >= 0 if set",
+		},
 		{
 			flags: []string{"-consul-k8s-image", "foo", "-consul-image", "foo", "-envoy-image", "envoy:1.16.0",
 				"-consul-api-timeout", "5s", "-default-sidecar-proxy-cpu-limit=unparseable"},
@@ -238,3 +261,32 @@ func TestRun_ValidationConsulHTTPAddr(t *testing.T) {
 	require.Equal(t, 1, code)
 	require.Contains(t, ui.ErrorWriter.String(), "error parsing consul address \"http://%\": parse \"http://%\": invalid URL escape \"%")
 }
+
+func TestNativeSidecarsSupported(t *testing.T) {
+	cases := map[string]struct {
+		major, minor string
+		exp          bool
+		expErr       string
+	}{
+		"1.28":        {major: "1", minor: "28"},
+		"1.29":        {major: "1", minor: "29", exp: true},
+		"1.30+":       {major: "1", minor: "30+", exp: true},
+		"invalid":     {major: "1", minor: "x", expErr: `invalid minor version "x"`},
+		"new major":   {major: "2", minor: "0", exp: true},
+		"empty major": {major: "", minor: "29", expErr: `invalid major version ""`},
+	}
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset()
+			clientset.Discovery().(*fakediscovery.FakeDiscovery).FakedServerVersion = &version.Info{Major: c.major, Minor: c.minor}
+
+			supported, err := nativeSidecarsSupported(clientset)
+			if c.expErr != "" {
+				require.EqualError(t, err, c.expErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.exp, supported)
+		})
+	}
+}
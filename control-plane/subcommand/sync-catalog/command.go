@@ -1,18 +1,25 @@
 package synccatalog
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"path"
 	"regexp"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	mapset "github.com/deckarep/golang-set"
+	"github.com/hashicorp/consul-k8s/control-plane/catalog/metrics"
 	catalogtoconsul "github.com/hashicorp/consul-k8s/control-plane/catalog/to-consul"
 	catalogtok8s "github.com/hashicorp/consul-k8s/control-plane/catalog/to-k8s"
 	"github.com/hashicorp/consul-k8s/control-plane/helper/controller"
@@ -22,9 +29,16 @@ import (
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/go-hclog"
 	"github.com/mitchellh/cli"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 )
 
 // Command is the command for syncing the K8S and Consul service
@@ -41,18 +55,36 @@ type Command struct {
 	flagConsulDomain          string
 	flagConsulK8STag          string
 	flagConsulNodeName        string
+	flagK8SSyncOwner          string
+	flagConsulNodePerK8SNode  bool
 	flagK8SDefault            bool
 	flagK8SServicePrefix      string
 	flagConsulServicePrefix   string
 	flagK8SSourceNamespace    string
 	flagK8SWriteNamespace     string
+	flagK8SServiceType        string
 	flagConsulWritePeriod     time.Duration
 	flagSyncClusterIPServices bool
 	flagSyncLBEndpoints       bool
+	flagSyncIngress           bool
+	flagEnableEndpointSlices  bool
 	flagNodePortSyncType      string
 	flagAddK8SNamespaceSuffix bool
 	flagLogLevel              string
 	flagLogJSON               bool
+	flagDryRun                bool
+
+	// Flags to support running multiple replicas
+	flagEnableLeaderElection    bool
+	flagLeaderElectionNamespace string
+	flagLeaderElectionID        string
+
+	// Flags to filter the services synced from Consul to K8s
+	flagAllowConsulServicesList []string          // Consul service name globs to explicitly sync
+	flagDenyConsulServicesList  []string          // Consul service name globs to never sync (has precedence)
+	flagRequiredConsulTags      []string          // Tags a Consul service must have to be synced
+	flagConsulServiceMeta       map[string]string // Meta a Consul service instance must have to be synced
+	flagConsulSourceNamespace   string            // Consul namespace to sync services from
 
 	// Flags to support namespaces
 	flagEnableNamespaces           bool     // Use namespacing on all components
@@ -62,6 +94,9 @@ type Command struct {
 	flagEnableK8SNSMirroring       bool     // Enables mirroring of k8s namespaces into Consul
 	flagK8SNSMirroringPrefix       string   // Prefix added to Consul namespaces created when mirroring
 	flagCrossNamespaceACLPolicy    string   // The name of the ACL policy to add to every created namespace if ACLs are enabled
+	flagEnableConsulNSMirroring    bool     // Enables mirroring of Consul namespaces into k8s
+	flagConsulNSMirroringPrefix    string   // Prefix removed from Consul namespaces when mirroring
+	flagK8SMissingNamespacePolicy  string   // Whether to create or skip missing k8s namespaces when mirroring
 
 	consulClient *api.Client
 	clientset    kubernetes.Interface
@@ -70,8 +105,19 @@ type Command struct {
 	sigCh  chan os.Signal
 	help   string
 	logger hclog.Logger
+
+	// leader is 1 while this replica holds the leader election lease.
+	leader int32
 }
 
+const (
+	// leaseDuration, renewDeadline and retryPeriod configure the leader
+	// election so that a standby replica takes over within seconds.
+	leaseDuration = 15 * time.Second
+	renewDeadline = 10 * time.Second
+	retryPeriod   = 2 * time.Second
+)
+
 func (c *Command) init() {
 	c.flags = flag.NewFlagSet("", flag.ContinueOnError)
 	c.flags.StringVar(&c.flagListen, "listen", ":8080", "Address to bind listener to.")
@@ -95,6 +141,15 @@ func (c *Command) init() {
 	c.flags.StringVar(&c.flagK8SWriteNamespace, "k8s-write-namespace", metav1.NamespaceDefault,
 		"The Kubernetes namespace to write to for services from Consul. "+
 			"If this is not set then it will default to the default namespace.")
+	c.flags.StringVar(&c.flagK8SSyncOwner, "k8s-sync-owner", "k8s-sync",
+		"The value of the consul.hashicorp.com/sync-owner label set on services written to Kubernetes. "+
+			"Only services with this label value are updated or deleted so it must be unique for each "+
+			"sync process writing to the cluster. Must be a valid Kubernetes label value.")
+	c.flags.StringVar(&c.flagK8SServiceType, "k8s-service-type", string(catalogtok8s.ExternalName),
+		"The type of Kubernetes service to create for services from Consul. Valid options are "+
+			"ExternalName, ClusterIP and Headless. ExternalName services point at the service's Consul "+
+			"DNS name. ClusterIP and Headless services are backed by Endpoints holding the addresses "+
+			"of the service's Consul instances.")
 	c.flags.StringVar(&c.flagConsulDomain, "consul-domain", "consul",
 		"The domain for Consul services to use when writing services to "+
 			"Kubernetes. Defaults to consul.")
@@ -103,6 +158,10 @@ func (c *Command) init() {
 	c.flags.StringVar(&c.flagConsulNodeName, "consul-node-name", "k8s-sync",
 		"The Consul node name to register for catalog sync. Defaults to k8s-sync. To be discoverable "+
 			"via DNS, the name should only contain alpha-numerics and dashes.")
+	c.flags.BoolVar(&c.flagConsulNodePerK8SNode, "consul-node-per-k8s-node", false,
+		"If true, service instances running on a Kubernetes node are registered under a Consul node "+
+			"named <consul-node-name>-<Kubernetes node name> with the node's address and topology labels "+
+			"as node meta instead of the single -consul-node-name node.")
 	c.flags.DurationVar(&c.flagConsulWritePeriod, "consul-write-interval", 30*time.Second,
 		"The interval to perform syncing operations creating Consul services, formatted "+
 			"as a time.Duration. All changes are merged and write calls are only made "+
@@ -113,6 +172,13 @@ func (c *Command) init() {
 	c.flags.BoolVar(&c.flagSyncLBEndpoints, "sync-lb-services-endpoints", false,
 		"If true, LoadBalancer service endpoints instead of ingress addresses will be synced to Consul. If false, "+
 			"LoadBalancer endpoints are not synced to Consul.")
+	c.flags.BoolVar(&c.flagEnableEndpointSlices, "enable-endpoint-slices", false,
+		"[Kubernetes -> Consul] Watch the EndpointSlices of services instead of Endpoints. "+
+			"Requires Kubernetes 1.21+.")
+	c.flags.BoolVar(&c.flagSyncIngress, "sync-ingress", false,
+		"[Kubernetes -> Consul] If true, the hosts of Ingress resources are synced to Consul "+
+			"at the address of the Ingress load balancer. The Consul service is named <ingress name>-ingress "+
+			"unless it's set with the consul.hashicorp.com/service-name annotation.")
 	c.flags.StringVar(&c.flagNodePortSyncType, "node-port-sync-type", "ExternalOnly",
 		"Defines the type of sync for NodePort services. Valid options are ExternalOnly, "+
 			"InternalOnly and ExternalFirst.")
@@ -123,9 +189,36 @@ func (c *Command) init() {
 	c.flags.StringVar(&c.flagLogLevel, "log-level", "info",
 		"Log verbosity level. Supported values (in order of detail) are \"trace\", "+
 			"\"debug\", \"info\", \"warn\", and \"error\".")
+	c.flags.BoolVar(&c.flagEnableLeaderElection, "enable-leader-election", false,
+		"Enable leader election so that only one of multiple replicas syncs at a time. "+
+			"Standby replicas keep their caches warm and take over when the leader stops.")
+	c.flags.StringVar(&c.flagLeaderElectionNamespace, "leader-election-namespace", "",
+		"Kubernetes namespace of the Lease used for leader election.")
+	c.flags.StringVar(&c.flagLeaderElectionID, "leader-election-id", "consul-sync-catalog",
+		"Name of the Lease used for leader election.")
 	c.flags.BoolVar(&c.flagLogJSON, "log-json", false,
 		"Enable or disable JSON output format for logging.")
-
+	c.flags.BoolVar(&c.flagDryRun, "dry-run", false,
+		"If true, no changes are written to Consul or Kubernetes. The changes that would have been "+
+			"written are logged and served as JSON at /dry-run on the listener.")
+
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagAllowConsulServicesList), "allow-consul-service",
+		"Consul service names to sync to Kubernetes. Supports glob patterns such as \"web-*\". "+
+			"If not set, all services are synced unless denied. May be specified multiple times.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagDenyConsulServicesList), "deny-consul-service",
+		"Consul service names to never sync to Kubernetes. Supports glob patterns such as \"web-*\". "+
+			"Takes precedence over allow. May be specified multiple times.")
+	c.flags.Var((*flags.AppendSliceValue)(&c.flagRequiredConsulTags), "require-consul-service-tag",
+		"Tag that a Consul service must have to be synced to Kubernetes. If specified multiple "+
+			"times, the service must have all of the tags.")
+	c.flags.Var((*flags.FlagMapValue)(&c.flagConsulServiceMeta), "consul-service-meta",
+		"Service meta in the form of key=value that an instance of a Consul service must have for "+
+			"the service to be synced to Kubernetes. If specified multiple times, an instance must "+
+			"have all of the key/value pairs.")
+	c.flags.StringVar(&c.flagConsulSourceNamespace, "consul-source-namespace", "",
+		"[Enterprise Only] The Consul namespace to sync services to Kubernetes from. Services are "+
+			"read from the Consul partition set by -partition. If not set, the namespace of the ACL "+
+			"token is used.")
 	c.flags.Var((*flags.AppendSliceValue)(&c.flagAllowK8sNamespacesList), "allow-k8s-namespace",
 		"K8s namespaces to explicitly allow. May be specified multiple times.")
 	c.flags.Var((*flags.AppendSliceValue)(&c.flagDenyK8sNamespacesList), "deny-k8s-namespace",
@@ -142,6 +235,15 @@ func (c *Command) init() {
 	c.flags.StringVar(&c.flagCrossNamespaceACLPolicy, "consul-cross-namespace-acl-policy", "",
 		"[Enterprise Only] Name of the ACL policy to attach to all created Consul namespaces to allow service "+
 			"discovery across Consul namespaces. Only necessary if ACLs are enabled.")
+	c.flags.BoolVar(&c.flagEnableConsulNSMirroring, "enable-consul-namespace-mirroring", false,
+		"[Enterprise Only] Enables mirroring of Consul namespaces into Kubernetes. Services from every Consul "+
+			"namespace are synced into the Kubernetes namespace of the same name instead of -k8s-write-namespace.")
+	c.flags.StringVar(&c.flagConsulNSMirroringPrefix, "consul-namespace-mirroring-prefix", "",
+		"[Enterprise Only] Prefix that will be removed from Consul namespaces when mirroring them into Kubernetes "+
+			"if mirroring is enabled.")
+	c.flags.StringVar(&c.flagK8SMissingNamespacePolicy, "k8s-missing-namespace-policy", string(catalogtok8s.SkipNamespace),
+		"[Enterprise Only] What to do when the Kubernetes namespace mirroring a Consul namespace does not exist "+
+			"if mirroring is enabled. Valid options are Create and Skip.")
 
 	c.http = &flags.HTTPFlags{}
 	c.k8s = &flags.K8SFlags{}
@@ -220,12 +322,43 @@ func (c *Command) Run(args []string) int {
 	}
 	c.logger.Info("K8s namespace syncing configuration", "k8s namespaces allowed to be synced", allowSet,
 		"k8s namespaces denied from syncing", denySet)
+	if c.flagToK8S {
+		c.logger.Info("Consul service syncing configuration",
+			"consul services allowed to be synced", c.flagAllowConsulServicesList,
+			"consul services denied from syncing", c.flagDenyConsulServicesList,
+			"required consul service tags", c.flagRequiredConsulTags,
+			"required consul service meta", c.flagConsulServiceMeta)
+	}
+
+	if c.flagDryRun {
+		c.logger.Warn("running in dry-run mode, no changes will be written to Consul or Kubernetes")
+	}
 
 	// Create the context we'll use to cancel everything
 	ctx, cancelF := context.WithCancel(context.Background())
 
+	// The metrics of both directions are served on the listener at /metrics.
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	syncMetrics := metrics.New(registry)
+
+	// leaderTasks write to Consul, so they are only started once this
+	// replica is elected leader, or immediately without leader election.
+	// Everything else runs on all replicas so that standby replicas keep
+	// their caches warm. The Kubernetes writes of the to-k8s sink are held
+	// off until elected is closed.
+	var leaderTasks []func()
+	var elected chan struct{}
+	if c.flagEnableLeaderElection {
+		elected = make(chan struct{})
+	}
+
 	// Start the K8S-to-Consul syncer
 	var toConsulCh chan struct{}
+	var syncer *catalogtoconsul.ConsulSyncer
 	if c.flagToConsul {
 		// If namespaces are enabled we need to use a new Consul API endpoint
 		// to list node services. This endpoint is only available in Consul
@@ -242,7 +375,7 @@ func (c *Command) Run(args []string) int {
 			}
 		}
 		// Build the Consul sync and start it
-		syncer := &catalogtoconsul.ConsulSyncer{
+		syncer = &catalogtoconsul.ConsulSyncer{
 			Client:                   c.consulClient,
 			Log:                      c.logger.Named("to-consul/sink"),
 			EnableNamespaces:         c.flagEnableNamespaces,
@@ -252,60 +385,106 @@ func (c *Command) Run(args []string) int {
 			ConsulK8STag:             c.flagConsulK8STag,
 			ConsulNodeName:           c.flagConsulNodeName,
 			ConsulNodeServicesClient: svcsClient,
+			Metrics:                  syncMetrics,
+			DryRun:                   c.flagDryRun,
 		}
-		go syncer.Run(ctx)
+		leaderTasks = append(leaderTasks, func() { go syncer.Run(ctx) })
+
+		// Pods and nodes are read from a cache since the pods and nodes of
+		// endpoint addresses are looked up while generating registrations.
+		informerFactory := informers.NewSharedInformerFactory(c.clientset, 0)
+		podLister := informerFactory.Core().V1().Pods().Lister()
+		nodeLister := informerFactory.Core().V1().Nodes().Lister()
 
 		// Build the controller and start it
+		serviceResource := &catalogtoconsul.ServiceResource{
+			Log:                        c.logger.Named("to-consul/source"),
+			Client:                     c.clientset,
+			Syncer:                     syncer,
+			Ctx:                        ctx,
+			PodLister:                  podLister,
+			NodeLister:                 nodeLister,
+			AllowK8sNamespacesSet:      allowSet,
+			DenyK8sNamespacesSet:       denySet,
+			ExplicitEnable:             !c.flagK8SDefault,
+			ClusterIPSync:              c.flagSyncClusterIPServices,
+			LoadBalancerEndpointsSync:  c.flagSyncLBEndpoints,
+			EnableEndpointSlices:       c.flagEnableEndpointSlices,
+			NodePortSync:               catalogtoconsul.NodePortSyncType(c.flagNodePortSyncType),
+			ConsulK8STag:               c.flagConsulK8STag,
+			ConsulServicePrefix:        c.flagConsulServicePrefix,
+			AddK8SNamespaceSuffix:      c.flagAddK8SNamespaceSuffix,
+			EnableNamespaces:           c.flagEnableNamespaces,
+			ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
+			EnableK8SNSMirroring:       c.flagEnableK8SNSMirroring,
+			K8SNSMirroringPrefix:       c.flagK8SNSMirroringPrefix,
+			ConsulNodeName:             c.flagConsulNodeName,
+			ConsulNodePerK8SNode:       c.flagConsulNodePerK8SNode,
+		}
 		ctl := &controller.Controller{
-			Log: c.logger.Named("to-consul/controller"),
-			Resource: &catalogtoconsul.ServiceResource{
-				Log:                        c.logger.Named("to-consul/source"),
-				Client:                     c.clientset,
-				Syncer:                     syncer,
-				Ctx:                        ctx,
-				AllowK8sNamespacesSet:      allowSet,
-				DenyK8sNamespacesSet:       denySet,
-				ExplicitEnable:             !c.flagK8SDefault,
-				ClusterIPSync:              c.flagSyncClusterIPServices,
-				LoadBalancerEndpointsSync:  c.flagSyncLBEndpoints,
-				NodePortSync:               catalogtoconsul.NodePortSyncType(c.flagNodePortSyncType),
-				ConsulK8STag:               c.flagConsulK8STag,
-				ConsulServicePrefix:        c.flagConsulServicePrefix,
-				AddK8SNamespaceSuffix:      c.flagAddK8SNamespaceSuffix,
-				EnableNamespaces:           c.flagEnableNamespaces,
-				ConsulDestinationNamespace: c.flagConsulDestinationNamespace,
-				EnableK8SNSMirroring:       c.flagEnableK8SNSMirroring,
-				K8SNSMirroringPrefix:       c.flagK8SNSMirroringPrefix,
-				ConsulNodeName:             c.flagConsulNodeName,
-			},
+			Log:      c.logger.Named("to-consul/controller"),
+			Resource: serviceResource,
 		}
 
+		// The ingress controller shares the service resource so that
+		// services and ingresses are synced as a single set.
+		var ingressCtl *controller.Controller
+		if c.flagSyncIngress {
+			ingressCtl = &controller.Controller{
+				Log:      c.logger.Named("to-consul/ingress-controller"),
+				Resource: &catalogtoconsul.IngressResource{Service: serviceResource},
+			}
+		}
+
+		informerFactory.Start(ctx.Done())
 		toConsulCh = make(chan struct{})
 		go func() {
 			defer close(toConsulCh)
+			informerFactory.WaitForCacheSync(ctx.Done())
+			if ingressCtl != nil {
+				go ingressCtl.Run(ctx.Done())
+			}
 			ctl.Run(ctx.Done())
 		}()
 	}
 
 	// Start Consul-to-K8S sync
 	var toK8SCh chan struct{}
+	var sink *catalogtok8s.K8SSink
 	if c.flagToK8S {
-		sink := &catalogtok8s.K8SSink{
-			Client:    c.clientset,
-			Namespace: c.flagK8SWriteNamespace,
-			Log:       c.logger.Named("to-k8s/sink"),
-			Ctx:       ctx,
+		serviceType := catalogtok8s.K8SServiceType(c.flagK8SServiceType)
+		sink = &catalogtok8s.K8SSink{
+			Client:                 c.clientset,
+			Namespace:              c.flagK8SWriteNamespace,
+			Owner:                  c.flagK8SSyncOwner,
+			MirrorNamespaces:       c.flagEnableConsulNSMirroring,
+			MissingNamespacePolicy: catalogtok8s.MissingNamespacePolicy(c.flagK8SMissingNamespacePolicy),
+			ServiceType:            serviceType,
+			Log:                    c.logger.Named("to-k8s/sink"),
+			Ctx:                    ctx,
+			Metrics:                syncMetrics,
+			DryRun:                 c.flagDryRun,
+			Elected:                elected,
 		}
 
 		source := &catalogtok8s.Source{
-			Client:       c.consulClient,
-			Domain:       c.flagConsulDomain,
-			Sink:         sink,
-			Prefix:       c.flagK8SServicePrefix,
-			Log:          c.logger.Named("to-k8s/source"),
-			ConsulK8STag: c.flagConsulK8STag,
+			Client:        c.consulClient,
+			Domain:        c.flagConsulDomain,
+			Sink:          sink,
+			Prefix:        c.flagK8SServicePrefix,
+			Log:           c.logger.Named("to-k8s/source"),
+			ConsulK8STag:  c.flagConsulK8STag,
+			SyncEndpoints: serviceType != catalogtok8s.ExternalName,
+
+			AllowServices:   c.flagAllowConsulServicesList,
+			DenyServices:    c.flagDenyConsulServicesList,
+			RequiredTags:    c.flagRequiredConsulTags,
+			ServiceMeta:     c.flagConsulServiceMeta,
+			ConsulNamespace: c.flagConsulSourceNamespace,
+
+			EnableConsulNSMirroring: c.flagEnableConsulNSMirroring,
+			ConsulNSMirroringPrefix: c.flagConsulNSMirroringPrefix,
 		}
-		go source.Run(ctx)
 
 		// Build the controller and start it
 		ctl := &controller.Controller{
@@ -313,6 +492,8 @@ func (c *Command) Run(args []string) int {
 			Resource: sink,
 		}
 
+		go source.Run(ctx)
+
 		toK8SCh = make(chan struct{})
 		go func() {
 			defer close(toK8SCh)
@@ -320,10 +501,41 @@ func (c *Command) Run(args []string) int {
 		}()
 	}
 
-	// Start healthcheck handler
+	// Start leader election, or the leader tasks if it isn't enabled
+	var electedCh, electionCh chan struct{}
+	if c.flagEnableLeaderElection {
+		elector, err := c.leaderElector(elected)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("Error setting up leader election: %s", err))
+			cancelF()
+			for _, ch := range []chan struct{}{toConsulCh, toK8SCh} {
+				if ch != nil {
+					<-ch
+				}
+			}
+			return 1
+		}
+
+		electedCh = elected
+		electionCh = make(chan struct{})
+		go func() {
+			defer close(electionCh)
+			elector.Run(ctx)
+		}()
+	} else {
+		for _, task := range leaderTasks {
+			task()
+		}
+	}
+
+	// Start healthcheck and metrics handler
 	go func() {
 		mux := http.NewServeMux()
 		mux.HandleFunc("/health/ready", c.handleReady)
+		mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		if c.flagDryRun {
+			mux.HandleFunc("/dry-run", dryRunHandler(syncer, sink))
+		}
 		var handler http.Handler = mux
 
 		c.UI.Info(fmt.Sprintf("Listening on %q...", c.flagListen))
@@ -332,34 +544,125 @@ func (c *Command) Run(args []string) int {
 		}
 	}()
 
-	select {
-	// Unexpected exit
-	case <-toConsulCh:
+	// stop cancels everything and waits for it to exit. The leader
+	// election lease is released on exit so that a standby replica can
+	// take over right away.
+	stop := func() {
 		cancelF()
-		if toK8SCh != nil {
-			<-toK8SCh
+		for _, ch := range []chan struct{}{toConsulCh, toK8SCh, electionCh} {
+			if ch != nil {
+				<-ch
+			}
 		}
-		return 1
+	}
 
-	// Unexpected exit
-	case <-toK8SCh:
-		cancelF()
-		if toConsulCh != nil {
-			<-toConsulCh
+	for {
+		select {
+		// Elected leader, start syncing
+		case <-electedCh:
+			electedCh = nil
+			for _, task := range leaderTasks {
+				task()
+			}
+
+		// Unexpected exit
+		case <-toConsulCh:
+			stop()
+			return 1
+
+		// Unexpected exit
+		case <-toK8SCh:
+			stop()
+			return 1
+
+		// Lost leadership, exit so that we restart as a standby
+		case <-electionCh:
+			c.logger.Info("leader election lost, shutting down")
+			stop()
+			return 1
+
+		// Interrupted/terminated, gracefully exit
+		case sig := <-c.sigCh:
+			c.logger.Info(fmt.Sprintf("%s received, shutting down", sig))
+			stop()
+			return 0
 		}
-		return 1
+	}
+}
 
-	// Interrupted/terminated, gracefully exit
-	case sig := <-c.sigCh:
-		c.logger.Info(fmt.Sprintf("%s received, shutting down", sig))
-		cancelF()
-		if toConsulCh != nil {
-			<-toConsulCh
+// leaderElector returns the leader elector for the sync. The elected channel
+// is closed once this replica is elected leader.
+func (c *Command) leaderElector(elected chan struct{}) (*leaderelection.LeaderElector, error) {
+	identity, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+
+	return leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock: &resourcelock.LeaseLock{
+			LeaseMeta: metav1.ObjectMeta{
+				Name:      c.flagLeaderElectionID,
+				Namespace: c.flagLeaderElectionNamespace,
+			},
+			Client: c.clientset.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{
+				Identity: identity,
+			},
+		},
+		ReleaseOnCancel: true,
+		LeaseDuration:   leaseDuration,
+		RenewDeadline:   renewDeadline,
+		RetryPeriod:     retryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(context.Context) {
+				c.logger.Info("elected leader, starting sync", "identity", identity)
+				atomic.StoreInt32(&c.leader, 1)
+				close(elected)
+			},
+			OnStoppedLeading: func() {
+				c.logger.Info("stopped leading", "identity", identity)
+				atomic.StoreInt32(&c.leader, 0)
+			},
+			OnNewLeader: func(leader string) {
+				c.logger.Info("new leader elected", "leader", leader)
+			},
+		},
+	})
+}
+
+// dryRunPlan is the JSON body served at /dry-run. It holds the changes the
+// last sync in each enabled direction would have written.
+type dryRunPlan struct {
+	ToConsul *catalogtoconsul.SyncPlan `json:"toConsul,omitempty"`
+	ToK8S    *catalogtok8s.SinkPlan    `json:"toK8S,omitempty"`
+}
+
+// dryRunHandler returns a handler serving the plans of the given syncer and
+// sink as JSON. Either may be nil if its direction isn't enabled.
+func dryRunHandler(syncer *catalogtoconsul.ConsulSyncer, sink *catalogtok8s.K8SSink) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		var plan dryRunPlan
+		if syncer != nil {
+			p := syncer.Plan()
+			plan.ToConsul = &p
+		}
+		if sink != nil {
+			p := sink.Plan()
+			plan.ToK8S = &p
 		}
-		if toK8SCh != nil {
-			<-toK8SCh
+
+		// Encode into a buffer first so that an error can still be reported
+		// with the status code.
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plan); err != nil {
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
 		}
-		return 0
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(http.StatusOK)
+		rw.Write(buf.Bytes())
 	}
 }
 
@@ -372,6 +675,18 @@ func (c *Command) handleReady(rw http.ResponseWriter, req *http.Request) {
 		rw.WriteHeader(500)
 		return
 	}
+
+	// With leader election, both the leader and standby replicas are ready
+	// and the body reports which one this replica is.
+	if c.flagEnableLeaderElection {
+		state := "follower"
+		if atomic.LoadInt32(&c.leader) == 1 {
+			state = "leader"
+		}
+		rw.WriteHeader(200)
+		fmt.Fprint(rw, state)
+		return
+	}
 	rw.WriteHeader(204)
 }
 
@@ -411,6 +726,38 @@ func (c *Command) validateFlags() error {
 		)
 	}
 
+	for _, pattern := range append(c.flagAllowConsulServicesList, c.flagDenyConsulServicesList...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%q is not a valid Consul service name pattern: %s", pattern, err)
+		}
+	}
+
+	if c.flagEnableLeaderElection && c.flagLeaderElectionNamespace == "" {
+		return errors.New("-leader-election-namespace must be set when -enable-leader-election is set")
+	}
+
+	if c.flagEnableConsulNSMirroring && !c.flagEnableNamespaces {
+		return errors.New("-enable-consul-namespace-mirroring requires -enable-namespaces")
+	}
+
+	switch catalogtok8s.MissingNamespacePolicy(c.flagK8SMissingNamespacePolicy) {
+	case catalogtok8s.CreateNamespace, catalogtok8s.SkipNamespace:
+	default:
+		return fmt.Errorf("-k8s-missing-namespace-policy=%s is invalid: valid options are %s and %s",
+			c.flagK8SMissingNamespacePolicy, catalogtok8s.CreateNamespace, catalogtok8s.SkipNamespace)
+	}
+
+	if errs := validation.IsValidLabelValue(c.flagK8SSyncOwner); len(errs) > 0 {
+		return fmt.Errorf("-k8s-sync-owner=%s is invalid: %s", c.flagK8SSyncOwner, strings.Join(errs, ", "))
+	}
+
+	switch catalogtok8s.K8SServiceType(c.flagK8SServiceType) {
+	case catalogtok8s.ExternalName, catalogtok8s.ClusterIP, catalogtok8s.Headless:
+	default:
+		return fmt.Errorf("-k8s-service-type=%s is invalid: valid options are %s, %s and %s",
+			c.flagK8SServiceType, catalogtok8s.ExternalName, catalogtok8s.ClusterIP, catalogtok8s.Headless)
+	}
+
 	return nil
 }
 
@@ -2,11 +2,17 @@ package synccatalog
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
 
+	catalogtok8s "github.com/hashicorp/consul-k8s/control-plane/catalog/to-k8s"
 	"github.com/hashicorp/consul/api"
 	"github.com/hashicorp/consul/sdk/testutil"
 	"github.com/hashicorp/consul/sdk/testutil/retry"
@@ -36,6 +42,26 @@ func TestRun_FlagValidation(t *testing.T) {
 			ExpErr: "-consul-node-name=5r9OPGfSRXUdGzNjBdAwmhCBrzHDNYs4XjZVR4wp7lSLIzqwS0ta51nBLIN0TMPV-too-long is invalid: node name will not be discoverable " +
 				"via DNS due to it being too long. Valid lengths are between 1 and 63 bytes",
 		},
+		{
+			Flags:  []string{"-k8s-service-type=NodePort"},
+			ExpErr: "-k8s-service-type=NodePort is invalid: valid options are ExternalName, ClusterIP and Headless",
+		},
+		{
+			Flags:  []string{"-deny-consul-service=web-[a"},
+			ExpErr: "\"web-[a\" is not a valid Consul service name pattern: syntax error in pattern",
+		},
+		{
+			Flags:  []string{"-enable-consul-namespace-mirroring"},
+			ExpErr: "-enable-consul-namespace-mirroring requires -enable-namespaces",
+		},
+		{
+			Flags:  []string{"-enable-leader-election"},
+			ExpErr: "-leader-election-namespace must be set when -enable-leader-election is set",
+		},
+		{
+			Flags:  []string{"-k8s-missing-namespace-policy=Fail"},
+			ExpErr: "-k8s-missing-namespace-policy=Fail is invalid: valid options are Create and Skip",
+		},
 	}
 
 	for _, c := range cases {
@@ -576,6 +602,85 @@ func TestRun_ToConsulChangingFlags(t *testing.T) {
 	}
 }
 
+// Test that the leader elector acquires the lease, reports leadership and
+// releases the lease when cancelled.
+func TestLeaderElector(t *testing.T) {
+	t.Parallel()
+	k8s := fake.NewSimpleClientset()
+	cmd := Command{
+		clientset:                   k8s,
+		logger:                      hclog.New(&hclog.LoggerOptions{Level: hclog.Debug}),
+		flagLeaderElectionID:        "consul-sync-catalog",
+		flagLeaderElectionNamespace: metav1.NamespaceDefault,
+	}
+
+	elected := make(chan struct{})
+	elector, err := cmd.leaderElector(elected)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		elector.Run(ctx)
+	}()
+
+	select {
+	case <-elected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting to be elected")
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&cmd.leader))
+
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	lease, err := k8s.CoordinationV1().Leases(metav1.NamespaceDefault).Get(context.Background(), "consul-sync-catalog", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Equal(t, hostname, *lease.Spec.HolderIdentity)
+
+	// Cancelling releases the lease.
+	cancel()
+	<-done
+	require.Equal(t, int32(0), atomic.LoadInt32(&cmd.leader))
+	lease, err = k8s.CoordinationV1().Leases(metav1.NamespaceDefault).Get(context.Background(), "consul-sync-catalog", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Empty(t, *lease.Spec.HolderIdentity)
+}
+
+// Test that the readiness endpoint reports whether the replica is the leader.
+func TestHandleReady_leaderElection(t *testing.T) {
+	t.Parallel()
+	consul := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `"127.0.0.1:8300"`)
+	}))
+	defer consul.Close()
+	client, err := api.NewClient(&api.Config{Address: consul.URL})
+	require.NoError(t, err)
+
+	cmd := Command{
+		UI:                       cli.NewMockUi(),
+		consulClient:             client,
+		flagEnableLeaderElection: true,
+	}
+	for _, leader := range []int32{0, 1} {
+		atomic.StoreInt32(&cmd.leader, leader)
+		rec := httptest.NewRecorder()
+		cmd.handleReady(rec, httptest.NewRequest("GET", "/health/ready", nil))
+		require.Equal(t, 200, rec.Code)
+		if leader == 1 {
+			require.Equal(t, "leader", rec.Body.String())
+		} else {
+			require.Equal(t, "follower", rec.Body.String())
+		}
+	}
+
+	// Without leader election, the endpoint keeps returning no content.
+	cmd.flagEnableLeaderElection = false
+	rec := httptest.NewRecorder()
+	cmd.handleReady(rec, httptest.NewRequest("GET", "/health/ready", nil))
+	require.Equal(t, 204, rec.Code)
+}
+
 // Set up test consul agent and fake kubernetes cluster client.
 func completeSetup(t *testing.T) (*fake.Clientset, *testutil.TestServer) {
 	k8s := fake.NewSimpleClientset()
@@ -636,3 +741,17 @@ func lbService(name, lbIP string) *apiv1.Service {
 		},
 	}
 }
+
+// Test that the dry-run plans are only served for the enabled directions.
+func TestDryRunHandler(t *testing.T) {
+	t.Parallel()
+	rec := httptest.NewRecorder()
+	dryRunHandler(nil, &catalogtok8s.K8SSink{})(rec, httptest.NewRequest("GET", "/dry-run", nil))
+	require.Equal(t, 200, rec.Code)
+	require.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+	var plan map[string]interface{}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &plan))
+	require.Contains(t, plan, "toK8S")
+	require.NotContains(t, plan, "toConsul")
+}